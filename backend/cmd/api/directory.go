@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/directory"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/jobs"
+)
+
+// runDirectorySyncCommand handles `api directory-sync`: it runs one sync from the configured source,
+// prints its change report and returns instead of starting the server. Useful from cron or after a manual HR drop.
+func runDirectorySyncCommand(db *sql.DB, directoryConfig config.DirectoryConfig, logger *slog.Logger) error {
+	auditService := audit.NewService(audit.NewRepository(db, logger), logger)
+	service := directory.NewService(directory.NewRepository(db, logger), directoryConfig, jobs.NewRunner(1, logger), auditService, logger)
+
+	run, err := service.Sync(context.Background(), directory.TriggerCLI, nil)
+	if run != nil {
+		var report []directory.Change
+		if len(run.Report) > 0 {
+			if err := json.Unmarshal(run.Report, &report); err != nil {
+				return fmt.Errorf("decode sync report: %w", err)
+			}
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "USER ID\tUSERNAME\tACTION\tCHANGES")
+		for _, change := range report {
+			fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", change.UserID, change.Username, change.Action, change.Changes)
+		}
+		writer.Flush()
+		fmt.Printf("run %d %s: %s\n", run.ID, run.Status, run.Summary)
+	}
+	return err
+}
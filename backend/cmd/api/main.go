@@ -2,20 +2,44 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/asset"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/auth"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/costcenter"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/department"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/depreciation"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/directory"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/disposal"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/email"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/health"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/jobs"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/loan"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/location"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/metrics"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/migrate"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/opname"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/repair"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/report"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/site"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/templates"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/timeout"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/transfer"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/upload"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/user"
 	"github.com/gin-contrib/cors"
@@ -24,30 +48,41 @@ import (
 	_ "github.com/lib/pq" // PostgreSQL driver
 )
 
-// Get environment variable with fallback.
-func getenv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return def
-}
+// Shutdown budgets: in-flight HTTP requests get shutdownHTTPTimeout, background jobs (emails, PDFs) get shutdownJobsTimeout.
+const (
+	shutdownHTTPTimeout = 15 * time.Second
+	shutdownJobsTimeout = 30 * time.Second
+)
+
+// jobConcurrency bounds how many background jobs (emails, PDF generation) run at the same time.
+const jobConcurrency = 4
 
 func main() {
-	// Resolve DB connection parameters from environment (Docker friendly) with sensible defaults.
-	host := getenv("DB_HOST", "localhost")
-	portStr := getenv("DB_PORT", "5433")
-	port, _ := strconv.Atoi(portStr)
-	username := getenv("DB_USER", "sosmit_admin")
-	password := getenv("DB_PASSWORD", "admin123")
-	dbName := getenv("DB_NAME", "sosmit_db")
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, username, password, dbName)
+	// Load the configuration: defaults, then the optional YAML file (-config or CONFIG_FILE), then the environment.
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to an optional YAML configuration file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Structured logger shared by every package, configured by log.level (debug|info|warn|error) and log.format (json|text).
+	logger := logging.New(os.Stdout, logging.ParseLevel(cfg.Log.Level), cfg.Log.Format)
+	slog.SetDefault(logger)
+	logger.Info("loaded configuration", "config_file", *configPath, "templates_override_dir", cfg.Paths.Templates, "uploads_dir", cfg.Paths.Uploads, "timezone", cfg.App.Timezone)
+
+	host, port, dbName := cfg.Database.Host, cfg.Database.Port, cfg.Database.Name
+	dsn := cfg.Database.DSN()
 	db, err := sql.Open("postgres", dsn)
 	for trial := 0; err != nil && trial < 3; trial++ {
-		log.Printf("Attempt %d: Error connecting to the database: %v", trial+1, err)
+		logger.Warn("failed to connect to the database", "attempt", trial+1, "host", host, "port", port, "error", err)
 		time.Sleep(2 * time.Second) // Wait before retrying
 		db, err = sql.Open("postgres", dsn)
 		if trial == 2 {
-			log.Fatalln("Error connecting to database after 3 attempts:", err, "\nExiting program...")
+			logger.Error("failed to connect to the database after 3 attempts, exiting", "error", err)
+			os.Exit(1)
 		}
 	}
 
@@ -56,54 +91,160 @@ func main() {
 
 	// Test the connection to the database with ping.
 	if err := db.Ping(); err != nil {
-		log.Fatalln("Error pinging the database:", err)
-	} else {
-		log.Println("✅ Successfully connected to the database!")
+		logger.Error("failed to ping the database, exiting", "host", host, "port", port, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("connected to the database", "host", host, "port", port, "database", dbName)
+
+	// `api migrate up|down [N]|status` manages the schema and exits without starting the server.
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrateCommand(db, flag.Args()[1:], logger); err != nil {
+			logger.Error("migration command failed", "error", err)
+			db.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// `api directory-sync` runs one directory sync and exits without starting the server.
+	if flag.Arg(0) == "directory-sync" {
+		if err := runDirectorySyncCommand(db, cfg.Directory, logger); err != nil {
+			logger.Error("directory sync failed", "error", err)
+			db.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Optionally bring the schema up to date before serving, e.g. in docker-compose.
+	if cfg.Database.AutoMigrate {
+		migrator, err := migrate.NewMigrator(db, logger)
+		if err == nil {
+			_, err = migrator.Up(context.Background())
+		}
+		if err != nil {
+			logger.Error("failed to apply database migrations, exiting", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// The backend and the stored procedures both check the admin positions, so both get auth.admin_positions.
+	roles.SetAdminPositions(cfg.Auth.AdminPositions)
+	if err := roles.NewRepository(db, logger).SyncAdminPositions(context.Background(), cfg.Auth.AdminPositions); err != nil {
+		logger.Error("failed to sync the admin positions, exiting", "error", err)
+		os.Exit(1)
 	}
 
 	// Initialize the Gin router which is a web framework for Go.
 	// This will be used to handle HTTP requests and define routes for the API.
-	router := gin.Default()
+	// Request logging is done by the structured logging middleware instead of gin's default logger.
+	// The audit middleware runs before the error middleware so it sees the final status code.
+	auditService := audit.NewService(audit.NewRepository(db, logger), logger)
+	router := gin.New()
+	// Only listed proxies may set the client IP through X-Forwarded-For, so it cannot be spoofed to dodge the login throttle.
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Error("invalid trusted proxies, exiting", "error", err)
+		os.Exit(1)
+	}
+	router.Use(logging.RequestMiddleware(logger), metrics.Middleware(), gin.Recovery(), timeout.Middleware(cfg.Server.RequestTimeout, cfg.Server.RouteTimeouts), audit.Middleware(auditService), apperr.Middleware(logger))
+	metrics.RegisterDB(db, dbName)
 
 	// Initialize the user repository with the database connection.
-	userRepo := user.NewRepository(db)
-	assetRepo := asset.NewRepository(db)
-	opnameRepo := opname.NewRepository(db)
-	siteRepo := site.NewRepository(db)
-	reportRepo := report.NewRepository(db)
-	deptRepo := department.NewRepository(db)
+	userRepo := user.NewRepository(db, logger)
+	assetRepo := asset.NewRepository(db, logger)
+	opnameRepo := opname.NewRepository(db, logger)
+	siteRepo := site.NewRepository(db, logger)
+	reportRepo := report.NewRepository(db, logger)
+	deptRepo := department.NewRepository(db, logger)
+	authRepo := auth.NewRepository(db, logger)
+	directoryRepo := directory.NewRepository(db, logger)
+	locationRepo := location.NewRepository(db, logger)
+	costCenterRepo := costcenter.NewRepository(db, logger)
+	transferRepo := transfer.NewRepository(db, logger)
+	loanRepo := loan.NewRepository(db, logger)
+	repairRepo := repair.NewRepository(db, logger)
+	disposalRepo := disposal.NewRepository(db, logger)
+	depreciationRepo := depreciation.NewRepository(db, logger)
+
+	// Parse every HTML template once, failing fast if an override is malformed.
+	templateSet, err := templates.Load(cfg.Paths.Templates, report.TemplateFuncs(), logger)
+	if err != nil {
+		logger.Error("failed to load templates, exiting", "error", err)
+		os.Exit(1)
+	}
+
+	// Background job runner owning every goroutine that outlives a request.
+	jobRunner := jobs.NewRunner(jobConcurrency, logger.With("component", "jobs"))
 
 	// Initialize the services
-	uploadService := upload.NewService()
-	emailService := email.NewService()
-	authService := auth.NewService(userRepo)
-	userService := user.NewService(userRepo)
-	assetService := asset.NewService(assetRepo)
-	siteService := site.NewService(siteRepo)
-	deptService := department.NewService(deptRepo)
-	reportService := report.NewService(reportRepo)
-	opnameService := opname.NewService(opnameRepo, uploadService, userRepo, siteRepo, emailService, reportService)
+	uploadService := upload.NewService(cfg.Paths.Uploads, logger)
+	emailService := email.NewService(cfg.Email, templateSet, logger)
+	authService := auth.NewService(userRepo, authRepo, cfg.Auth, cfg.OIDC, cfg.App, logger)
+	userService := user.NewService(userRepo, logger)
+	reportService := report.NewService(reportRepo, templateSet, cfg.Opname, cfg.App.Location(), logger)
+	assetService := asset.NewService(assetRepo, reportService, logger)
+	siteService := site.NewService(siteRepo, logger)
+	deptService := department.NewService(deptRepo, logger)
+	opnameService := opname.NewService(opnameRepo, uploadService, userRepo, siteRepo, emailService, reportService, jobRunner, cfg.Opname, cfg.App, logger)
+	locationService := location.NewService(locationRepo, deptService, logger)
+	costCenterService := costcenter.NewService(costCenterRepo, logger)
+	transferService := transfer.NewService(transferRepo, reportService, cfg.App.Location(), logger)
+	loanService := loan.NewService(loanRepo, uploadService, emailService, jobRunner, cfg.Loans, cfg.App, logger)
+	repairService := repair.NewService(repairRepo, uploadService, logger)
+	disposalService := disposal.NewService(disposalRepo, reportService, cfg.Disposal, cfg.App.Location(), logger)
+	depreciationService := depreciation.NewService(depreciationRepo, logger)
+	directoryService := directory.NewService(directoryRepo, cfg.Directory, jobRunner, auditService, logger)
+
+	// Sync users from the company directory every day at directory.schedule, if configured.
+	directoryService.Schedule(cfg.App.Location())
+
+	// Remind the borrowers of overdue loans every day at loans.reminder_schedule, if configured.
+	loanService.Schedule()
 
 	// Initialize the handlers
-	authHandler := auth.NewHandler(authService)
-	userHandler := user.NewHandler(userService)
-	assetHandler := asset.NewHandler(assetService)
-	opnameHandler := opname.NewHandler(opnameService)
-	siteHandler := site.NewHandler(siteService)
-	deptHandler := department.NewHandler(deptService)
-	uploadHandler := upload.NewHandler(uploadService)
-	reportHandler := report.NewHandler(reportService)
+	authHandler := auth.NewHandler(authService, logger)
+	userHandler := user.NewHandler(userService, logger)
+	assetHandler := asset.NewHandler(assetService, logger)
+	opnameHandler := opname.NewHandler(opnameService, logger)
+	siteHandler := site.NewHandler(siteService, logger)
+	deptHandler := department.NewHandler(deptService, logger)
+	uploadHandler := upload.NewHandler(uploadService, logger)
+	reportHandler := report.NewHandler(reportService, logger)
+	healthHandler := health.NewHandler(db, cfg.Paths.Uploads, logger)
+	auditHandler := audit.NewHandler(auditService, logger)
+	directoryHandler := directory.NewHandler(directoryService, logger)
+	locationHandler := location.NewHandler(locationService, logger)
+	costCenterHandler := costcenter.NewHandler(costCenterService, logger)
+	transferHandler := transfer.NewHandler(transferService, logger)
+	loanHandler := loan.NewHandler(loanService, logger)
+	repairHandler := repair.NewHandler(repairService, logger)
+	disposalHandler := disposal.NewHandler(disposalService, logger)
+	depreciationHandler := depreciation.NewHandler(depreciationService, logger)
+
+	// Every /api route except login checks the caller's token with auth.jwt_secret.
+	requireAuth := auth.AuthMiddleware(cfg.Auth, userRepo)
 
 	// Setup the static file server route for serving uploaded files.
-	router.Static("/uploads", "../uploads")
+	router.Static("/uploads", cfg.Paths.Uploads)
 
 	// Setup CORS (Cross-Origin Resource Sharing) middleware.
 	// This allows us to handle requests from the Angular frontend.
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:4200"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
-	router.Use(cors.New(config))
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = cfg.Server.AllowedOrigins
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", logging.RequestIDHeader}
+	corsConfig.ExposeHeaders = []string{logging.RequestIDHeader}
+	router.Use(cors.New(corsConfig))
+
+	// Operational endpoints for the platform team, outside /api and without authentication.
+	// GET /healthz
+	router.GET("/healthz", healthHandler.LivenessHandler)
+
+	// GET /readyz
+	router.GET("/readyz", healthHandler.ReadinessHandler)
+
+	// GET /metrics
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	// Define the routes for the API.
 	// In simple terms, a route is a URL path that the server listens to and responds to.
@@ -114,12 +255,21 @@ func main() {
 		{
 			// POST /api/auth/login
 			authRoutes.POST("/login", authHandler.LoginHandler)
+
+			// GET /api/auth/oidc/login, redirects to the OIDC provider
+			authRoutes.GET("/oidc/login", authHandler.OIDCLoginHandler)
+
+			// GET /api/auth/oidc/callback, the provider redirects back here, then to the frontend with the token
+			authRoutes.GET("/oidc/callback", authHandler.OIDCCallbackHandler)
+
+			// POST /api/auth/unlock
+			authRoutes.POST("/unlock", requireAuth, authHandler.UnlockAccountHandler)
 		}
 
 		userRoutes := api.Group("/user")
 
 		// This route group is protected by the AuthMiddleware, which checks for a valid JWT token.
-		userRoutes.Use(auth.AuthMiddleware())
+		userRoutes.Use(requireAuth)
 		{
 			// GET /api/user/me
 			userRoutes.GET("/me", userHandler.GetMeHandler)
@@ -130,11 +280,26 @@ func main() {
 			// GET /api/user/opname-locations
 			userRoutes.GET("/opname-locations", userHandler.GetUserOpnameLocationsHandler)
 
-			// GET /api/user/all
+			// GET /api/user/all?search=&position=&department=&division=&site_id=&cost_center_id=&is_active=&limit=&page_num=
 			userRoutes.GET("/all", userHandler.GetAllUsersHandler)
+
+			// POST /api/user
+			userRoutes.POST("", userHandler.CreateUserHandler)
+
+			// PUT /api/user/:user-id
+			userRoutes.PUT("/:user-id", userHandler.UpdateUserHandler)
+
+			// PUT /api/user/:user-id/password
+			userRoutes.PUT("/:user-id/password", userHandler.ResetPasswordHandler)
+
+			// PUT /api/user/:user-id/deactivate
+			userRoutes.PUT("/:user-id/deactivate", userHandler.DeactivateUserHandler)
+
+			// PUT /api/user/:user-id/reactivate
+			userRoutes.PUT("/:user-id/reactivate", userHandler.ReactivateUserHandler)
 		}
 
-		siteRoutes := api.Group("/site").Use(auth.AuthMiddleware())
+		siteRoutes := api.Group("/site").Use(requireAuth)
 		{
 			// GET /api/site/assets
 			siteRoutes.GET("/assets", assetHandler.GetAssetsOnLocationHandler)
@@ -155,25 +320,40 @@ func main() {
 			siteRoutes.GET("/all-sub-sites", siteHandler.GetAllSubSitesHandler)
 		}
 
-		deptRoutes := api.Group("/department").Use(auth.AuthMiddleware())
+		deptRoutes := api.Group("/department").Use(requireAuth)
 		{
+			// GET /api/department/all
+			deptRoutes.GET("/all", deptHandler.GetAllDeptsHandler)
+
 			// GET /api/department/:id
 			deptRoutes.GET("/:id", deptHandler.GetDeptByIDHandler)
 		}
 
-		assetRoutes := api.Group("/asset").Use(auth.AuthMiddleware())
+		assetRoutes := api.Group("/asset").Use(requireAuth)
 		{
 			// GET /api/asset/tag/:asset_tag
 			assetRoutes.GET("/tag/:asset_tag", assetHandler.GetAssetByTagHandler)
 
+			// PUT /api/asset/tag/:asset_tag/acquisition
+			assetRoutes.PUT("/tag/:asset_tag/acquisition", assetHandler.SetAcquisitionHandler)
+
 			// GET /api/asset/serial/:serial_number
 			assetRoutes.GET("/serial/:serial_number", assetHandler.GetAssetBySerialNumberHandler)
 
 			// GET /api/asset/:product-variety/equipments
 			assetRoutes.GET("/:product-variety/equipments", assetHandler.GetAssetEquipmentsHandler)
+
+			// GET /api/asset/labels.pdf?site_id=|sub_site_id=|tags=&symbology=&layout=&skip=
+			assetRoutes.GET("/labels.pdf", assetHandler.GenerateLabelsHandler)
+
+			// GET /api/asset/label-layouts
+			assetRoutes.GET("/label-layouts", assetHandler.GetLabelLayoutsHandler)
+
+			// POST /api/asset/decode
+			assetRoutes.POST("/decode", assetHandler.DecodeAssetHandler)
 		}
 
-		opnameRoutes := api.Group("/opname").Use(auth.AuthMiddleware())
+		opnameRoutes := api.Group("/opname").Use(requireAuth, logging.ParamMiddleware("session-id", "session_id"))
 		{
 			// GET /api/opname/:session-id
 			opnameRoutes.GET("/:session-id", opnameHandler.GetSessionByIDHandler)
@@ -190,6 +370,9 @@ func main() {
 			// GET /api/opname/:session-id/unscanned-assets
 			opnameRoutes.GET("/:session-id/unscanned-assets", opnameHandler.GetUnscannedAssetsHandler)
 
+			// GET /api/opname/:session-id/expected-off-site
+			opnameRoutes.GET("/:session-id/expected-off-site", opnameHandler.GetExpectedOffSiteAssetsHandler)
+
 			// POST /api/opname/start
 			opnameRoutes.POST("/start", opnameHandler.StartNewSessionHandler)
 
@@ -212,13 +395,13 @@ func main() {
 			opnameRoutes.DELETE("/:session-id/remove-asset", opnameHandler.RemoveAssetChangeHandler)
 		}
 
-		uploadRoutes := api.Group("/upload").Use(auth.AuthMiddleware())
+		uploadRoutes := api.Group("/upload").Use(requireAuth)
 		{
 			// POST /api/upload/photo
 			uploadRoutes.POST("/photo", uploadHandler.UploadPhotoHandler)
 		}
 
-		reportRoutes := api.Group("/report").Use(auth.AuthMiddleware())
+		reportRoutes := api.Group("/report").Use(requireAuth, logging.ParamMiddleware("session-id", "session_id"))
 		{
 			// GET /api/report/:session-id/stats
 			reportRoutes.GET("/:session-id/stats", reportHandler.GetOpnameStatsHandler)
@@ -233,11 +416,242 @@ func main() {
 			reportRoutes.DELETE("/action-notes/delete", reportHandler.DeleteActionNotesHandler)
 		}
 
+		auditRoutes := api.Group("/audit").Use(requireAuth)
+		{
+			// GET /api/audit?actor_id=&action=&entity_type=&entity_id=&from_date=&end_date=&limit=&page_num=
+			auditRoutes.GET("", auditHandler.GetAuditLogHandler)
+		}
+
+		directoryRoutes := api.Group("/directory").Use(requireAuth)
+		{
+			// POST /api/directory/sync
+			directoryRoutes.POST("/sync", directoryHandler.TriggerSyncHandler)
+
+			// GET /api/directory/sync-runs?limit=
+			directoryRoutes.GET("/sync-runs", directoryHandler.GetSyncRunsHandler)
+
+			// GET /api/directory/sync-runs/:run-id
+			directoryRoutes.GET("/sync-runs/:run-id", directoryHandler.GetSyncRunHandler)
+
+			// GET /api/directory/reassignments
+			directoryRoutes.GET("/reassignments", directoryHandler.GetReassignmentsHandler)
+		}
+
+		locationRoutes := api.Group("/location").Use(requireAuth)
+		{
+			// GET /api/location/tree
+			locationRoutes.GET("/tree", locationHandler.GetTreeHandler)
+
+			// POST /api/location/regions
+			locationRoutes.POST("/regions", locationHandler.CreateRegionHandler)
+
+			// PUT /api/location/regions/:id
+			locationRoutes.PUT("/regions/:id", locationHandler.UpdateRegionHandler)
+
+			// DELETE /api/location/regions/:id
+			locationRoutes.DELETE("/regions/:id", locationHandler.DeleteRegionHandler)
+
+			// POST /api/location/site-groups
+			locationRoutes.POST("/site-groups", locationHandler.CreateSiteGroupHandler)
+
+			// PUT /api/location/site-groups/:id
+			locationRoutes.PUT("/site-groups/:id", locationHandler.UpdateSiteGroupHandler)
+
+			// DELETE /api/location/site-groups/:id
+			locationRoutes.DELETE("/site-groups/:id", locationHandler.DeleteSiteGroupHandler)
+
+			// POST /api/location/sites
+			locationRoutes.POST("/sites", locationHandler.CreateSiteHandler)
+
+			// PUT /api/location/sites/:id
+			locationRoutes.PUT("/sites/:id", locationHandler.UpdateSiteHandler)
+
+			// DELETE /api/location/sites/:id
+			locationRoutes.DELETE("/sites/:id", locationHandler.DeleteSiteHandler)
+
+			// POST /api/location/sub-sites
+			locationRoutes.POST("/sub-sites", locationHandler.CreateSubSiteHandler)
+
+			// PUT /api/location/sub-sites/:id
+			locationRoutes.PUT("/sub-sites/:id", locationHandler.UpdateSubSiteHandler)
+
+			// DELETE /api/location/sub-sites/:id
+			locationRoutes.DELETE("/sub-sites/:id", locationHandler.DeleteSubSiteHandler)
+
+			// POST /api/location/departments
+			locationRoutes.POST("/departments", locationHandler.CreateDepartmentHandler)
+
+			// PUT /api/location/departments/:id
+			locationRoutes.PUT("/departments/:id", locationHandler.UpdateDepartmentHandler)
+
+			// DELETE /api/location/departments/:id
+			locationRoutes.DELETE("/departments/:id", locationHandler.DeleteDepartmentHandler)
+		}
+
+		costCenterRoutes := api.Group("/cost-center").Use(requireAuth)
+		{
+			// GET /api/cost-center/all
+			costCenterRoutes.GET("/all", costCenterHandler.GetAllCostCentersHandler)
+
+			// GET /api/cost-center/chargeback?from_date=&end_date=
+			costCenterRoutes.GET("/chargeback", costCenterHandler.GetChargebackHandler)
+
+			// GET /api/cost-center/chargeback.xlsx?from_date=&end_date=
+			costCenterRoutes.GET("/chargeback.xlsx", costCenterHandler.GetChargebackXLSXHandler)
+
+			// GET /api/cost-center/:cost-center-id
+			costCenterRoutes.GET("/:cost-center-id", costCenterHandler.GetCostCenterByIDHandler)
+
+			// POST /api/cost-center
+			costCenterRoutes.POST("", costCenterHandler.CreateCostCenterHandler)
+
+			// PUT /api/cost-center/:cost-center-id
+			costCenterRoutes.PUT("/:cost-center-id", costCenterHandler.UpdateCostCenterHandler)
+
+			// DELETE /api/cost-center/:cost-center-id
+			costCenterRoutes.DELETE("/:cost-center-id", costCenterHandler.DeleteCostCenterHandler)
+		}
+
+		transferRoutes := api.Group("/transfer").Use(requireAuth, logging.ParamMiddleware("transfer-id", "transfer_id"))
+		{
+			// GET /api/transfer?status=&asset_tag=&site_id=&user_id=&limit=&page_num=
+			transferRoutes.GET("", transferHandler.GetTransfersHandler)
+
+			// GET /api/transfer/:transfer-id
+			transferRoutes.GET("/:transfer-id", transferHandler.GetTransferByIDHandler)
+
+			// GET /api/transfer/:transfer-id/bast.pdf
+			transferRoutes.GET("/:transfer-id/bast.pdf", transferHandler.GenerateBASTHandler)
+
+			// POST /api/transfer
+			transferRoutes.POST("", transferHandler.RequestTransferHandler)
+
+			// PUT /api/transfer/:transfer-id/approve
+			transferRoutes.PUT("/:transfer-id/approve", transferHandler.ApproveTransferHandler)
+
+			// PUT /api/transfer/:transfer-id/reject
+			transferRoutes.PUT("/:transfer-id/reject", transferHandler.RejectTransferHandler)
+
+			// PUT /api/transfer/:transfer-id/cancel
+			transferRoutes.PUT("/:transfer-id/cancel", transferHandler.CancelTransferHandler)
+		}
+
+		loanRoutes := api.Group("/loan").Use(requireAuth, logging.ParamMiddleware("loan-id", "loan_id"))
+		{
+			// GET /api/loan?asset_tag=&borrower_id=&site_id=&open=&limit=&page_num=
+			loanRoutes.GET("", loanHandler.GetLoansHandler)
+
+			// GET /api/loan/overdue?asset_tag=&borrower_id=&site_id=&limit=&page_num=
+			loanRoutes.GET("/overdue", loanHandler.GetOverdueLoansHandler)
+
+			// POST /api/loan/overdue/remind
+			loanRoutes.POST("/overdue/remind", loanHandler.RemindOverdueHandler)
+
+			// GET /api/loan/:loan-id
+			loanRoutes.GET("/:loan-id", loanHandler.GetLoanByIDHandler)
+
+			// POST /api/loan
+			loanRoutes.POST("", loanHandler.CheckOutHandler)
+
+			// PUT /api/loan/:loan-id/check-in
+			loanRoutes.PUT("/:loan-id/check-in", loanHandler.CheckInHandler)
+		}
+
+		repairRoutes := api.Group("/repair").Use(requireAuth, logging.ParamMiddleware("ticket-id", "ticket_id"))
+		{
+			// GET /api/repair?asset_tag=&session_id=&site_id=&status=&limit=&page_num=
+			repairRoutes.GET("", repairHandler.GetTicketsHandler)
+
+			// GET /api/repair/:ticket-id
+			repairRoutes.GET("/:ticket-id", repairHandler.GetTicketByIDHandler)
+
+			// PUT /api/repair/:ticket-id
+			repairRoutes.PUT("/:ticket-id", repairHandler.UpdateTicketHandler)
+
+			// POST /api/repair/:ticket-id/photos
+			repairRoutes.POST("/:ticket-id/photos", repairHandler.AddPhotoHandler)
+
+			// PUT /api/repair/:ticket-id/resolve
+			repairRoutes.PUT("/:ticket-id/resolve", repairHandler.ResolveTicketHandler)
+		}
+
+		disposalRoutes := api.Group("/disposal").Use(requireAuth, logging.ParamMiddleware("disposal-id", "disposal_id"))
+		{
+			// GET /api/disposal?status=&reason=&session_id=&limit=&page_num=
+			disposalRoutes.GET("", disposalHandler.GetDisposalsHandler)
+
+			// GET /api/disposal/:disposal-id
+			disposalRoutes.GET("/:disposal-id", disposalHandler.GetDisposalByIDHandler)
+
+			// GET /api/disposal/:disposal-id/bap.pdf
+			disposalRoutes.GET("/:disposal-id/bap.pdf", disposalHandler.GenerateBAPHandler)
+
+			// POST /api/disposal
+			disposalRoutes.POST("", disposalHandler.RequestDisposalHandler)
+
+			// PUT /api/disposal/:disposal-id/approve
+			disposalRoutes.PUT("/:disposal-id/approve", disposalHandler.ApproveDisposalHandler)
+
+			// PUT /api/disposal/:disposal-id/reject
+			disposalRoutes.PUT("/:disposal-id/reject", disposalHandler.RejectDisposalHandler)
+
+			// PUT /api/disposal/:disposal-id/cancel
+			disposalRoutes.PUT("/:disposal-id/cancel", disposalHandler.CancelDisposalHandler)
+		}
+
+		depreciationRoutes := api.Group("/depreciation").Use(requireAuth)
+		{
+			// GET /api/depreciation
+			depreciationRoutes.GET("", depreciationHandler.GetPoliciesHandler)
+
+			// PUT /api/depreciation
+			depreciationRoutes.PUT("", depreciationHandler.UpdatePolicyHandler)
+		}
+
 	}
 
-	// Start the server on port 8080.
-	log.Println("Starting server on port 8080...")
-	if err := router.Run(":8080"); err != nil {
-		log.Fatalf("Failed to start API server: %v\n", err)
+	// Start the server on the configured port and stop gracefully on SIGINT/SIGTERM.
+	server := &http.Server{
+		Addr:    cfg.Server.Addr(),
+		Handler: router,
 	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("starting server", "port", cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			logger.Error("failed to start API server", "error", err)
+			os.Exit(1)
+		}
+	case <-ctx.Done():
+		logger.Info("shutdown signal received, stopping server")
+	}
+	stop() // A second signal kills the process immediately.
+
+	// Stop accepting connections and let in-flight requests finish first, since they may still submit jobs.
+	httpCtx, cancelHTTP := context.WithTimeout(context.Background(), shutdownHTTPTimeout)
+	defer cancelHTTP()
+	if err := server.Shutdown(httpCtx); err != nil {
+		logger.Error("failed to shut down the server gracefully", "error", err)
+	}
+
+	// Then drain the background jobs.
+	jobsCtx, cancelJobs := context.WithTimeout(context.Background(), shutdownJobsTimeout)
+	defer cancelJobs()
+	if err := jobRunner.Shutdown(jobsCtx); err != nil {
+		logger.Error("background jobs did not finish before the shutdown deadline", "error", err)
+	}
+
+	logger.Info("server stopped")
 }
@@ -0,0 +1,147 @@
+// == Defines the application error taxonomy shared by every layer ==
+// == Services return *Error values; the Gin middleware turns them into a uniform JSON body and HTTP status ==
+package apperr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Kind classifies an error and decides its HTTP status code.
+type Kind string
+
+const (
+	KindValidation   Kind = "validation"
+	KindUnauthorized Kind = "unauthorized"
+	KindForbidden    Kind = "forbidden"
+	KindNotFound     Kind = "not_found"
+	KindConflict     Kind = "conflict"
+	KindRateLimited  Kind = "rate_limited"
+	KindTimeout      Kind = "timeout"
+	KindInternal     Kind = "internal"
+)
+
+// Generic codes used when no more specific code applies.
+const (
+	CodeInternal  = "internal_error"
+	CodeTimeout   = "request_timeout"
+	CodeCancelled = "request_cancelled"
+)
+
+// Error is an error that is safe to show to clients.
+// Code is a stable machine readable identifier, Message is human readable, Err is the cause (logged, never sent).
+// RetryAfter, when set, tells the client how long to wait before trying again.
+type Error struct {
+	Kind       Kind
+	Code       string
+	Message    string
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (err *Error) Error() string {
+	if err.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", err.Code, err.Message, err.Err)
+	}
+	return fmt.Sprintf("%s: %s", err.Code, err.Message)
+}
+
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// Wrap returns a copy of err with cause attached, for logging.
+func (err *Error) Wrap(cause error) *Error {
+	wrapped := *err
+	wrapped.Err = cause
+	return &wrapped
+}
+
+// WithRetryAfter returns a copy of err asking the client to wait for wait before retrying.
+func (err *Error) WithRetryAfter(wait time.Duration) *Error {
+	delayed := *err
+	delayed.RetryAfter = wait
+	return &delayed
+}
+
+// Status returns the HTTP status code for the error's kind.
+func (err *Error) Status() int {
+	switch err.Kind {
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindRateLimited:
+		return http.StatusTooManyRequests
+	case KindTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Validation reports invalid input from the client.
+func Validation(code, message string) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message}
+}
+
+// Unauthorized reports a missing or invalid identity.
+func Unauthorized(code, message string) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Message: message}
+}
+
+// Forbidden reports an authenticated user who may not perform the action.
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+// NotFound reports a resource that does not exist.
+func NotFound(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+// Conflict reports a request that clashes with the current state of a resource.
+func Conflict(code, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+// RateLimited reports a client that sent too many requests and has to slow down.
+func RateLimited(code, message string) *Error {
+	return &Error{Kind: KindRateLimited, Code: code, Message: message}
+}
+
+// Internal wraps an unexpected failure. The cause is logged but clients only see a generic message.
+func Internal(cause error) *Error {
+	return &Error{Kind: KindInternal, Code: CodeInternal, Message: "internal server error", Err: cause}
+}
+
+// From converts any error into an *Error: application errors pass through, context errors become timeouts
+// and everything else is treated as internal.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Kind: KindTimeout, Code: CodeTimeout, Message: "the request took too long to complete", Err: err}
+	}
+	if errors.Is(err, context.Canceled) {
+		return &Error{Kind: KindTimeout, Code: CodeCancelled, Message: "the request was cancelled", Err: err}
+	}
+	return Internal(err)
+}
+
+// IsKind reports whether err is an application error of the given kind.
+func IsKind(err error, kind Kind) bool {
+	var appErr *Error
+	return errors.As(err, &appErr) && appErr.Kind == kind
+}
@@ -0,0 +1,51 @@
+// == Renders errors attached to the Gin context as one uniform JSON body ==
+package apperr
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response is the JSON body of every error response. "error" stays a plain message for existing clients.
+type Response struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Middleware writes the response for the last error a handler attached with Abort (or context.Error).
+// Internal errors are logged with their cause; clients only ever see the code and the safe message.
+func Middleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Next()
+
+		if len(context.Errors) == 0 || context.Writer.Written() {
+			return
+		}
+
+		appErr := From(context.Errors.Last().Err)
+		if appErr.Kind == KindInternal {
+			logging.FromGin(context, logger).Error("request failed", "code", appErr.Code, "error", appErr.Err)
+		}
+
+		if appErr.RetryAfter > 0 {
+			context.Header("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+		}
+		context.JSON(appErr.Status(), Response{
+			Error:     appErr.Message,
+			Code:      appErr.Code,
+			RequestID: context.GetString("request_id"),
+		})
+	}
+}
+
+// Abort attaches err to the request and stops the handler chain; the middleware renders the response.
+func Abort(context *gin.Context, err error) {
+	context.Error(err)
+	context.Abort()
+}
@@ -0,0 +1,141 @@
+// == Translates PostgreSQL errors (constraint violations and RAISE EXCEPTION from the stored functions) into application errors ==
+package apperr
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+
+	"github.com/lib/pq"
+)
+
+// exceptionRule maps the message of a RAISE EXCEPTION in the stored functions to an application error.
+type exceptionRule struct {
+	pattern *regexp.Regexp
+	err     *Error
+}
+
+// exceptionRules must be kept in sync with the RAISE EXCEPTION messages in the migrations.
+var exceptionRules = []exceptionRule{
+	{regexp.MustCompile(`^(Only|Exactly) one of site_id or dept_id must be provided`), Validation("invalid_location", "exactly one of site_id or dept_id must be provided")},
+	{regexp.MustCompile(`^No active opname session found`), Conflict("opname_session_not_active", "the opname session is not active")},
+	{regexp.MustCompile(`^Session ID: .* is not active`), Conflict("opname_session_not_active", "the opname session is not active")},
+	{regexp.MustCompile(`^No asset changes recorded`), Conflict("opname_session_empty", "no assets have been scanned in this opname session yet")},
+	{regexp.MustCompile(`^There are assets that have not been processed yet`), Conflict("opname_session_incomplete", "some assets in this opname session have not been processed yet")},
+	{regexp.MustCompile(`^No submitted or escalated opname session found`), Conflict("opname_session_not_reviewable", "the opname session is not waiting for a review")},
+	{regexp.MustCompile(`^No escalated opname session found`), Conflict("opname_session_not_reviewable", "the opname session is not waiting for a review")},
+	{regexp.MustCompile(`^No opname session waiting for a loss approval found`), Conflict("opname_session_not_reviewable", "the opname session is not waiting for a review")},
+	{regexp.MustCompile(`^Only the next reviewer on the approval path can`), Forbidden("opname_review_forbidden", "you are not the next reviewer on this location's approval path")},
+	{regexp.MustCompile(`^Only area manager can`), Forbidden("opname_review_forbidden", "only the area manager can review a submitted opname session")},
+	{regexp.MustCompile(`^Only L1 support can`), Forbidden("opname_review_forbidden", "only L1 support can review an escalated opname session")},
+	{regexp.MustCompile(`^At least one admin position is required`), Validation("invalid_admin_positions", "at least one admin position is required")},
+	{regexp.MustCompile(`^Asset with tag .* not found`), NotFound("asset_not_found", "asset not found")},
+	{regexp.MustCompile(`^No asset change record found`), NotFound("asset_change_not_found", "asset change record not found")},
+	{regexp.MustCompile(`^User is not authorized to (update|delete) action notes`), Forbidden("action_notes_forbidden", "you are not allowed to change the action notes of this opname session")},
+	{regexp.MustCompile(`^User with ID .* not found`), NotFound("user_not_found", "user not found")},
+	{regexp.MustCompile(`^Username .* is already taken`), Conflict("username_taken", "the username is already taken")},
+	{regexp.MustCompile(`^User ID .* is already taken`), Conflict("user_id_taken", "the user ID is already taken")},
+	{regexp.MustCompile(`^The VACANT placeholder user cannot be changed`), Forbidden("vacant_user_immutable", "the VACANT placeholder user cannot be changed")},
+	{regexp.MustCompile(`^A directory sync is already running`), Conflict("directory_sync_running", "a directory sync is already running")},
+	{regexp.MustCompile(`^Directory sync would deactivate`), Conflict("directory_sync_too_many_leavers", "the directory sync would deactivate too many users, check the export")},
+	{regexp.MustCompile(`^Location region .* not found`), NotFound("region_not_found", "region not found")},
+	{regexp.MustCompile(`^Location site group .* not found`), NotFound("site_group_not_found", "site group not found")},
+	{regexp.MustCompile(`^Location site .* not found`), NotFound("site_not_found", "site not found")},
+	{regexp.MustCompile(`^Location sub-site .* not found`), NotFound("sub_site_not_found", "sub-site not found")},
+	{regexp.MustCompile(`^Location department .* not found`), NotFound("department_not_found", "department not found")},
+	{regexp.MustCompile(`^Location .* still has .* (site groups|sites|sub-sites|departments)$`), Conflict("location_not_empty", "the location still contains lower levels, move or delete them first")},
+	{regexp.MustCompile(`^Location .* still holds .* assets$`), Conflict("location_has_assets", "the location still holds assets, move them first")},
+	{regexp.MustCompile(`^Location .* still has .* users$`), Conflict("location_has_users", "users are still assigned to the site, move them first")},
+	{regexp.MustCompile(`^Location .* still has .* opname sessions$`), Conflict("location_has_opname_sessions", "the location has opname history and cannot be deleted")},
+	{regexp.MustCompile(`^Site GA user .* not found or deactivated`), Validation("invalid_site_ga", "the site GA must be an active user")},
+	{regexp.MustCompile(`^Site .* is not a head office`), Validation("not_head_office", "departments can only belong to a head office site")},
+	{regexp.MustCompile(`^Head office site .* still has .* departments`), Conflict("head_office_has_departments", "the head office still has departments, move them first")},
+	{regexp.MustCompile(`^There are several head offices, site_id is required`), Validation("head_office_required", "there are several head offices, site_id is required")},
+	{regexp.MustCompile(`^Cost center ID .* is already taken`), Conflict("cost_center_id_taken", "the cost center ID is already taken")},
+	{regexp.MustCompile(`^Cost center .* not found`), NotFound("cost_center_not_found", "cost center not found")},
+	{regexp.MustCompile(`^Cost center .* still has .* users`), Conflict("cost_center_has_users", "users are still charged to the cost center, move them first")},
+	{regexp.MustCompile(`^Asset .* is disposed and cannot be transferred`), Conflict("asset_disposed", "the asset is disposed and cannot be transferred")},
+	{regexp.MustCompile(`^Asset .* already has a pending transfer`), Conflict("transfer_already_pending", "the asset already has a pending transfer")},
+	{regexp.MustCompile(`^A transfer goes to either a sub-site or a department`), Validation("invalid_destination", "only one of to_sub_site_id or to_dept_id can be provided")},
+	{regexp.MustCompile(`^Transfer recipient .* not found or deactivated`), Validation("invalid_recipient", "the receiving owner must be an active user")},
+	{regexp.MustCompile(`^Transfer of asset .* changes nothing`), Validation("transfer_changes_nothing", "the transfer does not change the owner, location or cost center of the asset")},
+	{regexp.MustCompile(`^Asset transfer .* not found`), NotFound("transfer_not_found", "asset transfer not found")},
+	{regexp.MustCompile(`^Asset transfer .* is not pending`), Conflict("transfer_not_pending", "the asset transfer is no longer pending")},
+	{regexp.MustCompile(`^Asset .* has changed since transfer .* was requested`), Conflict("transfer_outdated", "the asset's owner or location changed since the transfer was requested, request it again")},
+	{regexp.MustCompile(`^Asset .* is .* and cannot be loaned`), Conflict("asset_not_loanable", "only deployed or in-inventory assets can be loaned")},
+	{regexp.MustCompile(`^Loan borrower .* not found or deactivated`), Validation("invalid_borrower", "the borrower must be an active user")},
+	{regexp.MustCompile(`^Return condition must be 0 \(bad\) or 1 \(good\)`), Validation("invalid_return_condition", "condition must be 0 (bad) or 1 (good)")},
+	{regexp.MustCompile(`^A photo is required when an asset is returned in bad condition`), Validation("return_photo_required", "a photo is required when the asset is returned in bad condition")},
+	{regexp.MustCompile(`^Asset loan .* not found`), NotFound("loan_not_found", "asset loan not found")},
+	{regexp.MustCompile(`^Asset loan .* is already checked in`), Conflict("loan_already_checked_in", "the asset has already been checked in")},
+	{regexp.MustCompile(`^Repair outcome must be Repaired or Beyond Repair`), Validation("invalid_repair_outcome", "status must be Repaired or Beyond Repair")},
+	{regexp.MustCompile(`^Repair ticket .* not found`), NotFound("repair_ticket_not_found", "repair ticket not found")},
+	{regexp.MustCompile(`^Repair ticket .* is already resolved`), Conflict("repair_ticket_resolved", "the repair ticket has already been resolved")},
+	{regexp.MustCompile(`^Disposal reason must be Lost or Obsolete`), Validation("invalid_disposal_reason", "reason must be Lost or Obsolete")},
+	{regexp.MustCompile(`^Opname session .* is not verified`), Conflict("opname_session_not_verified", "the opname session has not been verified yet")},
+	{regexp.MustCompile(`^Lost assets are disposed of from a verified opname session`), Validation("session_required", "session_id is required to dispose of lost assets")},
+	{regexp.MustCompile(`^Asset .* is not missing in opname session`), Validation("asset_not_missing", "only the missing assets of the opname session can be disposed of as lost")},
+	{regexp.MustCompile(`^A disposal request needs at least one asset`), Validation("disposal_empty", "a disposal request needs at least one asset")},
+	{regexp.MustCompile(`^Asset .* is already in pending disposal request`), Conflict("disposal_already_pending", "the asset is already in a pending disposal request")},
+	{regexp.MustCompile(`^Asset .* is .* and cannot be disposed`), Conflict("asset_not_disposable", "disposed, loaned and in-repair assets cannot be disposed of")},
+	{regexp.MustCompile(`^Disposal request .* not found`), NotFound("disposal_not_found", "disposal request not found")},
+	{regexp.MustCompile(`^Disposal request .* is not pending`), Conflict("disposal_not_pending", "the disposal request is no longer pending")},
+	{regexp.MustCompile(`^Depreciation policy for .* not found`), NotFound("depreciation_policy_not_found", "depreciation policy not found")},
+}
+
+// PostgreSQL error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html.
+const (
+	pgRaiseException      = "P0001"
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+	pgInvalidTextRepr     = "22P02"
+	pgStringTooLong       = "22001"
+	pgQueryCanceled       = "57014"
+)
+
+// FromPostgres translates a database error into an application error, keeping the original as the cause.
+// sql.ErrNoRows becomes NotFound; unknown errors become Internal so raw database text never reaches clients.
+func FromPostgres(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFound("not_found", "resource not found").Wrap(err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return From(err)
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return Internal(err)
+	}
+
+	switch pqErr.Code {
+	case pgRaiseException:
+		for _, rule := range exceptionRules {
+			if rule.pattern.MatchString(pqErr.Message) {
+				return rule.err.Wrap(err)
+			}
+		}
+		return Internal(err)
+	case pgUniqueViolation:
+		return Conflict("duplicate", "a record with the same value already exists").Wrap(err)
+	case pgForeignKeyViolation:
+		return Conflict("invalid_reference", "a referenced record does not exist or is still in use").Wrap(err)
+	case pgNotNullViolation, pgCheckViolation, pgInvalidTextRepr, pgStringTooLong:
+		return Validation("invalid_value", "one of the values is missing or invalid").Wrap(err)
+	case pgQueryCanceled:
+		return &Error{Kind: KindTimeout, Code: CodeTimeout, Message: "the request took too long to complete", Err: err}
+	default:
+		return Internal(err)
+	}
+}
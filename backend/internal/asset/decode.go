@@ -0,0 +1,218 @@
+// == Handles server-side barcode/QR decoding of photographed asset tags ==
+package asset
+
+import (
+	"context"
+	"image"
+	_ "image/gif"  // Register GIF decoder for image.Decode
+	_ "image/jpeg" // Register JPEG decoder for image.Decode
+	_ "image/png"  // Register PNG decoder for image.Decode
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/datamatrix"
+	"github.com/makiuchi-d/gozxing/multi"
+	multiqr "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/makiuchi-d/gozxing/oned"
+)
+
+// Decoded symbologies, in addition to the printable ones in labels.go.
+const SymbologyDataMatrix = "datamatrix"
+
+// Match types describing how a decoded value was resolved to an asset.
+const (
+	MatchedByAssetTag     = "asset_tag"
+	MatchedBySerialNumber = "serial_number"
+)
+
+// Weight applied to the decode confidence depending on how the value was resolved.
+// A direct tag hit is what the labels encode; a serial number hit usually comes from a manufacturer sticker.
+var matchWeight = map[string]float64{
+	MatchedByAssetTag:     1.0,
+	MatchedBySerialNumber: 0.85,
+}
+
+// DecodedSymbol is a single symbol found in an image.
+// Confidence is the share of binarizers that read the same value, taken from the better polarity
+// (a printed label only reads in normal polarity, a laser-etched plate often only inverted).
+type DecodedSymbol struct {
+	Text       string
+	Symbology  string
+	Confidence float64
+}
+
+// DecodeMatch is a decoded symbol together with the asset it resolved to, if any.
+type DecodeMatch struct {
+	DecodedText string
+	Symbology   string
+	MatchedBy   string // MatchedByAssetTag, MatchedBySerialNumber or "" when nothing matched
+	Confidence  float64
+	Asset       *Asset
+}
+
+// decodeBinarizers are the ways of turning the photo into a black/white bitmap, each tried in both polarities.
+var decodeBinarizers = []func(gozxing.LuminanceSource) gozxing.Binarizer{
+	gozxing.NewHybridBinarizer,
+	gozxing.NewGlobalHistgramBinarizer,
+}
+
+// DecodeImage reads an uploaded photo and decodes all Code128, QR and DataMatrix symbols in it.
+func (service *Service) DecodeImage(reader io.Reader) ([]DecodedSymbol, error) {
+	img, format, err := image.Decode(reader)
+	if err != nil {
+		service.logger.Warn("unable to decode uploaded image", "error", err)
+		return nil, err
+	}
+	service.logger.Debug("decoding symbols from image", "format", format, "width", img.Bounds().Dx(), "height", img.Bounds().Dy())
+
+	return decodeSymbols(img), nil
+}
+
+// decodeSymbols runs every reader over every pass and aggregates how often each value was read.
+func decodeSymbols(img image.Image) []DecodedSymbol {
+	type symbolKey struct{ text, symbology string }
+	hits := make(map[symbolKey]*[2]int) // Index 0 counts normal polarity reads, index 1 inverted ones
+
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+	readers := symbolReaders{
+		qr:         multiqr.NewQRCodeMultiReader(),
+		dataMatrix: datamatrix.NewDataMatrixReader(),
+		code128:    oned.NewCode128Reader(),
+	}
+
+	source := gozxing.NewLuminanceSourceFromImage(img)
+	for polarity, passSource := range []gozxing.LuminanceSource{source, source.Invert()} {
+		for _, binarizer := range decodeBinarizers {
+			readers.decodePass(passSource, binarizer, hints, func(text, symbology string) {
+				key := symbolKey{text: text, symbology: symbology}
+				if hits[key] == nil {
+					hits[key] = &[2]int{}
+				}
+				hits[key][polarity]++
+			})
+		}
+	}
+	symbols := make([]DecodedSymbol, 0, len(hits))
+	for key, count := range hits {
+		symbols = append(symbols, DecodedSymbol{
+			Text:       key.text,
+			Symbology:  key.symbology,
+			Confidence: float64(max(count[0], count[1])) / float64(len(decodeBinarizers)),
+		})
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if symbols[i].Confidence == symbols[j].Confidence {
+			return symbols[i].Text < symbols[j].Text
+		}
+		return symbols[i].Confidence > symbols[j].Confidence
+	})
+
+	return symbols
+}
+
+// symbolReaders groups the readers for every supported symbology.
+type symbolReaders struct {
+	qr         multi.MultipleBarcodeReader
+	dataMatrix gozxing.Reader
+	code128    gozxing.Reader
+}
+
+// decodePass runs every reader once over the source binarized with the given binarizer.
+// Each value is reported at most once per pass, even if a reader returns it several times.
+func (readers symbolReaders) decodePass(
+	source gozxing.LuminanceSource,
+	binarizer func(gozxing.LuminanceSource) gozxing.Binarizer,
+	hints map[gozxing.DecodeHintType]interface{},
+	record func(text, symbology string),
+) {
+	seen := make(map[string]bool)
+	recordResult := func(result *gozxing.Result, symbology string) {
+		text := strings.TrimSpace(result.GetText())
+		if text == "" || seen[symbology+"|"+text] {
+			return
+		}
+		seen[symbology+"|"+text] = true
+		record(text, symbology)
+	}
+
+	// Readers may keep state on the bitmap, so every reader gets its own.
+	newBitmap := func() *gozxing.BinaryBitmap {
+		bitmap, err := gozxing.NewBinaryBitmap(binarizer(source))
+		if err != nil {
+			return nil
+		}
+		return bitmap
+	}
+
+	if bitmap := newBitmap(); bitmap != nil {
+		if results, err := readers.qr.DecodeMultiple(bitmap, hints); err == nil {
+			for _, result := range results {
+				recordResult(result, SymbologyQR)
+			}
+		}
+	}
+	if bitmap := newBitmap(); bitmap != nil {
+		if result, err := readers.dataMatrix.Decode(bitmap, hints); err == nil {
+			recordResult(result, SymbologyDataMatrix)
+		}
+	}
+	if bitmap := newBitmap(); bitmap != nil {
+		if result, err := readers.code128.Decode(bitmap, hints); err == nil {
+			recordResult(result, SymbologyCode128)
+		}
+	}
+}
+
+// ResolveDecodedSymbols looks up every decoded value as an asset tag first, then as a serial number.
+// Unresolved symbols are still returned (without an asset) so the client can show what was read.
+func (service *Service) ResolveDecodedSymbols(ctx context.Context, symbols []DecodedSymbol) ([]DecodeMatch, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	matches := make([]DecodeMatch, 0, len(symbols))
+	for _, symbol := range symbols {
+		match := DecodeMatch{
+			DecodedText: symbol.Text,
+			Symbology:   symbol.Symbology,
+		}
+
+		// Asset tags are stored in upper case (VARCHAR(12)), labels may be read back in any case.
+		if len(symbol.Text) <= 12 {
+			matchedAsset, err := service.GetAssetByTag(ctx, strings.ToUpper(symbol.Text))
+			if err != nil {
+				return nil, err
+			}
+			if matchedAsset != nil {
+				match.Asset = matchedAsset
+				match.MatchedBy = MatchedByAssetTag
+			}
+		}
+
+		if match.Asset == nil {
+			matchedAsset, err := service.GetAssetBySerialNumber(ctx, symbol.Text)
+			if err != nil {
+				return nil, err
+			}
+			if matchedAsset != nil {
+				match.Asset = matchedAsset
+				match.MatchedBy = MatchedBySerialNumber
+			}
+		}
+
+		match.Confidence = symbol.Confidence * matchWeight[match.MatchedBy]
+		matches = append(matches, match)
+	}
+
+	// Resolved matches first, highest confidence first.
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Confidence > matches[j].Confidence
+	})
+
+	logger.Info("resolved decoded symbols", "count", len(matches))
+	return matches, nil
+}
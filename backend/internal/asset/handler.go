@@ -2,21 +2,30 @@
 package asset
 
 import (
-	"log"
+	"database/sql"
+	"log/slog"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
 	service *Service
+	logger  *slog.Logger
 }
 
 // NewHandler creates a new asset handler with the provided asset service.
-func NewHandler(service *Service) *Handler {
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
 	return &Handler{
 		service: service,
+		logger:  logger,
 	}
 }
 
@@ -56,9 +65,21 @@ func SerializeAsset(a *Asset) gin.H {
 		"site_name":           utils.SerializeNS(a.SiteName),
 		"site_group_name":     utils.SerializeNS(a.SiteGroupName),
 		"region_name":         utils.SerializeNS(a.RegionName),
+		"acquisition_date":    serializeDate(a.AcquisitionDate),
+		"depreciation_method": utils.SerializeNS(a.DepreciationMethod),
+		"useful_life_months":  utils.SerializeNI(a.UsefulLifeMonths),
+		"net_book_value":      a.NetBookValue(time.Now()),
 	}
 }
 
+// serializeDate flattens a nullable date to YYYY-MM-DD or null.
+func serializeDate(date sql.NullTime) interface{} {
+	if !date.Valid {
+		return nil
+	}
+	return date.Time.Format(time.DateOnly)
+}
+
 // SerializeMultipleAssets maps a slice of Asset pointers to a slice of gin.H with flattened nullable values.
 func SerializeMultipleAssets(list []*Asset) []gin.H {
 	if len(list) == 0 {
@@ -73,22 +94,24 @@ func SerializeMultipleAssets(list []*Asset) []gin.H {
 
 // GetAssetByTagHandler retrieves an asset by its tag.
 func (handler *Handler) GetAssetByTagHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	assetTag := context.Param("asset_tag")
 	if assetTag == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "asset_tag is required"})
-		log.Printf("⚠ asset_tag is required but not provided")
+		apperr.Abort(context, apperr.Validation("invalid_asset_tag", "asset_tag is required"))
+		logger.Warn("missing asset tag in request")
 		return
 	}
 
-	asset, err := handler.service.GetAssetByTag(assetTag)
+	asset, err := handler.service.GetAssetByTag(context.Request.Context(), assetTag)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch asset details: " + err.Error()})
-		log.Printf("❌ Error fetching asset by tag %s: %v", assetTag, err)
+		apperr.Abort(context, err)
+		logger.Error("failed to fetch asset by tag", "asset_tag", assetTag, "error", err)
 		return
 	}
 	if asset == nil {
-		context.JSON(http.StatusNotFound, gin.H{"error": "asset not found with tag: " + assetTag})
-		log.Printf("⚠ No asset found with tag: %s", assetTag)
+		apperr.Abort(context, apperr.NotFound("asset_not_found", "asset not found with tag: "+assetTag))
+		logger.Debug("no asset found", "asset_tag", assetTag)
 		return
 	}
 
@@ -97,22 +120,24 @@ func (handler *Handler) GetAssetByTagHandler(context *gin.Context) {
 
 // GetAssetBySerialNumberHandler retrieves an asset by its serial number.
 func (handler *Handler) GetAssetBySerialNumberHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	serialNumber := context.Param("serial_number")
 	if serialNumber == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "serial_number is required"})
-		log.Printf("⚠ serial_number is required but not provided")
+		apperr.Abort(context, apperr.Validation("invalid_serial_number", "serial_number is required"))
+		logger.Warn("missing serial number in request")
 		return
 	}
 
-	asset, err := handler.service.GetAssetBySerialNumber(serialNumber)
+	asset, err := handler.service.GetAssetBySerialNumber(context.Request.Context(), serialNumber)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch asset details: " + err.Error()})
-		log.Printf("❌ Error fetching asset by serial number %s: %v", serialNumber, err)
+		apperr.Abort(context, err)
+		logger.Error("failed to fetch asset by serial number", "serial_number", serialNumber, "error", err)
 		return
 	}
 	if asset == nil {
-		context.JSON(http.StatusNotFound, gin.H{"error": "asset not found with serial number: " + serialNumber})
-		log.Printf("⚠ No asset found with serial number: %s", serialNumber)
+		apperr.Abort(context, apperr.NotFound("asset_not_found", "asset not found with serial number: "+serialNumber))
+		logger.Debug("no asset found", "serial_number", serialNumber)
 		return
 	}
 
@@ -121,27 +146,27 @@ func (handler *Handler) GetAssetBySerialNumberHandler(context *gin.Context) {
 
 // GetAssetsOnLocationHandler retrieves all assets for a given location.
 func (handler *Handler) GetAssetsOnLocationHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Retrieve the site or dept id from query params
 	siteIDStr := context.Query("site_id")
 	deptIDStr := context.Query("dept_id")
 
 	siteID, deptID, err := utils.ParseLocationParams(siteIDStr, deptIDStr)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		apperr.Abort(context, err)
 		return
 	}
 
-	assetsOnLocation, err := handler.service.GetAssetsOnLocation(siteID, deptID)
+	assetsOnLocation, err := handler.service.GetAssetsOnLocation(context.Request.Context(), siteID, deptID)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch assets for location: " + err.Error()})
-		log.Printf("❌ Error fetching assets for location - site-id: %v, dept-id: %v: %v", siteID, deptID, err)
+		apperr.Abort(context, err)
+		logger.Error("failed to fetch assets for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID), "error", err)
 		return
 	}
 	if assetsOnLocation == nil {
 		assetsOnLocation = make([]*Asset, 0)
-		log.Printf("⚠ No assets found for location - site-id: %v, dept-id: %v", siteID, deptID)
+		logger.Debug("no assets found for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID))
 	}
 
 	context.JSON(http.StatusOK, gin.H{"assets_on_location": SerializeMultipleAssets(assetsOnLocation)})
@@ -149,23 +174,25 @@ func (handler *Handler) GetAssetsOnLocationHandler(context *gin.Context) {
 
 // GetAssetEquipmentsHandler retrieves all equipments for a given product variety.
 func (handler *Handler) GetAssetEquipmentsHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	productVariety := context.Param("product-variety")
 	if productVariety == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "product-variety is required"})
-		log.Printf("⚠ product-variety is required but not provided")
+		apperr.Abort(context, apperr.Validation("invalid_product_variety", "product-variety is required"))
+		logger.Warn("missing product variety in request")
 		return
 	}
 
 	// Call the service to get equipments for the product variety
-	equipments, err := handler.service.GetAssetEquipments(productVariety)
+	equipments, err := handler.service.GetAssetEquipments(context.Request.Context(), productVariety)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch equipments: " + err.Error()})
-		log.Printf("❌ Error fetching equipments for product variety %s: %v", productVariety, err)
+		apperr.Abort(context, err)
+		logger.Error("failed to fetch equipments", "product_variety", productVariety, "error", err)
 		return
 	}
 	if equipments == "" {
 		// If no equipments are found, return an empty string
-		log.Printf("⚠ No equipments found for product variety: %s", productVariety)
+		logger.Debug("no equipments found", "product_variety", productVariety)
 		context.JSON(http.StatusOK, gin.H{"equipments": ""})
 		return
 	}
@@ -176,3 +203,171 @@ func (handler *Handler) GetAssetEquipmentsHandler(context *gin.Context) {
 		"equipments":      equipments,
 	})
 }
+
+// SetAcquisitionHandler records the acquisition date, and optionally the cost, an asset is depreciated from. Only L1 support may do this.
+func (handler *Handler) SetAcquisitionHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	assetTag := context.Param("asset_tag")
+	if assetTag == "" {
+		apperr.Abort(context, apperr.Validation("invalid_asset_tag", "asset_tag is required"))
+		logger.Warn("missing asset tag in request")
+		return
+	}
+
+	var request AcquisitionRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid asset acquisition request", "asset_tag", assetTag, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	updated, err := handler.service.SetAcquisition(context.Request.Context(), context.GetString("position"), assetTag, request)
+	if err != nil {
+		logger.Warn("failed to set asset acquisition", "asset_tag", assetTag, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "asset acquisition updated successfully",
+		"asset":   SerializeAsset(updated),
+	})
+}
+
+// GenerateLabelsHandler streams a printable label sheet PDF for a site, a sub-site or an explicit list of asset tags.
+// Query params: site_id | sub_site_id | tags (comma separated), symbology (code128|qr), layout, skip.
+func (handler *Handler) GenerateLabelsHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	var request LabelRequest
+
+	parseOptionalInt := func(param string) (*int, bool) {
+		value := context.Query(param)
+		if value == "" {
+			return nil, true
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			apperr.Abort(context, apperr.Validation("invalid_label_request", "invalid "+param+", must be a positive integer"))
+			return nil, false
+		}
+		return &parsed, true
+	}
+
+	var ok bool
+	if request.SiteID, ok = parseOptionalInt("site_id"); !ok {
+		return
+	}
+	if request.SubSiteID, ok = parseOptionalInt("sub_site_id"); !ok {
+		return
+	}
+	for _, assetTag := range strings.Split(context.Query("tags"), ",") {
+		if assetTag = strings.TrimSpace(assetTag); assetTag != "" {
+			request.AssetTags = append(request.AssetTags, assetTag)
+		}
+	}
+	if skip := context.Query("skip"); skip != "" {
+		parsedSkip, err := strconv.Atoi(skip)
+		if err != nil {
+			apperr.Abort(context, apperr.Validation("invalid_label_request", "invalid skip, must be an integer"))
+			return
+		}
+		request.Skip = parsedSkip
+	}
+	request.Symbology = context.Query("symbology")
+	request.Layout = context.Query("layout")
+
+	if err := request.Validate(); err != nil {
+		apperr.Abort(context, err)
+		logger.Warn("invalid label request", "error", err)
+		return
+	}
+
+	pdfBytes, filename, err := handler.service.GenerateLabelsPDF(context.Request.Context(), request)
+	if err != nil {
+		apperr.Abort(context, err)
+		logger.Error("failed to generate asset labels", "error", err)
+		return
+	}
+	if pdfBytes == nil {
+		apperr.Abort(context, apperr.NotFound("asset_not_found", "no assets found for the requested labels"))
+		logger.Debug("no assets found for label request")
+		return
+	}
+
+	context.Header("Content-Disposition", "attachment; filename="+filename)
+	context.Data(http.StatusOK, "application/pdf", pdfBytes)
+}
+
+// GetLabelLayoutsHandler lists the label sheet layouts available for printing.
+func (handler *Handler) GetLabelLayoutsHandler(context *gin.Context) {
+	layouts := make([]LabelLayout, 0, len(LabelLayouts))
+	for _, layout := range LabelLayouts {
+		layouts = append(layouts, layout)
+	}
+	sort.Slice(layouts, func(i, j int) bool { return layouts[i].Name < layouts[j].Name })
+
+	context.JSON(http.StatusOK, gin.H{
+		"default_layout": DefaultLabelLayout,
+		"layouts":        layouts,
+	})
+}
+
+// maxDecodeImageSize caps uploaded photos for barcode decoding (phone cameras produce 3-8 MB JPEGs).
+const maxDecodeImageSize = 15 << 20
+
+// DecodeAssetHandler decodes Code128/QR/DataMatrix symbols from an uploaded photo and resolves them into assets.
+// Used as a fallback when the mobile browser cannot scan the label itself (poor lighting, damaged labels).
+func (handler *Handler) DecodeAssetHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, maxDecodeImageSize)
+
+	// "image" is the 'name' attribute of the file input in the form.
+	file, err := context.FormFile("image")
+	if err != nil {
+		apperr.Abort(context, apperr.Validation("invalid_image", "no image received, make sure the form is multipart/form-data with an 'image' field of at most 15 MB"))
+		logger.Warn("no image received for decoding", "error", err)
+		return
+	}
+
+	imageFile, err := file.Open()
+	if err != nil {
+		apperr.Abort(context, apperr.Internal(err))
+		logger.Error("failed to open uploaded image", "error", err)
+		return
+	}
+	defer imageFile.Close()
+
+	symbols, err := handler.service.DecodeImage(imageFile)
+	if err != nil {
+		apperr.Abort(context, apperr.Validation("invalid_image", "unsupported or corrupt image, upload a JPEG, PNG or GIF"))
+		return
+	}
+
+	matches, err := handler.service.ResolveDecodedSymbols(context.Request.Context(), symbols)
+	if err != nil {
+		apperr.Abort(context, err)
+		logger.Error("failed to resolve decoded symbols", "error", err)
+		return
+	}
+
+	serializedMatches := make([]gin.H, 0, len(matches))
+	for _, match := range matches {
+		serializedMatch := gin.H{
+			"decoded_text": match.DecodedText,
+			"symbology":    match.Symbology,
+			"matched_by":   nil,
+			"confidence":   match.Confidence,
+			"asset":        nil,
+		}
+		if match.Asset != nil {
+			serializedMatch["matched_by"] = match.MatchedBy
+			serializedMatch["asset"] = SerializeAsset(match.Asset)
+		}
+		serializedMatches = append(serializedMatches, serializedMatch)
+	}
+
+	context.JSON(http.StatusOK, gin.H{"matches": serializedMatches})
+}
@@ -0,0 +1,293 @@
+// == Handles printable asset label sheets (Code128/QR) ==
+package asset
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image/png"
+	"slices"
+	"strings"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/report"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+
+	"github.com/SebastiaanKlippert/go-wkhtmltopdf"
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+)
+
+// Supported label symbologies.
+const (
+	SymbologyCode128 = "code128"
+	SymbologyQR      = "qr"
+)
+
+// maxAssetTagLength is the length of "Asset".asset_tag.
+const maxAssetTagLength = 12
+
+// DefaultLabelLayout is used when the request does not specify a layout.
+const DefaultLabelLayout = "L7160"
+
+// LabelLayout describes an Avery-style label sheet. All measurements are in millimetres.
+type LabelLayout struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	PageSize    string  `json:"page_size"`
+	Columns     int     `json:"columns"`
+	Rows        int     `json:"rows"`
+	LabelWidth  float64 `json:"label_width_mm"`
+	LabelHeight float64 `json:"label_height_mm"`
+	MarginTop   float64 `json:"margin_top_mm"`
+	MarginLeft  float64 `json:"margin_left_mm"`
+	GapX        float64 `json:"gap_x_mm"` // Horizontal space between two columns
+	GapY        float64 `json:"gap_y_mm"` // Vertical space between two rows
+}
+
+// LabelsPerSheet returns how many labels fit on one sheet.
+func (layout LabelLayout) LabelsPerSheet() int {
+	return layout.Columns * layout.Rows
+}
+
+// LabelLayouts lists the sheet layouts GA staff can print on.
+var LabelLayouts = map[string]LabelLayout{
+	"L7160": {Name: "L7160", Description: "A4, 21 labels (3 x 7), 63.5 x 38.1 mm", PageSize: wkhtmltopdf.PageSizeA4, Columns: 3, Rows: 7, LabelWidth: 63.5, LabelHeight: 38.1, MarginTop: 15.15, MarginLeft: 7.21, GapX: 2.54, GapY: 0},
+	"L7159": {Name: "L7159", Description: "A4, 24 labels (3 x 8), 63.5 x 33.9 mm", PageSize: wkhtmltopdf.PageSizeA4, Columns: 3, Rows: 8, LabelWidth: 63.5, LabelHeight: 33.9, MarginTop: 12.9, MarginLeft: 6.47, GapX: 2.54, GapY: 0},
+	"L7163": {Name: "L7163", Description: "A4, 14 labels (2 x 7), 99.1 x 38.1 mm", PageSize: wkhtmltopdf.PageSizeA4, Columns: 2, Rows: 7, LabelWidth: 99.1, LabelHeight: 38.1, MarginTop: 15.15, MarginLeft: 4.65, GapX: 2.54, GapY: 0},
+	"L7651": {Name: "L7651", Description: "A4, 65 labels (5 x 13), 38.1 x 21.2 mm", PageSize: wkhtmltopdf.PageSizeA4, Columns: 5, Rows: 13, LabelWidth: 38.1, LabelHeight: 21.2, MarginTop: 10.7, MarginLeft: 4.72, GapX: 2.54, GapY: 0},
+	"5160":  {Name: "5160", Description: "Letter, 30 labels (3 x 10), 66.7 x 25.4 mm", PageSize: wkhtmltopdf.PageSizeLetter, Columns: 3, Rows: 10, LabelWidth: 66.7, LabelHeight: 25.4, MarginTop: 12.7, MarginLeft: 4.8, GapX: 3.2, GapY: 0},
+}
+
+// pageDimensions maps a page size to its portrait width and height in millimetres.
+var pageDimensions = map[string][2]float64{
+	wkhtmltopdf.PageSizeA4:     {210, 297},
+	wkhtmltopdf.PageSizeLetter: {215.9, 279.4},
+}
+
+// LabelRequest selects which assets to print and how. Exactly one of SiteID, SubSiteID or AssetTags must be set.
+type LabelRequest struct {
+	SiteID    *int
+	SubSiteID *int
+	AssetTags []string
+	Symbology string
+	Layout    string
+	Skip      int // Number of label positions already used on the first sheet
+}
+
+// Validate checks the request and fills in defaults for symbology and layout.
+func (request *LabelRequest) Validate() error {
+	selectors := 0
+	if request.SiteID != nil {
+		selectors++
+	}
+	if request.SubSiteID != nil {
+		selectors++
+	}
+	if len(request.AssetTags) > 0 {
+		selectors++
+	}
+	if selectors != 1 {
+		return apperr.Validation("invalid_label_request", "exactly one of site_id, sub_site_id or tags must be provided")
+	}
+
+	var invalidTags []string
+	for _, assetTag := range request.AssetTags {
+		if len(assetTag) > maxAssetTagLength {
+			invalidTags = append(invalidTags, assetTag)
+		}
+	}
+	if len(invalidTags) > 0 {
+		return apperr.Validation("invalid_label_request", fmt.Sprintf("invalid asset tags, at most %d characters: %s", maxAssetTagLength, strings.Join(invalidTags, ", ")))
+	}
+
+	request.Symbology = strings.ToLower(strings.TrimSpace(request.Symbology))
+	if request.Symbology == "" {
+		request.Symbology = SymbologyCode128
+	}
+	if request.Symbology != SymbologyCode128 && request.Symbology != SymbologyQR {
+		return apperr.Validation("invalid_label_request", fmt.Sprintf("invalid symbology %q, must be %s or %s", request.Symbology, SymbologyCode128, SymbologyQR))
+	}
+
+	if request.Layout == "" {
+		request.Layout = DefaultLabelLayout
+	}
+	layout, ok := LabelLayouts[request.Layout]
+	if !ok {
+		return apperr.Validation("invalid_label_request", fmt.Sprintf("unknown label layout %q", request.Layout))
+	}
+	if request.Skip < 0 || request.Skip >= layout.LabelsPerSheet() {
+		return apperr.Validation("invalid_label_request", fmt.Sprintf("skip must be between 0 and %d for layout %s", layout.LabelsPerSheet()-1, layout.Name))
+	}
+
+	return nil
+}
+
+// assetLabel is a single label positioned on a sheet.
+type assetLabel struct {
+	AssetTag    string
+	ProductName string
+	SiteName    string
+	BarcodeURI  template.URL
+	Left        float64
+	Top         float64
+}
+
+// GenerateLabelsPDF renders the requested assets onto label sheets and returns the PDF bytes and a filename.
+func (service *Service) GenerateLabelsPDF(ctx context.Context, request LabelRequest) ([]byte, string, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if err := request.Validate(); err != nil {
+		return nil, "", err
+	}
+	layout := LabelLayouts[request.Layout]
+
+	assets, err := service.collectLabelAssets(ctx, request)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(assets) == 0 {
+		return nil, "", nil
+	}
+
+	// Lay the labels out row by row, leaving the first `Skip` positions empty on the first sheet.
+	perSheet := layout.LabelsPerSheet()
+	var pages [][]assetLabel
+	for i, labelledAsset := range assets {
+		position := i + request.Skip
+		if position%perSheet == 0 || len(pages) == 0 {
+			pages = append(pages, []assetLabel{})
+		}
+		slot := position % perSheet
+		column, row := slot%layout.Columns, slot/layout.Columns
+
+		barcodeURI, err := encodeBarcode(labelledAsset.AssetTag, request.Symbology)
+		if err != nil {
+			logger.Error("failed to encode barcode", "symbology", request.Symbology, "asset_tag", labelledAsset.AssetTag, "error", err)
+			return nil, "", err
+		}
+
+		pages[len(pages)-1] = append(pages[len(pages)-1], assetLabel{
+			AssetTag:    labelledAsset.AssetTag,
+			ProductName: labelledAsset.ProductName,
+			SiteName:    utils.SafeString(labelledAsset.SiteName),
+			BarcodeURI:  barcodeURI,
+			Left:        layout.MarginLeft + float64(column)*(layout.LabelWidth+layout.GapX),
+			Top:         layout.MarginTop + float64(row)*(layout.LabelHeight+layout.GapY),
+		})
+	}
+
+	dimensions := pageDimensions[layout.PageSize]
+	data := struct {
+		Layout     LabelLayout
+		Symbology  string
+		PageWidth  float64
+		PageHeight float64
+		Pages      [][]assetLabel
+	}{
+		Layout:     layout,
+		Symbology:  request.Symbology,
+		PageWidth:  dimensions[0],
+		PageHeight: dimensions[1],
+		Pages:      pages,
+	}
+
+	html, err := service.reportService.RenderTemplate("asset_labels.html", data)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to render template: %w", err)
+	}
+
+	pdfBytes, err := service.reportService.RenderPDF(ctx, html, report.PDFOptions{
+		Orientation:           wkhtmltopdf.OrientationPortrait,
+		PageSize:              layout.PageSize,
+		DisableSmartShrinking: true,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	logger.Info("generated asset labels", "count", len(assets), "sheets", len(pages), "layout", layout.Name, "symbology", request.Symbology)
+	filename := fmt.Sprintf("asset_labels_%s_%s.pdf", layout.Name, request.Symbology)
+	return pdfBytes, filename, nil
+}
+
+// collectLabelAssets resolves the request selector into a list of assets, keeping the requested order for explicit tags.
+func (service *Service) collectLabelAssets(ctx context.Context, request LabelRequest) ([]*LabelAsset, error) {
+	var assetTags []string
+	switch {
+	case request.SiteID != nil:
+		siteAssetTags, err := service.repo.GetAssetsOnLocation(ctx, request.SiteID, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, assetTag := range siteAssetTags {
+			assetTags = append(assetTags, *assetTag)
+		}
+	case request.SubSiteID != nil:
+		subSiteAssetTags, err := service.repo.GetAssetsOnSubSite(ctx, *request.SubSiteID)
+		if err != nil {
+			return nil, err
+		}
+		assetTags = subSiteAssetTags
+	default:
+		assetTags = request.AssetTags
+	}
+
+	assets, err := service.repo.GetAssetLabels(ctx, assetTags)
+	if err != nil {
+		return nil, err
+	}
+
+	// Explicitly requested tags must exist, otherwise the printed sheet would silently miss a label.
+	if len(request.AssetTags) > 0 {
+		found := make(map[string]bool, len(assets))
+		for _, labelledAsset := range assets {
+			found[labelledAsset.AssetTag] = true
+		}
+		var missing []string
+		for _, assetTag := range request.AssetTags {
+			if !found[assetTag] && !slices.Contains(missing, assetTag) {
+				missing = append(missing, assetTag)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, apperr.NotFound("asset_not_found", fmt.Sprintf("assets not found with tags: %s", strings.Join(missing, ", ")))
+		}
+	}
+
+	return assets, nil
+}
+
+// encodeBarcode renders the asset tag as a Code128 or QR PNG and returns it as a data URI for the template.
+func encodeBarcode(content, symbology string) (template.URL, error) {
+	var code barcode.Barcode
+	var err error
+
+	switch symbology {
+	case SymbologyQR:
+		code, err = qr.Encode(content, qr.M, qr.Auto)
+		if err == nil {
+			code, err = barcode.Scale(code, 300, 300)
+		}
+	default:
+		code, err = code128.Encode(content)
+		if err == nil {
+			// Scale by an integer factor so every bar keeps the same width.
+			code, err = barcode.Scale(code, code.Bounds().Dx()*4, 120)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var pngBuffer bytes.Buffer
+	if err := png.Encode(&pngBuffer, code); err != nil {
+		return "", err
+	}
+
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(pngBuffer.Bytes())), nil
+}
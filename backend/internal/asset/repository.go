@@ -2,10 +2,16 @@
 package asset
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+	"time"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/depreciation"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+	"github.com/lib/pq"
 )
 
 type Asset struct {
@@ -39,25 +45,56 @@ type Asset struct {
 	SiteName           sql.NullString
 	SiteGroupName      sql.NullString
 	RegionName         sql.NullString
+	AcquisitionDate    sql.NullTime   // Assets without one are not depreciated
+	DepreciationMethod sql.NullString // Depreciation policy of the product variety
+	UsefulLifeMonths   sql.NullInt64
+}
+
+// AcquisitionRequest is the body of PUT /api/asset/tag/:asset_tag/acquisition. TotalCost is left unchanged when omitted.
+type AcquisitionRequest struct {
+	AcquisitionDate string `json:"acquisition_date" binding:"required"` // YYYY-MM-DD
+	TotalCost       *int64 `json:"total_cost"`
+}
+
+// NetBookValue returns the value of the asset in the books at asOf, in rupiah, after depreciation.
+func (asset *Asset) NetBookValue(asOf time.Time) int64 {
+	valuation := depreciation.Valuation{
+		Cost:             int64(asset.TotalCost),
+		AcquisitionDate:  asset.AcquisitionDate,
+		Method:           asset.DepreciationMethod,
+		UsefulLifeMonths: asset.UsefulLifeMonths,
+	}
+	return valuation.BookValue(asOf)
+}
+
+// LabelAsset is what an asset label shows of an asset.
+type LabelAsset struct {
+	AssetTag    string
+	ProductName string
+	SiteName    sql.NullString
 }
 
 type Repository struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *slog.Logger
 }
 
 // NewRepository creates a new instance of Repository with the provided database connection.
-func NewRepository(db *sql.DB) *Repository {
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
 	return &Repository{
-		db: db,
+		db:     db,
+		logger: logger,
 	}
 }
 
-func (repo *Repository) GetAssetByTag(assetTag string) (*Asset, error) {
+func (repo *Repository) GetAssetByTag(ctx context.Context, assetTag string) (*Asset, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var asset Asset
 
 	query := `SELECT * FROM get_asset_by_tag($1)`
 
-	err := repo.db.QueryRow(query, assetTag).Scan(
+	err := repo.db.QueryRowContext(ctx, query, assetTag).Scan(
 		&asset.AssetTag,
 		&asset.SerialNumber,
 		&asset.Status,
@@ -88,29 +125,34 @@ func (repo *Repository) GetAssetByTag(assetTag string) (*Asset, error) {
 		&asset.SiteName,
 		&asset.SiteGroupName,
 		&asset.RegionName,
+		&asset.AcquisitionDate,
+		&asset.DepreciationMethod,
+		&asset.UsefulLifeMonths,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("⚠ No asset found with tag: %s", assetTag)
+			logger.Debug("no asset found", "asset_tag", assetTag)
 			return nil, nil // No asset found with the given tag
 		}
 
-		log.Printf("❌ Error retrieving asset by tag: %s, error: %v", assetTag, err)
-		return nil, err // Return any other error
+		logger.Error("failed to query asset by tag", "asset_tag", assetTag, "error", err)
+		return nil, apperr.FromPostgres(err) // Return any other error
 	}
 
-	log.Printf("✅ Successfully retrieved asset by tag: %s", assetTag)
+	logger.Debug("retrieved asset by tag", "asset_tag", assetTag)
 	return &asset, nil // Return the found asset
 }
 
 // GetAssetBySerialNumber retrieves an asset by its serial number.
-func (repo *Repository) GetAssetBySerialNumber(serialNumber string) (*Asset, error) {
+func (repo *Repository) GetAssetBySerialNumber(ctx context.Context, serialNumber string) (*Asset, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var asset Asset
 
 	query := `SELECT * FROM get_asset_by_serial_number($1)`
 
-	err := repo.db.QueryRow(query, serialNumber).Scan(
+	err := repo.db.QueryRowContext(ctx, query, serialNumber).Scan(
 		&asset.AssetTag,
 		&asset.SerialNumber,
 		&asset.Status,
@@ -141,24 +183,29 @@ func (repo *Repository) GetAssetBySerialNumber(serialNumber string) (*Asset, err
 		&asset.SiteName,
 		&asset.SiteGroupName,
 		&asset.RegionName,
+		&asset.AcquisitionDate,
+		&asset.DepreciationMethod,
+		&asset.UsefulLifeMonths,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("⚠ No asset found with serial number: %s", serialNumber)
+			logger.Debug("no asset found", "serial_number", serialNumber)
 			return nil, nil // No asset found with the given serial number
 		}
 
-		log.Printf("❌ Error retrieving asset by serial number: %s, error: %v", serialNumber, err)
-		return nil, err // Return any other error
+		logger.Error("failed to query asset by serial number", "serial_number", serialNumber, "error", err)
+		return nil, apperr.FromPostgres(err) // Return any other error
 	}
 
-	log.Printf("✅ Successfully retrieved asset by serial number: %s", serialNumber)
+	logger.Debug("retrieved asset by serial number", "serial_number", serialNumber)
 	return &asset, nil // Return the found asset
 }
 
 // GetAssetsOnLocation retrieves all assets for a given location.
-func (repo *Repository) GetAssetsOnLocation(siteID *int, deptID *int) ([]*string, error) {
+func (repo *Repository) GetAssetsOnLocation(ctx context.Context, siteID *int, deptID *int) ([]*string, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var assets []*string
 
 	query := `SELECT * FROM get_assets_by_location($1, $2)`
@@ -166,10 +213,10 @@ func (repo *Repository) GetAssetsOnLocation(siteID *int, deptID *int) ([]*string
 	siteIDParam := utils.ParseNullableInt(siteID)
 	deptIDParam := utils.ParseNullableInt(deptID)
 
-	rows, err := repo.db.Query(query, siteIDParam, deptIDParam)
+	rows, err := repo.db.QueryContext(ctx, query, siteIDParam, deptIDParam)
 	if err != nil {
-		log.Printf("❌ Error retrieving assets for site ID %v or department ID %v: %v", siteIDParam, deptIDParam, err)
-		return nil, err // Return the error if query fails
+		logger.Error("failed to query assets for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID), "error", err)
+		return nil, apperr.FromPostgres(err) // Return the error if query fails
 	}
 
 	defer rows.Close()
@@ -177,34 +224,116 @@ func (repo *Repository) GetAssetsOnLocation(siteID *int, deptID *int) ([]*string
 	for rows.Next() {
 		var assetTag string
 		if err := rows.Scan(&assetTag); err != nil {
-			log.Printf("❌ Error scanning asset row: %v", err)
-			return nil, err // Return the error if scanning fails
+			logger.Error("failed to scan asset row", "error", err)
+			return nil, apperr.FromPostgres(err) // Return the error if scanning fails
 		}
 		assets = append(assets, &assetTag) // Append the asset tag to the slice
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("❌ Error iterating over asset rows: %v", err)
-		return nil, err // Return any error encountered during iteration
+		logger.Error("failed to iterate asset rows", "error", err)
+		return nil, apperr.FromPostgres(err) // Return any error encountered during iteration
 	}
 
-	log.Printf("✅ Successfully retrieved %d assets for site ID: %d", len(assets), siteID)
+	logger.Debug("retrieved assets for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID), "count", len(assets))
 	return assets, nil // Return the slice of asset tags found
 }
 
 // GetAssetEquipments retrieves all equipments for a given product variety.
-func (repo *Repository) GetAssetEquipments(productVariety string) (string, error) {
+func (repo *Repository) GetAssetEquipments(ctx context.Context, productVariety string) (string, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var equipments string
 
 	query := `SELECT equipments FROM get_asset_equipments($1)`
 
-	if err := repo.db.QueryRow(query, productVariety).Scan(&equipments); err != nil {
+	if err := repo.db.QueryRowContext(ctx, query, productVariety).Scan(&equipments); err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("⚠ No equipments found for product variety: %s", productVariety)
+			logger.Debug("no equipments found", "product_variety", productVariety)
 			return "", nil // No equipments found for the given product variety
 		}
 	}
 
-	log.Printf("✅ Successfully retrieved equipments for product variety: %s", productVariety)
+	logger.Debug("retrieved equipments", "product_variety", productVariety)
 	return equipments, nil // Return the string of equipments found
 }
+
+// GetAssetsOnSubSite retrieves all asset tags placed in a given sub-site.
+func (repo *Repository) GetAssetsOnSubSite(ctx context.Context, subSiteID int) ([]string, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	var assetTags []string
+
+	query := `SELECT * FROM get_assets_by_sub_site($1)`
+
+	rows, err := repo.db.QueryContext(ctx, query, subSiteID)
+	if err != nil {
+		logger.Error("failed to query assets for sub-site", "sub_site_id", subSiteID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var assetTag string
+		if err := rows.Scan(&assetTag); err != nil {
+			logger.Error("failed to scan asset row", "sub_site_id", subSiteID, "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		assetTags = append(assetTags, assetTag)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to iterate asset rows", "sub_site_id", subSiteID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	logger.Debug("retrieved assets for sub-site", "sub_site_id", subSiteID, "count", len(assetTags))
+	return assetTags, nil
+}
+
+// GetAssetLabels retrieves the label contents of the given assets in one query, in the order of the tags.
+// Unknown tags are left out.
+func (repo *Repository) GetAssetLabels(ctx context.Context, assetTags []string) ([]*LabelAsset, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT asset_tag, product_name, site_name FROM get_asset_labels($1)`
+
+	rows, err := repo.db.QueryContext(ctx, query, pq.Array(assetTags))
+	if err != nil {
+		logger.Error("failed to query asset labels", "count", len(assetTags), "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	labels := make([]*LabelAsset, 0, len(assetTags))
+	for rows.Next() {
+		var label LabelAsset
+		if err := rows.Scan(&label.AssetTag, &label.ProductName, &label.SiteName); err != nil {
+			logger.Error("failed to scan asset label row", "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		labels = append(labels, &label)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to iterate asset label rows", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return labels, nil
+}
+
+// SetAcquisition records when an asset was acquired and, if totalCost is set, for how much.
+func (repo *Repository) SetAcquisition(ctx context.Context, assetTag string, acquisitionDate time.Time, totalCost *int64) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL set_asset_acquisition($1, $2, $3)`
+
+	if _, err := repo.db.ExecContext(ctx, query, assetTag, acquisitionDate.Format(time.DateOnly), totalCost); err != nil {
+		logger.Warn("failed to set asset acquisition", "asset_tag", assetTag, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("set asset acquisition", "asset_tag", assetTag, "acquisition_date", acquisitionDate.Format(time.DateOnly))
+	return nil
+}
@@ -2,85 +2,112 @@
 package asset
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"math"
 	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/report"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+)
+
+// Errors returned when recording the acquisition of an asset.
+var (
+	ErrAcquisitionAdminOnly   = apperr.Forbidden("asset_acquisition_forbidden", "only an admin can change the acquisition of an asset")
+	ErrAssetNotFound          = apperr.NotFound("asset_not_found", "asset not found")
+	ErrInvalidAcquisitionDate = apperr.Validation("invalid_acquisition_date", "acquisition_date must be a past date formatted as YYYY-MM-DD")
+	ErrInvalidTotalCost       = apperr.Validation("invalid_total_cost", "total_cost must be between 0 and 2147483647")
 )
 
 type Service struct {
-	repo *Repository
+	repo          *Repository
+	reportService *report.Service
+	logger        *slog.Logger
 }
 
 // NewService creates a new instance of the asset service.
-func NewService(repo *Repository) *Service {
+// The report service provides the PDF pipeline used to print asset labels.
+func NewService(repo *Repository, reportService *report.Service, logger *slog.Logger) *Service {
 	return &Service{
-		repo: repo,
+		repo:          repo,
+		reportService: reportService,
+		logger:        logger,
 	}
 }
 
 // GetAssetByTag retrieves an asset by its tag.
-func (service *Service) GetAssetByTag(assetTag string) (*Asset, error) {
-	asset, err := service.repo.GetAssetByTag(assetTag)
+func (service *Service) GetAssetByTag(ctx context.Context, assetTag string) (*Asset, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	asset, err := service.repo.GetAssetByTag(ctx, assetTag)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching asset by tag %s: %v", assetTag, err)
+		logger.Error("failed to fetch asset by tag", "asset_tag", assetTag, "error", err)
 		return nil, err
 	}
 	if asset == nil {
 		// If no asset is found, return nil
-		log.Printf("No asset found with tag: %s", assetTag)
+		logger.Debug("no asset found", "asset_tag", assetTag)
 		return nil, nil // No asset found
 	}
 
-	log.Printf("Successfully retrieved asset by tag: %s", assetTag)
 	return asset, nil
 }
 
 // GetAssetBySerialNumber retrieves an asset by its serial number.
-func (service *Service) GetAssetBySerialNumber(serialNumber string) (*Asset, error) {
-	asset, err := service.repo.GetAssetBySerialNumber(serialNumber)
+func (service *Service) GetAssetBySerialNumber(ctx context.Context, serialNumber string) (*Asset, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	asset, err := service.repo.GetAssetBySerialNumber(ctx, serialNumber)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching asset by serial number %s: %v", serialNumber, err)
+		logger.Error("failed to fetch asset by serial number", "serial_number", serialNumber, "error", err)
 		return nil, err
 	}
 	if asset == nil {
 		// If no asset is found, return nil
-		log.Printf("No asset found with serial number: %s", serialNumber)
+		logger.Debug("no asset found", "serial_number", serialNumber)
 		return nil, nil // No asset found
 	}
 
-	log.Printf("Successfully retrieved asset by serial number: %s", serialNumber)
 	return asset, nil
 }
 
 // GetAssetsOnLocation retrieves all assets for a given location.
-func (service *Service) GetAssetsOnLocation(siteID *int, deptID *int) ([]*Asset, error) {
+func (service *Service) GetAssetsOnLocation(ctx context.Context, siteID *int, deptID *int) ([]*Asset, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
 	var assetsOnSite []*Asset
-	assetTags, err := service.repo.GetAssetsOnLocation(siteID, deptID)
+	assetTags, err := service.repo.GetAssetsOnLocation(ctx, siteID, deptID)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching assets for site_id %d or dept_id %d: %v", siteID, deptID, err)
+		logger.Error("failed to fetch assets for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID), "error", err)
 		return nil, err
 	}
 
 	if assetTags == nil {
 		// If no assets are found, return an empty slice
-		log.Printf("No assets found for site_id: %d", siteID)
+		logger.Debug("no assets found for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID))
 		return nil, nil // No assets found
 	}
 
 	for _, assetTag := range assetTags {
 		// TODO: get asset object info for each tag
 		var asset *Asset
-		asset, err = service.repo.GetAssetByTag(*assetTag)
+		asset, err = service.repo.GetAssetByTag(ctx, *assetTag)
 		if err != nil {
 			// Log the error and continue to the next asset
-			log.Printf("Error retrieving asset by tag %s for site_id %d: %v", *assetTag, siteID, err)
+			logger.Warn("skipping asset that could not be retrieved", "asset_tag", *assetTag, "error", err)
 			continue // Skip this asset and continue with the next
 		}
 		if asset == nil {
 			// If no asset is found for this tag, log it and continue
-			log.Printf("No asset found with tag: %s for site_id: %d", *assetTag, siteID)
+			logger.Warn("skipping asset listed on location but not found", "asset_tag", *assetTag)
 			continue // Skip this asset and continue with the next
 		}
 
@@ -88,32 +115,78 @@ func (service *Service) GetAssetsOnLocation(siteID *int, deptID *int) ([]*Asset,
 		assetsOnSite = append(assetsOnSite, asset)
 	}
 
-	log.Printf("Successfully retrieved %d assets for site_id: %d", len(assetsOnSite), siteID)
+	logger.Debug("retrieved assets for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID), "count", len(assetsOnSite))
 	return assetsOnSite, nil
 }
 
 // GetAssetEquipments retrieves all equipments for a given product variety.
-func (service *Service) GetAssetEquipments(productVariety string) (string, error) {
+func (service *Service) GetAssetEquipments(ctx context.Context, productVariety string) (string, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
 	// Decode the product variety
 	decodedVariety, err := url.QueryUnescape(productVariety)
 	if err != nil {
-		log.Printf("Error decoding product variety %s: %v", productVariety, err)
+		logger.Warn("invalid product variety encoding", "product_variety", productVariety, "error", err)
 		return "", err // Return the error if decoding fails
 	}
 
-	equipments, err := service.repo.GetAssetEquipments(decodedVariety)
+	equipments, err := service.repo.GetAssetEquipments(ctx, decodedVariety)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching equipments for product variety %s: %v", decodedVariety, err)
+		logger.Error("failed to fetch equipments", "product_variety", decodedVariety, "error", err)
 		return "", err
 	}
 
 	if equipments == "" {
 		// If no equipments are found, return an empty string
-		log.Printf("No equipments found for product variety: %s", decodedVariety)
+		logger.Debug("no equipments found", "product_variety", decodedVariety)
 		return "", nil // No equipments found
 	}
 
-	log.Printf("Successfully retrieved equipments for product variety: %s", decodedVariety)
 	return equipments, nil
 }
+
+// SetAcquisition records when, and optionally for how much, an asset was acquired on behalf of L1 support and returns the asset.
+func (service *Service) SetAcquisition(ctx context.Context, adminPosition string, assetTag string, request AcquisitionRequest) (*Asset, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !roles.IsAdmin(adminPosition) {
+		logger.Warn("asset acquisition update denied", "position", adminPosition, "asset_tag", assetTag)
+		return nil, ErrAcquisitionAdminOnly
+	}
+
+	acquisitionDate, err := time.Parse(time.DateOnly, strings.TrimSpace(request.AcquisitionDate))
+	if err != nil || acquisitionDate.After(time.Now()) {
+		return nil, ErrInvalidAcquisitionDate
+	}
+	if request.TotalCost != nil && (*request.TotalCost < 0 || *request.TotalCost > math.MaxInt32) {
+		return nil, ErrInvalidTotalCost
+	}
+
+	before, err := service.GetAssetByTag(ctx, assetTag)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, ErrAssetNotFound
+	}
+	if err := service.repo.SetAcquisition(ctx, assetTag, acquisitionDate, request.TotalCost); err != nil {
+		return nil, err
+	}
+	after, err := service.GetAssetByTag(ctx, assetTag)
+	if err != nil {
+		return nil, err
+	}
+	if after == nil {
+		return nil, ErrAssetNotFound
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "asset.acquisition_set",
+		EntityType: "asset",
+		EntityID:   assetTag,
+		Before:     map[string]any{"acquisition_date": serializeDate(before.AcquisitionDate), "total_cost": before.TotalCost},
+		After:      map[string]any{"acquisition_date": serializeDate(after.AcquisitionDate), "total_cost": after.TotalCost},
+	})
+	return after, nil
+}
@@ -0,0 +1,105 @@
+// == Handles API requests related to the audit log ==
+package audit
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readerPositions may read the audit log, on top of the admin positions.
+var readerPositions = []string{"AUDITOR"}
+
+type Handler struct {
+	service *Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates a new audit log handler.
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetAuditLogHandler lists audit entries, filterable by actor_id, action, entity_type, entity_id, from_date and end_date (YYYY-MM-DD).
+func (handler *Handler) GetAuditLogHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	position := context.GetString("position")
+	if !canReadAuditLog(position) {
+		logger.Warn("audit log access denied", "position", position)
+		apperr.Abort(context, apperr.Forbidden("forbidden", "only admins and auditors can read the audit log"))
+		return
+	}
+
+	var filter Filter
+	if err := context.ShouldBindQuery(&filter); err != nil {
+		logger.Warn("invalid audit log filter", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_query", "invalid query parameters: "+err.Error()))
+		return
+	}
+	for _, date := range []*string{filter.FromDate, filter.EndDate} {
+		if date == nil || *date == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, *date); err != nil {
+			apperr.Abort(context, apperr.Validation("invalid_query", "from_date and end_date must be formatted as YYYY-MM-DD"))
+			return
+		}
+	}
+
+	entries, totalCount, err := handler.service.GetEntries(context.Request.Context(), filter)
+	if err != nil {
+		logger.Error("failed to retrieve audit log", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	serialized := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		serialized = append(serialized, gin.H{
+			"id":             entry.ID,
+			"occurred_at":    entry.OccurredAt,
+			"actor_id":       utils.SerializeNI(entry.ActorID),
+			"actor_username": entry.ActorUsername,
+			"actor_position": entry.ActorPosition,
+			"action":         entry.Action,
+			"entity_type":    entry.EntityType,
+			"entity_id":      entry.EntityID,
+			"before":         entry.Before,
+			"after":          entry.After,
+			"ip_address":     entry.IPAddress,
+			"user_agent":     entry.UserAgent,
+			"request_id":     entry.RequestID,
+			"status_code":    utils.SerializeNI(entry.StatusCode),
+		})
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"entries":     serialized,
+		"total_count": totalCount,
+	})
+}
+
+// canReadAuditLog reports whether a position may read the audit log.
+func canReadAuditLog(position string) bool {
+	if roles.IsAdmin(position) {
+		return true
+	}
+	for _, allowed := range readerPositions {
+		if strings.EqualFold(position, allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+// == Makes every request auditable and keeps a generic entry for the mutations no service described ==
+package audit
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware places a recorder in the request context of every request so services can call Record.
+// When a POST, PUT, PATCH or DELETE request ends without any recorded event (it was refused, failed, or the route has no hook),
+// a generic "request" entry with the route and status code is written instead, so no mutation goes unaudited.
+// It must run before apperr.Middleware so the final status code is known.
+func Middleware(service *Service) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		rec := &recorder{service: service, ginContext: context}
+		context.Request = context.Request.WithContext(withRecorder(context.Request.Context(), rec))
+
+		context.Next()
+
+		if rec.recorded || !isMutation(context.Request.Method) {
+			return
+		}
+		event := Event{
+			Action:     "request",
+			EntityType: "route",
+			EntityID:   context.Request.Method + " " + context.FullPath(),
+		}
+		if len(context.Errors) > 0 {
+			event.After = gin.H{"error": context.Errors.Last().Error()}
+		}
+		status := sql.NullInt64{Int64: int64(context.Writer.Status()), Valid: true}
+		service.write(context.Request.Context(), rec.entry(event, status), event.Before, event.After)
+	}
+}
+
+// isMutation reports whether the HTTP method changes state.
+func isMutation(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
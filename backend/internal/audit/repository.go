@@ -0,0 +1,156 @@
+// == Handles all database operations related to the audit log ==
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+)
+
+// Entry is one row of the audit log.
+type Entry struct {
+	ID            int64           `json:"id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	ActorID       sql.NullInt64   `json:"actor_id"`
+	ActorUsername string          `json:"actor_username"`
+	ActorPosition string          `json:"actor_position"`
+	Action        string          `json:"action"`
+	EntityType    string          `json:"entity_type"`
+	EntityID      string          `json:"entity_id"`
+	Before        json.RawMessage `json:"before"`
+	After         json.RawMessage `json:"after"`
+	IPAddress     string          `json:"ip_address"`
+	UserAgent     string          `json:"user_agent"`
+	RequestID     string          `json:"request_id"`
+	StatusCode    sql.NullInt64   `json:"status_code"`
+}
+
+// Filter narrows down GET /api/audit. Every field is optional.
+type Filter struct {
+	ActorID    *int    `json:"actor_id" form:"actor_id"`
+	Action     *string `json:"action" form:"action"`
+	EntityType *string `json:"entity_type" form:"entity_type"`
+	EntityID   *string `json:"entity_id" form:"entity_id"`
+	FromDate   *string `json:"from_date" form:"from_date"`
+	EndDate    *string `json:"end_date" form:"end_date"`
+	Limit      *int    `json:"limit" form:"limit"`
+	PageNum    *int    `json:"page_num" form:"page_num"`
+}
+
+type Repository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewRepository creates a new audit log repository.
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// InsertEntry appends an entry to the audit log and returns its ID.
+func (repo *Repository) InsertEntry(ctx context.Context, entry Entry) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT record_audit_event($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	var id int64
+	err := repo.db.QueryRowContext(
+		ctx,
+		query,
+		entry.ActorID,
+		entry.ActorUsername,
+		entry.ActorPosition,
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		nullableJSON(entry.Before),
+		nullableJSON(entry.After),
+		entry.IPAddress,
+		entry.UserAgent,
+		entry.RequestID,
+		entry.StatusCode,
+	).Scan(&id)
+	if err != nil {
+		logger.Error("failed to insert audit entry", "action", entry.Action, "entity_type", entry.EntityType, "entity_id", entry.EntityID, "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+
+	return id, nil
+}
+
+// GetEntries retrieves audit entries matching the filter, newest first, along with the total number of matches.
+func (repo *Repository) GetEntries(ctx context.Context, filter Filter) ([]Entry, int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_audit_log($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	rows, err := repo.db.QueryContext(
+		ctx,
+		query,
+		filter.ActorID,
+		filter.Action,
+		filter.EntityType,
+		filter.EntityID,
+		filter.FromDate,
+		filter.EndDate,
+		filter.Limit,
+		filter.PageNum,
+	)
+	if err != nil {
+		logger.Error("failed to query audit log", "error", err)
+		return nil, 0, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	entries := make([]Entry, 0)
+	var totalCount int64
+	for rows.Next() {
+		var entry Entry
+		var before, after []byte
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.OccurredAt,
+			&entry.ActorID,
+			&entry.ActorUsername,
+			&entry.ActorPosition,
+			&entry.Action,
+			&entry.EntityType,
+			&entry.EntityID,
+			&before,
+			&after,
+			&entry.IPAddress,
+			&entry.UserAgent,
+			&entry.RequestID,
+			&entry.StatusCode,
+			&totalCount,
+		); err != nil {
+			logger.Error("failed to scan audit entry", "error", err)
+			return nil, 0, apperr.FromPostgres(err)
+		}
+		entry.Before, entry.After = before, after
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to iterate audit entries", "error", err)
+		return nil, 0, apperr.FromPostgres(err)
+	}
+
+	return entries, totalCount, nil
+}
+
+// nullableJSON stores an empty snapshot as NULL instead of invalid JSON.
+func nullableJSON(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
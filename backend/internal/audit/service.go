@@ -0,0 +1,187 @@
+// == Records who did what: services describe each mutation with an Event, the middleware adds the actor and request metadata ==
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeTimeout bounds a single audit insert. It runs detached from the request so a client disconnect does not lose the entry.
+const writeTimeout = 5 * time.Second
+
+// Actor identifies who performed an action.
+type Actor struct {
+	UserID   int64
+	Username string
+	Position string
+}
+
+// Event describes one auditable action. Before and After are snapshots marshalled to JSON, nil when not applicable.
+type Event struct {
+	Action     string // e.g. "opname.cancel"
+	EntityType string // e.g. "opname_session"
+	EntityID   string
+	Before     any
+	After      any
+	Actor      *Actor // overrides the authenticated user, e.g. on login where there is none yet
+}
+
+type Service struct {
+	repo   *Repository
+	logger *slog.Logger
+}
+
+// NewService creates a new audit service.
+func NewService(repo *Repository, logger *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// GetEntries retrieves audit entries matching the filter, newest first, along with the total number of matches.
+func (service *Service) GetEntries(ctx context.Context, filter Filter) ([]Entry, int64, error) {
+	return service.repo.GetEntries(ctx, filter)
+}
+
+// write stores an entry. Failures are logged but never fail the audited request, whose change is already committed.
+func (service *Service) write(ctx context.Context, entry Entry, before, after any) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	var err error
+	if entry.Before, err = marshalSnapshot(before); err != nil {
+		logger.Error("failed to marshal audit snapshot", "action", entry.Action, "error", err)
+	}
+	if entry.After, err = marshalSnapshot(after); err != nil {
+		logger.Error("failed to marshal audit snapshot", "action", entry.Action, "error", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), writeTimeout)
+	defer cancel()
+	if _, err := service.repo.InsertEntry(ctx, entry); err != nil {
+		logger.Error("failed to write audit entry", "action", entry.Action, "entity_type", entry.EntityType, "entity_id", entry.EntityID, "error", err)
+	}
+}
+
+// marshalSnapshot turns a snapshot into JSON, keeping nil (and already encoded JSON) as is.
+func marshalSnapshot(snapshot any) (json.RawMessage, error) {
+	switch value := snapshot.(type) {
+	case nil:
+		return nil, nil
+	case json.RawMessage:
+		return value, nil
+	case []byte:
+		return json.RawMessage(value), nil
+	}
+	return json.Marshal(snapshot)
+}
+
+// recorder is placed in the request context by the middleware and writes events with the request's actor and metadata.
+// Background jobs get one without a request, see Background.
+type recorder struct {
+	service    *Service
+	ginContext *gin.Context // nil outside a request
+	actor      *Actor       // Default actor outside a request, nil for the system itself
+	recorded   bool
+}
+
+type contextKey struct{}
+
+// withRecorder stores the request's recorder in ctx.
+func withRecorder(ctx context.Context, rec *recorder) context.Context {
+	return context.WithValue(ctx, contextKey{}, rec)
+}
+
+// Background returns a context in which Record works without a request, for jobs such as scheduled syncs.
+// Events are attributed to actor, the user who triggered the job, or to nobody (the system) when nil.
+func (service *Service) Background(ctx context.Context, actor *Actor) context.Context {
+	return withRecorder(ctx, &recorder{service: service, actor: actor})
+}
+
+// Record writes an audit entry for the current request. Services call it after a mutation succeeded.
+// Outside an audited request or a Background context the event is logged and dropped.
+func Record(ctx context.Context, event Event) {
+	rec, ok := ctx.Value(contextKey{}).(*recorder)
+	if !ok {
+		slog.Default().Warn("audit event outside an audited request, dropped", "action", event.Action, "entity_type", event.EntityType, "entity_id", event.EntityID)
+		return
+	}
+	rec.recorded = true
+	rec.service.write(ctx, rec.entry(event, sql.NullInt64{}), event.Before, event.After)
+}
+
+// Transitions audits the status changes of one type of entity, e.g. approving or cancelling a transfer.
+type Transitions[T any] struct {
+	EntityType string                                         // e.g. "asset_transfer"
+	Reload     func(ctx context.Context, id int64) (T, error) // Reads the entity again once a change is stored
+	Snapshot   func(entity T) map[string]any                  // The audited state of the entity
+}
+
+// Record audits a status change of the entity with the given ID, from its state before, and returns its state after.
+func (transitions Transitions[T]) Record(ctx context.Context, action string, id int64, before T) (T, error) {
+	after, err := transitions.Reload(ctx, id)
+	if err != nil {
+		var none T
+		return none, err
+	}
+
+	Record(ctx, Event{
+		Action:     action,
+		EntityType: transitions.EntityType,
+		EntityID:   strconv.FormatInt(id, 10),
+		Before:     transitions.Snapshot(before),
+		After:      transitions.Snapshot(after),
+	})
+	return after, nil
+}
+
+// entry builds the stored entry from an event, the authenticated user and the request.
+func (rec *recorder) entry(event Event, statusCode sql.NullInt64) Entry {
+	actor := event.Actor
+	if actor == nil && rec.ginContext != nil {
+		actor = actorFromGin(rec.ginContext)
+	}
+	if actor == nil {
+		actor = rec.actor
+	}
+
+	entry := Entry{
+		Action:     event.Action,
+		EntityType: event.EntityType,
+		EntityID:   event.EntityID,
+		StatusCode: statusCode,
+	}
+	if rec.ginContext != nil {
+		entry.IPAddress = rec.ginContext.ClientIP()
+		entry.UserAgent = rec.ginContext.Request.UserAgent()
+		entry.RequestID = rec.ginContext.GetString("request_id")
+	}
+	if actor != nil {
+		entry.ActorID = sql.NullInt64{Int64: actor.UserID, Valid: actor.UserID > 0}
+		entry.ActorUsername = actor.Username
+		entry.ActorPosition = actor.Position
+	}
+	return entry
+}
+
+// actorFromGin reads the user placed in the gin context by the auth middleware, nil for anonymous requests.
+func actorFromGin(context *gin.Context) *Actor {
+	userID, exists := context.Get("user_id")
+	if !exists {
+		return nil
+	}
+	id, _ := userID.(int64)
+	return &Actor{
+		UserID:   id,
+		Username: context.GetString("username"),
+		Position: context.GetString("position"),
+	}
+}
@@ -2,29 +2,47 @@
 package auth
 
 import (
+	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
 	"github.com/gin-gonic/gin"
 )
 
 // Handler holds the authentication service.
 type Handler struct {
 	service *Service
+	logger  *slog.Logger
 }
 
 // NewHandler creates a new auth handler with the provided authentication service.
-func NewHandler(service *Service) *Handler {
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
 	return &Handler{
 		service: service,
+		logger:  logger,
 	}
 }
 
+// The OIDC flow cookie only lives between the redirect to the provider and its callback.
+const (
+	oidcFlowCookie  = "sosmit_oidc_flow"
+	oidcFlowPath    = "/api/auth/oidc"
+	oidcFlowTimeout = 10 * time.Minute
+)
+
 // LoginRequest defines the structure of the login request in JSON format.
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
+// UnlockRequest defines the body of an account unlock request.
+type UnlockRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
 // LoginHandler processes the login request.
 func (handler *Handler) LoginHandler(context *gin.Context) {
 	var request LoginRequest
@@ -32,15 +50,15 @@ func (handler *Handler) LoginHandler(context *gin.Context) {
 	// Bind the incoming JSON request to the LoginRequest struct.
 	// If the JSON is malformed or required fields are missing, it will return a 400 Bad Request response (Gin handles it).
 	if err := context.ShouldBindJSON(&request); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "Invalid request format"))
 		return
 	}
 
 	// Call the login service to validate the credentials and generate a JWT token.
-	token, err := handler.service.Login(request.Username, request.Password)
+	token, err := handler.service.Login(context.Request.Context(), request.Username, request.Password, context.ClientIP())
 	if err != nil {
-		// If an error occurs during login, return a 401 Unauthorized response with the error message.
-		context.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		// Every rejected credential becomes 401 (ErrInvalidCredentials), throttled attempts 429 with Retry-After (ErrTooManyAttempts).
+		apperr.Abort(context, err)
 		return
 	}
 
@@ -48,3 +66,90 @@ func (handler *Handler) LoginHandler(context *gin.Context) {
 	context.JSON(http.StatusOK, gin.H{"token": token})
 
 }
+
+// UnlockAccountHandler lifts the lockout of a username after repeated failed logins.
+func (handler *Handler) UnlockAccountHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	var request UnlockRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid unlock request", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	// Get the acting user from context (placed by auth middleware)
+	userID, exists := context.Get("user_id")
+	if !exists {
+		apperr.Abort(context, apperr.Unauthorized("unauthorized", "user not authenticated"))
+		return
+	}
+
+	if err := handler.service.UnlockAccount(context.Request.Context(), userID.(int64), context.GetString("position"), request.Username); err != nil {
+		logger.Error("failed to unlock account", "username", request.Username, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "account unlocked successfully"})
+}
+
+// OIDCLoginHandler starts a single sign-on by redirecting the browser to the provider's login page.
+func (handler *Handler) OIDCLoginHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	if !handler.service.SSOEnabled() {
+		apperr.Abort(context, ErrSSODisabled)
+		return
+	}
+
+	authURL, flow, err := handler.service.StartOIDCLogin(context.Request.Context())
+	if err != nil {
+		logger.Error("failed to start oidc login", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.SetSameSite(http.SameSiteLaxMode) // Lax, so the cookie comes back on the provider's top-level redirect
+	context.SetCookie(oidcFlowCookie, flow.Encode(), int(oidcFlowTimeout.Seconds()), oidcFlowPath, "", handler.service.SecureOIDCCookie(), true)
+	context.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallbackHandler completes a single sign-on. The browser is sent back to the frontend with the SOSMIT token,
+// or with an error code when the login failed.
+func (handler *Handler) OIDCCallbackHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	if !handler.service.SSOEnabled() {
+		apperr.Abort(context, ErrSSODisabled)
+		return
+	}
+
+	// The flow cookie is single use.
+	cookie, _ := context.Cookie(oidcFlowCookie)
+	context.SetSameSite(http.SameSiteLaxMode)
+	context.SetCookie(oidcFlowCookie, "", -1, oidcFlowPath, "", handler.service.SecureOIDCCookie(), true)
+
+	if providerError := context.Query("error"); providerError != "" {
+		logger.Warn("oidc provider returned an error", "error", providerError, "description", context.Query("error_description"))
+		context.Redirect(http.StatusFound, handler.service.OIDCCallbackURL("", ErrSSOFailed.Code))
+		return
+	}
+
+	flow, ok := DecodeFlow(cookie)
+	if !ok || !flow.MatchesState(context.Query("state")) {
+		logger.Warn("oidc callback with missing or mismatching state")
+		context.Redirect(http.StatusFound, handler.service.OIDCCallbackURL("", ErrSSOInvalidState.Code))
+		return
+	}
+
+	token, err := handler.service.LoginWithOIDC(context.Request.Context(), flow, context.Query("code"), context.ClientIP())
+	if err != nil {
+		appErr := apperr.From(err)
+		logger.Warn("oidc login rejected", "code", appErr.Code, "error", err)
+		context.Redirect(http.StatusFound, handler.service.OIDCCallbackURL("", appErr.Code))
+		return
+	}
+
+	context.Redirect(http.StatusFound, handler.service.OIDCCallbackURL(token, ""))
+}
@@ -5,34 +5,41 @@ package auth
 
 import (
 	"fmt"
-	"net/http"
+	"log/slog"
 	"strings"
 	"time"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/user"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 // AuthMiddleware is a function that intercepts HTTP requests and responses.
 // It checks for a valid JWT token in the request header and verifies the user's role.
-func AuthMiddleware() gin.HandlerFunc {
+// Tokens are verified with auth.jwt_secret, the key the auth service signs them with.
+// Tokens of deactivated users are refused even before they expire.
+func AuthMiddleware(authConfig config.AuthConfig, userRepo *user.Repository) gin.HandlerFunc {
+	jwtKey := authConfig.JWTKey()
 	return func(context *gin.Context) {
+		logger := logging.FromGin(context, slog.Default())
+
 		// Extract the token from the Authorization header
 		authHeader := context.GetHeader("Authorization")
 		// Check if the Authorization header is present
 		if authHeader == "" {
-			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "authorization header is required",
-			})
+			apperr.Abort(context, apperr.Unauthorized("missing_token", "authorization header is required"))
 			return
 		}
 
 		// The header should be in the format "Bearer <token>"
 		headerSplit := strings.Split(authHeader, " ")
 		if len(headerSplit) != 2 || strings.ToLower(headerSplit[0]) != "bearer" {
-			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "invalid authorization header format, received: " + authHeader,
-			})
+			apperr.Abort(context, apperr.Unauthorized("invalid_token", "invalid authorization header format, expected: Bearer <token>"))
+			logger.Warn("rejected malformed authorization header")
 			return
 		}
 
@@ -47,26 +54,21 @@ func AuthMiddleware() gin.HandlerFunc {
 			}
 
 			// Return the secret key used to sign the token
-			// Reminder: secretJWTKey was defined in the auth package (auth/service.go)
-			return secretJWTKey, nil
+			return jwtKey, nil
 		})
 
 		// Check if token is valid
 		if err != nil {
-			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "invalid token",
-			})
+			apperr.Abort(context, apperr.Unauthorized("invalid_token", "invalid token"))
 
-			fmt.Println("Error parsing token:", err)
+			logger.Warn("rejected invalid token", "error", err)
 			return
 		}
 
 		// Check if the token is expired
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 			if int64(claims["exp"].(float64)) < time.Now().Unix() {
-				context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-					"error": "token has expired",
-				})
+				apperr.Abort(context, apperr.Unauthorized("token_expired", "token has expired"))
 				return
 			}
 
@@ -84,14 +86,28 @@ func AuthMiddleware() gin.HandlerFunc {
 				return
 			}
 
+			// A token stays valid after its user is deactivated, e.g. by the directory sync, so check the user is still active.
+			active, err := userRepo.IsActive(context.Request.Context(), context.GetInt64("user_id"))
+			if err != nil {
+				apperr.Abort(context, err)
+				return
+			}
+			if !active {
+				apperr.Abort(context, apperr.Unauthorized("user_inactive", "this account has been deactivated"))
+
+				logger.Warn("rejected token of inactive user", "user_id", context.GetInt64("user_id"))
+				return
+			}
+
+			// Every log line for the rest of the request carries the authenticated user.
+			logging.With(context, "user_id", context.GetInt64("user_id"))
+
 			// Continue to next handler
 			context.Next()
 		} else {
-			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "invalid token claims",
-			})
+			apperr.Abort(context, apperr.Unauthorized("invalid_token", "invalid token claims"))
 
-			fmt.Println("Invalid token claims:", claims)
+			logger.Warn("rejected token with invalid claims")
 			return
 		}
 	}
@@ -101,9 +117,8 @@ func AuthMiddleware() gin.HandlerFunc {
 func extractAndSetClaims(claims jwt.MapClaims, key string, context *gin.Context) error {
 	value, ok := claims[key]
 	if !ok {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"error": fmt.Sprintf("invalid %s in token", key),
-		})
+		apperr.Abort(context, apperr.Unauthorized("invalid_token", fmt.Sprintf("invalid %s in token", key)))
+		logging.FromGin(context, slog.Default()).Warn("rejected token with missing claim", "claim", key)
 		return fmt.Errorf("missing %s in token claims", key)
 	}
 
@@ -115,9 +130,7 @@ func extractAndSetClaims(claims jwt.MapClaims, key string, context *gin.Context)
 		case int:
 			context.Set(key, int64(v))
 		default:
-			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": fmt.Sprintf("invalid %s type in token", key),
-			})
+			apperr.Abort(context, apperr.Unauthorized("invalid_token", fmt.Sprintf("invalid %s type in token", key)))
 			return fmt.Errorf("unexpected %s type: %T", key, value)
 		}
 	} else {
@@ -0,0 +1,219 @@
+// == Talks to the OpenID Connect provider for single sign-on (authorization-code flow with PKCE) ==
+// == The provider only proves who the user is; SOSMIT then issues its own JWT like a password login ==
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Errors returned by the single sign-on flow.
+var (
+	ErrSSODisabled     = apperr.NotFound("sso_disabled", "single sign-on is not configured")
+	ErrSSOFailed       = apperr.Unauthorized("sso_failed", "single sign-on failed, please try again")
+	ErrSSOInvalidState = apperr.Unauthorized("sso_invalid_state", "the single sign-on request expired or was not started here, please try again")
+	ErrSSOUserNotFound = apperr.Unauthorized("sso_user_not_found", "no SOSMIT account is linked to this identity")
+	ErrSSOAmbiguous    = apperr.Conflict("sso_user_ambiguous", "several SOSMIT accounts share this email, ask L1 support to link your account")
+	ErrSSONoAccess     = apperr.Forbidden("no_access", "user does not have access to the system")
+	ErrSSODeactivated  = apperr.Forbidden("account_deactivated", "this SOSMIT account has been deactivated")
+)
+
+// Identity is a user as verified by the provider's ID token.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string // preferred_username
+	FirstName     string
+	LastName      string
+	Position      string // From the configured position claim, used when provisioning
+	OuCode        string // From the configured OU code claim, used when provisioning
+}
+
+// Flow holds the secrets of one login attempt between the redirect to the provider and the callback.
+// It travels in a short-lived HttpOnly cookie, so the callback can only complete a flow started by the same browser.
+type Flow struct {
+	State    string
+	Nonce    string
+	Verifier string // PKCE code verifier
+}
+
+// Encode serializes the flow for the cookie.
+func (flow *Flow) Encode() string {
+	return flow.State + "." + flow.Nonce + "." + flow.Verifier
+}
+
+// MatchesState reports whether the state returned by the provider belongs to this flow.
+func (flow *Flow) MatchesState(state string) bool {
+	return state != "" && subtle.ConstantTimeCompare([]byte(flow.State), []byte(state)) == 1
+}
+
+// DecodeFlow parses a flow cookie, reporting false when it is missing or malformed.
+func DecodeFlow(value string) (*Flow, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, false
+	}
+	return &Flow{State: parts[0], Nonce: parts[1], Verifier: parts[2]}, true
+}
+
+// OIDC is the client of the OpenID Connect provider.
+type OIDC struct {
+	config config.OIDCConfig
+	app    config.AppConfig
+	logger *slog.Logger
+
+	mutex    sync.Mutex
+	verifier *oidc.IDTokenVerifier
+	oauth    oauth2.Config
+}
+
+// NewOIDC creates the OIDC client. The provider is contacted on the first login, so the API starts even while it is unreachable.
+func NewOIDC(oidcConfig config.OIDCConfig, app config.AppConfig, logger *slog.Logger) *OIDC {
+	return &OIDC{
+		config: oidcConfig,
+		app:    app,
+		logger: logger,
+	}
+}
+
+// Enabled reports whether single sign-on is configured.
+func (client *OIDC) Enabled() bool {
+	return client.config.Enabled()
+}
+
+// Start begins a login: it returns the provider's login page URL and the flow to keep until the callback.
+func (client *OIDC) Start(ctx context.Context) (string, *Flow, error) {
+	oauth, _, err := client.discover(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	flow := &Flow{State: randomToken(), Nonce: randomToken(), Verifier: oauth2.GenerateVerifier()}
+	authURL := oauth.AuthCodeURL(flow.State, oidc.Nonce(flow.Nonce), oauth2.S256ChallengeOption(flow.Verifier))
+	return authURL, flow, nil
+}
+
+// Exchange trades the authorization code of the callback for tokens and returns the identity of the verified ID token.
+func (client *OIDC) Exchange(ctx context.Context, flow *Flow, code string) (*Identity, error) {
+	oauth, verifier, err := client.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, ErrSSOFailed.Wrap(fmt.Errorf("exchange authorization code: %w", err))
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrSSOFailed.Wrap(fmt.Errorf("token response has no id_token"))
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, ErrSSOFailed.Wrap(fmt.Errorf("verify id token: %w", err))
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(flow.Nonce)) != 1 {
+		return nil, ErrSSOFailed.Wrap(fmt.Errorf("id token nonce does not match"))
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, ErrSSOFailed.Wrap(fmt.Errorf("decode id token claims: %w", err))
+	}
+
+	return &Identity{
+		Subject:       idToken.Subject,
+		Email:         stringClaim(claims, "email"),
+		EmailVerified: boolClaim(claims, "email_verified"),
+		Username:      stringClaim(claims, "preferred_username"),
+		FirstName:     stringClaim(claims, "given_name"),
+		LastName:      stringClaim(claims, "family_name"),
+		Position:      stringClaim(claims, client.config.PositionClaim),
+		OuCode:        stringClaim(claims, client.config.OuCodeClaim),
+	}, nil
+}
+
+// CallbackURL is the frontend page the browser is sent to after the callback, carrying either the token or the error code.
+// The token goes in the fragment so it never reaches server logs or Referer headers.
+func (client *OIDC) CallbackURL(token, errorCode string) string {
+	link := client.app.FrontendLink(client.config.FrontendCallbackPath)
+	if errorCode != "" {
+		return link + "?sso_error=" + url.QueryEscape(errorCode)
+	}
+	return link + "#token=" + url.QueryEscape(token)
+}
+
+// SecureCookie reports whether the flow cookie must only travel over HTTPS.
+func (client *OIDC) SecureCookie() bool {
+	return strings.HasPrefix(client.config.RedirectURL, "https://")
+}
+
+// discover fetches the provider metadata and signing keys location once and caches them.
+// A failed discovery is retried on the next login.
+func (client *OIDC) discover(ctx context.Context) (oauth2.Config, *oidc.IDTokenVerifier, error) {
+	if !client.Enabled() {
+		return oauth2.Config{}, nil, ErrSSODisabled
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.verifier != nil {
+		return client.oauth, client.verifier, nil
+	}
+
+	// The provider keeps using this context to refresh its signing keys, so it must outlive the request.
+	provider, err := oidc.NewProvider(context.WithoutCancel(ctx), client.config.IssuerURL)
+	if err != nil {
+		client.logger.Error("failed to discover oidc provider", "issuer_url", client.config.IssuerURL, "error", err)
+		return oauth2.Config{}, nil, apperr.Internal(fmt.Errorf("discover oidc provider: %w", err))
+	}
+
+	client.verifier = provider.Verifier(&oidc.Config{ClientID: client.config.ClientID})
+	client.oauth = oauth2.Config{
+		ClientID:     client.config.ClientID,
+		ClientSecret: client.config.ClientSecret,
+		RedirectURL:  client.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       client.config.Scopes,
+	}
+	client.logger.Info("discovered oidc provider", "issuer_url", client.config.IssuerURL)
+	return client.oauth, client.verifier, nil
+}
+
+// randomToken returns 32 random bytes, URL-safe encoded.
+func randomToken() string {
+	buffer := make([]byte, 32)
+	_, _ = rand.Read(buffer) // crypto/rand never fails on supported platforms
+	return base64.RawURLEncoding.EncodeToString(buffer)
+}
+
+// stringClaim reads a string claim, empty when it is missing or not a string.
+func stringClaim(claims map[string]any, name string) string {
+	value, _ := claims[name].(string)
+	return strings.TrimSpace(value)
+}
+
+// boolClaim reads a boolean claim. Some providers send booleans as strings.
+func boolClaim(claims map[string]any, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return strings.EqualFold(value, "true")
+	}
+	return false
+}
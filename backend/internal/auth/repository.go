@@ -0,0 +1,121 @@
+// == Handles all database operations related to login attempts and account lockouts ==
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+)
+
+// ThrottleState is what the login throttle knows about a username and client IP.
+type ThrottleState struct {
+	AttemptID         int64        // Attempt claimed by StartAttempt, finished with FinishAttempt
+	UsernameFailures  int          // Failed and pending password checks since the last success or unlock, within the window
+	LastFailureAt     sql.NullTime // Most recent of those failures
+	IPFailures        int          // Failed and pending password checks from the IP within the window, any username
+	OldestIPFailureAt sql.NullTime // Oldest of those failures, the IP is allowed again once it leaves the window
+	LockedUntil       sql.NullTime // Set while the username is locked
+}
+
+type Repository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewRepository creates a new login attempt repository.
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// RecordAttempt stores one login attempt. reason is empty for successful attempts.
+func (repo *Repository) RecordAttempt(ctx context.Context, username, ipAddress string, succeeded bool, reason string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT record_login_attempt($1, $2, $3, $4)`
+
+	_, err := repo.db.ExecContext(ctx, query, username, ipAddress, succeeded, reason)
+	if err != nil {
+		logger.Error("failed to record login attempt", "username", username, "ip_address", ipAddress, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	return nil
+}
+
+// StartAttempt claims a login attempt of a username from a client IP and returns the failure counts and lock from before it,
+// looking back window. Concurrent calls for the same username or IP are serialised, and the claimed attempt counts as
+// a failure for them until FinishAttempt records its outcome.
+func (repo *Repository) StartAttempt(ctx context.Context, username, ipAddress string, window time.Duration) (*ThrottleState, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM start_login_attempt($1, $2, $3)`
+
+	var state ThrottleState
+	err := repo.db.QueryRowContext(ctx, query, username, ipAddress, int(window.Seconds())).Scan(
+		&state.AttemptID,
+		&state.UsernameFailures,
+		&state.LastFailureAt,
+		&state.IPFailures,
+		&state.OldestIPFailureAt,
+		&state.LockedUntil,
+	)
+	if err != nil {
+		logger.Error("failed to start login attempt", "username", username, "ip_address", ipAddress, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return &state, nil
+}
+
+// FinishAttempt records the outcome of an attempt claimed by StartAttempt. reason is empty for successful attempts.
+func (repo *Repository) FinishAttempt(ctx context.Context, attemptID int64, succeeded bool, reason string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT finish_login_attempt($1, $2, $3)`
+
+	_, err := repo.db.ExecContext(ctx, query, attemptID, succeeded, reason)
+	if err != nil {
+		logger.Error("failed to finish login attempt", "attempt_id", attemptID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	return nil
+}
+
+// LockAccount locks a username until the given time.
+func (repo *Repository) LockAccount(ctx context.Context, username string, lockedUntil time.Time) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT lock_account($1, $2)`
+
+	_, err := repo.db.ExecContext(ctx, query, username, lockedUntil)
+	if err != nil {
+		logger.Error("failed to lock account", "username", username, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	return nil
+}
+
+// UnlockAccount lifts the lock of a username and resets its failure count. It reports whether the username was locked.
+func (repo *Repository) UnlockAccount(ctx context.Context, username string, unlockedBy int64) (bool, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT unlock_account($1, $2)`
+
+	var wasLocked bool
+	err := repo.db.QueryRowContext(ctx, query, username, unlockedBy).Scan(&wasLocked)
+	if err != nil {
+		logger.Error("failed to unlock account", "username", username, "error", err)
+		return false, apperr.FromPostgres(err)
+	}
+
+	return wasLocked, nil
+}
@@ -2,79 +2,282 @@
 package auth
 
 import (
-	"errors"
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/user"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// A secret key used to sign JWT tokens.
-// In this project, we use a hardcoded secret key for simplicity.
-var secretJWTKey = []byte("sosmit_secret_key")
+// Errors returned by the authentication service.
+// A wrong password, an unknown username and a position without access all return ErrInvalidCredentials,
+// so the response does not reveal which usernames exist.
+var (
+	ErrInvalidCredentials = apperr.Unauthorized("invalid_credentials", "invalid username or password")
+	ErrUnlockNotAllowed   = apperr.Forbidden("unlock_not_allowed", "only an admin can unlock accounts")
+	ErrInvalidUsername    = apperr.Validation("invalid_username", "username is required")
+)
 
 // Service struct represents the authentication service.
-// It holds the secret key used for signing JWT tokens.
+// It holds the secret key used for signing JWT tokens, from auth.jwt_secret.
 type Service struct {
-	userRepo *user.Repository
+	jwtKey     []byte // auth.jwt_secret, also verified by AuthMiddleware
+	userRepo   *user.Repository
+	throttle   *Throttle
+	oidc       *OIDC
+	oidcConfig config.OIDCConfig
+	logger     *slog.Logger
 }
 
 // NewService creates a new instance of the authentication service.
-func NewService(userRepo *user.Repository) *Service {
+func NewService(userRepo *user.Repository, repo *Repository, authConfig config.AuthConfig, oidcConfig config.OIDCConfig, app config.AppConfig, logger *slog.Logger) *Service {
 	return &Service{
-		userRepo: userRepo,
+		jwtKey:     authConfig.JWTKey(),
+		userRepo:   userRepo,
+		throttle:   NewThrottle(repo, authConfig, logger),
+		oidc:       NewOIDC(oidcConfig, app, logger),
+		oidcConfig: oidcConfig,
+		logger:     logger,
 	}
 }
 
 // Login validates the user's credentials and returns a JWT token if successful.
-func (service *Service) Login(username, password string) (string, error) {
+// ipAddress is the client's address, used to throttle password guessing across usernames.
+func (service *Service) Login(ctx context.Context, username, password, ipAddress string) (string, error) {
+	// Refuse the attempt before looking at the credentials while the username or client is throttled.
+	state, err := service.throttle.Check(ctx, username, ipAddress)
+	if err != nil {
+		return "", err
+	}
+
 	// Block system placeholder accounts explicitly (case-insensitive)
 	if strings.EqualFold(username, "vacant") {
-		return "", errors.New("invalid username or password")
+		user.RejectPassword(password)
+		service.throttle.Failed(ctx, state, username, ipAddress)
+		return "", ErrInvalidCredentials
 	}
 
 	// Fetch user credentials from the repository.
-	userCredentials, err := service.userRepo.GetUserCredentials(username)
+	userCredentials, err := service.userRepo.GetUserCredentials(ctx, username)
 	if err != nil {
 		// Database error occurred while fetching user credentials.
+		service.throttle.Denied(ctx, state, username, ipAddress, reasonError, nil)
 		return "", err
 	}
-	if userCredentials == nil || userCredentials.Password != password {
-		// No user found with the provided username or password doesn't match
-		return "", errors.New("invalid username or password")
-	}
-
-	// Check if the user has access to the system.
-	// Only positions listed below have access.
-	// ! Note: This is a temporary measure and should be replaced with a more robust access control system, e.g. storing user roles in the database.
-	allowedPositions := []string{
-		"ADMIN STAFF GENERAL AFFAIRS",
-		"L1 SUPPORT",
-		"AREA MANAGER",
-		"IT SERVICES MANAGER",
-		"FINANCE & ACCOUNTING MANAGER",
-	}
-	hasAccess := false
-	for _, p := range allowedPositions {
-		if strings.EqualFold(strings.ToUpper(userCredentials.Position), strings.ToUpper(p)) {
-			hasAccess = true
-			break
-		}
+	if userCredentials == nil {
+		// No user found with the provided username. The password is still checked against a dummy hash,
+		// so an unknown username takes as long to refuse as a wrong password.
+		user.RejectPassword(password)
+		service.throttle.Failed(ctx, state, username, ipAddress)
+		return "", ErrInvalidCredentials
 	}
-	if !hasAccess {
+	if !user.CheckPassword(userCredentials.Password, password) {
+		// The password doesn't match.
+		// Users without a password (provisioned through SSO) can only sign in through SSO.
+		service.throttle.Failed(ctx, state, username, ipAddress)
+		return "", ErrInvalidCredentials
+	}
+
+	// Check if the user is still employed and has access to the system.
+	// Both are answered like a wrong password so the response does not confirm the username exists.
+	actor := &audit.Actor{UserID: userCredentials.UserID, Username: userCredentials.Username, Position: userCredentials.Position}
+	if !userCredentials.IsActive {
+		service.throttle.Denied(ctx, state, username, ipAddress, reasonDeactivated, actor)
+		return "", ErrInvalidCredentials
+	}
+	if !roles.CanLogin(userCredentials.Position) {
 		// User does not have the required position to access the system.
-		return "", errors.New("user does not have access to the system")
+		service.throttle.Denied(ctx, state, username, ipAddress, reasonNoAccess, actor)
+		return "", ErrInvalidCredentials
+	}
+
+	// A seeded plain-text password is replaced by its bcrypt hash on the first successful login.
+	// The login goes on if that fails, the password is simply upgraded on a later login.
+	if !user.IsHashed(userCredentials.Password) {
+		service.upgradePassword(ctx, userCredentials.UserID, password)
+	}
+
+	signedToken, err := service.issueToken(userCredentials)
+	if err != nil {
+		service.throttle.Denied(ctx, state, username, ipAddress, reasonError, actor)
+		return "", err
+	}
+	service.throttle.Succeeded(ctx, state, username, ipAddress, methodPassword, actor)
+
+	return signedToken, nil
+}
+
+// upgradePassword stores the bcrypt hash of a user's plain-text password.
+func (service *Service) upgradePassword(ctx context.Context, userID int64, password string) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	hash, err := user.HashPassword(password)
+	if err == nil {
+		err = service.userRepo.SetPassword(ctx, userID, hash)
+	}
+	if err != nil {
+		logger.Warn("failed to hash plain-text password", "user_id", userID, "error", err)
+		return
+	}
+	logger.Info("hashed plain-text password", "user_id", userID)
+}
+
+// SSOEnabled reports whether single sign-on is configured.
+func (service *Service) SSOEnabled() bool {
+	return service.oidc.Enabled()
+}
+
+// StartOIDCLogin returns the provider's login page URL and the flow the callback has to present.
+func (service *Service) StartOIDCLogin(ctx context.Context) (string, *Flow, error) {
+	return service.oidc.Start(ctx)
+}
+
+// OIDCCallbackURL is where the browser is sent after the callback, see OIDC.CallbackURL.
+func (service *Service) OIDCCallbackURL(token, errorCode string) string {
+	return service.oidc.CallbackURL(token, errorCode)
+}
+
+// SecureOIDCCookie reports whether the flow cookie must only travel over HTTPS.
+func (service *Service) SecureOIDCCookie() bool {
+	return service.oidc.SecureCookie()
+}
+
+// LoginWithOIDC completes a single sign-on: it verifies the provider's answer, maps the identity to a user
+// (by linked subject, then by verified email, then by provisioning one if enabled) and returns the same JWT as Login.
+func (service *Service) LoginWithOIDC(ctx context.Context, flow *Flow, code, ipAddress string) (string, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	identity, err := service.oidc.Exchange(ctx, flow, code)
+	if err != nil {
+		logger.Warn("oidc login failed", "error", err)
+		service.throttle.Denied(ctx, nil, "", ipAddress, reasonSSOFailed, nil)
+		return "", err
+	}
+
+	credentials, err := service.resolveIdentity(ctx, identity)
+	if err != nil {
+		if apperr.IsKind(err, apperr.KindUnauthorized) || apperr.IsKind(err, apperr.KindConflict) {
+			service.throttle.Denied(ctx, nil, cmp.Or(identity.Username, identity.Email), ipAddress, reasonSSOUnmatched, nil)
+		}
+		return "", err
 	}
 
+	actor := &audit.Actor{UserID: credentials.UserID, Username: credentials.Username, Position: credentials.Position}
+	if !credentials.IsActive {
+		service.throttle.Denied(ctx, nil, credentials.Username, ipAddress, reasonDeactivated, actor)
+		return "", ErrSSODeactivated
+	}
+	if !roles.CanLogin(credentials.Position) {
+		service.throttle.Denied(ctx, nil, credentials.Username, ipAddress, reasonNoAccess, actor)
+		return "", ErrSSONoAccess
+	}
+
+	signedToken, err := service.issueToken(credentials)
+	if err != nil {
+		return "", err
+	}
+	service.throttle.Succeeded(ctx, nil, credentials.Username, ipAddress, methodOIDC, actor)
+
+	return signedToken, nil
+}
+
+// resolveIdentity finds the user of an identity, linking or provisioning it on its first login.
+func (service *Service) resolveIdentity(ctx context.Context, identity *Identity) (*user.Credentials, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	// An unverified email could be claimed by anyone at the provider, so it is never used to link accounts.
+	email := ""
+	if service.oidcConfig.LinkByEmail && identity.EmailVerified {
+		email = identity.Email
+	}
+
+	matches, err := service.userRepo.GetCredentialsByOIDC(ctx, identity.Subject, email)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(matches) > 1:
+		logger.Warn("oidc identity matches several users by email", "subject", identity.Subject, "email", email, "count", len(matches))
+		return nil, ErrSSOAmbiguous
+	case len(matches) == 1:
+		credentials := matches[0]
+		if credentials.OIDCSubject == "" {
+			if err := service.userRepo.LinkOIDCSubject(ctx, credentials.UserID, identity.Subject); err != nil {
+				return nil, err
+			}
+			audit.Record(ctx, audit.Event{
+				Action:     "auth.sso_link",
+				EntityType: "user",
+				EntityID:   credentials.Username,
+				After:      map[string]any{"oidc_subject": identity.Subject, "email": email},
+				Actor:      &audit.Actor{UserID: credentials.UserID, Username: credentials.Username, Position: credentials.Position},
+			})
+		}
+		return credentials, nil
+	case !service.oidcConfig.AutoProvision:
+		logger.Warn("oidc identity matches no user", "subject", identity.Subject, "username", identity.Username)
+		return nil, ErrSSOUserNotFound
+	}
+
+	return service.provision(ctx, identity)
+}
+
+// provision creates the user of an identity that matches nobody.
+func (service *Service) provision(ctx context.Context, identity *Identity) (*user.Credentials, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	username := identity.Username
+	if username == "" {
+		username, _, _ = strings.Cut(identity.Email, "@")
+	}
+	if username == "" {
+		logger.Warn("oidc identity has neither a username nor an email to provision", "subject", identity.Subject)
+		return nil, ErrSSOUserNotFound
+	}
+
+	newUser := user.User{
+		Username:  username,
+		Email:     identity.Email,
+		FirstName: identity.FirstName,
+		LastName:  identity.LastName,
+		Position:  identity.Position,
+	}
+	userID, err := service.userRepo.ProvisionOIDCUser(ctx, identity.Subject, newUser, identity.OuCode)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := &user.Credentials{UserID: userID, Username: username, Position: identity.Position, OuCode: identity.OuCode, OIDCSubject: identity.Subject, IsActive: true}
+	audit.Record(ctx, audit.Event{
+		Action:     "auth.sso_provision",
+		EntityType: "user",
+		EntityID:   username,
+		After:      map[string]any{"user_id": userID, "email": identity.Email, "position": identity.Position, "ou_code": identity.OuCode, "oidc_subject": identity.Subject},
+		Actor:      &audit.Actor{UserID: userID, Username: username, Position: identity.Position},
+	})
+	return credentials, nil
+}
+
+// issueToken signs the SOSMIT JWT of a user, the same for password and SSO logins.
+func (service *Service) issueToken(credentials *user.Credentials) (string, error) {
 	// Generate a JWT token for the user.
 	// Create the claims for the token.
 	// Claims are the data that will be encoded in the JWT token.
 	claims := jwt.MapClaims{
-		"user_id":  userCredentials.UserID,
-		"username": userCredentials.Username,
-		"position": userCredentials.Position,
-		"ou_code":  userCredentials.OuCode,
+		"user_id":  credentials.UserID,
+		"username": credentials.Username,
+		"position": credentials.Position,
+		"ou_code":  credentials.OuCode,
 		"exp":      time.Now().Add(time.Hour * 720).Unix(), // Token expires in 720 hours (only for educational purposes, unrelated to SM's policies)
 	}
 
@@ -82,11 +285,39 @@ func (service *Service) Login(username, password string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// Sign the token with the secret key.
-	signedToken, err := token.SignedString(secretJWTKey)
+	signedToken, err := token.SignedString(service.jwtKey)
 	if err != nil {
 		// Error while signing the token.
-		return "", errors.New("error signing the token")
+		return "", apperr.Internal(fmt.Errorf("sign token: %w", err))
 	}
-
 	return signedToken, nil
 }
+
+// UnlockAccount lifts the lockout of a username and resets its failed login count. Only L1 support may do this.
+func (service *Service) UnlockAccount(ctx context.Context, adminID int64, adminPosition, username string) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !roles.IsAdmin(adminPosition) {
+		logger.Warn("account unlock denied", "user_id", adminID, "position", adminPosition, "username", username)
+		return ErrUnlockNotAllowed
+	}
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return ErrInvalidUsername
+	}
+
+	wasLocked, err := service.throttle.Unlock(ctx, username, adminID)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("account unlocked", "username", username, "was_locked", wasLocked, "unlocked_by", adminID)
+	audit.Record(ctx, audit.Event{
+		Action:     "auth.account_unlock",
+		EntityType: "user",
+		EntityID:   username,
+		Before:     map[string]any{"locked": wasLocked},
+		After:      map[string]any{"locked": false},
+	})
+	return nil
+}
@@ -0,0 +1,182 @@
+// == Protects the login endpoint against password guessing ==
+// == Failures are counted per username and per client IP; every attempt is stored and written to the audit log ==
+package auth
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/metrics"
+)
+
+// ErrTooManyAttempts is returned while a username is locked or a client has to wait before trying again.
+// It does not say which, nor whether the username exists, so it cannot be used to enumerate accounts.
+var ErrTooManyAttempts = apperr.RateLimited("too_many_login_attempts", "too many failed login attempts, please try again later")
+
+// Reasons stored with refused login attempts.
+const (
+	reasonInvalidCredentials = "invalid_credentials"
+	reasonNoAccess           = "no_access"
+	reasonDeactivated        = "deactivated"
+	reasonThrottled          = "throttled"
+	reasonLocked             = "locked"
+	reasonSSOFailed          = "sso_failed"
+	reasonSSOUnmatched       = "sso_user_not_found"
+	reasonError              = "error"
+)
+
+// Login methods recorded with successful attempts.
+const (
+	methodPassword = "password"
+	methodOIDC     = "oidc"
+)
+
+// Throttle holds the brute-force rules of the login endpoint:
+//   - after a failed password check the username has to wait BaseDelay, doubled after every further failure up to MaxDelay,
+//   - after MaxFailedAttempts failures the username is locked for LockoutDuration, or until an admin unlocks it,
+//   - a client IP with MaxFailedAttemptsIP failures within AttemptWindow is refused for any username.
+//
+// Usernames are throttled whether they exist or not, so the responses do not reveal which accounts exist.
+type Throttle struct {
+	repo   *Repository
+	config config.AuthConfig
+	logger *slog.Logger
+}
+
+// NewThrottle creates the login throttle.
+func NewThrottle(repo *Repository, authConfig config.AuthConfig, logger *slog.Logger) *Throttle {
+	return &Throttle{
+		repo:   repo,
+		config: authConfig,
+		logger: logger,
+	}
+}
+
+// Check claims the attempt and refuses it while the username is locked or reached MaxFailedAttempts, the IP has too many
+// failures, or the progressive delay since the username's last failure has not passed yet. The returned state has to be
+// passed to Succeeded, Denied or Failed to record the outcome of the claimed attempt.
+func (throttle *Throttle) Check(ctx context.Context, username, ipAddress string) (*ThrottleState, error) {
+	state, err := throttle.repo.StartAttempt(ctx, username, ipAddress, throttle.config.AttemptWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if state.LockedUntil.Valid && state.LockedUntil.Time.After(now) {
+		return nil, throttle.refuse(ctx, state, username, ipAddress, reasonLocked, state.LockedUntil.Time.Sub(now))
+	}
+	if state.UsernameFailures >= throttle.config.MaxFailedAttempts {
+		// Attempts still in progress count as failures: the one that fails last locks the username.
+		return nil, throttle.refuse(ctx, state, username, ipAddress, reasonLocked, throttle.config.LockoutDuration)
+	}
+	if state.IPFailures >= throttle.config.MaxFailedAttemptsIP {
+		wait := throttle.config.AttemptWindow
+		if state.OldestIPFailureAt.Valid {
+			wait = state.OldestIPFailureAt.Time.Add(throttle.config.AttemptWindow).Sub(now)
+		}
+		return nil, throttle.refuse(ctx, state, username, ipAddress, reasonThrottled, wait)
+	}
+	if state.UsernameFailures > 0 && state.LastFailureAt.Valid {
+		if wait := state.LastFailureAt.Time.Add(throttle.delay(state.UsernameFailures)).Sub(now); wait > 0 {
+			return nil, throttle.refuse(ctx, state, username, ipAddress, reasonThrottled, wait)
+		}
+	}
+	return state, nil
+}
+
+// Succeeded records a successful login of actor through method.
+// state is the one returned by Check for this attempt, nil for logins that were not checked such as OIDC.
+func (throttle *Throttle) Succeeded(ctx context.Context, state *ThrottleState, username, ipAddress, method string, actor *audit.Actor) {
+	throttle.record(ctx, state, username, ipAddress, "", map[string]any{"method": method}, actor)
+}
+
+// Denied records a login refused for another reason than a wrong password, e.g. a position without access.
+// It does not count towards a lockout since no password was guessed. state is as for Succeeded.
+func (throttle *Throttle) Denied(ctx context.Context, state *ThrottleState, username, ipAddress, reason string, actor *audit.Actor) {
+	throttle.record(ctx, state, username, ipAddress, reason, nil, actor)
+}
+
+// Failed records a failed password check and locks the username once it reached MaxFailedAttempts.
+// state is the one returned by Check for this attempt.
+func (throttle *Throttle) Failed(ctx context.Context, state *ThrottleState, username, ipAddress string) {
+	logger := logging.FromContext(ctx, throttle.logger)
+
+	throttle.record(ctx, state, username, ipAddress, reasonInvalidCredentials, nil, nil)
+
+	failures := state.UsernameFailures + 1
+	if failures < throttle.config.MaxFailedAttempts {
+		return
+	}
+
+	lockedUntil := time.Now().Add(throttle.config.LockoutDuration)
+	if err := throttle.repo.LockAccount(ctx, username, lockedUntil); err != nil {
+		logger.Error("failed to lock account", "username", username, "error", err)
+		return
+	}
+	logger.Warn("account locked after repeated failed logins", "username", username, "ip_address", ipAddress, "failed_attempts", failures, "locked_until", lockedUntil)
+	audit.Record(ctx, audit.Event{
+		Action:     "auth.account_locked",
+		EntityType: "user",
+		EntityID:   username,
+		After:      map[string]any{"failed_attempts": failures, "locked_until": lockedUntil},
+		Actor:      &audit.Actor{Username: username},
+	})
+}
+
+// Unlock lifts the lock of a username and resets its failure count. It reports whether the username was locked.
+func (throttle *Throttle) Unlock(ctx context.Context, username string, unlockedBy int64) (bool, error) {
+	return throttle.repo.UnlockAccount(ctx, username, unlockedBy)
+}
+
+// refuse records an attempt refused without checking the password and returns the error asking the client to wait.
+func (throttle *Throttle) refuse(ctx context.Context, state *ThrottleState, username, ipAddress, reason string, wait time.Duration) error {
+	logger := logging.FromContext(ctx, throttle.logger)
+
+	logger.Warn("login attempt refused", "username", username, "ip_address", ipAddress, "reason", reason, "retry_after", wait)
+	throttle.record(ctx, state, username, ipAddress, reason, nil, nil)
+	return ErrTooManyAttempts.WithRetryAfter(wait)
+}
+
+// record stores an attempt, an empty reason meaning success, and writes it to the audit log with details.
+// The attempt claimed by Check is finished when state is set, otherwise a new attempt is stored.
+// Failing to store it is logged but does not change the outcome of the login.
+func (throttle *Throttle) record(ctx context.Context, state *ThrottleState, username, ipAddress, reason string, details map[string]any, actor *audit.Actor) {
+	logger := logging.FromContext(ctx, throttle.logger)
+
+	succeeded := reason == ""
+	var err error
+	if state != nil {
+		err = throttle.repo.FinishAttempt(ctx, state.AttemptID, succeeded, reason)
+	} else {
+		err = throttle.repo.RecordAttempt(ctx, username, ipAddress, succeeded, reason)
+	}
+	if err != nil {
+		logger.Error("failed to record login attempt", "username", username, "error", err)
+	}
+	metrics.CountLoginAttempt(cmp.Or(reason, metrics.ResultSuccess))
+
+	event := audit.Event{Action: "auth.login", EntityType: "user", EntityID: username, After: details, Actor: actor}
+	if !succeeded {
+		event.Action = "auth.login_failed"
+		event.After = map[string]any{"reason": reason}
+	}
+	if event.Actor == nil {
+		event.Actor = &audit.Actor{Username: username}
+	}
+	audit.Record(ctx, event)
+}
+
+// delay is how long a username has to wait after its nth consecutive failure.
+func (throttle *Throttle) delay(failures int) time.Duration {
+	delay := throttle.config.BaseDelay
+	for i := 1; i < failures && delay < throttle.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, throttle.config.MaxDelay)
+}
@@ -0,0 +1,793 @@
+// == Loads the application configuration from an optional YAML file and environment variables ==
+// == Precedence: defaults < YAML file < environment. Everything is validated once at startup ==
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Embedded zone database, so the timezone resolves even on images without tzdata.
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the root of the application configuration.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Paths     PathsConfig     `yaml:"paths"`
+	Email     EmailConfig     `yaml:"email"`
+	App       AppConfig       `yaml:"app"`
+	Auth      AuthConfig      `yaml:"auth"`
+	OIDC      OIDCConfig      `yaml:"oidc"`
+	Directory DirectoryConfig `yaml:"directory"`
+	Loans     LoanConfig      `yaml:"loans"`
+	Disposal  DisposalConfig  `yaml:"disposal"`
+	Opname    OpnameConfig    `yaml:"opname"`
+	Log       LogConfig       `yaml:"log"`
+}
+
+// ServerConfig controls the HTTP listener.
+type ServerConfig struct {
+	Port           int      `yaml:"port"`
+	AllowedOrigins []string `yaml:"allowed_origins"` // CORS origins allowed to call the API
+	// RequestTimeout bounds every request, including its DB queries and PDF rendering. 0 disables it.
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// RouteTimeouts overrides RequestTimeout for slow routes, keyed as "METHOD /route/template".
+	RouteTimeouts map[string]time.Duration `yaml:"route_timeouts"`
+	// TrustedProxies lists the proxy IPs or CIDRs whose X-Forwarded-For header is believed. Empty trusts none.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// DatabaseConfig holds the PostgreSQL connection parameters.
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"ssl_mode"`
+	// AutoMigrate applies pending schema migrations when the API starts, instead of running `api migrate up` separately.
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+// PathsConfig points at the directories the app reads from and writes to.
+// Relative paths are resolved against the directory of the YAML file, or the working directory without one.
+type PathsConfig struct {
+	Templates string `yaml:"templates"` // Optional; files here replace the embedded templates with the same name
+	Uploads   string `yaml:"uploads"`
+}
+
+// EmailConfig holds the SendGrid credentials. Email is disabled when either value is empty.
+type EmailConfig struct {
+	SendGridAPIKey string `yaml:"sendgrid_api_key"`
+	SenderEmail    string `yaml:"sender_email"`
+}
+
+// Environments the app can run in, see AppConfig.Environment.
+const (
+	EnvironmentDevelopment = "development"
+	EnvironmentProduction  = "production"
+)
+
+// DevelopmentJWTSecret is the signing key of local setups. It is public, so production refuses to start with it.
+const DevelopmentJWTSecret = "sosmit_secret_key"
+
+// minProductionJWTSecretLength is the shortest signing key accepted in production (256 bits for HS256).
+const minProductionJWTSecretLength = 32
+
+// AppConfig holds application level settings used when building links and timestamps.
+type AppConfig struct {
+	FrontendURL string `yaml:"frontend_url"`
+	Timezone    string `yaml:"timezone"`
+	Environment string `yaml:"environment"` // development or production; production refuses insecure defaults
+
+	location *time.Location
+}
+
+// AuthConfig controls the signing of login tokens and the brute-force protection of the login endpoint.
+// Only failed password checks within AttemptWindow count; a successful login or an admin unlock resets a username's count.
+type AuthConfig struct {
+	JWTSecret           string        `yaml:"jwt_secret"`             // Key signing and verifying the HS256 login tokens
+	AttemptWindow       time.Duration `yaml:"attempt_window"`         // How far back failed attempts are counted
+	MaxFailedAttempts   int           `yaml:"max_failed_attempts"`    // Failures per username before it is locked
+	LockoutDuration     time.Duration `yaml:"lockout_duration"`       // How long a locked username stays locked
+	MaxFailedAttemptsIP int           `yaml:"max_failed_attempts_ip"` // Failures per client IP (any username) before it is refused
+	BaseDelay           time.Duration `yaml:"base_delay"`             // Wait after the first failure, doubled after every further one
+	MaxDelay            time.Duration `yaml:"max_delay"`              // Upper bound of the progressive wait
+	// AdminPositions administer the system: users, locations, cost centers, policies and every asset and session.
+	AdminPositions []string `yaml:"admin_positions"`
+}
+
+// OIDCConfig enables single sign-on through an OpenID Connect provider, alongside the local passwords.
+// SSO is disabled while IssuerURL is empty.
+type OIDCConfig struct {
+	IssuerURL    string   `yaml:"issuer_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"` // This API's callback, registered at the provider: .../api/auth/oidc/callback
+	Scopes       []string `yaml:"scopes"`
+	// LinkByEmail links an identity to the unlinked user with the same (verified) email on its first login.
+	LinkByEmail bool `yaml:"link_by_email"`
+	// AutoProvision creates a user on the first login of an identity that matches nobody.
+	AutoProvision bool   `yaml:"auto_provision"`
+	PositionClaim string `yaml:"position_claim"` // Claim holding the job title of provisioned users
+	OuCodeClaim   string `yaml:"ou_code_claim"`  // Claim holding the OU code of provisioned users
+	// FrontendCallbackPath is the frontend page receiving the token (as #token=...) or the error (as ?sso_error=...).
+	FrontendCallbackPath string `yaml:"frontend_callback_path"`
+}
+
+// DirectoryConfig controls the synchronisation of users from the company directory, see internal/directory.
+// The sync is disabled while Source is empty.
+type DirectoryConfig struct {
+	Source   string `yaml:"source"`   // "csv" for the HR export, "ldap" for the directory server
+	Schedule string `yaml:"schedule"` // Daily run time as HH:MM in app.timezone; empty runs only on demand
+	// CSVPath is the semicolon separated HR export, with the same columns as the seed's user.csv.
+	// A relative path is resolved like the paths section.
+	CSVPath string     `yaml:"csv_path"`
+	LDAP    LDAPConfig `yaml:"ldap"`
+	// MaxDeactivationRatio aborts a sync that would deactivate more than this share of the active users,
+	// which usually means a truncated export rather than a wave of leavers.
+	MaxDeactivationRatio float64 `yaml:"max_deactivation_ratio"`
+}
+
+// LoanConfig controls the reminders sent to the borrowers of overdue asset loans, see internal/loan.
+type LoanConfig struct {
+	ReminderSchedule string `yaml:"reminder_schedule"` // Daily run time as HH:MM in app.timezone; empty sends reminders only on demand
+}
+
+// DisposalConfig controls who approves the write-off of lost and obsolete assets, see internal/disposal.
+type DisposalConfig struct {
+	FinancePositions []string `yaml:"finance_positions"` // User positions allowed to approve or reject disposals, matched case-insensitively
+}
+
+// OpnameConfig controls the loss approval of opname sessions, see internal/opname.
+// Once the last reviewer on its approval path verifies a session whose broken and missing assets are worth more than LossThreshold (net book value),
+// the session also needs the approval of one of LossApproverPositions before it is verified.
+type OpnameConfig struct {
+	LossThreshold         int64    `yaml:"loss_threshold"`          // In rupiah; 0 disables the loss approval
+	LossApproverPositions []string `yaml:"loss_approver_positions"` // User positions allowed to approve or reject losses, matched case-insensitively
+}
+
+// LDAPConfig holds the directory server connection and search used when directory.source is "ldap".
+type LDAPConfig struct {
+	URL          string         `yaml:"url"` // ldap://host:389 or ldaps://host:636
+	BindDN       string         `yaml:"bind_dn"`
+	BindPassword string         `yaml:"bind_password"`
+	BaseDN       string         `yaml:"base_dn"`
+	Filter       string         `yaml:"filter"`    // Selects the employees, e.g. (&(objectClass=person)(employeeNumber=*))
+	StartTLS     bool           `yaml:"start_tls"` // Upgrade an ldap:// connection before binding
+	Attributes   LDAPAttributes `yaml:"attributes"`
+}
+
+// LDAPAttributes names the LDAP attribute holding each user field. An empty name leaves the field blank.
+type LDAPAttributes struct {
+	UserID       string `yaml:"user_id"` // Numeric employee ID, the key matched against "User".user_id
+	Username     string `yaml:"username"`
+	Email        string `yaml:"email"`
+	FirstName    string `yaml:"first_name"`
+	LastName     string `yaml:"last_name"`
+	Position     string `yaml:"position"`
+	Department   string `yaml:"department"`
+	Division     string `yaml:"division"`
+	SiteID       string `yaml:"site_id"`
+	CostCenterID string `yaml:"cost_center_id"`
+	OuCode       string `yaml:"ou_code"`
+}
+
+// LogConfig controls the structured logger.
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// Default returns the configuration used when nothing is overridden. It matches the local docker-compose setup.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:           8080,
+			AllowedOrigins: []string{"http://localhost:4200"},
+			RequestTimeout: 30 * time.Second,
+			RouteTimeouts: map[string]time.Duration{
+				"GET /api/report/:session-id/bap.pdf":     2 * time.Minute,
+				"GET /api/asset/labels.pdf":               2 * time.Minute,
+				"GET /api/transfer/:transfer-id/bast.pdf": 2 * time.Minute,
+				"GET /api/disposal/:disposal-id/bap.pdf":  2 * time.Minute,
+				"POST /api/asset/decode":                  time.Minute,
+			},
+		},
+		Database: DatabaseConfig{
+			Host:     "localhost",
+			Port:     5433,
+			User:     "sosmit_admin",
+			Password: "admin123",
+			Name:     "sosmit_db",
+			SSLMode:  "disable",
+		},
+		Paths: PathsConfig{
+			Uploads: "../uploads",
+		},
+		App: AppConfig{
+			FrontendURL: "http://localhost:4200",
+			Timezone:    "Asia/Jakarta",
+			Environment: EnvironmentDevelopment,
+		},
+		Auth: AuthConfig{
+			JWTSecret:           DevelopmentJWTSecret,
+			AttemptWindow:       15 * time.Minute,
+			MaxFailedAttempts:   5,
+			LockoutDuration:     15 * time.Minute,
+			MaxFailedAttemptsIP: 20,
+			BaseDelay:           time.Second,
+			MaxDelay:            30 * time.Second,
+			AdminPositions:      slices.Clone(roles.DefaultAdminPositions),
+		},
+		OIDC: OIDCConfig{
+			Scopes:               []string{"openid", "profile", "email"},
+			LinkByEmail:          true,
+			PositionClaim:        "position",
+			OuCodeClaim:          "ou_code",
+			FrontendCallbackPath: "/login/sso",
+		},
+		Directory: DirectoryConfig{
+			LDAP: LDAPConfig{
+				Filter: "(&(objectClass=person)(employeeNumber=*))",
+				Attributes: LDAPAttributes{
+					UserID:       "employeeNumber",
+					Username:     "sAMAccountName",
+					Email:        "mail",
+					FirstName:    "givenName",
+					LastName:     "sn",
+					Position:     "title",
+					Department:   "department",
+					Division:     "division",
+					SiteID:       "physicalDeliveryOfficeName",
+					CostCenterID: "departmentNumber",
+					OuCode:       "ou",
+				},
+			},
+			MaxDeactivationRatio: 0.2,
+		},
+		Loans: LoanConfig{
+			ReminderSchedule: "08:00",
+		},
+		Disposal: DisposalConfig{
+			FinancePositions: []string{"FINANCE & ACCOUNTING MANAGER"},
+		},
+		Opname: OpnameConfig{
+			LossApproverPositions: []string{"FINANCE & ACCOUNTING MANAGER"},
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file at path (skipped when path is empty),
+// a .env file in the working directory if present, and the environment, then validates it.
+func Load(path string) (*Config, error) {
+	config := Default()
+	baseDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		defer file.Close()
+
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true) // Typos in the file should fail loudly instead of being ignored
+		if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+
+		absolutePath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve config file path: %w", err)
+		}
+		baseDir = filepath.Dir(absolutePath)
+	}
+
+	// A missing .env is normal (e.g. in Docker), the variables then come from the real environment.
+	_ = godotenv.Load()
+
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	config.Paths.Templates = resolvePath(baseDir, config.Paths.Templates)
+	config.Paths.Uploads = resolvePath(baseDir, config.Paths.Uploads)
+	config.Directory.CSVPath = resolvePath(baseDir, config.Directory.CSVPath)
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyEnv overrides the loaded values with every environment variable that is set and non-empty.
+// Empty values are ignored because docker-compose passes unset ${VARS} through as empty strings.
+func (config *Config) applyEnv() error {
+	var errs []error
+	setString := func(key string, target *string) {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			*target = value
+		}
+	}
+	setInt := func(key string, target *int) {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a number", key, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	setInt64 := func(key string, target *int64) {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a number", key, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	setBool := func(key string, target *bool) {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a boolean", key, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	setDuration := func(key string, target *time.Duration) {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a duration (e.g. 30s, 2m)", key, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	// setDurationMap merges "METHOD /route=duration" pairs into target, keeping the entries not mentioned.
+	setDurationMap := func(key string, target *map[string]time.Duration) {
+		value := strings.TrimSpace(os.Getenv(key))
+		if value == "" {
+			return
+		}
+		if *target == nil {
+			*target = make(map[string]time.Duration)
+		}
+		for _, pair := range splitList(value) {
+			route, rawDuration, found := strings.Cut(pair, "=")
+			parsed, err := time.ParseDuration(strings.TrimSpace(rawDuration))
+			if !found || err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q must look like \"GET /api/route=30s\"", key, pair))
+				continue
+			}
+			(*target)[strings.TrimSpace(route)] = parsed
+		}
+	}
+	setFloat := func(key string, target *float64) {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a number", key, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	setList := func(key string, target *[]string) {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			*target = splitList(value)
+		}
+	}
+
+	setInt("SERVER_PORT", &config.Server.Port)
+	setList("CORS_ALLOWED_ORIGINS", &config.Server.AllowedOrigins)
+	setDuration("REQUEST_TIMEOUT", &config.Server.RequestTimeout)
+	setDurationMap("ROUTE_TIMEOUTS", &config.Server.RouteTimeouts)
+	setList("TRUSTED_PROXIES", &config.Server.TrustedProxies)
+
+	setString("DB_HOST", &config.Database.Host)
+	setInt("DB_PORT", &config.Database.Port)
+	setString("DB_USER", &config.Database.User)
+	setString("DB_PASSWORD", &config.Database.Password)
+	setString("DB_NAME", &config.Database.Name)
+	setString("DB_SSLMODE", &config.Database.SSLMode)
+	setBool("DB_AUTO_MIGRATE", &config.Database.AutoMigrate)
+
+	setString("TEMPLATES_DIR", &config.Paths.Templates)
+	setString("UPLOADS_DIR", &config.Paths.Uploads)
+
+	setString("SENDGRID_API_KEY", &config.Email.SendGridAPIKey)
+	setString("SENDER_EMAIL", &config.Email.SenderEmail)
+
+	setString("FRONTEND_URL", &config.App.FrontendURL)
+	setString("APP_TIMEZONE", &config.App.Timezone)
+	setString("APP_ENV", &config.App.Environment)
+
+	setString("JWT_SECRET", &config.Auth.JWTSecret)
+
+	setDuration("AUTH_ATTEMPT_WINDOW", &config.Auth.AttemptWindow)
+	setInt("AUTH_MAX_FAILED_ATTEMPTS", &config.Auth.MaxFailedAttempts)
+	setDuration("AUTH_LOCKOUT_DURATION", &config.Auth.LockoutDuration)
+	setInt("AUTH_MAX_FAILED_ATTEMPTS_IP", &config.Auth.MaxFailedAttemptsIP)
+	setDuration("AUTH_BASE_DELAY", &config.Auth.BaseDelay)
+	setDuration("AUTH_MAX_DELAY", &config.Auth.MaxDelay)
+	setList("AUTH_ADMIN_POSITIONS", &config.Auth.AdminPositions)
+
+	setString("OIDC_ISSUER_URL", &config.OIDC.IssuerURL)
+	setString("OIDC_CLIENT_ID", &config.OIDC.ClientID)
+	setString("OIDC_CLIENT_SECRET", &config.OIDC.ClientSecret)
+	setString("OIDC_REDIRECT_URL", &config.OIDC.RedirectURL)
+	setList("OIDC_SCOPES", &config.OIDC.Scopes)
+	setBool("OIDC_LINK_BY_EMAIL", &config.OIDC.LinkByEmail)
+	setBool("OIDC_AUTO_PROVISION", &config.OIDC.AutoProvision)
+	setString("OIDC_POSITION_CLAIM", &config.OIDC.PositionClaim)
+	setString("OIDC_OU_CODE_CLAIM", &config.OIDC.OuCodeClaim)
+	setString("OIDC_FRONTEND_CALLBACK_PATH", &config.OIDC.FrontendCallbackPath)
+
+	setString("DIRECTORY_SOURCE", &config.Directory.Source)
+	setString("DIRECTORY_SCHEDULE", &config.Directory.Schedule)
+	setString("DIRECTORY_CSV_PATH", &config.Directory.CSVPath)
+	setFloat("DIRECTORY_MAX_DEACTIVATION_RATIO", &config.Directory.MaxDeactivationRatio)
+	setString("LDAP_URL", &config.Directory.LDAP.URL)
+	setString("LDAP_BIND_DN", &config.Directory.LDAP.BindDN)
+	setString("LDAP_BIND_PASSWORD", &config.Directory.LDAP.BindPassword)
+	setString("LDAP_BASE_DN", &config.Directory.LDAP.BaseDN)
+	setString("LDAP_FILTER", &config.Directory.LDAP.Filter)
+	setBool("LDAP_START_TLS", &config.Directory.LDAP.StartTLS)
+
+	setString("LOAN_REMINDER_SCHEDULE", &config.Loans.ReminderSchedule)
+
+	setList("DISPOSAL_FINANCE_POSITIONS", &config.Disposal.FinancePositions)
+
+	setInt64("OPNAME_LOSS_THRESHOLD", &config.Opname.LossThreshold)
+	setList("OPNAME_LOSS_APPROVER_POSITIONS", &config.Opname.LossApproverPositions)
+
+	setString("LOG_LEVEL", &config.Log.Level)
+	setString("LOG_FORMAT", &config.Log.Format)
+
+	return errors.Join(errs...)
+}
+
+// Validate checks every setting and reports all problems at once, each prefixed with the setting's YAML path.
+func (config *Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if config.Server.Port < 1 || config.Server.Port > 65535 {
+		fail("server.port", "must be between 1 and 65535, got %d", config.Server.Port)
+	}
+	if len(config.Server.AllowedOrigins) == 0 {
+		fail("server.allowed_origins", "at least one origin is required")
+	}
+	for _, origin := range config.Server.AllowedOrigins {
+		if origin != "*" && !isHTTPURL(origin) {
+			fail("server.allowed_origins", "%q is not an http(s) origin", origin)
+		}
+	}
+
+	if config.Server.RequestTimeout < 0 {
+		fail("server.request_timeout", "must not be negative")
+	}
+	for route, limit := range config.Server.RouteTimeouts {
+		method, path, found := strings.Cut(route, " ")
+		if !found || method != strings.ToUpper(method) || !strings.HasPrefix(path, "/") {
+			fail("server.route_timeouts", "key %q must look like \"GET /api/route\"", route)
+		}
+		if limit < 0 {
+			fail("server.route_timeouts", "timeout for %q must not be negative", route)
+		}
+	}
+
+	for _, proxy := range config.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			fail("server.trusted_proxies", "%q is not an IP address or CIDR", proxy)
+		}
+	}
+
+	if config.Database.Host == "" {
+		fail("database.host", "is required")
+	}
+	if config.Database.Port < 1 || config.Database.Port > 65535 {
+		fail("database.port", "must be between 1 and 65535, got %d", config.Database.Port)
+	}
+	if config.Database.User == "" {
+		fail("database.user", "is required")
+	}
+	if config.Database.Name == "" {
+		fail("database.name", "is required")
+	}
+	switch config.Database.SSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		fail("database.ssl_mode", "must be one of disable, require, verify-ca, verify-full, got %q", config.Database.SSLMode)
+	}
+
+	if config.Paths.Templates != "" {
+		if info, err := os.Stat(config.Paths.Templates); err != nil || !info.IsDir() {
+			fail("paths.templates", "%s is not a readable directory", config.Paths.Templates)
+		}
+	}
+	if config.Paths.Uploads == "" {
+		fail("paths.uploads", "is required")
+	}
+
+	if (config.Email.SendGridAPIKey == "") != (config.Email.SenderEmail == "") {
+		fail("email", "sendgrid_api_key and sender_email must be set together")
+	}
+
+	if !isHTTPURL(config.App.FrontendURL) {
+		fail("app.frontend_url", "%q is not an http(s) URL", config.App.FrontendURL)
+	}
+	location, err := time.LoadLocation(config.App.Timezone)
+	if err != nil {
+		fail("app.timezone", "unknown timezone %q", config.App.Timezone)
+	}
+	config.App.location = location
+	switch config.App.Environment {
+	case EnvironmentDevelopment, EnvironmentProduction:
+	default:
+		fail("app.environment", "must be %s or %s, got %q", EnvironmentDevelopment, EnvironmentProduction, config.App.Environment)
+	}
+
+	switch {
+	case config.Auth.JWTSecret == "":
+		fail("auth.jwt_secret", "is required")
+	case config.App.IsProduction() && config.Auth.JWTSecret == DevelopmentJWTSecret:
+		fail("auth.jwt_secret", "must be changed from the development key in production")
+	case config.App.IsProduction() && len(config.Auth.JWTSecret) < minProductionJWTSecretLength:
+		fail("auth.jwt_secret", "must be at least %d characters in production", minProductionJWTSecretLength)
+	}
+	if config.Auth.AttemptWindow <= 0 {
+		fail("auth.attempt_window", "must be positive")
+	}
+	if config.Auth.MaxFailedAttempts < 1 {
+		fail("auth.max_failed_attempts", "must be at least 1, got %d", config.Auth.MaxFailedAttempts)
+	}
+	if config.Auth.LockoutDuration <= 0 {
+		fail("auth.lockout_duration", "must be positive")
+	}
+	if config.Auth.MaxFailedAttemptsIP < 1 {
+		fail("auth.max_failed_attempts_ip", "must be at least 1, got %d", config.Auth.MaxFailedAttemptsIP)
+	}
+	if config.Auth.BaseDelay < 0 || config.Auth.MaxDelay < config.Auth.BaseDelay {
+		fail("auth.base_delay", "must not be negative nor exceed auth.max_delay")
+	}
+	if len(config.Auth.AdminPositions) == 0 {
+		fail("auth.admin_positions", "at least one position is required")
+	}
+	validateLoginPositions("auth.admin_positions", config.Auth.AdminPositions, fail)
+
+	if config.OIDC.Enabled() {
+		if !isHTTPURL(config.OIDC.IssuerURL) {
+			fail("oidc.issuer_url", "%q is not an http(s) URL", config.OIDC.IssuerURL)
+		}
+		if config.OIDC.ClientID == "" {
+			fail("oidc.client_id", "is required when oidc.issuer_url is set")
+		}
+		if !isHTTPURL(config.OIDC.RedirectURL) {
+			fail("oidc.redirect_url", "%q is not an http(s) URL", config.OIDC.RedirectURL)
+		}
+		if !slices.Contains(config.OIDC.Scopes, "openid") {
+			fail("oidc.scopes", "must include openid")
+		}
+		if !strings.HasPrefix(config.OIDC.FrontendCallbackPath, "/") {
+			fail("oidc.frontend_callback_path", "must start with /, got %q", config.OIDC.FrontendCallbackPath)
+		}
+	}
+
+	switch config.Directory.Source {
+	case "":
+	case "csv":
+		if config.Directory.CSVPath == "" {
+			fail("directory.csv_path", "is required when directory.source is csv")
+		}
+	case "ldap":
+		ldapURL, err := url.Parse(config.Directory.LDAP.URL)
+		if err != nil || (ldapURL.Scheme != "ldap" && ldapURL.Scheme != "ldaps") || ldapURL.Host == "" {
+			fail("directory.ldap.url", "%q is not an ldap:// or ldaps:// URL", config.Directory.LDAP.URL)
+		}
+		if config.Directory.LDAP.BaseDN == "" {
+			fail("directory.ldap.base_dn", "is required when directory.source is ldap")
+		}
+		if config.Directory.LDAP.Attributes.UserID == "" || config.Directory.LDAP.Attributes.Username == "" {
+			fail("directory.ldap.attributes", "user_id and username are required")
+		}
+	default:
+		fail("directory.source", "must be empty, csv or ldap, got %q", config.Directory.Source)
+	}
+	if config.Directory.Schedule != "" {
+		if _, err := time.Parse("15:04", config.Directory.Schedule); err != nil {
+			fail("directory.schedule", "must be a time of day as HH:MM, got %q", config.Directory.Schedule)
+		}
+		if config.Directory.Source == "" {
+			fail("directory.schedule", "requires directory.source")
+		}
+	}
+	if config.Directory.MaxDeactivationRatio <= 0 || config.Directory.MaxDeactivationRatio > 1 {
+		fail("directory.max_deactivation_ratio", "must be greater than 0 and at most 1, got %g", config.Directory.MaxDeactivationRatio)
+	}
+	if config.Loans.ReminderSchedule != "" {
+		if _, err := time.Parse("15:04", config.Loans.ReminderSchedule); err != nil {
+			fail("loans.reminder_schedule", "must be a time of day as HH:MM, got %q", config.Loans.ReminderSchedule)
+		}
+	}
+	if len(config.Disposal.FinancePositions) == 0 {
+		fail("disposal.finance_positions", "at least one position is required")
+	}
+	validateLoginPositions("disposal.finance_positions", config.Disposal.FinancePositions, fail)
+	if config.Opname.LossThreshold < 0 {
+		fail("opname.loss_threshold", "must not be negative, got %d", config.Opname.LossThreshold)
+	}
+	if config.Opname.LossThreshold > 0 && len(config.Opname.LossApproverPositions) == 0 {
+		fail("opname.loss_approver_positions", "at least one position is required when opname.loss_threshold is set")
+	}
+	validateLoginPositions("opname.loss_approver_positions", config.Opname.LossApproverPositions, fail)
+
+	switch strings.ToLower(config.Log.Level) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		fail("log.level", "must be one of debug, info, warn, error, got %q", config.Log.Level)
+	}
+	switch strings.ToLower(config.Log.Format) {
+	case "json", "text":
+	default:
+		fail("log.format", "must be json or text, got %q", config.Log.Format)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validateLoginPositions fails for every position that cannot sign in, since nobody holding it could use the role it is given.
+func validateLoginPositions(field string, positions []string, fail func(field, format string, args ...any)) {
+	for _, position := range positions {
+		if !roles.CanLogin(position) {
+			fail(field, "%q cannot sign in, use one of %s", position, strings.Join(roles.LoginPositions, ", "))
+		}
+	}
+}
+
+// DSN returns the lib/pq connection string for the database.
+func (database DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		database.Host, database.Port, database.User, database.Password, database.Name, database.SSLMode)
+}
+
+// Addr returns the listen address of the HTTP server.
+func (server ServerConfig) Addr() string {
+	return ":" + strconv.Itoa(server.Port)
+}
+
+// Enabled reports whether single sign-on is configured.
+func (oidc OIDCConfig) Enabled() bool {
+	return oidc.IssuerURL != ""
+}
+
+// Enabled reports whether a directory source is configured.
+func (directory DirectoryConfig) Enabled() bool {
+	return directory.Source != ""
+}
+
+// NextRun returns the first scheduled sync after now, or the zero time without a schedule.
+func (directory DirectoryConfig) NextRun(now time.Time) time.Time {
+	return nextDailyRun(directory.Schedule, now)
+}
+
+// NextReminder returns the first scheduled overdue loan reminder after now, or the zero time without a schedule.
+func (loans LoanConfig) NextReminder(now time.Time) time.Time {
+	return nextDailyRun(loans.ReminderSchedule, now)
+}
+
+// IsFinance reports whether a user position may approve or reject disposals.
+func (disposal DisposalConfig) IsFinance(position string) bool {
+	return slices.ContainsFunc(disposal.FinancePositions, func(finance string) bool {
+		return strings.EqualFold(strings.TrimSpace(finance), strings.TrimSpace(position))
+	})
+}
+
+// RequiresLossApproval reports whether a session whose broken and missing assets are worth loss needs a loss approval.
+func (opname OpnameConfig) RequiresLossApproval(loss int64) bool {
+	return opname.LossThreshold > 0 && loss > opname.LossThreshold
+}
+
+// IsLossApprover reports whether a user position may approve or reject the loss of an opname session.
+func (opname OpnameConfig) IsLossApprover(position string) bool {
+	return slices.ContainsFunc(opname.LossApproverPositions, func(approver string) bool {
+		return strings.EqualFold(strings.TrimSpace(approver), strings.TrimSpace(position))
+	})
+}
+
+// Enabled reports whether SendGrid credentials are configured.
+func (email EmailConfig) Enabled() bool {
+	return email.SendGridAPIKey != "" && email.SenderEmail != ""
+}
+
+// Location returns the configured timezone, UTC until the config has been validated.
+func (app AppConfig) Location() *time.Location {
+	if app.location == nil {
+		return time.UTC
+	}
+	return app.location
+}
+
+// IsProduction reports whether the app runs in production.
+func (app AppConfig) IsProduction() bool {
+	return app.Environment == EnvironmentProduction
+}
+
+// JWTKey returns the key signing and verifying login tokens.
+func (auth AuthConfig) JWTKey() []byte {
+	return []byte(auth.JWTSecret)
+}
+
+// FrontendLink joins a path onto the frontend base URL.
+func (app AppConfig) FrontendLink(path string) string {
+	return strings.TrimRight(app.FrontendURL, "/") + path
+}
+
+// resolvePath makes a relative path absolute against baseDir, so later working directory changes do not matter.
+func resolvePath(baseDir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// splitList splits a comma separated environment value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func isHTTPURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// nextDailyRun returns the first time of day schedule (HH:MM, in now's location) after now, or the zero time if schedule is empty.
+func nextDailyRun(schedule string, now time.Time) time.Time {
+	at, err := time.Parse("15:04", schedule)
+	if schedule == "" || err != nil {
+		return time.Time{}
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
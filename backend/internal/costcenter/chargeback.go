@@ -0,0 +1,205 @@
+// == Builds the cost center chargeback report and writes it as an XLSX workbook for finance ==
+package costcenter
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// buildChargeback aggregates the assets of verified opname sessions per cost center. Each asset counts once, as recorded
+// by its latest session in the period, and is charged to the cost center of its owner after that session's changes.
+// The owner before those changes is the one the asset had when the session was verified: the owner the first transfer
+// approved after that moved it away from, or its current owner. A session changing the owner to another cost center moves
+// the asset's value out of the old cost center and into the new one. Rows are ordered by cost center, NULL last.
+func buildChargeback(assets []chargebackAsset, transfers []chargebackTransfer) []ChargebackRow {
+	transfersByAsset := make(map[string][]chargebackTransfer)
+	for _, transfer := range transfers {
+		transfersByAsset[transfer.AssetTag] = append(transfersByAsset[transfer.AssetTag], transfer)
+	}
+
+	rows := make(map[sql.NullInt64]*ChargebackRow)
+	rowOf := func(costCenter chargebackCostCenter) *ChargebackRow {
+		row, ok := rows[costCenter.ID]
+		if !ok {
+			row = &ChargebackRow{CostCenterID: costCenter.ID, CostCenterName: costCenter.Name}
+			rows[costCenter.ID] = row
+		}
+		return row
+	}
+
+	for _, asset := range assets {
+		oldCostCenter := costCenterAt(asset, transfersByAsset[asset.AssetTag])
+		newCostCenter := oldCostCenter
+		if asset.NewCostCenter.ID.Valid {
+			newCostCenter = asset.NewCostCenter
+		}
+
+		held := rowOf(newCostCenter)
+		held.AssetCount++
+		held.TotalCost += asset.Cost
+		if asset.IsBroken {
+			held.BrokenCount++
+			held.BrokenCost += asset.Cost
+		}
+		if asset.IsMissing {
+			held.MissingCount++
+			held.MissingCost += asset.Cost
+		}
+		if newCostCenter.ID != oldCostCenter.ID {
+			held.TransferredInCount++
+			held.TransferredInCost += asset.Cost
+			left := rowOf(oldCostCenter)
+			left.TransferredOutCount++
+			left.TransferredOutCost += asset.Cost
+		}
+	}
+
+	report := make([]ChargebackRow, 0, len(rows))
+	for _, row := range rows {
+		report = append(report, *row)
+	}
+	slices.SortFunc(report, func(a, b ChargebackRow) int {
+		if a.CostCenterID.Valid != b.CostCenterID.Valid {
+			if a.CostCenterID.Valid {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(a.CostCenterID.Int64, b.CostCenterID.Int64)
+	})
+	return report
+}
+
+// costCenterAt returns the cost center of the asset's owner when its session was verified.
+// transfers are the approved transfers of the asset, in the order they were approved.
+func costCenterAt(asset chargebackAsset, transfers []chargebackTransfer) chargebackCostCenter {
+	for _, transfer := range transfers {
+		if transfer.ApprovedAt.After(asset.VerifiedAt) {
+			return transfer.FromCostCenter
+		}
+	}
+	return asset.CostCenter
+}
+
+// chargebackSheet is the name of the only sheet of the workbook.
+const chargebackSheet = "Chargeback"
+
+// chargebackColumns are the headers of the sheet, in the order of chargebackValues.
+var chargebackColumns = []string{
+	"Cost Center ID", "Cost Center",
+	"Assets", "Total Cost",
+	"Broken", "Broken Cost",
+	"Missing", "Missing Cost",
+	"Transferred In", "Transferred In Cost",
+	"Transferred Out", "Transferred Out Cost",
+}
+
+// chargebackValues returns the counts and costs of a row, from the third column on.
+func chargebackValues(row ChargebackRow) []int64 {
+	return []int64{
+		row.AssetCount, row.TotalCost,
+		row.BrokenCount, row.BrokenCost,
+		row.MissingCount, row.MissingCost,
+		row.TransferredInCount, row.TransferredInCost,
+		row.TransferredOutCount, row.TransferredOutCost,
+	}
+}
+
+// BuildChargebackXLSX writes the chargeback report as a workbook with one row per cost center and a totals row.
+// period describes the verification period in the title, e.g. "2025-01-01 - 2025-03-31".
+func BuildChargebackXLSX(report []ChargebackRow, period string) ([]byte, error) {
+	workbook := excelize.NewFile()
+	defer workbook.Close()
+
+	if err := workbook.SetSheetName("Sheet1", chargebackSheet); err != nil {
+		return nil, err
+	}
+	boldStyle, err := workbook.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return nil, err
+	}
+	numberFormat := "#,##0"
+	numberStyle, err := workbook.NewStyle(&excelize.Style{CustomNumFmt: &numberFormat})
+	if err != nil {
+		return nil, err
+	}
+	totalStyle, err := workbook.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}, CustomNumFmt: &numberFormat})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := workbook.SetCellValue(chargebackSheet, "A1", "Cost center chargeback, verified opname sessions: "+period); err != nil {
+		return nil, err
+	}
+	if err := workbook.SetCellStyle(chargebackSheet, "A1", "A1", boldStyle); err != nil {
+		return nil, err
+	}
+
+	const headerRow = 3
+	if err := workbook.SetSheetRow(chargebackSheet, cell(1, headerRow), &chargebackColumns); err != nil {
+		return nil, err
+	}
+	if err := workbook.SetCellStyle(chargebackSheet, cell(1, headerRow), cell(len(chargebackColumns), headerRow), boldStyle); err != nil {
+		return nil, err
+	}
+
+	totals := make([]int64, len(chargebackColumns)-2)
+	rowNumber := headerRow
+	for _, row := range report {
+		rowNumber++
+		values := []any{"", "Unassigned"}
+		if row.CostCenterID.Valid {
+			values = []any{row.CostCenterID.Int64, row.CostCenterName.String}
+		}
+		for i, value := range chargebackValues(row) {
+			values = append(values, value)
+			totals[i] += value
+		}
+		if err := workbook.SetSheetRow(chargebackSheet, cell(1, rowNumber), &values); err != nil {
+			return nil, err
+		}
+	}
+
+	rowNumber++
+	totalValues := []any{"", "Total"}
+	for _, total := range totals {
+		totalValues = append(totalValues, total)
+	}
+	if err := workbook.SetSheetRow(chargebackSheet, cell(1, rowNumber), &totalValues); err != nil {
+		return nil, err
+	}
+	if rowNumber > headerRow+1 {
+		if err := workbook.SetCellStyle(chargebackSheet, cell(3, headerRow+1), cell(len(chargebackColumns), rowNumber-1), numberStyle); err != nil {
+			return nil, err
+		}
+	}
+	if err := workbook.SetCellStyle(chargebackSheet, cell(1, rowNumber), cell(len(chargebackColumns), rowNumber), totalStyle); err != nil {
+		return nil, err
+	}
+
+	if err := workbook.SetColWidth(chargebackSheet, "B", "B", 30); err != nil {
+		return nil, err
+	}
+	if err := workbook.SetColWidth(chargebackSheet, "C", "L", 16); err != nil {
+		return nil, err
+	}
+	if err := workbook.SetPanes(chargebackSheet, &excelize.Panes{Freeze: true, YSplit: headerRow, TopLeftCell: cell(1, headerRow+1), ActivePane: "bottomLeft"}); err != nil {
+		return nil, err
+	}
+
+	buffer, err := workbook.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("write chargeback workbook: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// cell returns the name of a cell from 1-based column and row numbers, e.g. cell(2, 3) is "B3".
+func cell(column, row int) string {
+	name, _ := excelize.CoordinatesToCellName(column, row)
+	return name
+}
@@ -0,0 +1,63 @@
+package costcenter
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildChargeback(t *testing.T) {
+	finance := chargebackCostCenter{ID: sql.NullInt64{Int64: 10, Valid: true}, Name: sql.NullString{String: "FINANCE", Valid: true}}
+	sales := chargebackCostCenter{ID: sql.NullInt64{Int64: 20, Valid: true}, Name: sql.NullString{String: "SALES", Valid: true}}
+	firstSession := time.Date(2025, time.January, 10, 9, 0, 0, 0, time.UTC)
+	secondSession := time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC)
+
+	// The asset was held by finance at the first session, transferred to sales in February, and recorded again at the second session.
+	transfers := []chargebackTransfer{
+		{AssetTag: "A1", ApprovedAt: time.Date(2025, time.February, 3, 14, 0, 0, 0, time.UTC), FromCostCenter: finance},
+	}
+
+	tests := []struct {
+		name   string
+		assets []chargebackAsset
+		want   []ChargebackRow
+	}{
+		{
+			"transferred after the session, charged to the owner at verification",
+			[]chargebackAsset{{AssetTag: "A1", Cost: 1_000, VerifiedAt: firstSession, CostCenter: sales, IsBroken: true}},
+			[]ChargebackRow{{CostCenterID: finance.ID, CostCenterName: finance.Name, AssetCount: 1, TotalCost: 1_000, BrokenCount: 1, BrokenCost: 1_000}},
+		},
+		{
+			"transferred before the session, charged to the new owner",
+			[]chargebackAsset{{AssetTag: "A1", Cost: 1_000, VerifiedAt: secondSession, CostCenter: sales, IsMissing: true}},
+			[]ChargebackRow{{CostCenterID: sales.ID, CostCenterName: sales.Name, AssetCount: 1, TotalCost: 1_000, MissingCount: 1, MissingCost: 1_000}},
+		},
+		{
+			"owner changed by the session, moved from the owner at verification",
+			[]chargebackAsset{{AssetTag: "A1", Cost: 1_000, VerifiedAt: firstSession, CostCenter: sales, NewCostCenter: sales}},
+			[]ChargebackRow{
+				{CostCenterID: finance.ID, CostCenterName: finance.Name, TransferredOutCount: 1, TransferredOutCost: 1_000},
+				{CostCenterID: sales.ID, CostCenterName: sales.Name, AssetCount: 1, TotalCost: 1_000, TransferredInCount: 1, TransferredInCost: 1_000},
+			},
+		},
+		{
+			"never transferred, charged to the current owner",
+			[]chargebackAsset{
+				{AssetTag: "B1", Cost: 500, VerifiedAt: firstSession, CostCenter: sales},
+				{AssetTag: "B2", Cost: 300, VerifiedAt: firstSession},
+			},
+			[]ChargebackRow{
+				{CostCenterID: sales.ID, CostCenterName: sales.Name, AssetCount: 1, TotalCost: 500},
+				{AssetCount: 1, TotalCost: 300},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := buildChargeback(test.assets, transfers); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("buildChargeback() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// == Handles API requests related to cost centers ==
+package costcenter
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates a new cost center handler.
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetAllCostCentersHandler retrieves every cost center.
+func (handler *Handler) GetAllCostCentersHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	costCenters, err := handler.service.GetAllCostCenters(context.Request.Context())
+	if err != nil {
+		logger.Error("failed to retrieve cost centers", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"cost_centers": costCenters})
+}
+
+// GetCostCenterByIDHandler retrieves a cost center.
+func (handler *Handler) GetCostCenterByIDHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	costCenterID, ok := costCenterIDParam(context)
+	if !ok {
+		return
+	}
+
+	costCenter, err := handler.service.GetCostCenterByID(context.Request.Context(), costCenterID)
+	if err != nil {
+		logger.Warn("failed to retrieve cost center", "cost_center_id", costCenterID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, costCenter)
+}
+
+// CreateCostCenterHandler creates a cost center. Only L1 support may do this.
+func (handler *Handler) CreateCostCenterHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	var request CostCenterRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid create cost center request", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	created, err := handler.service.CreateCostCenter(context.Request.Context(), context.GetString("position"), request)
+	if err != nil {
+		logger.Warn("failed to create cost center", "cost_center_id", request.CostCenterID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{
+		"message":     "cost center created successfully",
+		"cost_center": created,
+	})
+}
+
+// UpdateCostCenterHandler renames a cost center. Only L1 support may do this.
+func (handler *Handler) UpdateCostCenterHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	costCenterID, ok := costCenterIDParam(context)
+	if !ok {
+		return
+	}
+
+	var request CostCenterRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid update cost center request", "cost_center_id", costCenterID, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	updated, err := handler.service.UpdateCostCenter(context.Request.Context(), context.GetString("position"), costCenterID, request)
+	if err != nil {
+		logger.Warn("failed to update cost center", "cost_center_id", costCenterID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message":     "cost center updated successfully",
+		"cost_center": updated,
+	})
+}
+
+// DeleteCostCenterHandler deletes a cost center no user is charged to. Only L1 support may do this.
+func (handler *Handler) DeleteCostCenterHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	costCenterID, ok := costCenterIDParam(context)
+	if !ok {
+		return
+	}
+
+	if err := handler.service.DeleteCostCenter(context.Request.Context(), context.GetString("position"), costCenterID); err != nil {
+		logger.Warn("failed to delete cost center", "cost_center_id", costCenterID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "cost center deleted successfully"})
+}
+
+// GetChargebackHandler aggregates the assets of verified opname sessions per cost center,
+// filterable by from_date and end_date (YYYY-MM-DD) of the verification. Only admins and finance may read it.
+func (handler *Handler) GetChargebackHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	filter, report, ok := handler.chargeback(context)
+	if !ok {
+		return
+	}
+
+	serialized := make([]gin.H, 0, len(report))
+	for _, row := range report {
+		serialized = append(serialized, gin.H{
+			"cost_center_id":        utils.SerializeNI(row.CostCenterID),
+			"cost_center_name":      utils.SerializeNS(row.CostCenterName),
+			"asset_count":           row.AssetCount,
+			"total_cost":            row.TotalCost,
+			"broken_count":          row.BrokenCount,
+			"broken_cost":           row.BrokenCost,
+			"missing_count":         row.MissingCount,
+			"missing_cost":          row.MissingCost,
+			"transferred_in_count":  row.TransferredInCount,
+			"transferred_in_cost":   row.TransferredInCost,
+			"transferred_out_count": row.TransferredOutCount,
+			"transferred_out_cost":  row.TransferredOutCost,
+		})
+	}
+
+	logger.Debug("retrieved cost center chargeback", "cost_centers", len(report))
+	context.JSON(http.StatusOK, gin.H{
+		"from_date":    filter.FromDate,
+		"end_date":     filter.EndDate,
+		"cost_centers": serialized,
+	})
+}
+
+// GetChargebackXLSXHandler downloads the chargeback report as an XLSX workbook. It takes the same filter as GetChargebackHandler.
+func (handler *Handler) GetChargebackXLSXHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	filter, report, ok := handler.chargeback(context)
+	if !ok {
+		return
+	}
+
+	period := dateOrDefault(filter.FromDate, "start") + " - " + dateOrDefault(filter.EndDate, "today")
+	workbook, err := BuildChargebackXLSX(report, period)
+	if err != nil {
+		logger.Error("failed to build chargeback workbook", "error", err)
+		apperr.Abort(context, apperr.Internal(err))
+		return
+	}
+
+	filename := "chargeback_" + dateOrDefault(filter.FromDate, "start") + "_" + dateOrDefault(filter.EndDate, "today") + ".xlsx"
+	context.Header("Content-Disposition", "attachment; filename="+filename)
+	context.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", workbook)
+}
+
+// chargeback binds the filter and retrieves the report for both chargeback handlers, aborting the request on failure.
+func (handler *Handler) chargeback(context *gin.Context) (ChargebackFilter, []ChargebackRow, bool) {
+	logger := logging.FromGin(context, handler.logger)
+
+	var filter ChargebackFilter
+	if err := context.ShouldBindQuery(&filter); err != nil {
+		apperr.Abort(context, apperr.Validation("invalid_query", "invalid query parameters: "+err.Error()))
+		return filter, nil, false
+	}
+
+	report, err := handler.service.GetChargeback(context.Request.Context(), context.GetString("position"), filter)
+	if err != nil {
+		logger.Warn("failed to retrieve cost center chargeback", "error", err)
+		apperr.Abort(context, err)
+		return filter, nil, false
+	}
+	return filter, report, true
+}
+
+// costCenterIDParam parses the :cost-center-id route parameter, aborting the request when it is invalid.
+func costCenterIDParam(context *gin.Context) (int64, bool) {
+	costCenterID, err := strconv.ParseInt(context.Param("cost-center-id"), 10, 64)
+	if err != nil || costCenterID <= 0 {
+		apperr.Abort(context, apperr.Validation("invalid_cost_center_id", "invalid cost_center_id format"))
+		return 0, false
+	}
+	return costCenterID, true
+}
+
+// dateOrDefault returns the date of an optional filter field, or fallback when it is not set.
+func dateOrDefault(date *string, fallback string) string {
+	if date == nil || *date == "" {
+		return fallback
+	}
+	return *date
+}
@@ -0,0 +1,252 @@
+// == Handles all database operations related to cost centers ==
+package costcenter
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+)
+
+// CostCenter is a finance cost center that users, and through them assets, are charged to.
+type CostCenter struct {
+	CostCenterID   int64  `json:"cost_center_id"`
+	CostCenterName string `json:"cost_center_name"`
+	UserCount      int64  `json:"user_count"`
+}
+
+// CostCenterRequest is the body of POST and PUT /api/cost-center. CostCenterID is only read on create.
+type CostCenterRequest struct {
+	CostCenterID   int64  `json:"cost_center_id"`
+	CostCenterName string `json:"cost_center_name" binding:"required"`
+}
+
+// ChargebackFilter narrows down the chargeback report to sessions verified in a period. Every field is optional.
+type ChargebackFilter struct {
+	FromDate *string `json:"from_date" form:"from_date"`
+	EndDate  *string `json:"end_date" form:"end_date"`
+}
+
+// ChargebackRow aggregates the assets of verified opname sessions for one cost center. Costs are in rupiah.
+// CostCenterID is NULL for assets without a cost center.
+type ChargebackRow struct {
+	CostCenterID        sql.NullInt64
+	CostCenterName      sql.NullString
+	AssetCount          int64
+	TotalCost           int64
+	BrokenCount         int64
+	BrokenCost          int64
+	MissingCount        int64
+	MissingCost         int64
+	TransferredInCount  int64
+	TransferredInCost   int64
+	TransferredOutCount int64
+	TransferredOutCost  int64
+}
+
+// chargebackCostCenter is a cost center of the chargeback report. ID is NULL for assets without a cost center.
+type chargebackCostCenter struct {
+	ID   sql.NullInt64
+	Name sql.NullString
+}
+
+// chargebackAsset is the latest record of an asset in the opname sessions verified in the chargeback period.
+type chargebackAsset struct {
+	AssetTag      string
+	Cost          int64
+	VerifiedAt    time.Time            // When the session recording the asset was verified
+	CostCenter    chargebackCostCenter // Of the asset's current owner
+	NewCostCenter chargebackCostCenter // Of the owner given by the session, NULL if it did not change the owner
+	IsBroken      bool
+	IsMissing     bool
+}
+
+// chargebackTransfer is an approved transfer, with the cost center the asset left.
+type chargebackTransfer struct {
+	AssetTag       string
+	ApprovedAt     time.Time
+	FromCostCenter chargebackCostCenter
+}
+
+type Repository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewRepository creates a new cost center repository.
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetAllCostCenters retrieves every cost center, ordered by ID.
+func (repo *Repository) GetAllCostCenters(ctx context.Context) ([]*CostCenter, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT cost_center_id, cost_center_name, user_count FROM get_all_cost_centers()`
+
+	rows, err := repo.db.QueryContext(ctx, query)
+	if err != nil {
+		logger.Error("failed to query all cost centers", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	costCenters := make([]*CostCenter, 0)
+	for rows.Next() {
+		var costCenter CostCenter
+		if err := rows.Scan(&costCenter.CostCenterID, &costCenter.CostCenterName, &costCenter.UserCount); err != nil {
+			logger.Error("failed to scan cost center row", "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		costCenters = append(costCenters, &costCenter)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating cost center rows", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return costCenters, nil
+}
+
+// GetCostCenterByID retrieves a cost center, or nil if it does not exist.
+func (repo *Repository) GetCostCenterByID(ctx context.Context, costCenterID int64) (*CostCenter, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT cost_center_id, cost_center_name, user_count FROM get_cost_center_by_id($1)`
+
+	var costCenter CostCenter
+	err := repo.db.QueryRowContext(ctx, query, costCenterID).Scan(&costCenter.CostCenterID, &costCenter.CostCenterName, &costCenter.UserCount)
+	if err == sql.ErrNoRows {
+		logger.Debug("no cost center found", "cost_center_id", costCenterID)
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("failed to query cost center", "cost_center_id", costCenterID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return &costCenter, nil
+}
+
+// CreateCostCenter creates a cost center with the given ID.
+func (repo *Repository) CreateCostCenter(ctx context.Context, costCenterID int64, name string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL create_cost_center($1, $2)`
+
+	if _, err := repo.db.ExecContext(ctx, query, costCenterID, name); err != nil {
+		logger.Error("failed to create cost center", "cost_center_id", costCenterID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("created cost center", "cost_center_id", costCenterID)
+	return nil
+}
+
+// UpdateCostCenter renames a cost center.
+func (repo *Repository) UpdateCostCenter(ctx context.Context, costCenterID int64, name string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL update_cost_center($1, $2)`
+
+	if _, err := repo.db.ExecContext(ctx, query, costCenterID, name); err != nil {
+		logger.Error("failed to update cost center", "cost_center_id", costCenterID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Debug("updated cost center", "cost_center_id", costCenterID)
+	return nil
+}
+
+// DeleteCostCenter deletes a cost center. The stored procedure refuses while users are charged to it.
+func (repo *Repository) DeleteCostCenter(ctx context.Context, costCenterID int64) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL delete_cost_center($1)`
+
+	if _, err := repo.db.ExecContext(ctx, query, costCenterID); err != nil {
+		logger.Warn("failed to delete cost center", "cost_center_id", costCenterID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("deleted cost center", "cost_center_id", costCenterID)
+	return nil
+}
+
+// GetChargebackAssets retrieves the latest record of every asset in the sessions verified in the filter's period.
+func (repo *Repository) GetChargebackAssets(ctx context.Context, filter ChargebackFilter) ([]chargebackAsset, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT asset_tag, cost, verified_at, cost_center_id, cost_center_name, new_cost_center_id, new_cost_center_name,
+		is_broken, is_missing
+		FROM get_chargeback_assets($1, $2)`
+
+	rows, err := repo.db.QueryContext(ctx, query, filter.FromDate, filter.EndDate)
+	if err != nil {
+		logger.Error("failed to query chargeback assets", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	assets := make([]chargebackAsset, 0)
+	for rows.Next() {
+		var asset chargebackAsset
+		if err := rows.Scan(
+			&asset.AssetTag,
+			&asset.Cost,
+			&asset.VerifiedAt,
+			&asset.CostCenter.ID,
+			&asset.CostCenter.Name,
+			&asset.NewCostCenter.ID,
+			&asset.NewCostCenter.Name,
+			&asset.IsBroken,
+			&asset.IsMissing,
+		); err != nil {
+			logger.Error("failed to scan chargeback asset row", "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		assets = append(assets, asset)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating chargeback asset rows", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return assets, nil
+}
+
+// GetChargebackTransfers retrieves the transfers approved since the start of the filter's period, by asset and approval.
+func (repo *Repository) GetChargebackTransfers(ctx context.Context, filter ChargebackFilter) ([]chargebackTransfer, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT asset_tag, approved_at, from_cost_center_id, from_cost_center_name FROM get_chargeback_transfers($1)`
+
+	rows, err := repo.db.QueryContext(ctx, query, filter.FromDate)
+	if err != nil {
+		logger.Error("failed to query chargeback transfers", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	transfers := make([]chargebackTransfer, 0)
+	for rows.Next() {
+		var transfer chargebackTransfer
+		if err := rows.Scan(&transfer.AssetTag, &transfer.ApprovedAt, &transfer.FromCostCenter.ID, &transfer.FromCostCenter.Name); err != nil {
+			logger.Error("failed to scan chargeback transfer row", "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		transfers = append(transfers, transfer)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating chargeback transfer rows", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return transfers, nil
+}
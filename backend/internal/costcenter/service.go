@@ -0,0 +1,208 @@
+// == Handles all logical operations related to cost centers ==
+package costcenter
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+)
+
+// Errors returned by the cost center administration.
+var (
+	ErrAdminOnly          = apperr.Forbidden("cost_center_admin_forbidden", "only an admin can manage cost centers")
+	ErrChargebackOnly     = apperr.Forbidden("chargeback_forbidden", "only admins and finance can read the chargeback report")
+	ErrCostCenterNotFound = apperr.NotFound("cost_center_not_found", "cost center not found")
+	ErrInvalidName        = apperr.Validation("invalid_cost_center_name", "cost_center_name must be between 1 and 100 characters long")
+	ErrInvalidID          = apperr.Validation("invalid_cost_center_id", "cost_center_id must be a positive integer")
+	ErrInvalidPeriod      = apperr.Validation("invalid_query", "from_date and end_date must be formatted as YYYY-MM-DD")
+)
+
+// chargebackPositions may read the chargeback report, on top of the admin positions.
+var chargebackPositions = []string{"FINANCE OFFICER", "FINANCE & ACCOUNTING MANAGER"}
+
+type Service struct {
+	repo   *Repository
+	logger *slog.Logger
+}
+
+// NewService creates a new cost center service.
+func NewService(repo *Repository, logger *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// GetAllCostCenters retrieves every cost center.
+func (service *Service) GetAllCostCenters(ctx context.Context) ([]*CostCenter, error) {
+	return service.repo.GetAllCostCenters(ctx)
+}
+
+// GetCostCenterByID retrieves a cost center.
+func (service *Service) GetCostCenterByID(ctx context.Context, costCenterID int64) (*CostCenter, error) {
+	costCenter, err := service.repo.GetCostCenterByID(ctx, costCenterID)
+	if err != nil {
+		return nil, err
+	}
+	if costCenter == nil {
+		return nil, ErrCostCenterNotFound
+	}
+	return costCenter, nil
+}
+
+// CreateCostCenter creates a cost center on behalf of L1 support and returns it.
+func (service *Service) CreateCostCenter(ctx context.Context, adminPosition string, request CostCenterRequest) (*CostCenter, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !isCostCenterAdmin(adminPosition) {
+		logger.Warn("cost center creation denied", "position", adminPosition)
+		return nil, ErrAdminOnly
+	}
+	if request.CostCenterID <= 0 {
+		return nil, ErrInvalidID
+	}
+	name, err := validateName(request.CostCenterName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.repo.CreateCostCenter(ctx, request.CostCenterID, name); err != nil {
+		return nil, err
+	}
+	created, err := service.GetCostCenterByID(ctx, request.CostCenterID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "cost_center.create",
+		EntityType: "cost_center",
+		EntityID:   strconv.FormatInt(created.CostCenterID, 10),
+		After:      map[string]any{"cost_center_name": created.CostCenterName},
+	})
+	return created, nil
+}
+
+// UpdateCostCenter renames a cost center on behalf of L1 support and returns it.
+func (service *Service) UpdateCostCenter(ctx context.Context, adminPosition string, costCenterID int64, request CostCenterRequest) (*CostCenter, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !isCostCenterAdmin(adminPosition) {
+		logger.Warn("cost center update denied", "position", adminPosition, "cost_center_id", costCenterID)
+		return nil, ErrAdminOnly
+	}
+	name, err := validateName(request.CostCenterName)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := service.GetCostCenterByID(ctx, costCenterID)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.repo.UpdateCostCenter(ctx, costCenterID, name); err != nil {
+		return nil, err
+	}
+	after, err := service.GetCostCenterByID(ctx, costCenterID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "cost_center.update",
+		EntityType: "cost_center",
+		EntityID:   strconv.FormatInt(costCenterID, 10),
+		Before:     map[string]any{"cost_center_name": before.CostCenterName},
+		After:      map[string]any{"cost_center_name": after.CostCenterName},
+	})
+	return after, nil
+}
+
+// DeleteCostCenter deletes a cost center on behalf of L1 support. It is refused while users are charged to it.
+func (service *Service) DeleteCostCenter(ctx context.Context, adminPosition string, costCenterID int64) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !isCostCenterAdmin(adminPosition) {
+		logger.Warn("cost center deletion denied", "position", adminPosition, "cost_center_id", costCenterID)
+		return ErrAdminOnly
+	}
+
+	before, err := service.GetCostCenterByID(ctx, costCenterID)
+	if err != nil {
+		return err
+	}
+	if err := service.repo.DeleteCostCenter(ctx, costCenterID); err != nil {
+		return err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "cost_center.delete",
+		EntityType: "cost_center",
+		EntityID:   strconv.FormatInt(costCenterID, 10),
+		Before:     map[string]any{"cost_center_name": before.CostCenterName},
+	})
+	return nil
+}
+
+// GetChargeback aggregates the assets of verified opname sessions per cost center, for admins and finance.
+func (service *Service) GetChargeback(ctx context.Context, position string, filter ChargebackFilter) ([]ChargebackRow, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !canReadChargeback(position) {
+		logger.Warn("chargeback report access denied", "position", position)
+		return nil, ErrChargebackOnly
+	}
+	for _, date := range []**string{&filter.FromDate, &filter.EndDate} {
+		if *date == nil || **date == "" {
+			*date = nil
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, **date); err != nil {
+			return nil, ErrInvalidPeriod
+		}
+	}
+
+	assets, err := service.repo.GetChargebackAssets(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := service.repo.GetChargebackTransfers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return buildChargeback(assets, transfers), nil
+}
+
+// validateName trims a cost center name, which must fit the VARCHAR(100) column.
+func validateName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+// isCostCenterAdmin reports whether a position may manage cost centers, which the admin positions do.
+func isCostCenterAdmin(position string) bool {
+	return roles.IsAdmin(position)
+}
+
+// canReadChargeback reports whether a position may read the chargeback report.
+func canReadChargeback(position string) bool {
+	if roles.IsAdmin(position) {
+		return true
+	}
+	for _, allowed := range chargebackPositions {
+		if strings.EqualFold(position, allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,47 +3,70 @@
 package department
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
 	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
 	service *Service
+	logger  *slog.Logger
 }
 
 // NewHandler creates a new department handler
-func NewHandler(service *Service) *Handler {
-	return &Handler{service: service}
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
+	return &Handler{service: service, logger: logger}
 }
 
 // GetDeptByIDHandler retrieves department details by its ID
 func (handler *Handler) GetDeptByIDHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
 	deptIDstr := context.Param("id")
 	deptID, err := strconv.ParseInt(deptIDstr, 10, 64)
 	if err != nil {
-		log.Printf("❌ Error parsing department ID %s: %v", deptIDstr, err)
-		context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		logger.Warn("invalid department id", "dept_id", deptIDstr, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_dept_id", "Invalid department ID"))
 		return
 	}
 
-	department, err := handler.service.GetDeptByID(deptID)
+	department, err := handler.service.GetDeptByID(context.Request.Context(), deptID)
 	if err != nil {
-		log.Printf("❌ Error retrieving department by ID %d: %v", deptID, err)
-		context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		logger.Error("failed to retrieve department", "dept_id", deptID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
-	log.Printf("✅ Successfully retrieved department by ID %d", deptID)
+	logger.Debug("retrieved department", "dept_id", deptID)
 	context.JSON(http.StatusOK, gin.H{
 		"message":           "successfully retrieved department details with id: " + deptIDstr,
 		"dept_id":           department.DepartmentID,
 		"dept_name":         department.DepartmentName,
+		"site_id":           department.SiteID,
 		"site_name":         department.SiteName,
 		"site_group_name":   department.SiteGroupName,
 		"region_name":       department.RegionName,
 		"opname_session_id": department.OpnameSessionID,
 	})
 }
+
+// GetAllDeptsHandler retrieves every department
+func (handler *Handler) GetAllDeptsHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	depts, err := handler.service.GetAllDepts(context.Request.Context())
+	if err != nil {
+		logger.Error("failed to retrieve departments", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	logger.Debug("retrieved all departments", "count", len(depts))
+	context.JSON(http.StatusOK, gin.H{
+		"departments": depts,
+	})
+}
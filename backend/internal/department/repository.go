@@ -3,18 +3,23 @@
 package department
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
 )
 
 // Repository is the struct for the department repository
 type Repository struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *slog.Logger
 }
 
 // NewRepository creates a new department repository
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{db: db, logger: logger}
 }
 
 type Department struct {
@@ -24,24 +29,68 @@ type Department struct {
 	SiteGroupName   string `json:"site_group_name"`
 	RegionName      string `json:"region_name"`
 	OpnameSessionID int64  `json:"opname_session_id"`
+	SiteID          int64  `json:"site_id"` // The department's head office
 }
 
 // GetDeptByID retrieves department details by its ID
-func (repo *Repository) GetDeptByID(deptID int64) (*Department, error) {
+func (repo *Repository) GetDeptByID(ctx context.Context, deptID int64) (*Department, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var dept Department
-	query := `SELECT dept_id, dept_name, site_name, site_group_name, region_name, latest_opname_session_id FROM get_dept_by_id($1)`
-	err := repo.db.QueryRow(query, deptID).Scan(
+	query := `SELECT dept_id, dept_name, site_name, site_group_name, region_name, latest_opname_session_id, site_id FROM get_dept_by_id($1)`
+	err := repo.db.QueryRowContext(ctx, query, deptID).Scan(
 		&dept.DepartmentID,
 		&dept.DepartmentName,
 		&dept.SiteName,
 		&dept.SiteGroupName,
 		&dept.RegionName,
 		&dept.OpnameSessionID,
+		&dept.SiteID,
 	)
+	if err == sql.ErrNoRows {
+		logger.Debug("no department found", "dept_id", deptID)
+		return nil, apperr.NotFound("department_not_found", "department not found")
+	}
 	if err != nil {
-		log.Printf("❌ Error retrieving department by ID %d: %v", deptID, err)
-		return nil, err
+		logger.Error("failed to query department", "dept_id", deptID, "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 
 	return &dept, nil
 }
+
+// DepartmentSummary is a department without its location details, as listed by GetAllDepts.
+type DepartmentSummary struct {
+	DepartmentID   int64  `json:"dept_id"`
+	DepartmentName string `json:"dept_name"`
+	SiteID         int64  `json:"site_id"`
+}
+
+// GetAllDepts retrieves every department, ordered by name
+func (repo *Repository) GetAllDepts(ctx context.Context) ([]*DepartmentSummary, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT dept_id, dept_name, site_id FROM get_all_departments()`
+	rows, err := repo.db.QueryContext(ctx, query)
+	if err != nil {
+		logger.Error("failed to query all departments", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	depts := make([]*DepartmentSummary, 0)
+	for rows.Next() {
+		var dept DepartmentSummary
+		if err := rows.Scan(&dept.DepartmentID, &dept.DepartmentName, &dept.SiteID); err != nil {
+			logger.Error("failed to scan department row", "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		depts = append(depts, &dept)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating department rows", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return depts, nil
+}
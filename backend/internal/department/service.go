@@ -2,16 +2,27 @@
 
 package department
 
+import (
+	"context"
+	"log/slog"
+)
+
 type Service struct {
-	repo *Repository
+	repo   *Repository
+	logger *slog.Logger
 }
 
 // NewService creates a new department service
-func NewService(repo *Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo *Repository, logger *slog.Logger) *Service {
+	return &Service{repo: repo, logger: logger}
 }
 
 // GetDeptByID retrieves department details by its ID
-func (service *Service) GetDeptByID(deptID int64) (*Department, error) {
-	return service.repo.GetDeptByID(deptID)
+func (service *Service) GetDeptByID(ctx context.Context, deptID int64) (*Department, error) {
+	return service.repo.GetDeptByID(ctx, deptID)
+}
+
+// GetAllDepts retrieves every department, ordered by name
+func (service *Service) GetAllDepts(ctx context.Context) ([]*DepartmentSummary, error) {
+	return service.repo.GetAllDepts(ctx)
 }
@@ -0,0 +1,92 @@
+// == Computes the net book value of assets from their acquisition cost and date and the depreciation policy of their product variety ==
+package depreciation
+
+import (
+	"database/sql"
+	"math"
+	"time"
+)
+
+// Depreciation methods. With both, an asset is worth nothing once its useful life is over.
+const (
+	StraightLine     = "Straight Line"
+	DecliningBalance = "Declining Balance"
+)
+
+// Methods are the supported depreciation methods.
+var Methods = []string{StraightLine, DecliningBalance}
+
+// Policy is how the assets of a product variety lose value.
+type Policy struct {
+	Method           string
+	UsefulLifeMonths int
+}
+
+// Valuation is what the net book value of an asset is computed from, as read from the database.
+// Assets without an acquisition date or a depreciation policy are not depreciated.
+type Valuation struct {
+	Cost             int64 // Acquisition cost in rupiah, "Asset".total_cost
+	AcquisitionDate  sql.NullTime
+	Method           sql.NullString
+	UsefulLifeMonths sql.NullInt64
+}
+
+// BookValue returns the net book value of the asset at asOf, in rupiah.
+func (valuation Valuation) BookValue(asOf time.Time) int64 {
+	if !valuation.AcquisitionDate.Valid || !valuation.Method.Valid || !valuation.UsefulLifeMonths.Valid {
+		return max(valuation.Cost, 0)
+	}
+	policy := Policy{Method: valuation.Method.String, UsefulLifeMonths: int(valuation.UsefulLifeMonths.Int64)}
+	return BookValue(valuation.Cost, valuation.AcquisitionDate.Time, policy, asOf)
+}
+
+// BookValue returns the net book value at asOf, in rupiah, of an asset acquired for cost on acquiredOn.
+// Depreciation counts whole months from the acquisition date, so an asset is worth its cost until a month has passed.
+// Straight line takes the same amount off every month. Declining balance takes twice the straight-line yearly rate off the
+// value left at the start of each year, pro rata over the months of the current year, and writes off the rest at the end.
+// An unknown method or a useful life that is not positive leaves the asset at its cost.
+func BookValue(cost int64, acquiredOn time.Time, policy Policy, asOf time.Time) int64 {
+	if cost <= 0 {
+		return 0
+	}
+	life := policy.UsefulLifeMonths
+	if life <= 0 || (policy.Method != StraightLine && policy.Method != DecliningBalance) {
+		return cost
+	}
+	months := MonthsElapsed(acquiredOn, asOf)
+	if months == 0 {
+		return cost
+	}
+	if months >= life {
+		return 0
+	}
+
+	switch policy.Method {
+	case StraightLine:
+		return cost - cost*int64(months)/int64(life)
+	case DecliningBalance:
+		yearlyRate := min(24/float64(life), 1)
+		value := float64(cost)
+		for ; months >= 12; months -= 12 {
+			value -= value * yearlyRate
+		}
+		value -= value * yearlyRate * float64(months) / 12
+		return int64(math.Round(value))
+	}
+	return cost
+}
+
+// MonthsElapsed returns the number of whole months from one date to another, 0 if to is before from.
+// Only the calendar dates count: a month has passed on the same day of the next month, or on its last day
+// when it is shorter, e.g. from January 31 to February 28 or from February 29 to February 28 of the next year.
+func MonthsElapsed(from, to time.Time) int {
+	fromYear, fromMonth, fromDay := from.Date()
+	toYear, toMonth, toDay := to.Date()
+
+	months := (toYear-fromYear)*12 + int(toMonth-fromMonth)
+	lastDay := time.Date(toYear, toMonth+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if toDay < fromDay && toDay < lastDay {
+		months--
+	}
+	return max(months, 0)
+}
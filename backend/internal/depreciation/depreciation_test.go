@@ -0,0 +1,120 @@
+package depreciation
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestMonthsElapsed(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{"same day", date(2024, time.January, 15), date(2024, time.January, 15), 0},
+		{"day before a month", date(2024, time.January, 15), date(2024, time.February, 14), 0},
+		{"same day of next month", date(2024, time.January, 15), date(2024, time.February, 15), 1},
+		{"across a year", date(2023, time.November, 20), date(2025, time.February, 20), 15},
+		{"time of day is ignored", time.Date(2024, time.January, 15, 23, 0, 0, 0, time.UTC), time.Date(2024, time.February, 15, 1, 0, 0, 0, time.UTC), 1},
+		{"month end to shorter month end", date(2023, time.January, 31), date(2023, time.February, 28), 1},
+		{"month end to leap february end", date(2024, time.January, 31), date(2024, time.February, 29), 1},
+		{"month end before shorter month end", date(2024, time.January, 31), date(2024, time.February, 28), 0},
+		{"month end to day before month end", date(2024, time.January, 31), date(2024, time.March, 30), 1},
+		{"month end to month end", date(2024, time.January, 31), date(2024, time.March, 31), 2},
+		{"month end to 30 day month end", date(2024, time.March, 31), date(2024, time.April, 30), 1},
+		{"leap day to day before next month", date(2024, time.February, 29), date(2024, time.March, 28), 0},
+		{"leap day to same day of next month", date(2024, time.February, 29), date(2024, time.March, 29), 1},
+		{"leap day to february end of next year", date(2024, time.February, 29), date(2025, time.February, 28), 12},
+		{"leap day to next leap day", date(2024, time.February, 29), date(2028, time.February, 29), 48},
+		{"to before from", date(2024, time.March, 15), date(2024, time.January, 15), 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := MonthsElapsed(test.from, test.to); got != test.want {
+				t.Errorf("MonthsElapsed(%s, %s) = %d, want %d", test.from.Format(time.DateOnly), test.to.Format(time.DateOnly), got, test.want)
+			}
+		})
+	}
+}
+
+func TestBookValue(t *testing.T) {
+	acquired := date(2024, time.January, 15)
+	straightLine := Policy{Method: StraightLine, UsefulLifeMonths: 12}
+	decliningBalance := Policy{Method: DecliningBalance, UsefulLifeMonths: 48} // Yearly rate 24/48 = 50%
+
+	tests := []struct {
+		name       string
+		cost       int64
+		acquiredOn time.Time
+		policy     Policy
+		asOf       time.Time
+		want       int64
+	}{
+		{"straight line on acquisition", 1_200_000, acquired, straightLine, acquired, 1_200_000},
+		{"straight line before a month", 1_200_000, acquired, straightLine, date(2024, time.February, 14), 1_200_000},
+		{"straight line after a month", 1_200_000, acquired, straightLine, date(2024, time.February, 15), 1_100_000},
+		{"straight line half way", 1_200_000, acquired, straightLine, date(2024, time.July, 15), 600_000},
+		{"straight line rounds the depreciation down", 1_000, acquired, Policy{Method: StraightLine, UsefulLifeMonths: 3}, date(2024, time.February, 15), 667},
+		{"straight line at end of life", 1_200_000, acquired, straightLine, date(2025, time.January, 15), 0},
+		{"straight line past end of life", 1_200_000, acquired, straightLine, date(2030, time.January, 1), 0},
+
+		{"declining balance within first year", 1_000_000, acquired, decliningBalance, date(2024, time.July, 15), 750_000},
+		{"declining balance after a year", 1_000_000, acquired, decliningBalance, date(2025, time.January, 15), 500_000},
+		{"declining balance pro rata in second year", 1_000_000, acquired, decliningBalance, date(2025, time.July, 15), 375_000},
+		{"declining balance after two years", 1_000_000, acquired, decliningBalance, date(2026, time.January, 15), 250_000},
+		{"declining balance rounds the value", 1_000_000, acquired, decliningBalance, date(2027, time.December, 15), 67_708},
+		{"declining balance at end of life", 1_000_000, acquired, decliningBalance, date(2028, time.January, 15), 0},
+		{"declining balance past end of life", 1_000_000, acquired, decliningBalance, date(2035, time.January, 15), 0},
+		{"declining balance rate capped at 100%", 1_000_000, acquired, Policy{Method: DecliningBalance, UsefulLifeMonths: 12}, date(2024, time.July, 15), 500_000},
+		{"declining balance capped rate writes off the first year", 1_000_000, acquired, Policy{Method: DecliningBalance, UsefulLifeMonths: 18}, date(2025, time.January, 15), 0},
+		{"declining balance capped rate before end of life", 1_000_000, acquired, Policy{Method: DecliningBalance, UsefulLifeMonths: 18}, date(2025, time.April, 15), 0},
+
+		{"month end acquisition in shorter month", 1_200_000, date(2023, time.January, 31), straightLine, date(2023, time.February, 28), 1_100_000},
+		{"month end acquisition before a month", 1_200_000, date(2024, time.January, 31), straightLine, date(2024, time.February, 28), 1_200_000},
+		{"leap day acquisition after a year", 1_000_000, date(2024, time.February, 29), decliningBalance, date(2025, time.February, 28), 500_000},
+		{"leap day acquisition at end of life", 1_200_000, date(2024, time.February, 29), straightLine, date(2025, time.February, 28), 0},
+
+		{"as of before acquisition", 1_200_000, acquired, straightLine, date(2023, time.December, 31), 1_200_000},
+		{"zero cost", 0, acquired, straightLine, date(2024, time.July, 15), 0},
+		{"negative cost", -500_000, acquired, decliningBalance, date(2024, time.July, 15), 0},
+		{"unknown method", 1_200_000, acquired, Policy{Method: "Sum Of Years", UsefulLifeMonths: 12}, date(2024, time.July, 15), 1_200_000},
+		{"useful life not positive", 1_200_000, acquired, Policy{Method: StraightLine, UsefulLifeMonths: 0}, date(2024, time.July, 15), 1_200_000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := BookValue(test.cost, test.acquiredOn, test.policy, test.asOf); got != test.want {
+				t.Errorf("BookValue(%d, %s, %+v, %s) = %d, want %d", test.cost, test.acquiredOn.Format(time.DateOnly), test.policy, test.asOf.Format(time.DateOnly), got, test.want)
+			}
+		})
+	}
+}
+
+func TestValuationBookValue(t *testing.T) {
+	acquired := sql.NullTime{Time: date(2024, time.January, 15), Valid: true}
+	method := sql.NullString{String: StraightLine, Valid: true}
+	life := sql.NullInt64{Int64: 12, Valid: true}
+	asOf := date(2024, time.July, 15)
+
+	tests := []struct {
+		name      string
+		valuation Valuation
+		want      int64
+	}{
+		{"with a policy", Valuation{Cost: 1_200_000, AcquisitionDate: acquired, Method: method, UsefulLifeMonths: life}, 600_000},
+		{"without acquisition date", Valuation{Cost: 1_200_000, Method: method, UsefulLifeMonths: life}, 1_200_000},
+		{"without policy", Valuation{Cost: 1_200_000, AcquisitionDate: acquired}, 1_200_000},
+		{"without policy and negative cost", Valuation{Cost: -1, AcquisitionDate: acquired}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.valuation.BookValue(asOf); got != test.want {
+				t.Errorf("BookValue(%s) = %d, want %d", asOf.Format(time.DateOnly), got, test.want)
+			}
+		})
+	}
+}
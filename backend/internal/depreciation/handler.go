@@ -0,0 +1,79 @@
+// == Handles API requests related to depreciation policies ==
+package depreciation
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates a new depreciation policy handler.
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetPoliciesHandler retrieves the depreciation policy of every product variety.
+func (handler *Handler) GetPoliciesHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	policies, err := handler.service.GetPolicies(context.Request.Context())
+	if err != nil {
+		logger.Error("failed to retrieve depreciation policies", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	serialized := make([]gin.H, 0, len(policies))
+	for _, policy := range policies {
+		serialized = append(serialized, serializePolicy(policy))
+	}
+	context.JSON(http.StatusOK, gin.H{"policies": serialized})
+}
+
+// UpdatePolicyHandler changes the depreciation method and useful life of a product variety. Only L1 support may do this.
+func (handler *Handler) UpdatePolicyHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	var request PolicyRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid update depreciation policy request", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	updated, err := handler.service.UpdatePolicy(context.Request.Context(), context.GetString("position"), request)
+	if err != nil {
+		logger.Warn("failed to update depreciation policy", "product_variety", request.ProductVariety, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "depreciation policy updated successfully",
+		"policy":  serializePolicy(updated),
+	})
+}
+
+// serializePolicy converts a depreciation policy to its JSON form.
+func serializePolicy(policy *VarietyPolicy) gin.H {
+	return gin.H{
+		"product_variety":     policy.ProductVariety,
+		"depreciation_method": policy.Method,
+		"useful_life_months":  policy.UsefulLifeMonths,
+		"asset_count":         policy.AssetCount,
+		"undated_asset_count": policy.UndatedAssetCount,
+		"updated_at":          policy.UpdatedAt,
+	}
+}
@@ -0,0 +1,93 @@
+// == Handles all database operations related to depreciation policies ==
+package depreciation
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+)
+
+// VarietyPolicy is the depreciation policy of a product variety, with the assets it applies to. Disposed assets are not counted.
+type VarietyPolicy struct {
+	ProductVariety    string
+	Policy            // Method and useful life
+	AssetCount        int64
+	UndatedAssetCount int64 // Assets without an acquisition date, which are not depreciated
+	UpdatedAt         time.Time
+}
+
+// PolicyRequest is the body of PUT /api/depreciation. The product variety is in the body as it may contain a slash.
+type PolicyRequest struct {
+	ProductVariety   string `json:"product_variety" binding:"required"`
+	Method           string `json:"depreciation_method" binding:"required"`
+	UsefulLifeMonths int    `json:"useful_life_months" binding:"required"`
+}
+
+type Repository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewRepository creates a new depreciation policy repository.
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetPolicies retrieves the depreciation policy of every product variety.
+func (repo *Repository) GetPolicies(ctx context.Context) ([]*VarietyPolicy, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT product_variety, depreciation_method, useful_life_months, asset_count, undated_asset_count, updated_at FROM get_depreciation_policies()`
+
+	rows, err := repo.db.QueryContext(ctx, query)
+	if err != nil {
+		logger.Error("failed to query depreciation policies", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	policies := make([]*VarietyPolicy, 0)
+	for rows.Next() {
+		var policy VarietyPolicy
+		if err := rows.Scan(
+			&policy.ProductVariety,
+			&policy.Method,
+			&policy.UsefulLifeMonths,
+			&policy.AssetCount,
+			&policy.UndatedAssetCount,
+			&policy.UpdatedAt,
+		); err != nil {
+			logger.Error("failed to scan depreciation policy row", "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		policies = append(policies, &policy)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating depreciation policy rows", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return policies, nil
+}
+
+// UpdatePolicy changes the method and useful life of a product variety.
+func (repo *Repository) UpdatePolicy(ctx context.Context, productVariety string, policy Policy) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL update_depreciation_policy($1, $2, $3)`
+
+	if _, err := repo.db.ExecContext(ctx, query, productVariety, policy.Method, policy.UsefulLifeMonths); err != nil {
+		logger.Warn("failed to update depreciation policy", "product_variety", productVariety, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("updated depreciation policy", "product_variety", productVariety, "method", policy.Method, "useful_life_months", policy.UsefulLifeMonths)
+	return nil
+}
@@ -0,0 +1,107 @@
+// == Handles all logical operations related to depreciation policies ==
+package depreciation
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+)
+
+// maxUsefulLifeMonths bounds the useful life of a product variety, 50 years.
+const maxUsefulLifeMonths = 600
+
+// Errors returned by the depreciation policy administration.
+var (
+	ErrAdminOnly         = apperr.Forbidden("depreciation_admin_forbidden", "only an admin can manage depreciation policies")
+	ErrPolicyNotFound    = apperr.NotFound("depreciation_policy_not_found", "depreciation policy not found")
+	ErrInvalidMethod     = apperr.Validation("invalid_depreciation_method", "depreciation_method must be Straight Line or Declining Balance")
+	ErrInvalidUsefulLife = apperr.Validation("invalid_useful_life", "useful_life_months must be between 1 and 600")
+)
+
+type Service struct {
+	repo   *Repository
+	logger *slog.Logger
+}
+
+// NewService creates a new depreciation policy service.
+func NewService(repo *Repository, logger *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// GetPolicies retrieves the depreciation policy of every product variety.
+func (service *Service) GetPolicies(ctx context.Context) ([]*VarietyPolicy, error) {
+	return service.repo.GetPolicies(ctx)
+}
+
+// GetPolicy retrieves the depreciation policy of a product variety.
+func (service *Service) GetPolicy(ctx context.Context, productVariety string) (*VarietyPolicy, error) {
+	policies, err := service.repo.GetPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range policies {
+		if strings.EqualFold(policy.ProductVariety, productVariety) {
+			return policy, nil
+		}
+	}
+	return nil, ErrPolicyNotFound
+}
+
+// UpdatePolicy changes the depreciation policy of a product variety on behalf of L1 support and returns it.
+func (service *Service) UpdatePolicy(ctx context.Context, adminPosition string, request PolicyRequest) (*VarietyPolicy, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !roles.IsAdmin(adminPosition) {
+		logger.Warn("depreciation policy update denied", "position", adminPosition, "product_variety", request.ProductVariety)
+		return nil, ErrAdminOnly
+	}
+
+	method, ok := normalizeMethod(request.Method)
+	if !ok {
+		return nil, ErrInvalidMethod
+	}
+	if request.UsefulLifeMonths < 1 || request.UsefulLifeMonths > maxUsefulLifeMonths {
+		return nil, ErrInvalidUsefulLife
+	}
+
+	before, err := service.GetPolicy(ctx, strings.TrimSpace(request.ProductVariety))
+	if err != nil {
+		return nil, err
+	}
+	policy := Policy{Method: method, UsefulLifeMonths: request.UsefulLifeMonths}
+	if err := service.repo.UpdatePolicy(ctx, before.ProductVariety, policy); err != nil {
+		return nil, err
+	}
+	after, err := service.GetPolicy(ctx, before.ProductVariety)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "depreciation_policy.update",
+		EntityType: "depreciation_policy",
+		EntityID:   after.ProductVariety,
+		Before:     map[string]any{"depreciation_method": before.Method, "useful_life_months": before.UsefulLifeMonths},
+		After:      map[string]any{"depreciation_method": after.Method, "useful_life_months": after.UsefulLifeMonths},
+	})
+	return after, nil
+}
+
+// normalizeMethod matches a depreciation method case-insensitively against the supported ones.
+func normalizeMethod(method string) (string, bool) {
+	method = strings.TrimSpace(method)
+	for _, candidate := range Methods {
+		if strings.EqualFold(method, candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,124 @@
+// == Reads the HR export: a semicolon separated file with the same columns as the seed's user.csv ==
+package directory
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// csvColumns maps the accepted header names to the field they fill. Columns may come in any order; unknown ones are ignored.
+var csvColumns = map[string]func(record *Record, value string) error{
+	"user_id":        func(record *Record, value string) error { return parseID(value, &record.UserID) },
+	"username":       func(record *Record, value string) error { record.Username = value; return nil },
+	"email":          func(record *Record, value string) error { record.Email = value; return nil },
+	"first_name":     func(record *Record, value string) error { record.FirstName = value; return nil },
+	"last_name":      func(record *Record, value string) error { record.LastName = value; return nil },
+	"position":       func(record *Record, value string) error { record.Position = value; return nil },
+	"department":     func(record *Record, value string) error { record.Department = value; return nil },
+	"division":       func(record *Record, value string) error { record.Division = value; return nil },
+	"site_id":        func(record *Record, value string) error { return parseOptionalID(value, &record.SiteID) },
+	"cost_center":    func(record *Record, value string) error { return parseOptionalID(value, &record.CostCenterID) },
+	"cost_center_id": func(record *Record, value string) error { return parseOptionalID(value, &record.CostCenterID) },
+	"ou_code":        func(record *Record, value string) error { record.OuCode = value; return nil },
+}
+
+// csvSource reads the nightly HR drop from a file.
+type csvSource struct {
+	path string
+}
+
+func (source *csvSource) Name() string {
+	return "csv"
+}
+
+// Fetch parses the whole file. Any malformed line fails the sync, since a partial list would deactivate everyone missing from it.
+func (source *csvSource) Fetch(ctx context.Context) ([]Record, error) {
+	file, err := os.Open(source.path)
+	if err != nil {
+		return nil, fmt.Errorf("open HR export: %w", err)
+	}
+	defer file.Close()
+
+	return parseCSV(file)
+}
+
+// parseCSV reads the records of an HR export.
+func parseCSV(input io.Reader) ([]Record, error) {
+	reader := csv.NewReader(input)
+	reader.Comma = ';'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("HR export is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read HR export header: %w", err)
+	}
+
+	setters := make([]func(*Record, string) error, len(header))
+	found := make(map[string]bool)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) // Excel adds a byte order mark
+		setters[i] = csvColumns[name]
+		found[name] = setters[i] != nil
+	}
+	for _, required := range []string{"user_id", "username"} {
+		if !found[required] {
+			return nil, fmt.Errorf("HR export has no %s column", required)
+		}
+	}
+
+	var records []Record
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read HR export: %w", err)
+		}
+
+		var record Record
+		for i, value := range fields {
+			if setters[i] == nil {
+				continue
+			}
+			if err := setters[i](&record, strings.TrimSpace(value)); err != nil {
+				line, _ := reader.FieldPos(i)
+				return nil, fmt.Errorf("HR export line %d, column %s: %w", line, header[i], err)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// parseID parses a required numeric ID.
+func parseID(value string, target *int64) error {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", value)
+	}
+	*target = id
+	return nil
+}
+
+// parseOptionalID parses a numeric ID, leaving target nil for an empty value.
+func parseOptionalID(value string, target **int64) error {
+	if value == "" {
+		return nil
+	}
+	var id int64
+	if err := parseID(value, &id); err != nil {
+		return err
+	}
+	*target = &id
+	return nil
+}
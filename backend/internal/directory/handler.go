@@ -0,0 +1,153 @@
+// == Handles API requests related to the directory sync ==
+package directory
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates a new directory sync handler.
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// TriggerSyncHandler starts a directory sync in the background. Poll GET /api/directory/sync-runs/:run-id for its report.
+func (handler *Handler) TriggerSyncHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	runID, err := handler.service.Trigger(context.Request.Context(), actorFromGin(context))
+	if err != nil {
+		logger.Warn("failed to start directory sync", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	logger.Info("directory sync started", "run_id", runID)
+	context.JSON(http.StatusAccepted, gin.H{
+		"message": "directory sync started",
+		"run_id":  runID,
+	})
+}
+
+// GetSyncRunsHandler lists the latest directory syncs, newest first. ?limit= defaults to 20.
+func (handler *Handler) GetSyncRunsHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	limit, err := strconv.Atoi(context.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 {
+		apperr.Abort(context, apperr.Validation("invalid_query", "limit must be a positive number"))
+		return
+	}
+
+	runs, err := handler.service.GetRuns(context.Request.Context(), actorFromGin(context), limit)
+	if err != nil {
+		logger.Error("failed to retrieve directory sync runs", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	serialized := make([]gin.H, 0, len(runs))
+	for i := range runs {
+		serialized = append(serialized, serializeRun(&runs[i]))
+	}
+	context.JSON(http.StatusOK, gin.H{"runs": serialized})
+}
+
+// GetSyncRunHandler retrieves one directory sync with its change report.
+func (handler *Handler) GetSyncRunHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	runID, err := strconv.ParseInt(context.Param("run-id"), 10, 64)
+	if err != nil {
+		apperr.Abort(context, apperr.Validation("invalid_run_id", "invalid run ID"))
+		return
+	}
+
+	run, err := handler.service.GetRun(context.Request.Context(), actorFromGin(context), runID)
+	if err != nil {
+		logger.Error("failed to retrieve directory sync run", "run_id", runID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	serialized := serializeRun(run)
+	serialized["report"] = run.Report
+	context.JSON(http.StatusOK, serialized)
+}
+
+// GetReassignmentsHandler lists the assets whose owner left and that wait for a new owner.
+func (handler *Handler) GetReassignmentsHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	reassignments, err := handler.service.GetOpenReassignments(context.Request.Context(), actorFromGin(context))
+	if err != nil {
+		logger.Error("failed to retrieve asset reassignments", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	serialized := make([]gin.H, 0, len(reassignments))
+	for _, reassignment := range reassignments {
+		serialized = append(serialized, gin.H{
+			"id":                  reassignment.ID,
+			"asset_tag":           reassignment.AssetTag,
+			"product_name":        reassignment.ProductName,
+			"sub_site_id":         utils.SerializeNI(reassignment.SubSiteID),
+			"dept_id":             utils.SerializeNI(reassignment.DeptID),
+			"previous_owner_id":   reassignment.PreviousOwnerID,
+			"previous_owner_name": reassignment.PreviousOwnerName,
+			"sync_run_id":         utils.SerializeNI(reassignment.SyncRunID),
+			"flagged_at":          reassignment.FlaggedAt,
+		})
+	}
+	context.JSON(http.StatusOK, gin.H{"reassignments": serialized})
+}
+
+// serializeRun converts a run to its JSON form, without the report.
+func serializeRun(run *Run) gin.H {
+	var finishedAt any
+	if run.FinishedAt.Valid {
+		finishedAt = run.FinishedAt.Time
+	}
+	return gin.H{
+		"id":           run.ID,
+		"source":       run.Source,
+		"trigger":      run.Trigger,
+		"triggered_by": utils.SerializeNI(run.TriggeredBy),
+		"status":       run.Status,
+		"started_at":   run.StartedAt,
+		"finished_at":  finishedAt,
+		"summary":      run.Summary,
+		"error":        run.Error,
+	}
+}
+
+// actorFromGin reads the authenticated user placed in the context by the auth middleware.
+func actorFromGin(context *gin.Context) *audit.Actor {
+	userID, exists := context.Get("user_id")
+	if !exists {
+		return nil
+	}
+	id, _ := userID.(int64)
+	return &audit.Actor{
+		UserID:   id,
+		Username: context.GetString("username"),
+		Position: context.GetString("position"),
+	}
+}
@@ -0,0 +1,106 @@
+// == Reads the employees from the LDAP (or Active Directory) server ==
+package directory
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ldapPageSize is the number of entries requested per page, below Active Directory's default limit of 1000.
+const ldapPageSize = 500
+
+// ldapSource searches the directory server.
+type ldapSource struct {
+	config config.LDAPConfig
+}
+
+func (source *ldapSource) Name() string {
+	return "ldap"
+}
+
+// Fetch binds with the service account and pages through every entry matching the filter.
+func (source *ldapSource) Fetch(ctx context.Context) ([]Record, error) {
+	conn, err := ldap.DialURL(source.config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("connect to LDAP server: %w", err)
+	}
+	defer conn.Close()
+
+	// The library has no context support, so closing the connection is what aborts a search on shutdown.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if source.config.StartTLS {
+		host := source.config.URL
+		if _, rest, found := strings.Cut(host, "://"); found {
+			host = rest
+		}
+		host, _, _ = strings.Cut(host, ":")
+		if err := conn.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return nil, fmt.Errorf("start TLS with LDAP server: %w", err)
+		}
+	}
+	if source.config.BindDN != "" {
+		if err := conn.Bind(source.config.BindDN, source.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("bind to LDAP server: %w", err)
+		}
+	}
+
+	attributes := source.config.Attributes
+	request := ldap.NewSearchRequest(
+		source.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		source.config.Filter,
+		nonEmpty(attributes.UserID, attributes.Username, attributes.Email, attributes.FirstName, attributes.LastName,
+			attributes.Position, attributes.Department, attributes.Division, attributes.SiteID, attributes.CostCenterID, attributes.OuCode),
+		nil,
+	)
+	result, err := conn.SearchWithPaging(request, ldapPageSize)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("search LDAP server: %w", err)
+	}
+
+	records := make([]Record, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		record := Record{
+			Username:   entry.GetAttributeValue(attributes.Username),
+			Email:      entry.GetAttributeValue(attributes.Email),
+			FirstName:  entry.GetAttributeValue(attributes.FirstName),
+			LastName:   entry.GetAttributeValue(attributes.LastName),
+			Position:   entry.GetAttributeValue(attributes.Position),
+			Department: entry.GetAttributeValue(attributes.Department),
+			Division:   entry.GetAttributeValue(attributes.Division),
+			OuCode:     entry.GetAttributeValue(attributes.OuCode),
+		}
+		if err := parseID(strings.TrimSpace(entry.GetAttributeValue(attributes.UserID)), &record.UserID); err != nil {
+			return nil, fmt.Errorf("LDAP entry %s, attribute %s: %w", entry.DN, attributes.UserID, err)
+		}
+		if err := parseOptionalID(strings.TrimSpace(entry.GetAttributeValue(attributes.SiteID)), &record.SiteID); err != nil {
+			return nil, fmt.Errorf("LDAP entry %s, attribute %s: %w", entry.DN, attributes.SiteID, err)
+		}
+		if err := parseOptionalID(strings.TrimSpace(entry.GetAttributeValue(attributes.CostCenterID)), &record.CostCenterID); err != nil {
+			return nil, fmt.Errorf("LDAP entry %s, attribute %s: %w", entry.DN, attributes.CostCenterID, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// nonEmpty drops the attributes that are not mapped.
+func nonEmpty(values ...string) []string {
+	var kept []string
+	for _, value := range values {
+		if value != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,239 @@
+// == Handles all database operations related to directory synchronisation ==
+package directory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+)
+
+// Run is one directory sync. Report is only loaded by GetRun.
+type Run struct {
+	ID          int64
+	Source      string
+	Trigger     string // "schedule", "manual" or "cli"
+	TriggeredBy sql.NullInt64
+	Status      string // "running", "succeeded" or "failed"
+	StartedAt   time.Time
+	FinishedAt  sql.NullTime
+	Summary     json.RawMessage
+	Error       string
+	Report      json.RawMessage
+}
+
+// Change is one line of a sync's change report.
+type Change struct {
+	UserID        int64           `json:"user_id"`
+	Username      string          `json:"username"`
+	Action        string          `json:"action"` // created, updated, reactivated, deactivated, unchanged or skipped
+	Changes       json.RawMessage `json:"changes,omitempty"`
+	FlaggedAssets int             `json:"flagged_assets,omitempty"` // Assets of a deactivated user flagged for reassignment
+}
+
+// Reassignment is an asset whose owner left and that waits for a new owner.
+type Reassignment struct {
+	ID                int64
+	AssetTag          string
+	ProductName       string
+	SubSiteID         sql.NullInt64
+	DeptID            sql.NullInt64
+	PreviousOwnerID   int64
+	PreviousOwnerName string
+	SyncRunID         sql.NullInt64
+	FlaggedAt         time.Time
+}
+
+type Repository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewRepository creates a new directory sync repository.
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// StartRun records the start of a sync and returns its ID. It fails with a conflict while another sync is running.
+func (repo *Repository) StartRun(ctx context.Context, source, trigger string, triggeredBy sql.NullInt64) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT start_directory_sync_run($1, $2, $3)`
+
+	var runID int64
+	err := repo.db.QueryRowContext(ctx, query, source, trigger, triggeredBy).Scan(&runID)
+	if err != nil {
+		logger.Error("failed to start directory sync run", "source", source, "trigger", trigger, "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+
+	return runID, nil
+}
+
+// ApplySync upserts the records and deactivates the users missing from them in one transaction, returning what changed.
+// Only users managed by the directory are deactivated, and an admin's (de)activation is kept until the directory agrees.
+// Nothing is applied when more than maxDeactivationRatio of the active users would be deactivated.
+func (repo *Repository) ApplySync(ctx context.Context, runID int64, records []Record, maxDeactivationRatio float64) ([]Change, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM apply_directory_sync($1, $2, $3)`
+
+	payload, err := json.Marshal(records)
+	if err != nil {
+		return nil, apperr.Internal(fmt.Errorf("marshal directory records: %w", err))
+	}
+
+	rows, err := repo.db.QueryContext(ctx, query, runID, payload, maxDeactivationRatio)
+	if err != nil {
+		logger.Error("failed to apply directory sync", "run_id", runID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var change Change
+		var details []byte
+		if err := rows.Scan(&change.UserID, &change.Username, &change.Action, &details, &change.FlaggedAssets); err != nil {
+			logger.Error("failed to scan directory sync change", "run_id", runID, "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		change.Changes = details
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to apply directory sync", "run_id", runID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return changes, nil
+}
+
+// FinishRun stores the outcome of a sync.
+func (repo *Repository) FinishRun(ctx context.Context, runID int64, status string, summary map[string]int, report []Change, errorText string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL finish_directory_sync_run($1, $2, $3, $4, $5)`
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return apperr.Internal(fmt.Errorf("marshal directory sync summary: %w", err))
+	}
+	if report == nil {
+		report = []Change{}
+	}
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return apperr.Internal(fmt.Errorf("marshal directory sync report: %w", err))
+	}
+
+	_, err = repo.db.ExecContext(ctx, query, runID, status, summaryJSON, reportJSON, errorText)
+	if err != nil {
+		logger.Error("failed to finish directory sync run", "run_id", runID, "status", status, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	return nil
+}
+
+// GetRuns retrieves the latest syncs, newest first, without their reports.
+func (repo *Repository) GetRuns(ctx context.Context, limit int) ([]Run, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_directory_sync_runs($1)`
+
+	rows, err := repo.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		logger.Error("failed to retrieve directory sync runs", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var run Run
+		var summary []byte
+		if err := rows.Scan(&run.ID, &run.Source, &run.Trigger, &run.TriggeredBy, &run.Status, &run.StartedAt, &run.FinishedAt, &summary, &run.Error); err != nil {
+			logger.Error("failed to scan directory sync run", "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		run.Summary = summary
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to retrieve directory sync runs", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return runs, nil
+}
+
+// GetRun retrieves one sync with its change report, nil when it does not exist.
+func (repo *Repository) GetRun(ctx context.Context, runID int64) (*Run, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_directory_sync_run($1)`
+
+	var run Run
+	var summary, report []byte
+	err := repo.db.QueryRowContext(ctx, query, runID).Scan(&run.ID, &run.Source, &run.Trigger, &run.TriggeredBy, &run.Status, &run.StartedAt, &run.FinishedAt, &summary, &run.Error, &report)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("failed to retrieve directory sync run", "run_id", runID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	run.Summary = summary
+	run.Report = report
+
+	return &run, nil
+}
+
+// GetOpenReassignments lists the assets waiting for a new owner because theirs left.
+func (repo *Repository) GetOpenReassignments(ctx context.Context) ([]Reassignment, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_open_asset_reassignments()`
+
+	rows, err := repo.db.QueryContext(ctx, query)
+	if err != nil {
+		logger.Error("failed to retrieve asset reassignments", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	var reassignments []Reassignment
+	for rows.Next() {
+		var reassignment Reassignment
+		if err := rows.Scan(
+			&reassignment.ID,
+			&reassignment.AssetTag,
+			&reassignment.ProductName,
+			&reassignment.SubSiteID,
+			&reassignment.DeptID,
+			&reassignment.PreviousOwnerID,
+			&reassignment.PreviousOwnerName,
+			&reassignment.SyncRunID,
+			&reassignment.FlaggedAt,
+		); err != nil {
+			logger.Error("failed to scan asset reassignment", "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		reassignments = append(reassignments, reassignment)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to retrieve asset reassignments", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return reassignments, nil
+}
@@ -0,0 +1,269 @@
+// == Synchronises users and positions from the company directory, deactivates leavers and flags their assets ==
+package directory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/jobs"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+)
+
+// vacantUserID is the placeholder owner of unassigned assets. It is not an employee and is never synced nor deactivated.
+const vacantUserID = 1
+
+// Run triggers, stored with each sync run.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+	TriggerCLI      = "cli"
+)
+
+// Errors returned by the directory sync service.
+var (
+	ErrSyncDisabled   = apperr.Conflict("directory_sync_disabled", "directory sync is not configured")
+	ErrSyncForbidden  = apperr.Forbidden("directory_sync_forbidden", "only an admin can manage the directory sync")
+	ErrRunNotFound    = apperr.NotFound("directory_sync_run_not_found", "directory sync run not found")
+	errEmptyDirectory = errors.New("the directory returned no employees")
+)
+
+type Service struct {
+	repo   *Repository
+	source Source
+	config config.DirectoryConfig
+	runner *jobs.Runner
+	audit  *audit.Service
+	logger *slog.Logger
+}
+
+// NewService creates a new directory sync service reading from the source selected in the configuration.
+func NewService(repo *Repository, directoryConfig config.DirectoryConfig, runner *jobs.Runner, auditService *audit.Service, logger *slog.Logger) *Service {
+	return &Service{
+		repo:   repo,
+		source: NewSource(directoryConfig),
+		config: directoryConfig,
+		runner: runner,
+		audit:  auditService,
+		logger: logger,
+	}
+}
+
+// Enabled reports whether a directory source is configured.
+func (service *Service) Enabled() bool {
+	return service.source != nil
+}
+
+// Schedule registers the daily sync with the job runner, if directory.schedule is set.
+func (service *Service) Schedule(location *time.Location) {
+	if !service.Enabled() || service.config.Schedule == "" {
+		return
+	}
+	service.runner.Schedule("directory_sync",
+		func(now time.Time) time.Time { return service.config.NextRun(now.In(location)) },
+		func(ctx context.Context) error {
+			_, err := service.Sync(ctx, TriggerSchedule, nil)
+			return err
+		},
+		"source", service.source.Name(),
+	)
+}
+
+// Trigger starts a sync in the background on behalf of an admin and returns its run ID right away.
+func (service *Service) Trigger(ctx context.Context, actor *audit.Actor) (int64, error) {
+	if err := authorize(actor); err != nil {
+		return 0, err
+	}
+	if !service.Enabled() {
+		return 0, ErrSyncDisabled
+	}
+
+	runID, err := service.repo.StartRun(ctx, service.source.Name(), TriggerManual, sql.NullInt64{Int64: actor.UserID, Valid: true})
+	if err != nil {
+		return 0, err
+	}
+
+	err = service.runner.Submit("directory_sync", func(jobCtx context.Context) error {
+		return service.run(service.audit.Background(jobCtx, actor), runID)
+	}, "run_id", runID)
+	if err != nil {
+		service.finish(ctx, runID, "failed", nil, nil, err)
+		return 0, apperr.Internal(err)
+	}
+
+	return runID, nil
+}
+
+// Sync runs a sync to completion and returns it with its report. actor is nil for the scheduler and the CLI.
+func (service *Service) Sync(ctx context.Context, trigger string, actor *audit.Actor) (*Run, error) {
+	if !service.Enabled() {
+		return nil, ErrSyncDisabled
+	}
+
+	var triggeredBy sql.NullInt64
+	if actor != nil {
+		triggeredBy = sql.NullInt64{Int64: actor.UserID, Valid: true}
+	}
+	runID, err := service.repo.StartRun(ctx, service.source.Name(), trigger, triggeredBy)
+	if err != nil {
+		return nil, err
+	}
+
+	syncErr := service.run(service.audit.Background(ctx, actor), runID)
+	run, err := service.repo.GetRun(context.WithoutCancel(ctx), runID)
+	if err != nil {
+		return nil, err
+	}
+	return run, syncErr
+}
+
+// run fetches the employees, applies them and stores the outcome of the run.
+func (service *Service) run(ctx context.Context, runID int64) error {
+	logger := logging.FromContext(ctx, service.logger).With("run_id", runID, "source", service.source.Name())
+	start := time.Now()
+
+	records, err := service.source.Fetch(ctx)
+	if err == nil && len(records) == 0 {
+		// An empty export would deactivate everyone; the ratio guard would catch it, but the cause is clearer here.
+		err = errEmptyDirectory
+	}
+	if err != nil {
+		logger.Error("failed to read the directory", "error", err)
+		service.finish(ctx, runID, "failed", nil, nil, err)
+		return err
+	}
+	checkRecords(records)
+
+	changes, err := service.repo.ApplySync(ctx, runID, records, service.config.MaxDeactivationRatio)
+	if err != nil {
+		logger.Error("failed to apply the directory", "error", err)
+		service.finish(ctx, runID, "failed", nil, nil, err)
+		return err
+	}
+
+	summary := make(map[string]int)
+	report := make([]Change, 0, len(changes))
+	flaggedAssets := 0
+	for _, change := range changes {
+		summary[change.Action]++
+		flaggedAssets += change.FlaggedAssets
+		if change.Action != "unchanged" {
+			report = append(report, change)
+		}
+	}
+	summary["flagged_assets"] = flaggedAssets
+
+	if err := service.finish(ctx, runID, "succeeded", summary, report, nil); err != nil {
+		return err
+	}
+	logger.Info("directory sync finished", "elapsed_ms", time.Since(start).Milliseconds(), "records", len(records),
+		"created", summary["created"], "updated", summary["updated"], "reactivated", summary["reactivated"],
+		"deactivated", summary["deactivated"], "skipped", summary["skipped"], "flagged_assets", flaggedAssets)
+
+	runEntityID := strconv.FormatInt(runID, 10)
+	audit.Record(ctx, audit.Event{
+		Action:     "directory.sync",
+		EntityType: "directory_sync_run",
+		EntityID:   runEntityID,
+		After:      summary,
+	})
+	for _, change := range report {
+		if change.Action != "deactivated" {
+			continue
+		}
+		audit.Record(ctx, audit.Event{
+			Action:     "user.deactivate",
+			EntityType: "user",
+			EntityID:   change.Username,
+			Before:     map[string]any{"is_active": true},
+			After:      map[string]any{"is_active": false, "flagged_assets": change.FlaggedAssets, "sync_run_id": runID},
+		})
+	}
+	return nil
+}
+
+// finish stores the outcome of a run. It outlives ctx, so a run cancelled on shutdown is still marked failed.
+func (service *Service) finish(ctx context.Context, runID int64, status string, summary map[string]int, report []Change, runErr error) error {
+	errorText := ""
+	if runErr != nil {
+		errorText = runErr.Error()
+	}
+	if summary == nil {
+		summary = map[string]int{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	return service.repo.FinishRun(ctx, runID, status, summary, report, errorText)
+}
+
+// checkRecords normalizes the records and marks the ones that cannot be applied, so they are reported instead.
+func checkRecords(records []Record) {
+	userIDs := make(map[int64]bool, len(records))
+	usernames := make(map[string]bool, len(records))
+	for i := range records {
+		record := &records[i]
+		record.normalize()
+		if err := record.validate(); err != nil {
+			record.SkipReason = err.Error()
+			continue
+		}
+
+		username := strings.ToLower(record.Username)
+		switch {
+		case userIDs[record.UserID]:
+			record.SkipReason = "duplicate user_id in the directory"
+		case usernames[username]:
+			record.SkipReason = "duplicate username in the directory"
+		}
+		userIDs[record.UserID] = true
+		usernames[username] = true
+	}
+}
+
+// GetRuns lists the latest syncs, newest first.
+func (service *Service) GetRuns(ctx context.Context, actor *audit.Actor, limit int) ([]Run, error) {
+	if err := authorize(actor); err != nil {
+		return nil, err
+	}
+	return service.repo.GetRuns(ctx, limit)
+}
+
+// GetRun retrieves one sync with its change report.
+func (service *Service) GetRun(ctx context.Context, actor *audit.Actor, runID int64) (*Run, error) {
+	if err := authorize(actor); err != nil {
+		return nil, err
+	}
+	run, err := service.repo.GetRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, ErrRunNotFound
+	}
+	return run, nil
+}
+
+// GetOpenReassignments lists the assets whose owner left and that still need a new owner.
+func (service *Service) GetOpenReassignments(ctx context.Context, actor *audit.Actor) ([]Reassignment, error) {
+	if err := authorize(actor); err != nil {
+		return nil, err
+	}
+	return service.repo.GetOpenReassignments(ctx)
+}
+
+// authorize allows the admin positions only, who administer the users.
+func authorize(actor *audit.Actor) error {
+	if actor == nil || !roles.IsAdmin(actor.Position) {
+		return ErrSyncForbidden
+	}
+	return nil
+}
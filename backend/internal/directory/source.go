@@ -0,0 +1,73 @@
+// == Reads the employees from the company directory: the nightly HR CSV drop or the LDAP server ==
+package directory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+)
+
+// Record is one employee as the directory knows them. It is sent to apply_directory_sync as JSON.
+type Record struct {
+	UserID       int64  `json:"user_id"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Position     string `json:"position"`
+	Department   string `json:"department"`
+	Division     string `json:"division"`
+	SiteID       *int64 `json:"site_id"`
+	CostCenterID *int64 `json:"cost_center_id"`
+	OuCode       string `json:"ou_code"`
+	SkipReason   string `json:"skip_reason,omitempty"` // Set by the service for records it rejects; they are reported, not applied
+}
+
+// Source lists every current employee.
+type Source interface {
+	Name() string // "csv" or "ldap", stored with each sync run
+	Fetch(ctx context.Context) ([]Record, error)
+}
+
+// NewSource returns the source selected by directory.source, nil when the sync is disabled.
+func NewSource(directoryConfig config.DirectoryConfig) Source {
+	switch directoryConfig.Source {
+	case "csv":
+		return &csvSource{path: directoryConfig.CSVPath}
+	case "ldap":
+		return &ldapSource{config: directoryConfig.LDAP}
+	}
+	return nil
+}
+
+// normalize trims every field and upper-cases the position, which access control compares case-insensitively
+// but reports and the seed data always show in capitals.
+func (record *Record) normalize() {
+	record.Username = strings.TrimSpace(record.Username)
+	record.Email = strings.TrimSpace(record.Email)
+	record.FirstName = strings.TrimSpace(record.FirstName)
+	record.LastName = strings.TrimSpace(record.LastName)
+	record.Position = strings.ToUpper(strings.TrimSpace(record.Position))
+	record.Department = strings.TrimSpace(record.Department)
+	record.Division = strings.TrimSpace(record.Division)
+	record.OuCode = strings.TrimSpace(record.OuCode)
+}
+
+// validate reports why a record cannot be synced, nil when it can.
+func (record *Record) validate() error {
+	switch {
+	case record.UserID <= 0:
+		return fmt.Errorf("user_id must be a positive number")
+	case record.UserID == vacantUserID:
+		return fmt.Errorf("user_id %d is reserved for the VACANT placeholder", vacantUserID)
+	case record.Username == "":
+		return fmt.Errorf("username is required")
+	case strings.EqualFold(record.Username, "vacant"):
+		return fmt.Errorf("username VACANT is reserved")
+	case len(record.OuCode) > 5:
+		return fmt.Errorf("ou_code %q is longer than 5 characters", record.OuCode)
+	}
+	return nil
+}
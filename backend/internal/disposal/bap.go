@@ -0,0 +1,117 @@
+// == Prints the write-off document (Berita Acara Penghapusan Aset) of an approved disposal ==
+package disposal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/report"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+
+	"github.com/SebastiaanKlippert/go-wkhtmltopdf"
+)
+
+// bapPDFOptions is the portrait A4 setup used by the disposal BAP.
+var bapPDFOptions = report.PDFOptions{
+	Orientation:  wkhtmltopdf.OrientationPortrait,
+	PageSize:     wkhtmltopdf.PageSizeA4,
+	MarginTop:    15,
+	MarginBottom: 15,
+	MarginLeft:   15,
+	MarginRight:  15,
+}
+
+// reasonLabels are the reasons as printed on the disposal BAP.
+var reasonLabels = map[string]string{
+	"Lost":     "Hilang (tidak ditemukan saat stock opname)",
+	"Obsolete": "Usang / tidak layak pakai",
+}
+
+// bapItem is an asset as printed on the disposal BAP.
+type bapItem struct {
+	No              int
+	AssetTag        string
+	SerialNumber    string
+	AssetName       string
+	ProductVariety  string
+	SiteName        string
+	AcquisitionCost string
+	BookValue       string
+}
+
+// GenerateBAPPDF renders the BAP of an approved disposal and returns the PDF bytes and a filename.
+func (service *Service) GenerateBAPPDF(ctx context.Context, disposalID int64) ([]byte, string, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	disposal, err := service.GetDisposalByID(ctx, disposalID)
+	if err != nil {
+		return nil, "", err
+	}
+	if disposal.Status != "Approved" || !disposal.ReviewedAt.Valid {
+		return nil, "", ErrNotApproved
+	}
+
+	items := make([]bapItem, 0, len(disposal.Items))
+	for i, item := range disposal.Items {
+		items = append(items, bapItem{
+			No:              i + 1,
+			AssetTag:        item.AssetTag,
+			SerialNumber:    item.SerialNumber,
+			AssetName:       item.BrandName + " " + item.ProductName,
+			ProductVariety:  item.ProductVariety,
+			SiteName:        utils.SafeString(item.SiteName),
+			AcquisitionCost: utils.FormatRupiah(item.AcquisitionCost),
+			BookValue:       utils.FormatRupiah(item.BookValue),
+		})
+	}
+
+	session := "-"
+	if disposal.SessionID.Valid {
+		session = "#" + strconv.FormatInt(disposal.SessionID.Int64, 10)
+	}
+
+	approvedAt := disposal.ReviewedAt.Time.In(service.location)
+	data := struct {
+		DocumentNumber       string
+		Date                 string
+		Time                 string
+		Reason               string
+		Session              string
+		Notes                string
+		Items                []bapItem
+		TotalAcquisitionCost string
+		TotalBookValue       string
+		RequesterName        string
+		ApproverName         string
+		ReviewNotes          string
+	}{
+		DocumentNumber:       fmt.Sprintf("BAP-DSP/%05d/%s", disposal.ID, approvedAt.Format("01/2006")),
+		Date:                 approvedAt.Format("02-01-2006"),
+		Time:                 approvedAt.Format("15:04"),
+		Reason:               reasonLabels[disposal.Reason],
+		Session:              session,
+		Notes:                disposal.Notes,
+		Items:                items,
+		TotalAcquisitionCost: utils.FormatRupiah(disposal.TotalAcquisitionCost),
+		TotalBookValue:       utils.FormatRupiah(disposal.TotalBookValue),
+		RequesterName:        disposal.RequesterName,
+		ApproverName:         disposal.ReviewerName.String,
+		ReviewNotes:          disposal.ReviewNotes,
+	}
+
+	html, err := service.reportService.RenderTemplate("asset_disposal_bap.html", data)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to render template: %w", err)
+	}
+
+	pdfBytes, err := service.reportService.RenderPDF(ctx, html, bapPDFOptions)
+	if err != nil {
+		return nil, "", err
+	}
+
+	logger.Debug("rendered disposal BAP PDF", "disposal_id", disposalID, "size_bytes", len(pdfBytes))
+	filename := fmt.Sprintf("BAP_penghapusan_%d.pdf", disposal.ID)
+	return pdfBytes, filename, nil
+}
@@ -0,0 +1,242 @@
+// == Handles API requests related to asset disposals ==
+package disposal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errMissingUser = apperr.Unauthorized("unauthorized", "user not found in context")
+
+// reviewAction is a service method closing a pending disposal: approve, reject or cancel.
+type reviewAction func(ctx context.Context, actor roles.Actor, disposalID int64, notes string) (*Disposal, error)
+
+type Handler struct {
+	service *Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates a new asset disposal handler.
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetDisposalsHandler lists disposals, filterable by status, reason and session_id.
+func (handler *Handler) GetDisposalsHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	var filter Filter
+	if err := context.ShouldBindQuery(&filter); err != nil {
+		logger.Warn("invalid asset disposal filter", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_query", "invalid query parameters: "+err.Error()))
+		return
+	}
+
+	disposals, totalCount, err := handler.service.GetDisposals(context.Request.Context(), filter)
+	if err != nil {
+		logger.Warn("failed to retrieve asset disposals", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	serialized := make([]gin.H, 0, len(disposals))
+	for _, disposal := range disposals {
+		serialized = append(serialized, serializeDisposal(disposal))
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"disposals":   serialized,
+		"total_count": totalCount,
+	})
+}
+
+// GetDisposalByIDHandler retrieves a disposal with its assets.
+func (handler *Handler) GetDisposalByIDHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	disposalID, ok := disposalIDParam(context)
+	if !ok {
+		return
+	}
+
+	disposal, err := handler.service.GetDisposalByID(context.Request.Context(), disposalID)
+	if err != nil {
+		logger.Warn("failed to retrieve asset disposal", "disposal_id", disposalID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, serializeDisposal(disposal))
+}
+
+// RequestDisposalHandler requests the write-off of lost or obsolete assets. Only an admin may do this.
+func (handler *Handler) RequestDisposalHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
+		return
+	}
+
+	var request DisposalRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid asset disposal request", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	created, err := handler.service.RequestDisposal(context.Request.Context(), actor, request)
+	if err != nil {
+		logger.Warn("failed to request asset disposal", "reason", request.Reason, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{
+		"message":  "asset disposal requested successfully",
+		"disposal": serializeDisposal(created),
+	})
+}
+
+// ApproveDisposalHandler approves a pending disposal and disposes of its assets. Only finance may do this.
+func (handler *Handler) ApproveDisposalHandler(context *gin.Context) {
+	handler.review(context, "approved", handler.service.ApproveDisposal)
+}
+
+// RejectDisposalHandler rejects a pending disposal. Only finance may do this.
+func (handler *Handler) RejectDisposalHandler(context *gin.Context) {
+	handler.review(context, "rejected", handler.service.RejectDisposal)
+}
+
+// CancelDisposalHandler withdraws a pending disposal. Only its requester or an admin may do this.
+func (handler *Handler) CancelDisposalHandler(context *gin.Context) {
+	handler.review(context, "cancelled", handler.service.CancelDisposal)
+}
+
+// GenerateBAPHandler downloads the write-off document (BAP) of an approved disposal as a PDF.
+func (handler *Handler) GenerateBAPHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	disposalID, ok := disposalIDParam(context)
+	if !ok {
+		return
+	}
+
+	pdfBytes, filename, err := handler.service.GenerateBAPPDF(context.Request.Context(), disposalID)
+	if err != nil {
+		logger.Error("failed to generate disposal BAP", "disposal_id", disposalID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.Header("Content-Disposition", "attachment; filename="+filename)
+	context.Data(http.StatusOK, "application/pdf", pdfBytes)
+}
+
+// review closes a pending disposal through one of the review actions. The notes body is optional.
+func (handler *Handler) review(context *gin.Context, outcome string, action reviewAction) {
+	logger := logging.FromGin(context, handler.logger)
+
+	disposalID, ok := disposalIDParam(context)
+	if !ok {
+		return
+	}
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
+		return
+	}
+
+	var request ReviewRequest
+	if err := context.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		logger.Warn("invalid asset disposal review request", "disposal_id", disposalID, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	disposal, err := action(context.Request.Context(), actor, disposalID, request.Notes)
+	if err != nil {
+		logger.Warn("failed to review asset disposal", "disposal_id", disposalID, "outcome", outcome, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	logger.Info("reviewed asset disposal", "disposal_id", disposalID, "outcome", outcome)
+	context.JSON(http.StatusOK, gin.H{
+		"message":  "asset disposal " + outcome + " successfully",
+		"disposal": serializeDisposal(disposal),
+	})
+}
+
+// serializeDisposal converts a disposal to its JSON form. Items are only included when they were loaded.
+func serializeDisposal(disposal *Disposal) gin.H {
+	var reviewedAt any
+	if disposal.ReviewedAt.Valid {
+		reviewedAt = disposal.ReviewedAt.Time
+	}
+	serialized := gin.H{
+		"id":                     disposal.ID,
+		"reason":                 disposal.Reason,
+		"session_id":             utils.SerializeNI(disposal.SessionID),
+		"notes":                  disposal.Notes,
+		"status":                 disposal.Status,
+		"requested_by":           disposal.RequestedBy,
+		"requester_name":         disposal.RequesterName,
+		"requested_at":           disposal.RequestedAt,
+		"reviewed_by":            utils.SerializeNI(disposal.ReviewedBy),
+		"reviewer_name":          utils.SerializeNS(disposal.ReviewerName),
+		"reviewed_at":            reviewedAt,
+		"review_notes":           disposal.ReviewNotes,
+		"item_count":             disposal.ItemCount,
+		"total_acquisition_cost": disposal.TotalAcquisitionCost,
+		"total_book_value":       disposal.TotalBookValue,
+	}
+	if disposal.Items != nil {
+		items := make([]gin.H, 0, len(disposal.Items))
+		for _, item := range disposal.Items {
+			var acquisitionDate any
+			if item.AcquisitionDate.Valid {
+				acquisitionDate = item.AcquisitionDate.Time.Format(time.DateOnly)
+			}
+			items = append(items, gin.H{
+				"asset_tag":        item.AssetTag,
+				"serial_number":    item.SerialNumber,
+				"brand_name":       item.BrandName,
+				"product_name":     item.ProductName,
+				"product_variety":  item.ProductVariety,
+				"site_name":        utils.SerializeNS(item.SiteName),
+				"asset_status":     item.AssetStatus,
+				"acquisition_cost": item.AcquisitionCost,
+				"book_value":       item.BookValue,
+				"acquisition_date": acquisitionDate,
+			})
+		}
+		serialized["items"] = items
+	}
+	return serialized
+}
+
+// disposalIDParam parses the :disposal-id route parameter, aborting the request when it is invalid.
+func disposalIDParam(context *gin.Context) (int64, bool) {
+	disposalID, err := strconv.ParseInt(context.Param("disposal-id"), 10, 64)
+	if err != nil || disposalID <= 0 {
+		apperr.Abort(context, apperr.Validation("invalid_disposal_id", "invalid disposal_id format"))
+		return 0, false
+	}
+	return disposalID, true
+}
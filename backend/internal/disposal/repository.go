@@ -0,0 +1,281 @@
+// == Handles all database operations related to asset disposals ==
+package disposal
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
+	"github.com/lib/pq"
+)
+
+// Disposal is a batch of assets written off together, with the totals of the values recorded when it was requested.
+type Disposal struct {
+	ID                   int64
+	Reason               string        // "Lost" or "Obsolete", the status reason of the disposed assets
+	SessionID            sql.NullInt64 // The verified opname session lost assets were missing in
+	Notes                string
+	Status               string // "Pending", "Approved", "Rejected" or "Cancelled"
+	RequestedBy          int64
+	RequesterName        string
+	RequestedAt          time.Time
+	ReviewedBy           sql.NullInt64 // The finance reviewer who approved or rejected the disposal, or the user who cancelled it
+	ReviewerName         sql.NullString
+	ReviewedAt           sql.NullTime
+	ReviewNotes          string
+	ItemCount            int
+	TotalAcquisitionCost int64   // In rupiah
+	TotalBookValue       int64   // In rupiah
+	Items                []*Item // Only loaded for a single disposal
+}
+
+// Item is an asset of a disposal. Its values are in rupiah, as of the request.
+type Item struct {
+	AssetTag           string
+	SerialNumber       string
+	BrandName          string
+	ProductName        string
+	ProductVariety     string
+	SiteName           sql.NullString
+	AssetStatus        string
+	AcquisitionCost    int64
+	BookValue          int64
+	AcquisitionDate    sql.NullTime   // The book value is computed from the acquisition date
+	DepreciationMethod sql.NullString // and the depreciation policy of the product variety
+	UsefulLifeMonths   sql.NullInt64
+}
+
+// queryer runs queries on the database or inside a transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// DisposalRequest is the body of POST /api/disposal. Lost assets come from the missing assets of SessionID, all of them
+// when AssetTags is empty; obsolete assets are listed in AssetTags.
+type DisposalRequest struct {
+	Reason    string   `json:"reason" binding:"required"`
+	SessionID *int64   `json:"session_id"`
+	AssetTags []string `json:"asset_tags"`
+	Notes     string   `json:"notes"`
+}
+
+// ReviewRequest is the optional body of the approve, reject and cancel endpoints.
+type ReviewRequest struct {
+	Notes string `json:"notes"`
+}
+
+// Filter narrows down GET /api/disposal. Every field is optional.
+type Filter struct {
+	Status    *string `json:"status" form:"status"`
+	Reason    *string `json:"reason" form:"reason"`
+	SessionID *int    `json:"session_id" form:"session_id"`
+	Limit     *int    `json:"limit" form:"limit"`
+	PageNum   *int    `json:"page_num" form:"page_num"`
+}
+
+type Repository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewRepository creates a new asset disposal repository.
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetDisposals retrieves the disposals matching the filter, newest first, along with the total number of matches.
+func (repo *Repository) GetDisposals(ctx context.Context, filter Filter) ([]*Disposal, int64, error) {
+	return repo.queryDisposals(ctx, nil, filter)
+}
+
+// GetDisposalByID retrieves a disposal without its items, or nil if it does not exist.
+func (repo *Repository) GetDisposalByID(ctx context.Context, disposalID int64) (*Disposal, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	disposals, _, err := repo.queryDisposals(ctx, &disposalID, Filter{})
+	if err != nil {
+		return nil, err
+	}
+	if len(disposals) == 0 {
+		logger.Debug("no asset disposal found", "disposal_id", disposalID)
+		return nil, nil
+	}
+	return disposals[0], nil
+}
+
+// queryDisposals runs get_disposal_requests, for a single disposal when disposalID is set.
+func (repo *Repository) queryDisposals(ctx context.Context, disposalID *int64, filter Filter) ([]*Disposal, int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_disposal_requests($1, $2, $3, $4, $5, $6)`
+
+	rows, err := repo.db.QueryContext(ctx, query, disposalID, filter.Status, filter.Reason, filter.SessionID, filter.Limit, filter.PageNum)
+	if err != nil {
+		logger.Error("failed to query asset disposals", "error", err)
+		return nil, 0, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	disposals := make([]*Disposal, 0)
+	var totalCount int64
+	for rows.Next() {
+		var disposal Disposal
+		if err := rows.Scan(
+			&disposal.ID,
+			&disposal.Reason,
+			&disposal.SessionID,
+			&disposal.Notes,
+			&disposal.Status,
+			&disposal.RequestedBy,
+			&disposal.RequesterName,
+			&disposal.RequestedAt,
+			&disposal.ReviewedBy,
+			&disposal.ReviewerName,
+			&disposal.ReviewedAt,
+			&disposal.ReviewNotes,
+			&disposal.ItemCount,
+			&disposal.TotalAcquisitionCost,
+			&disposal.TotalBookValue,
+			&totalCount,
+		); err != nil {
+			logger.Error("failed to scan asset disposal row", "error", err)
+			return nil, 0, apperr.FromPostgres(err)
+		}
+		disposals = append(disposals, &disposal)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating asset disposal rows", "error", err)
+		return nil, 0, apperr.FromPostgres(err)
+	}
+
+	return disposals, totalCount, nil
+}
+
+// GetItems retrieves the assets of a disposal, by site then asset tag.
+func (repo *Repository) GetItems(ctx context.Context, disposalID int64) ([]*Item, error) {
+	return repo.queryItems(ctx, repo.db, disposalID)
+}
+
+// queryItems runs get_disposal_items on the database or inside a transaction.
+func (repo *Repository) queryItems(ctx context.Context, db queryer, disposalID int64) ([]*Item, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_disposal_items($1)`
+
+	rows, err := db.QueryContext(ctx, query, disposalID)
+	if err != nil {
+		logger.Error("failed to query asset disposal items", "disposal_id", disposalID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	items := make([]*Item, 0)
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(
+			&item.AssetTag,
+			&item.SerialNumber,
+			&item.BrandName,
+			&item.ProductName,
+			&item.ProductVariety,
+			&item.SiteName,
+			&item.AssetStatus,
+			&item.AcquisitionCost,
+			&item.BookValue,
+			&item.AcquisitionDate,
+			&item.DepreciationMethod,
+			&item.UsefulLifeMonths,
+		); err != nil {
+			logger.Error("failed to scan asset disposal item row", "disposal_id", disposalID, "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		items = append(items, &item)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating asset disposal item rows", "disposal_id", disposalID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return items, nil
+}
+
+// CreateDisposal records a pending disposal and returns its ID. The book value of every asset is computed by valuate and
+// recorded in the same transaction.
+func (repo *Repository) CreateDisposal(ctx context.Context, requestedBy int64, request DisposalRequest, valuate func(item *Item) int64) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to begin asset disposal transaction", "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+	defer tx.Rollback()
+
+	var disposalID int64
+	query := `SELECT create_disposal_request($1, $2, $3, $4, $5)`
+	err = tx.QueryRowContext(ctx, query, request.Reason, request.SessionID, pq.Array(request.AssetTags), request.Notes, requestedBy).Scan(&disposalID)
+	if err != nil {
+		logger.Warn("failed to request asset disposal", "reason", request.Reason, "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+
+	items, err := repo.queryItems(ctx, tx, disposalID)
+	if err != nil {
+		return 0, err
+	}
+	assetTags := make([]string, 0, len(items))
+	bookValues := make([]int64, 0, len(items))
+	for _, item := range items {
+		assetTags = append(assetTags, item.AssetTag)
+		bookValues = append(bookValues, valuate(item))
+	}
+	if _, err := tx.ExecContext(ctx, `CALL set_disposal_book_values($1, $2, $3)`, disposalID, pq.Array(assetTags), pq.Array(bookValues)); err != nil {
+		logger.Error("failed to record asset disposal book values", "disposal_id", disposalID, "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("failed to commit asset disposal", "disposal_id", disposalID, "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+
+	logger.Info("requested asset disposal", "disposal_id", disposalID, "reason", request.Reason)
+	return disposalID, nil
+}
+
+// ApproveDisposal approves a pending disposal and disposes of all its assets in the same transaction.
+func (repo *Repository) ApproveDisposal(ctx context.Context, disposalID int64, reviewerID int64, notes string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL approve_disposal_request($1, $2, $3)`
+
+	if _, err := repo.db.ExecContext(ctx, query, disposalID, reviewerID, notes); err != nil {
+		logger.Warn("failed to approve asset disposal", "disposal_id", disposalID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("approved asset disposal", "disposal_id", disposalID, "reviewer_id", reviewerID)
+	return nil
+}
+
+// CloseDisposal rejects or cancels a pending disposal. status is "Rejected" or "Cancelled".
+func (repo *Repository) CloseDisposal(ctx context.Context, disposalID int64, status string, reviewerID int64, notes string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL close_disposal_request($1, $2, $3, $4)`
+
+	if _, err := repo.db.ExecContext(ctx, query, disposalID, status, reviewerID, notes); err != nil {
+		logger.Warn("failed to close asset disposal", "disposal_id", disposalID, "status", status, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("closed asset disposal", "disposal_id", disposalID, "status", status, "reviewer_id", reviewerID)
+	return nil
+}
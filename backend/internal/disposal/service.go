@@ -0,0 +1,232 @@
+// == Handles all logical operations related to asset disposals (penghapusan aset) ==
+// == An admin requests the write-off of lost or obsolete assets, finance approves it and every asset is disposed of at once ==
+package disposal
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/depreciation"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/report"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+)
+
+// Errors returned by the disposal workflow, in addition to the ones translated from the database.
+var (
+	ErrDisposalNotFound = apperr.NotFound("disposal_not_found", "disposal request not found")
+	ErrNotPending       = apperr.Conflict("disposal_not_pending", "the disposal request is no longer pending")
+	ErrNotApproved      = apperr.Conflict("disposal_not_approved", "the disposal BAP is only available once the disposal is approved")
+	ErrNotRequester     = apperr.Forbidden("disposal_request_forbidden", "only an admin can request the disposal of assets")
+	ErrNotFinance       = apperr.Forbidden("disposal_review_forbidden", "only finance can review a disposal request")
+	ErrNotCancellable   = apperr.Forbidden("disposal_cancel_forbidden", "only the requester or an admin can cancel this disposal request")
+	ErrInvalidReason    = apperr.Validation("invalid_disposal_reason", "reason must be Lost or Obsolete")
+	ErrInvalidStatus    = apperr.Validation("invalid_query", "status must be one of Pending, Approved, Rejected or Cancelled")
+)
+
+// statuses are the states of a disposal, Pending until it is approved, rejected or cancelled.
+var statuses = []string{"Pending", "Approved", "Rejected", "Cancelled"}
+
+// reasons are the status reasons a disposal gives its assets.
+var reasons = []string{"Lost", "Obsolete"}
+
+type Service struct {
+	repo          *Repository
+	reportService *report.Service
+	config        config.DisposalConfig
+	location      *time.Location // Timezone of the dates printed on the disposal BAP
+	transitions   audit.Transitions[*Disposal]
+	logger        *slog.Logger
+}
+
+// NewService creates a new asset disposal service. The report service provides the PDF pipeline used to print the disposal BAP.
+func NewService(repo *Repository, reportService *report.Service, disposalConfig config.DisposalConfig, location *time.Location, logger *slog.Logger) *Service {
+	service := &Service{
+		repo:          repo,
+		reportService: reportService,
+		config:        disposalConfig,
+		location:      location,
+		logger:        logger,
+	}
+	service.transitions = audit.Transitions[*Disposal]{EntityType: "asset_disposal", Reload: service.GetDisposalByID, Snapshot: disposalSnapshot}
+	return service
+}
+
+// GetDisposals retrieves the disposals matching the filter, newest first, along with the total number of matches.
+func (service *Service) GetDisposals(ctx context.Context, filter Filter) ([]*Disposal, int64, error) {
+	if filter.Status != nil && *filter.Status != "" {
+		status, ok := utils.MatchFold(statuses, *filter.Status)
+		if !ok {
+			return nil, 0, ErrInvalidStatus
+		}
+		filter.Status = &status
+	}
+	if filter.Reason != nil && *filter.Reason != "" {
+		reason, ok := utils.MatchFold(reasons, *filter.Reason)
+		if !ok {
+			return nil, 0, ErrInvalidReason
+		}
+		filter.Reason = &reason
+	}
+	return service.repo.GetDisposals(ctx, filter)
+}
+
+// GetDisposalByID retrieves a disposal with its assets.
+func (service *Service) GetDisposalByID(ctx context.Context, disposalID int64) (*Disposal, error) {
+	disposal, err := service.repo.GetDisposalByID(ctx, disposalID)
+	if err != nil {
+		return nil, err
+	}
+	if disposal == nil {
+		return nil, ErrDisposalNotFound
+	}
+
+	disposal.Items, err = service.repo.GetItems(ctx, disposalID)
+	if err != nil {
+		return nil, err
+	}
+	return disposal, nil
+}
+
+// RequestDisposal records a pending disposal on behalf of an admin and returns it. The book value of every asset is
+// recorded now, after depreciation.
+func (service *Service) RequestDisposal(ctx context.Context, actor roles.Actor, request DisposalRequest) (*Disposal, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !actor.IsAdmin() {
+		logger.Warn("asset disposal request denied", "user_id", actor.UserID, "position", actor.Position)
+		return nil, ErrNotRequester
+	}
+
+	reason, ok := utils.MatchFold(reasons, strings.TrimSpace(request.Reason))
+	if !ok {
+		return nil, ErrInvalidReason
+	}
+	request.Reason = reason
+	request.Notes = strings.TrimSpace(request.Notes)
+	assetTags := make([]string, 0, len(request.AssetTags))
+	for _, assetTag := range request.AssetTags {
+		if assetTag = strings.TrimSpace(assetTag); assetTag != "" {
+			assetTags = append(assetTags, assetTag)
+		}
+	}
+	request.AssetTags = assetTags
+
+	requestedAt := time.Now()
+	disposalID, err := service.repo.CreateDisposal(ctx, actor.UserID, request, func(item *Item) int64 {
+		valuation := depreciation.Valuation{
+			Cost:             item.AcquisitionCost,
+			AcquisitionDate:  item.AcquisitionDate,
+			Method:           item.DepreciationMethod,
+			UsefulLifeMonths: item.UsefulLifeMonths,
+		}
+		return valuation.BookValue(requestedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	created, err := service.GetDisposalByID(ctx, disposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "asset_disposal.request",
+		EntityType: "asset_disposal",
+		EntityID:   strconv.FormatInt(disposalID, 10),
+		After:      disposalSnapshot(created),
+	})
+	return created, nil
+}
+
+// ApproveDisposal approves a pending disposal on behalf of finance. All its assets become "Disposed" with the disposal's reason
+// in one transaction, or none of them if one can no longer be disposed of.
+func (service *Service) ApproveDisposal(ctx context.Context, actor roles.Actor, disposalID int64, notes string) (*Disposal, error) {
+	before, err := service.reviewable(ctx, actor, "approve", disposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.repo.ApproveDisposal(ctx, disposalID, actor.UserID, strings.TrimSpace(notes)); err != nil {
+		return nil, err
+	}
+	return service.transitions.Record(ctx, "asset_disposal.approve", before.ID, before)
+}
+
+// RejectDisposal rejects a pending disposal on behalf of finance. The assets are left as they are.
+func (service *Service) RejectDisposal(ctx context.Context, actor roles.Actor, disposalID int64, notes string) (*Disposal, error) {
+	before, err := service.reviewable(ctx, actor, "reject", disposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.repo.CloseDisposal(ctx, disposalID, "Rejected", actor.UserID, strings.TrimSpace(notes)); err != nil {
+		return nil, err
+	}
+	return service.transitions.Record(ctx, "asset_disposal.reject", before.ID, before)
+}
+
+// CancelDisposal withdraws a pending disposal on behalf of its requester or an admin.
+func (service *Service) CancelDisposal(ctx context.Context, actor roles.Actor, disposalID int64, notes string) (*Disposal, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	before, err := service.GetDisposalByID(ctx, disposalID)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != "Pending" {
+		return nil, ErrNotPending
+	}
+	if before.RequestedBy != actor.UserID && !actor.IsAdmin() {
+		logger.Warn("asset disposal cancellation denied", "user_id", actor.UserID, "position", actor.Position, "disposal_id", disposalID)
+		return nil, ErrNotCancellable
+	}
+
+	if err := service.repo.CloseDisposal(ctx, disposalID, "Cancelled", actor.UserID, strings.TrimSpace(notes)); err != nil {
+		return nil, err
+	}
+	return service.transitions.Record(ctx, "asset_disposal.cancel", before.ID, before)
+}
+
+// reviewable loads a disposal and checks that it is pending and that the actor is finance.
+func (service *Service) reviewable(ctx context.Context, actor roles.Actor, action string, disposalID int64) (*Disposal, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	disposal, err := service.GetDisposalByID(ctx, disposalID)
+	if err != nil {
+		return nil, err
+	}
+	if disposal.Status != "Pending" {
+		return nil, ErrNotPending
+	}
+	if !service.config.IsFinance(actor.Position) {
+		logger.Warn("asset disposal review denied", "action", action, "user_id", actor.UserID, "position", actor.Position, "disposal_id", disposalID)
+		return nil, ErrNotFinance
+	}
+	return disposal, nil
+}
+
+// disposalSnapshot is the audited state of a disposal.
+func disposalSnapshot(disposal *Disposal) map[string]any {
+	assetTags := make([]string, 0, len(disposal.Items))
+	for _, item := range disposal.Items {
+		assetTags = append(assetTags, item.AssetTag)
+	}
+	return map[string]any{
+		"reason":           disposal.Reason,
+		"session_id":       utils.SerializeNI(disposal.SessionID),
+		"status":           disposal.Status,
+		"requested_by":     disposal.RequestedBy,
+		"asset_tags":       assetTags,
+		"total_book_value": disposal.TotalBookValue,
+		"reviewed_by":      utils.SerializeNI(disposal.ReviewedBy),
+		"review_notes":     disposal.ReviewNotes,
+	}
+}
@@ -2,14 +2,16 @@
 package email
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
-	"html/template"
-	"log"
-	"os"
-	"path/filepath"
+	"fmt"
+	"log/slog"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/metrics"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/templates"
 
-	"github.com/joho/godotenv"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 )
@@ -17,6 +19,8 @@ import (
 type Service struct {
 	sendgridKey string
 	senderEmail string
+	templates   *templates.Set
+	logger      *slog.Logger
 }
 
 type EmailData struct {
@@ -26,6 +30,17 @@ type EmailData struct {
 	CompletedDate    string
 	VerificationLink string
 	PageLink         string
+
+	// Asset loan reminders
+	Borrower  string
+	Lender    string
+	AssetTag  string
+	AssetName string
+	DueDate   string
+
+	// Opname loss approvals, IDR formatted
+	LossValue     string
+	LossThreshold string
 }
 
 // Attachment represents a file attachment (e.g., PDF) to send.
@@ -36,51 +51,36 @@ type Attachment struct {
 }
 
 // NewService creates a new email service with the provided SendGrid API key and sender email.
-func NewService() *Service {
-	err := godotenv.Load()
-	if err != nil {
-		log.Printf("⚠️ Warning: Could not load .env file (this is normal in Docker): %v", err)
-	}
-
-	apiKey := os.Getenv("SENDGRID_API_KEY")
-	sender := os.Getenv("SENDER_EMAIL")
-
-	if apiKey == "" || sender == "" {
-		log.Printf("⚠️ Warning: SENDGRID_API_KEY or SENDER_EMAIL environment variables are not set. Email functionality will be disabled.")
-		// Return a service with empty credentials - it won't work but won't crash the app
-		return &Service{
-			sendgridKey: "",
-			senderEmail: "",
-		}
+func NewService(emailConfig config.EmailConfig, templateSet *templates.Set, logger *slog.Logger) *Service {
+	if !emailConfig.Enabled() {
+		// The service still works but SendGrid will reject every send, the app keeps running.
+		logger.Warn("SENDGRID_API_KEY or SENDER_EMAIL is not set, email functionality is disabled")
 	}
 
 	return &Service{
-		sendgridKey: apiKey,
-		senderEmail: sender,
+		sendgridKey: emailConfig.SendGridAPIKey,
+		senderEmail: emailConfig.SenderEmail,
+		templates:   templateSet,
+		logger:      logger,
 	}
 }
 
 // SendEmail sends an email using the SendGrid API.
-func (service *Service) SendEmail(recipientEmail, recipientName, subject, templateName string, data EmailData, ccEmail []string, attachments ...Attachment) error {
-	// Parse the HTML template
-	templatePath := filepath.Join("templates", templateName)
-	templateFile, err := template.ParseFiles(templatePath)
-	if err != nil {
-		log.Printf("❌ Error parsing template %s: %v", templateName, err)
-		return err
-	}
+func (service *Service) SendEmail(ctx context.Context, recipientEmail, recipientName, subject, templateName string, data EmailData, ccEmail []string, attachments ...Attachment) error {
+	logger := logging.FromContext(ctx, service.logger)
 
-	// Create a buffer to hold the rendered template.
-	var body bytes.Buffer
-	if err := templateFile.Execute(&body, data); err != nil {
-		log.Printf("❌ Error executing template %s: %v", templateName, err)
+	// Render the preloaded HTML template.
+	body, err := service.templates.Render(templateName, data)
+	if err != nil {
+		logger.Error("failed to execute email template", "template", templateName, "error", err)
+		metrics.CountEmail(templateName, err)
 		return err
 	}
 
 	// Send the email using SendGrid API.
 	from := mail.NewEmail("SOSMIT App", service.senderEmail)
 	to := mail.NewEmail(recipientName, recipientEmail)
-	message := mail.NewSingleEmail(from, subject, to, "", body.String())
+	message := mail.NewSingleEmail(from, subject, to, "", string(body))
 	for _, cc := range ccEmail { // Add cc recipients if provided.
 		ccRecipient := mail.NewEmail("", cc)
 		message.Personalizations[0].AddCCs(ccRecipient)
@@ -101,18 +101,21 @@ func (service *Service) SendEmail(recipientEmail, recipientName, subject, templa
 		message.AddAttachment(&a)
 	}
 	client := sendgrid.NewSendClient(service.sendgridKey)
-	response, err := client.Send(message)
+	response, err := client.SendWithContext(ctx, message)
 
 	if err != nil {
-		log.Printf("❌ Error sending email to %s: %v", recipientEmail, err)
+		logger.Error("failed to send email", "recipient", recipientEmail, "subject", subject, "error", err)
+		metrics.CountEmail(templateName, err)
 		return err
 	}
 
 	// Log the response from SendGrid.
 	if response.StatusCode >= 300 {
-		log.Printf("❌ Sendgrid returned a non-success status when sending email to %s: %s", recipientEmail, response.Body)
+		logger.Error("SendGrid returned a non-success status", "recipient", recipientEmail, "subject", subject, "status", response.StatusCode, "body", response.Body)
+		metrics.CountEmail(templateName, fmt.Errorf("sendgrid status %d", response.StatusCode))
 	} else {
-		log.Printf("✅ Email sent successfully to %s with status code %d", recipientEmail, response.StatusCode)
+		logger.Info("sent email", "recipient", recipientEmail, "subject", subject, "status", response.StatusCode)
+		metrics.CountEmail(templateName, nil)
 	}
 
 	return nil
@@ -0,0 +1,108 @@
+// == Handles liveness and readiness probes ==
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/report"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds how long all readiness checks together may take.
+const readinessTimeout = 3 * time.Second
+
+// Check is a single readiness dependency check.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Handler serves the liveness and readiness probes.
+type Handler struct {
+	checks []Check
+	logger *slog.Logger
+}
+
+// NewHandler creates a health handler checking the DB pool, the PDF backend and upload storage.
+// Templates are embedded and parsed at startup, so they need no check here.
+func NewHandler(db *sql.DB, uploadsDir string, logger *slog.Logger) *Handler {
+	return &Handler{
+		checks: []Check{
+			{Name: "database", Run: func(ctx context.Context) error { return db.PingContext(ctx) }},
+			{Name: "pdf_backend", Run: func(ctx context.Context) error { return report.CheckPDFBackend() }},
+			{Name: "upload_storage", Run: func(ctx context.Context) error { return checkWritableDir(uploadsDir) }},
+		},
+		logger: logger,
+	}
+}
+
+// LivenessHandler reports that the process is up. It deliberately checks no dependencies.
+func (handler *Handler) LivenessHandler(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ReadinessHandler runs every dependency check and returns 503 if any of them fails.
+func (handler *Handler) ReadinessHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	ctx, cancel := contextWithTimeout(context.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	results := make(gin.H, len(handler.checks))
+	ready := true
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range handler.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			err := check.Run(ctx)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				ready = false
+				results[check.Name] = gin.H{"status": "fail", "error": err.Error()}
+				logger.Warn("readiness check failed", "check", check.Name, "error", err)
+				return
+			}
+			results[check.Name] = gin.H{"status": "ok"}
+		}(check)
+	}
+	wg.Wait()
+
+	status, statusText := http.StatusOK, "ready"
+	if !ready {
+		status, statusText = http.StatusServiceUnavailable, "not_ready"
+	}
+	context.JSON(status, gin.H{"status": statusText, "checks": results})
+}
+
+// contextWithTimeout is split out because the handler's gin context shadows the context package.
+func contextWithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, timeout)
+}
+
+// checkWritableDir verifies the directory exists (creating it if needed) and accepts writes.
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	probe, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return fmt.Errorf("upload storage not writable: %w", err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
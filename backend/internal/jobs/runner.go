@@ -0,0 +1,152 @@
+// == Runs background work (emails, PDF generation, schedulers) outside the request lifecycle ==
+// == Bounds how many jobs run at once and drains in-flight jobs on shutdown ==
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+)
+
+// ErrShuttingDown is returned by Submit once Shutdown has been called.
+var ErrShuttingDown = errors.New("job runner is shutting down")
+
+// Job is a unit of background work. ctx is cancelled if the job is still running when the shutdown deadline passes.
+type Job func(ctx context.Context) error
+
+// Runner owns every background goroutine of the application.
+type Runner struct {
+	slots   chan struct{} // Semaphore bounding the number of concurrently running jobs
+	wg      sync.WaitGroup
+	mutex   sync.RWMutex
+	closed  bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending atomic.Int64 // Submitted jobs that have not finished yet, waiting or running
+	logger  *slog.Logger
+}
+
+// NewRunner creates a runner executing at most concurrency jobs at the same time.
+func NewRunner(concurrency int, logger *slog.Logger) *Runner {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		slots:  make(chan struct{}, concurrency),
+		ctx:    ctx,
+		cancel: cancel,
+		logger: logger,
+	}
+}
+
+// Submit queues a job and returns immediately. The job starts as soon as a slot is free.
+// attrs are added to every log line about the job (e.g. "session_id", 12).
+func (runner *Runner) Submit(name string, job Job, attrs ...any) error {
+	// Hold the read lock while registering with the wait group, so Shutdown cannot start waiting in between.
+	runner.mutex.RLock()
+	defer runner.mutex.RUnlock()
+	if runner.closed {
+		runner.logger.Warn("rejected background job, runner is shutting down", append([]any{"job", name}, attrs...)...)
+		return ErrShuttingDown
+	}
+
+	runner.wg.Add(1)
+	runner.pending.Add(1)
+	go runner.run(name, job, runner.logger.With(append([]any{"job", name}, attrs...)...))
+	return nil
+}
+
+// Schedule submits job at every time returned by next, which receives the current time, until the runner shuts down.
+// A zero time from next stops the schedule. Each run is an ordinary job: it shares the concurrency limit and is drained on shutdown.
+func (runner *Runner) Schedule(name string, next func(now time.Time) time.Time, job Job, attrs ...any) {
+	logger := runner.logger.With(append([]any{"job", name}, attrs...)...)
+	go func() {
+		for {
+			at := next(time.Now())
+			if at.IsZero() {
+				logger.Warn("schedule has no next run, stopped")
+				return
+			}
+			logger.Info("scheduled next run", "at", at)
+
+			timer := time.NewTimer(time.Until(at))
+			select {
+			case <-timer.C:
+			case <-runner.ctx.Done():
+				timer.Stop()
+				return
+			}
+			if err := runner.Submit(name, job, attrs...); errors.Is(err, ErrShuttingDown) {
+				return
+			}
+		}
+	}()
+}
+
+// run waits for a slot, executes the job and recovers from panics so one bad job cannot take the process down.
+func (runner *Runner) run(name string, job Job, logger *slog.Logger) {
+	defer runner.wg.Done()
+	defer runner.pending.Add(-1)
+
+	select {
+	case runner.slots <- struct{}{}:
+		defer func() { <-runner.slots }()
+	case <-runner.ctx.Done():
+		logger.Warn("dropped background job before it started, shutdown deadline passed")
+		return
+	}
+
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = fmt.Errorf("panic: %v", recovered)
+				logger.Error("background job panicked", "panic", recovered, "stack", string(debug.Stack()))
+			}
+		}()
+		// Repositories and services called by the job log with its name and attributes.
+		return job(logging.WithLogger(runner.ctx, logger))
+	}()
+
+	elapsed := time.Since(start).Milliseconds()
+	if err != nil {
+		logger.Error("background job failed", "elapsed_ms", elapsed, "error", err)
+		return
+	}
+	logger.Debug("background job finished", "elapsed_ms", elapsed)
+}
+
+// Shutdown stops accepting jobs and waits for queued and running jobs to finish.
+// When ctx expires first, running jobs get their context cancelled and ctx's error is returned.
+func (runner *Runner) Shutdown(ctx context.Context) error {
+	runner.mutex.Lock()
+	runner.closed = true
+	runner.mutex.Unlock()
+
+	runner.logger.Info("draining background jobs", "pending", runner.pending.Load())
+
+	done := make(chan struct{})
+	go func() {
+		runner.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		runner.cancel()
+		runner.logger.Info("background jobs drained")
+		return nil
+	case <-ctx.Done():
+		runner.cancel()
+		runner.logger.Error("shutdown deadline passed with background jobs still pending", "pending", runner.pending.Load())
+		return ctx.Err()
+	}
+}
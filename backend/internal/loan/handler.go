@@ -0,0 +1,221 @@
+// == Handles API requests related to asset loans ==
+package loan
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errMissingUser = apperr.Unauthorized("unauthorized", "user not found in context")
+
+// listQuery is a service method listing loans: all of them or the overdue ones.
+type listQuery func(ctx context.Context, filter Filter) ([]*Loan, int64, error)
+
+type Handler struct {
+	service *Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates a new asset loan handler.
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetLoansHandler lists loans, filterable by asset_tag, borrower_id, site_id (of the asset) and open.
+func (handler *Handler) GetLoansHandler(context *gin.Context) {
+	handler.list(context, "all", handler.service.GetLoans)
+}
+
+// GetOverdueLoansHandler lists the open loans whose due date has passed, most overdue first.
+func (handler *Handler) GetOverdueLoansHandler(context *gin.Context) {
+	handler.list(context, "overdue", handler.service.GetOverdueLoans)
+}
+
+// GetLoanByIDHandler retrieves a loan.
+func (handler *Handler) GetLoanByIDHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	loanID, ok := loanIDParam(context)
+	if !ok {
+		return
+	}
+
+	loan, err := handler.service.GetLoanByID(context.Request.Context(), loanID)
+	if err != nil {
+		logger.Warn("failed to retrieve asset loan", "loan_id", loanID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, serializeLoan(loan))
+}
+
+// CheckOutHandler lends an asset to a borrower until a due date.
+func (handler *Handler) CheckOutHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
+		return
+	}
+
+	var request CheckOutRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid asset check-out request", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	loan, err := handler.service.CheckOut(context.Request.Context(), actor, request)
+	if err != nil {
+		logger.Warn("failed to check out asset", "asset_tag", request.AssetTag, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{
+		"message": "asset checked out successfully",
+		"loan":    serializeLoan(loan),
+	})
+}
+
+// CheckInHandler takes a loaned asset back with its assessed condition.
+func (handler *Handler) CheckInHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	loanID, ok := loanIDParam(context)
+	if !ok {
+		return
+	}
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
+		return
+	}
+
+	var request CheckInRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid asset check-in request", "loan_id", loanID, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	loan, err := handler.service.CheckIn(context.Request.Context(), actor, loanID, request)
+	if err != nil {
+		logger.Warn("failed to check in asset", "loan_id", loanID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "asset checked in successfully",
+		"loan":    serializeLoan(loan),
+	})
+}
+
+// RemindOverdueHandler sends the overdue reminders in the background, on top of the daily schedule. Only an admin may do this.
+func (handler *Handler) RemindOverdueHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
+		return
+	}
+
+	if err := handler.service.TriggerReminders(context.Request.Context(), actor); err != nil {
+		logger.Warn("failed to trigger overdue reminders", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusAccepted, gin.H{"message": "overdue reminders are being sent"})
+}
+
+// list serves one of the loan listings, filtered by the query parameters.
+func (handler *Handler) list(context *gin.Context, listing string, query listQuery) {
+	logger := logging.FromGin(context, handler.logger)
+
+	var filter Filter
+	if err := context.ShouldBindQuery(&filter); err != nil {
+		logger.Warn("invalid asset loan filter", "listing", listing, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_query", "invalid query parameters: "+err.Error()))
+		return
+	}
+
+	loans, totalCount, err := query(context.Request.Context(), filter)
+	if err != nil {
+		logger.Warn("failed to retrieve asset loans", "listing", listing, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	serialized := make([]gin.H, 0, len(loans))
+	for _, loan := range loans {
+		serialized = append(serialized, serializeLoan(loan))
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"loans":       serialized,
+		"total_count": totalCount,
+	})
+}
+
+// serializeLoan converts a loan to its JSON form.
+func serializeLoan(loan *Loan) gin.H {
+	var checkedInAt, lastRemindedAt any
+	if loan.CheckedInAt.Valid {
+		checkedInAt = loan.CheckedInAt.Time
+	}
+	if loan.LastRemindedAt.Valid {
+		lastRemindedAt = loan.LastRemindedAt.Time
+	}
+	return gin.H{
+		"id":                  loan.ID,
+		"asset_tag":           loan.AssetTag,
+		"serial_number":       loan.SerialNumber,
+		"brand_name":          loan.BrandName,
+		"product_name":        loan.ProductName,
+		"product_variety":     loan.ProductVariety,
+		"site_id":             loan.SiteID,
+		"site_name":           loan.SiteName,
+		"purpose":             loan.Purpose,
+		"due_date":            loan.DueDate.Format(time.DateOnly),
+		"previous_status":     loan.PreviousStatus,
+		"borrower_id":         loan.BorrowerID,
+		"borrower_name":       loan.BorrowerName,
+		"checked_out_by":      loan.CheckedOutBy,
+		"checked_out_by_name": loan.CheckedOutByName,
+		"checked_out_at":      loan.CheckedOutAt,
+		"checked_in_by":       utils.SerializeNI(loan.CheckedInBy),
+		"checked_in_by_name":  utils.SerializeNS(loan.CheckedInByName),
+		"checked_in_at":       checkedInAt,
+		"return_condition":    utils.SerializeNI(loan.ReturnCondition),
+		"return_notes":        loan.ReturnNotes,
+		"return_photo_url":    loan.ReturnPhotoURL,
+		"last_reminded_at":    lastRemindedAt,
+	}
+}
+
+// loanIDParam parses the :loan-id route parameter, aborting the request when it is invalid.
+func loanIDParam(context *gin.Context) (int64, bool) {
+	loanID, err := strconv.ParseInt(context.Param("loan-id"), 10, 64)
+	if err != nil || loanID <= 0 {
+		apperr.Abort(context, apperr.Validation("invalid_loan_id", "invalid loan_id format"))
+		return 0, false
+	}
+	return loanID, true
+}
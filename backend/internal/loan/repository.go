@@ -0,0 +1,224 @@
+// == Handles all database operations related to asset loans ==
+package loan
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+)
+
+// Loan is the check-out of an asset to a borrower, along with the names needed to display it.
+// The loan is open until it is checked in, then CheckedInAt and ReturnCondition are set.
+type Loan struct {
+	ID                int64
+	AssetTag          string
+	SerialNumber      string
+	BrandName         string
+	ProductName       string
+	ProductVariety    string
+	SiteID            int64 // The site the asset is on
+	SiteName          string
+	Purpose           string
+	DueDate           time.Time
+	PreviousStatus    string // The asset status restored on check-in, "Deployed" or "In Inventory"
+	BorrowerID        int64
+	BorrowerName      string
+	BorrowerEmail     string
+	CheckedOutBy      int64
+	CheckedOutByName  string
+	CheckedOutByEmail string
+	CheckedOutAt      time.Time
+	CheckedInBy       sql.NullInt64
+	CheckedInByName   sql.NullString
+	CheckedInAt       sql.NullTime
+	ReturnCondition   sql.NullInt64 // 0: bad, 1: good
+	ReturnNotes       string
+	ReturnPhotoURL    string
+	LastRemindedAt    sql.NullTime
+}
+
+// CheckOutRequest is the body of POST /api/loan. DueDate is a date as YYYY-MM-DD.
+type CheckOutRequest struct {
+	AssetTag   string `json:"asset_tag" binding:"required"`
+	BorrowerID int64  `json:"borrower_id" binding:"required"`
+	DueDate    string `json:"due_date" binding:"required"`
+	Purpose    string `json:"purpose" binding:"required"`
+}
+
+// CheckInRequest is the body of PUT /api/loan/:loan-id/check-in. PhotoURL comes from POST /api/upload/photo
+// and is required when the asset is returned in bad condition.
+type CheckInRequest struct {
+	Condition *int   `json:"condition" binding:"required"`
+	Notes     string `json:"notes"`
+	PhotoURL  string `json:"photo_url"`
+}
+
+// Filter narrows down GET /api/loan and GET /api/loan/overdue. Every field is optional.
+type Filter struct {
+	AssetTag   *string `json:"asset_tag" form:"asset_tag"`
+	BorrowerID *int    `json:"borrower_id" form:"borrower_id"`
+	SiteID     *int    `json:"site_id" form:"site_id"`
+	Open       *bool   `json:"open" form:"open"`
+	Limit      *int    `json:"limit" form:"limit"`
+	PageNum    *int    `json:"page_num" form:"page_num"`
+}
+
+type Repository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewRepository creates a new asset loan repository.
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetLoans retrieves the loans matching the filter, newest first, along with the total number of matches.
+func (repo *Repository) GetLoans(ctx context.Context, filter Filter) ([]*Loan, int64, error) {
+	return repo.queryLoans(ctx, nil, nil, filter)
+}
+
+// GetOverdueLoans retrieves the loans still open on today and due before it, most overdue first.
+func (repo *Repository) GetOverdueLoans(ctx context.Context, today time.Time, filter Filter) ([]*Loan, int64, error) {
+	overdueOn := today.Format(time.DateOnly)
+	return repo.queryLoans(ctx, nil, &overdueOn, filter)
+}
+
+// GetLoanByID retrieves a loan, or nil if it does not exist.
+func (repo *Repository) GetLoanByID(ctx context.Context, loanID int64) (*Loan, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	loans, _, err := repo.queryLoans(ctx, &loanID, nil, Filter{})
+	if err != nil {
+		return nil, err
+	}
+	if len(loans) == 0 {
+		logger.Debug("no asset loan found", "loan_id", loanID)
+		return nil, nil
+	}
+	return loans[0], nil
+}
+
+// queryLoans runs get_asset_loans, for a single loan when loanID is set and for overdue loans when overdueOn is set.
+func (repo *Repository) queryLoans(ctx context.Context, loanID *int64, overdueOn *string, filter Filter) ([]*Loan, int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_asset_loans($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	rows, err := repo.db.QueryContext(ctx, query, loanID, filter.AssetTag, filter.BorrowerID, filter.SiteID, filter.Open, overdueOn, filter.Limit, filter.PageNum)
+	if err != nil {
+		logger.Error("failed to query asset loans", "error", err)
+		return nil, 0, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	loans := make([]*Loan, 0)
+	var totalCount int64
+	for rows.Next() {
+		var loan Loan
+		if err := rows.Scan(
+			&loan.ID,
+			&loan.AssetTag,
+			&loan.SerialNumber,
+			&loan.BrandName,
+			&loan.ProductName,
+			&loan.ProductVariety,
+			&loan.SiteID,
+			&loan.SiteName,
+			&loan.Purpose,
+			&loan.DueDate,
+			&loan.PreviousStatus,
+			&loan.BorrowerID,
+			&loan.BorrowerName,
+			&loan.BorrowerEmail,
+			&loan.CheckedOutBy,
+			&loan.CheckedOutByName,
+			&loan.CheckedOutByEmail,
+			&loan.CheckedOutAt,
+			&loan.CheckedInBy,
+			&loan.CheckedInByName,
+			&loan.CheckedInAt,
+			&loan.ReturnCondition,
+			&loan.ReturnNotes,
+			&loan.ReturnPhotoURL,
+			&loan.LastRemindedAt,
+			&totalCount,
+		); err != nil {
+			logger.Error("failed to scan asset loan row", "error", err)
+			return nil, 0, apperr.FromPostgres(err)
+		}
+		loans = append(loans, &loan)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating asset loan rows", "error", err)
+		return nil, 0, apperr.FromPostgres(err)
+	}
+
+	return loans, totalCount, nil
+}
+
+// CheckOut lends an asset out and returns the loan ID. The request's due date must already be validated.
+func (repo *Repository) CheckOut(ctx context.Context, checkedOutBy int64, request CheckOutRequest) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT check_out_asset($1, $2, $3, $4, $5)`
+
+	var loanID int64
+	err := repo.db.QueryRowContext(ctx, query, request.AssetTag, request.BorrowerID, request.DueDate, request.Purpose, checkedOutBy).Scan(&loanID)
+	if err != nil {
+		logger.Warn("failed to check out asset", "asset_tag", request.AssetTag, "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+
+	logger.Info("checked out asset", "loan_id", loanID, "asset_tag", request.AssetTag, "borrower_id", request.BorrowerID)
+	return loanID, nil
+}
+
+// CheckIn closes an open loan with the assessed condition of the returned asset.
+func (repo *Repository) CheckIn(ctx context.Context, loanID int64, checkedInBy int64, request CheckInRequest) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL check_in_asset($1, $2, $3, $4, $5)`
+
+	if _, err := repo.db.ExecContext(ctx, query, loanID, checkedInBy, request.Condition, request.Notes, request.PhotoURL); err != nil {
+		logger.Warn("failed to check in asset", "loan_id", loanID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("checked in asset", "loan_id", loanID)
+	return nil
+}
+
+// IsLoanManager checks whether a user is the GA staff of the site an asset is on.
+func (repo *Repository) IsLoanManager(ctx context.Context, assetTag string, userID int64) (bool, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT is_asset_loan_manager($1, $2)`
+
+	var manager bool
+	if err := repo.db.QueryRowContext(ctx, query, assetTag, userID).Scan(&manager); err != nil {
+		logger.Error("failed to check asset loan manager", "asset_tag", assetTag, "user_id", userID, "error", err)
+		return false, apperr.FromPostgres(err)
+	}
+	return manager, nil
+}
+
+// MarkReminded records that the borrower of an overdue loan has been reminded.
+func (repo *Repository) MarkReminded(ctx context.Context, loanID int64) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL mark_asset_loan_reminded($1)`
+
+	if _, err := repo.db.ExecContext(ctx, query, loanID); err != nil {
+		logger.Error("failed to mark asset loan as reminded", "loan_id", loanID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+	return nil
+}
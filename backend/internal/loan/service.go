@@ -0,0 +1,314 @@
+// == Handles all logical operations related to asset loans ==
+// == The GA staff of a site lends an asset out until a due date and takes it back with a condition assessment; overdue borrowers get a daily reminder ==
+package loan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/email"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/jobs"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/upload"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+)
+
+// Errors returned by the loan workflow, in addition to the ones translated from the database.
+var (
+	ErrLoanNotFound     = apperr.NotFound("loan_not_found", "asset loan not found")
+	ErrAlreadyReturned  = apperr.Conflict("loan_already_checked_in", "the asset has already been checked in")
+	ErrNotLoanManager   = apperr.Forbidden("loan_forbidden", "only the GA staff of the asset's site or an admin can lend it out or take it back")
+	ErrRemindForbidden  = apperr.Forbidden("loan_remind_forbidden", "only an admin can send the overdue reminders")
+	ErrInvalidDueDate   = apperr.Validation("invalid_due_date", "due_date must be a date as YYYY-MM-DD, today or later")
+	ErrInvalidPurpose   = apperr.Validation("invalid_purpose", "purpose must not be empty")
+	ErrInvalidCondition = apperr.Validation("invalid_return_condition", "condition must be 0 (bad) or 1 (good)")
+	ErrPhotoRequired    = apperr.Validation("return_photo_required", "a photo is required when the asset is returned in bad condition")
+	ErrInvalidPhoto     = apperr.Validation("invalid_photo_url", "photo_url must be a photo uploaded through /api/upload/photo")
+)
+
+// reminderPageSize is the number of overdue loans loaded at a time when sending reminders.
+const reminderPageSize = 100
+
+type Service struct {
+	repo          *Repository
+	uploadService *upload.Service
+	emailService  *email.Service
+	jobs          *jobs.Runner
+	config        config.LoanConfig
+	app           config.AppConfig // Timezone deciding what "today" is for due dates
+	logger        *slog.Logger
+}
+
+// NewService creates a new asset loan service. Overdue reminders are sent in the background through the job runner.
+func NewService(repo *Repository, uploadService *upload.Service, emailService *email.Service, jobRunner *jobs.Runner, loanConfig config.LoanConfig, app config.AppConfig, logger *slog.Logger) *Service {
+	return &Service{
+		repo:          repo,
+		uploadService: uploadService,
+		emailService:  emailService,
+		jobs:          jobRunner,
+		config:        loanConfig,
+		app:           app,
+		logger:        logger,
+	}
+}
+
+// GetLoans retrieves the loans matching the filter, newest first, along with the total number of matches.
+func (service *Service) GetLoans(ctx context.Context, filter Filter) ([]*Loan, int64, error) {
+	return service.repo.GetLoans(ctx, filter)
+}
+
+// GetOverdueLoans retrieves the open loans whose due date has passed, most overdue first.
+func (service *Service) GetOverdueLoans(ctx context.Context, filter Filter) ([]*Loan, int64, error) {
+	return service.repo.GetOverdueLoans(ctx, service.today(), filter)
+}
+
+// GetLoanByID retrieves a loan.
+func (service *Service) GetLoanByID(ctx context.Context, loanID int64) (*Loan, error) {
+	loan, err := service.repo.GetLoanByID(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if loan == nil {
+		return nil, ErrLoanNotFound
+	}
+	return loan, nil
+}
+
+// CheckOut lends an asset to a borrower until the due date on behalf of the GA staff of its site or an admin, and returns the loan.
+// The asset is "On Loan" until it is checked in.
+func (service *Service) CheckOut(ctx context.Context, actor roles.Actor, request CheckOutRequest) (*Loan, error) {
+	request.AssetTag = strings.TrimSpace(request.AssetTag)
+	request.Purpose = strings.TrimSpace(request.Purpose)
+	if request.Purpose == "" {
+		return nil, ErrInvalidPurpose
+	}
+	dueDate, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(request.DueDate), service.app.Location())
+	if err != nil || dueDate.Before(service.today()) {
+		return nil, ErrInvalidDueDate
+	}
+	request.DueDate = dueDate.Format(time.DateOnly)
+
+	if err := service.authorize(ctx, actor, "check_out", request.AssetTag); err != nil {
+		return nil, err
+	}
+
+	loanID, err := service.repo.CheckOut(ctx, actor.UserID, request)
+	if err != nil {
+		return nil, err
+	}
+	created, err := service.GetLoanByID(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "asset_loan.check_out",
+		EntityType: "asset_loan",
+		EntityID:   strconv.FormatInt(loanID, 10),
+		After:      loanSnapshot(created),
+	})
+	return created, nil
+}
+
+// CheckIn takes a loaned asset back on behalf of the GA staff of its site or an admin. The asset gets its pre-loan status back
+// and the assessed condition; a bad condition needs a photo uploaded through /api/upload/photo.
+func (service *Service) CheckIn(ctx context.Context, actor roles.Actor, loanID int64, request CheckInRequest) (*Loan, error) {
+	request.Notes = strings.TrimSpace(request.Notes)
+	request.PhotoURL = strings.TrimSpace(request.PhotoURL)
+	if request.Condition == nil || (*request.Condition != 0 && *request.Condition != 1) {
+		return nil, ErrInvalidCondition
+	}
+	if *request.Condition == 0 && request.PhotoURL == "" {
+		return nil, ErrPhotoRequired
+	}
+	if request.PhotoURL != "" && !service.uploadService.IsConditionPhoto(request.PhotoURL) {
+		return nil, ErrInvalidPhoto
+	}
+
+	before, err := service.GetLoanByID(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if before.CheckedInAt.Valid {
+		return nil, ErrAlreadyReturned
+	}
+	if err := service.authorize(ctx, actor, "check_in", before.AssetTag); err != nil {
+		return nil, err
+	}
+
+	if err := service.repo.CheckIn(ctx, loanID, actor.UserID, request); err != nil {
+		return nil, err
+	}
+	after, err := service.GetLoanByID(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "asset_loan.check_in",
+		EntityType: "asset_loan",
+		EntityID:   strconv.FormatInt(loanID, 10),
+		Before:     loanSnapshot(before),
+		After:      loanSnapshot(after),
+	})
+	return after, nil
+}
+
+// Schedule registers the daily overdue reminders with the job runner, if loans.reminder_schedule is set.
+func (service *Service) Schedule() {
+	if service.config.ReminderSchedule == "" {
+		return
+	}
+	service.jobs.Schedule("loan.overdue_reminders",
+		func(now time.Time) time.Time { return service.config.NextReminder(now.In(service.app.Location())) },
+		func(ctx context.Context) error {
+			_, err := service.SendOverdueReminders(ctx)
+			return err
+		},
+	)
+}
+
+// TriggerReminders sends the overdue reminders in the background on behalf of an admin.
+func (service *Service) TriggerReminders(ctx context.Context, actor roles.Actor) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !actor.IsAdmin() {
+		logger.Warn("overdue reminders denied", "user_id", actor.UserID, "position", actor.Position)
+		return ErrRemindForbidden
+	}
+
+	err := service.jobs.Submit("loan.overdue_reminders", func(ctx context.Context) error {
+		_, err := service.SendOverdueReminders(ctx)
+		return err
+	}, "user_id", actor.UserID)
+	if err != nil {
+		return apperr.Internal(err)
+	}
+	return nil
+}
+
+// SendOverdueReminders emails the borrower of every overdue loan, with the lender in copy, and returns how many were sent.
+// A borrower is reminded at most once a day, however often this runs.
+func (service *Service) SendOverdueReminders(ctx context.Context) (int, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	today := service.today()
+
+	var overdue []*Loan
+	for page := 1; ; page++ {
+		limit := reminderPageSize
+		loans, _, err := service.repo.GetOverdueLoans(ctx, today, Filter{Limit: &limit, PageNum: &page})
+		if err != nil {
+			return 0, err
+		}
+		overdue = append(overdue, loans...)
+		if len(loans) < limit {
+			break
+		}
+	}
+
+	var sent int
+	var errs []error
+	for _, loan := range overdue {
+		if loan.LastRemindedAt.Valid && !loan.LastRemindedAt.Time.In(service.app.Location()).Before(today) {
+			continue
+		}
+		if loan.BorrowerEmail == "" {
+			logger.Warn("overdue borrower has no email, not reminded", "loan_id", loan.ID, "borrower_id", loan.BorrowerID)
+			continue
+		}
+
+		if err := service.sendReminder(ctx, loan); err != nil {
+			logger.Error("failed to send overdue reminder", "loan_id", loan.ID, "error", err)
+			errs = append(errs, fmt.Errorf("loan %d: %w", loan.ID, err))
+			continue
+		}
+		if err := service.repo.MarkReminded(ctx, loan.ID); err != nil {
+			errs = append(errs, fmt.Errorf("loan %d: %w", loan.ID, err))
+			continue
+		}
+		sent++
+	}
+
+	logger.Info("sent overdue loan reminders", "overdue", len(overdue), "sent", sent, "failed", len(errs))
+	return sent, errors.Join(errs...)
+}
+
+// sendReminder emails the borrower of an overdue loan.
+func (service *Service) sendReminder(ctx context.Context, loan *Loan) error {
+	title := cases.Title(language.English)
+	var cc []string
+	if loan.CheckedOutByEmail != "" && loan.CheckedOutByEmail != loan.BorrowerEmail {
+		cc = append(cc, loan.CheckedOutByEmail)
+	}
+
+	data := email.EmailData{
+		SiteName:  loan.SiteName,
+		Borrower:  title.String(loan.BorrowerName),
+		Lender:    title.String(loan.CheckedOutByName),
+		AssetTag:  loan.AssetTag,
+		AssetName: loan.BrandName + " " + loan.ProductName,
+		DueDate:   loan.DueDate.Format("02 Jan 2006"),
+	}
+	return service.emailService.SendEmail(
+		ctx,
+		loan.BorrowerEmail,
+		data.Borrower,
+		fmt.Sprintf("Loaned asset %s is overdue", loan.AssetTag),
+		"loan_overdue.html",
+		data,
+		cc,
+	)
+}
+
+// authorize checks that the actor is the GA staff of the asset's site or an admin.
+func (service *Service) authorize(ctx context.Context, actor roles.Actor, action, assetTag string) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if actor.IsAdmin() {
+		return nil
+	}
+	manager, err := service.repo.IsLoanManager(ctx, assetTag, actor.UserID)
+	if err != nil {
+		return err
+	}
+	if !manager {
+		logger.Warn("asset loan denied", "action", action, "user_id", actor.UserID, "position", actor.Position, "asset_tag", assetTag)
+		return ErrNotLoanManager
+	}
+	return nil
+}
+
+// today returns the start of the current day in the configured timezone.
+func (service *Service) today() time.Time {
+	now := time.Now().In(service.app.Location())
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// loanSnapshot is the audited state of a loan.
+func loanSnapshot(loan *Loan) map[string]any {
+	return map[string]any{
+		"asset_tag":        loan.AssetTag,
+		"borrower_id":      loan.BorrowerID,
+		"purpose":          loan.Purpose,
+		"due_date":         loan.DueDate.Format(time.DateOnly),
+		"previous_status":  loan.PreviousStatus,
+		"checked_out_by":   loan.CheckedOutBy,
+		"checked_in_by":    utils.SerializeNI(loan.CheckedInBy),
+		"return_condition": utils.SerializeNI(loan.ReturnCondition),
+		"return_notes":     loan.ReturnNotes,
+		"return_photo_url": loan.ReturnPhotoURL,
+	}
+}
@@ -0,0 +1,194 @@
+// == Handles API requests related to the location hierarchy administration ==
+package location
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	service *Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates a new location handler.
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetTreeHandler retrieves the whole location hierarchy, for pickers.
+func (handler *Handler) GetTreeHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	tree, err := handler.service.GetTree(context.Request.Context())
+	if err != nil {
+		logger.Error("failed to retrieve location tree", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, tree)
+}
+
+// CreateRegionHandler creates a region. Only L1 support may do this.
+func (handler *Handler) CreateRegionHandler(context *gin.Context) {
+	handler.create(context, RegionLevel, &RegionRequest{})
+}
+
+// UpdateRegionHandler renames a region. Only L1 support may do this.
+func (handler *Handler) UpdateRegionHandler(context *gin.Context) {
+	handler.update(context, RegionLevel, &RegionRequest{})
+}
+
+// DeleteRegionHandler deletes a region without site groups. Only L1 support may do this.
+func (handler *Handler) DeleteRegionHandler(context *gin.Context) {
+	handler.delete(context, RegionLevel)
+}
+
+// CreateSiteGroupHandler creates a site group. Only L1 support may do this.
+func (handler *Handler) CreateSiteGroupHandler(context *gin.Context) {
+	handler.create(context, SiteGroupLevel, &SiteGroupRequest{})
+}
+
+// UpdateSiteGroupHandler renames a site group or moves it to another region. Only L1 support may do this.
+func (handler *Handler) UpdateSiteGroupHandler(context *gin.Context) {
+	handler.update(context, SiteGroupLevel, &SiteGroupRequest{})
+}
+
+// DeleteSiteGroupHandler deletes a site group without sites. Only L1 support may do this.
+func (handler *Handler) DeleteSiteGroupHandler(context *gin.Context) {
+	handler.delete(context, SiteGroupLevel)
+}
+
+// CreateSiteHandler creates a site. Only L1 support may do this.
+func (handler *Handler) CreateSiteHandler(context *gin.Context) {
+	handler.create(context, SiteLevel, &SiteRequest{})
+}
+
+// UpdateSiteHandler renames a site, moves it to another site group, reassigns its GA or flags it as a head office.
+// Only L1 support may do this.
+func (handler *Handler) UpdateSiteHandler(context *gin.Context) {
+	handler.update(context, SiteLevel, &SiteRequest{})
+}
+
+// DeleteSiteHandler deletes a site without sub-sites, departments, assets, users or opname sessions. Only L1 support may do this.
+func (handler *Handler) DeleteSiteHandler(context *gin.Context) {
+	handler.delete(context, SiteLevel)
+}
+
+// CreateSubSiteHandler creates a sub-site. Only L1 support may do this.
+func (handler *Handler) CreateSubSiteHandler(context *gin.Context) {
+	handler.create(context, SubSiteLevel, &SubSiteRequest{})
+}
+
+// UpdateSubSiteHandler renames a sub-site or moves it to another site. Only L1 support may do this.
+func (handler *Handler) UpdateSubSiteHandler(context *gin.Context) {
+	handler.update(context, SubSiteLevel, &SubSiteRequest{})
+}
+
+// DeleteSubSiteHandler deletes a sub-site that holds no assets. Only L1 support may do this.
+func (handler *Handler) DeleteSubSiteHandler(context *gin.Context) {
+	handler.delete(context, SubSiteLevel)
+}
+
+// CreateDepartmentHandler creates a department at a head office. Only L1 support may do this.
+func (handler *Handler) CreateDepartmentHandler(context *gin.Context) {
+	handler.create(context, DepartmentLevel, &DepartmentRequest{})
+}
+
+// UpdateDepartmentHandler renames a department or moves it to another head office. Only L1 support may do this.
+func (handler *Handler) UpdateDepartmentHandler(context *gin.Context) {
+	handler.update(context, DepartmentLevel, &DepartmentRequest{})
+}
+
+// DeleteDepartmentHandler deletes a department that holds no assets or opname sessions. Only L1 support may do this.
+func (handler *Handler) DeleteDepartmentHandler(context *gin.Context) {
+	handler.delete(context, DepartmentLevel)
+}
+
+// create handles the Create*Handler requests.
+func (handler *Handler) create(context *gin.Context, level Level, request Request) {
+	logger := logging.FromGin(context, handler.logger)
+
+	if err := context.ShouldBindJSON(request); err != nil {
+		logger.Warn("invalid create location request", "level", level.Name, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	created, err := handler.service.Create(context.Request.Context(), context.GetString("position"), level, request)
+	if err != nil {
+		logger.Warn("failed to create location", "level", level.Name, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{
+		"message":  level.Name + " created successfully",
+		level.Name: created,
+	})
+}
+
+// update handles the Update*Handler requests.
+func (handler *Handler) update(context *gin.Context, level Level, request Request) {
+	logger := logging.FromGin(context, handler.logger)
+
+	id, ok := idParam(context)
+	if !ok {
+		return
+	}
+	if err := context.ShouldBindJSON(request); err != nil {
+		logger.Warn("invalid update location request", "level", level.Name, "id", id, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	updated, err := handler.service.Update(context.Request.Context(), context.GetString("position"), level, id, request)
+	if err != nil {
+		logger.Warn("failed to update location", "level", level.Name, "id", id, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message":  level.Name + " updated successfully",
+		level.Name: updated,
+	})
+}
+
+// delete handles the Delete*Handler requests.
+func (handler *Handler) delete(context *gin.Context, level Level) {
+	logger := logging.FromGin(context, handler.logger)
+
+	id, ok := idParam(context)
+	if !ok {
+		return
+	}
+
+	if err := handler.service.Delete(context.Request.Context(), context.GetString("position"), level, id); err != nil {
+		logger.Warn("failed to delete location", "level", level.Name, "id", id, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": level.Name + " deleted successfully"})
+}
+
+// idParam parses the :id route parameter, aborting the request when it is invalid.
+func idParam(context *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		apperr.Abort(context, apperr.Validation("invalid_id", "invalid ID format"))
+		return 0, false
+	}
+	return id, true
+}
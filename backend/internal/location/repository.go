@@ -0,0 +1,291 @@
+// == Handles all database operations related to the location hierarchy administration ==
+package location
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+)
+
+// Level is one level of the location hierarchy, along with the stored routines that administer it.
+type Level struct {
+	Name        string // audit entity type, e.g. "sub_site"
+	createQuery string // takes the request arguments, returns the new ID
+	updateQuery string // takes the ID followed by the request arguments
+	deleteQuery string // takes the ID
+	notFound    *apperr.Error
+}
+
+// The levels of the hierarchy: Region > SiteGroup > Site > SubSite at the areas, Department at the head office.
+var (
+	RegionLevel = Level{
+		Name:        "region",
+		createQuery: `SELECT create_region($1)`,
+		updateQuery: `CALL update_region($1, $2)`,
+		deleteQuery: `CALL delete_region($1)`,
+		notFound:    apperr.NotFound("region_not_found", "region not found"),
+	}
+	SiteGroupLevel = Level{
+		Name:        "site_group",
+		createQuery: `SELECT create_site_group($1, $2)`,
+		updateQuery: `CALL update_site_group($1, $2, $3)`,
+		deleteQuery: `CALL delete_site_group($1)`,
+		notFound:    apperr.NotFound("site_group_not_found", "site group not found"),
+	}
+	SiteLevel = Level{
+		Name:        "site",
+		createQuery: `SELECT create_site($1, $2, $3, $4)`,
+		updateQuery: `CALL update_site($1, $2, $3, $4, $5)`,
+		deleteQuery: `CALL delete_site($1)`,
+		notFound:    apperr.NotFound("site_not_found", "site not found"),
+	}
+	SubSiteLevel = Level{
+		Name:        "sub_site",
+		createQuery: `SELECT create_sub_site($1, $2)`,
+		updateQuery: `CALL update_sub_site($1, $2, $3)`,
+		deleteQuery: `CALL delete_sub_site($1)`,
+		notFound:    apperr.NotFound("sub_site_not_found", "sub-site not found"),
+	}
+	DepartmentLevel = Level{
+		Name:        "department",
+		createQuery: `SELECT create_department($1, $2)`,
+		updateQuery: `CALL update_department($1, $2, $3)`,
+		deleteQuery: `CALL delete_department($1)`,
+		notFound:    apperr.NotFound("department_not_found", "department not found"),
+	}
+)
+
+// Request is the body of a create or update call for one level. On update, omitted fields keep their current value.
+type Request interface {
+	// validate trims the names and checks the request; create requires every field.
+	validate(create bool) error
+	// args returns the arguments of the level's create routine, in order.
+	args() []any
+}
+
+// RegionRequest is the body of POST and PUT /api/location/regions.
+type RegionRequest struct {
+	RegionName *string `json:"region_name"`
+}
+
+// SiteGroupRequest is the body of POST and PUT /api/location/site-groups.
+type SiteGroupRequest struct {
+	SiteGroupName *string `json:"site_group_name"`
+	RegionID      *int64  `json:"region_id"`
+}
+
+// SiteRequest is the body of POST and PUT /api/location/sites. SiteGaID hands the site to another GA.
+// IsHeadOffice defaults to false on create.
+type SiteRequest struct {
+	SiteName     *string `json:"site_name"`
+	SiteGroupID  *int64  `json:"site_group_id"`
+	SiteGaID     *int64  `json:"site_ga_id"`
+	IsHeadOffice *bool   `json:"is_head_office"`
+}
+
+// SubSiteRequest is the body of POST and PUT /api/location/sub-sites.
+type SubSiteRequest struct {
+	SubSiteName *string `json:"sub_site_name"`
+	SiteID      *int64  `json:"site_id"`
+}
+
+// DepartmentRequest is the body of POST and PUT /api/location/departments. SiteID is the department's head office;
+// it may be omitted on create when there is only one.
+type DepartmentRequest struct {
+	DeptName *string `json:"dept_name"`
+	SiteID   *int64  `json:"site_id"`
+}
+
+func (request *RegionRequest) args() []any {
+	return []any{request.RegionName}
+}
+
+func (request *SiteGroupRequest) args() []any {
+	return []any{request.SiteGroupName, request.RegionID}
+}
+
+func (request *SiteRequest) args() []any {
+	return []any{request.SiteName, request.SiteGroupID, request.SiteGaID, request.IsHeadOffice}
+}
+
+func (request *SubSiteRequest) args() []any {
+	return []any{request.SubSiteName, request.SiteID}
+}
+
+func (request *DepartmentRequest) args() []any {
+	return []any{request.DeptName, request.SiteID}
+}
+
+// Region is the top of the location tree.
+type Region struct {
+	RegionID   int64        `json:"region_id"`
+	RegionName string       `json:"region_name"`
+	SiteGroups []*SiteGroup `json:"site_groups"`
+}
+
+// SiteGroup groups the sites of a region.
+type SiteGroup struct {
+	SiteGroupID   int64   `json:"site_group_id"`
+	SiteGroupName string  `json:"site_group_name"`
+	Sites         []*Site `json:"sites"`
+}
+
+// Site is a branch or a head office, looked after by a GA.
+type Site struct {
+	SiteID       int64      `json:"site_id"`
+	SiteName     string     `json:"site_name"`
+	SiteGaID     int64      `json:"site_ga_id"`
+	IsHeadOffice bool       `json:"is_head_office"`
+	SubSites     []*SubSite `json:"sub_sites"`
+}
+
+// SubSite is the most granular location at a site.
+type SubSite struct {
+	SubSiteID   int64  `json:"sub_site_id"`
+	SubSiteName string `json:"sub_site_name"`
+}
+
+type Repository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewRepository creates a new location repository.
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetTree retrieves the whole Region > SiteGroup > Site > SubSite hierarchy, nested and ordered by name.
+func (repo *Repository) GetTree(ctx context.Context) ([]*Region, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT region_id, region_name, site_group_id, site_group_name, site_id, site_name, site_ga_id, is_head_office, sub_site_id, sub_site_name FROM get_location_tree()`
+
+	rows, err := repo.db.QueryContext(ctx, query)
+	if err != nil {
+		logger.Error("failed to query location tree", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	// The rows come ordered by region, site group, site and sub-site, so each node only has to be compared with the last one.
+	regions := make([]*Region, 0)
+	var (
+		region    *Region
+		siteGroup *SiteGroup
+		site      *Site
+	)
+	for rows.Next() {
+		var (
+			regionID                                 int64
+			regionName                               string
+			siteGroupID, siteID, siteGaID, subSiteID sql.NullInt64
+			siteGroupName, siteName, subSiteName     sql.NullString
+			isHeadOffice                             sql.NullBool
+		)
+		if err := rows.Scan(&regionID, &regionName, &siteGroupID, &siteGroupName, &siteID, &siteName, &siteGaID, &isHeadOffice, &subSiteID, &subSiteName); err != nil {
+			logger.Error("failed to scan location tree row", "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+
+		if region == nil || region.RegionID != regionID {
+			region = &Region{RegionID: regionID, RegionName: regionName, SiteGroups: make([]*SiteGroup, 0)}
+			regions = append(regions, region)
+			siteGroup, site = nil, nil
+		}
+		if !siteGroupID.Valid {
+			continue
+		}
+		if siteGroup == nil || siteGroup.SiteGroupID != siteGroupID.Int64 {
+			siteGroup = &SiteGroup{SiteGroupID: siteGroupID.Int64, SiteGroupName: siteGroupName.String, Sites: make([]*Site, 0)}
+			region.SiteGroups = append(region.SiteGroups, siteGroup)
+			site = nil
+		}
+		if !siteID.Valid {
+			continue
+		}
+		if site == nil || site.SiteID != siteID.Int64 {
+			site = &Site{SiteID: siteID.Int64, SiteName: siteName.String, SiteGaID: siteGaID.Int64, IsHeadOffice: isHeadOffice.Bool, SubSites: make([]*SubSite, 0)}
+			siteGroup.Sites = append(siteGroup.Sites, site)
+		}
+		if subSiteID.Valid {
+			site.SubSites = append(site.SubSites, &SubSite{SubSiteID: subSiteID.Int64, SubSiteName: subSiteName.String})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating location tree rows", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return regions, nil
+}
+
+// GetNode retrieves one node of a level as a flat JSON object, or nil if it does not exist.
+func (repo *Repository) GetNode(ctx context.Context, level Level, id int64) (map[string]any, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT get_location_node($1, $2)`
+
+	var raw []byte
+	if err := repo.db.QueryRowContext(ctx, query, level.Name, id).Scan(&raw); err != nil {
+		logger.Error("failed to query location node", "level", level.Name, "id", id, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	var node map[string]any
+	if err := json.Unmarshal(raw, &node); err != nil {
+		return nil, apperr.Internal(err)
+	}
+	return node, nil
+}
+
+// Create creates a node of a level and returns its ID.
+func (repo *Repository) Create(ctx context.Context, level Level, request Request) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	var id int64
+	if err := repo.db.QueryRowContext(ctx, level.createQuery, request.args()...).Scan(&id); err != nil {
+		logger.Error("failed to create location", "level", level.Name, "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+
+	logger.Info("created location", "level", level.Name, "id", id)
+	return id, nil
+}
+
+// Update changes a node of a level, keeping the fields the request omits.
+func (repo *Repository) Update(ctx context.Context, level Level, id int64, request Request) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	args := append([]any{id}, request.args()...)
+	if _, err := repo.db.ExecContext(ctx, level.updateQuery, args...); err != nil {
+		logger.Error("failed to update location", "level", level.Name, "id", id, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Debug("updated location", "level", level.Name, "id", id)
+	return nil
+}
+
+// Delete deletes a node of a level. The stored routine refuses while anything still refers to it.
+func (repo *Repository) Delete(ctx context.Context, level Level, id int64) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	if _, err := repo.db.ExecContext(ctx, level.deleteQuery, id); err != nil {
+		logger.Warn("failed to delete location", "level", level.Name, "id", id, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("deleted location", "level", level.Name, "id", id)
+	return nil
+}
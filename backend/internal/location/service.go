@@ -0,0 +1,261 @@
+// == Handles all logical operations related to the location hierarchy administration ==
+package location
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/department"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+)
+
+// Errors returned by the location administration.
+var (
+	ErrAdminOnly       = apperr.Forbidden("location_admin_forbidden", "only an admin can manage locations")
+	ErrNothingToUpdate = apperr.Validation("nothing_to_update", "the request changes no field")
+)
+
+// Tree is the whole location hierarchy, for pickers.
+type Tree struct {
+	Regions     []*Region                       `json:"regions"`
+	Departments []*department.DepartmentSummary `json:"departments"`
+}
+
+type Service struct {
+	repo        *Repository
+	deptService *department.Service
+	logger      *slog.Logger
+}
+
+// NewService creates a new location service.
+func NewService(repo *Repository, deptService *department.Service, logger *slog.Logger) *Service {
+	return &Service{
+		repo:        repo,
+		deptService: deptService,
+		logger:      logger,
+	}
+}
+
+// GetTree retrieves the area hierarchy along with the departments, which refer to their head office site.
+func (service *Service) GetTree(ctx context.Context) (*Tree, error) {
+	regions, err := service.repo.GetTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	departments, err := service.deptService.GetAllDepts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tree{Regions: regions, Departments: departments}, nil
+}
+
+// Create creates a node of a level on behalf of L1 support and returns it.
+func (service *Service) Create(ctx context.Context, adminPosition string, level Level, request Request) (map[string]any, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !isLocationAdmin(adminPosition) {
+		logger.Warn("location creation denied", "position", adminPosition, "level", level.Name)
+		return nil, ErrAdminOnly
+	}
+	if err := request.validate(true); err != nil {
+		return nil, err
+	}
+
+	id, err := service.repo.Create(ctx, level, request)
+	if err != nil {
+		return nil, err
+	}
+	created, err := service.repo.GetNode(ctx, level, id)
+	if err != nil {
+		return nil, err
+	}
+	if created == nil {
+		return nil, level.notFound
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "location." + level.Name + ".create",
+		EntityType: level.Name,
+		EntityID:   strconv.FormatInt(id, 10),
+		After:      created,
+	})
+	return created, nil
+}
+
+// Update changes a node of a level on behalf of L1 support and returns it.
+func (service *Service) Update(ctx context.Context, adminPosition string, level Level, id int64, request Request) (map[string]any, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !isLocationAdmin(adminPosition) {
+		logger.Warn("location update denied", "position", adminPosition, "level", level.Name, "id", id)
+		return nil, ErrAdminOnly
+	}
+	if err := request.validate(false); err != nil {
+		return nil, err
+	}
+
+	before, err := service.repo.GetNode(ctx, level, id)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, level.notFound
+	}
+
+	if err := service.repo.Update(ctx, level, id, request); err != nil {
+		return nil, err
+	}
+	after, err := service.repo.GetNode(ctx, level, id)
+	if err != nil {
+		return nil, err
+	}
+	if after == nil {
+		return nil, level.notFound
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "location." + level.Name + ".update",
+		EntityType: level.Name,
+		EntityID:   strconv.FormatInt(id, 10),
+		Before:     before,
+		After:      after,
+	})
+	return after, nil
+}
+
+// Delete deletes a node of a level on behalf of L1 support. It is refused while assets, users, opname sessions
+// or lower levels still refer to the node.
+func (service *Service) Delete(ctx context.Context, adminPosition string, level Level, id int64) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !isLocationAdmin(adminPosition) {
+		logger.Warn("location deletion denied", "position", adminPosition, "level", level.Name, "id", id)
+		return ErrAdminOnly
+	}
+
+	before, err := service.repo.GetNode(ctx, level, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return level.notFound
+	}
+
+	if err := service.repo.Delete(ctx, level, id); err != nil {
+		return err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "location." + level.Name + ".delete",
+		EntityType: level.Name,
+		EntityID:   strconv.FormatInt(id, 10),
+		Before:     before,
+	})
+	return nil
+}
+
+func (request *RegionRequest) validate(create bool) error {
+	if err := checkName(&request.RegionName, "region_name", create); err != nil {
+		return err
+	}
+	if request.RegionName == nil {
+		return ErrNothingToUpdate
+	}
+	return nil
+}
+
+func (request *SiteGroupRequest) validate(create bool) error {
+	if err := checkName(&request.SiteGroupName, "site_group_name", create); err != nil {
+		return err
+	}
+	if err := checkID(request.RegionID, "region_id", create); err != nil {
+		return err
+	}
+	if request.SiteGroupName == nil && request.RegionID == nil {
+		return ErrNothingToUpdate
+	}
+	return nil
+}
+
+func (request *SiteRequest) validate(create bool) error {
+	if err := checkName(&request.SiteName, "site_name", create); err != nil {
+		return err
+	}
+	if err := checkID(request.SiteGroupID, "site_group_id", create); err != nil {
+		return err
+	}
+	if err := checkID(request.SiteGaID, "site_ga_id", create); err != nil {
+		return err
+	}
+	if request.SiteName == nil && request.SiteGroupID == nil && request.SiteGaID == nil && request.IsHeadOffice == nil {
+		return ErrNothingToUpdate
+	}
+	return nil
+}
+
+func (request *SubSiteRequest) validate(create bool) error {
+	if err := checkName(&request.SubSiteName, "sub_site_name", create); err != nil {
+		return err
+	}
+	if err := checkID(request.SiteID, "site_id", create); err != nil {
+		return err
+	}
+	if request.SubSiteName == nil && request.SiteID == nil {
+		return ErrNothingToUpdate
+	}
+	return nil
+}
+
+func (request *DepartmentRequest) validate(create bool) error {
+	if err := checkName(&request.DeptName, "dept_name", create); err != nil {
+		return err
+	}
+	if err := checkID(request.SiteID, "site_id", false); err != nil {
+		return err
+	}
+	if request.DeptName == nil && request.SiteID == nil {
+		return ErrNothingToUpdate
+	}
+	return nil
+}
+
+// checkName trims a name and requires it to be set when creating. Names must fit the VARCHAR(100) columns.
+func checkName(name **string, field string, create bool) error {
+	if *name == nil {
+		if create {
+			return apperr.Validation("missing_field", field+" is required")
+		}
+		return nil
+	}
+	trimmed := strings.TrimSpace(**name)
+	if trimmed == "" || len(trimmed) > 100 {
+		return apperr.Validation("invalid_name", field+" must be between 1 and 100 characters long")
+	}
+	*name = &trimmed
+	return nil
+}
+
+// checkID requires a reference to be set when creating, and to be positive.
+func checkID(id *int64, field string, create bool) error {
+	if id == nil {
+		if create {
+			return apperr.Validation("missing_field", field+" is required")
+		}
+		return nil
+	}
+	if *id <= 0 {
+		return apperr.Validation("invalid_id", field+" must be a positive integer")
+	}
+	return nil
+}
+
+// isLocationAdmin reports whether a position may manage the location hierarchy, which the admin positions do.
+func isLocationAdmin(position string) bool {
+	return roles.IsAdmin(position)
+}
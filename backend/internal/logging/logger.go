@@ -0,0 +1,91 @@
+// == Provides the structured logger (log/slog) shared by every package ==
+// == Handles log level/format configuration and redaction of secrets ==
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Output formats supported by New.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// redactedValue replaces the value of any attribute that looks like a secret.
+const redactedValue = "[REDACTED]"
+
+// sensitiveKeys are attribute keys (lower case, matched as substrings) whose values must never reach the logs.
+var sensitiveKeys = []string{
+	"password",
+	"secret",
+	"token",
+	"authorization",
+	"api_key",
+	"apikey",
+	"cookie",
+	"dsn",
+}
+
+// ParseLevel converts a level name (debug, info, warn, error) into a slog.Level, defaulting to info.
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// New creates a logger writing to w at the given level, as JSON (default) or human readable text.
+func New(w io.Writer, level slog.Leveler, format string) *slog.Logger {
+	options := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}
+
+	if strings.ToLower(format) == FormatText {
+		return slog.New(slog.NewTextHandler(w, options))
+	}
+	return slog.New(slog.NewJSONHandler(w, options))
+}
+
+// IsSensitiveKey reports whether an attribute key names a secret.
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitiveKey := range sensitiveKeys {
+		if strings.Contains(key, sensitiveKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAttr hides secret values, including bearer tokens that ended up in a non-sensitive attribute.
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if IsSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, redactedValue)
+	}
+
+	if attr.Value.Kind() == slog.KindString {
+		if value := attr.Value.String(); strings.HasPrefix(strings.ToLower(value), "bearer ") {
+			return slog.String(attr.Key, "Bearer "+redactedValue)
+		}
+	}
+
+	return attr
+}
+
+// Optional dereferences an optional parameter so it logs as its value (or null) instead of a pointer address.
+func Optional[T any](value *T) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
@@ -0,0 +1,111 @@
+// == Provides request-scoped loggers carrying the correlation ID, user and session ==
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is read from incoming requests (e.g. set by a reverse proxy) and echoed in every response.
+const RequestIDHeader = "X-Request-ID"
+
+// loggerKey is where the request-scoped logger lives in the gin and request contexts.
+const loggerKey = "logger"
+
+type contextKey struct{}
+
+// validRequestID guards against log injection through a client supplied request ID.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestMiddleware assigns every request a correlation ID, stores a logger carrying it, and logs the request once it completes.
+func RequestMiddleware(base *slog.Logger) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+
+		requestID := context.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		context.Header(RequestIDHeader, requestID)
+		context.Set("request_id", requestID)
+
+		setLogger(context, base.With("request_id", requestID))
+
+		context.Next()
+
+		// Fetch the logger again so attributes added downstream (user_id, session_id) are included.
+		logger := FromGin(context, base)
+		status := context.Writer.Status()
+		attrs := []any{
+			"method", context.Request.Method,
+			"route", context.FullPath(),
+			"path", context.Request.URL.Path,
+			"status", status,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", context.ClientIP(),
+		}
+		if len(context.Errors) > 0 {
+			attrs = append(attrs, "errors", context.Errors.String())
+		}
+
+		switch {
+		case status >= 500:
+			logger.Error("request completed", attrs...)
+		case status >= 400:
+			logger.Warn("request completed", attrs...)
+		default:
+			logger.Info("request completed", attrs...)
+		}
+	}
+}
+
+// ParamMiddleware adds a route parameter (e.g. session-id) to the request logger under the given key.
+func ParamMiddleware(param, key string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if value := context.Param(param); value != "" {
+			With(context, key, value)
+		}
+		context.Next()
+	}
+}
+
+// With adds attributes to the request-scoped logger for the rest of the request.
+func With(context *gin.Context, args ...any) *slog.Logger {
+	logger := FromGin(context, slog.Default()).With(args...)
+	setLogger(context, logger)
+	return logger
+}
+
+// FromGin returns the request-scoped logger, or fallback when the request did not pass through RequestMiddleware.
+func FromGin(context *gin.Context, fallback *slog.Logger) *slog.Logger {
+	if value, exists := context.Get(loggerKey); exists {
+		if logger, ok := value.(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return fallback
+}
+
+// WithLogger stores a logger in a standard context.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx, or fallback when there is none.
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
+// setLogger stores the logger on both the gin context and the request context.
+func setLogger(context *gin.Context, logger *slog.Logger) {
+	context.Set(loggerKey, logger)
+	context.Request = context.Request.WithContext(WithLogger(context.Request.Context(), logger))
+}
@@ -0,0 +1,121 @@
+// == Provides Prometheus metrics for HTTP traffic, the DB pool, BAP generation, email delivery and logins ==
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric exposed by the application.
+const namespace = "sosmit"
+
+// Results used as label values for operations that can fail.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Registry holds every application metric plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// httpRequestDuration measures request latency per route template (not raw path, to keep cardinality bounded).
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// bapGenerationDuration measures how long rendering a BAP PDF takes, wkhtmltopdf included.
+	bapGenerationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "report",
+		Name:      "bap_generation_duration_seconds",
+		Help:      "Time taken to generate a BAP PDF by result.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60},
+	}, []string{"result"})
+
+	// emailsSent counts SendGrid deliveries per template and result.
+	emailsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "email",
+		Name:      "sent_total",
+		Help:      "Emails sent through SendGrid by template and result.",
+	}, []string{"template", "result"})
+
+	// loginAttempts counts login attempts by outcome, "success" or the reason they were refused.
+	loginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "login_attempts_total",
+		Help:      "Login attempts by outcome.",
+	}, []string{"outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestDuration,
+		bapGenerationDuration,
+		emailsSent,
+		loginAttempts,
+	)
+}
+
+// RegisterDB exposes the connection pool statistics (open, in use, idle, wait count/duration) of db.
+func RegisterDB(db *sql.DB, dbName string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// Middleware records the latency of every request under its route template.
+func Middleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+		context.Next()
+
+		route := context.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequestDuration.
+			WithLabelValues(context.Request.Method, route, strconv.Itoa(context.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveBAPGeneration records the duration of one BAP PDF generation.
+func ObserveBAPGeneration(start time.Time, err error) {
+	bapGenerationDuration.WithLabelValues(result(err)).Observe(time.Since(start).Seconds())
+}
+
+// CountEmail records one email send attempt.
+func CountEmail(templateName string, err error) {
+	emailsSent.WithLabelValues(templateName, result(err)).Inc()
+}
+
+// CountLoginAttempt records one login attempt.
+func CountLoginAttempt(outcome string) {
+	loginAttempts.WithLabelValues(outcome).Inc()
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
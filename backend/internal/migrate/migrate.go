@@ -0,0 +1,352 @@
+// == Applies the versioned SQL migrations embedded in migrations/ and tracks them in schema_version ==
+// == Files are named NNNN_description.up.sql / NNNN_description.down.sql and applied in version order ==
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// advisoryLockID ("SOSMIT" in hex) serialises migrators when several API instances start at the same time.
+const advisoryLockID int64 = 0x534F534D4954
+
+// baselineVersion is the schema that databases created with the old init.sql already have.
+const baselineVersion = 1
+
+// migrationFilePattern matches e.g. 0003_add_asset_loans.up.sql.
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of the up script, detects edits to already applied migrations
+}
+
+// Status describes one migration and whether it has been applied.
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// ErrChecksumMismatch is returned when an applied migration file was modified afterwards.
+var ErrChecksumMismatch = errors.New("migration checksum mismatch")
+
+// Migrator applies and rolls back migrations on a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	logger     *slog.Logger
+}
+
+// NewMigrator creates a migrator using the migrations embedded in the binary.
+func NewMigrator(db *sql.DB, logger *slog.Logger) (*Migrator, error) {
+	migrationsFS, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := Load(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, logger: logger}, nil
+}
+
+// Load reads every migration pair from fsys, sorted by version.
+// Every version needs both an up and a down script, and versions must be unique.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s does not match NNNN_name.(up|down).sql", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		name, direction := match[2], match[3]
+
+		content, err := fs.ReadFile(fsys, path.Clean(entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, name)
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+			sum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction, and returns how many were applied.
+func (migrator *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := migrator.withLock(ctx, func(conn *sql.Conn) error {
+		if err := migrator.adoptBaseline(ctx, conn); err != nil {
+			return err
+		}
+
+		appliedVersions, err := migrator.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrator.migrations {
+			if _, done := appliedVersions[migration.Version]; done {
+				continue
+			}
+			if err := migrator.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first.
+func (migrator *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	rolledBack := 0
+	err := migrator.withLock(ctx, func(conn *sql.Conn) error {
+		appliedVersions, err := migrator.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for index := len(migrator.migrations) - 1; index >= 0 && rolledBack < steps; index-- {
+			migration := migrator.migrations[index]
+			if _, done := appliedVersions[migration.Version]; !done {
+				continue
+			}
+			if err := migrator.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status lists every known migration and when it was applied.
+func (migrator *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := migrator.withLock(ctx, func(conn *sql.Conn) error {
+		appliedVersions, err := migrator.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrator.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if appliedAt, done := appliedVersions[migration.Version]; done {
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock, creating schema_version if needed.
+func (migrator *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := migrator.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if ctx was cancelled.
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockID); err != nil {
+			migrator.logger.Error("failed to release migration lock", "error", err)
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			"version" INT PRIMARY KEY,
+			"name" VARCHAR(255) NOT NULL,
+			"checksum" CHAR(64) NOT NULL,
+			"applied_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			"execution_ms" INT NOT NULL DEFAULT 0
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// verify returns the applied versions and fails if an applied migration was edited or no longer exists.
+func (migrator *Migrator) verify(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT "version", "name", "checksum", "applied_at" FROM schema_version ORDER BY "version"`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_version: %w", err)
+	}
+	defer rows.Close()
+
+	known := make(map[int]Migration, len(migrator.migrations))
+	for _, migration := range migrator.migrations {
+		known[migration.Version] = migration
+	}
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var name, checksum string
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &name, &checksum, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		migration, exists := known[version]
+		if !exists {
+			return nil, fmt.Errorf("database is at migration %04d_%s which this binary does not know, refusing to continue", version, name)
+		}
+		if migration.Checksum != checksum {
+			return nil, fmt.Errorf("%w: %04d_%s was modified after it was applied, add a new migration instead", ErrChecksumMismatch, version, name)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// adoptBaseline marks the baseline schema as applied on databases created with the old init.sql,
+// so their data is kept instead of failing on tables that already exist.
+func (migrator *Migrator) adoptBaseline(ctx context.Context, conn *sql.Conn) error {
+	var recorded int
+	if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_version`).Scan(&recorded); err != nil {
+		return fmt.Errorf("failed to read schema_version: %w", err)
+	}
+	if recorded > 0 {
+		return nil
+	}
+
+	var hasSchema bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass('public."Asset"') IS NOT NULL`).Scan(&hasSchema); err != nil {
+		return fmt.Errorf("failed to inspect existing schema: %w", err)
+	}
+	if !hasSchema {
+		return nil
+	}
+
+	for _, migration := range migrator.migrations {
+		if migration.Version != baselineVersion {
+			continue
+		}
+		_, err := conn.ExecContext(ctx,
+			`INSERT INTO schema_version ("version", "name", "checksum") VALUES ($1, $2, $3)`,
+			migration.Version, migration.Name, migration.Checksum)
+		if err != nil {
+			return fmt.Errorf("failed to adopt baseline schema: %w", err)
+		}
+		migrator.logger.Warn("existing schema found without schema_version, marked baseline as applied", "version", migration.Version, "name", migration.Name)
+	}
+	return nil
+}
+
+// apply runs one up script and records it in the same transaction.
+func (migrator *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	start := time.Now()
+	err := inTransaction(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_version ("version", "name", "checksum", "execution_ms") VALUES ($1, $2, $3, $4)`,
+			migration.Version, migration.Name, migration.Checksum, time.Since(start).Milliseconds())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+
+	migrator.logger.Info("applied migration", "version", migration.Version, "name", migration.Name, "elapsed_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// revert runs one down script and removes its record in the same transaction.
+func (migrator *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	start := time.Now()
+	err := inTransaction(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM schema_version WHERE "version" = $1`, migration.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("rollback of migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+
+	migrator.logger.Info("rolled back migration", "version", migration.Version, "name", migration.Name, "elapsed_ms", time.Since(start).Milliseconds())
+	return nil
+}
+
+// inTransaction commits when fn succeeds and rolls back otherwise.
+func inTransaction(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,15 @@
+-- Drops every baseline table. This deletes ALL data.
+-- Drop tables in reverse order to avoid foreign key constraint violations.
+DROP TABLE IF EXISTS "AssetChanges" CASCADE;
+DROP TABLE IF EXISTS "OpnameSession" CASCADE;
+DROP TABLE IF EXISTS "AssetEquipments" CASCADE;
+DROP TABLE IF EXISTS "Asset" CASCADE;
+DROP TABLE IF EXISTS "ApprovalPath" CASCADE;
+DROP TABLE IF EXISTS "User" CASCADE;
+DROP TABLE IF EXISTS "CostCenter" CASCADE;
+DROP TABLE IF EXISTS "SubSite" CASCADE;
+DROP TABLE IF EXISTS "Department" CASCADE;
+DROP TABLE IF EXISTS "Site" CASCADE;
+DROP TABLE IF EXISTS "SiteGroup" CASCADE;
+DROP TABLE IF EXISTS "Region" CASCADE;
+DROP TABLE IF EXISTS "Notification" CASCADE;
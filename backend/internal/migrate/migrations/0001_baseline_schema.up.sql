@@ -1,21 +1,5 @@
--- Run this script to initialize the database schema.
--- Run this script only once.
-
--- == CLEAR ALL EXISTING TABLES ==
--- Drop tables in reverse order to avoid foreign key constraint violations.
-DROP TABLE IF EXISTS "AssetChanges" CASCADE;
-DROP TABLE IF EXISTS "OpnameSession" CASCADE;
-DROP TABLE IF EXISTS "AssetEquipments" CASCADE;
-DROP TABLE IF EXISTS "Asset" CASCADE;
-DROP TABLE IF EXISTS "ApprovalPath" CASCADE;
-DROP TABLE IF EXISTS "User" CASCADE;
-DROP TABLE IF EXISTS "CostCenter" CASCADE;
-DROP TABLE IF EXISTS "SubSite" CASCADE;
-DROP TABLE IF EXISTS "Department" CASCADE;
-DROP TABLE IF EXISTS "Site" CASCADE;
-DROP TABLE IF EXISTS "SiteGroup" CASCADE;
-DROP TABLE IF EXISTS "Region" CASCADE;
-DROP TABLE IF EXISTS "Notification" CASCADE;
+-- Baseline schema: the tables formerly created by seed/init.sql.
+-- Databases that were initialised with init.sql are adopted at this version instead of running it again.
 
 -- == LOCATION HIERARCHY TABLES ==
 -- Region (Top level)
@@ -0,0 +1,41 @@
+-- Drops every baseline stored function and procedure.
+DROP FUNCTION IF EXISTS public.get_credentials(VARCHAR);
+DROP FUNCTION IF EXISTS public.get_all_users();
+DROP FUNCTION IF EXISTS public.get_user_by_id(INT);
+DROP FUNCTION IF EXISTS public.get_user_by_username(VARCHAR);
+DROP FUNCTION IF EXISTS public.get_latest_opname_status(INT, INT);
+DROP FUNCTION IF EXISTS public.get_user_opname_locations(INT, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, TIMESTAMP, TIMESTAMP, VARCHAR, INT, INT);
+DROP FUNCTION IF EXISTS public.get_l1_support_emails();
+DROP FUNCTION IF EXISTS public.get_area_manager_info(INT);
+DROP FUNCTION IF EXISTS public.get_asset_by_tag(VARCHAR);
+DROP FUNCTION IF EXISTS public.get_asset_by_serial_number(VARCHAR);
+DROP FUNCTION IF EXISTS public.get_assets_by_location(INT, INT);
+DROP FUNCTION IF EXISTS public.get_assets_by_sub_site(INT);
+DROP FUNCTION IF EXISTS public.create_new_opname_session(INT, INT);
+DROP FUNCTION IF EXISTS public.create_new_opname_session(INT, INT, INT);
+DROP FUNCTION IF EXISTS public.get_opname_session_by_id(INT);
+DROP FUNCTION IF EXISTS public.get_user_from_opname_session(INT);
+DROP PROCEDURE IF EXISTS public.finish_opname_session(INT);
+DROP PROCEDURE IF EXISTS public.delete_opname_session(INT);
+DROP PROCEDURE IF EXISTS public.approve_opname_session(INT, INT);
+DROP PROCEDURE IF EXISTS public.reject_opname_session(INT, INT);
+DROP FUNCTION IF EXISTS public.record_asset_change(INT, VARCHAR(12), VARCHAR(50), VARCHAR(20), VARCHAR(20), INT, TEXT, TEXT, TEXT, VARCHAR(255), VARCHAR(255), TEXT, INT, VARCHAR(255), VARCHAR(100), VARCHAR(100), INT, INT, INT, TEXT, VARCHAR(25));
+DROP FUNCTION IF EXISTS public.get_asset_change(INT, VARCHAR);
+DROP PROCEDURE IF EXISTS public.delete_asset_change(INT, VARCHAR(12));
+DROP PROCEDURE IF EXISTS public.set_action_notes(VARCHAR(12), INT, INT, TEXT);
+DROP PROCEDURE IF EXISTS public.delete_action_notes(VARCHAR(12), INT, INT);
+DROP FUNCTION IF EXISTS public.get_asset_change_photo(INT, VARCHAR(12));
+DROP FUNCTION IF EXISTS public.get_all_photos_by_session_id(INT);
+DROP FUNCTION IF EXISTS public.get_all_sites();
+DROP FUNCTION IF EXISTS public.get_all_sub_sites();
+DROP FUNCTION IF EXISTS public.get_site_by_id(INT);
+DROP FUNCTION IF EXISTS public.get_dept_by_id(INT);
+DROP FUNCTION IF EXISTS public.get_sub_site_by_id(INT);
+DROP FUNCTION IF EXISTS public.get_sub_sites_by_site_id(INT);
+DROP FUNCTION IF EXISTS public.load_opname_progress(INT);
+DROP FUNCTION IF EXISTS public.get_finished_opnames_by_location_id(INT, INT);
+DROP FUNCTION IF EXISTS public.get_asset_equipments(VARCHAR(50));
+DROP FUNCTION IF EXISTS public.categorize_opname_assets(INT);
+DROP FUNCTION IF EXISTS public.get_opname_stats(INT);
+DROP FUNCTION IF EXISTS public.get_opname_bap_recap(INT);
+DROP FUNCTION IF EXISTS public.get_opname_bap_details(INT);
@@ -1,3 +1,6 @@
+-- Baseline stored functions and procedures, formerly model/init_funcs_and_procs.sql.
+-- Later migrations change a function with CREATE OR REPLACE, or DROP and CREATE when its signature changes.
+
 DROP FUNCTION IF EXISTS public.get_credentials(VARCHAR);
 DROP FUNCTION IF EXISTS public.get_all_users();
 DROP FUNCTION IF EXISTS public.get_user_by_id(INT);
@@ -9,6 +12,7 @@ DROP FUNCTION IF EXISTS public.get_area_manager_info(INT);
 DROP FUNCTION IF EXISTS public.get_asset_by_tag(VARCHAR);
 DROP FUNCTION IF EXISTS public.get_asset_by_serial_number(VARCHAR);
 DROP FUNCTION IF EXISTS public.get_assets_by_location(INT, INT);
+DROP FUNCTION IF EXISTS public.get_assets_by_sub_site(INT);
 DROP FUNCTION IF EXISTS public.create_new_opname_session(INT, INT);
 DROP FUNCTION IF EXISTS public.create_new_opname_session(INT, INT, INT);
 DROP FUNCTION IF EXISTS public.get_opname_session_by_id(INT);
@@ -525,6 +529,22 @@ AS $$
 	END;
 $$;
 
+-- get_assets_by_sub_site retrieves all assets placed in a given sub-site (used for printing asset labels per area)
+CREATE OR REPLACE FUNCTION public.get_assets_by_sub_site(_sub_site_id INT)
+	RETURNS TABLE (
+		asset_tag VARCHAR(12)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT a.asset_tag
+			FROM "Asset" AS a
+			WHERE a.sub_site_id = _sub_site_id
+			ORDER BY a.asset_tag;
+	END;
+$$;
+
 -- create_new_opname_session creates a new opname session for a site or department
 CREATE OR REPLACE FUNCTION public.create_new_opname_session(
 	-- The ID of the user creating the session (from JWT).
@@ -0,0 +1,3 @@
+-- Drops the opname authorization functions.
+DROP FUNCTION IF EXISTS public.is_opname_session_reviewer(INT, INT);
+DROP FUNCTION IF EXISTS public.is_location_assignee(INT, INT, INT);
@@ -0,0 +1,63 @@
+-- Functions used by the opname authorization policy (internal/opname/policy.go).
+
+-- is_location_assignee checks whether a user may start an opname session on a location.
+-- Sites are assigned to their GA staff through "Site".site_ga_id, departments to the users working in them.
+CREATE OR REPLACE FUNCTION public.is_location_assignee(_user_id INT, _site_id INT DEFAULT NULL, _dept_id INT DEFAULT NULL)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF _site_id IS NOT NULL THEN
+			RETURN EXISTS (
+				SELECT 1
+				FROM "Site" AS s
+				WHERE s.id = _site_id AND s.site_ga_id = _user_id
+			);
+		END IF;
+
+		RETURN EXISTS (
+			SELECT 1
+			FROM "User" AS u
+			INNER JOIN "Department" AS d ON LOWER(u.department) = LOWER(d.dept_name)
+			WHERE u.user_id = _user_id AND d.id = _dept_id
+		);
+	END;
+$$;
+
+-- is_opname_session_reviewer checks whether a user is the next reviewer on the approval path of a session's location.
+-- Submitted sessions are reviewed by an area manager of the location's region, escalated sessions by L1 support.
+-- Department sessions belong to the head office (site ID 25, see get_user_opname_locations).
+CREATE OR REPLACE FUNCTION public.is_opname_session_reviewer(_session_id INT, _user_id INT)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_status VARCHAR(20);
+		_session_region_id INT;
+		_user_position VARCHAR(100);
+		_user_region_id INT;
+	BEGIN
+		SELECT os.status, sg.region_id
+		INTO _status, _session_region_id
+		FROM "OpnameSession" AS os
+		LEFT JOIN "Site" AS s ON s.id = COALESCE(os.site_id, CASE WHEN os.dept_id IS NOT NULL THEN 25 END)
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		WHERE os.id = _session_id;
+
+		SELECT LOWER(u.position), sg.region_id
+		INTO _user_position, _user_region_id
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		WHERE u.user_id = _user_id;
+
+		IF _status = 'Submitted' THEN
+			RETURN _user_position = 'area manager' AND _user_region_id = _session_region_id;
+		ELSIF _status = 'Escalated' THEN
+			RETURN _user_position = 'l1 support';
+		END IF;
+
+		-- Sessions that are not waiting for a review have no reviewer.
+		RETURN FALSE;
+	END;
+$$;
@@ -0,0 +1,5 @@
+-- Drops the audit log. This deletes every recorded entry.
+DROP FUNCTION IF EXISTS public.get_audit_log(INT, VARCHAR, VARCHAR, VARCHAR, TIMESTAMP, TIMESTAMP, INT, INT);
+DROP FUNCTION IF EXISTS public.record_audit_event(INT, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, JSONB, JSONB, VARCHAR, TEXT, VARCHAR, INT);
+DROP TABLE IF EXISTS "AuditLog" CASCADE;
+DROP FUNCTION IF EXISTS public.prevent_audit_log_change();
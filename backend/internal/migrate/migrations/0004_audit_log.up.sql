@@ -0,0 +1,141 @@
+-- Append-only audit log of user actions (internal/audit).
+
+CREATE TABLE "AuditLog" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "occurred_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- The acting user. Not a foreign key so the history survives when users are removed.
+    "actor_id" INT,
+    "actor_username" VARCHAR(255) NOT NULL DEFAULT '',
+    "actor_position" VARCHAR(100) NOT NULL DEFAULT '',
+
+    -- What was done to which record, e.g. action 'opname.cancel' on entity 'opname_session' '42'.
+    "action" VARCHAR(50) NOT NULL,
+    "entity_type" VARCHAR(50) NOT NULL,
+    "entity_id" VARCHAR(100) NOT NULL DEFAULT '',
+
+    -- Snapshots of the record before and after the action, NULL when not applicable.
+    "before" JSONB,
+    "after" JSONB,
+
+    -- Request metadata.
+    "ip_address" VARCHAR(45) NOT NULL DEFAULT '',
+    "user_agent" TEXT NOT NULL DEFAULT '',
+    "request_id" VARCHAR(64) NOT NULL DEFAULT '',
+    "status_code" INT
+);
+
+CREATE INDEX idx_audit_log_occurred_at ON "AuditLog" ("occurred_at" DESC);
+CREATE INDEX idx_audit_log_entity ON "AuditLog" ("entity_type", "entity_id");
+CREATE INDEX idx_audit_log_actor ON "AuditLog" ("actor_id");
+
+-- prevent_audit_log_change keeps the audit log append-only.
+CREATE OR REPLACE FUNCTION public.prevent_audit_log_change()
+	RETURNS TRIGGER
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RAISE EXCEPTION 'AuditLog is append-only, % is not allowed', TG_OP;
+	END;
+$$;
+
+CREATE TRIGGER audit_log_no_update_or_delete
+	BEFORE UPDATE OR DELETE ON "AuditLog"
+	FOR EACH ROW EXECUTE FUNCTION prevent_audit_log_change();
+
+CREATE TRIGGER audit_log_no_truncate
+	BEFORE TRUNCATE ON "AuditLog"
+	FOR EACH STATEMENT EXECUTE FUNCTION prevent_audit_log_change();
+
+-- record_audit_event appends one entry to the audit log and returns its ID.
+CREATE OR REPLACE FUNCTION public.record_audit_event(
+	_actor_id INT,
+	_actor_username VARCHAR(255),
+	_actor_position VARCHAR(100),
+	_action VARCHAR(50),
+	_entity_type VARCHAR(50),
+	_entity_id VARCHAR(100),
+	_before JSONB,
+	_after JSONB,
+	_ip_address VARCHAR(45),
+	_user_agent TEXT,
+	_request_id VARCHAR(64),
+	_status_code INT
+) RETURNS BIGINT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_new_id BIGINT;
+	BEGIN
+		INSERT INTO "AuditLog" (actor_id, actor_username, actor_position, "action", entity_type, entity_id, "before", "after", ip_address, user_agent, request_id, status_code)
+		VALUES (_actor_id, COALESCE(_actor_username, ''), COALESCE(_actor_position, ''), _action, _entity_type, COALESCE(_entity_id, ''), _before, _after, COALESCE(_ip_address, ''), COALESCE(_user_agent, ''), COALESCE(_request_id, ''), _status_code)
+		RETURNING id INTO _new_id;
+
+		RETURN _new_id;
+	END;
+$$;
+
+-- get_audit_log retrieves audit entries, newest first. Every filter is optional.
+-- Pagination is done here, total_count is the number of entries matching the filters.
+CREATE OR REPLACE FUNCTION public.get_audit_log(
+	_actor_id INT,
+	_action VARCHAR(50),
+	_entity_type VARCHAR(50),
+	_entity_id VARCHAR(100),
+	_from_date TIMESTAMP,
+	_end_date TIMESTAMP,
+	_limit INT,
+	_page_number INT
+)
+	RETURNS TABLE (
+		id BIGINT,
+		occurred_at TIMESTAMP WITH TIME ZONE,
+		actor_id INT,
+		actor_username VARCHAR(255),
+		actor_position VARCHAR(100),
+		"action" VARCHAR(50),
+		entity_type VARCHAR(50),
+		entity_id VARCHAR(100),
+		"before" JSONB,
+		"after" JSONB,
+		ip_address VARCHAR(45),
+		user_agent TEXT,
+		request_id VARCHAR(64),
+		status_code INT,
+		total_count BIGINT
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_offset INT := GREATEST(COALESCE(_page_number,1)-1,0) * COALESCE(NULLIF(_limit,0),50);
+	BEGIN
+		RETURN QUERY
+		SELECT
+			al.id,
+			al.occurred_at,
+			al.actor_id,
+			al.actor_username,
+			al.actor_position,
+			al."action",
+			al.entity_type,
+			al.entity_id,
+			al."before",
+			al."after",
+			al.ip_address,
+			al.user_agent,
+			al.request_id,
+			al.status_code,
+			COUNT(*) OVER()::BIGINT AS total_count
+		FROM "AuditLog" AS al
+		WHERE
+			(_actor_id IS NULL OR al.actor_id = _actor_id)
+			-- A trailing dot matches a whole family of actions, e.g. 'opname.' matches 'opname.start' and 'opname.cancel'.
+			AND (_action IS NULL OR _action = '' OR al."action" = _action OR (RIGHT(_action, 1) = '.' AND al."action" LIKE _action || '%'))
+			AND (_entity_type IS NULL OR _entity_type = '' OR al.entity_type = _entity_type)
+			AND (_entity_id IS NULL OR _entity_id = '' OR al.entity_id = _entity_id)
+			AND (_from_date IS NULL OR al.occurred_at >= _from_date)
+			AND (_end_date IS NULL OR al.occurred_at < _end_date + INTERVAL '1 day')
+		ORDER BY al.occurred_at DESC, al.id DESC
+		LIMIT COALESCE(NULLIF(_limit,0), 50) OFFSET v_offset;
+	END;
+$$;
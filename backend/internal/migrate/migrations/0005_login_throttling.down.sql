@@ -0,0 +1,7 @@
+-- Drops the login throttling tables and functions. This deletes every recorded login attempt and lockout.
+DROP FUNCTION IF EXISTS public.unlock_account(VARCHAR, INT);
+DROP FUNCTION IF EXISTS public.lock_account(VARCHAR, TIMESTAMP WITH TIME ZONE);
+DROP FUNCTION IF EXISTS public.get_login_throttle(VARCHAR, VARCHAR, INT);
+DROP FUNCTION IF EXISTS public.record_login_attempt(VARCHAR, VARCHAR, BOOLEAN, VARCHAR);
+DROP TABLE IF EXISTS "AccountLockout";
+DROP TABLE IF EXISTS "LoginAttempt";
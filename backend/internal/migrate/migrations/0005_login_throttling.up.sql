@@ -0,0 +1,116 @@
+-- Login attempts and account lockouts used by the brute-force protection of POST /api/auth/login (internal/auth/throttle.go).
+
+-- Every login attempt, successful or not. Usernames are stored lowercased and need not exist,
+-- so unknown usernames are throttled and locked exactly like real ones.
+CREATE TABLE "LoginAttempt" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "username" VARCHAR(255) NOT NULL,
+    "ip_address" VARCHAR(45) NOT NULL DEFAULT '',
+    "succeeded" BOOLEAN NOT NULL,
+    -- Why a failed attempt was refused: invalid_credentials, no_access, throttled or locked.
+    "reason" VARCHAR(50) NOT NULL DEFAULT '',
+    "attempted_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_attempt_username ON "LoginAttempt" ("username", "attempted_at" DESC);
+CREATE INDEX idx_login_attempt_ip ON "LoginAttempt" ("ip_address", "attempted_at" DESC);
+
+-- Temporarily locked usernames. unlocked_at is set by an admin unlock and resets the failure count.
+CREATE TABLE "AccountLockout" (
+    "username" VARCHAR(255) PRIMARY KEY,
+    "locked_at" TIMESTAMP WITH TIME ZONE,
+    "locked_until" TIMESTAMP WITH TIME ZONE,
+    "unlocked_at" TIMESTAMP WITH TIME ZONE,
+    "unlocked_by" INT REFERENCES "User"(user_id) ON DELETE SET NULL
+);
+
+-- record_login_attempt stores one login attempt.
+CREATE OR REPLACE FUNCTION public.record_login_attempt(_username VARCHAR(255), _ip_address VARCHAR(45), _succeeded BOOLEAN, _reason VARCHAR(50))
+	RETURNS VOID
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		INSERT INTO "LoginAttempt" (username, ip_address, succeeded, reason)
+		VALUES (LOWER(TRIM(_username)), COALESCE(_ip_address, ''), _succeeded, COALESCE(_reason, ''));
+	END;
+$$;
+
+-- get_login_throttle returns the state the login throttle decides on, counting only failed password checks:
+-- the failures of the username since its last success or unlock within the window, the failures from the IP within the window,
+-- when the last username failure and the oldest IP failure happened, and until when the username is locked.
+CREATE OR REPLACE FUNCTION public.get_login_throttle(_username VARCHAR(255), _ip_address VARCHAR(45), _window_seconds INT)
+	RETURNS TABLE (
+		username_failures INT,
+		last_failure_at TIMESTAMP WITH TIME ZONE,
+		ip_failures INT,
+		oldest_ip_failure_at TIMESTAMP WITH TIME ZONE,
+		locked_until TIMESTAMP WITH TIME ZONE
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_username VARCHAR(255) := LOWER(TRIM(_username));
+		v_window_start TIMESTAMP WITH TIME ZONE := NOW() - make_interval(secs => _window_seconds);
+		v_since TIMESTAMP WITH TIME ZONE;
+	BEGIN
+		SELECT GREATEST(
+			v_window_start,
+			(SELECT MAX(la.attempted_at) FROM "LoginAttempt" AS la WHERE la.username = v_username AND la.succeeded),
+			(SELECT al.unlocked_at FROM "AccountLockout" AS al WHERE al.username = v_username)
+		) INTO v_since;
+
+		RETURN QUERY
+		SELECT
+			user_failures.total::INT,
+			user_failures.latest,
+			ip_failures.total::INT,
+			ip_failures.oldest,
+			(SELECT al.locked_until FROM "AccountLockout" AS al WHERE al.username = v_username AND al.locked_until > NOW())
+		FROM
+			(
+				SELECT COUNT(*) AS total, MAX(la.attempted_at) AS latest
+				FROM "LoginAttempt" AS la
+				WHERE la.username = v_username AND la.reason = 'invalid_credentials' AND la.attempted_at > v_since
+			) AS user_failures,
+			(
+				SELECT COUNT(*) AS total, MIN(la.attempted_at) AS oldest
+				FROM "LoginAttempt" AS la
+				WHERE la.ip_address = _ip_address AND la.reason = 'invalid_credentials' AND la.attempted_at > v_window_start
+			) AS ip_failures;
+	END;
+$$;
+
+-- lock_account locks a username until the given time.
+CREATE OR REPLACE FUNCTION public.lock_account(_username VARCHAR(255), _locked_until TIMESTAMP WITH TIME ZONE)
+	RETURNS VOID
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		INSERT INTO "AccountLockout" (username, locked_at, locked_until)
+		VALUES (LOWER(TRIM(_username)), NOW(), _locked_until)
+		ON CONFLICT (username) DO UPDATE
+		SET locked_at = EXCLUDED.locked_at, locked_until = EXCLUDED.locked_until;
+	END;
+$$;
+
+-- unlock_account lifts a lock and resets the failure count of a username. Returns whether the username was locked.
+CREATE OR REPLACE FUNCTION public.unlock_account(_username VARCHAR(255), _unlocked_by INT)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_username VARCHAR(255) := LOWER(TRIM(_username));
+		v_was_locked BOOLEAN;
+	BEGIN
+		SELECT EXISTS (
+			SELECT 1 FROM "AccountLockout" AS al WHERE al.username = v_username AND al.locked_until > NOW()
+		) INTO v_was_locked;
+
+		INSERT INTO "AccountLockout" (username, unlocked_at, unlocked_by)
+		VALUES (v_username, NOW(), _unlocked_by)
+		ON CONFLICT (username) DO UPDATE
+		SET locked_until = NULL, unlocked_at = EXCLUDED.unlocked_at, unlocked_by = EXCLUDED.unlocked_by;
+
+		RETURN v_was_locked;
+	END;
+$$;
@@ -0,0 +1,5 @@
+-- Removes the OIDC identity links. Users provisioned through SSO are kept but can no longer sign in.
+DROP FUNCTION IF EXISTS public.provision_oidc_user(VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR);
+DROP PROCEDURE IF EXISTS public.link_oidc_subject(INT, VARCHAR);
+DROP FUNCTION IF EXISTS public.get_credentials_by_oidc(VARCHAR, VARCHAR);
+ALTER TABLE "User" DROP COLUMN IF EXISTS "oidc_subject";
@@ -0,0 +1,73 @@
+-- Links "User" rows to OpenID Connect identities for single sign-on (internal/auth/oidc.go).
+
+-- The IdP's stable subject identifier, set on the first SSO login of a user.
+ALTER TABLE "User" ADD COLUMN "oidc_subject" VARCHAR(255) UNIQUE;
+
+-- get_credentials_by_oidc retrieves the credentials of the user linked to an OIDC subject.
+-- Without a linked user, it falls back to the unlinked users with the given email (which is not unique, so there may be several).
+CREATE OR REPLACE FUNCTION public.get_credentials_by_oidc(_subject VARCHAR(255), _email VARCHAR(255))
+	RETURNS TABLE (
+		user_id INT,
+		username VARCHAR(255),
+		"password" VARCHAR(255),
+		"position" VARCHAR(100),
+		ou_code VARCHAR(5),
+		oidc_subject VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT u.user_id, u.username, u.password, u.position, u.ou_code, u.oidc_subject
+		FROM "User" AS u
+		WHERE u.oidc_subject = _subject AND LOWER(u.username) <> 'vacant';
+
+		IF FOUND OR COALESCE(_email, '') = '' THEN
+			RETURN;
+		END IF;
+
+		RETURN QUERY
+		SELECT u.user_id, u.username, u.password, u.position, u.ou_code, u.oidc_subject
+		FROM "User" AS u
+		WHERE LOWER(u.email) = LOWER(_email) AND u.oidc_subject IS NULL AND LOWER(u.username) <> 'vacant'
+		ORDER BY u.user_id;
+	END;
+$$;
+
+-- link_oidc_subject links a user to an OIDC subject, unless the user is already linked.
+CREATE OR REPLACE PROCEDURE public.link_oidc_subject(_user_id INT, _subject VARCHAR(255))
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "User" AS u
+		SET oidc_subject = _subject
+		WHERE u.user_id = _user_id AND u.oidc_subject IS NULL;
+	END;
+$$;
+
+-- provision_oidc_user creates a user for an OIDC identity on its first login and returns the new user ID.
+-- Provisioned users have no password, so they can only sign in through SSO.
+CREATE OR REPLACE FUNCTION public.provision_oidc_user(
+	_subject VARCHAR(255),
+	_username VARCHAR(255),
+	_email VARCHAR(255),
+	_first_name VARCHAR(255),
+	_last_name VARCHAR(255),
+	_position VARCHAR(100),
+	_ou_code VARCHAR(5)
+) RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_user_id INT;
+	BEGIN
+		-- user_id is not generated by the table, serialize concurrent provisioning so two logins cannot pick the same ID.
+		LOCK TABLE "User" IN SHARE ROW EXCLUSIVE MODE;
+		SELECT COALESCE(MAX(u.user_id), 1) + 1 INTO v_user_id FROM "User" AS u;
+
+		INSERT INTO "User" (user_id, username, email, password, first_name, last_name, position, ou_code, oidc_subject)
+		VALUES (v_user_id, _username, COALESCE(_email, ''), '', COALESCE(_first_name, ''), COALESCE(_last_name, ''), COALESCE(_position, ''), COALESCE(_ou_code, ''), _subject);
+
+		RETURN v_user_id;
+	END;
+$$;
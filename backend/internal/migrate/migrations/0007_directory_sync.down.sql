@@ -0,0 +1,92 @@
+-- Removes directory synchronisation. Deactivated users become active again.
+CREATE OR REPLACE FUNCTION public.get_credentials(_username VARCHAR(255))
+    RETURNS table (
+		user_id INT,
+        username VARCHAR(255),
+        "password" VARCHAR(255),
+		"position" VARCHAR(100),
+        ou_code VARCHAR(5)
+    )
+    LANGUAGE plpgsql
+AS $$
+    BEGIN
+        RETURN QUERY
+        SELECT u.user_id, u.username, u.password, u.position, u.ou_code
+        FROM "User" AS u
+        WHERE LOWER(u.username) = LOWER(_username) AND LOWER(u.username) <> 'vacant'; -- Block users trying to login using 'vacant' username
+    END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_credentials_by_oidc(_subject VARCHAR(255), _email VARCHAR(255))
+	RETURNS TABLE (
+		user_id INT,
+		username VARCHAR(255),
+		"password" VARCHAR(255),
+		"position" VARCHAR(100),
+		ou_code VARCHAR(5),
+		oidc_subject VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT u.user_id, u.username, u.password, u.position, u.ou_code, u.oidc_subject
+		FROM "User" AS u
+		WHERE u.oidc_subject = _subject AND LOWER(u.username) <> 'vacant';
+
+		IF FOUND OR COALESCE(_email, '') = '' THEN
+			RETURN;
+		END IF;
+
+		RETURN QUERY
+		SELECT u.user_id, u.username, u.password, u.position, u.ou_code, u.oidc_subject
+		FROM "User" AS u
+		WHERE LOWER(u.email) = LOWER(_email) AND u.oidc_subject IS NULL AND LOWER(u.username) <> 'vacant'
+		ORDER BY u.user_id;
+	END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_l1_support_emails()
+	RETURNS TABLE (
+		email VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT u.email
+			FROM "User" AS u
+			WHERE LOWER(u.position) = 'l1 support'
+			ORDER BY u.email;
+	END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_area_manager_info(_site_id INT)
+	RETURNS TABLE (
+		user_id INT,
+		email VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT u.user_id, u.email
+			FROM "User" AS u
+			INNER JOIN "Site" AS s ON u.site_id = s.id
+			WHERE LOWER(u.position) = 'area manager'
+			AND s.id = _site_id;
+	END;
+$$;
+
+DROP FUNCTION IF EXISTS public.get_open_asset_reassignments();
+DROP FUNCTION IF EXISTS public.get_directory_sync_run(INT);
+DROP FUNCTION IF EXISTS public.get_directory_sync_runs(INT);
+DROP PROCEDURE IF EXISTS public.finish_directory_sync_run(INT, VARCHAR, JSONB, JSONB, TEXT);
+DROP FUNCTION IF EXISTS public.apply_directory_sync(INT, JSONB, NUMERIC);
+DROP FUNCTION IF EXISTS public.start_directory_sync_run(VARCHAR, VARCHAR, INT);
+DROP TRIGGER IF EXISTS asset_owner_changed ON "Asset";
+DROP FUNCTION IF EXISTS public.resolve_asset_reassignment();
+DROP TABLE IF EXISTS "AssetReassignment";
+DROP TABLE IF EXISTS "DirectorySyncRun";
+ALTER TABLE "User" DROP COLUMN IF EXISTS "deactivated_at";
+ALTER TABLE "User" DROP COLUMN IF EXISTS "is_active";
@@ -0,0 +1,389 @@
+-- Synchronisation of users and positions from LDAP or a nightly HR CSV drop (internal/directory).
+
+-- Leavers are deactivated instead of deleted, since assets, opname sessions and the audit log still refer to them.
+ALTER TABLE "User" ADD COLUMN "is_active" BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE "User" ADD COLUMN "deactivated_at" TIMESTAMP WITH TIME ZONE;
+
+-- One row per sync, with the change report.
+CREATE TABLE "DirectorySyncRun" (
+    "id" SERIAL PRIMARY KEY,
+    "source" VARCHAR(10) NOT NULL CHECK ("source" IN ('csv', 'ldap')),
+    "trigger" VARCHAR(20) NOT NULL CHECK ("trigger" IN ('schedule', 'manual', 'cli')),
+    "triggered_by" INT REFERENCES "User"("user_id") ON DELETE SET NULL,
+    "status" VARCHAR(20) NOT NULL DEFAULT 'running' CHECK ("status" IN ('running', 'succeeded', 'failed')),
+    "started_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    "finished_at" TIMESTAMP WITH TIME ZONE,
+    "summary" JSONB NOT NULL DEFAULT '{}', -- Number of users per action, e.g. {"created": 2, "deactivated": 1}
+    "report" JSONB NOT NULL DEFAULT '[]', -- One entry per created, updated, reactivated, deactivated or skipped user
+    "error" TEXT NOT NULL DEFAULT ''
+);
+
+-- Assets whose owner left, waiting to be given to someone else. Resolved when the asset's owner changes.
+CREATE TABLE "AssetReassignment" (
+    "id" SERIAL PRIMARY KEY,
+    "asset_tag" VARCHAR(12) NOT NULL REFERENCES "Asset"("asset_tag") ON DELETE CASCADE,
+    "previous_owner_id" INT NOT NULL REFERENCES "User"("user_id"),
+    "sync_run_id" INT REFERENCES "DirectorySyncRun"("id") ON DELETE SET NULL,
+    "flagged_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    "resolved_at" TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX uq_asset_reassignment_open ON "AssetReassignment" ("asset_tag") WHERE "resolved_at" IS NULL;
+
+-- resolve_asset_reassignment closes the open reassignment of an asset once it has a new owner.
+CREATE OR REPLACE FUNCTION public.resolve_asset_reassignment()
+	RETURNS TRIGGER
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "AssetReassignment" AS ar
+		SET resolved_at = NOW()
+		WHERE ar.asset_tag = NEW.asset_tag AND ar.resolved_at IS NULL;
+		RETURN NEW;
+	END;
+$$;
+
+CREATE TRIGGER asset_owner_changed
+	AFTER UPDATE OF owner_id ON "Asset"
+	FOR EACH ROW
+	WHEN (OLD.owner_id IS DISTINCT FROM NEW.owner_id)
+	EXECUTE FUNCTION resolve_asset_reassignment();
+
+-- start_directory_sync_run records the start of a sync and returns its ID. Only one sync may run at a time;
+-- a run still marked running after an hour is considered crashed and does not block.
+CREATE OR REPLACE FUNCTION public.start_directory_sync_run(_source VARCHAR(10), _trigger VARCHAR(20), _triggered_by INT)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_run_id INT;
+	BEGIN
+		LOCK TABLE "DirectorySyncRun" IN SHARE ROW EXCLUSIVE MODE;
+		IF EXISTS (
+			SELECT 1 FROM "DirectorySyncRun" AS r
+			WHERE r.status = 'running' AND r.started_at > NOW() - INTERVAL '1 hour'
+		) THEN
+			RAISE EXCEPTION 'A directory sync is already running';
+		END IF;
+
+		INSERT INTO "DirectorySyncRun" (source, "trigger", triggered_by)
+		VALUES (_source, _trigger, _triggered_by)
+		RETURNING id INTO v_run_id;
+
+		RETURN v_run_id;
+	END;
+$$;
+
+-- apply_directory_sync upserts every user of the directory and deactivates the active users missing from it, atomically.
+-- _users is a JSON array of objects with user_id, username, email, first_name, last_name, position, department, division,
+-- site_id, cost_center_id, ou_code and optionally skip_reason. Users referring to an unknown site or cost center, or taking another user's username, are skipped.
+-- The assets of deactivated users are flagged for reassignment.
+-- To protect against a truncated export, nothing is applied when more than _max_deactivation_ratio of the active users would be deactivated.
+CREATE OR REPLACE FUNCTION public.apply_directory_sync(_run_id INT, _users JSONB, _max_deactivation_ratio NUMERIC)
+	RETURNS TABLE (
+		user_id INT,
+		username VARCHAR(255),
+		"action" VARCHAR(20),
+		changes JSONB,
+		flagged_assets INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_record JSONB;
+		v_user_id INT;
+		v_new JSONB;
+		v_old JSONB;
+		v_changes JSONB;
+		v_seen INT[] := ARRAY[]::INT[];
+		v_active_count INT;
+		v_leaver RECORD;
+		v_leaver_count INT;
+		v_flagged INT;
+	BEGIN
+		FOR v_record IN SELECT * FROM jsonb_array_elements(_users) LOOP
+			v_user_id := (v_record->>'user_id')::INT;
+			v_seen := array_append(v_seen, v_user_id);
+			v_new := jsonb_build_object(
+				'username', v_record->>'username',
+				'email', COALESCE(v_record->>'email', ''),
+				'first_name', COALESCE(v_record->>'first_name', ''),
+				'last_name', COALESCE(v_record->>'last_name', ''),
+				'position', COALESCE(v_record->>'position', ''),
+				'department', COALESCE(v_record->>'department', ''),
+				'division', COALESCE(v_record->>'division', ''),
+				'site_id', (v_record->>'site_id')::INT,
+				'cost_center_id', (v_record->>'cost_center_id')::INT,
+				'ou_code', COALESCE(v_record->>'ou_code', ''),
+				'is_active', TRUE
+			);
+
+			-- Records the service already rejected are only reported. They still count as seen, so a typo does not deactivate anyone.
+			IF v_record->>'skip_reason' IS NOT NULL THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', v_record->>'skip_reason'), 0;
+				CONTINUE;
+			END IF;
+			IF v_new->>'site_id' IS NOT NULL AND NOT EXISTS (SELECT 1 FROM "Site" AS s WHERE s.id = (v_new->>'site_id')::INT) THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', 'unknown site_id ' || (v_new->>'site_id')), 0;
+				CONTINUE;
+			END IF;
+			IF v_new->>'cost_center_id' IS NOT NULL AND NOT EXISTS (SELECT 1 FROM "CostCenter" AS cc WHERE cc.cost_center_id = (v_new->>'cost_center_id')::INT) THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', 'unknown cost_center_id ' || (v_new->>'cost_center_id')), 0;
+				CONTINUE;
+			END IF;
+			IF EXISTS (SELECT 1 FROM "User" AS u WHERE LOWER(u.username) = LOWER(v_new->>'username') AND u.user_id <> v_user_id) THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', 'username already taken by another user'), 0;
+				CONTINUE;
+			END IF;
+
+			SELECT jsonb_build_object(
+				'username', u.username,
+				'email', u.email,
+				'first_name', u.first_name,
+				'last_name', u.last_name,
+				'position', u.position,
+				'department', u.department,
+				'division', u.division,
+				'site_id', u.site_id,
+				'cost_center_id', u.cost_center_id,
+				'ou_code', u.ou_code,
+				'is_active', u.is_active
+			) INTO v_old
+			FROM "User" AS u
+			WHERE u.user_id = v_user_id;
+
+			-- New users get no password: they sign in through SSO until an admin sets one.
+			IF v_old IS NULL THEN
+				INSERT INTO "User" (user_id, username, email, password, first_name, last_name, position, department, division, site_id, cost_center_id, ou_code)
+				VALUES (
+					v_user_id, v_new->>'username', v_new->>'email', '', v_new->>'first_name', v_new->>'last_name', v_new->>'position',
+					v_new->>'department', v_new->>'division', (v_new->>'site_id')::INT, (v_new->>'cost_center_id')::INT, v_new->>'ou_code'
+				);
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'created'::VARCHAR(20), v_new - 'is_active', 0;
+				CONTINUE;
+			END IF;
+
+			SELECT jsonb_object_agg(n.key, jsonb_build_object('old', v_old->n.key, 'new', n.value))
+			INTO v_changes
+			FROM jsonb_each(v_new) AS n
+			WHERE v_old->n.key IS DISTINCT FROM n.value;
+
+			IF v_changes IS NULL THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'unchanged'::VARCHAR(20), NULL::JSONB, 0;
+				CONTINUE;
+			END IF;
+
+			UPDATE "User" AS u
+			SET username = v_new->>'username',
+				email = v_new->>'email',
+				first_name = v_new->>'first_name',
+				last_name = v_new->>'last_name',
+				position = v_new->>'position',
+				department = v_new->>'department',
+				division = v_new->>'division',
+				site_id = (v_new->>'site_id')::INT,
+				cost_center_id = (v_new->>'cost_center_id')::INT,
+				ou_code = v_new->>'ou_code',
+				is_active = TRUE,
+				deactivated_at = NULL
+			WHERE u.user_id = v_user_id;
+
+			RETURN QUERY SELECT
+				v_user_id,
+				(v_new->>'username')::VARCHAR(255),
+				(CASE WHEN (v_old->>'is_active')::BOOLEAN THEN 'updated' ELSE 'reactivated' END)::VARCHAR(20),
+				v_changes,
+				0;
+		END LOOP;
+
+		-- Everyone active who is not in the directory has left. The VACANT placeholder (user ID 1) is never deactivated.
+		SELECT COUNT(*) INTO v_active_count FROM "User" AS u WHERE u.is_active AND u.user_id <> 1;
+		SELECT COUNT(*) INTO v_leaver_count FROM "User" AS u WHERE u.is_active AND u.user_id <> 1 AND NOT (u.user_id = ANY(v_seen));
+		IF v_active_count > 0 AND v_leaver_count > v_active_count * _max_deactivation_ratio THEN
+			RAISE EXCEPTION 'Directory sync would deactivate % of % active users', v_leaver_count, v_active_count;
+		END IF;
+
+		FOR v_leaver IN
+			SELECT u.user_id AS id, u.username AS name
+			FROM "User" AS u
+			WHERE u.is_active AND u.user_id <> 1 AND NOT (u.user_id = ANY(v_seen))
+			ORDER BY u.user_id
+		LOOP
+			UPDATE "User" AS u SET is_active = FALSE, deactivated_at = NOW() WHERE u.user_id = v_leaver.id;
+
+			INSERT INTO "AssetReassignment" (asset_tag, previous_owner_id, sync_run_id)
+			SELECT a.asset_tag, v_leaver.id, _run_id
+			FROM "Asset" AS a
+			WHERE a.owner_id = v_leaver.id AND a.status <> 'Disposed'
+			ON CONFLICT (asset_tag) WHERE resolved_at IS NULL DO NOTHING;
+			GET DIAGNOSTICS v_flagged = ROW_COUNT;
+
+			RETURN QUERY SELECT v_leaver.id, v_leaver.name, 'deactivated'::VARCHAR(20), jsonb_build_object('is_active', jsonb_build_object('old', TRUE, 'new', FALSE)), v_flagged;
+		END LOOP;
+	END;
+$$;
+
+-- finish_directory_sync_run stores the outcome and change report of a sync.
+CREATE OR REPLACE PROCEDURE public.finish_directory_sync_run(_run_id INT, _status VARCHAR(20), _summary JSONB, _report JSONB, _error TEXT)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "DirectorySyncRun" AS r
+		SET status = _status, finished_at = NOW(), summary = COALESCE(_summary, '{}'), report = COALESCE(_report, '[]'), error = COALESCE(_error, '')
+		WHERE r.id = _run_id;
+	END;
+$$;
+
+-- get_directory_sync_runs retrieves the latest syncs, newest first, without their reports.
+CREATE OR REPLACE FUNCTION public.get_directory_sync_runs(_limit INT)
+	RETURNS TABLE (
+		id INT,
+		source VARCHAR(10),
+		"trigger" VARCHAR(20),
+		triggered_by INT,
+		status VARCHAR(20),
+		started_at TIMESTAMP WITH TIME ZONE,
+		finished_at TIMESTAMP WITH TIME ZONE,
+		summary JSONB,
+		error TEXT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT r.id, r.source, r."trigger", r.triggered_by, r.status, r.started_at, r.finished_at, r.summary, r.error
+		FROM "DirectorySyncRun" AS r
+		ORDER BY r.started_at DESC, r.id DESC
+		LIMIT COALESCE(NULLIF(_limit, 0), 20);
+	END;
+$$;
+
+-- get_directory_sync_run retrieves one sync with its change report.
+CREATE OR REPLACE FUNCTION public.get_directory_sync_run(_run_id INT)
+	RETURNS TABLE (
+		id INT,
+		source VARCHAR(10),
+		"trigger" VARCHAR(20),
+		triggered_by INT,
+		status VARCHAR(20),
+		started_at TIMESTAMP WITH TIME ZONE,
+		finished_at TIMESTAMP WITH TIME ZONE,
+		summary JSONB,
+		error TEXT,
+		report JSONB
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT r.id, r.source, r."trigger", r.triggered_by, r.status, r.started_at, r.finished_at, r.summary, r.error, r.report
+		FROM "DirectorySyncRun" AS r
+		WHERE r.id = _run_id;
+	END;
+$$;
+
+-- get_open_asset_reassignments lists the assets whose owner left and that still wait for a new owner.
+CREATE OR REPLACE FUNCTION public.get_open_asset_reassignments()
+	RETURNS TABLE (
+		id INT,
+		asset_tag VARCHAR(12),
+		product_name VARCHAR(50),
+		sub_site_id INT,
+		dept_id INT,
+		previous_owner_id INT,
+		previous_owner_name TEXT,
+		sync_run_id INT,
+		flagged_at TIMESTAMP WITH TIME ZONE
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT ar.id, ar.asset_tag, a.product_name, a.sub_site_id, a.dept_id, ar.previous_owner_id, (u.first_name || ' ' || u.last_name)::TEXT, ar.sync_run_id, ar.flagged_at
+		FROM "AssetReassignment" AS ar
+		INNER JOIN "Asset" AS a ON a.asset_tag = ar.asset_tag
+		INNER JOIN "User" AS u ON u.user_id = ar.previous_owner_id
+		WHERE ar.resolved_at IS NULL
+		ORDER BY ar.flagged_at DESC, ar.asset_tag;
+	END;
+$$;
+
+-- Deactivated users can no longer sign in nor be picked as reviewers or notification recipients.
+CREATE OR REPLACE FUNCTION public.get_credentials(_username VARCHAR(255))
+    RETURNS table (
+		user_id INT,
+        username VARCHAR(255),
+        "password" VARCHAR(255),
+		"position" VARCHAR(100),
+        ou_code VARCHAR(5)
+    )
+    LANGUAGE plpgsql
+AS $$
+    BEGIN
+        RETURN QUERY
+        SELECT u.user_id, u.username, u.password, u.position, u.ou_code
+        FROM "User" AS u
+        WHERE LOWER(u.username) = LOWER(_username) AND LOWER(u.username) <> 'vacant' AND u.is_active; -- Block users trying to login using 'vacant' username
+    END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_credentials_by_oidc(_subject VARCHAR(255), _email VARCHAR(255))
+	RETURNS TABLE (
+		user_id INT,
+		username VARCHAR(255),
+		"password" VARCHAR(255),
+		"position" VARCHAR(100),
+		ou_code VARCHAR(5),
+		oidc_subject VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT u.user_id, u.username, u.password, u.position, u.ou_code, u.oidc_subject
+		FROM "User" AS u
+		WHERE u.oidc_subject = _subject AND LOWER(u.username) <> 'vacant' AND u.is_active;
+
+		IF FOUND OR COALESCE(_email, '') = '' THEN
+			RETURN;
+		END IF;
+
+		RETURN QUERY
+		SELECT u.user_id, u.username, u.password, u.position, u.ou_code, u.oidc_subject
+		FROM "User" AS u
+		WHERE LOWER(u.email) = LOWER(_email) AND u.oidc_subject IS NULL AND LOWER(u.username) <> 'vacant' AND u.is_active
+		ORDER BY u.user_id;
+	END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_l1_support_emails()
+	RETURNS TABLE (
+		email VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT u.email
+			FROM "User" AS u
+			WHERE LOWER(u.position) = 'l1 support' AND u.is_active
+			ORDER BY u.email;
+	END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_area_manager_info(_site_id INT)
+	RETURNS TABLE (
+		user_id INT,
+		email VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT u.user_id, u.email
+			FROM "User" AS u
+			INNER JOIN "Site" AS s ON u.site_id = s.id
+			WHERE LOWER(u.position) = 'area manager' AND u.is_active
+			AND s.id = _site_id;
+	END;
+$$;
@@ -0,0 +1,179 @@
+-- Restores the read-only user functions and the credential lookups that skip deactivated users.
+DROP FUNCTION IF EXISTS public.set_user_active(INT, BOOLEAN);
+DROP PROCEDURE IF EXISTS public.set_user_password(INT, VARCHAR);
+DROP PROCEDURE IF EXISTS public.update_user(INT, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, INT, INT, VARCHAR);
+DROP FUNCTION IF EXISTS public.create_user(INT, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, VARCHAR, INT, INT, VARCHAR);
+DROP FUNCTION IF EXISTS public.get_all_users(VARCHAR, VARCHAR, VARCHAR, VARCHAR, INT, INT, BOOLEAN, INT, INT);
+DROP FUNCTION IF EXISTS public.get_user_by_id(INT);
+DROP FUNCTION IF EXISTS public.get_user_by_username(VARCHAR);
+DROP FUNCTION IF EXISTS public.get_credentials(VARCHAR);
+DROP FUNCTION IF EXISTS public.get_credentials_by_oidc(VARCHAR, VARCHAR);
+
+CREATE OR REPLACE FUNCTION public.get_all_users()
+	RETURNS TABLE (
+		user_id INT,
+		username VARCHAR(255),
+		email VARCHAR(255),
+		first_name VARCHAR(255),
+		last_name VARCHAR(255),
+		"position" VARCHAR(100),
+		department VARCHAR(100),
+		division VARCHAR(100),
+		site_id INT,
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100),
+		cost_center_id INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN 
+		RETURN QUERY
+		SELECT u.user_id,
+		       u.username,
+		       u.email,
+		       u.first_name,
+		       u.last_name,
+		       u."position",
+		       u.department,
+		       u.division,
+		       s.id AS site_id,
+		       s.site_name,
+		       sg.site_group_name,
+		       r.region_name,
+		       u.cost_center_id
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		LEFT JOIN "Region" AS r ON sg.region_id = r.id;
+	END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_user_by_id(_user_id INT)
+	RETURNS table (
+		user_id INT,
+		username VARCHAR(255),
+		email VARCHAR(255),
+		first_name VARCHAR(255),
+		last_name VARCHAR(255),
+		"position" VARCHAR(100),
+		department VARCHAR(100),
+		division VARCHAR(100),
+		site_id INT,
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100),
+		cost_center_id INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN 
+		RETURN QUERY
+		SELECT u.user_id,
+		       u.username,
+		       u.email,
+		       u.first_name,
+		       u.last_name,
+		       u."position",
+		       u.department,
+		       u.division,
+		       s.id AS site_id,
+		       s.site_name,
+		       sg.site_group_name,
+		       r.region_name,
+		       u.cost_center_id
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		LEFT JOIN "Region" AS r ON sg.region_id = r.id
+		WHERE u.user_id = _user_id; 
+	END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_user_by_username(_username VARCHAR(255))
+	RETURNS table (
+		user_id INT,
+		username VARCHAR(255),
+		email VARCHAR(255),
+		first_name VARCHAR(255),
+		last_name VARCHAR(255),
+		"position" VARCHAR(100),
+		department VARCHAR(100),
+		division VARCHAR(100),
+		site_id INT,
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100),
+		cost_center_id INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN 
+		RETURN QUERY
+		SELECT u.user_id,
+		       u.username,
+		       u.email,
+		       u.first_name,
+		       u.last_name,
+		       u."position",
+		       u.department,
+		       u.division,
+		       s.id AS site_id,
+		       s.site_name,
+		       sg.site_group_name,
+		       r.region_name,
+		       u.cost_center_id
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		LEFT JOIN "Region" AS r ON sg.region_id = r.id
+		WHERE LOWER(u.username) = LOWER(_username); 
+	END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_credentials(_username VARCHAR(255))
+    RETURNS table (
+		user_id INT,
+        username VARCHAR(255),
+        "password" VARCHAR(255),
+		"position" VARCHAR(100),
+        ou_code VARCHAR(5)
+    )
+    LANGUAGE plpgsql
+AS $$
+    BEGIN
+        RETURN QUERY
+        SELECT u.user_id, u.username, u.password, u.position, u.ou_code
+        FROM "User" AS u
+        WHERE LOWER(u.username) = LOWER(_username) AND LOWER(u.username) <> 'vacant' AND u.is_active; -- Block users trying to login using 'vacant' username
+    END;
+$$;
+
+CREATE OR REPLACE FUNCTION public.get_credentials_by_oidc(_subject VARCHAR(255), _email VARCHAR(255))
+	RETURNS TABLE (
+		user_id INT,
+		username VARCHAR(255),
+		"password" VARCHAR(255),
+		"position" VARCHAR(100),
+		ou_code VARCHAR(5),
+		oidc_subject VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT u.user_id, u.username, u.password, u.position, u.ou_code, u.oidc_subject
+		FROM "User" AS u
+		WHERE u.oidc_subject = _subject AND LOWER(u.username) <> 'vacant' AND u.is_active;
+
+		IF FOUND OR COALESCE(_email, '') = '' THEN
+			RETURN;
+		END IF;
+
+		RETURN QUERY
+		SELECT u.user_id, u.username, u.password, u.position, u.ou_code, u.oidc_subject
+		FROM "User" AS u
+		WHERE LOWER(u.email) = LOWER(_email) AND u.oidc_subject IS NULL AND LOWER(u.username) <> 'vacant' AND u.is_active
+		ORDER BY u.user_id;
+	END;
+$$;
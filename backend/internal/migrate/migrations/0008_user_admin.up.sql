@@ -0,0 +1,347 @@
+-- User administration from the API (internal/user): filtered and paginated listing, create, edit, password reset and deactivation.
+-- Inactive users are now returned by the credential lookups, so the login can tell a deactivated account apart in the audit log.
+
+DROP FUNCTION IF EXISTS public.get_all_users();
+DROP FUNCTION IF EXISTS public.get_user_by_id(INT);
+DROP FUNCTION IF EXISTS public.get_user_by_username(VARCHAR);
+DROP FUNCTION IF EXISTS public.get_credentials(VARCHAR);
+DROP FUNCTION IF EXISTS public.get_credentials_by_oidc(VARCHAR, VARCHAR);
+
+-- get_all_users retrieves the users matching every given filter (NULL skips a filter), ordered by name.
+-- _search matches the username, first name, last name or email. Without _limit every match is returned.
+CREATE OR REPLACE FUNCTION public.get_all_users(
+	_search VARCHAR(255),
+	_position VARCHAR(100),
+	_department VARCHAR(100),
+	_division VARCHAR(100),
+	_site_id INT,
+	_cost_center_id INT,
+	_is_active BOOLEAN,
+	_limit INT,
+	_page_number INT
+)
+	RETURNS TABLE (
+		user_id INT,
+		username VARCHAR(255),
+		email VARCHAR(255),
+		first_name VARCHAR(255),
+		last_name VARCHAR(255),
+		"position" VARCHAR(100),
+		department VARCHAR(100),
+		division VARCHAR(100),
+		site_id INT,
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100),
+		cost_center_id INT,
+		is_active BOOLEAN,
+		total_count BIGINT
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_offset INT := GREATEST(COALESCE(_page_number, 1) - 1, 0) * COALESCE(NULLIF(_limit, 0), 0);
+	BEGIN
+		RETURN QUERY
+		SELECT u.user_id,
+		       u.username,
+		       u.email,
+		       u.first_name,
+		       u.last_name,
+		       u."position",
+		       u.department,
+		       u.division,
+		       s.id AS site_id,
+		       s.site_name,
+		       sg.site_group_name,
+		       r.region_name,
+		       u.cost_center_id,
+		       u.is_active,
+		       COUNT(*) OVER()::BIGINT AS total_count
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		LEFT JOIN "Region" AS r ON sg.region_id = r.id
+		WHERE (_search IS NULL OR u.username ILIKE '%' || _search || '%' OR u.first_name ILIKE '%' || _search || '%'
+		       OR u.last_name ILIKE '%' || _search || '%' OR u.email ILIKE '%' || _search || '%')
+		AND (_position IS NULL OR LOWER(u."position") = LOWER(_position))
+		AND (_department IS NULL OR LOWER(u.department) = LOWER(_department))
+		AND (_division IS NULL OR LOWER(u.division) = LOWER(_division))
+		AND (_site_id IS NULL OR u.site_id = _site_id)
+		AND (_cost_center_id IS NULL OR u.cost_center_id = _cost_center_id)
+		AND (_is_active IS NULL OR u.is_active = _is_active)
+		ORDER BY u.first_name, u.last_name, u.user_id
+		LIMIT NULLIF(_limit, 0) OFFSET v_offset;
+	END;
+$$;
+
+-- get_user_by_id retrieves user details by user ID
+CREATE OR REPLACE FUNCTION public.get_user_by_id(_user_id INT)
+	RETURNS table (
+		user_id INT,
+		username VARCHAR(255),
+		email VARCHAR(255),
+		first_name VARCHAR(255),
+		last_name VARCHAR(255),
+		"position" VARCHAR(100),
+		department VARCHAR(100),
+		division VARCHAR(100),
+		site_id INT,
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100),
+		cost_center_id INT,
+		is_active BOOLEAN
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT u.user_id,
+		       u.username,
+		       u.email,
+		       u.first_name,
+		       u.last_name,
+		       u."position",
+		       u.department,
+		       u.division,
+		       s.id AS site_id,
+		       s.site_name,
+		       sg.site_group_name,
+		       r.region_name,
+		       u.cost_center_id,
+		       u.is_active
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		LEFT JOIN "Region" AS r ON sg.region_id = r.id
+		WHERE u.user_id = _user_id;
+	END;
+$$;
+
+-- get_user_by_username retrieves user details by username
+CREATE OR REPLACE FUNCTION public.get_user_by_username(_username VARCHAR(255))
+	RETURNS table (
+		user_id INT,
+		username VARCHAR(255),
+		email VARCHAR(255),
+		first_name VARCHAR(255),
+		last_name VARCHAR(255),
+		"position" VARCHAR(100),
+		department VARCHAR(100),
+		division VARCHAR(100),
+		site_id INT,
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100),
+		cost_center_id INT,
+		is_active BOOLEAN
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT u.user_id,
+		       u.username,
+		       u.email,
+		       u.first_name,
+		       u.last_name,
+		       u."position",
+		       u.department,
+		       u.division,
+		       s.id AS site_id,
+		       s.site_name,
+		       sg.site_group_name,
+		       r.region_name,
+		       u.cost_center_id,
+		       u.is_active
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		LEFT JOIN "Region" AS r ON sg.region_id = r.id
+		WHERE LOWER(u.username) = LOWER(_username);
+	END;
+$$;
+
+-- get_credentials retrieves user credentials by username (for login auth), including deactivated users.
+CREATE OR REPLACE FUNCTION public.get_credentials(_username VARCHAR(255))
+	RETURNS TABLE (
+		user_id INT,
+		username VARCHAR(255),
+		"password" VARCHAR(255),
+		"position" VARCHAR(100),
+		ou_code VARCHAR(5),
+		is_active BOOLEAN
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT u.user_id, u.username, u.password, u.position, u.ou_code, u.is_active
+		FROM "User" AS u
+		WHERE LOWER(u.username) = LOWER(_username) AND LOWER(u.username) <> 'vacant'; -- Block users trying to login using 'vacant' username
+	END;
+$$;
+
+-- get_credentials_by_oidc retrieves the credentials of the user linked to an OIDC subject, including a deactivated one,
+-- so a leaver is refused instead of provisioned again. Without a linked user, it falls back to the active unlinked users with the given email.
+CREATE OR REPLACE FUNCTION public.get_credentials_by_oidc(_subject VARCHAR(255), _email VARCHAR(255))
+	RETURNS TABLE (
+		user_id INT,
+		username VARCHAR(255),
+		"password" VARCHAR(255),
+		"position" VARCHAR(100),
+		ou_code VARCHAR(5),
+		oidc_subject VARCHAR(255),
+		is_active BOOLEAN
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT u.user_id, u.username, u.password, u.position, u.ou_code, u.oidc_subject, u.is_active
+		FROM "User" AS u
+		WHERE u.oidc_subject = _subject AND LOWER(u.username) <> 'vacant';
+
+		IF FOUND OR COALESCE(_email, '') = '' THEN
+			RETURN;
+		END IF;
+
+		RETURN QUERY
+		SELECT u.user_id, u.username, u.password, u.position, u.ou_code, u.oidc_subject, u.is_active
+		FROM "User" AS u
+		WHERE LOWER(u.email) = LOWER(_email) AND u.oidc_subject IS NULL AND LOWER(u.username) <> 'vacant' AND u.is_active
+		ORDER BY u.user_id;
+	END;
+$$;
+
+-- create_user creates a user and returns its ID. Without _user_id (the HR employee number) the next free ID is used.
+CREATE OR REPLACE FUNCTION public.create_user(
+	_user_id INT,
+	_username VARCHAR(255),
+	_email VARCHAR(255),
+	_password VARCHAR(255),
+	_first_name VARCHAR(255),
+	_last_name VARCHAR(255),
+	_position VARCHAR(100),
+	_department VARCHAR(100),
+	_division VARCHAR(100),
+	_site_id INT,
+	_cost_center_id INT,
+	_ou_code VARCHAR(5)
+)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_user_id INT := _user_id;
+	BEGIN
+		IF EXISTS (SELECT 1 FROM "User" AS u WHERE LOWER(u.username) = LOWER(_username)) THEN
+			RAISE EXCEPTION 'Username % is already taken', _username;
+		END IF;
+
+		IF v_user_id IS NULL THEN
+			-- Serialise concurrent creations so two of them cannot pick the same ID.
+			LOCK TABLE "User" IN SHARE ROW EXCLUSIVE MODE;
+			SELECT COALESCE(MAX(u.user_id), 1) + 1 INTO v_user_id FROM "User" AS u;
+		ELSIF EXISTS (SELECT 1 FROM "User" AS u WHERE u.user_id = v_user_id) THEN
+			RAISE EXCEPTION 'User ID % is already taken', v_user_id;
+		END IF;
+
+		INSERT INTO "User" (user_id, username, email, password, first_name, last_name, position, department, division, site_id, cost_center_id, ou_code)
+		VALUES (v_user_id, _username, COALESCE(_email, ''), _password, COALESCE(_first_name, ''), COALESCE(_last_name, ''), COALESCE(_position, ''),
+			COALESCE(_department, ''), COALESCE(_division, ''), _site_id, _cost_center_id, COALESCE(_ou_code, ''));
+
+		RETURN v_user_id;
+	END;
+$$;
+
+-- update_user changes a user's details. NULL parameters keep the current value.
+CREATE OR REPLACE PROCEDURE public.update_user(
+	_user_id INT,
+	_email VARCHAR(255),
+	_first_name VARCHAR(255),
+	_last_name VARCHAR(255),
+	_position VARCHAR(100),
+	_department VARCHAR(100),
+	_division VARCHAR(100),
+	_site_id INT,
+	_cost_center_id INT,
+	_ou_code VARCHAR(5)
+)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF _user_id = 1 THEN
+			RAISE EXCEPTION 'The VACANT placeholder user cannot be changed';
+		END IF;
+
+		UPDATE "User" AS u
+		SET email = COALESCE(_email, u.email),
+			first_name = COALESCE(_first_name, u.first_name),
+			last_name = COALESCE(_last_name, u.last_name),
+			position = COALESCE(_position, u.position),
+			department = COALESCE(_department, u.department),
+			division = COALESCE(_division, u.division),
+			site_id = COALESCE(_site_id, u.site_id),
+			cost_center_id = COALESCE(_cost_center_id, u.cost_center_id),
+			ou_code = COALESCE(_ou_code, u.ou_code)
+		WHERE u.user_id = _user_id;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'User with ID % not found', _user_id;
+		END IF;
+	END;
+$$;
+
+-- set_user_password replaces a user's password (already hashed by the API).
+CREATE OR REPLACE PROCEDURE public.set_user_password(_user_id INT, _password VARCHAR(255))
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF _user_id = 1 THEN
+			RAISE EXCEPTION 'The VACANT placeholder user cannot be changed';
+		END IF;
+
+		UPDATE "User" AS u SET password = _password WHERE u.user_id = _user_id;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'User with ID % not found', _user_id;
+		END IF;
+	END;
+$$;
+
+-- set_user_active deactivates or reactivates a user and returns the number of its assets flagged for reassignment.
+-- Like the directory sync, deactivating flags the user's assets so they get a new owner.
+CREATE OR REPLACE FUNCTION public.set_user_active(_user_id INT, _is_active BOOLEAN)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_flagged INT := 0;
+	BEGIN
+		IF _user_id = 1 THEN
+			RAISE EXCEPTION 'The VACANT placeholder user cannot be changed';
+		END IF;
+
+		UPDATE "User" AS u
+		SET is_active = _is_active,
+			deactivated_at = CASE WHEN _is_active THEN NULL ELSE COALESCE(u.deactivated_at, NOW()) END
+		WHERE u.user_id = _user_id;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'User with ID % not found', _user_id;
+		END IF;
+
+		IF NOT _is_active THEN
+			INSERT INTO "AssetReassignment" (asset_tag, previous_owner_id)
+			SELECT a.asset_tag, _user_id
+			FROM "Asset" AS a
+			WHERE a.owner_id = _user_id AND a.status <> 'Disposed'
+			ON CONFLICT (asset_tag) WHERE resolved_at IS NULL DO NOTHING;
+			GET DIAGNOSTICS v_flagged = ROW_COUNT;
+		END IF;
+
+		RETURN v_flagged;
+	END;
+$$;
@@ -0,0 +1,37 @@
+-- Removes the location administration and restores the cascading deletes.
+DROP PROCEDURE IF EXISTS public.delete_department(INT);
+DROP PROCEDURE IF EXISTS public.update_department(INT, VARCHAR);
+DROP FUNCTION IF EXISTS public.create_department(VARCHAR);
+DROP PROCEDURE IF EXISTS public.delete_sub_site(INT);
+DROP PROCEDURE IF EXISTS public.update_sub_site(INT, VARCHAR, INT);
+DROP FUNCTION IF EXISTS public.create_sub_site(VARCHAR, INT);
+DROP PROCEDURE IF EXISTS public.delete_site(INT);
+DROP PROCEDURE IF EXISTS public.update_site(INT, VARCHAR, INT, INT);
+DROP FUNCTION IF EXISTS public.create_site(VARCHAR, INT, INT);
+DROP PROCEDURE IF EXISTS public.delete_site_group(INT);
+DROP PROCEDURE IF EXISTS public.update_site_group(INT, VARCHAR, INT);
+DROP FUNCTION IF EXISTS public.create_site_group(VARCHAR, INT);
+DROP PROCEDURE IF EXISTS public.delete_region(INT);
+DROP PROCEDURE IF EXISTS public.update_region(INT, VARCHAR);
+DROP FUNCTION IF EXISTS public.create_region(VARCHAR);
+DROP FUNCTION IF EXISTS public.check_site_ga(INT);
+DROP FUNCTION IF EXISTS public.get_location_node(VARCHAR, INT);
+DROP FUNCTION IF EXISTS public.get_all_departments();
+DROP FUNCTION IF EXISTS public.get_location_tree();
+
+ALTER TABLE "Site" DROP CONSTRAINT IF EXISTS "Site_site_ga_id_fkey";
+
+ALTER TABLE "User" DROP CONSTRAINT "User_site_id_fkey",
+	ADD CONSTRAINT "User_site_id_fkey" FOREIGN KEY ("site_id") REFERENCES "Site"("id") ON DELETE CASCADE;
+ALTER TABLE "Asset" DROP CONSTRAINT "Asset_site_id_fkey",
+	ADD CONSTRAINT "Asset_site_id_fkey" FOREIGN KEY ("site_id") REFERENCES "Site"("id") ON DELETE CASCADE;
+ALTER TABLE "Asset" DROP CONSTRAINT "Asset_dept_id_fkey",
+	ADD CONSTRAINT "Asset_dept_id_fkey" FOREIGN KEY ("dept_id") REFERENCES "Department"("id") ON DELETE CASCADE;
+ALTER TABLE "Asset" DROP CONSTRAINT "Asset_sub_site_id_fkey",
+	ADD CONSTRAINT "Asset_sub_site_id_fkey" FOREIGN KEY ("sub_site_id") REFERENCES "SubSite"("id") ON DELETE CASCADE;
+ALTER TABLE "SubSite" DROP CONSTRAINT "SubSite_site_id_fkey",
+	ADD CONSTRAINT "SubSite_site_id_fkey" FOREIGN KEY ("site_id") REFERENCES "Site"("id") ON DELETE CASCADE;
+ALTER TABLE "Site" DROP CONSTRAINT "Site_site_group_id_fkey",
+	ADD CONSTRAINT "Site_site_group_id_fkey" FOREIGN KEY ("site_group_id") REFERENCES "SiteGroup"("id") ON DELETE CASCADE;
+ALTER TABLE "SiteGroup" DROP CONSTRAINT "SiteGroup_region_id_fkey",
+	ADD CONSTRAINT "SiteGroup_region_id_fkey" FOREIGN KEY ("region_id") REFERENCES "Region"("id") ON DELETE CASCADE;
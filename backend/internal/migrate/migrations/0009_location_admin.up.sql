@@ -0,0 +1,360 @@
+-- Administration of the location hierarchy (internal/location): Region > SiteGroup > Site > SubSite, and the head office Departments.
+
+-- Deleting a location used to cascade down to its assets and users. Refuse instead, so nothing is wiped silently;
+-- the delete functions below check first and explain what is still attached.
+ALTER TABLE "SiteGroup" DROP CONSTRAINT "SiteGroup_region_id_fkey",
+	ADD CONSTRAINT "SiteGroup_region_id_fkey" FOREIGN KEY ("region_id") REFERENCES "Region"("id") ON DELETE RESTRICT;
+ALTER TABLE "Site" DROP CONSTRAINT "Site_site_group_id_fkey",
+	ADD CONSTRAINT "Site_site_group_id_fkey" FOREIGN KEY ("site_group_id") REFERENCES "SiteGroup"("id") ON DELETE RESTRICT;
+ALTER TABLE "SubSite" DROP CONSTRAINT "SubSite_site_id_fkey",
+	ADD CONSTRAINT "SubSite_site_id_fkey" FOREIGN KEY ("site_id") REFERENCES "Site"("id") ON DELETE RESTRICT;
+ALTER TABLE "Asset" DROP CONSTRAINT "Asset_sub_site_id_fkey",
+	ADD CONSTRAINT "Asset_sub_site_id_fkey" FOREIGN KEY ("sub_site_id") REFERENCES "SubSite"("id") ON DELETE RESTRICT;
+ALTER TABLE "Asset" DROP CONSTRAINT "Asset_dept_id_fkey",
+	ADD CONSTRAINT "Asset_dept_id_fkey" FOREIGN KEY ("dept_id") REFERENCES "Department"("id") ON DELETE RESTRICT;
+ALTER TABLE "Asset" DROP CONSTRAINT "Asset_site_id_fkey",
+	ADD CONSTRAINT "Asset_site_id_fkey" FOREIGN KEY ("site_id") REFERENCES "Site"("id") ON DELETE RESTRICT;
+ALTER TABLE "User" DROP CONSTRAINT "User_site_id_fkey",
+	ADD CONSTRAINT "User_site_id_fkey" FOREIGN KEY ("site_id") REFERENCES "Site"("id") ON DELETE RESTRICT;
+
+-- The site GA was never enforced. NOT VALID keeps existing rows as they are and checks every new or changed one.
+ALTER TABLE "Site" ADD CONSTRAINT "Site_site_ga_id_fkey" FOREIGN KEY ("site_ga_id") REFERENCES "User"("user_id") NOT VALID;
+
+-- get_location_tree retrieves the whole Region > SiteGroup > Site > SubSite hierarchy as one row per deepest node,
+-- with NULLs below a node that has no children. The API nests it.
+CREATE OR REPLACE FUNCTION public.get_location_tree()
+	RETURNS TABLE (
+		region_id INT,
+		region_name VARCHAR(100),
+		site_group_id INT,
+		site_group_name VARCHAR(100),
+		site_id INT,
+		site_name VARCHAR(100),
+		site_ga_id INT,
+		sub_site_id INT,
+		sub_site_name VARCHAR(100)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT r.id, r.region_name, sg.id, sg.site_group_name, s.id, s.site_name, s.site_ga_id, ss.id, ss.sub_site_name
+		FROM "Region" AS r
+		LEFT JOIN "SiteGroup" AS sg ON sg.region_id = r.id
+		LEFT JOIN "Site" AS s ON s.site_group_id = sg.id
+		LEFT JOIN "SubSite" AS ss ON ss.site_id = s.id
+		ORDER BY r.region_name, sg.site_group_name, s.site_name, ss.sub_site_name;
+	END;
+$$;
+
+-- get_all_departments retrieves every department, ordered by name.
+CREATE OR REPLACE FUNCTION public.get_all_departments()
+	RETURNS TABLE (
+		dept_id INT,
+		dept_name VARCHAR(100)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT d.id, d.dept_name
+		FROM "Department" AS d
+		ORDER BY d.dept_name;
+	END;
+$$;
+
+-- get_location_node retrieves one node of the hierarchy as JSON, NULL when it does not exist.
+-- _level is one of region, site_group, site, sub_site or department.
+CREATE OR REPLACE FUNCTION public.get_location_node(_level VARCHAR(20), _id INT)
+	RETURNS JSONB
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		CASE _level
+			WHEN 'region' THEN
+				RETURN (SELECT jsonb_build_object('id', r.id, 'region_name', r.region_name) FROM "Region" AS r WHERE r.id = _id);
+			WHEN 'site_group' THEN
+				RETURN (SELECT jsonb_build_object('id', sg.id, 'site_group_name', sg.site_group_name, 'region_id', sg.region_id) FROM "SiteGroup" AS sg WHERE sg.id = _id);
+			WHEN 'site' THEN
+				RETURN (SELECT jsonb_build_object('id', s.id, 'site_name', s.site_name, 'site_group_id', s.site_group_id, 'site_ga_id', s.site_ga_id) FROM "Site" AS s WHERE s.id = _id);
+			WHEN 'sub_site' THEN
+				RETURN (SELECT jsonb_build_object('id', ss.id, 'sub_site_name', ss.sub_site_name, 'site_id', ss.site_id) FROM "SubSite" AS ss WHERE ss.id = _id);
+			WHEN 'department' THEN
+				RETURN (SELECT jsonb_build_object('id', d.id, 'dept_name', d.dept_name) FROM "Department" AS d WHERE d.id = _id);
+			ELSE
+				RAISE EXCEPTION 'Unknown location level %', _level;
+		END CASE;
+	END;
+$$;
+
+-- check_site_ga raises unless the user exists and is active, so a site is never handed to a leaver.
+CREATE OR REPLACE FUNCTION public.check_site_ga(_user_id INT)
+	RETURNS VOID
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM "User" AS u WHERE u.user_id = _user_id AND u.is_active AND u.user_id <> 1) THEN
+			RAISE EXCEPTION 'Site GA user % not found or deactivated', _user_id;
+		END IF;
+	END;
+$$;
+
+-- create_region creates a region and returns its ID.
+CREATE OR REPLACE FUNCTION public.create_region(_region_name VARCHAR(100))
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_id INT;
+	BEGIN
+		INSERT INTO "Region" (region_name) VALUES (_region_name) RETURNING id INTO v_id;
+		RETURN v_id;
+	END;
+$$;
+
+-- update_region renames a region.
+CREATE OR REPLACE PROCEDURE public.update_region(_id INT, _region_name VARCHAR(100))
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "Region" AS r SET region_name = COALESCE(_region_name, r.region_name) WHERE r.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location region % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- delete_region deletes a region without site groups.
+CREATE OR REPLACE PROCEDURE public.delete_region(_id INT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_count INT;
+	BEGIN
+		SELECT COUNT(*) INTO v_count FROM "SiteGroup" AS sg WHERE sg.region_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location region % still has % site groups', _id, v_count;
+		END IF;
+
+		DELETE FROM "Region" AS r WHERE r.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location region % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- create_site_group creates a site group in a region and returns its ID.
+CREATE OR REPLACE FUNCTION public.create_site_group(_site_group_name VARCHAR(100), _region_id INT)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_id INT;
+	BEGIN
+		INSERT INTO "SiteGroup" (site_group_name, region_id) VALUES (_site_group_name, _region_id) RETURNING id INTO v_id;
+		RETURN v_id;
+	END;
+$$;
+
+-- update_site_group renames a site group or moves it to another region. NULL parameters keep the current value.
+CREATE OR REPLACE PROCEDURE public.update_site_group(_id INT, _site_group_name VARCHAR(100), _region_id INT)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "SiteGroup" AS sg
+		SET site_group_name = COALESCE(_site_group_name, sg.site_group_name),
+			region_id = COALESCE(_region_id, sg.region_id)
+		WHERE sg.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location site group % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- delete_site_group deletes a site group without sites.
+CREATE OR REPLACE PROCEDURE public.delete_site_group(_id INT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_count INT;
+	BEGIN
+		SELECT COUNT(*) INTO v_count FROM "Site" AS s WHERE s.site_group_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site group % still has % sites', _id, v_count;
+		END IF;
+
+		DELETE FROM "SiteGroup" AS sg WHERE sg.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location site group % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- create_site creates a site in a site group, looked after by a GA, and returns its ID.
+CREATE OR REPLACE FUNCTION public.create_site(_site_name VARCHAR(100), _site_group_id INT, _site_ga_id INT)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_id INT;
+	BEGIN
+		PERFORM check_site_ga(_site_ga_id);
+		INSERT INTO "Site" (site_name, site_group_id, site_ga_id) VALUES (_site_name, _site_group_id, _site_ga_id) RETURNING id INTO v_id;
+		RETURN v_id;
+	END;
+$$;
+
+-- update_site renames a site, moves it to another site group or hands it to another GA. NULL parameters keep the current value.
+CREATE OR REPLACE PROCEDURE public.update_site(_id INT, _site_name VARCHAR(100), _site_group_id INT, _site_ga_id INT)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF _site_ga_id IS NOT NULL THEN
+			PERFORM check_site_ga(_site_ga_id);
+		END IF;
+
+		UPDATE "Site" AS s
+		SET site_name = COALESCE(_site_name, s.site_name),
+			site_group_id = COALESCE(_site_group_id, s.site_group_id),
+			site_ga_id = COALESCE(_site_ga_id, s.site_ga_id)
+		WHERE s.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location site % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- delete_site deletes a site that has no sub-sites, assets, users nor opname history.
+CREATE OR REPLACE PROCEDURE public.delete_site(_id INT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_count INT;
+	BEGIN
+		SELECT COUNT(*) INTO v_count FROM "SubSite" AS ss WHERE ss.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still has % sub-sites', _id, v_count;
+		END IF;
+		SELECT COUNT(*) INTO v_count FROM "Asset" AS a WHERE a.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still holds % assets', _id, v_count;
+		END IF;
+		SELECT COUNT(*) INTO v_count FROM "User" AS u WHERE u.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still has % users', _id, v_count;
+		END IF;
+		SELECT COUNT(*) INTO v_count FROM "OpnameSession" AS os WHERE os.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still has % opname sessions', _id, v_count;
+		END IF;
+
+		DELETE FROM "Site" AS s WHERE s.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location site % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- create_sub_site creates a sub-site in a site and returns its ID.
+CREATE OR REPLACE FUNCTION public.create_sub_site(_sub_site_name VARCHAR(100), _site_id INT)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_id INT;
+	BEGIN
+		INSERT INTO "SubSite" (sub_site_name, site_id) VALUES (_sub_site_name, _site_id) RETURNING id INTO v_id;
+		RETURN v_id;
+	END;
+$$;
+
+-- update_sub_site renames a sub-site. It cannot move to another site while it holds assets, whose site_id would disagree.
+CREATE OR REPLACE PROCEDURE public.update_sub_site(_id INT, _sub_site_name VARCHAR(100), _site_id INT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_count INT;
+	BEGIN
+		IF _site_id IS NOT NULL THEN
+			SELECT COUNT(*) INTO v_count FROM "Asset" AS a WHERE a.sub_site_id = _id AND a.site_id <> _site_id;
+			IF v_count > 0 THEN
+				RAISE EXCEPTION 'Location sub-site % still holds % assets', _id, v_count;
+			END IF;
+		END IF;
+
+		UPDATE "SubSite" AS ss
+		SET sub_site_name = COALESCE(_sub_site_name, ss.sub_site_name),
+			site_id = COALESCE(_site_id, ss.site_id)
+		WHERE ss.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location sub-site % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- delete_sub_site deletes a sub-site that holds no assets.
+CREATE OR REPLACE PROCEDURE public.delete_sub_site(_id INT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_count INT;
+	BEGIN
+		SELECT COUNT(*) INTO v_count FROM "Asset" AS a WHERE a.sub_site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location sub-site % still holds % assets', _id, v_count;
+		END IF;
+
+		DELETE FROM "SubSite" AS ss WHERE ss.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location sub-site % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- create_department creates a head office department and returns its ID.
+CREATE OR REPLACE FUNCTION public.create_department(_dept_name VARCHAR(100))
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_id INT;
+	BEGIN
+		INSERT INTO "Department" (dept_name) VALUES (_dept_name) RETURNING id INTO v_id;
+		RETURN v_id;
+	END;
+$$;
+
+-- update_department renames a department.
+CREATE OR REPLACE PROCEDURE public.update_department(_id INT, _dept_name VARCHAR(100))
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "Department" AS d SET dept_name = COALESCE(_dept_name, d.dept_name) WHERE d.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location department % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- delete_department deletes a department that holds no assets nor opname history.
+CREATE OR REPLACE PROCEDURE public.delete_department(_id INT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_count INT;
+	BEGIN
+		SELECT COUNT(*) INTO v_count FROM "Asset" AS a WHERE a.dept_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location department % still holds % assets', _id, v_count;
+		END IF;
+		SELECT COUNT(*) INTO v_count FROM "OpnameSession" AS os WHERE os.dept_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location department % still has % opname sessions', _id, v_count;
+		END IF;
+
+		DELETE FROM "Department" AS d WHERE d.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location department % not found', _id;
+		END IF;
+	END;
+$$;
@@ -0,0 +1,358 @@
+-- Restores the functions that assumed a single head office (site 25) and drops the head office model.
+DROP PROCEDURE IF EXISTS public.update_department(INT, VARCHAR, INT);
+DROP FUNCTION IF EXISTS public.create_department(VARCHAR, INT);
+DROP PROCEDURE IF EXISTS public.update_site(INT, VARCHAR, INT, INT, BOOLEAN);
+DROP FUNCTION IF EXISTS public.create_site(VARCHAR, INT, INT, BOOLEAN);
+DROP FUNCTION IF EXISTS public.get_all_departments();
+DROP FUNCTION IF EXISTS public.get_location_tree();
+DROP FUNCTION IF EXISTS public.get_dept_by_id(INT);
+
+-- get_user_opname_locations retrieves the sites/dept a user has access to using their position and id (from login session)
+-- Pagination and filtering is done here
+-- Note: This functions assumes that "L1 Support" users can see all sites and dept, while others are restriced to their region.
+-- It also assumes that users can only see their own department's sites.
+-- HO mode: shows departments only (for department-level opname)  
+-- Area mode: shows sites only (for site-level opname)
+CREATE OR REPLACE FUNCTION public.get_user_opname_locations(
+	_user_id INT,
+	_position VARCHAR(100),
+	_site_group_name VARCHAR(100),
+	_site_name VARCHAR(100),
+	_sub_site_name VARCHAR(100),
+	_dept_name VARCHAR(100),
+	_created_by VARCHAR(255),
+	_opname_status VARCHAR(20),
+	_from_date TIMESTAMP,
+	_end_date TIMESTAMP,
+	_search_in VARCHAR(10), -- Search in area/HO only
+	_limit INT,
+	_page_number INT
+)
+    RETURNS TABLE (
+		site_id INT,
+		dept_id INT,
+		dept_name VARCHAR(100),
+        site_name VARCHAR(100),
+        site_group_name VARCHAR(100),
+        region_name VARCHAR(100),
+        opname_status VARCHAR(20),
+        last_opname_date TIMESTAMP,
+		last_opname_by VARCHAR(255),
+		total_count BIGINT
+    )
+    LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_user_region_id INT;
+		v_user_dept_id INT;
+		v_offset INT := GREATEST(COALESCE(_page_number,1)-1,0) * COALESCE(NULLIF(_limit,0),20);
+		v_search_in VARCHAR(10) := LOWER(COALESCE(_search_in, 'area'));
+		v_user_position VARCHAR(100) := LOWER(COALESCE(_position,''));
+	BEGIN
+		-- Fetch the user's department and region context
+		SELECT r.id, d.id
+		INTO v_user_region_id, v_user_dept_id
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		LEFT JOIN "Region" AS r ON sg.region_id = r.id
+		LEFT JOIN "Department" AS d ON LOWER(u.department) = LOWER(d.dept_name)
+		WHERE u.user_id = _user_id;
+
+		IF v_search_in = 'ho' THEN
+			-- HO MODE: Show departments only (site_name = NULL)
+			RETURN QUERY
+			SELECT
+				25::INT AS site_id, -- Head office site ID is always 25
+				d.id::INT AS dept_id,
+				d.dept_name::VARCHAR(100),
+				NULL::VARCHAR(100) AS site_name,
+				NULL::VARCHAR(100) AS site_group_name,
+				NULL::VARCHAR(100) AS region_name,
+				COALESCE(lo.session_status, 'Outdated')::VARCHAR(20) AS opname_status,
+				lo.session_end_date::TIMESTAMP AS last_opname_date,
+				lo.created_by::VARCHAR(255) AS last_opname_by,
+				COUNT(*) OVER()::BIGINT AS total_count
+			FROM "Department" AS d
+			LEFT JOIN LATERAL get_latest_opname_status(NULL, d.id) lo ON TRUE
+			WHERE 
+				-- Access control: L1 support sees all, others see only their department
+				(v_user_position = 'l1 support' OR d.id = v_user_dept_id)
+				-- Filters
+				AND (_dept_name IS NULL OR _dept_name = '' OR d.dept_name ILIKE '%'||_dept_name||'%')
+				AND (_created_by IS NULL OR _created_by = '' OR lo.created_by ILIKE '%'||_created_by||'%')
+				AND (_from_date IS NULL OR lo.session_end_date >= _from_date)
+				AND (_end_date IS NULL OR lo.session_end_date <= _end_date)
+				AND (_opname_status IS NULL OR _opname_status = '' OR COALESCE(lo.session_status, 'Outdated') = _opname_status)
+			ORDER BY d.dept_name
+			LIMIT COALESCE(NULLIF(_limit,0), 20) OFFSET v_offset;
+		ELSE
+			-- AREA MODE: Show sites only (dept_name = NULL)  
+			RETURN QUERY
+			SELECT
+				s.id::INT AS site_id,
+				NULL::INT AS dept_id,
+				NULL::VARCHAR(100) AS dept_name,
+				s.site_name::VARCHAR(100),
+				sg.site_group_name::VARCHAR(100),
+				r.region_name::VARCHAR(100),
+				COALESCE(lo.session_status, 'Outdated')::VARCHAR(20) AS opname_status,
+				lo.session_end_date::TIMESTAMP AS last_opname_date,
+				lo.created_by::VARCHAR(255) AS last_opname_by,
+				COUNT(*) OVER()::BIGINT AS total_count
+			FROM "Site" AS s
+			INNER JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+			INNER JOIN "Region" AS r ON sg.region_id = r.id
+			LEFT JOIN "SubSite" AS ss ON (_sub_site_name IS NOT NULL AND _sub_site_name <> '') AND ss.site_id = s.id
+			LEFT JOIN LATERAL get_latest_opname_status(s.id, NULL) lo ON TRUE
+			WHERE
+				-- Access control: L1 support sees all, area users see only their region and must be managers
+				(v_user_position = 'l1 support' 
+				 OR (v_user_position IN ('admin staff general affairs', 'area manager') AND r.id = v_user_region_id))
+				-- Filters
+				AND (_site_group_name IS NULL OR _site_group_name = '' OR sg.site_group_name ILIKE '%'||_site_group_name||'%')
+				AND (_site_name IS NULL OR _site_name = '' OR s.site_name ILIKE '%'||_site_name||'%')
+				AND (_sub_site_name IS NULL OR _sub_site_name = '' OR ss.sub_site_name ILIKE '%'||_sub_site_name||'%')
+				AND (_created_by IS NULL OR _created_by = '' OR lo.created_by ILIKE '%'||_created_by||'%')
+				AND (_from_date IS NULL OR lo.session_end_date >= _from_date)
+				AND (_end_date IS NULL OR lo.session_end_date <= _end_date)
+				AND (_opname_status IS NULL OR _opname_status = '' OR COALESCE(lo.session_status, 'Outdated') = _opname_status)
+			ORDER BY s.site_name
+			LIMIT COALESCE(NULLIF(_limit,0), 20) OFFSET v_offset;
+		END IF;
+	END;
+$$;
+
+-- is_opname_session_reviewer checks whether a user is the next reviewer on the approval path of a session's location.
+-- Submitted sessions are reviewed by an area manager of the location's region, escalated sessions by L1 support.
+-- Department sessions belong to the head office (site ID 25, see get_user_opname_locations).
+CREATE OR REPLACE FUNCTION public.is_opname_session_reviewer(_session_id INT, _user_id INT)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_status VARCHAR(20);
+		_session_region_id INT;
+		_user_position VARCHAR(100);
+		_user_region_id INT;
+	BEGIN
+		SELECT os.status, sg.region_id
+		INTO _status, _session_region_id
+		FROM "OpnameSession" AS os
+		LEFT JOIN "Site" AS s ON s.id = COALESCE(os.site_id, CASE WHEN os.dept_id IS NOT NULL THEN 25 END)
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		WHERE os.id = _session_id;
+
+		SELECT LOWER(u.position), sg.region_id
+		INTO _user_position, _user_region_id
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		WHERE u.user_id = _user_id;
+
+		IF _status = 'Submitted' THEN
+			RETURN _user_position = 'area manager' AND _user_region_id = _session_region_id;
+		ELSIF _status = 'Escalated' THEN
+			RETURN _user_position = 'l1 support';
+		END IF;
+
+		-- Sessions that are not waiting for a review have no reviewer.
+		RETURN FALSE;
+	END;
+$$;
+
+-- get_dept_by_id retrieves department details by department ID
+CREATE OR REPLACE FUNCTION public.get_dept_by_id(_dept_id INT)
+	RETURNS TABLE (
+		dept_id INT,
+		dept_name VARCHAR(100),
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100),
+		latest_opname_session_id INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT 
+			d.id AS dept_id,
+			d.dept_name,
+			s.site_name,
+			sg.site_group_name,
+			r.region_name,
+			COALESCE(os.id, -1) AS opname_session_id
+		FROM "Department" AS d
+		INNER JOIN "Site" AS s ON LOWER(s.site_name) = 'head office jakarta'
+		INNER JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		INNER JOIN "Region" AS r ON sg.region_id = r.id
+		LEFT JOIN "OpnameSession" AS os ON os.dept_id = d.id
+		WHERE d.id = _dept_id;
+	END;
+$$;
+
+-- get_location_tree retrieves the whole Region > SiteGroup > Site > SubSite hierarchy as one row per deepest node,
+-- with NULLs below a node that has no children. The API nests it.
+CREATE OR REPLACE FUNCTION public.get_location_tree()
+	RETURNS TABLE (
+		region_id INT,
+		region_name VARCHAR(100),
+		site_group_id INT,
+		site_group_name VARCHAR(100),
+		site_id INT,
+		site_name VARCHAR(100),
+		site_ga_id INT,
+		sub_site_id INT,
+		sub_site_name VARCHAR(100)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT r.id, r.region_name, sg.id, sg.site_group_name, s.id, s.site_name, s.site_ga_id, ss.id, ss.sub_site_name
+		FROM "Region" AS r
+		LEFT JOIN "SiteGroup" AS sg ON sg.region_id = r.id
+		LEFT JOIN "Site" AS s ON s.site_group_id = sg.id
+		LEFT JOIN "SubSite" AS ss ON ss.site_id = s.id
+		ORDER BY r.region_name, sg.site_group_name, s.site_name, ss.sub_site_name;
+	END;
+$$;
+
+-- get_all_departments retrieves every department, ordered by name.
+CREATE OR REPLACE FUNCTION public.get_all_departments()
+	RETURNS TABLE (
+		dept_id INT,
+		dept_name VARCHAR(100)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT d.id, d.dept_name
+		FROM "Department" AS d
+		ORDER BY d.dept_name;
+	END;
+$$;
+
+-- get_location_node retrieves one node of the hierarchy as JSON, NULL when it does not exist.
+-- _level is one of region, site_group, site, sub_site or department.
+CREATE OR REPLACE FUNCTION public.get_location_node(_level VARCHAR(20), _id INT)
+	RETURNS JSONB
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		CASE _level
+			WHEN 'region' THEN
+				RETURN (SELECT jsonb_build_object('id', r.id, 'region_name', r.region_name) FROM "Region" AS r WHERE r.id = _id);
+			WHEN 'site_group' THEN
+				RETURN (SELECT jsonb_build_object('id', sg.id, 'site_group_name', sg.site_group_name, 'region_id', sg.region_id) FROM "SiteGroup" AS sg WHERE sg.id = _id);
+			WHEN 'site' THEN
+				RETURN (SELECT jsonb_build_object('id', s.id, 'site_name', s.site_name, 'site_group_id', s.site_group_id, 'site_ga_id', s.site_ga_id) FROM "Site" AS s WHERE s.id = _id);
+			WHEN 'sub_site' THEN
+				RETURN (SELECT jsonb_build_object('id', ss.id, 'sub_site_name', ss.sub_site_name, 'site_id', ss.site_id) FROM "SubSite" AS ss WHERE ss.id = _id);
+			WHEN 'department' THEN
+				RETURN (SELECT jsonb_build_object('id', d.id, 'dept_name', d.dept_name) FROM "Department" AS d WHERE d.id = _id);
+			ELSE
+				RAISE EXCEPTION 'Unknown location level %', _level;
+		END CASE;
+	END;
+$$;
+
+-- create_site creates a site in a site group, looked after by a GA, and returns its ID.
+CREATE OR REPLACE FUNCTION public.create_site(_site_name VARCHAR(100), _site_group_id INT, _site_ga_id INT)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_id INT;
+	BEGIN
+		PERFORM check_site_ga(_site_ga_id);
+		INSERT INTO "Site" (site_name, site_group_id, site_ga_id) VALUES (_site_name, _site_group_id, _site_ga_id) RETURNING id INTO v_id;
+		RETURN v_id;
+	END;
+$$;
+
+-- update_site renames a site, moves it to another site group or hands it to another GA. NULL parameters keep the current value.
+CREATE OR REPLACE PROCEDURE public.update_site(_id INT, _site_name VARCHAR(100), _site_group_id INT, _site_ga_id INT)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF _site_ga_id IS NOT NULL THEN
+			PERFORM check_site_ga(_site_ga_id);
+		END IF;
+
+		UPDATE "Site" AS s
+		SET site_name = COALESCE(_site_name, s.site_name),
+			site_group_id = COALESCE(_site_group_id, s.site_group_id),
+			site_ga_id = COALESCE(_site_ga_id, s.site_ga_id)
+		WHERE s.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location site % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- delete_site deletes a site that has no sub-sites, assets, users nor opname history.
+CREATE OR REPLACE PROCEDURE public.delete_site(_id INT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_count INT;
+	BEGIN
+		SELECT COUNT(*) INTO v_count FROM "SubSite" AS ss WHERE ss.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still has % sub-sites', _id, v_count;
+		END IF;
+		SELECT COUNT(*) INTO v_count FROM "Asset" AS a WHERE a.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still holds % assets', _id, v_count;
+		END IF;
+		SELECT COUNT(*) INTO v_count FROM "User" AS u WHERE u.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still has % users', _id, v_count;
+		END IF;
+		SELECT COUNT(*) INTO v_count FROM "OpnameSession" AS os WHERE os.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still has % opname sessions', _id, v_count;
+		END IF;
+
+		DELETE FROM "Site" AS s WHERE s.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location site % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- create_department creates a head office department and returns its ID.
+CREATE OR REPLACE FUNCTION public.create_department(_dept_name VARCHAR(100))
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_id INT;
+	BEGIN
+		INSERT INTO "Department" (dept_name) VALUES (_dept_name) RETURNING id INTO v_id;
+		RETURN v_id;
+	END;
+$$;
+
+-- update_department renames a department.
+CREATE OR REPLACE PROCEDURE public.update_department(_id INT, _dept_name VARCHAR(100))
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "Department" AS d SET dept_name = COALESCE(_dept_name, d.dept_name) WHERE d.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location department % not found', _id;
+		END IF;
+	END;
+$$;
+
+DROP FUNCTION IF EXISTS public.get_default_head_office();
+DROP TRIGGER IF EXISTS site_head_office_changed ON "Site";
+DROP FUNCTION IF EXISTS public.check_head_office_departments();
+DROP TRIGGER IF EXISTS department_site_changed ON "Department";
+DROP FUNCTION IF EXISTS public.check_department_site();
+
+ALTER TABLE "Department" DROP COLUMN IF EXISTS "site_id";
+ALTER TABLE "Site" DROP COLUMN IF EXISTS "is_head_office";
@@ -0,0 +1,447 @@
+-- Models the head office explicitly instead of assuming site 25 ("Head Office Jakarta"), so that a second company
+-- can have its own head office. A head office is a site flagged with is_head_office, and departments belong to one.
+ALTER TABLE "Site" ADD COLUMN "is_head_office" BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE "Department" ADD COLUMN "site_id" INT REFERENCES "Site"("id") ON DELETE RESTRICT;
+
+-- Adopt the head office the functions used to assume: by name, or site 25 as get_user_opname_locations did.
+UPDATE "Site" SET is_head_office = TRUE WHERE LOWER(site_name) = 'head office jakarta';
+UPDATE "Site" SET is_head_office = TRUE WHERE id = 25 AND NOT EXISTS (SELECT 1 FROM "Site" WHERE is_head_office);
+UPDATE "Department" SET site_id = (SELECT s.id FROM "Site" AS s WHERE s.is_head_office ORDER BY s.id LIMIT 1);
+
+DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM "Department" WHERE site_id IS NULL) THEN
+			RAISE EXCEPTION 'No head office site found for the existing departments, flag it with "Site".is_head_office first';
+		END IF;
+	END;
+$$;
+
+ALTER TABLE "Department" ALTER COLUMN "site_id" SET NOT NULL;
+
+-- check_department_site keeps departments at head office sites.
+CREATE OR REPLACE FUNCTION public.check_department_site()
+	RETURNS TRIGGER
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM "Site" AS s WHERE s.id = NEW.site_id AND s.is_head_office) THEN
+			RAISE EXCEPTION 'Site % is not a head office', NEW.site_id;
+		END IF;
+		RETURN NEW;
+	END;
+$$;
+
+CREATE TRIGGER department_site_changed
+	BEFORE INSERT OR UPDATE OF site_id ON "Department"
+	FOR EACH ROW
+	EXECUTE FUNCTION check_department_site();
+
+-- check_head_office_departments refuses to unflag a head office that still has departments.
+CREATE OR REPLACE FUNCTION public.check_head_office_departments()
+	RETURNS TRIGGER
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_count INT;
+	BEGIN
+		SELECT COUNT(*) INTO v_count FROM "Department" AS d WHERE d.site_id = NEW.id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Head office site % still has % departments', NEW.id, v_count;
+		END IF;
+		RETURN NEW;
+	END;
+$$;
+
+CREATE TRIGGER site_head_office_changed
+	BEFORE UPDATE OF is_head_office ON "Site"
+	FOR EACH ROW
+	WHEN (OLD.is_head_office AND NOT NEW.is_head_office)
+	EXECUTE FUNCTION check_head_office_departments();
+
+-- get_default_head_office retrieves the head office site when there is exactly one, NULL otherwise.
+CREATE OR REPLACE FUNCTION public.get_default_head_office()
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_site_id INT;
+	BEGIN
+		SELECT MIN(s.id) INTO v_site_id
+		FROM "Site" AS s
+		WHERE s.is_head_office
+		HAVING COUNT(*) = 1;
+		RETURN v_site_id;
+	END;
+$$;
+
+-- get_user_opname_locations retrieves the sites/dept a user has access to using their position and id (from login session)
+-- Pagination and filtering is done here
+-- Note: This functions assumes that "L1 Support" users can see all sites and dept, while others are restriced to their region.
+-- It also assumes that users can only see their own department's sites.
+-- HO mode: shows departments only (for department-level opname), site_id is the department's head office
+-- Area mode: shows sites only (for site-level opname)
+CREATE OR REPLACE FUNCTION public.get_user_opname_locations(
+	_user_id INT,
+	_position VARCHAR(100),
+	_site_group_name VARCHAR(100),
+	_site_name VARCHAR(100),
+	_sub_site_name VARCHAR(100),
+	_dept_name VARCHAR(100),
+	_created_by VARCHAR(255),
+	_opname_status VARCHAR(20),
+	_from_date TIMESTAMP,
+	_end_date TIMESTAMP,
+	_search_in VARCHAR(10), -- Search in area/HO only
+	_limit INT,
+	_page_number INT
+)
+    RETURNS TABLE (
+		site_id INT,
+		dept_id INT,
+		dept_name VARCHAR(100),
+        site_name VARCHAR(100),
+        site_group_name VARCHAR(100),
+        region_name VARCHAR(100),
+        opname_status VARCHAR(20),
+        last_opname_date TIMESTAMP,
+		last_opname_by VARCHAR(255),
+		total_count BIGINT
+    )
+    LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_user_region_id INT;
+		v_user_dept_id INT;
+		v_offset INT := GREATEST(COALESCE(_page_number,1)-1,0) * COALESCE(NULLIF(_limit,0),20);
+		v_search_in VARCHAR(10) := LOWER(COALESCE(_search_in, 'area'));
+		v_user_position VARCHAR(100) := LOWER(COALESCE(_position,''));
+	BEGIN
+		-- Fetch the user's department and region context
+		SELECT r.id, d.id
+		INTO v_user_region_id, v_user_dept_id
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		LEFT JOIN "Region" AS r ON sg.region_id = r.id
+		LEFT JOIN "Department" AS d ON LOWER(u.department) = LOWER(d.dept_name)
+		WHERE u.user_id = _user_id;
+
+		IF v_search_in = 'ho' THEN
+			-- HO MODE: Show departments only (site_name = NULL)
+			RETURN QUERY
+			SELECT
+				d.site_id::INT AS site_id, -- The department's head office
+				d.id::INT AS dept_id,
+				d.dept_name::VARCHAR(100),
+				NULL::VARCHAR(100) AS site_name,
+				NULL::VARCHAR(100) AS site_group_name,
+				NULL::VARCHAR(100) AS region_name,
+				COALESCE(lo.session_status, 'Outdated')::VARCHAR(20) AS opname_status,
+				lo.session_end_date::TIMESTAMP AS last_opname_date,
+				lo.created_by::VARCHAR(255) AS last_opname_by,
+				COUNT(*) OVER()::BIGINT AS total_count
+			FROM "Department" AS d
+			INNER JOIN "Site" AS hs ON d.site_id = hs.id
+			LEFT JOIN LATERAL get_latest_opname_status(NULL, d.id) lo ON TRUE
+			WHERE 
+				-- Access control: L1 support sees all, others see only their department
+				(v_user_position = 'l1 support' OR d.id = v_user_dept_id)
+				-- Filters
+				AND (_site_name IS NULL OR _site_name = '' OR hs.site_name ILIKE '%'||_site_name||'%') -- Picks one head office when there are several
+				AND (_dept_name IS NULL OR _dept_name = '' OR d.dept_name ILIKE '%'||_dept_name||'%')
+				AND (_created_by IS NULL OR _created_by = '' OR lo.created_by ILIKE '%'||_created_by||'%')
+				AND (_from_date IS NULL OR lo.session_end_date >= _from_date)
+				AND (_end_date IS NULL OR lo.session_end_date <= _end_date)
+				AND (_opname_status IS NULL OR _opname_status = '' OR COALESCE(lo.session_status, 'Outdated') = _opname_status)
+			ORDER BY d.dept_name
+			LIMIT COALESCE(NULLIF(_limit,0), 20) OFFSET v_offset;
+		ELSE
+			-- AREA MODE: Show sites only (dept_name = NULL)  
+			RETURN QUERY
+			SELECT
+				s.id::INT AS site_id,
+				NULL::INT AS dept_id,
+				NULL::VARCHAR(100) AS dept_name,
+				s.site_name::VARCHAR(100),
+				sg.site_group_name::VARCHAR(100),
+				r.region_name::VARCHAR(100),
+				COALESCE(lo.session_status, 'Outdated')::VARCHAR(20) AS opname_status,
+				lo.session_end_date::TIMESTAMP AS last_opname_date,
+				lo.created_by::VARCHAR(255) AS last_opname_by,
+				COUNT(*) OVER()::BIGINT AS total_count
+			FROM "Site" AS s
+			INNER JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+			INNER JOIN "Region" AS r ON sg.region_id = r.id
+			LEFT JOIN "SubSite" AS ss ON (_sub_site_name IS NOT NULL AND _sub_site_name <> '') AND ss.site_id = s.id
+			LEFT JOIN LATERAL get_latest_opname_status(s.id, NULL) lo ON TRUE
+			WHERE
+				-- Access control: L1 support sees all, area users see only their region and must be managers
+				(v_user_position = 'l1 support' 
+				 OR (v_user_position IN ('admin staff general affairs', 'area manager') AND r.id = v_user_region_id))
+				-- Filters
+				AND (_site_group_name IS NULL OR _site_group_name = '' OR sg.site_group_name ILIKE '%'||_site_group_name||'%')
+				AND (_site_name IS NULL OR _site_name = '' OR s.site_name ILIKE '%'||_site_name||'%')
+				AND (_sub_site_name IS NULL OR _sub_site_name = '' OR ss.sub_site_name ILIKE '%'||_sub_site_name||'%')
+				AND (_created_by IS NULL OR _created_by = '' OR lo.created_by ILIKE '%'||_created_by||'%')
+				AND (_from_date IS NULL OR lo.session_end_date >= _from_date)
+				AND (_end_date IS NULL OR lo.session_end_date <= _end_date)
+				AND (_opname_status IS NULL OR _opname_status = '' OR COALESCE(lo.session_status, 'Outdated') = _opname_status)
+			ORDER BY s.site_name
+			LIMIT COALESCE(NULLIF(_limit,0), 20) OFFSET v_offset;
+		END IF;
+	END;
+$$;
+
+-- is_opname_session_reviewer checks whether a user is the next reviewer on the approval path of a session's location.
+-- Submitted sessions are reviewed by an area manager of the location's region, escalated sessions by L1 support.
+-- Department sessions belong to the department's head office site.
+CREATE OR REPLACE FUNCTION public.is_opname_session_reviewer(_session_id INT, _user_id INT)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_status VARCHAR(20);
+		_session_region_id INT;
+		_user_position VARCHAR(100);
+		_user_region_id INT;
+	BEGIN
+		SELECT os.status, sg.region_id
+		INTO _status, _session_region_id
+		FROM "OpnameSession" AS os
+		LEFT JOIN "Department" AS d ON os.dept_id = d.id
+		LEFT JOIN "Site" AS s ON s.id = COALESCE(os.site_id, d.site_id)
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		WHERE os.id = _session_id;
+
+		SELECT LOWER(u.position), sg.region_id
+		INTO _user_position, _user_region_id
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		WHERE u.user_id = _user_id;
+
+		IF _status = 'Submitted' THEN
+			RETURN _user_position = 'area manager' AND _user_region_id = _session_region_id;
+		ELSIF _status = 'Escalated' THEN
+			RETURN _user_position = 'l1 support';
+		END IF;
+
+		-- Sessions that are not waiting for a review have no reviewer.
+		RETURN FALSE;
+	END;
+$$;
+
+-- The functions below gain a column or a parameter, so they are dropped and created again.
+DROP FUNCTION IF EXISTS public.get_dept_by_id(INT);
+DROP FUNCTION IF EXISTS public.get_location_tree();
+DROP FUNCTION IF EXISTS public.get_all_departments();
+DROP FUNCTION IF EXISTS public.create_site(VARCHAR, INT, INT);
+DROP PROCEDURE IF EXISTS public.update_site(INT, VARCHAR, INT, INT);
+DROP FUNCTION IF EXISTS public.create_department(VARCHAR);
+DROP PROCEDURE IF EXISTS public.update_department(INT, VARCHAR);
+
+-- get_dept_by_id retrieves department details by department ID
+CREATE OR REPLACE FUNCTION public.get_dept_by_id(_dept_id INT)
+	RETURNS TABLE (
+		dept_id INT,
+		dept_name VARCHAR(100),
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100),
+		latest_opname_session_id INT,
+		site_id INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT 
+			d.id AS dept_id,
+			d.dept_name,
+			s.site_name,
+			sg.site_group_name,
+			r.region_name,
+			COALESCE(os.id, -1) AS opname_session_id,
+			s.id AS site_id
+		FROM "Department" AS d
+		INNER JOIN "Site" AS s ON d.site_id = s.id
+		INNER JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		INNER JOIN "Region" AS r ON sg.region_id = r.id
+		LEFT JOIN "OpnameSession" AS os ON os.dept_id = d.id
+		WHERE d.id = _dept_id;
+	END;
+$$;
+
+-- get_location_tree retrieves the whole Region > SiteGroup > Site > SubSite hierarchy as one row per deepest node,
+-- with NULLs below a node that has no children. The API nests it.
+CREATE OR REPLACE FUNCTION public.get_location_tree()
+	RETURNS TABLE (
+		region_id INT,
+		region_name VARCHAR(100),
+		site_group_id INT,
+		site_group_name VARCHAR(100),
+		site_id INT,
+		site_name VARCHAR(100),
+		site_ga_id INT,
+		is_head_office BOOLEAN,
+		sub_site_id INT,
+		sub_site_name VARCHAR(100)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT r.id, r.region_name, sg.id, sg.site_group_name, s.id, s.site_name, s.site_ga_id, s.is_head_office, ss.id, ss.sub_site_name
+		FROM "Region" AS r
+		LEFT JOIN "SiteGroup" AS sg ON sg.region_id = r.id
+		LEFT JOIN "Site" AS s ON s.site_group_id = sg.id
+		LEFT JOIN "SubSite" AS ss ON ss.site_id = s.id
+		ORDER BY r.region_name, sg.site_group_name, s.site_name, ss.sub_site_name;
+	END;
+$$;
+
+-- get_all_departments retrieves every department, ordered by name.
+CREATE OR REPLACE FUNCTION public.get_all_departments()
+	RETURNS TABLE (
+		dept_id INT,
+		dept_name VARCHAR(100),
+		site_id INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT d.id, d.dept_name, d.site_id
+		FROM "Department" AS d
+		ORDER BY d.dept_name;
+	END;
+$$;
+
+-- get_location_node retrieves one node of the hierarchy as JSON, NULL when it does not exist.
+-- _level is one of region, site_group, site, sub_site or department.
+CREATE OR REPLACE FUNCTION public.get_location_node(_level VARCHAR(20), _id INT)
+	RETURNS JSONB
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		CASE _level
+			WHEN 'region' THEN
+				RETURN (SELECT jsonb_build_object('id', r.id, 'region_name', r.region_name) FROM "Region" AS r WHERE r.id = _id);
+			WHEN 'site_group' THEN
+				RETURN (SELECT jsonb_build_object('id', sg.id, 'site_group_name', sg.site_group_name, 'region_id', sg.region_id) FROM "SiteGroup" AS sg WHERE sg.id = _id);
+			WHEN 'site' THEN
+				RETURN (SELECT jsonb_build_object('id', s.id, 'site_name', s.site_name, 'site_group_id', s.site_group_id, 'site_ga_id', s.site_ga_id, 'is_head_office', s.is_head_office) FROM "Site" AS s WHERE s.id = _id);
+			WHEN 'sub_site' THEN
+				RETURN (SELECT jsonb_build_object('id', ss.id, 'sub_site_name', ss.sub_site_name, 'site_id', ss.site_id) FROM "SubSite" AS ss WHERE ss.id = _id);
+			WHEN 'department' THEN
+				RETURN (SELECT jsonb_build_object('id', d.id, 'dept_name', d.dept_name, 'site_id', d.site_id) FROM "Department" AS d WHERE d.id = _id);
+			ELSE
+				RAISE EXCEPTION 'Unknown location level %', _level;
+		END CASE;
+	END;
+$$;
+
+-- create_site creates a site or head office in a site group, looked after by a GA, and returns its ID.
+CREATE OR REPLACE FUNCTION public.create_site(_site_name VARCHAR(100), _site_group_id INT, _site_ga_id INT, _is_head_office BOOLEAN)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_id INT;
+	BEGIN
+		PERFORM check_site_ga(_site_ga_id);
+		INSERT INTO "Site" (site_name, site_group_id, site_ga_id, is_head_office) VALUES (_site_name, _site_group_id, _site_ga_id, COALESCE(_is_head_office, FALSE)) RETURNING id INTO v_id;
+		RETURN v_id;
+	END;
+$$;
+
+-- update_site renames a site, moves it to another site group, hands it to another GA or flags it as a head office. NULL parameters keep the current value.
+CREATE OR REPLACE PROCEDURE public.update_site(_id INT, _site_name VARCHAR(100), _site_group_id INT, _site_ga_id INT, _is_head_office BOOLEAN)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF _site_ga_id IS NOT NULL THEN
+			PERFORM check_site_ga(_site_ga_id);
+		END IF;
+
+		UPDATE "Site" AS s
+		SET site_name = COALESCE(_site_name, s.site_name),
+			site_group_id = COALESCE(_site_group_id, s.site_group_id),
+			site_ga_id = COALESCE(_site_ga_id, s.site_ga_id),
+			is_head_office = COALESCE(_is_head_office, s.is_head_office)
+		WHERE s.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location site % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- delete_site deletes a site that has no sub-sites, departments, assets, users nor opname history.
+CREATE OR REPLACE PROCEDURE public.delete_site(_id INT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_count INT;
+	BEGIN
+		SELECT COUNT(*) INTO v_count FROM "SubSite" AS ss WHERE ss.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still has % sub-sites', _id, v_count;
+		END IF;
+		SELECT COUNT(*) INTO v_count FROM "Department" AS d WHERE d.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still has % departments', _id, v_count;
+		END IF;
+		SELECT COUNT(*) INTO v_count FROM "Asset" AS a WHERE a.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still holds % assets', _id, v_count;
+		END IF;
+		SELECT COUNT(*) INTO v_count FROM "User" AS u WHERE u.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still has % users', _id, v_count;
+		END IF;
+		SELECT COUNT(*) INTO v_count FROM "OpnameSession" AS os WHERE os.site_id = _id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Location site % still has % opname sessions', _id, v_count;
+		END IF;
+
+		DELETE FROM "Site" AS s WHERE s.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location site % not found', _id;
+		END IF;
+	END;
+$$;
+
+-- create_department creates a department at a head office and returns its ID. Without _site_id, the department goes
+-- to the head office when there is only one.
+CREATE OR REPLACE FUNCTION public.create_department(_dept_name VARCHAR(100), _site_id INT)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_id INT;
+		v_site_id INT;
+	BEGIN
+		v_site_id := COALESCE(_site_id, get_default_head_office());
+		IF v_site_id IS NULL THEN
+			RAISE EXCEPTION 'There are several head offices, site_id is required';
+		END IF;
+
+		INSERT INTO "Department" (dept_name, site_id) VALUES (_dept_name, v_site_id) RETURNING id INTO v_id;
+		RETURN v_id;
+	END;
+$$;
+
+-- update_department renames a department or moves it to another head office. NULL parameters keep the current value.
+CREATE OR REPLACE PROCEDURE public.update_department(_id INT, _dept_name VARCHAR(100), _site_id INT)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "Department" AS d
+		SET dept_name = COALESCE(_dept_name, d.dept_name),
+			site_id = COALESCE(_site_id, d.site_id)
+		WHERE d.id = _id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Location department % not found', _id;
+		END IF;
+	END;
+$$;
@@ -0,0 +1,10 @@
+-- Removes the cost center administration and restores the cascading delete of users.
+DROP FUNCTION IF EXISTS public.get_cost_center_chargeback(TIMESTAMP, TIMESTAMP);
+DROP PROCEDURE IF EXISTS public.delete_cost_center(INT);
+DROP PROCEDURE IF EXISTS public.update_cost_center(INT, VARCHAR);
+DROP PROCEDURE IF EXISTS public.create_cost_center(INT, VARCHAR);
+DROP FUNCTION IF EXISTS public.get_cost_center_by_id(INT);
+DROP FUNCTION IF EXISTS public.get_all_cost_centers();
+
+ALTER TABLE "User" DROP CONSTRAINT "User_cost_center_id_fkey",
+	ADD CONSTRAINT "User_cost_center_id_fkey" FOREIGN KEY ("cost_center_id") REFERENCES "CostCenter"("cost_center_id") ON DELETE CASCADE;
@@ -0,0 +1,164 @@
+-- Cost center master data (internal/costcenter) and the chargeback report.
+
+-- Deleting a cost center used to delete its users. Refuse instead; delete_cost_center explains what is still attached.
+ALTER TABLE "User" DROP CONSTRAINT "User_cost_center_id_fkey",
+	ADD CONSTRAINT "User_cost_center_id_fkey" FOREIGN KEY ("cost_center_id") REFERENCES "CostCenter"("cost_center_id") ON DELETE RESTRICT;
+
+-- get_all_cost_centers retrieves every cost center with the number of users charged to it, ordered by ID.
+CREATE OR REPLACE FUNCTION public.get_all_cost_centers()
+	RETURNS TABLE (
+		cost_center_id INT,
+		cost_center_name VARCHAR(100),
+		user_count BIGINT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT cc.cost_center_id, cc.cost_center_name, COUNT(u.user_id) AS user_count
+		FROM "CostCenter" AS cc
+		LEFT JOIN "User" AS u ON u.cost_center_id = cc.cost_center_id
+		GROUP BY cc.cost_center_id, cc.cost_center_name
+		ORDER BY cc.cost_center_id;
+	END;
+$$;
+
+-- get_cost_center_by_id retrieves one cost center with the number of users charged to it.
+CREATE OR REPLACE FUNCTION public.get_cost_center_by_id(_cost_center_id INT)
+	RETURNS TABLE (
+		cost_center_id INT,
+		cost_center_name VARCHAR(100),
+		user_count BIGINT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT cc.cost_center_id, cc.cost_center_name, COUNT(u.user_id) AS user_count
+		FROM "CostCenter" AS cc
+		LEFT JOIN "User" AS u ON u.cost_center_id = cc.cost_center_id
+		WHERE cc.cost_center_id = _cost_center_id
+		GROUP BY cc.cost_center_id, cc.cost_center_name;
+	END;
+$$;
+
+-- create_cost_center creates a cost center. Its ID is the finance code, so the caller chooses it.
+CREATE OR REPLACE PROCEDURE public.create_cost_center(_cost_center_id INT, _cost_center_name VARCHAR(100))
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM "CostCenter" AS cc WHERE cc.cost_center_id = _cost_center_id) THEN
+			RAISE EXCEPTION 'Cost center ID % is already taken', _cost_center_id;
+		END IF;
+
+		INSERT INTO "CostCenter" (cost_center_id, cost_center_name) VALUES (_cost_center_id, _cost_center_name);
+	END;
+$$;
+
+-- update_cost_center renames a cost center.
+CREATE OR REPLACE PROCEDURE public.update_cost_center(_cost_center_id INT, _cost_center_name VARCHAR(100))
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "CostCenter" AS cc SET cost_center_name = _cost_center_name WHERE cc.cost_center_id = _cost_center_id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Cost center % not found', _cost_center_id;
+		END IF;
+	END;
+$$;
+
+-- delete_cost_center deletes a cost center no user is charged to.
+CREATE OR REPLACE PROCEDURE public.delete_cost_center(_cost_center_id INT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_count INT;
+	BEGIN
+		SELECT COUNT(*) INTO v_count FROM "User" AS u WHERE u.cost_center_id = _cost_center_id;
+		IF v_count > 0 THEN
+			RAISE EXCEPTION 'Cost center % still has % users', _cost_center_id, v_count;
+		END IF;
+
+		DELETE FROM "CostCenter" AS cc WHERE cc.cost_center_id = _cost_center_id;
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Cost center % not found', _cost_center_id;
+		END IF;
+	END;
+$$;
+
+-- get_cost_center_chargeback aggregates the assets of verified opname sessions per cost center, so that finance can
+-- charge losses back. Each asset counts once, as recorded by its latest session verified between _from_date and
+-- _end_date inclusive (either may be NULL). Assets are charged to their effective owner's cost center, i.e. after the
+-- changes recorded in that session; an owner change to another cost center moves the asset's value out of the old
+-- cost center and into the new one. Assets without a cost center (VACANT owners) are grouped under a NULL cost center.
+CREATE OR REPLACE FUNCTION public.get_cost_center_chargeback(_from_date TIMESTAMP, _end_date TIMESTAMP)
+	RETURNS TABLE (
+		cost_center_id INT,
+		cost_center_name VARCHAR(100),
+		asset_count BIGINT,
+		total_cost BIGINT,
+		broken_count BIGINT,
+		broken_cost BIGINT,
+		missing_count BIGINT,
+		missing_cost BIGINT,
+		transferred_in_count BIGINT,
+		transferred_in_cost BIGINT,
+		transferred_out_count BIGINT,
+		transferred_out_cost BIGINT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		WITH latest AS (
+			-- The latest verified record of each asset in the period
+			SELECT DISTINCT ON (ac.asset_tag) ac.asset_tag, ac.session_id, ac."changes"
+			FROM "AssetChanges" AS ac
+			INNER JOIN "OpnameSession" AS os ON ac.session_id = os.id
+			WHERE os.status = 'Verified'
+				AND (_from_date IS NULL OR os.l1_reviewed_at >= _from_date)
+				AND (_end_date IS NULL OR os.l1_reviewed_at < _end_date + INTERVAL '1 day')
+			ORDER BY ac.asset_tag, os.l1_reviewed_at DESC, os.id DESC
+		),
+		effective AS (
+			SELECT
+				a.asset_tag,
+				COALESCE(a.total_cost, 0)::BIGINT AS cost,
+				NULLIF(ou.cost_center_id, 0) AS old_cost_center,
+				NULLIF(COALESCE((l."changes"->>'newOwnerCostCenter')::INT, nu.cost_center_id, ou.cost_center_id), 0) AS new_cost_center,
+				COALESCE(l."changes"->>'newStatus', a.status) = 'Down' AS is_broken,
+				COALESCE((l."changes"->>'newCondition')::INT, a.condition) = 2 AS is_missing
+			FROM latest AS l
+			INNER JOIN "Asset" AS a ON l.asset_tag = a.asset_tag
+			LEFT JOIN "User" AS ou ON a.owner_id = ou.user_id -- original owner
+			LEFT JOIN "User" AS nu ON nu.user_id = (l."changes"->>'newOwnerID')::INT -- new owner, if changed
+		),
+		movements AS (
+			-- Every asset is held by its effective cost center, and leaves the old one when they differ
+			SELECT e.new_cost_center AS cc_id, e.cost, e.is_broken, e.is_missing, TRUE AS held,
+				e.new_cost_center IS DISTINCT FROM e.old_cost_center AS moved_in, FALSE AS moved_out
+			FROM effective AS e
+			UNION ALL
+			SELECT e.old_cost_center, e.cost, FALSE, FALSE, FALSE, FALSE, TRUE
+			FROM effective AS e
+			WHERE e.new_cost_center IS DISTINCT FROM e.old_cost_center
+		)
+		SELECT
+			m.cc_id,
+			cc.cost_center_name,
+			COUNT(*) FILTER (WHERE m.held),
+			COALESCE(SUM(m.cost) FILTER (WHERE m.held), 0)::BIGINT,
+			COUNT(*) FILTER (WHERE m.is_broken),
+			COALESCE(SUM(m.cost) FILTER (WHERE m.is_broken), 0)::BIGINT,
+			COUNT(*) FILTER (WHERE m.is_missing),
+			COALESCE(SUM(m.cost) FILTER (WHERE m.is_missing), 0)::BIGINT,
+			COUNT(*) FILTER (WHERE m.moved_in),
+			COALESCE(SUM(m.cost) FILTER (WHERE m.moved_in), 0)::BIGINT,
+			COUNT(*) FILTER (WHERE m.moved_out),
+			COALESCE(SUM(m.cost) FILTER (WHERE m.moved_out), 0)::BIGINT
+		FROM movements AS m
+		LEFT JOIN "CostCenter" AS cc ON m.cc_id = cc.cost_center_id
+		GROUP BY m.cc_id, cc.cost_center_name
+		ORDER BY m.cc_id NULLS LAST;
+	END;
+$$;
@@ -0,0 +1,9 @@
+-- Removes the asset transfers. Approved transfers stay applied to the asset master.
+DROP PROCEDURE IF EXISTS public.close_asset_transfer(INT, VARCHAR, INT, TEXT);
+DROP PROCEDURE IF EXISTS public.approve_asset_transfer(INT, INT, TEXT);
+DROP FUNCTION IF EXISTS public.is_asset_transfer_approver(INT, INT);
+DROP FUNCTION IF EXISTS public.is_asset_transfer_requester(VARCHAR, INT);
+DROP FUNCTION IF EXISTS public.get_asset_transfers(INT, VARCHAR, VARCHAR, INT, INT, INT, INT);
+DROP FUNCTION IF EXISTS public.create_asset_transfer(VARCHAR, INT, INT, INT, INT, INT, TEXT);
+
+DROP TABLE IF EXISTS "AssetTransfer";
@@ -0,0 +1,391 @@
+-- Asset transfers (mutasi) outside of opname sessions (internal/transfer).
+-- A transfer moves an asset to another owner, sub-site or department and is approved by the area manager of the receiving site.
+-- Approval updates the asset master, so later opnames no longer report the asset as misplaced.
+
+CREATE TABLE "AssetTransfer" (
+    "id" SERIAL PRIMARY KEY,
+    "asset_tag" VARCHAR(12) NOT NULL REFERENCES "Asset"("asset_tag"),
+    "status" VARCHAR(20) NOT NULL CHECK ("status" IN ('Pending', 'Approved', 'Rejected', 'Cancelled')) DEFAULT 'Pending',
+    "reason" TEXT NOT NULL,
+
+    -- Foreign key to User (the user who requested the transfer).
+    "requester_id" INT NOT NULL REFERENCES "User"("user_id"),
+    "requested_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Where the asset was when the transfer was requested. The cost center is the owner's.
+    "from_owner_id" INT NOT NULL REFERENCES "User"("user_id"),
+    "from_cost_center_id" INT REFERENCES "CostCenter"("cost_center_id"),
+    "from_sub_site_id" INT REFERENCES "SubSite"("id"),
+    "from_dept_id" INT REFERENCES "Department"("id"),
+    "from_site_id" INT NOT NULL REFERENCES "Site"("id"),
+
+    -- Where the asset goes. At most one of to_sub_site_id or to_dept_id is set, to_site_id is their parent site.
+    "to_owner_id" INT NOT NULL REFERENCES "User"("user_id"),
+    "to_cost_center_id" INT REFERENCES "CostCenter"("cost_center_id"),
+    "to_sub_site_id" INT REFERENCES "SubSite"("id"),
+    "to_dept_id" INT REFERENCES "Department"("id"),
+    "to_site_id" INT NOT NULL REFERENCES "Site"("id"),
+
+    -- Foreign key to User (the manager who approved or rejected the transfer, or the user who cancelled it).
+    "reviewer_id" INT REFERENCES "User"("user_id") ON DELETE SET NULL,
+    "reviewed_at" TIMESTAMP WITH TIME ZONE,
+    "review_notes" TEXT NOT NULL DEFAULT '',
+
+    CONSTRAINT ck_transfer_destination CHECK ("to_sub_site_id" IS NULL OR "to_dept_id" IS NULL)
+);
+
+-- An asset has at most one pending transfer at a time.
+CREATE UNIQUE INDEX uq_asset_transfer_pending ON "AssetTransfer" ("asset_tag") WHERE "status" = 'Pending';
+CREATE INDEX idx_asset_transfer_requested_at ON "AssetTransfer" ("requested_at" DESC);
+
+-- create_asset_transfer requests the transfer of an asset and returns its ID.
+-- Unset destination fields keep the asset's current owner and location, the cost center defaults to the new owner's.
+CREATE OR REPLACE FUNCTION public.create_asset_transfer(
+	_asset_tag VARCHAR(12),
+	_requester_id INT,
+	_to_owner_id INT,
+	_to_sub_site_id INT,
+	_to_dept_id INT,
+	_to_cost_center_id INT,
+	_reason TEXT
+)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_asset RECORD;
+		v_from_cost_center_id INT;
+		v_to_owner_id INT;
+		v_to_cost_center_id INT;
+		v_to_sub_site_id INT;
+		v_to_dept_id INT;
+		v_to_site_id INT;
+		v_id INT;
+	BEGIN
+		SELECT a.asset_tag, a.status, a.owner_id, a.sub_site_id, a.dept_id, a.site_id
+		INTO v_asset
+		FROM "Asset" AS a
+		WHERE a.asset_tag = _asset_tag
+		FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Asset with tag % not found', _asset_tag;
+		END IF;
+
+		IF v_asset.status = 'Disposed' THEN
+			RAISE EXCEPTION 'Asset % is disposed and cannot be transferred', _asset_tag;
+		END IF;
+
+		IF EXISTS (SELECT 1 FROM "AssetTransfer" AS t WHERE t.asset_tag = _asset_tag AND t.status = 'Pending') THEN
+			RAISE EXCEPTION 'Asset % already has a pending transfer', _asset_tag;
+		END IF;
+
+		IF _to_sub_site_id IS NOT NULL AND _to_dept_id IS NOT NULL THEN
+			RAISE EXCEPTION 'A transfer goes to either a sub-site or a department, not both';
+		END IF;
+
+		-- The receiving owner must be an active user. The VACANT placeholder takes the asset back into stock.
+		v_to_owner_id := COALESCE(_to_owner_id, v_asset.owner_id);
+		SELECT u.cost_center_id INTO v_to_cost_center_id
+		FROM "User" AS u
+		WHERE u.user_id = v_to_owner_id AND u.is_active;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Transfer recipient % not found or deactivated', v_to_owner_id;
+		END IF;
+
+		IF _to_cost_center_id IS NOT NULL AND _to_cost_center_id IS DISTINCT FROM v_to_cost_center_id THEN
+			IF v_to_owner_id = 1 THEN
+				RAISE EXCEPTION 'The VACANT placeholder user cannot be changed';
+			END IF;
+			IF NOT EXISTS (SELECT 1 FROM "CostCenter" AS cc WHERE cc.cost_center_id = _to_cost_center_id) THEN
+				RAISE EXCEPTION 'Cost center % not found', _to_cost_center_id;
+			END IF;
+			v_to_cost_center_id := _to_cost_center_id;
+		END IF;
+
+		IF _to_sub_site_id IS NOT NULL THEN
+			SELECT ss.id, ss.site_id INTO v_to_sub_site_id, v_to_site_id FROM "SubSite" AS ss WHERE ss.id = _to_sub_site_id;
+			IF NOT FOUND THEN
+				RAISE EXCEPTION 'Location sub-site % not found', _to_sub_site_id;
+			END IF;
+		ELSIF _to_dept_id IS NOT NULL THEN
+			SELECT d.id, d.site_id INTO v_to_dept_id, v_to_site_id FROM "Department" AS d WHERE d.id = _to_dept_id;
+			IF NOT FOUND THEN
+				RAISE EXCEPTION 'Location department % not found', _to_dept_id;
+			END IF;
+		ELSE
+			v_to_sub_site_id := v_asset.sub_site_id;
+			v_to_dept_id := v_asset.dept_id;
+			v_to_site_id := v_asset.site_id;
+		END IF;
+
+		SELECT u.cost_center_id INTO v_from_cost_center_id FROM "User" AS u WHERE u.user_id = v_asset.owner_id;
+
+		IF v_to_owner_id = v_asset.owner_id
+			AND v_to_cost_center_id IS NOT DISTINCT FROM v_from_cost_center_id
+			AND v_to_sub_site_id IS NOT DISTINCT FROM v_asset.sub_site_id
+			AND v_to_dept_id IS NOT DISTINCT FROM v_asset.dept_id
+			AND v_to_site_id = v_asset.site_id
+		THEN
+			RAISE EXCEPTION 'Transfer of asset % changes nothing', _asset_tag;
+		END IF;
+
+		INSERT INTO "AssetTransfer" (
+			asset_tag, reason, requester_id,
+			from_owner_id, from_cost_center_id, from_sub_site_id, from_dept_id, from_site_id,
+			to_owner_id, to_cost_center_id, to_sub_site_id, to_dept_id, to_site_id
+		)
+		VALUES (
+			_asset_tag, _reason, _requester_id,
+			v_asset.owner_id, v_from_cost_center_id, v_asset.sub_site_id, v_asset.dept_id, v_asset.site_id,
+			v_to_owner_id, v_to_cost_center_id, v_to_sub_site_id, v_to_dept_id, v_to_site_id
+		)
+		RETURNING id INTO v_id;
+
+		RETURN v_id;
+	END;
+$$;
+
+-- get_asset_transfers retrieves transfers with the names needed to display them and print their BAST, newest first.
+-- Every filter is optional. _user_id matches the transfers a user requested, hands over, receives or reviewed,
+-- _site_id the transfers from or to a site. Pagination is done here, total_count is the number of transfers matching the filters.
+CREATE OR REPLACE FUNCTION public.get_asset_transfers(
+	_transfer_id INT,
+	_status VARCHAR(20),
+	_asset_tag VARCHAR(12),
+	_site_id INT,
+	_user_id INT,
+	_limit INT,
+	_page_number INT
+)
+	RETURNS TABLE (
+		id INT,
+		asset_tag VARCHAR(12),
+		serial_number VARCHAR(25),
+		brand_name VARCHAR(25),
+		product_name VARCHAR(50),
+		product_variety VARCHAR(50),
+		equipments TEXT,
+		status VARCHAR(20),
+		reason TEXT,
+		requester_id INT,
+		requester_name VARCHAR(510),
+		requested_at TIMESTAMP WITH TIME ZONE,
+		from_owner_id INT,
+		from_owner_name VARCHAR(510),
+		from_owner_position VARCHAR(100),
+		from_cost_center_id INT,
+		from_cost_center_name VARCHAR(100),
+		from_sub_site_id INT,
+		from_sub_site_name VARCHAR(100),
+		from_dept_id INT,
+		from_dept_name VARCHAR(100),
+		from_site_id INT,
+		from_site_name VARCHAR(100),
+		to_owner_id INT,
+		to_owner_name VARCHAR(510),
+		to_owner_position VARCHAR(100),
+		to_cost_center_id INT,
+		to_cost_center_name VARCHAR(100),
+		to_sub_site_id INT,
+		to_sub_site_name VARCHAR(100),
+		to_dept_id INT,
+		to_dept_name VARCHAR(100),
+		to_site_id INT,
+		to_site_name VARCHAR(100),
+		reviewer_id INT,
+		reviewer_name VARCHAR(510),
+		reviewed_at TIMESTAMP WITH TIME ZONE,
+		review_notes TEXT,
+		total_count BIGINT
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_offset INT := GREATEST(COALESCE(_page_number,1)-1,0) * COALESCE(NULLIF(_limit,0),50);
+	BEGIN
+		RETURN QUERY
+		SELECT
+			t.id,
+			t.asset_tag,
+			a.serial_number,
+			a.brand_name,
+			a.product_name,
+			a.product_variety,
+			a.equipments,
+			t.status,
+			t.reason,
+			t.requester_id,
+			(COALESCE(ru.first_name, '') || ' ' || COALESCE(ru.last_name, ''))::VARCHAR(510) AS requester_name,
+			t.requested_at,
+			t.from_owner_id,
+			(COALESCE(fu.first_name, '') || ' ' || COALESCE(fu.last_name, ''))::VARCHAR(510) AS from_owner_name,
+			fu.position AS from_owner_position,
+			t.from_cost_center_id,
+			fcc.cost_center_name AS from_cost_center_name,
+			t.from_sub_site_id,
+			fss.sub_site_name AS from_sub_site_name,
+			t.from_dept_id,
+			fd.dept_name AS from_dept_name,
+			t.from_site_id,
+			fs.site_name AS from_site_name,
+			t.to_owner_id,
+			(COALESCE(tu.first_name, '') || ' ' || COALESCE(tu.last_name, ''))::VARCHAR(510) AS to_owner_name,
+			tu.position AS to_owner_position,
+			t.to_cost_center_id,
+			tcc.cost_center_name AS to_cost_center_name,
+			t.to_sub_site_id,
+			tss.sub_site_name AS to_sub_site_name,
+			t.to_dept_id,
+			td.dept_name AS to_dept_name,
+			t.to_site_id,
+			ts.site_name AS to_site_name,
+			t.reviewer_id,
+			(CASE WHEN rv.user_id IS NULL THEN NULL ELSE COALESCE(rv.first_name, '') || ' ' || COALESCE(rv.last_name, '') END)::VARCHAR(510) AS reviewer_name,
+			t.reviewed_at,
+			t.review_notes,
+			COUNT(*) OVER()::BIGINT AS total_count
+		FROM "AssetTransfer" AS t
+		INNER JOIN "Asset" AS a ON t.asset_tag = a.asset_tag
+		INNER JOIN "User" AS ru ON t.requester_id = ru.user_id
+		INNER JOIN "User" AS fu ON t.from_owner_id = fu.user_id
+		INNER JOIN "User" AS tu ON t.to_owner_id = tu.user_id
+		LEFT JOIN "User" AS rv ON t.reviewer_id = rv.user_id
+		LEFT JOIN "CostCenter" AS fcc ON t.from_cost_center_id = fcc.cost_center_id
+		LEFT JOIN "CostCenter" AS tcc ON t.to_cost_center_id = tcc.cost_center_id
+		LEFT JOIN "SubSite" AS fss ON t.from_sub_site_id = fss.id
+		LEFT JOIN "SubSite" AS tss ON t.to_sub_site_id = tss.id
+		LEFT JOIN "Department" AS fd ON t.from_dept_id = fd.id
+		LEFT JOIN "Department" AS td ON t.to_dept_id = td.id
+		INNER JOIN "Site" AS fs ON t.from_site_id = fs.id
+		INNER JOIN "Site" AS ts ON t.to_site_id = ts.id
+		WHERE
+			(_transfer_id IS NULL OR t.id = _transfer_id)
+			AND (_status IS NULL OR _status = '' OR t.status = _status)
+			AND (_asset_tag IS NULL OR _asset_tag = '' OR t.asset_tag = _asset_tag)
+			AND (_site_id IS NULL OR t.from_site_id = _site_id OR t.to_site_id = _site_id)
+			AND (_user_id IS NULL OR _user_id IN (t.requester_id, t.from_owner_id, t.to_owner_id, t.reviewer_id))
+		ORDER BY t.requested_at DESC, t.id DESC
+		LIMIT COALESCE(NULLIF(_limit,0), 50) OFFSET v_offset;
+	END;
+$$;
+
+-- is_asset_transfer_requester checks whether a user may request the transfer of an asset:
+-- its current owner or the GA staff of the site it is on.
+CREATE OR REPLACE FUNCTION public.is_asset_transfer_requester(_asset_tag VARCHAR(12), _user_id INT)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN EXISTS (
+			SELECT 1
+			FROM "Asset" AS a
+			INNER JOIN "Site" AS s ON a.site_id = s.id
+			WHERE a.asset_tag = _asset_tag AND (a.owner_id = _user_id OR s.site_ga_id = _user_id)
+		);
+	END;
+$$;
+
+-- is_asset_transfer_approver checks whether a user is the receiving manager of a transfer,
+-- an active area manager of the region the asset is transferred to, like the reviewer of a submitted opname session.
+CREATE OR REPLACE FUNCTION public.is_asset_transfer_approver(_transfer_id INT, _user_id INT)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN EXISTS (
+			SELECT 1
+			FROM "AssetTransfer" AS t
+			INNER JOIN "Site" AS ts ON t.to_site_id = ts.id
+			INNER JOIN "SiteGroup" AS tsg ON ts.site_group_id = tsg.id
+			INNER JOIN "User" AS u ON u.user_id = _user_id
+			INNER JOIN "Site" AS us ON u.site_id = us.id
+			INNER JOIN "SiteGroup" AS usg ON us.site_group_id = usg.id
+			WHERE t.id = _transfer_id
+				AND LOWER(u.position) = 'area manager'
+				AND u.is_active
+				AND usg.region_id = tsg.region_id
+		);
+	END;
+$$;
+
+-- approve_asset_transfer approves a pending transfer and applies it to the asset master.
+-- The receiving owner is charged to the transfer's cost center when it differs from theirs.
+-- An open reassignment of the asset (see 0007_directory_sync) is resolved by the asset_owner_changed trigger.
+CREATE OR REPLACE PROCEDURE public.approve_asset_transfer(_transfer_id INT, _reviewer_id INT, _review_notes TEXT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_transfer "AssetTransfer"%ROWTYPE;
+	BEGIN
+		SELECT * INTO v_transfer FROM "AssetTransfer" AS t WHERE t.id = _transfer_id FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Asset transfer % not found', _transfer_id;
+		END IF;
+
+		IF v_transfer.status <> 'Pending' THEN
+			RAISE EXCEPTION 'Asset transfer % is not pending', _transfer_id;
+		END IF;
+
+		-- The asset must still be where the transfer was requested from, otherwise the request is out of date.
+		PERFORM 1
+		FROM "Asset" AS a
+		WHERE a.asset_tag = v_transfer.asset_tag
+			AND a.owner_id = v_transfer.from_owner_id
+			AND a.sub_site_id IS NOT DISTINCT FROM v_transfer.from_sub_site_id
+			AND a.dept_id IS NOT DISTINCT FROM v_transfer.from_dept_id
+			AND a.site_id = v_transfer.from_site_id
+			AND a.status <> 'Disposed'
+		FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Asset % has changed since transfer % was requested', v_transfer.asset_tag, _transfer_id;
+		END IF;
+
+		IF NOT EXISTS (SELECT 1 FROM "User" AS u WHERE u.user_id = v_transfer.to_owner_id AND u.is_active) THEN
+			RAISE EXCEPTION 'Transfer recipient % not found or deactivated', v_transfer.to_owner_id;
+		END IF;
+
+		UPDATE "Asset" AS a
+		SET
+			owner_id = v_transfer.to_owner_id,
+			sub_site_id = v_transfer.to_sub_site_id,
+			dept_id = v_transfer.to_dept_id,
+			site_id = v_transfer.to_site_id
+		WHERE a.asset_tag = v_transfer.asset_tag;
+
+		UPDATE "User" AS u
+		SET cost_center_id = v_transfer.to_cost_center_id
+		WHERE u.user_id = v_transfer.to_owner_id
+			AND u.user_id <> 1
+			AND u.cost_center_id IS DISTINCT FROM v_transfer.to_cost_center_id;
+
+		UPDATE "AssetTransfer" AS t
+		SET status = 'Approved', reviewer_id = _reviewer_id, reviewed_at = NOW(), review_notes = COALESCE(_review_notes, '')
+		WHERE t.id = _transfer_id;
+	END;
+$$;
+
+-- close_asset_transfer rejects or cancels a pending transfer without touching the asset.
+CREATE OR REPLACE PROCEDURE public.close_asset_transfer(_transfer_id INT, _status VARCHAR(20), _reviewer_id INT, _review_notes TEXT)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF _status NOT IN ('Rejected', 'Cancelled') THEN
+			RAISE EXCEPTION 'Asset transfer can only be closed as Rejected or Cancelled';
+		END IF;
+
+		UPDATE "AssetTransfer" AS t
+		SET status = _status, reviewer_id = _reviewer_id, reviewed_at = NOW(), review_notes = COALESCE(_review_notes, '')
+		WHERE t.id = _transfer_id AND t.status = 'Pending';
+
+		IF NOT FOUND THEN
+			IF EXISTS (SELECT 1 FROM "AssetTransfer" AS t WHERE t.id = _transfer_id) THEN
+				RAISE EXCEPTION 'Asset transfer % is not pending', _transfer_id;
+			END IF;
+			RAISE EXCEPTION 'Asset transfer % not found', _transfer_id;
+		END IF;
+	END;
+$$;
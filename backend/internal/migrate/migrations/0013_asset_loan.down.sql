@@ -0,0 +1,112 @@
+-- Removes the asset loans and restores the opname functions of 0002_baseline_functions.
+-- Assets still on loan keep the "On Loan" status.
+DROP FUNCTION IF EXISTS public.get_opname_stats(INT);
+
+-- get_opname_stats retrieves the statistic for an opname session
+CREATE OR REPLACE FUNCTION public.get_opname_stats(_session_id INT)
+	RETURNS TABLE (
+		working_assets INT,
+		broken_assets INT,
+		misplaced_assets INT,
+		missing_assets INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			COALESCE(SUM(CASE WHEN category = 'working_assets' THEN 1 ELSE 0 END), 0)::INT AS working_assets,
+			COALESCE(SUM(CASE WHEN category = 'broken_assets' THEN 1 ELSE 0 END), 0)::INT AS broken_assets,
+			COALESCE(SUM(CASE WHEN category = 'misplaced_assets' THEN 1 ELSE 0 END), 0)::INT AS misplaced_assets,
+			COALESCE(SUM(CASE WHEN category = 'missing_assets' THEN 1 ELSE 0 END), 0)::INT AS missing_assets
+		FROM public.categorize_opname_assets(_session_id);
+	END;
+$$;
+
+-- categorize_opname_assets categorizes assets based on their status and changes
+CREATE OR REPLACE FUNCTION public.categorize_opname_assets(_session_id INT)
+	RETURNS TABLE (
+		category VARCHAR(50),
+		asset_tag VARCHAR(12),
+		product_variety VARCHAR(50)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT * FROM (
+			-- Categorize assets that were scanned and processed during opname (use effective values from changes JSON)
+			SELECT
+				CASE
+					WHEN effective_status = 'Down' THEN 'broken_assets'::VARCHAR(50)
+					WHEN cost_center_changed AND effective_status <> 'Down' THEN 'misplaced_assets'::VARCHAR(50)
+					ELSE 'working_assets'::VARCHAR(50)
+				END AS category,
+				a.asset_tag,
+				a.product_variety
+			FROM "AssetChanges" AS ac
+			INNER JOIN "Asset" AS a ON ac.asset_tag = a.asset_tag
+			LEFT JOIN "User" AS ou ON a.owner_id = ou.user_id
+			LEFT JOIN LATERAL (
+				SELECT
+					COALESCE((ac."changes"->>'newStatus')::VARCHAR, a.status) AS effective_status,
+					COALESCE((ac."changes"->>'newOwnerCostCenter')::INT, ou.cost_center_id) AS effective_cost_center,
+					CASE 
+						WHEN (ac."changes" ? 'newOwnerCostCenter') AND ( (ac."changes"->>'newOwnerCostCenter')::INT IS DISTINCT FROM ou.cost_center_id) 
+						THEN TRUE ELSE FALSE END AS cost_center_changed
+			) eff ON TRUE
+			WHERE ac.session_id = _session_id
+			
+			UNION
+
+			-- Missing assets: assets not found during opname, denoted by 'Lost' condition or 2 in value.
+			SELECT
+				'missing_assets'::VARCHAR(50) AS category,
+				ac.asset_tag,
+				a.product_variety
+			FROM "AssetChanges" AS ac
+			LEFT JOIN "Asset" AS a ON ac.asset_tag = a.asset_tag
+			WHERE 
+				(a.condition = 2 OR ac.changes ->> 'newCondition' = '2')
+				AND
+				(a.status <> 'Down') -- Exclude 'Down' status to avoid double counting broken assets
+		)
+		ORDER BY category, asset_tag;
+	END;
+$$;
+
+-- get_unscanned_assets retrieves all assets on that location (site/dept) that hasn't been scanned/searched by the user
+CREATE OR REPLACE FUNCTION public.get_unscanned_assets(_session_id INT)
+	RETURNS TABLE (
+		asset_tag VARCHAR(12)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT a.asset_tag
+		FROM "Asset" AS a
+		INNER JOIN "OpnameSession" AS os ON os.id = _session_id
+		LEFT JOIN "SubSite" AS ss ON a.sub_site_id = ss.id
+		LEFT JOIN "User" AS u ON a.owner_id = u.user_id
+		LEFT JOIN "Department" AS d ON LOWER(u.department) = LOWER(d.dept_name)
+		WHERE 
+			-- For site-based opname: match by site (either through subsite or directly in parent site)
+			(os.site_id IS NOT NULL AND (ss.site_id = os.site_id OR (a.sub_site_id IS NULL AND a.site_id = os.site_id)))
+			OR
+			-- For department-based opname: match by department
+			(os.dept_id IS NOT NULL AND d.id = os.dept_id)
+		AND NOT EXISTS (
+			SELECT 1 FROM "AssetChanges" AS ac2 WHERE ac2.session_id = _session_id AND ac2.asset_tag = a.asset_tag
+		);
+	END;
+$$;
+
+DROP FUNCTION IF EXISTS public.get_expected_off_site_assets(INT);
+DROP PROCEDURE IF EXISTS public.mark_asset_loan_reminded(INT);
+DROP FUNCTION IF EXISTS public.is_asset_loan_manager(VARCHAR, INT);
+DROP FUNCTION IF EXISTS public.get_asset_loans(INT, VARCHAR, INT, INT, BOOLEAN, DATE, INT, INT);
+DROP PROCEDURE IF EXISTS public.check_in_asset(INT, INT, INT, TEXT, TEXT);
+DROP FUNCTION IF EXISTS public.check_out_asset(VARCHAR, INT, DATE, TEXT, INT);
+
+DROP TABLE IF EXISTS "AssetLoan";
@@ -0,0 +1,426 @@
+-- Asset loans (internal/loan): check-out of an asset to a borrower until a due date, and its check-in with a condition assessment.
+-- An open loan puts the asset "On Loan". Opname sessions expect such assets off-site: they are not required to be scanned
+-- and are reported as expected off-site rather than missing.
+
+CREATE TABLE "AssetLoan" (
+    "id" SERIAL PRIMARY KEY,
+    "asset_tag" VARCHAR(12) NOT NULL REFERENCES "Asset"("asset_tag"),
+    "purpose" TEXT NOT NULL,
+    "due_date" DATE NOT NULL,
+
+    -- The status the asset had before the loan, restored on check-in.
+    "previous_status" VARCHAR(20) NOT NULL CHECK ("previous_status" IN ('Deployed', 'In Inventory')),
+
+    -- Foreign key to User (the user who borrows the asset).
+    "borrower_id" INT NOT NULL REFERENCES "User"("user_id"),
+
+    -- Foreign key to User (the GA staff or L1 support who handed the asset out).
+    "checked_out_by" INT NOT NULL REFERENCES "User"("user_id"),
+    "checked_out_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Foreign key to User (the GA staff or L1 support who took the asset back). The loan is open while checked_in_at is NULL.
+    "checked_in_by" INT REFERENCES "User"("user_id") ON DELETE SET NULL,
+    "checked_in_at" TIMESTAMP WITH TIME ZONE,
+    "return_condition" INT CHECK ("return_condition" IN (0, 1)), -- 0: bad, 1: good, like "Asset".condition
+    "return_notes" TEXT NOT NULL DEFAULT '',
+    "return_photo_url" TEXT NOT NULL DEFAULT '',
+
+    -- When the borrower was last reminded of an overdue loan, so the daily reminder goes out once per day.
+    "last_reminded_at" TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT ck_loan_check_in CHECK (("checked_in_at" IS NULL) = ("return_condition" IS NULL)),
+    CONSTRAINT ck_loan_return_photo CHECK ("return_condition" IS DISTINCT FROM 0 OR "return_photo_url" <> '')
+);
+
+-- An asset has at most one open loan at a time.
+CREATE UNIQUE INDEX uq_asset_loan_open ON "AssetLoan" ("asset_tag") WHERE "checked_in_at" IS NULL;
+CREATE INDEX idx_asset_loan_due_date ON "AssetLoan" ("due_date") WHERE "checked_in_at" IS NULL;
+CREATE INDEX idx_asset_loan_checked_out_at ON "AssetLoan" ("checked_out_at" DESC);
+
+-- check_out_asset lends a deployed or in-inventory asset to an active borrower and returns the loan ID.
+-- The asset becomes "On Loan" until it is checked in.
+CREATE OR REPLACE FUNCTION public.check_out_asset(
+	_asset_tag VARCHAR(12),
+	_borrower_id INT,
+	_due_date DATE,
+	_purpose TEXT,
+	_checked_out_by INT
+)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_status VARCHAR(20);
+		v_id INT;
+	BEGIN
+		SELECT a.status INTO v_status
+		FROM "Asset" AS a
+		WHERE a.asset_tag = _asset_tag
+		FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Asset with tag % not found', _asset_tag;
+		END IF;
+
+		IF v_status NOT IN ('Deployed', 'In Inventory') THEN
+			RAISE EXCEPTION 'Asset % is % and cannot be loaned', _asset_tag, v_status;
+		END IF;
+
+		IF NOT EXISTS (SELECT 1 FROM "User" AS u WHERE u.user_id = _borrower_id AND u.is_active) THEN
+			RAISE EXCEPTION 'Loan borrower % not found or deactivated', _borrower_id;
+		END IF;
+
+		INSERT INTO "AssetLoan" (asset_tag, purpose, due_date, previous_status, borrower_id, checked_out_by)
+		VALUES (_asset_tag, _purpose, _due_date, v_status, _borrower_id, _checked_out_by)
+		RETURNING id INTO v_id;
+
+		UPDATE "Asset" AS a
+		SET status = 'On Loan'
+		WHERE a.asset_tag = _asset_tag;
+
+		RETURN v_id;
+	END;
+$$;
+
+-- check_in_asset closes an open loan. The asset gets its pre-loan status back, unless it was changed meanwhile,
+-- and the assessed condition. A bad condition needs a photo, like "Asset".condition_photo_url.
+CREATE OR REPLACE PROCEDURE public.check_in_asset(
+	_loan_id INT,
+	_checked_in_by INT,
+	_return_condition INT,
+	_return_notes TEXT,
+	_return_photo_url TEXT
+)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_loan "AssetLoan"%ROWTYPE;
+	BEGIN
+		IF _return_condition IS NULL OR _return_condition NOT IN (0, 1) THEN
+			RAISE EXCEPTION 'Return condition must be 0 (bad) or 1 (good)';
+		END IF;
+
+		IF _return_condition = 0 AND COALESCE(_return_photo_url, '') = '' THEN
+			RAISE EXCEPTION 'A photo is required when an asset is returned in bad condition';
+		END IF;
+
+		SELECT * INTO v_loan FROM "AssetLoan" AS l WHERE l.id = _loan_id FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Asset loan % not found', _loan_id;
+		END IF;
+
+		IF v_loan.checked_in_at IS NOT NULL THEN
+			RAISE EXCEPTION 'Asset loan % is already checked in', _loan_id;
+		END IF;
+
+		UPDATE "Asset" AS a
+		SET
+			status = CASE WHEN a.status = 'On Loan' THEN v_loan.previous_status ELSE a.status END,
+			condition = _return_condition,
+			condition_notes = COALESCE(_return_notes, ''),
+			condition_photo_url = COALESCE(_return_photo_url, '')
+		WHERE a.asset_tag = v_loan.asset_tag;
+
+		UPDATE "AssetLoan" AS l
+		SET
+			checked_in_by = _checked_in_by,
+			checked_in_at = NOW(),
+			return_condition = _return_condition,
+			return_notes = COALESCE(_return_notes, ''),
+			return_photo_url = COALESCE(_return_photo_url, '')
+		WHERE l.id = _loan_id;
+	END;
+$$;
+
+-- get_asset_loans retrieves loans with the names needed to display them, newest first.
+-- Every filter is optional. _open keeps open (TRUE) or returned (FALSE) loans, _overdue_on keeps the loans still open
+-- on that day and due before it, most overdue first. _site_id matches the site the asset is on.
+-- Pagination is done here, total_count is the number of loans matching the filters.
+CREATE OR REPLACE FUNCTION public.get_asset_loans(
+	_loan_id INT,
+	_asset_tag VARCHAR(12),
+	_borrower_id INT,
+	_site_id INT,
+	_open BOOLEAN,
+	_overdue_on DATE,
+	_limit INT,
+	_page_number INT
+)
+	RETURNS TABLE (
+		id INT,
+		asset_tag VARCHAR(12),
+		serial_number VARCHAR(25),
+		brand_name VARCHAR(25),
+		product_name VARCHAR(50),
+		product_variety VARCHAR(50),
+		site_id INT,
+		site_name VARCHAR(100),
+		purpose TEXT,
+		due_date DATE,
+		previous_status VARCHAR(20),
+		borrower_id INT,
+		borrower_name VARCHAR(510),
+		borrower_email VARCHAR(255),
+		checked_out_by INT,
+		checked_out_by_name VARCHAR(510),
+		checked_out_by_email VARCHAR(255),
+		checked_out_at TIMESTAMP WITH TIME ZONE,
+		checked_in_by INT,
+		checked_in_by_name VARCHAR(510),
+		checked_in_at TIMESTAMP WITH TIME ZONE,
+		return_condition INT,
+		return_notes TEXT,
+		return_photo_url TEXT,
+		last_reminded_at TIMESTAMP WITH TIME ZONE,
+		total_count BIGINT
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_offset INT := GREATEST(COALESCE(_page_number,1)-1,0) * COALESCE(NULLIF(_limit,0),50);
+	BEGIN
+		RETURN QUERY
+		SELECT
+			l.id,
+			l.asset_tag,
+			a.serial_number,
+			a.brand_name,
+			a.product_name,
+			a.product_variety,
+			a.site_id,
+			s.site_name,
+			l.purpose,
+			l.due_date,
+			l.previous_status,
+			l.borrower_id,
+			(COALESCE(bu.first_name, '') || ' ' || COALESCE(bu.last_name, ''))::VARCHAR(510) AS borrower_name,
+			bu.email AS borrower_email,
+			l.checked_out_by,
+			(COALESCE(ou.first_name, '') || ' ' || COALESCE(ou.last_name, ''))::VARCHAR(510) AS checked_out_by_name,
+			ou.email AS checked_out_by_email,
+			l.checked_out_at,
+			l.checked_in_by,
+			(CASE WHEN iu.user_id IS NULL THEN NULL ELSE COALESCE(iu.first_name, '') || ' ' || COALESCE(iu.last_name, '') END)::VARCHAR(510) AS checked_in_by_name,
+			l.checked_in_at,
+			l.return_condition,
+			l.return_notes,
+			l.return_photo_url,
+			l.last_reminded_at,
+			COUNT(*) OVER()::BIGINT AS total_count
+		FROM "AssetLoan" AS l
+		INNER JOIN "Asset" AS a ON l.asset_tag = a.asset_tag
+		INNER JOIN "Site" AS s ON a.site_id = s.id
+		INNER JOIN "User" AS bu ON l.borrower_id = bu.user_id
+		INNER JOIN "User" AS ou ON l.checked_out_by = ou.user_id
+		LEFT JOIN "User" AS iu ON l.checked_in_by = iu.user_id
+		WHERE
+			(_loan_id IS NULL OR l.id = _loan_id)
+			AND (_asset_tag IS NULL OR _asset_tag = '' OR l.asset_tag = _asset_tag)
+			AND (_borrower_id IS NULL OR l.borrower_id = _borrower_id)
+			AND (_site_id IS NULL OR a.site_id = _site_id)
+			AND (_open IS NULL OR (l.checked_in_at IS NULL) = _open)
+			AND (_overdue_on IS NULL OR (l.checked_in_at IS NULL AND l.due_date < _overdue_on))
+		ORDER BY
+			CASE WHEN _overdue_on IS NOT NULL THEN l.due_date END,
+			l.checked_out_at DESC,
+			l.id DESC
+		LIMIT COALESCE(NULLIF(_limit,0), 50) OFFSET v_offset;
+	END;
+$$;
+
+-- is_asset_loan_manager checks whether a user may lend an asset out and take it back: the GA staff of the site it is on.
+CREATE OR REPLACE FUNCTION public.is_asset_loan_manager(_asset_tag VARCHAR(12), _user_id INT)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN EXISTS (
+			SELECT 1
+			FROM "Asset" AS a
+			INNER JOIN "Site" AS s ON a.site_id = s.id
+			WHERE a.asset_tag = _asset_tag AND s.site_ga_id = _user_id
+		);
+	END;
+$$;
+
+-- mark_asset_loan_reminded records that the borrower of an overdue loan has been reminded.
+CREATE OR REPLACE PROCEDURE public.mark_asset_loan_reminded(_loan_id INT)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "AssetLoan" AS l
+		SET last_reminded_at = NOW()
+		WHERE l.id = _loan_id;
+	END;
+$$;
+
+-- get_expected_off_site_assets retrieves the assets at the location of an opname session that were on loan
+-- when the session ended, or are on loan now while it is still running.
+CREATE OR REPLACE FUNCTION public.get_expected_off_site_assets(_session_id INT)
+	RETURNS TABLE (
+		asset_tag VARCHAR(12),
+		product_variety VARCHAR(50),
+		loan_id INT,
+		borrower_id INT,
+		borrower_name VARCHAR(510),
+		due_date DATE
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			a.asset_tag,
+			a.product_variety,
+			l.id AS loan_id,
+			l.borrower_id,
+			(COALESCE(bu.first_name, '') || ' ' || COALESCE(bu.last_name, ''))::VARCHAR(510) AS borrower_name,
+			l.due_date
+		FROM "Asset" AS a
+		INNER JOIN "OpnameSession" AS os ON os.id = _session_id
+		INNER JOIN "AssetLoan" AS l
+			ON l.asset_tag = a.asset_tag
+			AND l.checked_out_at <= COALESCE(os.end_date, NOW())
+			AND (l.checked_in_at IS NULL OR l.checked_in_at > COALESCE(os.end_date, NOW()))
+		INNER JOIN "User" AS bu ON l.borrower_id = bu.user_id
+		LEFT JOIN "SubSite" AS ss ON a.sub_site_id = ss.id
+		LEFT JOIN "User" AS u ON a.owner_id = u.user_id
+		LEFT JOIN "Department" AS d ON LOWER(u.department) = LOWER(d.dept_name)
+		WHERE
+			(os.site_id IS NOT NULL AND (ss.site_id = os.site_id OR (a.sub_site_id IS NULL AND a.site_id = os.site_id)))
+			OR
+			(os.dept_id IS NOT NULL AND d.id = os.dept_id)
+		ORDER BY l.due_date, a.asset_tag;
+	END;
+$$;
+
+-- get_unscanned_assets no longer requires assets that are expected off-site to be scanned.
+-- The scanned check now also applies to site-based sessions: it used to bind to the department condition only.
+CREATE OR REPLACE FUNCTION public.get_unscanned_assets(_session_id INT)
+	RETURNS TABLE (
+		asset_tag VARCHAR(12)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT a.asset_tag
+		FROM "Asset" AS a
+		INNER JOIN "OpnameSession" AS os ON os.id = _session_id
+		LEFT JOIN "SubSite" AS ss ON a.sub_site_id = ss.id
+		LEFT JOIN "User" AS u ON a.owner_id = u.user_id
+		LEFT JOIN "Department" AS d ON LOWER(u.department) = LOWER(d.dept_name)
+		WHERE
+			(
+				-- For site-based opname: match by site (either through subsite or directly in parent site)
+				(os.site_id IS NOT NULL AND (ss.site_id = os.site_id OR (a.sub_site_id IS NULL AND a.site_id = os.site_id)))
+				OR
+				-- For department-based opname: match by department
+				(os.dept_id IS NOT NULL AND d.id = os.dept_id)
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM "AssetChanges" AS ac2 WHERE ac2.session_id = _session_id AND ac2.asset_tag = a.asset_tag
+			)
+			AND a.asset_tag NOT IN (
+				SELECT eo.asset_tag FROM public.get_expected_off_site_assets(_session_id) AS eo
+			);
+	END;
+$$;
+
+-- categorize_opname_assets adds the expected_off_site category: assets on loan that were not scanned, or were recorded as lost.
+-- Missing assets are now limited to the session's own changes and are no longer listed as working as well.
+CREATE OR REPLACE FUNCTION public.categorize_opname_assets(_session_id INT)
+	RETURNS TABLE (
+		category VARCHAR(50),
+		asset_tag VARCHAR(12),
+		product_variety VARCHAR(50)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		WITH off_site AS (
+			SELECT eo.asset_tag, eo.product_variety FROM public.get_expected_off_site_assets(_session_id) AS eo
+		)
+		SELECT * FROM (
+			-- Categorize assets that were scanned and processed during opname (use effective values from changes JSON)
+			SELECT
+				CASE
+					WHEN effective_status = 'Down' THEN 'broken_assets'::VARCHAR(50)
+					WHEN cost_center_changed AND effective_status <> 'Down' THEN 'misplaced_assets'::VARCHAR(50)
+					ELSE 'working_assets'::VARCHAR(50)
+				END AS category,
+				a.asset_tag,
+				a.product_variety
+			FROM "AssetChanges" AS ac
+			INNER JOIN "Asset" AS a ON ac.asset_tag = a.asset_tag
+			LEFT JOIN "User" AS ou ON a.owner_id = ou.user_id
+			LEFT JOIN LATERAL (
+				SELECT
+					COALESCE((ac."changes"->>'newStatus')::VARCHAR, a.status) AS effective_status,
+					COALESCE((ac."changes"->>'newOwnerCostCenter')::INT, ou.cost_center_id) AS effective_cost_center,
+					CASE
+						WHEN (ac."changes" ? 'newOwnerCostCenter') AND ( (ac."changes"->>'newOwnerCostCenter')::INT IS DISTINCT FROM ou.cost_center_id)
+						THEN TRUE ELSE FALSE END AS cost_center_changed
+			) eff ON TRUE
+			WHERE ac.session_id = _session_id
+				AND NOT ((a.condition = 2 OR ac.changes ->> 'newCondition' = '2') AND a.status <> 'Down')
+
+			UNION
+
+			-- Missing assets: assets not found during opname, denoted by 'Lost' condition or 2 in value.
+			-- Assets on loan are expected not to be found and are listed below instead.
+			SELECT
+				'missing_assets'::VARCHAR(50) AS category,
+				ac.asset_tag,
+				a.product_variety
+			FROM "AssetChanges" AS ac
+			LEFT JOIN "Asset" AS a ON ac.asset_tag = a.asset_tag
+			WHERE
+				ac.session_id = _session_id
+				AND
+				(a.condition = 2 OR ac.changes ->> 'newCondition' = '2')
+				AND
+				(a.status <> 'Down') -- Exclude 'Down' status to avoid double counting broken assets
+				AND
+				NOT EXISTS (SELECT 1 FROM off_site AS os WHERE os.asset_tag = ac.asset_tag)
+
+			UNION
+
+			-- Expected off-site assets: on loan and either not scanned, or recorded as lost.
+			SELECT
+				'expected_off_site'::VARCHAR(50) AS category,
+				os.asset_tag,
+				os.product_variety
+			FROM off_site AS os
+			LEFT JOIN "AssetChanges" AS ac ON ac.session_id = _session_id AND ac.asset_tag = os.asset_tag
+			WHERE ac.asset_tag IS NULL OR ac.changes ->> 'newCondition' = '2'
+		)
+		ORDER BY category, asset_tag;
+	END;
+$$;
+
+-- get_opname_stats adds the number of assets expected off-site.
+DROP FUNCTION IF EXISTS public.get_opname_stats(INT);
+CREATE OR REPLACE FUNCTION public.get_opname_stats(_session_id INT)
+	RETURNS TABLE (
+		working_assets INT,
+		broken_assets INT,
+		misplaced_assets INT,
+		missing_assets INT,
+		expected_off_site INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			COALESCE(SUM(CASE WHEN category = 'working_assets' THEN 1 ELSE 0 END), 0)::INT AS working_assets,
+			COALESCE(SUM(CASE WHEN category = 'broken_assets' THEN 1 ELSE 0 END), 0)::INT AS broken_assets,
+			COALESCE(SUM(CASE WHEN category = 'misplaced_assets' THEN 1 ELSE 0 END), 0)::INT AS misplaced_assets,
+			COALESCE(SUM(CASE WHEN category = 'missing_assets' THEN 1 ELSE 0 END), 0)::INT AS missing_assets,
+			COALESCE(SUM(CASE WHEN category = 'expected_off_site' THEN 1 ELSE 0 END), 0)::INT AS expected_off_site
+		FROM public.categorize_opname_assets(_session_id);
+	END;
+$$;
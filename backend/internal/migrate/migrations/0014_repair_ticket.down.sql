@@ -0,0 +1,157 @@
+-- Removes the repair tickets and restores categorize_opname_assets of 0013_asset_loan and get_opname_bap_details of 0002_baseline_functions.
+-- Assets still "In Repair" keep that status.
+DROP TRIGGER IF EXISTS opname_session_verified ON "OpnameSession";
+
+-- categorize_opname_assets adds the expected_off_site category: assets on loan that were not scanned, or were recorded as lost.
+-- Missing assets are now limited to the session's own changes and are no longer listed as working as well.
+CREATE OR REPLACE FUNCTION public.categorize_opname_assets(_session_id INT)
+	RETURNS TABLE (
+		category VARCHAR(50),
+		asset_tag VARCHAR(12),
+		product_variety VARCHAR(50)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		WITH off_site AS (
+			SELECT eo.asset_tag, eo.product_variety FROM public.get_expected_off_site_assets(_session_id) AS eo
+		)
+		SELECT * FROM (
+			-- Categorize assets that were scanned and processed during opname (use effective values from changes JSON)
+			SELECT
+				CASE
+					WHEN effective_status = 'Down' THEN 'broken_assets'::VARCHAR(50)
+					WHEN cost_center_changed AND effective_status <> 'Down' THEN 'misplaced_assets'::VARCHAR(50)
+					ELSE 'working_assets'::VARCHAR(50)
+				END AS category,
+				a.asset_tag,
+				a.product_variety
+			FROM "AssetChanges" AS ac
+			INNER JOIN "Asset" AS a ON ac.asset_tag = a.asset_tag
+			LEFT JOIN "User" AS ou ON a.owner_id = ou.user_id
+			LEFT JOIN LATERAL (
+				SELECT
+					COALESCE((ac."changes"->>'newStatus')::VARCHAR, a.status) AS effective_status,
+					COALESCE((ac."changes"->>'newOwnerCostCenter')::INT, ou.cost_center_id) AS effective_cost_center,
+					CASE
+						WHEN (ac."changes" ? 'newOwnerCostCenter') AND ( (ac."changes"->>'newOwnerCostCenter')::INT IS DISTINCT FROM ou.cost_center_id)
+						THEN TRUE ELSE FALSE END AS cost_center_changed
+			) eff ON TRUE
+			WHERE ac.session_id = _session_id
+				AND NOT ((a.condition = 2 OR ac.changes ->> 'newCondition' = '2') AND a.status <> 'Down')
+
+			UNION
+
+			-- Missing assets: assets not found during opname, denoted by 'Lost' condition or 2 in value.
+			-- Assets on loan are expected not to be found and are listed below instead.
+			SELECT
+				'missing_assets'::VARCHAR(50) AS category,
+				ac.asset_tag,
+				a.product_variety
+			FROM "AssetChanges" AS ac
+			LEFT JOIN "Asset" AS a ON ac.asset_tag = a.asset_tag
+			WHERE
+				ac.session_id = _session_id
+				AND
+				(a.condition = 2 OR ac.changes ->> 'newCondition' = '2')
+				AND
+				(a.status <> 'Down') -- Exclude 'Down' status to avoid double counting broken assets
+				AND
+				NOT EXISTS (SELECT 1 FROM off_site AS os WHERE os.asset_tag = ac.asset_tag)
+
+			UNION
+
+			-- Expected off-site assets: on loan and either not scanned, or recorded as lost.
+			SELECT
+				'expected_off_site'::VARCHAR(50) AS category,
+				os.asset_tag,
+				os.product_variety
+			FROM off_site AS os
+			LEFT JOIN "AssetChanges" AS ac ON ac.session_id = _session_id AND ac.asset_tag = os.asset_tag
+			WHERE ac.asset_tag IS NULL OR ac.changes ->> 'newCondition' = '2'
+		)
+		ORDER BY category, asset_tag;
+	END;
+$$;
+
+-- get_opname_bap_details retrieves the detailed BAP report per category
+CREATE OR REPLACE FUNCTION public.get_opname_bap_details(_session_id INT)
+	RETURNS TABLE (
+		category VARCHAR(50),
+		company VARCHAR(50),
+		asset_tag VARCHAR(12),
+		asset_name VARCHAR(50),
+		equipments TEXT,
+		user_name_and_position TEXT,
+		asset_status VARCHAR(20),
+		action_notes TEXT,
+		cost_center_id INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		-- NOTE:
+		-- 1. Some assets may have NULL owner_id -> use LEFT JOIN to avoid losing rows.
+		-- 2. Apply latest (effective) values from AssetChanges JSON when present.
+		-- 3. Use concat_ws to safely build user_name_and_position.
+		-- 4. Use LEFT JOIN LATERAL for equipments helper and effective field derivation.
+		-- 5. Deterministic category ordering via CASE.
+		RETURN QUERY
+		SELECT
+			ca.category,
+			'Surya Madistrindo'::VARCHAR(50) AS company,
+			a.asset_tag,
+			a.product_name AS asset_name,
+			eff.effective_equipments AS equipments,
+			CASE 
+				WHEN u.user_id IS NULL THEN 'N/A'
+				WHEN LOWER(u.username) = 'vacant' THEN 'VACANT'
+				ELSE UPPER(concat_ws(' - ', eff.effective_owner_position, trim(both ' ' FROM concat_ws(' ', u.first_name, u.last_name))))
+			END AS user_name_and_position,
+				eff.effective_status::VARCHAR(20) AS asset_status,
+			NULLIF(COALESCE(ac.action_notes, ''), '') AS action_notes,
+			CASE 
+				WHEN eff.effective_owner_cost_center IS NULL THEN NULL
+				WHEN eff.effective_owner_cost_center = 0 THEN NULL -- normalize 0 to NULL (VACANT or unset)
+				ELSE eff.effective_owner_cost_center
+			END AS cost_center_id
+		FROM public.categorize_opname_assets(_session_id) AS ca
+		INNER JOIN "Asset" AS a ON ca.asset_tag = a.asset_tag
+		LEFT JOIN "AssetChanges" AS ac 
+			ON a.asset_tag = ac.asset_tag 
+			AND ac.session_id = _session_id
+		LEFT JOIN "User" AS ou ON a.owner_id = ou.user_id -- original owner
+		LEFT JOIN LATERAL (
+			SELECT
+				COALESCE((ac."changes"->>'newOwnerID')::INT, a.owner_id) AS effective_owner_id,
+				COALESCE(ac."changes"->>'newOwnerPosition', ou.position) AS effective_owner_position,
+				COALESCE(ac."changes"->>'newOwnerDepartment', ou.department) AS effective_owner_department,
+				COALESCE(ac."changes"->>'newOwnerDivision', ou.division) AS effective_owner_division,
+				COALESCE((ac."changes"->>'newOwnerCostCenter')::INT, ou.cost_center_id) AS effective_owner_cost_center,
+				COALESCE(ac."changes"->>'newEquipments', a.equipments) AS effective_equipments,
+				COALESCE(ac."changes"->>'newStatus', a.status) AS effective_status
+		) eff ON TRUE
+		LEFT JOIN "User" AS u ON u.user_id = eff.effective_owner_id
+		ORDER BY 
+			CASE ca.category
+				WHEN 'working_assets' THEN 1
+				WHEN 'broken_assets' THEN 2
+				WHEN 'misplaced_assets' THEN 3
+				WHEN 'missing_assets' THEN 4
+				ELSE 99
+			END,
+			a.asset_tag;
+	END;
+$$;
+DROP FUNCTION IF EXISTS public.is_repair_ticket_manager(INT, INT);
+DROP PROCEDURE IF EXISTS public.resolve_repair_ticket(INT, VARCHAR(20), BIGINT, TEXT, INT);
+DROP FUNCTION IF EXISTS public.add_repair_ticket_photo(INT, TEXT, TEXT, INT);
+DROP PROCEDURE IF EXISTS public.update_repair_ticket(INT, VARCHAR(100), BIGINT, DATE, DATE, INT);
+DROP FUNCTION IF EXISTS public.get_repair_ticket_photos(INT);
+DROP FUNCTION IF EXISTS public.get_repair_tickets(INT, VARCHAR(12), INT, INT, VARCHAR(20), INT, INT);
+DROP FUNCTION IF EXISTS public.open_repair_tickets_on_verify();
+DROP FUNCTION IF EXISTS public.open_repair_tickets(INT);
+
+DROP TABLE IF EXISTS "RepairTicketPhoto";
+DROP TABLE IF EXISTS "RepairTicket";
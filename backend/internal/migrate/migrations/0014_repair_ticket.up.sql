@@ -0,0 +1,553 @@
+-- Repair tickets (internal/repair): follow a broken asset through its repair. A ticket opens automatically for every broken
+-- asset of an opname session once the session is verified, and puts the asset "In Repair". It tracks the vendor, cost and dates,
+-- and is resolved as repaired (the asset gets its status back) or beyond repair (the asset goes "Down", to be disposed of).
+-- The ticket status shows up in the action notes of the next BAP the asset is in.
+
+CREATE TABLE "RepairTicket" (
+    "id" SERIAL PRIMARY KEY,
+    "asset_tag" VARCHAR(12) NOT NULL REFERENCES "Asset"("asset_tag"),
+
+    -- Foreign key to OpnameSession (the verified session the asset was found broken in).
+    "session_id" INT REFERENCES "OpnameSession"("id") ON DELETE SET NULL,
+
+    "status" VARCHAR(20) NOT NULL DEFAULT 'In Repair' CHECK ("status" IN ('In Repair', 'Repaired', 'Beyond Repair')),
+    "problem" TEXT NOT NULL,
+
+    -- The status the asset gets back once repaired.
+    "previous_status" VARCHAR(20) NOT NULL CHECK ("previous_status" IN ('Deployed', 'In Inventory')),
+
+    -- Costs are in rupiah, like "Asset".total_cost.
+    "vendor" VARCHAR(100) NOT NULL DEFAULT '',
+    "cost_estimate" BIGINT CHECK ("cost_estimate" >= 0),
+    "repair_cost" BIGINT CHECK ("repair_cost" >= 0),
+    "sent_date" DATE, -- When the asset was handed to the vendor
+    "expected_return_date" DATE,
+    "opened_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Foreign key to User (the GA staff or L1 support who last updated the repair details).
+    "updated_by" INT REFERENCES "User"("user_id") ON DELETE SET NULL,
+    "updated_at" TIMESTAMP WITH TIME ZONE,
+
+    -- Foreign key to User (the GA staff or L1 support who resolved the ticket). The ticket is open while resolved_at is NULL.
+    "resolved_by" INT REFERENCES "User"("user_id") ON DELETE SET NULL,
+    "resolved_at" TIMESTAMP WITH TIME ZONE,
+    "resolution_notes" TEXT NOT NULL DEFAULT '',
+
+    CONSTRAINT ck_repair_ticket_resolved CHECK (("status" = 'In Repair') = ("resolved_at" IS NULL))
+);
+
+-- An asset has at most one open repair ticket at a time.
+CREATE UNIQUE INDEX uq_repair_ticket_open ON "RepairTicket" ("asset_tag") WHERE "status" = 'In Repair';
+CREATE INDEX idx_repair_ticket_session ON "RepairTicket" ("session_id");
+CREATE INDEX idx_repair_ticket_opened_at ON "RepairTicket" ("opened_at" DESC);
+
+CREATE TABLE "RepairTicketPhoto" (
+    "id" SERIAL PRIMARY KEY,
+    "ticket_id" INT NOT NULL REFERENCES "RepairTicket"("id") ON DELETE CASCADE,
+    "photo_url" TEXT NOT NULL,
+    "caption" TEXT NOT NULL DEFAULT '',
+
+    -- Foreign key to User (the user who added the photo). NULL for the condition photo taken during opname.
+    "uploaded_by" INT REFERENCES "User"("user_id") ON DELETE SET NULL,
+    "uploaded_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_repair_ticket_photo_ticket ON "RepairTicketPhoto" ("ticket_id");
+
+-- open_repair_tickets opens a ticket for every broken asset of a session that has none open yet, and returns how many were opened.
+-- The problem is the change reason recorded during opname and the condition photo becomes the first photo of the ticket.
+CREATE OR REPLACE FUNCTION public.open_repair_tickets(_session_id INT)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_asset RECORD;
+		v_ticket_id INT;
+		v_opened INT := 0;
+	BEGIN
+		FOR v_asset IN
+			SELECT
+				a.asset_tag,
+				a.status,
+				COALESCE(NULLIF(TRIM(ac.change_reason), ''), 'Found broken during opname') AS problem,
+				COALESCE(NULLIF(ac."changes"->>'newConditionPhotoURL', ''), NULLIF(a.condition_photo_url, '')) AS photo_url
+			FROM public.categorize_opname_assets(_session_id) AS ca
+			INNER JOIN "Asset" AS a ON ca.asset_tag = a.asset_tag
+			LEFT JOIN "AssetChanges" AS ac ON ac.asset_tag = a.asset_tag AND ac.session_id = _session_id
+			WHERE ca.category = 'broken_assets'
+				AND a.status <> 'Disposed'
+				AND NOT EXISTS (
+					SELECT 1 FROM "RepairTicket" AS rt
+					WHERE rt.asset_tag = a.asset_tag AND rt.status = 'In Repair'
+				)
+			FOR UPDATE OF a
+		LOOP
+			INSERT INTO "RepairTicket" (asset_tag, session_id, problem, previous_status)
+			VALUES (
+				v_asset.asset_tag,
+				_session_id,
+				v_asset.problem,
+				CASE WHEN v_asset.status IN ('Deployed', 'In Inventory') THEN v_asset.status ELSE 'In Inventory' END
+			)
+			RETURNING id INTO v_ticket_id;
+
+			IF v_asset.photo_url IS NOT NULL THEN
+				INSERT INTO "RepairTicketPhoto" (ticket_id, photo_url, caption)
+				VALUES (v_ticket_id, v_asset.photo_url, 'Opname condition photo');
+			END IF;
+
+			UPDATE "Asset" AS a
+			SET status = 'In Repair'
+			WHERE a.asset_tag = v_asset.asset_tag;
+
+			v_opened := v_opened + 1;
+		END LOOP;
+
+		RETURN v_opened;
+	END;
+$$;
+
+-- open_repair_tickets_on_verify opens the repair tickets of a session when it is verified.
+CREATE OR REPLACE FUNCTION public.open_repair_tickets_on_verify()
+	RETURNS TRIGGER
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		PERFORM public.open_repair_tickets(NEW.id);
+		RETURN NEW;
+	END;
+$$;
+
+CREATE TRIGGER opname_session_verified
+	AFTER UPDATE OF status ON "OpnameSession"
+	FOR EACH ROW
+	WHEN (NEW.status = 'Verified' AND OLD.status IS DISTINCT FROM 'Verified')
+	EXECUTE FUNCTION open_repair_tickets_on_verify();
+
+-- get_repair_tickets retrieves repair tickets with the names needed to display them, open ones first, then newest first.
+-- Every filter is optional. _site_id matches the site the asset is on.
+-- Pagination is done here, total_count is the number of tickets matching the filters.
+CREATE OR REPLACE FUNCTION public.get_repair_tickets(
+	_ticket_id INT,
+	_asset_tag VARCHAR(12),
+	_session_id INT,
+	_site_id INT,
+	_status VARCHAR(20),
+	_limit INT,
+	_page_number INT
+)
+	RETURNS TABLE (
+		id INT,
+		asset_tag VARCHAR(12),
+		serial_number VARCHAR(25),
+		brand_name VARCHAR(25),
+		product_name VARCHAR(50),
+		product_variety VARCHAR(50),
+		site_id INT,
+		site_name VARCHAR(100),
+		asset_status VARCHAR(20),
+		session_id INT,
+		status VARCHAR(20),
+		problem TEXT,
+		previous_status VARCHAR(20),
+		vendor VARCHAR(100),
+		cost_estimate BIGINT,
+		repair_cost BIGINT,
+		sent_date DATE,
+		expected_return_date DATE,
+		opened_at TIMESTAMP WITH TIME ZONE,
+		updated_by INT,
+		updated_at TIMESTAMP WITH TIME ZONE,
+		resolved_by INT,
+		resolved_by_name VARCHAR(510),
+		resolved_at TIMESTAMP WITH TIME ZONE,
+		resolution_notes TEXT,
+		total_count BIGINT
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_offset INT := GREATEST(COALESCE(_page_number,1)-1,0) * COALESCE(NULLIF(_limit,0),50);
+	BEGIN
+		RETURN QUERY
+		SELECT
+			rt.id,
+			rt.asset_tag,
+			a.serial_number,
+			a.brand_name,
+			a.product_name,
+			a.product_variety,
+			a.site_id,
+			s.site_name,
+			a.status AS asset_status,
+			rt.session_id,
+			rt.status,
+			rt.problem,
+			rt.previous_status,
+			rt.vendor,
+			rt.cost_estimate,
+			rt.repair_cost,
+			rt.sent_date,
+			rt.expected_return_date,
+			rt.opened_at,
+			rt.updated_by,
+			rt.updated_at,
+			rt.resolved_by,
+			(CASE WHEN ru.user_id IS NULL THEN NULL ELSE COALESCE(ru.first_name, '') || ' ' || COALESCE(ru.last_name, '') END)::VARCHAR(510) AS resolved_by_name,
+			rt.resolved_at,
+			rt.resolution_notes,
+			COUNT(*) OVER()::BIGINT AS total_count
+		FROM "RepairTicket" AS rt
+		INNER JOIN "Asset" AS a ON rt.asset_tag = a.asset_tag
+		INNER JOIN "Site" AS s ON a.site_id = s.id
+		LEFT JOIN "User" AS ru ON rt.resolved_by = ru.user_id
+		WHERE
+			(_ticket_id IS NULL OR rt.id = _ticket_id)
+			AND (_asset_tag IS NULL OR _asset_tag = '' OR rt.asset_tag = _asset_tag)
+			AND (_session_id IS NULL OR rt.session_id = _session_id)
+			AND (_site_id IS NULL OR a.site_id = _site_id)
+			AND (_status IS NULL OR _status = '' OR rt.status = _status)
+		ORDER BY
+			(rt.status = 'In Repair') DESC,
+			rt.opened_at DESC,
+			rt.id DESC
+		LIMIT COALESCE(NULLIF(_limit,0), 50) OFFSET v_offset;
+	END;
+$$;
+
+-- get_repair_ticket_photos retrieves the photos of a repair ticket, oldest first.
+CREATE OR REPLACE FUNCTION public.get_repair_ticket_photos(_ticket_id INT)
+	RETURNS TABLE (
+		id INT,
+		photo_url TEXT,
+		caption TEXT,
+		uploaded_by INT,
+		uploaded_by_name VARCHAR(510),
+		uploaded_at TIMESTAMP WITH TIME ZONE
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			p.id,
+			p.photo_url,
+			p.caption,
+			p.uploaded_by,
+			(CASE WHEN u.user_id IS NULL THEN NULL ELSE COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '') END)::VARCHAR(510) AS uploaded_by_name,
+			p.uploaded_at
+		FROM "RepairTicketPhoto" AS p
+		LEFT JOIN "User" AS u ON p.uploaded_by = u.user_id
+		WHERE p.ticket_id = _ticket_id
+		ORDER BY p.uploaded_at, p.id;
+	END;
+$$;
+
+-- update_repair_ticket sets the repair details of an open ticket.
+CREATE OR REPLACE PROCEDURE public.update_repair_ticket(
+	_ticket_id INT,
+	_vendor VARCHAR(100),
+	_cost_estimate BIGINT,
+	_sent_date DATE,
+	_expected_return_date DATE,
+	_updated_by INT
+)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_status VARCHAR(20);
+	BEGIN
+		SELECT rt.status INTO v_status FROM "RepairTicket" AS rt WHERE rt.id = _ticket_id FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Repair ticket % not found', _ticket_id;
+		END IF;
+
+		IF v_status <> 'In Repair' THEN
+			RAISE EXCEPTION 'Repair ticket % is already resolved', _ticket_id;
+		END IF;
+
+		UPDATE "RepairTicket" AS rt
+		SET
+			vendor = COALESCE(_vendor, ''),
+			cost_estimate = _cost_estimate,
+			sent_date = _sent_date,
+			expected_return_date = _expected_return_date,
+			updated_by = _updated_by,
+			updated_at = NOW()
+		WHERE rt.id = _ticket_id;
+	END;
+$$;
+
+-- add_repair_ticket_photo attaches a photo to a repair ticket and returns its ID.
+CREATE OR REPLACE FUNCTION public.add_repair_ticket_photo(_ticket_id INT, _photo_url TEXT, _caption TEXT, _uploaded_by INT)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_id INT;
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM "RepairTicket" AS rt WHERE rt.id = _ticket_id) THEN
+			RAISE EXCEPTION 'Repair ticket % not found', _ticket_id;
+		END IF;
+
+		INSERT INTO "RepairTicketPhoto" (ticket_id, photo_url, caption, uploaded_by)
+		VALUES (_ticket_id, _photo_url, COALESCE(_caption, ''), _uploaded_by)
+		RETURNING id INTO v_id;
+
+		RETURN v_id;
+	END;
+$$;
+
+-- resolve_repair_ticket closes an open ticket. A repaired asset gets its status back in good condition; an asset beyond repair
+-- goes "Down" in bad condition, with the latest ticket photo as its condition photo, and is left for disposal.
+CREATE OR REPLACE PROCEDURE public.resolve_repair_ticket(
+	_ticket_id INT,
+	_status VARCHAR(20),
+	_repair_cost BIGINT,
+	_resolution_notes TEXT,
+	_resolved_by INT
+)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_ticket "RepairTicket"%ROWTYPE;
+		v_photo_url TEXT;
+	BEGIN
+		IF _status IS NULL OR _status NOT IN ('Repaired', 'Beyond Repair') THEN
+			RAISE EXCEPTION 'Repair outcome must be Repaired or Beyond Repair';
+		END IF;
+
+		SELECT * INTO v_ticket FROM "RepairTicket" AS rt WHERE rt.id = _ticket_id FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Repair ticket % not found', _ticket_id;
+		END IF;
+
+		IF v_ticket.status <> 'In Repair' THEN
+			RAISE EXCEPTION 'Repair ticket % is already resolved', _ticket_id;
+		END IF;
+
+		IF _status = 'Repaired' THEN
+			UPDATE "Asset" AS a
+			SET
+				status = CASE WHEN a.status = 'In Repair' THEN v_ticket.previous_status ELSE a.status END,
+				condition = 1,
+				condition_notes = COALESCE(_resolution_notes, '')
+			WHERE a.asset_tag = v_ticket.asset_tag;
+		ELSE
+			SELECT p.photo_url INTO v_photo_url
+			FROM "RepairTicketPhoto" AS p
+			WHERE p.ticket_id = _ticket_id
+			ORDER BY p.uploaded_at DESC, p.id DESC
+			LIMIT 1;
+
+			UPDATE "Asset" AS a
+			SET
+				status = CASE WHEN a.status = 'In Repair' THEN 'Down' ELSE a.status END,
+				condition = CASE WHEN COALESCE(v_photo_url, a.condition_photo_url, '') <> '' THEN 0 ELSE a.condition END,
+				condition_notes = COALESCE(_resolution_notes, ''),
+				condition_photo_url = COALESCE(v_photo_url, a.condition_photo_url)
+			WHERE a.asset_tag = v_ticket.asset_tag;
+		END IF;
+
+		UPDATE "RepairTicket" AS rt
+		SET
+			status = _status,
+			repair_cost = _repair_cost,
+			resolved_by = _resolved_by,
+			resolved_at = NOW(),
+			resolution_notes = COALESCE(_resolution_notes, '')
+		WHERE rt.id = _ticket_id;
+	END;
+$$;
+
+-- is_repair_ticket_manager checks whether a user may update and resolve a repair ticket: the GA staff of the site the asset is on.
+CREATE OR REPLACE FUNCTION public.is_repair_ticket_manager(_ticket_id INT, _user_id INT)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN EXISTS (
+			SELECT 1
+			FROM "RepairTicket" AS rt
+			INNER JOIN "Asset" AS a ON rt.asset_tag = a.asset_tag
+			INNER JOIN "Site" AS s ON a.site_id = s.id
+			WHERE rt.id = _ticket_id AND s.site_ga_id = _user_id
+		);
+	END;
+$$;
+
+-- categorize_opname_assets counts assets "In Repair" as broken, like "Down" ones, so that opening their repair ticket does not
+-- move them out of the broken assets of the session they were found in.
+CREATE OR REPLACE FUNCTION public.categorize_opname_assets(_session_id INT)
+	RETURNS TABLE (
+		category VARCHAR(50),
+		asset_tag VARCHAR(12),
+		product_variety VARCHAR(50)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		WITH off_site AS (
+			SELECT eo.asset_tag, eo.product_variety FROM public.get_expected_off_site_assets(_session_id) AS eo
+		)
+		SELECT * FROM (
+			-- Categorize assets that were scanned and processed during opname (use effective values from changes JSON)
+			SELECT
+				CASE
+					WHEN effective_status IN ('Down', 'In Repair') THEN 'broken_assets'::VARCHAR(50)
+					WHEN cost_center_changed AND effective_status NOT IN ('Down', 'In Repair') THEN 'misplaced_assets'::VARCHAR(50)
+					ELSE 'working_assets'::VARCHAR(50)
+				END AS category,
+				a.asset_tag,
+				a.product_variety
+			FROM "AssetChanges" AS ac
+			INNER JOIN "Asset" AS a ON ac.asset_tag = a.asset_tag
+			LEFT JOIN "User" AS ou ON a.owner_id = ou.user_id
+			LEFT JOIN LATERAL (
+				SELECT
+					COALESCE((ac."changes"->>'newStatus')::VARCHAR, a.status) AS effective_status,
+					COALESCE((ac."changes"->>'newOwnerCostCenter')::INT, ou.cost_center_id) AS effective_cost_center,
+					CASE
+						WHEN (ac."changes" ? 'newOwnerCostCenter') AND ( (ac."changes"->>'newOwnerCostCenter')::INT IS DISTINCT FROM ou.cost_center_id)
+						THEN TRUE ELSE FALSE END AS cost_center_changed
+			) eff ON TRUE
+			WHERE ac.session_id = _session_id
+				AND NOT ((a.condition = 2 OR ac.changes ->> 'newCondition' = '2') AND a.status NOT IN ('Down', 'In Repair'))
+
+			UNION
+
+			-- Missing assets: assets not found during opname, denoted by 'Lost' condition or 2 in value.
+			-- Assets on loan are expected not to be found and are listed below instead.
+			SELECT
+				'missing_assets'::VARCHAR(50) AS category,
+				ac.asset_tag,
+				a.product_variety
+			FROM "AssetChanges" AS ac
+			LEFT JOIN "Asset" AS a ON ac.asset_tag = a.asset_tag
+			WHERE
+				ac.session_id = _session_id
+				AND
+				(a.condition = 2 OR ac.changes ->> 'newCondition' = '2')
+				AND
+				(a.status NOT IN ('Down', 'In Repair')) -- Exclude broken assets to avoid double counting them
+				AND
+				NOT EXISTS (SELECT 1 FROM off_site AS os WHERE os.asset_tag = ac.asset_tag)
+
+			UNION
+
+			-- Expected off-site assets: on loan and either not scanned, or recorded as lost.
+			SELECT
+				'expected_off_site'::VARCHAR(50) AS category,
+				os.asset_tag,
+				os.product_variety
+			FROM off_site AS os
+			LEFT JOIN "AssetChanges" AS ac ON ac.session_id = _session_id AND ac.asset_tag = os.asset_tag
+			WHERE ac.asset_tag IS NULL OR ac.changes ->> 'newCondition' = '2'
+		)
+		ORDER BY category, asset_tag;
+	END;
+$$;
+
+-- get_opname_bap_details appends the status of the asset's repair ticket to its action notes. A ticket shows up in the BAP of the
+-- session it was opened in and of every later session, until the first one started after the ticket was resolved.
+CREATE OR REPLACE FUNCTION public.get_opname_bap_details(_session_id INT)
+	RETURNS TABLE (
+		category VARCHAR(50),
+		company VARCHAR(50),
+		asset_tag VARCHAR(12),
+		asset_name VARCHAR(50),
+		equipments TEXT,
+		user_name_and_position TEXT,
+		asset_status VARCHAR(20),
+		action_notes TEXT,
+		cost_center_id INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		-- NOTE:
+		-- 1. Some assets may have NULL owner_id -> use LEFT JOIN to avoid losing rows.
+		-- 2. Apply latest (effective) values from AssetChanges JSON when present.
+		-- 3. Use concat_ws to safely build user_name_and_position and action_notes.
+		-- 4. Use LEFT JOIN LATERAL for equipments helper, effective field derivation and the repair ticket.
+		-- 5. Deterministic category ordering via CASE.
+		RETURN QUERY
+		SELECT
+			ca.category,
+			'Surya Madistrindo'::VARCHAR(50) AS company,
+			a.asset_tag,
+			a.product_name AS asset_name,
+			eff.effective_equipments AS equipments,
+			CASE
+				WHEN u.user_id IS NULL THEN 'N/A'
+				WHEN LOWER(u.username) = 'vacant' THEN 'VACANT'
+				ELSE UPPER(concat_ws(' - ', eff.effective_owner_position, trim(both ' ' FROM concat_ws(' ', u.first_name, u.last_name))))
+			END AS user_name_and_position,
+				eff.effective_status::VARCHAR(20) AS asset_status,
+			NULLIF(concat_ws('; ', NULLIF(ac.action_notes, ''), repair.note), '') AS action_notes,
+			CASE
+				WHEN eff.effective_owner_cost_center IS NULL THEN NULL
+				WHEN eff.effective_owner_cost_center = 0 THEN NULL -- normalize 0 to NULL (VACANT or unset)
+				ELSE eff.effective_owner_cost_center
+			END AS cost_center_id
+		FROM public.categorize_opname_assets(_session_id) AS ca
+		INNER JOIN "Asset" AS a ON ca.asset_tag = a.asset_tag
+		LEFT JOIN "OpnameSession" AS os ON os.id = _session_id
+		LEFT JOIN "AssetChanges" AS ac
+			ON a.asset_tag = ac.asset_tag
+			AND ac.session_id = _session_id
+		LEFT JOIN "User" AS ou ON a.owner_id = ou.user_id -- original owner
+		LEFT JOIN LATERAL (
+			SELECT
+				COALESCE((ac."changes"->>'newOwnerID')::INT, a.owner_id) AS effective_owner_id,
+				COALESCE(ac."changes"->>'newOwnerPosition', ou.position) AS effective_owner_position,
+				COALESCE(ac."changes"->>'newOwnerDepartment', ou.department) AS effective_owner_department,
+				COALESCE(ac."changes"->>'newOwnerDivision', ou.division) AS effective_owner_division,
+				COALESCE((ac."changes"->>'newOwnerCostCenter')::INT, ou.cost_center_id) AS effective_owner_cost_center,
+				COALESCE(ac."changes"->>'newEquipments', a.equipments) AS effective_equipments,
+				COALESCE(ac."changes"->>'newStatus', a.status) AS effective_status
+		) eff ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT
+				concat_ws(' ',
+					'Perbaikan #' || rt.id || ':',
+					CASE rt.status
+						WHEN 'In Repair' THEN 'dalam perbaikan'
+						WHEN 'Repaired' THEN 'selesai diperbaiki'
+						ELSE 'tidak dapat diperbaiki, diusulkan untuk dimusnahkan'
+					END,
+					CASE WHEN rt.vendor <> '' THEN '(' || rt.vendor || ')' END
+				) AS note
+			FROM "RepairTicket" AS rt
+			WHERE rt.asset_tag = a.asset_tag
+				AND rt.opened_at <= COALESCE(os.l1_reviewed_at, NOW())
+				AND (
+					rt.resolved_at IS NULL
+					OR NOT EXISTS (
+						SELECT 1
+						FROM "AssetChanges" AS pac
+						INNER JOIN "OpnameSession" AS ps ON pac.session_id = ps.id
+						WHERE pac.asset_tag = a.asset_tag
+							AND ps.status = 'Verified'
+							AND ps.start_date > rt.resolved_at
+							AND ps.start_date < os.start_date
+					)
+				)
+			ORDER BY rt.opened_at DESC, rt.id DESC
+			LIMIT 1
+		) repair ON TRUE
+		LEFT JOIN "User" AS u ON u.user_id = eff.effective_owner_id
+		ORDER BY
+			CASE ca.category
+				WHEN 'working_assets' THEN 1
+				WHEN 'broken_assets' THEN 2
+				WHEN 'misplaced_assets' THEN 3
+				WHEN 'missing_assets' THEN 4
+				ELSE 99
+			END,
+			a.asset_tag;
+	END;
+$$;
@@ -0,0 +1,11 @@
+-- Removes the disposal requests. Assets already disposed of keep their "Disposed" status and reason.
+DROP PROCEDURE IF EXISTS public.close_disposal_request(INT, VARCHAR(20), INT, TEXT);
+DROP PROCEDURE IF EXISTS public.approve_disposal_request(INT, INT, TEXT);
+DROP FUNCTION IF EXISTS public.get_disposal_items(INT);
+DROP FUNCTION IF EXISTS public.get_disposal_requests(INT, VARCHAR(20), VARCHAR(20), INT, INT, INT);
+DROP FUNCTION IF EXISTS public.create_disposal_request(VARCHAR(20), INT, VARCHAR(12)[], TEXT, INT);
+DROP FUNCTION IF EXISTS public.check_asset_disposable(VARCHAR(12), INT);
+DROP FUNCTION IF EXISTS public.asset_book_value(VARCHAR(12));
+
+DROP TABLE IF EXISTS "DisposalItem";
+DROP TABLE IF EXISTS "DisposalRequest";
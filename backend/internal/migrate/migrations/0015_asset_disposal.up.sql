@@ -0,0 +1,323 @@
+-- Asset disposals (internal/disposal): a batch of lost or obsolete assets written off together after a finance approval.
+-- Lost assets come from the missing assets of a verified opname session, obsolete ones are selected by hand.
+-- The book value of every asset is recorded when the request is made; approval disposes of all assets at once.
+
+CREATE TABLE "DisposalRequest" (
+    "id" SERIAL PRIMARY KEY,
+    "reason" VARCHAR(20) NOT NULL CHECK ("reason" IN ('Lost', 'Obsolete')), -- Becomes "Asset".status_reason on approval
+
+    -- Foreign key to OpnameSession (the verified session the lost assets were missing in).
+    "session_id" INT REFERENCES "OpnameSession"("id") ON DELETE SET NULL,
+
+    "notes" TEXT NOT NULL DEFAULT '',
+    "status" VARCHAR(20) NOT NULL DEFAULT 'Pending' CHECK ("status" IN ('Pending', 'Approved', 'Rejected', 'Cancelled')),
+
+    -- Foreign key to User (the L1 support who requested the disposal).
+    "requested_by" INT NOT NULL REFERENCES "User"("user_id"),
+    "requested_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Foreign key to User (the finance reviewer who approved or rejected the disposal, or the user who cancelled it).
+    "reviewed_by" INT REFERENCES "User"("user_id") ON DELETE SET NULL,
+    "reviewed_at" TIMESTAMP WITH TIME ZONE,
+    "review_notes" TEXT NOT NULL DEFAULT '',
+
+    CONSTRAINT ck_disposal_review CHECK (("status" = 'Pending') = ("reviewed_at" IS NULL))
+);
+
+CREATE INDEX idx_disposal_request_requested_at ON "DisposalRequest" ("requested_at" DESC);
+
+CREATE TABLE "DisposalItem" (
+    "request_id" INT NOT NULL REFERENCES "DisposalRequest"("id") ON DELETE CASCADE,
+    "asset_tag" VARCHAR(12) NOT NULL REFERENCES "Asset"("asset_tag"),
+
+    -- In rupiah, as of the request.
+    "acquisition_cost" BIGINT NOT NULL,
+    "book_value" BIGINT NOT NULL,
+
+    PRIMARY KEY ("request_id", "asset_tag")
+);
+
+CREATE INDEX idx_disposal_item_asset_tag ON "DisposalItem" ("asset_tag");
+
+-- asset_book_value returns the value of an asset in the books, in rupiah.
+-- Without depreciation data this is its acquisition cost, "Asset".total_cost.
+CREATE OR REPLACE FUNCTION public.asset_book_value(_asset_tag VARCHAR(12))
+	RETURNS BIGINT
+	LANGUAGE plpgsql
+	STABLE
+AS $$
+	BEGIN
+		RETURN (SELECT COALESCE(a.total_cost, 0)::BIGINT FROM "Asset" AS a WHERE a.asset_tag = _asset_tag);
+	END;
+$$;
+
+-- check_asset_disposable raises an exception unless an asset can be disposed of: it exists, is not disposed, on loan or in repair,
+-- and is in no other pending disposal request. The asset row is locked until the end of the transaction.
+CREATE OR REPLACE FUNCTION public.check_asset_disposable(_asset_tag VARCHAR(12), _request_id INT)
+	RETURNS VOID
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_status VARCHAR(20);
+		v_pending_id INT;
+	BEGIN
+		SELECT a.status INTO v_status
+		FROM "Asset" AS a
+		WHERE a.asset_tag = _asset_tag
+		FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Asset with tag % not found', _asset_tag;
+		END IF;
+
+		IF v_status IN ('Disposed', 'On Loan', 'In Repair') THEN
+			RAISE EXCEPTION 'Asset % is % and cannot be disposed', _asset_tag, v_status;
+		END IF;
+
+		SELECT dr.id INTO v_pending_id
+		FROM "DisposalItem" AS di
+		INNER JOIN "DisposalRequest" AS dr ON di.request_id = dr.id
+		WHERE di.asset_tag = _asset_tag
+			AND dr.status = 'Pending'
+			AND dr.id IS DISTINCT FROM _request_id
+		LIMIT 1;
+
+		IF v_pending_id IS NOT NULL THEN
+			RAISE EXCEPTION 'Asset % is already in pending disposal request %', _asset_tag, v_pending_id;
+		END IF;
+	END;
+$$;
+
+-- create_disposal_request records a pending disposal and returns its ID. Lost assets must be missing in the given verified session;
+-- without asset tags, all of its missing assets are taken. Obsolete assets are given by tag, the session is then optional.
+CREATE OR REPLACE FUNCTION public.create_disposal_request(
+	_reason VARCHAR(20),
+	_session_id INT,
+	_asset_tags VARCHAR(12)[],
+	_notes TEXT,
+	_requested_by INT
+)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_missing VARCHAR(12)[];
+		v_tags VARCHAR(12)[];
+		v_tag VARCHAR(12);
+		v_id INT;
+	BEGIN
+		IF _reason IS NULL OR _reason NOT IN ('Lost', 'Obsolete') THEN
+			RAISE EXCEPTION 'Disposal reason must be Lost or Obsolete';
+		END IF;
+
+		IF _session_id IS NOT NULL AND NOT EXISTS (
+			SELECT 1 FROM "OpnameSession" AS os WHERE os.id = _session_id AND os.status = 'Verified'
+		) THEN
+			RAISE EXCEPTION 'Opname session % is not verified', _session_id;
+		END IF;
+
+		IF _reason = 'Lost' THEN
+			IF _session_id IS NULL THEN
+				RAISE EXCEPTION 'Lost assets are disposed of from a verified opname session';
+			END IF;
+
+			SELECT COALESCE(array_agg(ca.asset_tag), '{}') INTO v_missing
+			FROM public.categorize_opname_assets(_session_id) AS ca
+			WHERE ca.category = 'missing_assets';
+
+			v_tags := COALESCE(NULLIF(_asset_tags, '{}'), v_missing);
+			FOREACH v_tag IN ARRAY v_tags LOOP
+				IF NOT (v_tag = ANY(v_missing)) THEN
+					RAISE EXCEPTION 'Asset % is not missing in opname session %', v_tag, _session_id;
+				END IF;
+			END LOOP;
+		ELSE
+			v_tags := COALESCE(_asset_tags, '{}');
+		END IF;
+
+		SELECT COALESCE(array_agg(DISTINCT t), '{}') INTO v_tags FROM unnest(v_tags) AS t;
+		IF cardinality(v_tags) = 0 THEN
+			RAISE EXCEPTION 'A disposal request needs at least one asset';
+		END IF;
+
+		-- Serialize requests so that an asset cannot end up in two pending ones.
+		LOCK TABLE "DisposalItem" IN SHARE ROW EXCLUSIVE MODE;
+		FOREACH v_tag IN ARRAY v_tags LOOP
+			PERFORM public.check_asset_disposable(v_tag, NULL);
+		END LOOP;
+
+		INSERT INTO "DisposalRequest" (reason, session_id, notes, requested_by)
+		VALUES (_reason, _session_id, COALESCE(_notes, ''), _requested_by)
+		RETURNING id INTO v_id;
+
+		INSERT INTO "DisposalItem" (request_id, asset_tag, acquisition_cost, book_value)
+		SELECT v_id, a.asset_tag, COALESCE(a.total_cost, 0), public.asset_book_value(a.asset_tag)
+		FROM "Asset" AS a
+		WHERE a.asset_tag = ANY(v_tags);
+
+		RETURN v_id;
+	END;
+$$;
+
+-- get_disposal_requests retrieves disposal requests with their totals and the names needed to display them, newest first.
+-- Every filter is optional. Pagination is done here, total_count is the number of requests matching the filters.
+CREATE OR REPLACE FUNCTION public.get_disposal_requests(
+	_request_id INT,
+	_status VARCHAR(20),
+	_reason VARCHAR(20),
+	_session_id INT,
+	_limit INT,
+	_page_number INT
+)
+	RETURNS TABLE (
+		id INT,
+		reason VARCHAR(20),
+		session_id INT,
+		notes TEXT,
+		status VARCHAR(20),
+		requested_by INT,
+		requester_name VARCHAR(510),
+		requested_at TIMESTAMP WITH TIME ZONE,
+		reviewed_by INT,
+		reviewer_name VARCHAR(510),
+		reviewed_at TIMESTAMP WITH TIME ZONE,
+		review_notes TEXT,
+		item_count INT,
+		total_acquisition_cost BIGINT,
+		total_book_value BIGINT,
+		total_count BIGINT
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_offset INT := GREATEST(COALESCE(_page_number,1)-1,0) * COALESCE(NULLIF(_limit,0),50);
+	BEGIN
+		RETURN QUERY
+		SELECT
+			dr.id,
+			dr.reason,
+			dr.session_id,
+			dr.notes,
+			dr.status,
+			dr.requested_by,
+			(COALESCE(qu.first_name, '') || ' ' || COALESCE(qu.last_name, ''))::VARCHAR(510) AS requester_name,
+			dr.requested_at,
+			dr.reviewed_by,
+			(CASE WHEN ru.user_id IS NULL THEN NULL ELSE COALESCE(ru.first_name, '') || ' ' || COALESCE(ru.last_name, '') END)::VARCHAR(510) AS reviewer_name,
+			dr.reviewed_at,
+			dr.review_notes,
+			totals.item_count,
+			totals.total_acquisition_cost,
+			totals.total_book_value,
+			COUNT(*) OVER()::BIGINT AS total_count
+		FROM "DisposalRequest" AS dr
+		INNER JOIN "User" AS qu ON dr.requested_by = qu.user_id
+		LEFT JOIN "User" AS ru ON dr.reviewed_by = ru.user_id
+		LEFT JOIN LATERAL (
+			SELECT
+				COUNT(*)::INT AS item_count,
+				COALESCE(SUM(di.acquisition_cost), 0)::BIGINT AS total_acquisition_cost,
+				COALESCE(SUM(di.book_value), 0)::BIGINT AS total_book_value
+			FROM "DisposalItem" AS di
+			WHERE di.request_id = dr.id
+		) totals ON TRUE
+		WHERE
+			(_request_id IS NULL OR dr.id = _request_id)
+			AND (_status IS NULL OR _status = '' OR dr.status = _status)
+			AND (_reason IS NULL OR _reason = '' OR dr.reason = _reason)
+			AND (_session_id IS NULL OR dr.session_id = _session_id)
+		ORDER BY dr.requested_at DESC, dr.id DESC
+		LIMIT COALESCE(NULLIF(_limit,0), 50) OFFSET v_offset;
+	END;
+$$;
+
+-- get_disposal_items retrieves the assets of a disposal request with their recorded values, by site then asset tag.
+CREATE OR REPLACE FUNCTION public.get_disposal_items(_request_id INT)
+	RETURNS TABLE (
+		asset_tag VARCHAR(12),
+		serial_number VARCHAR(25),
+		brand_name VARCHAR(25),
+		product_name VARCHAR(50),
+		product_variety VARCHAR(50),
+		site_name VARCHAR(100),
+		asset_status VARCHAR(20),
+		acquisition_cost BIGINT,
+		book_value BIGINT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			di.asset_tag,
+			a.serial_number,
+			a.brand_name,
+			a.product_name,
+			a.product_variety,
+			s.site_name,
+			a.status AS asset_status,
+			di.acquisition_cost,
+			di.book_value
+		FROM "DisposalItem" AS di
+		INNER JOIN "Asset" AS a ON di.asset_tag = a.asset_tag
+		LEFT JOIN "Site" AS s ON a.site_id = s.id
+		WHERE di.request_id = _request_id
+		ORDER BY s.site_name, di.asset_tag;
+	END;
+$$;
+
+-- approve_disposal_request approves a pending disposal and disposes of all its assets at once, with the request's reason.
+-- It fails as a whole if any asset was loaned out, sent to repair or disposed of since the request.
+CREATE OR REPLACE PROCEDURE public.approve_disposal_request(_request_id INT, _reviewed_by INT, _review_notes TEXT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_request "DisposalRequest"%ROWTYPE;
+		v_tag VARCHAR(12);
+	BEGIN
+		SELECT * INTO v_request FROM "DisposalRequest" AS dr WHERE dr.id = _request_id FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Disposal request % not found', _request_id;
+		END IF;
+
+		IF v_request.status <> 'Pending' THEN
+			RAISE EXCEPTION 'Disposal request % is not pending', _request_id;
+		END IF;
+
+		FOR v_tag IN SELECT di.asset_tag FROM "DisposalItem" AS di WHERE di.request_id = _request_id ORDER BY di.asset_tag LOOP
+			PERFORM public.check_asset_disposable(v_tag, _request_id);
+		END LOOP;
+
+		UPDATE "Asset" AS a
+		SET status = 'Disposed', status_reason = v_request.reason
+		FROM "DisposalItem" AS di
+		WHERE di.request_id = _request_id AND a.asset_tag = di.asset_tag;
+
+		UPDATE "DisposalRequest" AS dr
+		SET status = 'Approved', reviewed_by = _reviewed_by, reviewed_at = NOW(), review_notes = COALESCE(_review_notes, '')
+		WHERE dr.id = _request_id;
+	END;
+$$;
+
+-- close_disposal_request rejects or cancels a pending disposal without touching the assets.
+CREATE OR REPLACE PROCEDURE public.close_disposal_request(_request_id INT, _status VARCHAR(20), _reviewed_by INT, _review_notes TEXT)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF _status NOT IN ('Rejected', 'Cancelled') THEN
+			RAISE EXCEPTION 'Disposal request can only be closed as Rejected or Cancelled';
+		END IF;
+
+		UPDATE "DisposalRequest" AS dr
+		SET status = _status, reviewed_by = _reviewed_by, reviewed_at = NOW(), review_notes = COALESCE(_review_notes, '')
+		WHERE dr.id = _request_id AND dr.status = 'Pending';
+
+		IF NOT FOUND THEN
+			IF EXISTS (SELECT 1 FROM "DisposalRequest" AS dr WHERE dr.id = _request_id) THEN
+				RAISE EXCEPTION 'Disposal request % is not pending', _request_id;
+			END IF;
+			RAISE EXCEPTION 'Disposal request % not found', _request_id;
+		END IF;
+	END;
+$$;
@@ -0,0 +1,264 @@
+-- Removes depreciation and restores get_asset_by_tag and get_asset_by_serial_number of 0002_baseline_functions, and
+-- asset_book_value, create_disposal_request and get_disposal_items of 0015_asset_disposal.
+-- Book values already recorded in disposal requests are kept.
+DROP FUNCTION IF EXISTS public.get_disposal_items(INT);
+DROP PROCEDURE IF EXISTS public.set_disposal_book_values(INT, VARCHAR[], BIGINT[]);
+DROP FUNCTION IF EXISTS public.get_opname_asset_values(INT);
+DROP FUNCTION IF EXISTS public.get_asset_by_tag(VARCHAR);
+DROP FUNCTION IF EXISTS public.get_asset_by_serial_number(VARCHAR);
+DROP PROCEDURE IF EXISTS public.set_asset_acquisition(VARCHAR, DATE, INT);
+DROP PROCEDURE IF EXISTS public.update_depreciation_policy(VARCHAR, VARCHAR, INT);
+DROP FUNCTION IF EXISTS public.get_depreciation_policies();
+
+-- asset_book_value returns the value of an asset in the books, in rupiah.
+-- Without depreciation data this is its acquisition cost, "Asset".total_cost.
+CREATE OR REPLACE FUNCTION public.asset_book_value(_asset_tag VARCHAR(12))
+	RETURNS BIGINT
+	LANGUAGE plpgsql
+	STABLE
+AS $$
+	BEGIN
+		RETURN (SELECT COALESCE(a.total_cost, 0)::BIGINT FROM "Asset" AS a WHERE a.asset_tag = _asset_tag);
+	END;
+$$;
+
+-- create_disposal_request records a pending disposal and returns its ID. Lost assets must be missing in the given verified session;
+-- without asset tags, all of its missing assets are taken. Obsolete assets are given by tag, the session is then optional.
+CREATE OR REPLACE FUNCTION public.create_disposal_request(
+	_reason VARCHAR(20),
+	_session_id INT,
+	_asset_tags VARCHAR(12)[],
+	_notes TEXT,
+	_requested_by INT
+)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_missing VARCHAR(12)[];
+		v_tags VARCHAR(12)[];
+		v_tag VARCHAR(12);
+		v_id INT;
+	BEGIN
+		IF _reason IS NULL OR _reason NOT IN ('Lost', 'Obsolete') THEN
+			RAISE EXCEPTION 'Disposal reason must be Lost or Obsolete';
+		END IF;
+
+		IF _session_id IS NOT NULL AND NOT EXISTS (
+			SELECT 1 FROM "OpnameSession" AS os WHERE os.id = _session_id AND os.status = 'Verified'
+		) THEN
+			RAISE EXCEPTION 'Opname session % is not verified', _session_id;
+		END IF;
+
+		IF _reason = 'Lost' THEN
+			IF _session_id IS NULL THEN
+				RAISE EXCEPTION 'Lost assets are disposed of from a verified opname session';
+			END IF;
+
+			SELECT COALESCE(array_agg(ca.asset_tag), '{}') INTO v_missing
+			FROM public.categorize_opname_assets(_session_id) AS ca
+			WHERE ca.category = 'missing_assets';
+
+			v_tags := COALESCE(NULLIF(_asset_tags, '{}'), v_missing);
+			FOREACH v_tag IN ARRAY v_tags LOOP
+				IF NOT (v_tag = ANY(v_missing)) THEN
+					RAISE EXCEPTION 'Asset % is not missing in opname session %', v_tag, _session_id;
+				END IF;
+			END LOOP;
+		ELSE
+			v_tags := COALESCE(_asset_tags, '{}');
+		END IF;
+
+		SELECT COALESCE(array_agg(DISTINCT t), '{}') INTO v_tags FROM unnest(v_tags) AS t;
+		IF cardinality(v_tags) = 0 THEN
+			RAISE EXCEPTION 'A disposal request needs at least one asset';
+		END IF;
+
+		-- Serialize requests so that an asset cannot end up in two pending ones.
+		LOCK TABLE "DisposalItem" IN SHARE ROW EXCLUSIVE MODE;
+		FOREACH v_tag IN ARRAY v_tags LOOP
+			PERFORM public.check_asset_disposable(v_tag, NULL);
+		END LOOP;
+
+		INSERT INTO "DisposalRequest" (reason, session_id, notes, requested_by)
+		VALUES (_reason, _session_id, COALESCE(_notes, ''), _requested_by)
+		RETURNING id INTO v_id;
+
+		INSERT INTO "DisposalItem" (request_id, asset_tag, acquisition_cost, book_value)
+		SELECT v_id, a.asset_tag, COALESCE(a.total_cost, 0), public.asset_book_value(a.asset_tag)
+		FROM "Asset" AS a
+		WHERE a.asset_tag = ANY(v_tags);
+
+		RETURN v_id;
+	END;
+$$;
+
+-- get_disposal_items retrieves the assets of a disposal request with their recorded values, by site then asset tag.
+CREATE OR REPLACE FUNCTION public.get_disposal_items(_request_id INT)
+	RETURNS TABLE (
+		asset_tag VARCHAR(12),
+		serial_number VARCHAR(25),
+		brand_name VARCHAR(25),
+		product_name VARCHAR(50),
+		product_variety VARCHAR(50),
+		site_name VARCHAR(100),
+		asset_status VARCHAR(20),
+		acquisition_cost BIGINT,
+		book_value BIGINT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			di.asset_tag,
+			a.serial_number,
+			a.brand_name,
+			a.product_name,
+			a.product_variety,
+			s.site_name,
+			a.status AS asset_status,
+			di.acquisition_cost,
+			di.book_value
+		FROM "DisposalItem" AS di
+		INNER JOIN "Asset" AS a ON di.asset_tag = a.asset_tag
+		LEFT JOIN "Site" AS s ON a.site_id = s.id
+		WHERE di.request_id = _request_id
+		ORDER BY s.site_name, di.asset_tag;
+	END;
+$$;
+
+-- get_asset_by_tag retrieves asset details by asset tag
+CREATE OR REPLACE FUNCTION public.get_asset_by_tag(_asset_tag VARCHAR(12))
+	RETURNS TABLE (
+		asset_tag VARCHAR(12),
+		serial_number VARCHAR(25),
+		"status" VARCHAR(20),
+		status_reason VARCHAR(20),
+		product_category VARCHAR(50),
+		product_subcategory VARCHAR(50),
+		product_variety VARCHAR(50),
+		brand_name VARCHAR(25),
+		product_name VARCHAR(50),
+		condition INT,
+		condition_notes TEXT,
+		condition_photo_url TEXT,
+		loss_notes TEXT,
+		"location" VARCHAR(255),
+		room VARCHAR(255),
+		equipments TEXT,
+		total_cost INT,
+		owner_id INT,
+		owner_name VARCHAR(510),
+		owner_position VARCHAR(100),
+		owner_department VARCHAR(100),
+		owner_division VARCHAR(100),
+		owner_cost_center INT,
+		sub_site_id INT,
+		sub_site_name VARCHAR(100),
+		site_id INT,
+		dept_id INT,
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT a.asset_tag, a.serial_number, a.status, a.status_reason,
+				a.product_category, a.product_subcategory, a.product_variety,
+				a.brand_name, a.product_name, 
+				a.condition, a.condition_notes, a.condition_photo_url::TEXT, a.loss_notes,
+				a.location, a.room, a.equipments, a.total_cost,
+				a.owner_id,
+				(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, ''))::VARCHAR(510) AS owner_name,
+				u.position AS owner_position,
+				d.dept_name AS owner_department, -- NOTE: 'owner department' is regarded as asset's location in HO, not owner's actual department
+				u.division AS owner_division,
+				u.cost_center_id AS owner_cost_center,
+				a.sub_site_id,
+				ss.sub_site_name AS sub_site_name,
+				a.site_id AS site_id, a.dept_id AS dept_id,
+				s.site_name AS site_name,
+				sg.site_group_name AS site_group_name,
+				r.region_name AS region_name
+			FROM "Asset" AS a
+			LEFT JOIN "User" AS u ON a.owner_id = u.user_id
+			LEFT JOIN "SubSite" AS ss ON a.sub_site_id = ss.id
+			LEFT JOIN "Site" AS s ON a.site_id = s.id
+			LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+			LEFT JOIN "Region" AS r ON sg.region_id = r.id
+			LEFT JOIN "Department" AS d ON LOWER(u.department) = LOWER(d.dept_name)
+			WHERE a.asset_tag = _asset_tag;
+	END;
+$$;
+
+-- get_assets_by_serial_number retrieves asset details by serial number
+CREATE OR REPLACE FUNCTION public.get_asset_by_serial_number(_serial_number VARCHAR(25))
+	RETURNS TABLE (
+		asset_tag VARCHAR(12),
+		serial_number VARCHAR(25),
+		"status" VARCHAR(20),
+		status_reason VARCHAR(20),
+		product_category VARCHAR(50),
+		product_subcategory VARCHAR(50),
+		product_variety VARCHAR(50),
+		brand_name VARCHAR(25),
+		product_name VARCHAR(50),
+		condition INT,
+		condition_notes TEXT,
+		condition_photo_url TEXT,
+		loss_notes TEXT,
+		"location" VARCHAR(255),
+		room VARCHAR(255),
+		equipments TEXT,
+		total_cost INT,
+		owner_id INT,
+		owner_name VARCHAR(510),
+		owner_position VARCHAR(100),
+		owner_department VARCHAR(100),
+		owner_division VARCHAR(100),
+		owner_cost_center INT,
+		sub_site_id INT,
+		sub_site_name VARCHAR(100),
+		site_id INT,
+		dept_id INT,
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT a.asset_tag, a.serial_number, a.status, a.status_reason,
+				a.product_category, a.product_subcategory, a.product_variety,
+				a.brand_name, a.product_name, 
+				a.condition, a.condition_notes, a.condition_photo_url::TEXT, a.loss_notes,
+				a.location, a.room, a.equipments, a.total_cost,
+				a.owner_id,
+				(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, ''))::VARCHAR(510) AS owner_name,
+				u.position AS owner_position,
+				d.dept_name AS owner_department, -- NOTE: 'owner department' is regarded as asset's location in HO, not owner's actual department
+				u.division AS owner_division,
+				u.cost_center_id AS owner_cost_center,
+				a.sub_site_id,
+				ss.sub_site_name AS sub_site_name,
+				a.site_id AS site_id, a.dept_id AS dept_id,
+				s.site_name AS site_name,
+				sg.site_group_name AS site_group_name,
+				r.region_name AS region_name
+			FROM "Asset" AS a
+			LEFT JOIN "User" AS u ON a.owner_id = u.user_id
+			LEFT JOIN "SubSite" AS ss ON a.sub_site_id = ss.id
+			LEFT JOIN "Site" AS s ON a.site_id = s.id
+			LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+			LEFT JOIN "Region" AS r ON sg.region_id = r.id
+			LEFT JOIN "Department" AS d ON LOWER(u.department) = LOWER(d.dept_name)
+			WHERE a.serial_number = _serial_number;
+	END;
+$$;
+
+DROP TABLE IF EXISTS "DepreciationPolicy";
+ALTER TABLE "Asset" DROP COLUMN IF EXISTS "acquisition_date";
@@ -0,0 +1,397 @@
+-- Asset depreciation (internal/depreciation): every asset loses value from its acquisition date over the useful life of its
+-- product variety, with a straight-line or declining balance method. The net book value itself is computed by the backend;
+-- these functions only return what it is computed from.
+
+ALTER TABLE "Asset" ADD COLUMN "acquisition_date" DATE; -- Assets without one are not depreciated
+
+CREATE TABLE "DepreciationPolicy" (
+    "product_variety" VARCHAR(50) PRIMARY KEY CHECK ("product_variety" IN ('Laptop', 'Desktop', 'Monitor', 'Uninterrupted Power Supply', 'Personal Digital Assistant', 'Printer/Multifunction')),
+    "depreciation_method" VARCHAR(20) NOT NULL CHECK ("depreciation_method" IN ('Straight Line', 'Declining Balance')),
+    "useful_life_months" INT NOT NULL CHECK ("useful_life_months" > 0),
+    "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- IT equipment is a group 1 asset (kelompok 1) for Indonesian tax purposes: a useful life of 4 years.
+INSERT INTO "DepreciationPolicy" ("product_variety", "depreciation_method", "useful_life_months") VALUES
+    ('Laptop', 'Straight Line', 48),
+    ('Desktop', 'Straight Line', 48),
+    ('Monitor', 'Straight Line', 48),
+    ('Uninterrupted Power Supply', 'Straight Line', 48),
+    ('Personal Digital Assistant', 'Straight Line', 48),
+    ('Printer/Multifunction', 'Straight Line', 48);
+
+-- get_depreciation_policies retrieves the depreciation policy of every product variety, with how many assets it applies to and how
+-- many of them have no acquisition date yet (and are therefore not depreciated). Disposed assets are not counted.
+CREATE OR REPLACE FUNCTION public.get_depreciation_policies()
+	RETURNS TABLE (
+		product_variety VARCHAR(50),
+		depreciation_method VARCHAR(20),
+		useful_life_months INT,
+		asset_count BIGINT,
+		undated_asset_count BIGINT,
+		updated_at TIMESTAMP WITH TIME ZONE
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			dp.product_variety,
+			dp.depreciation_method,
+			dp.useful_life_months,
+			COUNT(a.asset_tag)::BIGINT AS asset_count,
+			COUNT(a.asset_tag) FILTER (WHERE a.acquisition_date IS NULL)::BIGINT AS undated_asset_count,
+			dp.updated_at
+		FROM "DepreciationPolicy" AS dp
+		LEFT JOIN "Asset" AS a ON a.product_variety = dp.product_variety AND a.status <> 'Disposed'
+		GROUP BY dp.product_variety
+		ORDER BY dp.product_variety;
+	END;
+$$;
+
+-- update_depreciation_policy changes the method and useful life of a product variety. Book values follow immediately, except the
+-- ones already recorded in disposal requests.
+CREATE OR REPLACE PROCEDURE public.update_depreciation_policy(_product_variety VARCHAR(50), _depreciation_method VARCHAR(20), _useful_life_months INT)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "DepreciationPolicy" AS dp
+		SET depreciation_method = _depreciation_method, useful_life_months = _useful_life_months, updated_at = NOW()
+		WHERE dp.product_variety = _product_variety;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Depreciation policy for % not found', _product_variety;
+		END IF;
+	END;
+$$;
+
+-- set_asset_acquisition records when an asset was acquired and, if given, for how much.
+CREATE OR REPLACE PROCEDURE public.set_asset_acquisition(_asset_tag VARCHAR(12), _acquisition_date DATE, _total_cost INT)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "Asset" AS a
+		SET acquisition_date = _acquisition_date, total_cost = COALESCE(_total_cost, a.total_cost)
+		WHERE a.asset_tag = _asset_tag;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Asset with tag % not found', _asset_tag;
+		END IF;
+	END;
+$$;
+
+DROP FUNCTION IF EXISTS public.get_asset_by_tag(VARCHAR);
+DROP FUNCTION IF EXISTS public.get_asset_by_serial_number(VARCHAR);
+
+-- get_asset_by_tag retrieves asset details by asset tag, with the acquisition date and depreciation policy its net book value is computed from.
+CREATE OR REPLACE FUNCTION public.get_asset_by_tag(_asset_tag VARCHAR(12))
+	RETURNS TABLE (
+		asset_tag VARCHAR(12),
+		serial_number VARCHAR(25),
+		"status" VARCHAR(20),
+		status_reason VARCHAR(20),
+		product_category VARCHAR(50),
+		product_subcategory VARCHAR(50),
+		product_variety VARCHAR(50),
+		brand_name VARCHAR(25),
+		product_name VARCHAR(50),
+		condition INT,
+		condition_notes TEXT,
+		condition_photo_url TEXT,
+		loss_notes TEXT,
+		"location" VARCHAR(255),
+		room VARCHAR(255),
+		equipments TEXT,
+		total_cost INT,
+		owner_id INT,
+		owner_name VARCHAR(510),
+		owner_position VARCHAR(100),
+		owner_department VARCHAR(100),
+		owner_division VARCHAR(100),
+		owner_cost_center INT,
+		sub_site_id INT,
+		sub_site_name VARCHAR(100),
+		site_id INT,
+		dept_id INT,
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100),
+		acquisition_date DATE,
+		depreciation_method VARCHAR(20),
+		useful_life_months INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT a.asset_tag, a.serial_number, a.status, a.status_reason,
+				a.product_category, a.product_subcategory, a.product_variety,
+				a.brand_name, a.product_name, 
+				a.condition, a.condition_notes, a.condition_photo_url::TEXT, a.loss_notes,
+				a.location, a.room, a.equipments, a.total_cost,
+				a.owner_id,
+				(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, ''))::VARCHAR(510) AS owner_name,
+				u.position AS owner_position,
+				d.dept_name AS owner_department, -- NOTE: 'owner department' is regarded as asset's location in HO, not owner's actual department
+				u.division AS owner_division,
+				u.cost_center_id AS owner_cost_center,
+				a.sub_site_id,
+				ss.sub_site_name AS sub_site_name,
+				a.site_id AS site_id, a.dept_id AS dept_id,
+				s.site_name AS site_name,
+				sg.site_group_name AS site_group_name,
+				r.region_name AS region_name,
+				a.acquisition_date,
+				dp.depreciation_method,
+				dp.useful_life_months
+			FROM "Asset" AS a
+			LEFT JOIN "User" AS u ON a.owner_id = u.user_id
+			LEFT JOIN "SubSite" AS ss ON a.sub_site_id = ss.id
+			LEFT JOIN "Site" AS s ON a.site_id = s.id
+			LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+			LEFT JOIN "Region" AS r ON sg.region_id = r.id
+			LEFT JOIN "Department" AS d ON LOWER(u.department) = LOWER(d.dept_name)
+			LEFT JOIN "DepreciationPolicy" AS dp ON a.product_variety = dp.product_variety
+			WHERE a.asset_tag = _asset_tag;
+	END;
+$$;
+
+-- get_asset_by_serial_number retrieves asset details by serial number, with the acquisition date and depreciation policy its net book value is computed from.
+CREATE OR REPLACE FUNCTION public.get_asset_by_serial_number(_serial_number VARCHAR(25))
+	RETURNS TABLE (
+		asset_tag VARCHAR(12),
+		serial_number VARCHAR(25),
+		"status" VARCHAR(20),
+		status_reason VARCHAR(20),
+		product_category VARCHAR(50),
+		product_subcategory VARCHAR(50),
+		product_variety VARCHAR(50),
+		brand_name VARCHAR(25),
+		product_name VARCHAR(50),
+		condition INT,
+		condition_notes TEXT,
+		condition_photo_url TEXT,
+		loss_notes TEXT,
+		"location" VARCHAR(255),
+		room VARCHAR(255),
+		equipments TEXT,
+		total_cost INT,
+		owner_id INT,
+		owner_name VARCHAR(510),
+		owner_position VARCHAR(100),
+		owner_department VARCHAR(100),
+		owner_division VARCHAR(100),
+		owner_cost_center INT,
+		sub_site_id INT,
+		sub_site_name VARCHAR(100),
+		site_id INT,
+		dept_id INT,
+		site_name VARCHAR(100),
+		site_group_name VARCHAR(100),
+		region_name VARCHAR(100),
+		acquisition_date DATE,
+		depreciation_method VARCHAR(20),
+		useful_life_months INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT a.asset_tag, a.serial_number, a.status, a.status_reason,
+				a.product_category, a.product_subcategory, a.product_variety,
+				a.brand_name, a.product_name, 
+				a.condition, a.condition_notes, a.condition_photo_url::TEXT, a.loss_notes,
+				a.location, a.room, a.equipments, a.total_cost,
+				a.owner_id,
+				(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, ''))::VARCHAR(510) AS owner_name,
+				u.position AS owner_position,
+				d.dept_name AS owner_department, -- NOTE: 'owner department' is regarded as asset's location in HO, not owner's actual department
+				u.division AS owner_division,
+				u.cost_center_id AS owner_cost_center,
+				a.sub_site_id,
+				ss.sub_site_name AS sub_site_name,
+				a.site_id AS site_id, a.dept_id AS dept_id,
+				s.site_name AS site_name,
+				sg.site_group_name AS site_group_name,
+				r.region_name AS region_name,
+				a.acquisition_date,
+				dp.depreciation_method,
+				dp.useful_life_months
+			FROM "Asset" AS a
+			LEFT JOIN "User" AS u ON a.owner_id = u.user_id
+			LEFT JOIN "SubSite" AS ss ON a.sub_site_id = ss.id
+			LEFT JOIN "Site" AS s ON a.site_id = s.id
+			LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+			LEFT JOIN "Region" AS r ON sg.region_id = r.id
+			LEFT JOIN "Department" AS d ON LOWER(u.department) = LOWER(d.dept_name)
+			LEFT JOIN "DepreciationPolicy" AS dp ON a.product_variety = dp.product_variety
+			WHERE a.serial_number = _serial_number;
+	END;
+$$;
+
+-- get_opname_asset_values retrieves the categorized assets of an opname session (see categorize_opname_assets) with what their net
+-- book value is computed from. Assets without a cost are worth nothing.
+CREATE OR REPLACE FUNCTION public.get_opname_asset_values(_session_id INT)
+	RETURNS TABLE (
+		category VARCHAR(50),
+		asset_tag VARCHAR(12),
+		product_variety VARCHAR(50),
+		total_cost BIGINT,
+		acquisition_date DATE,
+		depreciation_method VARCHAR(20),
+		useful_life_months INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			ca.category,
+			ca.asset_tag,
+			ca.product_variety,
+			COALESCE(a.total_cost, 0)::BIGINT AS total_cost,
+			a.acquisition_date,
+			dp.depreciation_method,
+			dp.useful_life_months
+		FROM public.categorize_opname_assets(_session_id) AS ca
+		INNER JOIN "Asset" AS a ON ca.asset_tag = a.asset_tag
+		LEFT JOIN "DepreciationPolicy" AS dp ON a.product_variety = dp.product_variety
+		ORDER BY ca.category, ca.asset_tag;
+	END;
+$$;
+
+-- create_disposal_request records a pending disposal and returns its ID. Lost assets must be missing in the given verified session;
+-- without asset tags, all of its missing assets are taken. Obsolete assets are given by tag, the session is then optional.
+-- Items are recorded at their acquisition cost: the backend computes their book value (internal/depreciation) and records it
+-- with set_disposal_book_values in the same transaction.
+CREATE OR REPLACE FUNCTION public.create_disposal_request(
+	_reason VARCHAR(20),
+	_session_id INT,
+	_asset_tags VARCHAR(12)[],
+	_notes TEXT,
+	_requested_by INT
+)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_missing VARCHAR(12)[];
+		v_tags VARCHAR(12)[];
+		v_tag VARCHAR(12);
+		v_id INT;
+	BEGIN
+		IF _reason IS NULL OR _reason NOT IN ('Lost', 'Obsolete') THEN
+			RAISE EXCEPTION 'Disposal reason must be Lost or Obsolete';
+		END IF;
+
+		IF _session_id IS NOT NULL AND NOT EXISTS (
+			SELECT 1 FROM "OpnameSession" AS os WHERE os.id = _session_id AND os.status = 'Verified'
+		) THEN
+			RAISE EXCEPTION 'Opname session % is not verified', _session_id;
+		END IF;
+
+		IF _reason = 'Lost' THEN
+			IF _session_id IS NULL THEN
+				RAISE EXCEPTION 'Lost assets are disposed of from a verified opname session';
+			END IF;
+
+			SELECT COALESCE(array_agg(ca.asset_tag), '{}') INTO v_missing
+			FROM public.categorize_opname_assets(_session_id) AS ca
+			WHERE ca.category = 'missing_assets';
+
+			v_tags := COALESCE(NULLIF(_asset_tags, '{}'), v_missing);
+			FOREACH v_tag IN ARRAY v_tags LOOP
+				IF NOT (v_tag = ANY(v_missing)) THEN
+					RAISE EXCEPTION 'Asset % is not missing in opname session %', v_tag, _session_id;
+				END IF;
+			END LOOP;
+		ELSE
+			v_tags := COALESCE(_asset_tags, '{}');
+		END IF;
+
+		SELECT COALESCE(array_agg(DISTINCT t), '{}') INTO v_tags FROM unnest(v_tags) AS t;
+		IF cardinality(v_tags) = 0 THEN
+			RAISE EXCEPTION 'A disposal request needs at least one asset';
+		END IF;
+
+		-- Serialize requests so that an asset cannot end up in two pending ones.
+		LOCK TABLE "DisposalItem" IN SHARE ROW EXCLUSIVE MODE;
+		FOREACH v_tag IN ARRAY v_tags LOOP
+			PERFORM public.check_asset_disposable(v_tag, NULL);
+		END LOOP;
+
+		INSERT INTO "DisposalRequest" (reason, session_id, notes, requested_by)
+		VALUES (_reason, _session_id, COALESCE(_notes, ''), _requested_by)
+		RETURNING id INTO v_id;
+
+		INSERT INTO "DisposalItem" (request_id, asset_tag, acquisition_cost, book_value)
+		SELECT v_id, a.asset_tag, COALESCE(a.total_cost, 0), COALESCE(a.total_cost, 0)
+		FROM "Asset" AS a
+		WHERE a.asset_tag = ANY(v_tags);
+
+		RETURN v_id;
+	END;
+$$;
+
+DROP FUNCTION IF EXISTS public.asset_book_value(VARCHAR);
+
+-- set_disposal_book_values records the book value computed for each asset of a pending disposal request, in rupiah.
+CREATE OR REPLACE PROCEDURE public.set_disposal_book_values(_request_id INT, _asset_tags VARCHAR(12)[], _book_values BIGINT[])
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM "DisposalRequest" AS dr WHERE dr.id = _request_id AND dr.status = 'Pending') THEN
+			RAISE EXCEPTION 'Disposal request % is not pending', _request_id;
+		END IF;
+
+		UPDATE "DisposalItem" AS di
+		SET book_value = v.book_value
+		FROM unnest(_asset_tags, _book_values) AS v(asset_tag, book_value)
+		WHERE di.request_id = _request_id AND di.asset_tag = v.asset_tag;
+	END;
+$$;
+
+DROP FUNCTION IF EXISTS public.get_disposal_items(INT);
+
+-- get_disposal_items retrieves the assets of a disposal request with their recorded values, by site then asset tag.
+-- The acquisition date and depreciation policy of each asset are those its book value is computed from.
+CREATE OR REPLACE FUNCTION public.get_disposal_items(_request_id INT)
+	RETURNS TABLE (
+		asset_tag VARCHAR(12),
+		serial_number VARCHAR(25),
+		brand_name VARCHAR(25),
+		product_name VARCHAR(50),
+		product_variety VARCHAR(50),
+		site_name VARCHAR(100),
+		asset_status VARCHAR(20),
+		acquisition_cost BIGINT,
+		book_value BIGINT,
+		acquisition_date DATE,
+		depreciation_method VARCHAR(20),
+		useful_life_months INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			di.asset_tag,
+			a.serial_number,
+			a.brand_name,
+			a.product_name,
+			a.product_variety,
+			s.site_name,
+			a.status AS asset_status,
+			di.acquisition_cost,
+			di.book_value,
+			a.acquisition_date,
+			dp.depreciation_method,
+			dp.useful_life_months
+		FROM "DisposalItem" AS di
+		INNER JOIN "Asset" AS a ON di.asset_tag = a.asset_tag
+		LEFT JOIN "Site" AS s ON a.site_id = s.id
+		LEFT JOIN "DepreciationPolicy" AS dp ON a.product_variety = dp.product_variety
+		WHERE di.request_id = _request_id
+		ORDER BY s.site_name, di.asset_tag;
+	END;
+$$;
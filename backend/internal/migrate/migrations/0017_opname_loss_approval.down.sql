@@ -0,0 +1,95 @@
+-- Removes the loss approvals and restores get_latest_opname_status and create_new_opname_session of 0002_baseline_functions.
+-- Sessions still in 'Loss Review' go back to 'Escalated', waiting for L1 support again.
+DROP FUNCTION IF EXISTS public.get_user_emails_by_positions(TEXT[]);
+DROP FUNCTION IF EXISTS public.get_opname_loss_approval(INT);
+DROP PROCEDURE IF EXISTS public.review_opname_loss(INT, INT, BOOLEAN);
+DROP PROCEDURE IF EXISTS public.request_opname_loss_approval(INT, INT, BIGINT, BIGINT);
+
+UPDATE "OpnameSession"
+SET "status" = 'Escalated',
+	l1_reviewer_id = NULL,
+	l1_reviewed_at = NULL
+WHERE "status" = 'Loss Review';
+
+DROP TABLE IF EXISTS "OpnameLossApproval";
+
+ALTER TABLE "OpnameSession" DROP CONSTRAINT "OpnameSession_status_check",
+	ADD CONSTRAINT "OpnameSession_status_check" CHECK ("status" IN ('Outdated', 'Active', 'Submitted', 'Escalated', 'Verified', 'Rejected'));
+
+-- get_latest_opname_status retrieves the latest opname session by either its site or department
+CREATE OR REPLACE FUNCTION public.get_latest_opname_status(_site_id INT, _dept_id INT)
+	RETURNS TABLE (
+		session_status VARCHAR(20),
+		session_end_date TIMESTAMP,
+		created_by VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			CASE
+				WHEN os.status = 'Active' THEN 'Active'
+				WHEN os.status IN ('Submitted', 'Escalated', 'Verified', 'Rejected')
+					-- TODO: Make the interval dynamic based on user settings.
+					AND COALESCE(os.end_date, os.start_date) > (NOW() - INTERVAL '30 days')
+					THEN os.status
+				ELSE 'Outdated'
+			END AS session_status,
+			os.end_date::TIMESTAMP,
+			(u.first_name || ' ' || u.last_name)::VARCHAR(255) AS created_by
+		FROM "OpnameSession" os
+		LEFT JOIN "User" AS u ON os.user_id = u.user_id
+		WHERE os.site_id = _site_id OR os.dept_id = _dept_id
+		ORDER BY os.start_date DESC
+		LIMIT 1;
+	END;
+$$;
+
+-- create_new_opname_session creates a new opname session for a site or department
+CREATE OR REPLACE FUNCTION public.create_new_opname_session(
+	-- The ID of the user creating the session (from JWT).
+	_user_id INT,
+	-- The ID of the site for which the session is being created (NULL for department sessions).
+	_site_id INT DEFAULT NULL,
+	-- The ID of the department for which the session is being created (NULL for site sessions).
+	_dept_id INT DEFAULT NULL
+) RETURNS INT -- Returns the new session ID, or 0 if it fails.
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		-- Local variable to count existing ongoing sessions for the site or department.
+		-- Sessions in 'Active', 'Submitted', and 'Escalated' status are considered ongoing.
+		_ongoing_session_count INT;
+		_new_session_id INT := 0; -- Initialize to 0, will be set if a new session is created.
+	BEGIN
+		-- Validate that exactly one of site_id or dept_id is provided
+		IF (_site_id IS NULL AND _dept_id IS NULL) OR (_site_id IS NOT NULL AND _dept_id IS NOT NULL) THEN
+			RAISE EXCEPTION 'Exactly one of site_id or dept_id must be provided';
+		END IF;
+
+		-- Check if there are any active opname sessions for the site or department.
+		SELECT COUNT(*)
+		INTO _ongoing_session_count
+		FROM "OpnameSession"
+		WHERE ((_site_id IS NOT NULL AND site_id = _site_id) OR (_dept_id IS NOT NULL AND dept_id = _dept_id))
+		  AND "status" IN ('Active', 'Submitted', 'Escalated');
+
+		-- If there are no ongoing sessions, proceed to create a new one.
+		IF _ongoing_session_count = 0 THEN
+			-- Insert a new opname session into the OpnameSession table.
+			INSERT INTO "OpnameSession" (user_id, site_id, dept_id, "status", start_date)
+			VALUES (_user_id, _site_id, _dept_id, 'Active', NOW())
+			-- Get the ID of the newly created session.
+			-- The 'RETURNING' clause allows us to capture the new session ID.
+			RETURNING id INTO _new_session_id;
+
+			RAISE NOTICE 'New opname session created with ID: %, site_id: %, dept_id: %', _new_session_id, _site_id, _dept_id;
+		ELSE
+			-- If there is already an active session, do nothing. _new_session_id will remain 0.
+			RAISE NOTICE 'An ongoing opname session already exists for site_id: %, dept_id: %, cannot create a new one.', _site_id, _dept_id;
+		END IF;
+
+		RETURN _new_session_id;
+	END;
+$$;
@@ -0,0 +1,215 @@
+-- Loss approval of opname sessions (internal/opname): when the net book value of the broken and missing assets of a session
+-- exceeds the configured opname.loss_threshold, L1 support verification no longer closes it. The session goes to 'Loss Review'
+-- and becomes 'Verified' or 'Rejected' once one of the opname.loss_approver_positions reviews the loss.
+-- The loss is computed by the backend (internal/depreciation), so it is recorded here as it was when the approval was requested.
+
+ALTER TABLE "OpnameSession" DROP CONSTRAINT "OpnameSession_status_check",
+	ADD CONSTRAINT "OpnameSession_status_check" CHECK ("status" IN ('Outdated', 'Active', 'Submitted', 'Escalated', 'Loss Review', 'Verified', 'Rejected'));
+
+CREATE TABLE "OpnameLossApproval" (
+    -- Foreign key to OpnameSession (the session whose loss is reviewed). A session is reviewed at most once.
+    "session_id" INT PRIMARY KEY REFERENCES "OpnameSession"("id") ON DELETE CASCADE,
+
+    "loss_value" BIGINT NOT NULL CHECK ("loss_value" >= 0), -- Net book value in rupiah of the broken and missing assets
+    "loss_threshold" BIGINT NOT NULL CHECK ("loss_threshold" >= 0), -- opname.loss_threshold when the approval was requested
+
+    -- Foreign key to User (the L1 support whose verification required the approval).
+    "requested_by" INT REFERENCES "User"("user_id") ON DELETE SET NULL,
+    "requested_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    -- Foreign key to User (the loss approver who approved or rejected the loss).
+    "reviewer_id" INT REFERENCES "User"("user_id") ON DELETE SET NULL,
+    "reviewed_at" TIMESTAMP WITH TIME ZONE,
+    "decision" VARCHAR(10) CHECK ("decision" IN ('Approved', 'Rejected')),
+
+    CONSTRAINT ck_loss_approval_review CHECK (("decision" IS NULL) = ("reviewed_at" IS NULL))
+);
+
+-- request_opname_loss_approval verifies an escalated session on behalf of L1 support like approve_opname_session,
+-- but moves it to 'Loss Review' and records the loss waiting for approval instead of verifying it.
+CREATE OR REPLACE PROCEDURE public.request_opname_loss_approval(_session_id INT, _reviewer_id INT, _loss_value BIGINT, _loss_threshold BIGINT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_user_position VARCHAR;
+		_current_status VARCHAR;
+	BEGIN
+		SELECT "status" INTO _current_status
+		FROM "OpnameSession"
+		WHERE id = _session_id AND "status" = 'Escalated'
+		FOR UPDATE;
+
+		IF _current_status IS NULL THEN
+			RAISE EXCEPTION 'No submitted or escalated opname session found with ID: %', _session_id;
+		END IF;
+
+		SELECT LOWER(u.position) INTO _user_position
+		FROM "User" AS u
+		WHERE u.user_id = _reviewer_id;
+
+		IF _user_position IS DISTINCT FROM 'l1 support' THEN
+			RAISE EXCEPTION 'Only L1 support can verify an escalated opname session. Session ID: %', _session_id;
+		END IF;
+
+		UPDATE "OpnameSession"
+		SET l1_reviewer_id = _reviewer_id,
+			"status" = 'Loss Review',
+			l1_reviewed_at = NOW()
+		WHERE id = _session_id;
+
+		INSERT INTO "OpnameLossApproval" (session_id, loss_value, loss_threshold, requested_by)
+		VALUES (_session_id, _loss_value, _loss_threshold, _reviewer_id);
+	END;
+$$;
+
+-- review_opname_loss approves or rejects the loss of a session in 'Loss Review', which becomes 'Verified' or 'Rejected'.
+-- Who may approve losses is configured in the backend (opname.loss_approver_positions), which checks the reviewer beforehand.
+CREATE OR REPLACE PROCEDURE public.review_opname_loss(_session_id INT, _reviewer_id INT, _approved BOOLEAN)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		PERFORM 1
+		FROM "OpnameSession"
+		WHERE id = _session_id AND "status" = 'Loss Review'
+		FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'No opname session waiting for a loss approval found with ID: %', _session_id;
+		END IF;
+
+		UPDATE "OpnameSession"
+		SET "status" = CASE WHEN _approved THEN 'Verified' ELSE 'Rejected' END
+		WHERE id = _session_id;
+
+		UPDATE "OpnameLossApproval"
+		SET reviewer_id = _reviewer_id,
+			reviewed_at = NOW(),
+			"decision" = CASE WHEN _approved THEN 'Approved' ELSE 'Rejected' END
+		WHERE session_id = _session_id;
+	END;
+$$;
+
+-- get_opname_loss_approval retrieves the loss approval of a session, no row when none was needed.
+CREATE OR REPLACE FUNCTION public.get_opname_loss_approval(_session_id INT)
+	RETURNS TABLE (
+		loss_value BIGINT,
+		loss_threshold BIGINT,
+		requested_by INT,
+		requested_at TIMESTAMP WITH TIME ZONE,
+		reviewer_id INT,
+		reviewer_name VARCHAR(255),
+		reviewed_at TIMESTAMP WITH TIME ZONE,
+		decision VARCHAR(10)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			la.loss_value,
+			la.loss_threshold,
+			la.requested_by,
+			la.requested_at,
+			la.reviewer_id,
+			(u.first_name || ' ' || u.last_name)::VARCHAR(255) AS reviewer_name,
+			la.reviewed_at,
+			la."decision"
+		FROM "OpnameLossApproval" AS la
+		LEFT JOIN "User" AS u ON la.reviewer_id = u.user_id
+		WHERE la.session_id = _session_id;
+	END;
+$$;
+
+-- get_user_emails_by_positions retrieves the emails of the active users holding one of the positions, matched case-insensitively.
+CREATE OR REPLACE FUNCTION public.get_user_emails_by_positions(_positions TEXT[])
+	RETURNS TABLE (
+		email VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT u.email
+			FROM "User" AS u
+			WHERE LOWER(u.position) = ANY (SELECT LOWER(TRIM(p)) FROM UNNEST(_positions) AS p) AND u.is_active
+			ORDER BY u.email;
+	END;
+$$;
+
+-- get_latest_opname_status shows sessions in 'Loss Review' like the other reviewed ones.
+CREATE OR REPLACE FUNCTION public.get_latest_opname_status(_site_id INT, _dept_id INT)
+	RETURNS TABLE (
+		session_status VARCHAR(20),
+		session_end_date TIMESTAMP,
+		created_by VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT
+			CASE
+				WHEN os.status = 'Active' THEN 'Active'
+				WHEN os.status IN ('Submitted', 'Escalated', 'Loss Review', 'Verified', 'Rejected')
+					-- TODO: Make the interval dynamic based on user settings.
+					AND COALESCE(os.end_date, os.start_date) > (NOW() - INTERVAL '30 days')
+					THEN os.status
+				ELSE 'Outdated'
+			END AS session_status,
+			os.end_date::TIMESTAMP,
+			(u.first_name || ' ' || u.last_name)::VARCHAR(255) AS created_by
+		FROM "OpnameSession" os
+		LEFT JOIN "User" AS u ON os.user_id = u.user_id
+		WHERE os.site_id = _site_id OR os.dept_id = _dept_id
+		ORDER BY os.start_date DESC
+		LIMIT 1;
+	END;
+$$;
+
+-- create_new_opname_session also considers sessions in 'Loss Review' ongoing.
+CREATE OR REPLACE FUNCTION public.create_new_opname_session(
+	-- The ID of the user creating the session (from JWT).
+	_user_id INT,
+	-- The ID of the site for which the session is being created (NULL for department sessions).
+	_site_id INT DEFAULT NULL,
+	-- The ID of the department for which the session is being created (NULL for site sessions).
+	_dept_id INT DEFAULT NULL
+) RETURNS INT -- Returns the new session ID, or 0 if it fails.
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		-- Local variable to count existing ongoing sessions for the site or department.
+		-- Sessions in 'Active', 'Submitted', 'Escalated' and 'Loss Review' status are considered ongoing.
+		_ongoing_session_count INT;
+		_new_session_id INT := 0; -- Initialize to 0, will be set if a new session is created.
+	BEGIN
+		-- Validate that exactly one of site_id or dept_id is provided
+		IF (_site_id IS NULL AND _dept_id IS NULL) OR (_site_id IS NOT NULL AND _dept_id IS NOT NULL) THEN
+			RAISE EXCEPTION 'Exactly one of site_id or dept_id must be provided';
+		END IF;
+
+		-- Check if there are any active opname sessions for the site or department.
+		SELECT COUNT(*)
+		INTO _ongoing_session_count
+		FROM "OpnameSession"
+		WHERE ((_site_id IS NOT NULL AND site_id = _site_id) OR (_dept_id IS NOT NULL AND dept_id = _dept_id))
+		  AND "status" IN ('Active', 'Submitted', 'Escalated', 'Loss Review');
+
+		-- If there are no ongoing sessions, proceed to create a new one.
+		IF _ongoing_session_count = 0 THEN
+			-- Insert a new opname session into the OpnameSession table.
+			INSERT INTO "OpnameSession" (user_id, site_id, dept_id, "status", start_date)
+			VALUES (_user_id, _site_id, _dept_id, 'Active', NOW())
+			-- Get the ID of the newly created session.
+			-- The 'RETURNING' clause allows us to capture the new session ID.
+			RETURNING id INTO _new_session_id;
+
+			RAISE NOTICE 'New opname session created with ID: %, site_id: %, dept_id: %', _new_session_id, _site_id, _dept_id;
+		ELSE
+			-- If there is already an active session, do nothing. _new_session_id will remain 0.
+			RAISE NOTICE 'An ongoing opname session already exists for site_id: %, dept_id: %, cannot create a new one.', _site_id, _dept_id;
+		END IF;
+
+		RETURN _new_session_id;
+	END;
+$$;
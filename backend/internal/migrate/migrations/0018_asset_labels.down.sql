@@ -0,0 +1,2 @@
+-- Removes get_asset_labels.
+DROP FUNCTION IF EXISTS public.get_asset_labels(VARCHAR(12)[]);
@@ -0,0 +1,21 @@
+-- Asset label sheets (internal/asset/labels.go) fetch every printed asset in one query instead of one get_asset_by_tag per asset.
+
+-- get_asset_labels retrieves what a label shows of the given assets, in the order of the tags.
+-- Unknown tags are left out, the backend reports them to the caller.
+CREATE OR REPLACE FUNCTION public.get_asset_labels(_asset_tags VARCHAR(12)[])
+	RETURNS TABLE (
+		asset_tag VARCHAR(12),
+		product_name VARCHAR(50),
+		site_name VARCHAR(100)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT a.asset_tag, a.product_name, s.site_name
+			FROM UNNEST(_asset_tags) WITH ORDINALITY AS t(tag, position)
+			JOIN "Asset" AS a ON a.asset_tag = t.tag
+			LEFT JOIN "Site" AS s ON a.site_id = s.id
+			ORDER BY t.position;
+	END;
+$$;
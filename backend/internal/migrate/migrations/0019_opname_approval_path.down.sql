@@ -0,0 +1,192 @@
+-- Restores the hardcoded opname reviewers: area manager of the region for submitted sessions, L1 support for escalated ones.
+DROP FUNCTION IF EXISTS public.get_opname_session_reviewers(INT);
+
+-- is_opname_session_reviewer checks whether a user is the next reviewer on the approval path of a session's location.
+-- Submitted sessions are reviewed by an area manager of the location's region, escalated sessions by L1 support.
+-- Department sessions belong to the department's head office site.
+CREATE OR REPLACE FUNCTION public.is_opname_session_reviewer(_session_id INT, _user_id INT)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_status VARCHAR(20);
+		_session_region_id INT;
+		_user_position VARCHAR(100);
+		_user_region_id INT;
+	BEGIN
+		SELECT os.status, sg.region_id
+		INTO _status, _session_region_id
+		FROM "OpnameSession" AS os
+		LEFT JOIN "Department" AS d ON os.dept_id = d.id
+		LEFT JOIN "Site" AS s ON s.id = COALESCE(os.site_id, d.site_id)
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		WHERE os.id = _session_id;
+
+		SELECT LOWER(u.position), sg.region_id
+		INTO _user_position, _user_region_id
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		WHERE u.user_id = _user_id;
+
+		IF _status = 'Submitted' THEN
+			RETURN _user_position = 'area manager' AND _user_region_id = _session_region_id;
+		ELSIF _status = 'Escalated' THEN
+			RETURN _user_position = 'l1 support';
+		END IF;
+
+		-- Sessions that are not waiting for a review have no reviewer.
+		RETURN FALSE;
+	END;
+$$;
+
+DROP FUNCTION IF EXISTS public.get_opname_review_step(INT);
+
+-- approve_opname_session verifies an opname session by session ID
+CREATE OR REPLACE PROCEDURE public.approve_opname_session(_session_id INT, _reviewer_id INT)
+    LANGUAGE plpgsql
+AS $$
+    DECLARE 
+        _user_position VARCHAR;
+        _current_status VARCHAR;
+    BEGIN
+        -- Check if the opname session exists and get its current status
+        SELECT "status" INTO _current_status
+        FROM "OpnameSession"
+        WHERE id = _session_id AND "status" IN ('Submitted', 'Escalated');
+        
+        IF _current_status IS NULL THEN
+            RAISE EXCEPTION 'No submitted or escalated opname session found with ID: %', _session_id;
+        END IF;
+
+        -- Get the user's position
+        SELECT LOWER(u.position) INTO _user_position
+        FROM "User" AS u 
+        WHERE u.user_id = _reviewer_id;
+
+        -- Handle based on current session status
+        IF _current_status = 'Submitted' THEN
+            -- Only area manager can approve/escalate a submitted session
+            IF _user_position != 'area manager' THEN
+                RAISE EXCEPTION 'Only area manager can approve a submitted opname session. Session ID: %', _session_id;
+            END IF;
+            
+            -- Update session to escalated status
+            UPDATE "OpnameSession"
+            SET manager_reviewer_id = _reviewer_id,
+                "status" = 'Escalated',
+                manager_reviewed_at = NOW()
+            WHERE id = _session_id;
+            
+            RAISE NOTICE 'Opname session with ID: % has been escalated by area manager.', _session_id;
+            
+        ELSIF _current_status = 'Escalated' THEN
+            -- Only L1 support can verify an escalated session
+            IF _user_position != 'l1 support' THEN
+                RAISE EXCEPTION 'Only L1 support can verify an escalated opname session. Session ID: %', _session_id;
+            END IF;
+            
+            -- Update session to verified status
+            UPDATE "OpnameSession"
+            SET l1_reviewer_id = _reviewer_id,
+                "status" = 'Verified',
+                l1_reviewed_at = NOW()
+            WHERE id = _session_id;
+            
+            RAISE NOTICE 'Opname session with ID: % has been verified by L1 support.', _session_id;
+        END IF;
+    END;
+$$;
+
+-- reject_opname_session marks an opname session as rejected
+CREATE OR REPLACE PROCEDURE public.reject_opname_session(_session_id INT, _reviewer_id INT)
+    LANGUAGE plpgsql
+AS $$
+    DECLARE 
+        _user_position VARCHAR;
+        _current_status VARCHAR;
+    BEGIN
+        -- Check if the opname session exists and get its current status
+        SELECT "status" INTO _current_status
+        FROM "OpnameSession"
+        WHERE id = _session_id AND "status" IN ('Submitted', 'Escalated');
+        
+        IF _current_status IS NULL THEN
+            RAISE EXCEPTION 'No submitted or escalated opname session found with ID: %', _session_id;
+        END IF;
+
+        -- Get the user's position
+        SELECT LOWER(u.position) INTO _user_position
+        FROM "User" AS u 
+        WHERE u.user_id = _reviewer_id;
+
+        -- Handle based on current session status
+        IF _current_status = 'Submitted' THEN
+            -- Only area manager can reject a submitted session
+            IF _user_position != 'area manager' THEN
+                RAISE EXCEPTION 'Only area manager can reject a submitted opname session. Session ID: %', _session_id;
+            END IF;
+            
+            -- Update session to rejected status
+            UPDATE "OpnameSession"
+            SET manager_reviewer_id = _reviewer_id,
+                "status" = 'Rejected',
+                manager_reviewed_at = NOW()
+            WHERE id = _session_id;
+            
+            RAISE NOTICE 'Opname session with ID: % has been rejected by area manager.', _session_id;
+            
+        ELSIF _current_status = 'Escalated' THEN
+            -- Only L1 support can reject an escalated session
+            IF _user_position != 'l1 support' THEN
+                RAISE EXCEPTION 'Only L1 support can reject an escalated opname session. Session ID: %', _session_id;
+            END IF;
+            
+            -- Update session to rejected status
+            UPDATE "OpnameSession"
+            SET l1_reviewer_id = _reviewer_id,
+                "status" = 'Rejected',
+                l1_reviewed_at = NOW()
+            WHERE id = _session_id;
+            
+            RAISE NOTICE 'Opname session with ID: % has been rejected by L1 support.', _session_id;
+        END IF;
+    END;
+$$;
+
+-- request_opname_loss_approval verifies an escalated session on behalf of L1 support like approve_opname_session,
+-- but moves it to 'Loss Review' and records the loss waiting for approval instead of verifying it.
+CREATE OR REPLACE PROCEDURE public.request_opname_loss_approval(_session_id INT, _reviewer_id INT, _loss_value BIGINT, _loss_threshold BIGINT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_user_position VARCHAR;
+		_current_status VARCHAR;
+	BEGIN
+		SELECT "status" INTO _current_status
+		FROM "OpnameSession"
+		WHERE id = _session_id AND "status" = 'Escalated'
+		FOR UPDATE;
+
+		IF _current_status IS NULL THEN
+			RAISE EXCEPTION 'No submitted or escalated opname session found with ID: %', _session_id;
+		END IF;
+
+		SELECT LOWER(u.position) INTO _user_position
+		FROM "User" AS u
+		WHERE u.user_id = _reviewer_id;
+
+		IF _user_position IS DISTINCT FROM 'l1 support' THEN
+			RAISE EXCEPTION 'Only L1 support can verify an escalated opname session. Session ID: %', _session_id;
+		END IF;
+
+		UPDATE "OpnameSession"
+		SET l1_reviewer_id = _reviewer_id,
+			"status" = 'Loss Review',
+			l1_reviewed_at = NOW()
+		WHERE id = _session_id;
+
+		INSERT INTO "OpnameLossApproval" (session_id, loss_value, loss_threshold, requested_by)
+		VALUES (_session_id, _loss_value, _loss_threshold, _reviewer_id);
+	END;
+$$;
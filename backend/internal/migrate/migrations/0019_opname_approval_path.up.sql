@@ -0,0 +1,196 @@
+-- Routes opname reviews through the "ApprovalPath" of the session's location instead of hardcoding area manager then L1 support.
+-- A submitted session is reviewed by the position at sequence 1, an escalated one by the position at sequence 2.
+-- Head office locations (department sessions and sites flagged is_head_office) follow their 'HO' path, other sites their 'Area' path.
+-- Locations without a path, or without a position at a step, keep the former reviewers: area manager, then L1 support.
+
+-- get_opname_review_step retrieves the approval step a session waits for, the position reviewing it and the session's region.
+-- No row is returned for sessions that are not waiting for a review.
+CREATE OR REPLACE FUNCTION public.get_opname_review_step(_session_id INT)
+	RETURNS TABLE (
+		step INT,
+		reviewer_position VARCHAR(100),
+		region_id INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_status VARCHAR(20);
+		_site_id INT;
+		_is_head_office BOOLEAN;
+		_region_id INT;
+		_step INT;
+		_position VARCHAR(100);
+	BEGIN
+		SELECT os.status, s.id, s.is_head_office, sg.region_id
+		INTO _status, _site_id, _is_head_office, _region_id
+		FROM "OpnameSession" AS os
+		LEFT JOIN "Department" AS d ON os.dept_id = d.id
+		LEFT JOIN "Site" AS s ON s.id = COALESCE(os.site_id, d.site_id)
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		WHERE os.id = _session_id;
+
+		_step := CASE _status WHEN 'Submitted' THEN 1 WHEN 'Escalated' THEN 2 END;
+		IF _step IS NULL THEN
+			RETURN;
+		END IF;
+
+		SELECT ap.position INTO _position
+		FROM "ApprovalPath" AS ap
+		WHERE ap.site_id = _site_id
+		  AND ap."from" = CASE WHEN COALESCE(_is_head_office, FALSE) THEN 'HO' ELSE 'Area' END
+		  AND ap."sequence" = _step
+		ORDER BY ap.position
+		LIMIT 1;
+
+		RETURN QUERY
+			SELECT _step, COALESCE(_position, CASE _step WHEN 1 THEN 'Area Manager' ELSE 'L1 Support' END)::VARCHAR(100), _region_id;
+	END;
+$$;
+
+-- is_opname_session_reviewer checks whether a user is the next reviewer on the approval path of a session's location.
+-- The user must be active and hold the position of the step; area managers only review the locations of their own region.
+CREATE OR REPLACE FUNCTION public.is_opname_session_reviewer(_session_id INT, _user_id INT)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN EXISTS (
+			SELECT 1
+			FROM get_opname_review_step(_session_id) AS rs
+			CROSS JOIN "User" AS u
+			LEFT JOIN "Site" AS s ON u.site_id = s.id
+			LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+			WHERE u.user_id = _user_id
+			  AND u.is_active
+			  AND LOWER(u.position) = LOWER(rs.reviewer_position)
+			  AND (LOWER(rs.reviewer_position) <> 'area manager' OR sg.region_id = rs.region_id)
+		);
+	END;
+$$;
+
+-- get_opname_session_reviewers retrieves the users who may review a session at its current step, to notify them.
+CREATE OR REPLACE FUNCTION public.get_opname_session_reviewers(_session_id INT)
+	RETURNS TABLE (
+		user_id INT,
+		reviewer_name VARCHAR(255),
+		email VARCHAR(255)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+			SELECT u.user_id, (u.first_name || ' ' || u.last_name)::VARCHAR(255), u.email
+			FROM get_opname_review_step(_session_id) AS rs
+			CROSS JOIN "User" AS u
+			LEFT JOIN "Site" AS s ON u.site_id = s.id
+			LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+			WHERE u.is_active
+			  AND LOWER(u.position) = LOWER(rs.reviewer_position)
+			  AND (LOWER(rs.reviewer_position) <> 'area manager' OR sg.region_id = rs.region_id)
+			ORDER BY u.email;
+	END;
+$$;
+
+-- approve_opname_session escalates a submitted session or verifies an escalated one, if the reviewer is next on its approval path.
+CREATE OR REPLACE PROCEDURE public.approve_opname_session(_session_id INT, _reviewer_id INT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_current_status VARCHAR;
+	BEGIN
+		SELECT "status" INTO _current_status
+		FROM "OpnameSession"
+		WHERE id = _session_id AND "status" IN ('Submitted', 'Escalated')
+		FOR UPDATE;
+
+		IF _current_status IS NULL THEN
+			RAISE EXCEPTION 'No submitted or escalated opname session found with ID: %', _session_id;
+		END IF;
+
+		IF NOT is_opname_session_reviewer(_session_id, _reviewer_id) THEN
+			RAISE EXCEPTION 'Only the next reviewer on the approval path can review opname session with ID: %', _session_id;
+		END IF;
+
+		IF _current_status = 'Submitted' THEN
+			UPDATE "OpnameSession"
+			SET manager_reviewer_id = _reviewer_id,
+				"status" = 'Escalated',
+				manager_reviewed_at = NOW()
+			WHERE id = _session_id;
+		ELSE
+			UPDATE "OpnameSession"
+			SET l1_reviewer_id = _reviewer_id,
+				"status" = 'Verified',
+				l1_reviewed_at = NOW()
+			WHERE id = _session_id;
+		END IF;
+	END;
+$$;
+
+-- reject_opname_session rejects a submitted or escalated session, if the reviewer is next on its approval path.
+CREATE OR REPLACE PROCEDURE public.reject_opname_session(_session_id INT, _reviewer_id INT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_current_status VARCHAR;
+	BEGIN
+		SELECT "status" INTO _current_status
+		FROM "OpnameSession"
+		WHERE id = _session_id AND "status" IN ('Submitted', 'Escalated')
+		FOR UPDATE;
+
+		IF _current_status IS NULL THEN
+			RAISE EXCEPTION 'No submitted or escalated opname session found with ID: %', _session_id;
+		END IF;
+
+		IF NOT is_opname_session_reviewer(_session_id, _reviewer_id) THEN
+			RAISE EXCEPTION 'Only the next reviewer on the approval path can review opname session with ID: %', _session_id;
+		END IF;
+
+		IF _current_status = 'Submitted' THEN
+			UPDATE "OpnameSession"
+			SET manager_reviewer_id = _reviewer_id,
+				"status" = 'Rejected',
+				manager_reviewed_at = NOW()
+			WHERE id = _session_id;
+		ELSE
+			UPDATE "OpnameSession"
+			SET l1_reviewer_id = _reviewer_id,
+				"status" = 'Rejected',
+				l1_reviewed_at = NOW()
+			WHERE id = _session_id;
+		END IF;
+	END;
+$$;
+
+-- request_opname_loss_approval verifies an escalated session on behalf of its second reviewer like approve_opname_session,
+-- but moves it to 'Loss Review' and records the loss waiting for approval instead of verifying it.
+CREATE OR REPLACE PROCEDURE public.request_opname_loss_approval(_session_id INT, _reviewer_id INT, _loss_value BIGINT, _loss_threshold BIGINT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_current_status VARCHAR;
+	BEGIN
+		SELECT "status" INTO _current_status
+		FROM "OpnameSession"
+		WHERE id = _session_id AND "status" = 'Escalated'
+		FOR UPDATE;
+
+		IF _current_status IS NULL THEN
+			RAISE EXCEPTION 'No submitted or escalated opname session found with ID: %', _session_id;
+		END IF;
+
+		IF NOT is_opname_session_reviewer(_session_id, _reviewer_id) THEN
+			RAISE EXCEPTION 'Only the next reviewer on the approval path can review opname session with ID: %', _session_id;
+		END IF;
+
+		UPDATE "OpnameSession"
+		SET l1_reviewer_id = _reviewer_id,
+			"status" = 'Loss Review',
+			l1_reviewed_at = NOW()
+		WHERE id = _session_id;
+
+		INSERT INTO "OpnameLossApproval" (session_id, loss_value, loss_threshold, requested_by)
+		VALUES (_session_id, _loss_value, _loss_threshold, _reviewer_id);
+	END;
+$$;
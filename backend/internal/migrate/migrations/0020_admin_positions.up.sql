@@ -0,0 +1,210 @@
+-- Admin positions (auth.admin_positions): the positions administering the system were hardcoded as L1 support in the
+-- stored procedures as well as in the backend. The backend now writes the configured positions to "AdminPosition" at startup
+-- (internal/roles), and the procedures check them through is_admin_position.
+
+CREATE TABLE "AdminPosition" (
+    "position" VARCHAR(100) PRIMARY KEY -- Upper case, as the positions are matched case-insensitively
+);
+
+-- Until the backend syncs its configuration, L1 support administers the system as before.
+INSERT INTO "AdminPosition" ("position") VALUES ('L1 SUPPORT');
+
+-- is_admin_position checks whether users with a position administer the system.
+CREATE OR REPLACE FUNCTION public.is_admin_position(_position VARCHAR(100))
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+	STABLE
+AS $$
+	BEGIN
+		RETURN EXISTS (
+			SELECT 1
+			FROM "AdminPosition" AS ap
+			WHERE ap."position" = UPPER(TRIM(_position))
+		);
+	END;
+$$;
+
+-- set_admin_positions replaces the admin positions with the configured ones.
+CREATE OR REPLACE PROCEDURE public.set_admin_positions(_positions VARCHAR(100)[])
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		IF COALESCE(CARDINALITY(_positions), 0) = 0 THEN
+			RAISE EXCEPTION 'At least one admin position is required';
+		END IF;
+
+		DELETE FROM "AdminPosition";
+
+		INSERT INTO "AdminPosition" ("position")
+		SELECT DISTINCT UPPER(TRIM(p))
+		FROM UNNEST(_positions) AS p
+		WHERE TRIM(p) <> '';
+	END;
+$$;
+
+-- get_user_opname_locations retrieves the sites/dept a user has access to using their position and id (from login session)
+-- Pagination and filtering is done here
+-- Note: This functions assumes that admins (see is_admin_position) can see all sites and dept, while others are restriced to their region.
+-- It also assumes that users can only see their own department's sites.
+-- HO mode: shows departments only (for department-level opname), site_id is the department's head office
+-- Area mode: shows sites only (for site-level opname)
+CREATE OR REPLACE FUNCTION public.get_user_opname_locations(
+	_user_id INT,
+	_position VARCHAR(100),
+	_site_group_name VARCHAR(100),
+	_site_name VARCHAR(100),
+	_sub_site_name VARCHAR(100),
+	_dept_name VARCHAR(100),
+	_created_by VARCHAR(255),
+	_opname_status VARCHAR(20),
+	_from_date TIMESTAMP,
+	_end_date TIMESTAMP,
+	_search_in VARCHAR(10), -- Search in area/HO only
+	_limit INT,
+	_page_number INT
+)
+    RETURNS TABLE (
+		site_id INT,
+		dept_id INT,
+		dept_name VARCHAR(100),
+        site_name VARCHAR(100),
+        site_group_name VARCHAR(100),
+        region_name VARCHAR(100),
+        opname_status VARCHAR(20),
+        last_opname_date TIMESTAMP,
+		last_opname_by VARCHAR(255),
+		total_count BIGINT
+    )
+    LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_user_region_id INT;
+		v_user_dept_id INT;
+		v_offset INT := GREATEST(COALESCE(_page_number,1)-1,0) * COALESCE(NULLIF(_limit,0),20);
+		v_search_in VARCHAR(10) := LOWER(COALESCE(_search_in, 'area'));
+		v_user_position VARCHAR(100) := LOWER(COALESCE(_position,''));
+	BEGIN
+		-- Fetch the user's department and region context
+		SELECT r.id, d.id
+		INTO v_user_region_id, v_user_dept_id
+		FROM "User" AS u
+		LEFT JOIN "Site" AS s ON u.site_id = s.id
+		LEFT JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+		LEFT JOIN "Region" AS r ON sg.region_id = r.id
+		LEFT JOIN "Department" AS d ON LOWER(u.department) = LOWER(d.dept_name)
+		WHERE u.user_id = _user_id;
+
+		IF v_search_in = 'ho' THEN
+			-- HO MODE: Show departments only (site_name = NULL)
+			RETURN QUERY
+			SELECT
+				d.site_id::INT AS site_id, -- The department's head office
+				d.id::INT AS dept_id,
+				d.dept_name::VARCHAR(100),
+				NULL::VARCHAR(100) AS site_name,
+				NULL::VARCHAR(100) AS site_group_name,
+				NULL::VARCHAR(100) AS region_name,
+				COALESCE(lo.session_status, 'Outdated')::VARCHAR(20) AS opname_status,
+				lo.session_end_date::TIMESTAMP AS last_opname_date,
+				lo.created_by::VARCHAR(255) AS last_opname_by,
+				COUNT(*) OVER()::BIGINT AS total_count
+			FROM "Department" AS d
+			INNER JOIN "Site" AS hs ON d.site_id = hs.id
+			LEFT JOIN LATERAL get_latest_opname_status(NULL, d.id) lo ON TRUE
+			WHERE 
+				-- Access control: admins see all, others see only their department
+				(is_admin_position(v_user_position) OR d.id = v_user_dept_id)
+				-- Filters
+				AND (_site_name IS NULL OR _site_name = '' OR hs.site_name ILIKE '%'||_site_name||'%') -- Picks one head office when there are several
+				AND (_dept_name IS NULL OR _dept_name = '' OR d.dept_name ILIKE '%'||_dept_name||'%')
+				AND (_created_by IS NULL OR _created_by = '' OR lo.created_by ILIKE '%'||_created_by||'%')
+				AND (_from_date IS NULL OR lo.session_end_date >= _from_date)
+				AND (_end_date IS NULL OR lo.session_end_date <= _end_date)
+				AND (_opname_status IS NULL OR _opname_status = '' OR COALESCE(lo.session_status, 'Outdated') = _opname_status)
+			ORDER BY d.dept_name
+			LIMIT COALESCE(NULLIF(_limit,0), 20) OFFSET v_offset;
+		ELSE
+			-- AREA MODE: Show sites only (dept_name = NULL)  
+			RETURN QUERY
+			SELECT
+				s.id::INT AS site_id,
+				NULL::INT AS dept_id,
+				NULL::VARCHAR(100) AS dept_name,
+				s.site_name::VARCHAR(100),
+				sg.site_group_name::VARCHAR(100),
+				r.region_name::VARCHAR(100),
+				COALESCE(lo.session_status, 'Outdated')::VARCHAR(20) AS opname_status,
+				lo.session_end_date::TIMESTAMP AS last_opname_date,
+				lo.created_by::VARCHAR(255) AS last_opname_by,
+				COUNT(*) OVER()::BIGINT AS total_count
+			FROM "Site" AS s
+			INNER JOIN "SiteGroup" AS sg ON s.site_group_id = sg.id
+			INNER JOIN "Region" AS r ON sg.region_id = r.id
+			LEFT JOIN "SubSite" AS ss ON (_sub_site_name IS NOT NULL AND _sub_site_name <> '') AND ss.site_id = s.id
+			LEFT JOIN LATERAL get_latest_opname_status(s.id, NULL) lo ON TRUE
+			WHERE
+				-- Access control: admins see all, area users see only their region and must be managers
+				(is_admin_position(v_user_position)
+				 OR (v_user_position IN ('admin staff general affairs', 'area manager') AND r.id = v_user_region_id))
+				-- Filters
+				AND (_site_group_name IS NULL OR _site_group_name = '' OR sg.site_group_name ILIKE '%'||_site_group_name||'%')
+				AND (_site_name IS NULL OR _site_name = '' OR s.site_name ILIKE '%'||_site_name||'%')
+				AND (_sub_site_name IS NULL OR _sub_site_name = '' OR ss.sub_site_name ILIKE '%'||_sub_site_name||'%')
+				AND (_created_by IS NULL OR _created_by = '' OR lo.created_by ILIKE '%'||_created_by||'%')
+				AND (_from_date IS NULL OR lo.session_end_date >= _from_date)
+				AND (_end_date IS NULL OR lo.session_end_date <= _end_date)
+				AND (_opname_status IS NULL OR _opname_status = '' OR COALESCE(lo.session_status, 'Outdated') = _opname_status)
+			ORDER BY s.site_name
+			LIMIT COALESCE(NULLIF(_limit,0), 20) OFFSET v_offset;
+		END IF;
+	END;
+$$;
+
+-- set_action_notes updates the action notes for an asset change
+CREATE OR REPLACE PROCEDURE public.set_action_notes(
+	_asset_tag VARCHAR(12),
+	_session_id INT,
+	_current_user_id INT, -- The ID of the user making the request (from JWT)
+	_action_notes TEXT
+)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		-- Security check: only admins can update action notes
+		IF NOT EXISTS (
+			SELECT 1
+			FROM "User"
+			WHERE user_id = _current_user_id AND is_admin_position(position)
+		) THEN
+			RAISE EXCEPTION 'User is not authorized to update action notes';
+		END IF;
+
+		UPDATE "AssetChanges"
+		SET action_notes = _action_notes
+		WHERE asset_tag = _asset_tag AND session_id = _session_id;
+	END;
+$$;
+
+-- delete_action_notes deletes the action notes for an asset change
+CREATE OR REPLACE PROCEDURE public.delete_action_notes(
+	_asset_tag VARCHAR(12),
+	_session_id INT,
+	_current_user_id INT -- The ID of the user making the request (from JWT)
+)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		-- Security check: only admins can delete action notes
+		IF NOT EXISTS (
+			SELECT 1
+			FROM "User"
+			WHERE user_id = _current_user_id AND is_admin_position(position)
+		) THEN
+			RAISE EXCEPTION 'User is not authorized to delete action notes';
+		END IF;
+
+		UPDATE "AssetChanges"
+		SET action_notes = ''
+		WHERE asset_tag = _asset_tag AND session_id = _session_id;
+	END;
+$$;
@@ -0,0 +1,258 @@
+-- Restores apply_directory_sync of 0007_directory_sync, create_user and set_user_active of 0008_user_admin and
+-- provision_oidc_user of 0006_oidc_identity, then drops the user source and the manual (de)activation flag.
+DROP FUNCTION IF EXISTS public.is_user_active(INT);
+
+-- apply_directory_sync upserts every user of the directory and deactivates the active users missing from it, atomically.
+-- _users is a JSON array of objects with user_id, username, email, first_name, last_name, position, department, division,
+-- site_id, cost_center_id, ou_code and optionally skip_reason. Users referring to an unknown site or cost center, or taking another user's username, are skipped.
+-- The assets of deactivated users are flagged for reassignment.
+-- To protect against a truncated export, nothing is applied when more than _max_deactivation_ratio of the active users would be deactivated.
+CREATE OR REPLACE FUNCTION public.apply_directory_sync(_run_id INT, _users JSONB, _max_deactivation_ratio NUMERIC)
+	RETURNS TABLE (
+		user_id INT,
+		username VARCHAR(255),
+		"action" VARCHAR(20),
+		changes JSONB,
+		flagged_assets INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_record JSONB;
+		v_user_id INT;
+		v_new JSONB;
+		v_old JSONB;
+		v_changes JSONB;
+		v_seen INT[] := ARRAY[]::INT[];
+		v_active_count INT;
+		v_leaver RECORD;
+		v_leaver_count INT;
+		v_flagged INT;
+	BEGIN
+		FOR v_record IN SELECT * FROM jsonb_array_elements(_users) LOOP
+			v_user_id := (v_record->>'user_id')::INT;
+			v_seen := array_append(v_seen, v_user_id);
+			v_new := jsonb_build_object(
+				'username', v_record->>'username',
+				'email', COALESCE(v_record->>'email', ''),
+				'first_name', COALESCE(v_record->>'first_name', ''),
+				'last_name', COALESCE(v_record->>'last_name', ''),
+				'position', COALESCE(v_record->>'position', ''),
+				'department', COALESCE(v_record->>'department', ''),
+				'division', COALESCE(v_record->>'division', ''),
+				'site_id', (v_record->>'site_id')::INT,
+				'cost_center_id', (v_record->>'cost_center_id')::INT,
+				'ou_code', COALESCE(v_record->>'ou_code', ''),
+				'is_active', TRUE
+			);
+
+			-- Records the service already rejected are only reported. They still count as seen, so a typo does not deactivate anyone.
+			IF v_record->>'skip_reason' IS NOT NULL THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', v_record->>'skip_reason'), 0;
+				CONTINUE;
+			END IF;
+			IF v_new->>'site_id' IS NOT NULL AND NOT EXISTS (SELECT 1 FROM "Site" AS s WHERE s.id = (v_new->>'site_id')::INT) THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', 'unknown site_id ' || (v_new->>'site_id')), 0;
+				CONTINUE;
+			END IF;
+			IF v_new->>'cost_center_id' IS NOT NULL AND NOT EXISTS (SELECT 1 FROM "CostCenter" AS cc WHERE cc.cost_center_id = (v_new->>'cost_center_id')::INT) THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', 'unknown cost_center_id ' || (v_new->>'cost_center_id')), 0;
+				CONTINUE;
+			END IF;
+			IF EXISTS (SELECT 1 FROM "User" AS u WHERE LOWER(u.username) = LOWER(v_new->>'username') AND u.user_id <> v_user_id) THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', 'username already taken by another user'), 0;
+				CONTINUE;
+			END IF;
+
+			SELECT jsonb_build_object(
+				'username', u.username,
+				'email', u.email,
+				'first_name', u.first_name,
+				'last_name', u.last_name,
+				'position', u.position,
+				'department', u.department,
+				'division', u.division,
+				'site_id', u.site_id,
+				'cost_center_id', u.cost_center_id,
+				'ou_code', u.ou_code,
+				'is_active', u.is_active
+			) INTO v_old
+			FROM "User" AS u
+			WHERE u.user_id = v_user_id;
+
+			-- New users get no password: they sign in through SSO until an admin sets one.
+			IF v_old IS NULL THEN
+				INSERT INTO "User" (user_id, username, email, password, first_name, last_name, position, department, division, site_id, cost_center_id, ou_code)
+				VALUES (
+					v_user_id, v_new->>'username', v_new->>'email', '', v_new->>'first_name', v_new->>'last_name', v_new->>'position',
+					v_new->>'department', v_new->>'division', (v_new->>'site_id')::INT, (v_new->>'cost_center_id')::INT, v_new->>'ou_code'
+				);
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'created'::VARCHAR(20), v_new - 'is_active', 0;
+				CONTINUE;
+			END IF;
+
+			SELECT jsonb_object_agg(n.key, jsonb_build_object('old', v_old->n.key, 'new', n.value))
+			INTO v_changes
+			FROM jsonb_each(v_new) AS n
+			WHERE v_old->n.key IS DISTINCT FROM n.value;
+
+			IF v_changes IS NULL THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'unchanged'::VARCHAR(20), NULL::JSONB, 0;
+				CONTINUE;
+			END IF;
+
+			UPDATE "User" AS u
+			SET username = v_new->>'username',
+				email = v_new->>'email',
+				first_name = v_new->>'first_name',
+				last_name = v_new->>'last_name',
+				position = v_new->>'position',
+				department = v_new->>'department',
+				division = v_new->>'division',
+				site_id = (v_new->>'site_id')::INT,
+				cost_center_id = (v_new->>'cost_center_id')::INT,
+				ou_code = v_new->>'ou_code',
+				is_active = TRUE,
+				deactivated_at = NULL
+			WHERE u.user_id = v_user_id;
+
+			RETURN QUERY SELECT
+				v_user_id,
+				(v_new->>'username')::VARCHAR(255),
+				(CASE WHEN (v_old->>'is_active')::BOOLEAN THEN 'updated' ELSE 'reactivated' END)::VARCHAR(20),
+				v_changes,
+				0;
+		END LOOP;
+
+		-- Everyone active who is not in the directory has left. The VACANT placeholder (user ID 1) is never deactivated.
+		SELECT COUNT(*) INTO v_active_count FROM "User" AS u WHERE u.is_active AND u.user_id <> 1;
+		SELECT COUNT(*) INTO v_leaver_count FROM "User" AS u WHERE u.is_active AND u.user_id <> 1 AND NOT (u.user_id = ANY(v_seen));
+		IF v_active_count > 0 AND v_leaver_count > v_active_count * _max_deactivation_ratio THEN
+			RAISE EXCEPTION 'Directory sync would deactivate % of % active users', v_leaver_count, v_active_count;
+		END IF;
+
+		FOR v_leaver IN
+			SELECT u.user_id AS id, u.username AS name
+			FROM "User" AS u
+			WHERE u.is_active AND u.user_id <> 1 AND NOT (u.user_id = ANY(v_seen))
+			ORDER BY u.user_id
+		LOOP
+			UPDATE "User" AS u SET is_active = FALSE, deactivated_at = NOW() WHERE u.user_id = v_leaver.id;
+
+			INSERT INTO "AssetReassignment" (asset_tag, previous_owner_id, sync_run_id)
+			SELECT a.asset_tag, v_leaver.id, _run_id
+			FROM "Asset" AS a
+			WHERE a.owner_id = v_leaver.id AND a.status <> 'Disposed'
+			ON CONFLICT (asset_tag) WHERE resolved_at IS NULL DO NOTHING;
+			GET DIAGNOSTICS v_flagged = ROW_COUNT;
+
+			RETURN QUERY SELECT v_leaver.id, v_leaver.name, 'deactivated'::VARCHAR(20), jsonb_build_object('is_active', jsonb_build_object('old', TRUE, 'new', FALSE)), v_flagged;
+		END LOOP;
+	END;
+$$;
+
+-- create_user creates a user and returns its ID. Without _user_id (the HR employee number) the next free ID is used.
+CREATE OR REPLACE FUNCTION public.create_user(
+	_user_id INT,
+	_username VARCHAR(255),
+	_email VARCHAR(255),
+	_password VARCHAR(255),
+	_first_name VARCHAR(255),
+	_last_name VARCHAR(255),
+	_position VARCHAR(100),
+	_department VARCHAR(100),
+	_division VARCHAR(100),
+	_site_id INT,
+	_cost_center_id INT,
+	_ou_code VARCHAR(5)
+)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_user_id INT := _user_id;
+	BEGIN
+		IF EXISTS (SELECT 1 FROM "User" AS u WHERE LOWER(u.username) = LOWER(_username)) THEN
+			RAISE EXCEPTION 'Username % is already taken', _username;
+		END IF;
+
+		IF v_user_id IS NULL THEN
+			-- Serialise concurrent creations so two of them cannot pick the same ID.
+			LOCK TABLE "User" IN SHARE ROW EXCLUSIVE MODE;
+			SELECT COALESCE(MAX(u.user_id), 1) + 1 INTO v_user_id FROM "User" AS u;
+		ELSIF EXISTS (SELECT 1 FROM "User" AS u WHERE u.user_id = v_user_id) THEN
+			RAISE EXCEPTION 'User ID % is already taken', v_user_id;
+		END IF;
+
+		INSERT INTO "User" (user_id, username, email, password, first_name, last_name, position, department, division, site_id, cost_center_id, ou_code)
+		VALUES (v_user_id, _username, COALESCE(_email, ''), _password, COALESCE(_first_name, ''), COALESCE(_last_name, ''), COALESCE(_position, ''),
+			COALESCE(_department, ''), COALESCE(_division, ''), _site_id, _cost_center_id, COALESCE(_ou_code, ''));
+
+		RETURN v_user_id;
+	END;
+$$;
+
+-- provision_oidc_user creates a user for an OIDC identity on its first login and returns the new user ID.
+-- Provisioned users have no password, so they can only sign in through SSO.
+CREATE OR REPLACE FUNCTION public.provision_oidc_user(
+	_subject VARCHAR(255),
+	_username VARCHAR(255),
+	_email VARCHAR(255),
+	_first_name VARCHAR(255),
+	_last_name VARCHAR(255),
+	_position VARCHAR(100),
+	_ou_code VARCHAR(5)
+) RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_user_id INT;
+	BEGIN
+		-- user_id is not generated by the table, serialize concurrent provisioning so two logins cannot pick the same ID.
+		LOCK TABLE "User" IN SHARE ROW EXCLUSIVE MODE;
+		SELECT COALESCE(MAX(u.user_id), 1) + 1 INTO v_user_id FROM "User" AS u;
+
+		INSERT INTO "User" (user_id, username, email, password, first_name, last_name, position, ou_code, oidc_subject)
+		VALUES (v_user_id, _username, COALESCE(_email, ''), '', COALESCE(_first_name, ''), COALESCE(_last_name, ''), COALESCE(_position, ''), COALESCE(_ou_code, ''), _subject);
+
+		RETURN v_user_id;
+	END;
+$$;
+
+-- set_user_active deactivates or reactivates a user and returns the number of its assets flagged for reassignment.
+-- Like the directory sync, deactivating flags the user's assets so they get a new owner.
+CREATE OR REPLACE FUNCTION public.set_user_active(_user_id INT, _is_active BOOLEAN)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_flagged INT := 0;
+	BEGIN
+		IF _user_id = 1 THEN
+			RAISE EXCEPTION 'The VACANT placeholder user cannot be changed';
+		END IF;
+
+		UPDATE "User" AS u
+		SET is_active = _is_active,
+			deactivated_at = CASE WHEN _is_active THEN NULL ELSE COALESCE(u.deactivated_at, NOW()) END
+		WHERE u.user_id = _user_id;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'User with ID % not found', _user_id;
+		END IF;
+
+		IF NOT _is_active THEN
+			INSERT INTO "AssetReassignment" (asset_tag, previous_owner_id)
+			SELECT a.asset_tag, _user_id
+			FROM "Asset" AS a
+			WHERE a.owner_id = _user_id AND a.status <> 'Disposed'
+			ON CONFLICT (asset_tag) WHERE resolved_at IS NULL DO NOTHING;
+			GET DIAGNOSTICS v_flagged = ROW_COUNT;
+		END IF;
+
+		RETURN v_flagged;
+	END;
+$$;
+
+ALTER TABLE "User" DROP COLUMN IF EXISTS "is_active_manual";
+ALTER TABLE "User" DROP COLUMN IF EXISTS "source";
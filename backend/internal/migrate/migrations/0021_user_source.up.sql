@@ -0,0 +1,319 @@
+-- Directory sync overrides: the sync reactivated every user listed in the directory and deactivated every other active user.
+-- It undid the deactivations made by admins and deactivated the users created through the API or SSO, flagging their assets.
+-- "User".source records who created a user, and is_active_manual protects an admin's (de)activation from the sync.
+
+-- Seeded users come from the HR export, so the directory is the default source.
+ALTER TABLE "User" ADD COLUMN "source" VARCHAR(10) NOT NULL DEFAULT 'directory' CHECK ("source" IN ('directory', 'api', 'sso'));
+-- TRUE while an admin deactivated or reactivated the user and the directory has not caught up yet.
+ALTER TABLE "User" ADD COLUMN "is_active_manual" BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Users whose latest (de)activation in the audit log was made by an admin, not by a sync run, keep it.
+UPDATE "User" AS u
+SET is_active_manual = TRUE
+FROM (
+	SELECT DISTINCT ON (al.entity_id) al.entity_id, al."after"
+	FROM "AuditLog" AS al
+	WHERE al.entity_type = 'user' AND al."action" IN ('user.deactivate', 'user.reactivate')
+	ORDER BY al.entity_id, al.occurred_at DESC, al.id DESC
+) AS latest
+WHERE latest.entity_id = u.user_id::TEXT AND NOT (latest."after" ? 'sync_run_id');
+
+-- Users created through the API or provisioned through SSO so far are found in the audit log.
+UPDATE "User" AS u
+SET "source" = 'api'
+FROM "AuditLog" AS al
+WHERE al."action" = 'user.create' AND al.entity_type = 'user' AND al.entity_id = u.user_id::TEXT;
+
+UPDATE "User" AS u
+SET "source" = 'sso'
+FROM "AuditLog" AS al
+WHERE al."action" = 'auth.sso_provision' AND al.entity_type = 'user' AND LOWER(al.entity_id) = LOWER(u.username);
+
+-- apply_directory_sync upserts every user of the directory and deactivates the active users missing from it, atomically.
+-- _users is a JSON array of objects with user_id, username, email, first_name, last_name, position, department, division,
+-- site_id, cost_center_id, ou_code and optionally skip_reason. Users referring to an unknown site or cost center, or taking another user's username, are skipped.
+-- The assets of deactivated users are flagged for reassignment.
+-- Only users managed by the directory (source 'directory') are deactivated, and the (de)activations of admins are kept, see is_active_manual.
+-- To protect against a truncated export, nothing is applied when more than _max_deactivation_ratio of the active users would be deactivated.
+CREATE OR REPLACE FUNCTION public.apply_directory_sync(_run_id INT, _users JSONB, _max_deactivation_ratio NUMERIC)
+	RETURNS TABLE (
+		user_id INT,
+		username VARCHAR(255),
+		"action" VARCHAR(20),
+		changes JSONB,
+		flagged_assets INT
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_record JSONB;
+		v_user_id INT;
+		v_new JSONB;
+		v_old JSONB;
+		v_changes JSONB;
+		v_manual BOOLEAN;
+		v_seen INT[] := ARRAY[]::INT[];
+		v_active_count INT;
+		v_leaver RECORD;
+		v_leaver_count INT;
+		v_flagged INT;
+	BEGIN
+		FOR v_record IN SELECT * FROM jsonb_array_elements(_users) LOOP
+			v_user_id := (v_record->>'user_id')::INT;
+			v_seen := array_append(v_seen, v_user_id);
+			v_new := jsonb_build_object(
+				'username', v_record->>'username',
+				'email', COALESCE(v_record->>'email', ''),
+				'first_name', COALESCE(v_record->>'first_name', ''),
+				'last_name', COALESCE(v_record->>'last_name', ''),
+				'position', COALESCE(v_record->>'position', ''),
+				'department', COALESCE(v_record->>'department', ''),
+				'division', COALESCE(v_record->>'division', ''),
+				'site_id', (v_record->>'site_id')::INT,
+				'cost_center_id', (v_record->>'cost_center_id')::INT,
+				'ou_code', COALESCE(v_record->>'ou_code', ''),
+				'is_active', TRUE
+			);
+
+			-- Records the service already rejected are only reported. They still count as seen, so a typo does not deactivate anyone.
+			IF v_record->>'skip_reason' IS NOT NULL THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', v_record->>'skip_reason'), 0;
+				CONTINUE;
+			END IF;
+			IF v_new->>'site_id' IS NOT NULL AND NOT EXISTS (SELECT 1 FROM "Site" AS s WHERE s.id = (v_new->>'site_id')::INT) THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', 'unknown site_id ' || (v_new->>'site_id')), 0;
+				CONTINUE;
+			END IF;
+			IF v_new->>'cost_center_id' IS NOT NULL AND NOT EXISTS (SELECT 1 FROM "CostCenter" AS cc WHERE cc.cost_center_id = (v_new->>'cost_center_id')::INT) THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', 'unknown cost_center_id ' || (v_new->>'cost_center_id')), 0;
+				CONTINUE;
+			END IF;
+			IF EXISTS (SELECT 1 FROM "User" AS u WHERE LOWER(u.username) = LOWER(v_new->>'username') AND u.user_id <> v_user_id) THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'skipped'::VARCHAR(20), jsonb_build_object('error', 'username already taken by another user'), 0;
+				CONTINUE;
+			END IF;
+
+			SELECT jsonb_build_object(
+				'username', u.username,
+				'email', u.email,
+				'first_name', u.first_name,
+				'last_name', u.last_name,
+				'position', u.position,
+				'department', u.department,
+				'division', u.division,
+				'site_id', u.site_id,
+				'cost_center_id', u.cost_center_id,
+				'ou_code', u.ou_code,
+				'is_active', u.is_active
+			), u.is_active_manual INTO v_old, v_manual
+			FROM "User" AS u
+			WHERE u.user_id = v_user_id;
+
+			-- New users get no password: they sign in through SSO until an admin sets one.
+			IF v_old IS NULL THEN
+				INSERT INTO "User" (user_id, username, email, password, first_name, last_name, position, department, division, site_id, cost_center_id, ou_code, "source")
+				VALUES (
+					v_user_id, v_new->>'username', v_new->>'email', '', v_new->>'first_name', v_new->>'last_name', v_new->>'position',
+					v_new->>'department', v_new->>'division', (v_new->>'site_id')::INT, (v_new->>'cost_center_id')::INT, v_new->>'ou_code', 'directory'
+				);
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'created'::VARCHAR(20), v_new - 'is_active', 0;
+				CONTINUE;
+			END IF;
+
+			-- A user an admin deactivated stays deactivated, the directory only keeps their details up to date.
+			IF v_manual AND NOT (v_old->>'is_active')::BOOLEAN THEN
+				v_new := jsonb_set(v_new, '{is_active}', 'false'::JSONB);
+			END IF;
+
+			-- Users listed in the directory are managed by it, even when they were created through the API or SSO.
+			-- An admin's reactivation no longer needs protecting once the directory lists the user.
+			UPDATE "User" AS u
+			SET "source" = 'directory',
+				is_active_manual = u.is_active_manual AND NOT u.is_active
+			WHERE u.user_id = v_user_id AND (u."source" <> 'directory' OR (u.is_active_manual AND u.is_active));
+
+			SELECT jsonb_object_agg(n.key, jsonb_build_object('old', v_old->n.key, 'new', n.value))
+			INTO v_changes
+			FROM jsonb_each(v_new) AS n
+			WHERE v_old->n.key IS DISTINCT FROM n.value;
+
+			IF v_changes IS NULL THEN
+				RETURN QUERY SELECT v_user_id, (v_new->>'username')::VARCHAR(255), 'unchanged'::VARCHAR(20), NULL::JSONB, 0;
+				CONTINUE;
+			END IF;
+
+			UPDATE "User" AS u
+			SET username = v_new->>'username',
+				email = v_new->>'email',
+				first_name = v_new->>'first_name',
+				last_name = v_new->>'last_name',
+				position = v_new->>'position',
+				department = v_new->>'department',
+				division = v_new->>'division',
+				site_id = (v_new->>'site_id')::INT,
+				cost_center_id = (v_new->>'cost_center_id')::INT,
+				ou_code = v_new->>'ou_code',
+				is_active = (v_new->>'is_active')::BOOLEAN,
+				deactivated_at = CASE WHEN (v_new->>'is_active')::BOOLEAN THEN NULL ELSE u.deactivated_at END
+			WHERE u.user_id = v_user_id;
+
+			RETURN QUERY SELECT
+				v_user_id,
+				(v_new->>'username')::VARCHAR(255),
+				(CASE WHEN (v_old->>'is_active')::BOOLEAN OR NOT (v_new->>'is_active')::BOOLEAN THEN 'updated' ELSE 'reactivated' END)::VARCHAR(20),
+				v_changes,
+				0;
+		END LOOP;
+
+		-- A user an admin deactivated who is no longer in the directory has left as well: the directory may reactivate them if they return.
+		UPDATE "User" AS u
+		SET is_active_manual = FALSE
+		WHERE u.is_active_manual AND NOT u.is_active AND u."source" = 'directory' AND NOT (u.user_id = ANY(v_seen));
+
+		-- Everyone active and managed by the directory who is not in it has left. Users created through the API or SSO,
+		-- users an admin reactivated and the VACANT placeholder (user ID 1) are never deactivated.
+		SELECT COUNT(*) INTO v_active_count FROM "User" AS u WHERE u.is_active AND u.user_id <> 1 AND u."source" = 'directory' AND NOT u.is_active_manual;
+		SELECT COUNT(*) INTO v_leaver_count FROM "User" AS u WHERE u.is_active AND u.user_id <> 1 AND u."source" = 'directory' AND NOT u.is_active_manual AND NOT (u.user_id = ANY(v_seen));
+		IF v_active_count > 0 AND v_leaver_count > v_active_count * _max_deactivation_ratio THEN
+			RAISE EXCEPTION 'Directory sync would deactivate % of % active users', v_leaver_count, v_active_count;
+		END IF;
+
+		FOR v_leaver IN
+			SELECT u.user_id AS id, u.username AS name
+			FROM "User" AS u
+			WHERE u.is_active AND u.user_id <> 1 AND u."source" = 'directory' AND NOT u.is_active_manual AND NOT (u.user_id = ANY(v_seen))
+			ORDER BY u.user_id
+		LOOP
+			UPDATE "User" AS u SET is_active = FALSE, deactivated_at = NOW() WHERE u.user_id = v_leaver.id;
+
+			INSERT INTO "AssetReassignment" (asset_tag, previous_owner_id, sync_run_id)
+			SELECT a.asset_tag, v_leaver.id, _run_id
+			FROM "Asset" AS a
+			WHERE a.owner_id = v_leaver.id AND a.status <> 'Disposed'
+			ON CONFLICT (asset_tag) WHERE resolved_at IS NULL DO NOTHING;
+			GET DIAGNOSTICS v_flagged = ROW_COUNT;
+
+			RETURN QUERY SELECT v_leaver.id, v_leaver.name, 'deactivated'::VARCHAR(20), jsonb_build_object('is_active', jsonb_build_object('old', TRUE, 'new', FALSE)), v_flagged;
+		END LOOP;
+	END;
+$$;
+
+-- create_user creates a user and returns its ID. The directory sync leaves the user alone unless the directory lists them. Without _user_id (the HR employee number) the next free ID is used.
+CREATE OR REPLACE FUNCTION public.create_user(
+	_user_id INT,
+	_username VARCHAR(255),
+	_email VARCHAR(255),
+	_password VARCHAR(255),
+	_first_name VARCHAR(255),
+	_last_name VARCHAR(255),
+	_position VARCHAR(100),
+	_department VARCHAR(100),
+	_division VARCHAR(100),
+	_site_id INT,
+	_cost_center_id INT,
+	_ou_code VARCHAR(5)
+)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_user_id INT := _user_id;
+	BEGIN
+		IF EXISTS (SELECT 1 FROM "User" AS u WHERE LOWER(u.username) = LOWER(_username)) THEN
+			RAISE EXCEPTION 'Username % is already taken', _username;
+		END IF;
+
+		IF v_user_id IS NULL THEN
+			-- Serialise concurrent creations so two of them cannot pick the same ID.
+			LOCK TABLE "User" IN SHARE ROW EXCLUSIVE MODE;
+			SELECT COALESCE(MAX(u.user_id), 1) + 1 INTO v_user_id FROM "User" AS u;
+		ELSIF EXISTS (SELECT 1 FROM "User" AS u WHERE u.user_id = v_user_id) THEN
+			RAISE EXCEPTION 'User ID % is already taken', v_user_id;
+		END IF;
+
+		INSERT INTO "User" (user_id, username, email, password, first_name, last_name, position, department, division, site_id, cost_center_id, ou_code, "source")
+		VALUES (v_user_id, _username, COALESCE(_email, ''), _password, COALESCE(_first_name, ''), COALESCE(_last_name, ''), COALESCE(_position, ''),
+			COALESCE(_department, ''), COALESCE(_division, ''), _site_id, _cost_center_id, COALESCE(_ou_code, ''), 'api');
+
+		RETURN v_user_id;
+	END;
+$$;
+
+-- provision_oidc_user creates a user for an OIDC identity on its first login and returns the new user ID.
+-- Provisioned users have no password, so they can only sign in through SSO.
+CREATE OR REPLACE FUNCTION public.provision_oidc_user(
+	_subject VARCHAR(255),
+	_username VARCHAR(255),
+	_email VARCHAR(255),
+	_first_name VARCHAR(255),
+	_last_name VARCHAR(255),
+	_position VARCHAR(100),
+	_ou_code VARCHAR(5)
+) RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_user_id INT;
+	BEGIN
+		-- user_id is not generated by the table, serialize concurrent provisioning so two logins cannot pick the same ID.
+		LOCK TABLE "User" IN SHARE ROW EXCLUSIVE MODE;
+		SELECT COALESCE(MAX(u.user_id), 1) + 1 INTO v_user_id FROM "User" AS u;
+
+		INSERT INTO "User" (user_id, username, email, password, first_name, last_name, position, ou_code, oidc_subject, "source")
+		VALUES (v_user_id, _username, COALESCE(_email, ''), '', COALESCE(_first_name, ''), COALESCE(_last_name, ''), COALESCE(_position, ''), COALESCE(_ou_code, ''), _subject, 'sso');
+
+		RETURN v_user_id;
+	END;
+$$;
+
+-- set_user_active deactivates or reactivates a user and returns the number of its assets flagged for reassignment.
+-- Like the directory sync, deactivating flags the user's assets so they get a new owner.
+-- The directory sync does not undo the change until the directory agrees with it, see apply_directory_sync.
+CREATE OR REPLACE FUNCTION public.set_user_active(_user_id INT, _is_active BOOLEAN)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_flagged INT := 0;
+	BEGIN
+		IF _user_id = 1 THEN
+			RAISE EXCEPTION 'The VACANT placeholder user cannot be changed';
+		END IF;
+
+		UPDATE "User" AS u
+		SET is_active = _is_active,
+			is_active_manual = TRUE,
+			deactivated_at = CASE WHEN _is_active THEN NULL ELSE COALESCE(u.deactivated_at, NOW()) END
+		WHERE u.user_id = _user_id;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'User with ID % not found', _user_id;
+		END IF;
+
+		IF NOT _is_active THEN
+			INSERT INTO "AssetReassignment" (asset_tag, previous_owner_id)
+			SELECT a.asset_tag, _user_id
+			FROM "Asset" AS a
+			WHERE a.owner_id = _user_id AND a.status <> 'Disposed'
+			ON CONFLICT (asset_tag) WHERE resolved_at IS NULL DO NOTHING;
+			GET DIAGNOSTICS v_flagged = ROW_COUNT;
+		END IF;
+
+		RETURN v_flagged;
+	END;
+$$;
+
+-- is_user_active checks whether a user may still use the system, for tokens issued before a deactivation.
+CREATE OR REPLACE FUNCTION public.is_user_active(_user_id INT)
+	RETURNS BOOLEAN
+	LANGUAGE plpgsql
+	STABLE
+AS $$
+	BEGIN
+		RETURN EXISTS (
+			SELECT 1
+			FROM "User" AS u
+			WHERE u.user_id = _user_id AND u.is_active
+		);
+	END;
+$$;
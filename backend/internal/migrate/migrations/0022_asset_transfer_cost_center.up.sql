@@ -0,0 +1,54 @@
+-- Approving an asset transfer changed the cost center of the receiving owner to the transfer's. That moved all of the
+-- owner's assets, and overwrote HR data the next directory sync put back. Approval now only applies the transfer to the asset.
+
+-- approve_asset_transfer approves a pending transfer and applies it to the asset master.
+-- The cost center stays recorded on the transfer (and its BAST): a user's cost center is HR data, kept by the directory sync and the admins.
+-- An open reassignment of the asset (see 0007_directory_sync) is resolved by the asset_owner_changed trigger.
+CREATE OR REPLACE PROCEDURE public.approve_asset_transfer(_transfer_id INT, _reviewer_id INT, _review_notes TEXT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_transfer "AssetTransfer"%ROWTYPE;
+	BEGIN
+		SELECT * INTO v_transfer FROM "AssetTransfer" AS t WHERE t.id = _transfer_id FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Asset transfer % not found', _transfer_id;
+		END IF;
+
+		IF v_transfer.status <> 'Pending' THEN
+			RAISE EXCEPTION 'Asset transfer % is not pending', _transfer_id;
+		END IF;
+
+		-- The asset must still be where the transfer was requested from, otherwise the request is out of date.
+		PERFORM 1
+		FROM "Asset" AS a
+		WHERE a.asset_tag = v_transfer.asset_tag
+			AND a.owner_id = v_transfer.from_owner_id
+			AND a.sub_site_id IS NOT DISTINCT FROM v_transfer.from_sub_site_id
+			AND a.dept_id IS NOT DISTINCT FROM v_transfer.from_dept_id
+			AND a.site_id = v_transfer.from_site_id
+			AND a.status <> 'Disposed'
+		FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Asset % has changed since transfer % was requested', v_transfer.asset_tag, _transfer_id;
+		END IF;
+
+		IF NOT EXISTS (SELECT 1 FROM "User" AS u WHERE u.user_id = v_transfer.to_owner_id AND u.is_active) THEN
+			RAISE EXCEPTION 'Transfer recipient % not found or deactivated', v_transfer.to_owner_id;
+		END IF;
+
+		UPDATE "Asset" AS a
+		SET
+			owner_id = v_transfer.to_owner_id,
+			sub_site_id = v_transfer.to_sub_site_id,
+			dept_id = v_transfer.to_dept_id,
+			site_id = v_transfer.to_site_id
+		WHERE a.asset_tag = v_transfer.asset_tag;
+
+		UPDATE "AssetTransfer" AS t
+		SET status = 'Approved', reviewer_id = _reviewer_id, reviewed_at = NOW(), review_notes = COALESCE(_review_notes, '')
+		WHERE t.id = _transfer_id;
+	END;
+$$;
@@ -0,0 +1,33 @@
+-- request_opname_loss_approval only takes escalated sessions, but reported a missing submitted or escalated session.
+
+-- request_opname_loss_approval verifies an escalated session on behalf of its second reviewer like approve_opname_session,
+-- but moves it to 'Loss Review' and records the loss waiting for approval instead of verifying it.
+CREATE OR REPLACE PROCEDURE public.request_opname_loss_approval(_session_id INT, _reviewer_id INT, _loss_value BIGINT, _loss_threshold BIGINT)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		_current_status VARCHAR;
+	BEGIN
+		SELECT "status" INTO _current_status
+		FROM "OpnameSession"
+		WHERE id = _session_id AND "status" = 'Escalated'
+		FOR UPDATE;
+
+		IF _current_status IS NULL THEN
+			RAISE EXCEPTION 'No escalated opname session found with ID: %', _session_id;
+		END IF;
+
+		IF NOT is_opname_session_reviewer(_session_id, _reviewer_id) THEN
+			RAISE EXCEPTION 'Only the next reviewer on the approval path can review opname session with ID: %', _session_id;
+		END IF;
+
+		UPDATE "OpnameSession"
+		SET l1_reviewer_id = _reviewer_id,
+			"status" = 'Loss Review',
+			l1_reviewed_at = NOW()
+		WHERE id = _session_id;
+
+		INSERT INTO "OpnameLossApproval" (session_id, loss_value, loss_threshold, requested_by)
+		VALUES (_session_id, _loss_value, _loss_threshold, _reviewer_id);
+	END;
+$$;
@@ -0,0 +1,51 @@
+-- Drops the claimed login attempts and restores get_login_throttle of 0005_login_throttling.
+-- Attempts still pending are counted as failed password checks.
+DROP FUNCTION IF EXISTS public.finish_login_attempt(BIGINT, BOOLEAN, VARCHAR);
+DROP FUNCTION IF EXISTS public.start_login_attempt(VARCHAR, VARCHAR, INT);
+
+UPDATE "LoginAttempt" SET reason = 'invalid_credentials' WHERE reason = 'pending';
+
+-- get_login_throttle returns the state the login throttle decides on, counting only failed password checks:
+-- the failures of the username since its last success or unlock within the window, the failures from the IP within the window,
+-- when the last username failure and the oldest IP failure happened, and until when the username is locked.
+CREATE OR REPLACE FUNCTION public.get_login_throttle(_username VARCHAR(255), _ip_address VARCHAR(45), _window_seconds INT)
+	RETURNS TABLE (
+		username_failures INT,
+		last_failure_at TIMESTAMP WITH TIME ZONE,
+		ip_failures INT,
+		oldest_ip_failure_at TIMESTAMP WITH TIME ZONE,
+		locked_until TIMESTAMP WITH TIME ZONE
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_username VARCHAR(255) := LOWER(TRIM(_username));
+		v_window_start TIMESTAMP WITH TIME ZONE := NOW() - make_interval(secs => _window_seconds);
+		v_since TIMESTAMP WITH TIME ZONE;
+	BEGIN
+		SELECT GREATEST(
+			v_window_start,
+			(SELECT MAX(la.attempted_at) FROM "LoginAttempt" AS la WHERE la.username = v_username AND la.succeeded),
+			(SELECT al.unlocked_at FROM "AccountLockout" AS al WHERE al.username = v_username)
+		) INTO v_since;
+
+		RETURN QUERY
+		SELECT
+			user_failures.total::INT,
+			user_failures.latest,
+			ip_failures.total::INT,
+			ip_failures.oldest,
+			(SELECT al.locked_until FROM "AccountLockout" AS al WHERE al.username = v_username AND al.locked_until > NOW())
+		FROM
+			(
+				SELECT COUNT(*) AS total, MAX(la.attempted_at) AS latest
+				FROM "LoginAttempt" AS la
+				WHERE la.username = v_username AND la.reason = 'invalid_credentials' AND la.attempted_at > v_since
+			) AS user_failures,
+			(
+				SELECT COUNT(*) AS total, MIN(la.attempted_at) AS oldest
+				FROM "LoginAttempt" AS la
+				WHERE la.ip_address = _ip_address AND la.reason = 'invalid_credentials' AND la.attempted_at > v_window_start
+			) AS ip_failures;
+	END;
+$$;
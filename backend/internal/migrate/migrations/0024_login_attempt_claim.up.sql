@@ -0,0 +1,95 @@
+-- The login throttle read the failures of a username, checked the password, then recorded the failure: concurrent bad logins
+-- all passed the check before any failure was stored, so more passwords than auth.max_failed_attempts could be tried.
+-- start_login_attempt now checks and claims an attempt in one call, serialised per username and per client IP. The claimed
+-- attempt is 'pending' until finish_login_attempt records its outcome, and counts as a failure meanwhile.
+
+-- get_login_throttle returns the state the login throttle decides on, counting only failed and pending password checks:
+-- the failures of the username since its last success or unlock within the window, the failures from the IP within the window,
+-- when the last username failure and the oldest IP failure happened, and until when the username is locked.
+CREATE OR REPLACE FUNCTION public.get_login_throttle(_username VARCHAR(255), _ip_address VARCHAR(45), _window_seconds INT)
+	RETURNS TABLE (
+		username_failures INT,
+		last_failure_at TIMESTAMP WITH TIME ZONE,
+		ip_failures INT,
+		oldest_ip_failure_at TIMESTAMP WITH TIME ZONE,
+		locked_until TIMESTAMP WITH TIME ZONE
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_username VARCHAR(255) := LOWER(TRIM(_username));
+		v_window_start TIMESTAMP WITH TIME ZONE := NOW() - make_interval(secs => _window_seconds);
+		v_since TIMESTAMP WITH TIME ZONE;
+	BEGIN
+		SELECT GREATEST(
+			v_window_start,
+			(SELECT MAX(la.attempted_at) FROM "LoginAttempt" AS la WHERE la.username = v_username AND la.succeeded),
+			(SELECT al.unlocked_at FROM "AccountLockout" AS al WHERE al.username = v_username)
+		) INTO v_since;
+
+		RETURN QUERY
+		SELECT
+			user_failures.total::INT,
+			user_failures.latest,
+			ip_failures.total::INT,
+			ip_failures.oldest,
+			(SELECT al.locked_until FROM "AccountLockout" AS al WHERE al.username = v_username AND al.locked_until > NOW())
+		FROM
+			(
+				SELECT COUNT(*) AS total, MAX(la.attempted_at) AS latest
+				FROM "LoginAttempt" AS la
+				WHERE la.username = v_username AND la.reason IN ('invalid_credentials', 'pending') AND la.attempted_at > v_since
+			) AS user_failures,
+			(
+				SELECT COUNT(*) AS total, MIN(la.attempted_at) AS oldest
+				FROM "LoginAttempt" AS la
+				WHERE la.ip_address = _ip_address AND la.reason IN ('invalid_credentials', 'pending') AND la.attempted_at > v_window_start
+			) AS ip_failures;
+	END;
+$$;
+
+-- start_login_attempt returns the throttle state of a username and client IP like get_login_throttle, and stores a 'pending'
+-- attempt counted by the next calls. Concurrent calls for the same username or IP wait for each other.
+CREATE OR REPLACE FUNCTION public.start_login_attempt(_username VARCHAR(255), _ip_address VARCHAR(45), _window_seconds INT)
+	RETURNS TABLE (
+		attempt_id BIGINT,
+		username_failures INT,
+		last_failure_at TIMESTAMP WITH TIME ZONE,
+		ip_failures INT,
+		oldest_ip_failure_at TIMESTAMP WITH TIME ZONE,
+		locked_until TIMESTAMP WITH TIME ZONE
+	)
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_username VARCHAR(255) := LOWER(TRIM(_username));
+		v_state RECORD;
+		v_attempt_id BIGINT;
+	BEGIN
+		-- Always the username first, then the IP, so two attempts never wait for each other in a cycle.
+		PERFORM pg_advisory_xact_lock(hashtext('login_username:' || v_username));
+		PERFORM pg_advisory_xact_lock(hashtext('login_ip:' || COALESCE(_ip_address, '')));
+
+		-- The state is read before the attempt is stored, so it counts the attempts made before this one only.
+		SELECT * INTO v_state FROM get_login_throttle(v_username, _ip_address, _window_seconds);
+
+		INSERT INTO "LoginAttempt" (username, ip_address, succeeded, reason)
+		VALUES (v_username, COALESCE(_ip_address, ''), FALSE, 'pending')
+		RETURNING id INTO v_attempt_id;
+
+		RETURN QUERY
+		SELECT v_attempt_id, v_state.username_failures, v_state.last_failure_at, v_state.ip_failures, v_state.oldest_ip_failure_at, v_state.locked_until;
+	END;
+$$;
+
+-- finish_login_attempt records the outcome of an attempt claimed by start_login_attempt. reason is empty for successful attempts.
+CREATE OR REPLACE FUNCTION public.finish_login_attempt(_attempt_id BIGINT, _succeeded BOOLEAN, _reason VARCHAR(50))
+	RETURNS VOID
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		UPDATE "LoginAttempt" AS la
+		SET succeeded = _succeeded, reason = COALESCE(_reason, '')
+		WHERE la.id = _attempt_id AND la.reason = 'pending';
+	END;
+$$;
@@ -0,0 +1,103 @@
+-- create_asset_transfer took a cost center for the transfer, but approving a transfer no longer changes the owner's cost
+-- center (see 0022_asset_transfer_cost_center): a requested cost center was recorded on the transfer and never applied.
+-- The parameter is dropped, the transfer records the new owner's cost center, and a cost center alone is no longer a change.
+
+DROP FUNCTION IF EXISTS public.create_asset_transfer(VARCHAR, INT, INT, INT, INT, INT, TEXT);
+
+-- create_asset_transfer requests the transfer of an asset and returns its ID.
+-- Unset destination fields keep the asset's current owner and location. The cost center recorded on the transfer is the
+-- new owner's: the asset follows the cost center of its owner, which only the directory sync and the admins change.
+CREATE OR REPLACE FUNCTION public.create_asset_transfer(
+	_asset_tag VARCHAR(12),
+	_requester_id INT,
+	_to_owner_id INT,
+	_to_sub_site_id INT,
+	_to_dept_id INT,
+	_reason TEXT
+)
+	RETURNS INT
+	LANGUAGE plpgsql
+AS $$
+	DECLARE
+		v_asset RECORD;
+		v_from_cost_center_id INT;
+		v_to_owner_id INT;
+		v_to_cost_center_id INT;
+		v_to_sub_site_id INT;
+		v_to_dept_id INT;
+		v_to_site_id INT;
+		v_id INT;
+	BEGIN
+		SELECT a.asset_tag, a.status, a.owner_id, a.sub_site_id, a.dept_id, a.site_id
+		INTO v_asset
+		FROM "Asset" AS a
+		WHERE a.asset_tag = _asset_tag
+		FOR UPDATE;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Asset with tag % not found', _asset_tag;
+		END IF;
+
+		IF v_asset.status = 'Disposed' THEN
+			RAISE EXCEPTION 'Asset % is disposed and cannot be transferred', _asset_tag;
+		END IF;
+
+		IF EXISTS (SELECT 1 FROM "AssetTransfer" AS t WHERE t.asset_tag = _asset_tag AND t.status = 'Pending') THEN
+			RAISE EXCEPTION 'Asset % already has a pending transfer', _asset_tag;
+		END IF;
+
+		IF _to_sub_site_id IS NOT NULL AND _to_dept_id IS NOT NULL THEN
+			RAISE EXCEPTION 'A transfer goes to either a sub-site or a department, not both';
+		END IF;
+
+		-- The receiving owner must be an active user. The VACANT placeholder takes the asset back into stock.
+		v_to_owner_id := COALESCE(_to_owner_id, v_asset.owner_id);
+		SELECT u.cost_center_id INTO v_to_cost_center_id
+		FROM "User" AS u
+		WHERE u.user_id = v_to_owner_id AND u.is_active;
+
+		IF NOT FOUND THEN
+			RAISE EXCEPTION 'Transfer recipient % not found or deactivated', v_to_owner_id;
+		END IF;
+
+		IF _to_sub_site_id IS NOT NULL THEN
+			SELECT ss.id, ss.site_id INTO v_to_sub_site_id, v_to_site_id FROM "SubSite" AS ss WHERE ss.id = _to_sub_site_id;
+			IF NOT FOUND THEN
+				RAISE EXCEPTION 'Location sub-site % not found', _to_sub_site_id;
+			END IF;
+		ELSIF _to_dept_id IS NOT NULL THEN
+			SELECT d.id, d.site_id INTO v_to_dept_id, v_to_site_id FROM "Department" AS d WHERE d.id = _to_dept_id;
+			IF NOT FOUND THEN
+				RAISE EXCEPTION 'Location department % not found', _to_dept_id;
+			END IF;
+		ELSE
+			v_to_sub_site_id := v_asset.sub_site_id;
+			v_to_dept_id := v_asset.dept_id;
+			v_to_site_id := v_asset.site_id;
+		END IF;
+
+		SELECT u.cost_center_id INTO v_from_cost_center_id FROM "User" AS u WHERE u.user_id = v_asset.owner_id;
+
+		IF v_to_owner_id = v_asset.owner_id
+			AND v_to_sub_site_id IS NOT DISTINCT FROM v_asset.sub_site_id
+			AND v_to_dept_id IS NOT DISTINCT FROM v_asset.dept_id
+			AND v_to_site_id = v_asset.site_id
+		THEN
+			RAISE EXCEPTION 'Transfer of asset % changes nothing', _asset_tag;
+		END IF;
+
+		INSERT INTO "AssetTransfer" (
+			asset_tag, reason, requester_id,
+			from_owner_id, from_cost_center_id, from_sub_site_id, from_dept_id, from_site_id,
+			to_owner_id, to_cost_center_id, to_sub_site_id, to_dept_id, to_site_id
+		)
+		VALUES (
+			_asset_tag, _reason, _requester_id,
+			v_asset.owner_id, v_from_cost_center_id, v_asset.sub_site_id, v_asset.dept_id, v_asset.site_id,
+			v_to_owner_id, v_to_cost_center_id, v_to_sub_site_id, v_to_dept_id, v_to_site_id
+		)
+		RETURNING id INTO v_id;
+
+		RETURN v_id;
+	END;
+$$;
@@ -0,0 +1,87 @@
+-- The chargeback report charged every asset to the cost center of its current owner, so an asset transferred after
+-- a session was verified moved that session's costs to the new owner. The report (internal/costcenter) now charges it to
+-- the cost center its owner had when the session was verified: the one an approved transfer moved it away from after
+-- that, or the current owner's if it has not been transferred since.
+
+DROP FUNCTION IF EXISTS public.get_cost_center_chargeback(TIMESTAMP, TIMESTAMP);
+
+-- get_chargeback_assets retrieves the latest record of every asset in the opname sessions verified in a period, with
+-- when its session was verified, the cost center of its current owner and the one of the owner given by the session, if any.
+CREATE OR REPLACE FUNCTION public.get_chargeback_assets(_from_date TIMESTAMP, _end_date TIMESTAMP)
+	RETURNS TABLE (
+		asset_tag VARCHAR(12),
+		cost BIGINT,
+		verified_at TIMESTAMP WITH TIME ZONE,
+		cost_center_id INT,
+		cost_center_name VARCHAR(100),
+		new_cost_center_id INT,
+		new_cost_center_name VARCHAR(100),
+		is_broken BOOLEAN,
+		is_missing BOOLEAN
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		WITH latest AS (
+			-- The latest verified record of each asset in the period
+			SELECT DISTINCT ON (ac.asset_tag) ac.asset_tag, os.l1_reviewed_at, ac."changes"
+			FROM "AssetChanges" AS ac
+			INNER JOIN "OpnameSession" AS os ON ac.session_id = os.id
+			WHERE os.status = 'Verified'
+				AND (_from_date IS NULL OR os.l1_reviewed_at >= _from_date)
+				AND (_end_date IS NULL OR os.l1_reviewed_at < _end_date + INTERVAL '1 day')
+			ORDER BY ac.asset_tag, os.l1_reviewed_at DESC, os.id DESC
+		),
+		effective AS (
+			SELECT
+				l.asset_tag,
+				COALESCE(a.total_cost, 0)::BIGINT AS cost,
+				l.l1_reviewed_at,
+				NULLIF(ou.cost_center_id, 0) AS owner_cost_center,
+				NULLIF(COALESCE((l."changes"->>'newOwnerCostCenter')::INT, nu.cost_center_id), 0) AS new_cost_center,
+				COALESCE(l."changes"->>'newStatus', a.status) = 'Down' AS is_broken,
+				COALESCE((l."changes"->>'newCondition')::INT, a.condition) = 2 AS is_missing
+			FROM latest AS l
+			INNER JOIN "Asset" AS a ON l.asset_tag = a.asset_tag
+			LEFT JOIN "User" AS ou ON a.owner_id = ou.user_id -- current owner
+			LEFT JOIN "User" AS nu ON nu.user_id = (l."changes"->>'newOwnerID')::INT -- owner given by the session, if changed
+		)
+		SELECT
+			e.asset_tag,
+			e.cost,
+			e.l1_reviewed_at,
+			e.owner_cost_center,
+			occ.cost_center_name,
+			e.new_cost_center,
+			ncc.cost_center_name,
+			e.is_broken,
+			e.is_missing
+		FROM effective AS e
+		LEFT JOIN "CostCenter" AS occ ON e.owner_cost_center = occ.cost_center_id
+		LEFT JOIN "CostCenter" AS ncc ON e.new_cost_center = ncc.cost_center_id
+		ORDER BY e.asset_tag;
+	END;
+$$;
+
+-- get_chargeback_transfers retrieves the transfers approved since _from_date with the cost center each asset left,
+-- ordered by asset and approval. The first one approved after a session was verified tells who held the asset then.
+CREATE OR REPLACE FUNCTION public.get_chargeback_transfers(_from_date TIMESTAMP)
+	RETURNS TABLE (
+		asset_tag VARCHAR(12),
+		approved_at TIMESTAMP WITH TIME ZONE,
+		from_cost_center_id INT,
+		from_cost_center_name VARCHAR(100)
+	)
+	LANGUAGE plpgsql
+AS $$
+	BEGIN
+		RETURN QUERY
+		SELECT t.asset_tag, t.reviewed_at, NULLIF(t.from_cost_center_id, 0), cc.cost_center_name
+		FROM "AssetTransfer" AS t
+		LEFT JOIN "CostCenter" AS cc ON t.from_cost_center_id = cc.cost_center_id
+		WHERE t.status = 'Approved'
+			AND (_from_date IS NULL OR t.reviewed_at >= _from_date)
+		ORDER BY t.asset_tag, t.reviewed_at, t.id;
+	END;
+$$;
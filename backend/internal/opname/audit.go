@@ -0,0 +1,45 @@
+// == Describes opname changes for the audit log ==
+package opname
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+)
+
+// sessionSnapshot is the audited state of a session.
+func sessionSnapshot(session *OpnameSession) map[string]any {
+	if session == nil {
+		return nil
+	}
+	return map[string]any{
+		"status":              session.Status,
+		"user_id":             session.UserID,
+		"site_id":             utils.SerializeNI(session.SiteID),
+		"dept_id":             utils.SerializeNI(session.DeptID),
+		"start_date":          session.StartDate,
+		"end_date":            utils.SerializeNS(session.EndDate),
+		"manager_reviewer_id": utils.SerializeNI(session.ManagerReviewerID),
+		"l1_reviewer_id":      utils.SerializeNI(session.L1ReviewerID),
+	}
+}
+
+// recordTransition audits a status change, reloading the session to capture its state after the change.
+func (service *Service) recordTransition(ctx context.Context, action string, before *OpnameSession) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	after, err := service.repo.GetSessionByID(ctx, before.ID)
+	if err != nil {
+		logger.Warn("failed to reload opname session for audit", "session_id", before.ID, "error", err)
+	}
+	audit.Record(ctx, audit.Event{
+		Action:     action,
+		EntityType: "opname_session",
+		EntityID:   strconv.Itoa(before.ID),
+		Before:     sessionSnapshot(before),
+		After:      sessionSnapshot(after),
+	})
+}
@@ -3,11 +3,15 @@ package opname
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/asset"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -15,12 +19,14 @@ import (
 
 type Handler struct {
 	service *Service
+	logger  *slog.Logger
 }
 
 // NewHandler creates a new Opname handler with the provided service.
-func NewHandler(service *Service) *Handler {
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
 	return &Handler{
 		service: service,
+		logger:  logger,
 	}
 }
 
@@ -33,39 +39,37 @@ type RequestWithLocation struct {
 func validateSessionID(sessionIDstr string) (int, error) {
 	sessionID, err := strconv.Atoi(sessionIDstr)
 	if err != nil || sessionID <= 0 {
-		log.Printf("⚠ Invalid session ID: %s", sessionIDstr)
-		return -1, err
+		return -1, ErrInvalidSessionID
 	}
 	return sessionID, nil
 }
 
+// errMissingUser is returned when the auth middleware did not place the user in the context.
+var errMissingUser = apperr.Unauthorized("unauthorized", "user unauthorized, user_id not found in context")
+
 // StartNewSessionHandler handles the creation of a new opname session.
 func (handler *Handler) StartNewSessionHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Bind the request body to RequestWithLocation struct
 	var request RequestWithLocation
 	if err := context.ShouldBindJSON(&request); err != nil {
-		log.Printf("❌ Error binding request body: %v", err)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body: " + err.Error(),
-		})
+		logger.Warn("invalid start session request", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
 		return
 	}
 
-	// Get the user ID from context (placed by auth middleware)
-	userID, exists := context.Get("user_id")
+	// Get the acting user from context (placed by auth middleware)
+	actor, exists := roles.ActorFromContext(context)
 	if !exists {
-		context.JSON(http.StatusUnauthorized, gin.H{
-			"error": "user unauthorized, user_id not found in context",
-		})
+		apperr.Abort(context, errMissingUser)
 		return
 	}
 
 	// Call the service with the validated user ID and site ID
-	newSessionID, err := handler.service.StartNewSession(int(userID.(int64)), request.SiteID, request.DeptID)
+	newSessionID, err := handler.service.StartNewSession(context.Request.Context(), actor, request.SiteID, request.DeptID)
 	if err != nil {
-		context.JSON(http.StatusConflict, gin.H{
-			"error": "failed to start new opname: " + err.Error(),
-		})
+		apperr.Abort(context, err)
 		return
 	}
 
@@ -78,29 +82,25 @@ func (handler *Handler) StartNewSessionHandler(context *gin.Context) {
 
 // GetSessionByIDHandler retrieves an opname session by its ID.
 func (handler *Handler) GetSessionByIDHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	sessionIDstr := context.Param("session-id")
 	sessionID, err := validateSessionID(sessionIDstr)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be a positive integer",
-		})
+		apperr.Abort(context, ErrInvalidSessionID)
 		return
 	}
 
 	// Call the service to get the session by ID
-	session, err := handler.service.GetSessionByID(sessionID)
+	session, err := handler.service.GetSessionByID(context.Request.Context(), sessionID)
 	if err != nil {
-		log.Printf("❌ Error retrieving opname session by ID %d: %v", sessionID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to retrieve opname session: " + err.Error(),
-		})
+		logger.Error("failed to retrieve opname session", "session_id", sessionID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 	if session == nil {
-		log.Printf("⚠ No opname session found with ID: %d", sessionID)
-		context.JSON(http.StatusNotFound, gin.H{
-			"error": "opname session not found",
-		})
+		logger.Debug("no opname session found", "session_id", sessionID)
+		apperr.Abort(context, ErrSessionNotFound)
 		return
 	}
 
@@ -125,22 +125,21 @@ func (handler *Handler) DeleteSessionHandler(context *gin.Context) {
 	sessionIDstr := context.Param("session-id")
 	sessionID, err := validateSessionID(sessionIDstr)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be a positive integer",
-		})
+		apperr.Abort(context, ErrInvalidSessionID)
 		return
 	}
 
-	// Get the user ID and position from context (placed by auth middleware)
-	userID, _ := context.Get("user_id")
-	userPosition, _ := context.Get("position")
+	// Get the acting user from context (placed by auth middleware)
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
+		return
+	}
 
 	// Call the service to cancel the session
-	err = handler.service.DeleteSession(sessionID, int(userID.(int64)), userPosition.(string))
+	err = handler.service.DeleteSession(context.Request.Context(), sessionID, actor)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to cancel opname session: " + err.Error(),
-		})
+		apperr.Abort(context, err)
 		return
 	}
 
@@ -177,13 +176,13 @@ type AssetChangeRequest struct {
 
 // ProcessAssetChangesHandler handles the processing of asset changes during an opname session.
 func (handler *Handler) ProcessAssetChangesHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Bind the request body to AssetChangeRequest struct
 	var assetChangeRequest AssetChangeRequest
 	if err := context.ShouldBindJSON(&assetChangeRequest); err != nil {
-		log.Printf("❌ Error binding request body: %v", err)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body: " + err.Error(),
-		})
+		logger.Warn("invalid process asset request", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
 		return
 	}
 
@@ -191,17 +190,20 @@ func (handler *Handler) ProcessAssetChangesHandler(context *gin.Context) {
 	sessionIDstr := context.Param("session-id")
 	sessionID, err := strconv.Atoi(sessionIDstr)
 	if err != nil {
-		log.Printf("⚠ Invalid session ID: %s", sessionIDstr)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be an integer",
-		})
+		logger.Warn("invalid session id", "session_id", sessionIDstr)
+		apperr.Abort(context, ErrInvalidSessionID)
 		return
 	}
 	if sessionID < -1 {
-		log.Printf("⚠ Invalid session ID: %d", sessionID)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be greater than -1",
-		})
+		logger.Warn("invalid session id", "session_id", sessionID)
+		apperr.Abort(context, ErrInvalidSessionID)
+		return
+	}
+
+	// Get the acting user from context (placed by auth middleware)
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
 		return
 	}
 
@@ -231,12 +233,10 @@ func (handler *Handler) ProcessAssetChangesHandler(context *gin.Context) {
 		ProcessingStatus:     assetChangeRequest.ProcessingStatus,
 	}
 
-	changesJSON, err := handler.service.ProcessAssetChanges(changedAsset)
+	changesJSON, err := handler.service.ProcessAssetChanges(context.Request.Context(), actor, changedAsset)
 	if err != nil {
-		log.Printf("❌ Error processing asset changes for session %d, asset %s: %v", sessionID, assetChangeRequest.AssetTag, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to process asset changes: " + err.Error(),
-		})
+		logger.Error("failed to process asset changes", "session_id", sessionID, "asset_tag", assetChangeRequest.AssetTag, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
@@ -253,13 +253,13 @@ type RemoveAssetChangeRequest struct {
 
 // RemoveAssetChangeHandler removes an asset change from an opname session.
 func (handler *Handler) RemoveAssetChangeHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Bind the request body to RemoveAssetChangeRequest struct
 	var request RemoveAssetChangeRequest
 	if err := context.ShouldBindJSON(&request); err != nil {
-		log.Printf("❌ Error binding request body: %v", err)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid request body: " + err.Error(),
-		})
+		logger.Warn("invalid remove asset request", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
 		return
 	}
 
@@ -267,26 +267,27 @@ func (handler *Handler) RemoveAssetChangeHandler(context *gin.Context) {
 	sessionIDstr := context.Param("session-id")
 	sessionID, err := validateSessionID(sessionIDstr)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be a positive integer",
-		})
+		apperr.Abort(context, ErrInvalidSessionID)
 		return
 	}
 	if sessionID < -1 {
-		log.Printf("⚠ Invalid session ID: %d", sessionID)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be greater than -1",
-		})
+		logger.Warn("invalid session id", "session_id", sessionID)
+		apperr.Abort(context, ErrInvalidSessionID)
+		return
+	}
+
+	// Get the acting user from context (placed by auth middleware)
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
 		return
 	}
 
 	// Call the service to remove the asset change
-	err = handler.service.RemoveAssetChange(sessionID, request.AssetTag)
+	err = handler.service.RemoveAssetChange(context.Request.Context(), actor, sessionID, request.AssetTag)
 	if err != nil {
-		log.Printf("❌ Error removing asset change for session %d, asset %s: %v", sessionID, request.AssetTag, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to remove asset change: " + err.Error(),
-		})
+		logger.Error("failed to remove asset change", "session_id", sessionID, "asset_tag", request.AssetTag, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
@@ -299,28 +300,26 @@ func (handler *Handler) RemoveAssetChangeHandler(context *gin.Context) {
 
 // LoadOpnameProgressHandler retrieves the progress of an opname session.
 func (handler *Handler) LoadOpnameProgressHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Get the session ID from the URL parameter
 	sessionIDstr := context.Param("session-id")
 	sessionID, err := validateSessionID(sessionIDstr)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be a positive integer",
-		})
+		apperr.Abort(context, ErrInvalidSessionID)
 		return
 	}
 
 	// Call the service to load the opname progress
-	progressList, err := handler.service.LoadOpnameProgress(sessionID)
+	progressList, err := handler.service.LoadOpnameProgress(context.Request.Context(), sessionID)
 	if err != nil {
-		log.Printf("❌ Error loading opname progress for session %d: %v", sessionID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to load opname progress: " + err.Error(),
-		})
+		logger.Error("failed to load opname progress", "session_id", sessionID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
 	if len(progressList) == 0 {
-		log.Printf("⚠ No changes found for opname session %d", sessionID)
+		logger.Debug("no changes found for opname session", "session_id", sessionID)
 		context.JSON(http.StatusOK, gin.H{
 			"message":  "No changes found for this opname session",
 			"progress": []map[string]any{},
@@ -342,7 +341,6 @@ func (handler *Handler) LoadOpnameProgressHandler(context *gin.Context) {
 		responseProgress = append(responseProgress, progressItem)
 	}
 
-	log.Printf("✅ Opname progress for session %d loaded successfully", sessionID)
 	context.JSON(http.StatusOK, gin.H{
 		"message":  "Opname progress loaded successfully",
 		"progress": responseProgress,
@@ -351,36 +349,32 @@ func (handler *Handler) LoadOpnameProgressHandler(context *gin.Context) {
 
 // FinishOpnameSessionHandler marks an opname session as finished.
 func (handler *Handler) FinishOpnameSessionHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Get the session ID from the URL parameter
 	sessionIDstr := context.Param("session-id")
 	sessionID, err := validateSessionID(sessionIDstr)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be a positive integer",
-		})
+		apperr.Abort(context, ErrInvalidSessionID)
 		return
 	}
 
-	// Get the user ID from context (placed by auth middleware)
-	userID, exists := context.Get("user_id")
+	// Get the acting user from context (placed by auth middleware)
+	actor, exists := roles.ActorFromContext(context)
 	if !exists {
-		context.JSON(http.StatusUnauthorized, gin.H{
-			"error": "user unauthorized, user_id not found in context",
-		})
+		apperr.Abort(context, errMissingUser)
 		return
 	}
 
 	// Call the service to finish the opname session
-	err = handler.service.FinishOpnameSession(sessionID, userID.(int64))
+	err = handler.service.FinishOpnameSession(context.Request.Context(), sessionID, actor)
 	if err != nil {
-		log.Printf("❌ Error finishing opname session with ID %d: %v", sessionID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to finish opname session: " + err.Error(),
-		})
+		logger.Error("failed to finish opname session", "session_id", sessionID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
-	log.Printf("✅ Opname session with ID %d finished successfully", sessionID)
+	logger.Info("finished opname session", "session_id", sessionID)
 	context.JSON(http.StatusOK, gin.H{
 		"message": fmt.Sprintf("Opname session %d finished successfully", sessionID),
 	})
@@ -388,28 +382,26 @@ func (handler *Handler) FinishOpnameSessionHandler(context *gin.Context) {
 
 // GetOpnameOnLocationHandler retrieves all opname sessions for a specific location.
 func (handler *Handler) GetOpnameOnLocationHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Read optional query params: /api/opname/location?site_id=1&dept_id=2
 	siteIDStr := context.Query("site_id")
 	deptIDStr := context.Query("dept_id")
 
 	siteID, deptID, err := utils.ParseLocationParams(siteIDStr, deptIDStr)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		apperr.Abort(context, err)
 		return
 	}
 
-	sessions, err := handler.service.GetOpnameOnLocation(siteID, deptID)
+	sessions, err := handler.service.GetOpnameOnLocation(context.Request.Context(), siteID, deptID)
 	if err != nil {
-		log.Printf("❌ Error retrieving opname sessions for site %v dept %v: %v", utils.ParseNullableInt(siteID), utils.ParseNullableInt(deptID), err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to retrieve opname sessions: " + err.Error(),
-		})
+		logger.Error("failed to retrieve opname sessions for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID), "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 	if len(sessions) == 0 {
-		log.Printf("⚠ No opname sessions found for site %v dept %v", utils.ParseNullableInt(siteID), utils.ParseNullableInt(deptID))
+		logger.Debug("no opname sessions found for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID))
 		context.JSON(http.StatusOK, gin.H{
 			"message":  "No opname sessions found for this filter",
 			"sessions": []OpnameFilter{},
@@ -417,8 +409,6 @@ func (handler *Handler) GetOpnameOnLocationHandler(context *gin.Context) {
 		return
 	}
 
-	log.Printf("✅ Retrieved %d opname sessions for site %v dept %v",
-		len(sessions), utils.ParseNullableInt(siteID), utils.ParseNullableInt(deptID))
 	context.JSON(http.StatusOK, gin.H{
 		"message":  fmt.Sprintf("Retrieved %d opname sessions", len(sessions)),
 		"sessions": sessions,
@@ -427,36 +417,32 @@ func (handler *Handler) GetOpnameOnLocationHandler(context *gin.Context) {
 
 // ApproveOpnameSessionHandler verifies an opname session by its ID.
 func (handler *Handler) ApproveOpnameSessionHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Get the session ID from the URL parameter
 	sessionIDstr := context.Param("session-id")
 	sessionID, err := validateSessionID(sessionIDstr)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be a positive integer",
-		})
+		apperr.Abort(context, ErrInvalidSessionID)
 		return
 	}
 
-	// Get the user ID from context (placed by auth middleware)
-	userID, exists := context.Get("user_id")
+	// Get the acting user from context (placed by auth middleware)
+	actor, exists := roles.ActorFromContext(context)
 	if !exists {
-		context.JSON(http.StatusUnauthorized, gin.H{
-			"error": "user unauthorized, user_id not found in context",
-		})
+		apperr.Abort(context, errMissingUser)
 		return
 	}
 
 	// Call the service to verify the opname session
-	err = handler.service.ApproveOpnameSession(sessionID, int(userID.(int64)))
+	err = handler.service.ApproveOpnameSession(context.Request.Context(), sessionID, actor)
 	if err != nil {
-		log.Printf("❌ Error verifying opname session with ID %d: %v", sessionID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to verify opname session: " + err.Error(),
-		})
+		logger.Error("failed to verify opname session", "session_id", sessionID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
-	log.Printf("✅ Opname session with ID %d verified successfully", sessionID)
+	logger.Info("verified opname session", "session_id", sessionID)
 	context.JSON(http.StatusOK, gin.H{
 		"message": fmt.Sprintf("Opname session %d verified successfully", sessionID),
 	})
@@ -464,36 +450,32 @@ func (handler *Handler) ApproveOpnameSessionHandler(context *gin.Context) {
 
 // RejectOpnameSessionHandler rejects an opname session by its ID.
 func (handler *Handler) RejectOpnameSessionHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Get the session ID from the URL parameter
 	sessionIDstr := context.Param("session-id")
 	sessionID, err := validateSessionID(sessionIDstr)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be a positive integer",
-		})
+		apperr.Abort(context, ErrInvalidSessionID)
 		return
 	}
 
-	// Get the user ID from context (placed by auth middleware)
-	userID, exists := context.Get("user_id")
+	// Get the acting user from context (placed by auth middleware)
+	actor, exists := roles.ActorFromContext(context)
 	if !exists {
-		context.JSON(http.StatusUnauthorized, gin.H{
-			"error": "user unauthorized, user_id not found in context",
-		})
+		apperr.Abort(context, errMissingUser)
 		return
 	}
 
 	// Call the service to reject the opname session
-	err = handler.service.RejectOpnameSession(sessionID, int(userID.(int64)))
+	err = handler.service.RejectOpnameSession(context.Request.Context(), sessionID, actor)
 	if err != nil {
-		log.Printf("❌ Error rejecting opname session with ID %d: %v", sessionID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to reject opname session: " + err.Error(),
-		})
+		logger.Error("failed to reject opname session", "session_id", sessionID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
-	log.Printf("✅ Opname session with ID %d rejected successfully", sessionID)
+	logger.Info("rejected opname session", "session_id", sessionID)
 	context.JSON(http.StatusOK, gin.H{
 		"message": fmt.Sprintf("Opname session %d rejected successfully", sessionID),
 	})
@@ -501,30 +483,26 @@ func (handler *Handler) RejectOpnameSessionHandler(context *gin.Context) {
 
 // GetUserFromOpnameSessionHandler retrieves the user associated with a specific opname session.
 func (handler *Handler) GetUserFromOpnameSessionHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Get the session ID from the URL parameter
 	sessionIDstr := context.Param("session-id")
 	sessionID, err := validateSessionID(sessionIDstr)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be a positive integer",
-		})
+		apperr.Abort(context, ErrInvalidSessionID)
 		return
 	}
 
 	// Call the service to get the user associated with the opname session
-	user, err := handler.service.GetUserFromOpnameSession(sessionID)
+	user, err := handler.service.GetUserFromOpnameSession(context.Request.Context(), sessionID)
 	if err != nil {
-		log.Printf("❌ Error retrieving user for opname session %d: %v", sessionID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to retrieve user: " + err.Error(),
-		})
+		logger.Error("failed to retrieve user for opname session", "session_id", sessionID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 	if user == nil {
-		log.Printf("⚠ No user found for opname session ID: %d", sessionID)
-		context.JSON(http.StatusNotFound, gin.H{
-			"error": "user not found for this opname session",
-		})
+		logger.Debug("no user found for opname session", "session_id", sessionID)
+		apperr.Abort(context, apperr.NotFound("opname_user_not_found", "user not found for this opname session"))
 		return
 	}
 
@@ -540,25 +518,54 @@ func (handler *Handler) GetUserFromOpnameSessionHandler(context *gin.Context) {
 
 // GetUnscannedAssetsHandler retrieves all unscanned assets for a specific opname session.
 func (handler *Handler) GetUnscannedAssetsHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Get the session ID from the URL parameter
 	sessionIDstr := context.Param("session-id")
 	sessionID, err := validateSessionID(sessionIDstr)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid session_id, must be a positive integer",
-		})
+		apperr.Abort(context, ErrInvalidSessionID)
 		return
 	}
 
 	// Call the service to get unscanned assets
-	unscannedAssets, err := handler.service.GetUnscannedAssets(sessionID)
+	unscannedAssets, err := handler.service.GetUnscannedAssets(context.Request.Context(), sessionID)
 	if err != nil {
-		log.Printf("❌ Error retrieving unscanned assets for opname session %d: %v", sessionID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to retrieve unscanned assets: " + err.Error(),
-		})
+		logger.Error("failed to retrieve unscanned assets", "session_id", sessionID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
 	context.JSON(http.StatusOK, asset.SerializeMultipleAssets(unscannedAssets))
 }
+
+// GetExpectedOffSiteAssetsHandler retrieves the assets on loan at the location of an opname session, with their loans.
+func (handler *Handler) GetExpectedOffSiteAssetsHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	sessionID, err := validateSessionID(context.Param("session-id"))
+	if err != nil {
+		apperr.Abort(context, ErrInvalidSessionID)
+		return
+	}
+
+	offSiteAssets, err := handler.service.GetExpectedOffSiteAssets(context.Request.Context(), sessionID)
+	if err != nil {
+		logger.Error("failed to retrieve expected off-site assets", "session_id", sessionID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	serialized := make([]gin.H, 0, len(offSiteAssets))
+	for _, offSite := range offSiteAssets {
+		serialized = append(serialized, gin.H{
+			"asset_tag":       offSite.AssetTag,
+			"product_variety": offSite.ProductVariety,
+			"loan_id":         offSite.LoanID,
+			"borrower_id":     offSite.BorrowerID,
+			"borrower_name":   offSite.BorrowerName,
+			"due_date":        offSite.DueDate.Format(time.DateOnly),
+		})
+	}
+	context.JSON(http.StatusOK, serialized)
+}
@@ -0,0 +1,114 @@
+// == Decides who may start, change and review opname sessions ==
+// == Every denial is logged with the acting user so refused attempts can be traced ==
+package opname
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+)
+
+// Errors returned when the policy refuses an action.
+var (
+	ErrNotSessionOwner      = apperr.Forbidden("opname_not_session_owner", "only the user who started this opname session or an admin can change it")
+	ErrLocationNotAssigned  = apperr.Forbidden("opname_location_not_assigned", "only the GA staff assigned to this location can start an opname session")
+	ErrNotSessionReviewer   = apperr.Forbidden("opname_not_session_reviewer", "you are not the next reviewer on this location's approval path")
+	ErrSessionNotReviewable = apperr.Conflict("opname_session_not_reviewable", "the opname session is not waiting for a review")
+	ErrSessionNotModifiable = apperr.Conflict("opname_session_not_modifiable", "the opname session can only be changed while it is active or submitted")
+	ErrOwnSessionReview     = apperr.Forbidden("opname_own_session_review", "you cannot review an opname session you started")
+	ErrLossReviewedSession  = apperr.Forbidden("opname_loss_reviewed_session", "the loss of an opname session must be approved by someone who did not review the session")
+)
+
+// Policy holds the authorization rules for opname sessions:
+//   - only GA staff mapped to the site (site_ga_id) or users of the department may start a session there,
+//   - only the user who started a session, or an admin (who already sees every location, see get_user_opname_locations),
+//     may edit, cancel or finish it, and only while it is active or submitted,
+//   - only the next reviewer on the location's approval path may approve or reject it, never the user who started it,
+//   - only the configured loss approvers may approve or reject the loss of a session in loss review,
+//     and not one who already reviewed the session.
+type Policy struct {
+	repo   *Repository
+	config config.OpnameConfig
+	logger *slog.Logger
+}
+
+// NewPolicy creates the opname authorization policy.
+func NewPolicy(repo *Repository, opnameConfig config.OpnameConfig, logger *slog.Logger) *Policy {
+	return &Policy{
+		repo:   repo,
+		config: opnameConfig,
+		logger: logger,
+	}
+}
+
+// CanStart checks that the actor is assigned to the site or department the session is started on.
+func (policy *Policy) CanStart(ctx context.Context, actor roles.Actor, siteID *int, deptID *int) error {
+	assigned, err := policy.repo.IsLocationAssignee(ctx, int(actor.UserID), siteID, deptID)
+	if err != nil {
+		return err
+	}
+	if !assigned {
+		return policy.deny(ctx, "start", actor, ErrLocationNotAssigned, "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID))
+	}
+	return nil
+}
+
+// CanModify checks that the session is active or submitted and that the actor started it or is an admin.
+// action names what is attempted, e.g. "cancel".
+func (policy *Policy) CanModify(ctx context.Context, action string, actor roles.Actor, session *OpnameSession) error {
+	if session.Status != "Active" && session.Status != "Submitted" {
+		return ErrSessionNotModifiable
+	}
+	if int64(session.UserID) != actor.UserID && !actor.IsAdmin() {
+		return policy.deny(ctx, action, actor, ErrNotSessionOwner, "session_id", session.ID, "owner_id", session.UserID)
+	}
+	return nil
+}
+
+// CanReview checks that the session waits for a review and that the actor is its next reviewer and did not start it.
+func (policy *Policy) CanReview(ctx context.Context, action string, actor roles.Actor, session *OpnameSession) error {
+	if int64(session.UserID) == actor.UserID {
+		return policy.deny(ctx, action, actor, ErrOwnSessionReview, "session_id", session.ID, "session_status", session.Status)
+	}
+	if session.Status == "Loss Review" {
+		if !policy.config.IsLossApprover(actor.Position) {
+			return policy.deny(ctx, action, actor, ErrNotSessionReviewer, "session_id", session.ID, "session_status", session.Status)
+		}
+		// The reviewers who escalated the loss cannot approve it themselves.
+		if reviewedBy(session.ManagerReviewerID, actor.UserID) || reviewedBy(session.L1ReviewerID, actor.UserID) {
+			return policy.deny(ctx, action, actor, ErrLossReviewedSession, "session_id", session.ID, "session_status", session.Status)
+		}
+		return nil
+	}
+	if session.Status != "Submitted" && session.Status != "Escalated" {
+		return ErrSessionNotReviewable
+	}
+
+	reviewer, err := policy.repo.IsSessionReviewer(ctx, session.ID, int(actor.UserID))
+	if err != nil {
+		return err
+	}
+	if !reviewer {
+		return policy.deny(ctx, action, actor, ErrNotSessionReviewer, "session_id", session.ID, "session_status", session.Status)
+	}
+	return nil
+}
+
+// reviewedBy reports whether a reviewer column of a session holds userID.
+func reviewedBy(reviewerID sql.NullInt64, userID int64) bool {
+	return reviewerID.Valid && reviewerID.Int64 == userID
+}
+
+// deny logs a refused action and returns its error.
+func (policy *Policy) deny(ctx context.Context, action string, actor roles.Actor, err *apperr.Error, attrs ...any) error {
+	logger := logging.FromContext(ctx, policy.logger)
+
+	attrs = append([]any{"action", action, "user_id", actor.UserID, "position", actor.Position, "reason", err.Code}, attrs...)
+	logger.Warn("opname action denied", attrs...)
+	return err
+}
@@ -0,0 +1,50 @@
+package opname
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+)
+
+func TestCanReviewLoss(t *testing.T) {
+	const (
+		creatorID  = 1
+		managerID  = 2
+		l1ID       = 3
+		approverID = 4
+	)
+	policy := NewPolicy(nil, config.OpnameConfig{LossApproverPositions: []string{"FINANCE & ACCOUNTING MANAGER"}}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	session := &OpnameSession{
+		ID:                10,
+		Status:            "Loss Review",
+		UserID:            creatorID,
+		ManagerReviewerID: sql.NullInt64{Int64: managerID, Valid: true},
+		L1ReviewerID:      sql.NullInt64{Int64: l1ID, Valid: true},
+	}
+
+	tests := []struct {
+		name  string
+		actor roles.Actor
+		want  error
+	}{
+		{"loss approver", roles.Actor{UserID: approverID, Position: "Finance & Accounting Manager"}, nil},
+		{"not a loss approver", roles.Actor{UserID: approverID, Position: "GA STAFF"}, ErrNotSessionReviewer},
+		{"loss approver who started the session", roles.Actor{UserID: creatorID, Position: "FINANCE & ACCOUNTING MANAGER"}, ErrOwnSessionReview},
+		{"loss approver who approved as manager", roles.Actor{UserID: managerID, Position: "FINANCE & ACCOUNTING MANAGER"}, ErrLossReviewedSession},
+		{"loss approver who escalated the loss", roles.Actor{UserID: l1ID, Position: "FINANCE & ACCOUNTING MANAGER"}, ErrLossReviewedSession},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := policy.CanReview(context.Background(), "approve", test.actor, session)
+			if !errors.Is(err, test.want) {
+				t.Errorf("CanReview(%+v) = %v, want %v", test.actor, err, test.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,14 @@
 package opname
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+	"time"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/asset"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/user"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
 )
@@ -57,19 +61,33 @@ type OpnameSessionProgress struct {
 	ActionNotes      string `json:"action_notes"`
 }
 
+// ExpectedOffSiteAsset is an asset of the session's location that was on loan during the session, see internal/loan.
+type ExpectedOffSiteAsset struct {
+	AssetTag       string
+	ProductVariety string
+	LoanID         int64
+	BorrowerID     int64
+	BorrowerName   string
+	DueDate        time.Time
+}
+
 type Repository struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *slog.Logger
 }
 
 // NewRepository creates a new Opname repository with the provided database connection.
-func NewRepository(db *sql.DB) *Repository {
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
 	return &Repository{
-		db: db,
+		db:     db,
+		logger: logger,
 	}
 }
 
 // CreateNewSession creates a new opname session in the database.
-func (repo *Repository) CreateNewSession(userID int, siteID *int, deptID *int) (int, error) {
+func (repo *Repository) CreateNewSession(ctx context.Context, userID int, siteID *int, deptID *int) (int, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var siteIDParam, deptIDParam sql.NullInt64
 	siteIDParam = utils.ParseNullableInt(siteID)
 	deptIDParam = utils.ParseNullableInt(deptID)
@@ -78,22 +96,24 @@ func (repo *Repository) CreateNewSession(userID int, siteID *int, deptID *int) (
 
 	query := `SELECT create_new_opname_session($1, $2, $3)`
 
-	err := repo.db.QueryRow(query, userID, siteIDParam, deptIDParam).Scan(&newSessionID)
+	err := repo.db.QueryRowContext(ctx, query, userID, siteIDParam, deptIDParam).Scan(&newSessionID)
 	if err != nil {
-		log.Printf("❌ Error creating new opname session: %v", err)
-		return 0, err
+		logger.Error("failed to create opname session", "user_id", userID, "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID), "error", err)
+		return 0, apperr.FromPostgres(err)
 	}
 
 	return newSessionID, nil
 }
 
 // GetSessionByID retrieves an opname session by its ID.
-func (repo *Repository) GetSessionByID(sessionID int) (*OpnameSession, error) {
+func (repo *Repository) GetSessionByID(ctx context.Context, sessionID int) (*OpnameSession, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var session OpnameSession
 
 	query := `SELECT * FROM get_opname_session_by_id($1)`
 
-	err := repo.db.QueryRow(query, sessionID).Scan(
+	err := repo.db.QueryRowContext(ctx, query, sessionID).Scan(
 		&session.ID,
 		&session.StartDate,
 		&session.EndDate,
@@ -108,38 +128,42 @@ func (repo *Repository) GetSessionByID(sessionID int) (*OpnameSession, error) {
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("⚠ No opname session found with ID: %d", sessionID)
+			logger.Debug("no opname session found", "session_id", sessionID)
 			return nil, nil // No session found.
 		}
-		log.Printf("❌ Error retrieving opname session by ID %d: %v", sessionID, err)
-		return nil, err // Other error.
+		logger.Error("failed to query opname session", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err) // Other error.
 	}
 
 	return &session, nil
 }
 
 // DeleteSession deletes an opname session by its ID.
-func (repo *Repository) DeleteSession(sessionID int) error {
+func (repo *Repository) DeleteSession(ctx context.Context, sessionID int) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	query := `CALL delete_opname_session($1)`
 
-	_, err := repo.db.Exec(query, sessionID)
+	_, err := repo.db.ExecContext(ctx, query, sessionID)
 	if err != nil {
-		log.Printf("❌ Error deleting opname session with ID %d: %v", sessionID, err)
-		return err // Deletion failed for some error.
+		logger.Error("failed to delete opname session", "session_id", sessionID, "error", err)
+		return apperr.FromPostgres(err) // Deletion failed for some error.
 	}
 
 	// If successful, log the deletion.
-	log.Printf("✅ Opname session with ID %d deleted successfully", sessionID)
+	logger.Debug("deleted opname session", "session_id", sessionID)
 	return nil
 }
 
 // RecordAssetChange records an asset change in the database.
-func (repo *Repository) RecordAssetChange(changedAsset AssetChange) ([]byte, error) {
+func (repo *Repository) RecordAssetChange(ctx context.Context, changedAsset AssetChange) ([]byte, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var changesJSON []byte // Use []byte to receive raw JSON data.
 
 	query := `SELECT record_asset_change($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)`
 
-	err := repo.db.QueryRow(query,
+	err := repo.db.QueryRowContext(ctx, query,
 		changedAsset.SessionID,
 		changedAsset.AssetTag,
 		changedAsset.NewSerialNumber,
@@ -163,44 +187,48 @@ func (repo *Repository) RecordAssetChange(changedAsset AssetChange) ([]byte, err
 		changedAsset.ProcessingStatus,
 	).Scan(&changesJSON)
 	if err != nil {
-		log.Printf("❌ Error recording asset change for asset %s: %v", changedAsset.AssetTag, err)
-		return nil, err
+		logger.Error("failed to record asset change", "session_id", changedAsset.SessionID, "asset_tag", changedAsset.AssetTag, "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 
-	log.Printf("✅ Asset change for asset %s recorded successfully", changedAsset.AssetTag)
+	logger.Debug("recorded asset change", "session_id", changedAsset.SessionID, "asset_tag", changedAsset.AssetTag)
 	return changesJSON, nil
 }
 
 // DeleteAssetChange deletes an asset change by its session ID and asset tag.
-func (repo *Repository) DeleteAssetChange(sessionID int, assetTag string) error {
+func (repo *Repository) DeleteAssetChange(ctx context.Context, sessionID int, assetTag string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	query := `CALL delete_asset_change($1, $2)`
 
-	_, err := repo.db.Exec(query, sessionID, assetTag)
+	_, err := repo.db.ExecContext(ctx, query, sessionID, assetTag)
 	if err != nil {
-		log.Printf("❌ Error deleting asset change for asset %s in session %d: %v", assetTag, sessionID, err)
-		return err // Deletion failed for some error.
+		logger.Error("failed to delete asset change", "session_id", sessionID, "asset_tag", assetTag, "error", err)
+		return apperr.FromPostgres(err) // Deletion failed for some error.
 	}
 
 	// If successful, log the deletion.
-	log.Printf("✅ Asset change for asset %s in session %d deleted successfully", assetTag, sessionID)
+	logger.Debug("deleted asset change", "session_id", sessionID, "asset_tag", assetTag)
 	return nil
 }
 
 // GetAssetChangePhoto retrieves an asset change by its session ID and asset tag.
-func (repo *Repository) GetAssetChangePhoto(sessionID int, assetTag string) (string, error) {
+func (repo *Repository) GetAssetChangePhoto(ctx context.Context, sessionID int, assetTag string) (string, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	query := `SELECT * FROM get_asset_change_photo($1, $2)`
 
-	row := repo.db.QueryRow(query, sessionID, assetTag)
+	row := repo.db.QueryRowContext(ctx, query, sessionID, assetTag)
 
 	var conditionPhotoURL sql.NullString // Use sql.NullString to handle NULL values
 	err := row.Scan(&conditionPhotoURL)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("⚠ No asset change found for asset %s in session %d", assetTag, sessionID)
+			logger.Debug("no asset change found", "session_id", sessionID, "asset_tag", assetTag)
 			return "", nil // No change found.
 		}
-		log.Printf("❌ Error retrieving asset change for asset %s in session %d: %v", assetTag, sessionID, err)
-		return "", err // Other error.
+		logger.Error("failed to query asset change", "session_id", sessionID, "asset_tag", assetTag, "error", err)
+		return "", apperr.FromPostgres(err) // Other error.
 	}
 
 	// Return the string value, or empty string if NULL
@@ -211,13 +239,15 @@ func (repo *Repository) GetAssetChangePhoto(sessionID int, assetTag string) (str
 }
 
 // GetPhotosBySessionID retrieves all photos associated with an opname session.
-func (repo *Repository) GetPhotosBySessionID(sessionID int) ([]string, error) {
+func (repo *Repository) GetPhotosBySessionID(ctx context.Context, sessionID int) ([]string, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	query := `SELECT * FROM get_all_photos_by_session_id($1)`
 
-	rows, err := repo.db.Query(query, sessionID)
+	rows, err := repo.db.QueryContext(ctx, query, sessionID)
 	if err != nil {
-		log.Printf("❌ Error retrieving photos for session %d: %v", sessionID, err)
-		return nil, err // Query failed for some error.
+		logger.Error("failed to query session photos", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err) // Query failed for some error.
 	}
 	defer rows.Close()
 
@@ -225,8 +255,8 @@ func (repo *Repository) GetPhotosBySessionID(sessionID int) ([]string, error) {
 	for rows.Next() {
 		var conditionPhotoURL string
 		if err := rows.Scan(&conditionPhotoURL); err != nil {
-			log.Printf("❌ Error scanning photo URL for session %d: %v", sessionID, err)
-			return nil, err // Row scan failed for some error.
+			logger.Error("failed to scan session photo url", "session_id", sessionID, "error", err)
+			return nil, apperr.FromPostgres(err) // Row scan failed for some error.
 		}
 		conditionPhotos = append(conditionPhotos, conditionPhotoURL)
 	}
@@ -235,15 +265,17 @@ func (repo *Repository) GetPhotosBySessionID(sessionID int) ([]string, error) {
 }
 
 // LoadOpnameProgress retrieves the current progress of an opname session in terms of recorded asset changes tied to that session.
-func (repo *Repository) LoadOpnameProgress(sessionID int) ([]OpnameSessionProgress, error) {
+func (repo *Repository) LoadOpnameProgress(ctx context.Context, sessionID int) ([]OpnameSessionProgress, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var progressList []OpnameSessionProgress
 
 	query := `SELECT * FROM load_opname_progress($1)`
 
-	rows, err := repo.db.Query(query, sessionID)
+	rows, err := repo.db.QueryContext(ctx, query, sessionID)
 	if err != nil {
-		log.Printf("❌ Error loading opname progress for session %d: %v", sessionID, err)
-		return nil, err // Query failed for some error.
+		logger.Error("failed to load opname progress", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err) // Query failed for some error.
 	}
 
 	defer rows.Close()
@@ -251,8 +283,8 @@ func (repo *Repository) LoadOpnameProgress(sessionID int) ([]OpnameSessionProgre
 	for rows.Next() {
 		var progress OpnameSessionProgress
 		if err := rows.Scan(&progress.ID, &progress.Changes, &progress.ChangeReason, &progress.AssetTag, &progress.ProcessingStatus, &progress.ActionNotes); err != nil {
-			log.Printf("❌ Error scanning row for opname progress: %v", err)
-			return nil, err // Row scan failed for some error.
+			logger.Error("failed to scan opname progress row", "session_id", sessionID, "error", err)
+			return nil, apperr.FromPostgres(err) // Row scan failed for some error.
 		}
 		progressList = append(progressList, progress)
 	}
@@ -261,45 +293,83 @@ func (repo *Repository) LoadOpnameProgress(sessionID int) ([]OpnameSessionProgre
 }
 
 // FinishOpnameSession marks an opname session as finished.
-func (repo *Repository) FinishOpnameSession(sessionID int) error {
+func (repo *Repository) FinishOpnameSession(ctx context.Context, sessionID int) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	query := `CALL finish_opname_session($1)`
 
-	_, err := repo.db.Exec(query, sessionID)
+	_, err := repo.db.ExecContext(ctx, query, sessionID)
 	if err != nil {
-		log.Printf("❌ Error finishing opname session with ID %d: %v", sessionID, err)
-		return err // Finishing failed for some error.
+		logger.Error("failed to finish opname session", "session_id", sessionID, "error", err)
+		return apperr.FromPostgres(err) // Finishing failed for some error.
 	}
 
 	// If successful, log the completion.
-	log.Printf("✅ Opname session with ID %d finished successfully", sessionID)
+	logger.Debug("finished opname session", "session_id", sessionID)
 	return nil
 }
 
-// ApproveOpnameSession sets the status of an opname session to "escalated" by an area manager or "verified" by an L1 support.
-func (repo *Repository) ApproveOpnameSession(sessionID int, reviewerID int) error {
+// ApproveOpnameSession sets the status of an opname session to "escalated" by its first reviewer or "verified" by its second,
+// as set by the approval path of its location.
+func (repo *Repository) ApproveOpnameSession(ctx context.Context, sessionID int, reviewerID int) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	query := `CALL approve_opname_session($1, $2)`
-	_, err := repo.db.Exec(query, sessionID, reviewerID)
+	_, err := repo.db.ExecContext(ctx, query, sessionID, reviewerID)
 	if err != nil {
-		log.Printf("❌ Error verifying opname session with ID %d by approver %d: %v", sessionID, reviewerID, err)
-		return err // Verification failed for some error.
+		logger.Error("failed to verify opname session", "session_id", sessionID, "reviewer_id", reviewerID, "error", err)
+		return apperr.FromPostgres(err) // Verification failed for some error.
 	}
 
 	// If successful, log the verification.
-	log.Printf("✅ Opname session with ID %d verified successfully by approver %d", sessionID, reviewerID)
+	logger.Debug("verified opname session", "session_id", sessionID, "reviewer_id", reviewerID)
 	return nil
 }
 
 // RejectOpnameSession sets the status of an opname session to "rejected" by an approver.
-func (repo *Repository) RejectOpnameSession(sessionID int, reviewerID int) error {
+func (repo *Repository) RejectOpnameSession(ctx context.Context, sessionID int, reviewerID int) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	query := `CALL reject_opname_session($1, $2)`
-	_, err := repo.db.Exec(query, sessionID, reviewerID)
+	_, err := repo.db.ExecContext(ctx, query, sessionID, reviewerID)
 	if err != nil {
-		log.Printf("❌ Error rejecting opname session with ID %d by approver %d: %v", sessionID, reviewerID, err)
-		return err
+		logger.Error("failed to reject opname session", "session_id", sessionID, "reviewer_id", reviewerID, "error", err)
+		return apperr.FromPostgres(err)
 	}
 
 	// If successful, log the rejection.
-	log.Printf("✅ Opname session with ID %d rejected successfully by approver %d", sessionID, reviewerID)
+	logger.Debug("rejected opname session", "session_id", sessionID, "reviewer_id", reviewerID)
+	return nil
+}
+
+// RequestLossApproval verifies an escalated opname session on behalf of its second reviewer but leaves it in "Loss Review",
+// waiting for the approval of its loss, the net book value of its broken and missing assets.
+func (repo *Repository) RequestLossApproval(ctx context.Context, sessionID int, reviewerID int, lossValue int64, lossThreshold int64) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL request_opname_loss_approval($1, $2, $3, $4)`
+	_, err := repo.db.ExecContext(ctx, query, sessionID, reviewerID, lossValue, lossThreshold)
+	if err != nil {
+		logger.Error("failed to request opname loss approval", "session_id", sessionID, "reviewer_id", reviewerID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Debug("requested opname loss approval", "session_id", sessionID, "reviewer_id", reviewerID, "loss_value", lossValue)
+	return nil
+}
+
+// ReviewLoss approves or rejects the loss of an opname session in "Loss Review", which becomes "Verified" or "Rejected".
+func (repo *Repository) ReviewLoss(ctx context.Context, sessionID int, reviewerID int, approved bool) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL review_opname_loss($1, $2, $3)`
+	_, err := repo.db.ExecContext(ctx, query, sessionID, reviewerID, approved)
+	if err != nil {
+		logger.Error("failed to review opname loss", "session_id", sessionID, "reviewer_id", reviewerID, "approved", approved, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Debug("reviewed opname loss", "session_id", sessionID, "reviewer_id", reviewerID, "approved", approved)
 	return nil
 }
 
@@ -310,16 +380,18 @@ type OpnameFilter struct {
 
 // GetOpnameOnLocation retrieves all opname sessions for a specific site.
 // Only non-active sessions are returned.
-func (repo *Repository) GetOpnameOnLocation(siteID *int, deptID *int) ([]OpnameFilter, error) {
+func (repo *Repository) GetOpnameOnLocation(ctx context.Context, siteID *int, deptID *int) ([]OpnameFilter, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	siteIDParam := utils.ParseNullableInt(siteID)
 	deptIDParam := utils.ParseNullableInt(deptID)
 
 	query := `SELECT * FROM get_finished_opnames_by_location_id($1, $2)`
 
-	rows, err := repo.db.Query(query, siteIDParam, deptIDParam)
+	rows, err := repo.db.QueryContext(ctx, query, siteIDParam, deptIDParam)
 	if err != nil {
-		log.Printf("❌ Error retrieving opname sessions for site %d and dept %d: %v", siteIDParam.Int64, deptIDParam.Int64, err)
-		return nil, err // Query failed for some error.
+		logger.Error("failed to query opname sessions for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID), "error", err)
+		return nil, apperr.FromPostgres(err) // Query failed for some error.
 	}
 
 	defer rows.Close()
@@ -328,74 +400,186 @@ func (repo *Repository) GetOpnameOnLocation(siteID *int, deptID *int) ([]OpnameF
 	for rows.Next() {
 		var session OpnameFilter
 		if err := rows.Scan(&session.SessionID, &session.CompletedDate); err != nil {
-			log.Printf("❌ Error scanning row for opname session: %v", err)
-			return nil, err // Row scan failed for some error.
+			logger.Error("failed to scan opname session row", "error", err)
+			return nil, apperr.FromPostgres(err) // Row scan failed for some error.
 		}
 		sessions = append(sessions, session)
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("❌ Error iterating over rows for opname sessions: %v", err)
-		return nil, err // Error occurred while iterating.
+		logger.Error("failed to iterate opname session rows", "error", err)
+		return nil, apperr.FromPostgres(err) // Error occurred while iterating.
 	}
 
-	log.Printf("✅ Retrieved %d opname sessions for site %d", len(sessions), siteID)
+	logger.Debug("retrieved opname sessions for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID), "count", len(sessions))
 	return sessions, nil
 }
 
 // GetUserFromOpnameSession retrieves the user associated with a specific opname session.
-func (repo *Repository) GetUserFromOpnameSession(sessionID int) (*user.User, error) {
+func (repo *Repository) GetUserFromOpnameSession(ctx context.Context, sessionID int) (*user.User, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	user := &user.User{}
 
 	query := `SELECT * FROM get_user_from_opname_session($1)`
 
-	err := repo.db.QueryRow(query, sessionID).Scan(&user.UserID, &user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Position, &user.Department, &user.Division)
+	err := repo.db.QueryRowContext(ctx, query, sessionID).Scan(&user.UserID, &user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Position, &user.Department, &user.Division)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("⚠ No user found for opname session ID: %d", sessionID)
+			logger.Debug("no user found for opname session", "session_id", sessionID)
 			return nil, nil // No user found.
 		}
-		log.Printf("❌ Error retrieving user for opname session ID %d: %v", sessionID, err)
-		return nil, err // Other error.
+		logger.Error("failed to query user for opname session", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err) // Other error.
 	}
 
 	return user, nil
 }
 
 // GetUnscannedAssets retrieves all assets that were not scanned during a specific opname session.
-func (repo *Repository) GetUnscannedAssets(sessionID int) ([]*asset.Asset, error) {
+func (repo *Repository) GetUnscannedAssets(ctx context.Context, sessionID int) ([]*asset.Asset, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	query := `SELECT * FROM get_unscanned_assets($1)`
 
-	rows, err := repo.db.Query(query, sessionID)
+	rows, err := repo.db.QueryContext(ctx, query, sessionID)
 	if err != nil {
-		log.Printf("❌ Error retrieving unscanned assets for opname session ID %d: %v", sessionID, err)
-		return nil, err
+		logger.Error("failed to query unscanned assets", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 	defer rows.Close()
 
 	var assets []*asset.Asset
-	var assetRepo = asset.NewRepository(repo.db)
+	var assetRepo = asset.NewRepository(repo.db, repo.logger)
 	for rows.Next() {
 		var unscannedAsset *asset.Asset
 		var assetTag string
 		if err := rows.Scan(&assetTag); err != nil {
-			log.Printf("❌ Error scanning row for unscanned asset: %v", err)
-			return nil, err
+			logger.Error("failed to scan unscanned asset row", "session_id", sessionID, "error", err)
+			return nil, apperr.FromPostgres(err)
 		}
 
-		unscannedAsset, err = assetRepo.GetAssetByTag(assetTag)
+		unscannedAsset, err = assetRepo.GetAssetByTag(ctx, assetTag)
 		if err != nil {
-			log.Printf("❌ Error retrieving asset by tag %s: %v", assetTag, err)
-			return nil, err
+			logger.Error("failed to retrieve unscanned asset", "session_id", sessionID, "asset_tag", assetTag, "error", err)
+			return nil, apperr.FromPostgres(err)
 		}
 		assets = append(assets, unscannedAsset)
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("❌ Error iterating over rows for unscanned assets: %v", err)
-		return nil, err
+		logger.Error("failed to iterate unscanned asset rows", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	logger.Debug("retrieved unscanned assets", "session_id", sessionID, "count", len(assets))
+	return assets, nil
+}
+
+// GetExpectedOffSiteAssets retrieves the assets of the session's location that are on loan and need not be scanned.
+func (repo *Repository) GetExpectedOffSiteAssets(ctx context.Context, sessionID int) ([]*ExpectedOffSiteAsset, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_expected_off_site_assets($1)`
+
+	rows, err := repo.db.QueryContext(ctx, query, sessionID)
+	if err != nil {
+		logger.Error("failed to query expected off-site assets", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	assets := make([]*ExpectedOffSiteAsset, 0)
+	for rows.Next() {
+		var offSite ExpectedOffSiteAsset
+		if err := rows.Scan(
+			&offSite.AssetTag,
+			&offSite.ProductVariety,
+			&offSite.LoanID,
+			&offSite.BorrowerID,
+			&offSite.BorrowerName,
+			&offSite.DueDate,
+		); err != nil {
+			logger.Error("failed to scan expected off-site asset row", "session_id", sessionID, "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		assets = append(assets, &offSite)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to iterate expected off-site asset rows", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 
-	log.Printf("✅ Retrieved %d unscanned assets for opname session ID %d", len(assets), sessionID)
+	logger.Debug("retrieved expected off-site assets", "session_id", sessionID, "count", len(assets))
 	return assets, nil
 }
+
+// IsLocationAssignee checks whether a user is assigned to the site (as its GA staff) or works in the department.
+func (repo *Repository) IsLocationAssignee(ctx context.Context, userID int, siteID *int, deptID *int) (bool, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT is_location_assignee($1, $2, $3)`
+
+	var assigned bool
+	err := repo.db.QueryRowContext(ctx, query, userID, utils.ParseNullableInt(siteID), utils.ParseNullableInt(deptID)).Scan(&assigned)
+	if err != nil {
+		logger.Error("failed to check location assignment", "user_id", userID, "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID), "error", err)
+		return false, apperr.FromPostgres(err)
+	}
+
+	return assigned, nil
+}
+
+// IsSessionReviewer checks whether a user is the next reviewer on the approval path of the session's location.
+func (repo *Repository) IsSessionReviewer(ctx context.Context, sessionID int, userID int) (bool, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT is_opname_session_reviewer($1, $2)`
+
+	var reviewer bool
+	err := repo.db.QueryRowContext(ctx, query, sessionID, userID).Scan(&reviewer)
+	if err != nil {
+		logger.Error("failed to check session reviewer", "session_id", sessionID, "user_id", userID, "error", err)
+		return false, apperr.FromPostgres(err)
+	}
+
+	return reviewer, nil
+}
+
+// SessionReviewer is a user who may review an opname session at its current approval step.
+type SessionReviewer struct {
+	UserID int64
+	Name   string
+	Email  string
+}
+
+// GetSessionReviewers retrieves the active users holding the position of the session's next approval step, see ApprovalPath.
+func (repo *Repository) GetSessionReviewers(ctx context.Context, sessionID int) ([]SessionReviewer, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT user_id, reviewer_name, email FROM get_opname_session_reviewers($1)`
+
+	rows, err := repo.db.QueryContext(ctx, query, sessionID)
+	if err != nil {
+		logger.Error("failed to query session reviewers", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	var reviewers []SessionReviewer
+	for rows.Next() {
+		var reviewer SessionReviewer
+		if err := rows.Scan(&reviewer.UserID, &reviewer.Name, &reviewer.Email); err != nil {
+			logger.Error("failed to scan session reviewer", "session_id", sessionID, "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		reviewers = append(reviewers, reviewer)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("failed to iterate session reviewers", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return reviewers, nil
+}
@@ -2,10 +2,12 @@
 package opname
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
@@ -13,9 +15,15 @@ import (
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/asset"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/email"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/jobs"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/report"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/site"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/upload"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/user"
@@ -29,10 +37,29 @@ type Service struct {
 	siteRepo      *site.Repository
 	emailService  *email.Service
 	reportService *report.Service
+	jobs          *jobs.Runner
+	policy        *Policy
+	config        config.OpnameConfig
+	app           config.AppConfig
+	logger        *slog.Logger
 }
 
+// Errors returned by the opname service, in addition to the ones translated from the database.
+var (
+	ErrInvalidUserID        = apperr.Validation("invalid_user_id", "user_id must be a positive integer")
+	ErrInvalidSessionID     = apperr.Validation("invalid_session_id", "session_id must be a positive integer")
+	ErrInvalidLocation      = apperr.Validation("invalid_location", "exactly one of site_id or dept_id must be provided")
+	ErrSessionNotFound      = apperr.NotFound("opname_session_not_found", "opname session not found")
+	ErrSessionAlreadyActive = apperr.Conflict("opname_session_already_active", "an ongoing opname session already exists for this location")
+)
+
+// errNotFound is returned by background jobs when a record they depend on no longer exists.
+var errNotFound = errors.New("not found")
+
 // NewService creates a new Opname service with the provided repository.
-func NewService(repo *Repository, uploadService *upload.Service, userRepo *user.Repository, siteRepo *site.Repository, emailService *email.Service, reportService *report.Service) *Service {
+// Notification emails and BAP PDFs are generated in the background through the job runner.
+// The opname config sets the loss above which a verified session also needs a loss approval.
+func NewService(repo *Repository, uploadService *upload.Service, userRepo *user.Repository, siteRepo *site.Repository, emailService *email.Service, reportService *report.Service, jobRunner *jobs.Runner, opnameConfig config.OpnameConfig, app config.AppConfig, logger *slog.Logger) *Service {
 	return &Service{
 		repo:          repo,
 		uploadService: uploadService,
@@ -40,232 +67,312 @@ func NewService(repo *Repository, uploadService *upload.Service, userRepo *user.
 		siteRepo:      siteRepo,
 		emailService:  emailService,
 		reportService: reportService,
+		jobs:          jobRunner,
+		policy:        NewPolicy(repo, opnameConfig, logger),
+		config:        opnameConfig,
+		app:           app,
+		logger:        logger,
 	}
 }
 
-// StartNewSession creates a new opname session for a user at a specific site.
-func (service *Service) StartNewSession(userID int, siteID *int, deptID *int) (int, error) {
-	// Validate userID
-	if userID <= 0 {
-		log.Printf("⚠ Invalid userID: userID=%d", userID)
-		return 0, errors.New("invalid userID")
+// StartNewSession creates a new opname session for a user at a specific site or department.
+// Only users assigned to the location may start a session there.
+func (service *Service) StartNewSession(ctx context.Context, actor roles.Actor, siteID *int, deptID *int) (int, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	// Validate userID and location
+	if actor.UserID <= 0 {
+		logger.Warn("invalid user id", "user_id", actor.UserID)
+		return 0, ErrInvalidUserID
+	}
+	hasSite, hasDept := siteID != nil && *siteID > 0, deptID != nil && *deptID > 0
+	if hasSite == hasDept {
+		logger.Warn("invalid location, exactly one of site or dept id must be given", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID))
+		return 0, ErrInvalidLocation
+	}
+
+	if err := service.policy.CanStart(ctx, actor, siteID, deptID); err != nil {
+		return 0, err
 	}
 
 	// Call the repository to create a new session
-	newSessionID, err := service.repo.CreateNewSession(userID, siteID, deptID)
+	newSessionID, err := service.repo.CreateNewSession(ctx, int(actor.UserID), siteID, deptID)
 	if err != nil {
-		log.Printf("❌ Error creating new opname session: %v", err)
+		logger.Error("failed to create opname session", "user_id", actor.UserID, "error", err)
 		return 0, err
 	}
 
 	// If newSessionID is 0, it indicates failure in session creation due to ongoing sessions already available.
 	// We translate this from business logic into a more user-friendly error.
 	if newSessionID == 0 {
-		log.Printf("⚠ No new session created, an ongoing session already exists for siteID %d", siteID)
-		return 0, errors.New("an ongoing opname session already exists for this user at the specified site")
+		logger.Warn("session not created, an ongoing session already exists", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID))
+		return 0, ErrSessionAlreadyActive
 	}
 
+	audit.Record(ctx, audit.Event{
+		Action:     "opname.start",
+		EntityType: "opname_session",
+		EntityID:   strconv.Itoa(newSessionID),
+		After:      map[string]any{"site_id": siteID, "dept_id": deptID},
+	})
+
 	// If session creation is successful, return the new session ID
 	return newSessionID, nil
 }
 
 // GetSessionByID retrieves an opname session by its ID.
-func (service *Service) GetSessionByID(sessionID int) (*OpnameSession, error) {
+func (service *Service) GetSessionByID(ctx context.Context, sessionID int) (*OpnameSession, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
 	// Validate sessionID
 	if sessionID <= 0 {
-		log.Printf("⚠ Invalid sessionID: %d", sessionID)
-		return nil, errors.New("invalid sessionID")
+		logger.Warn("invalid session id", "session_id", sessionID)
+		return nil, ErrInvalidSessionID
 	}
 
 	// Call the repository to get the session by ID
-	session, err := service.repo.GetSessionByID(sessionID)
+	session, err := service.repo.GetSessionByID(ctx, sessionID)
 	if err != nil {
-		log.Printf("❌ Error retrieving opname session by ID %d: %v", sessionID, err)
+		logger.Error("failed to retrieve opname session", "session_id", sessionID, "error", err)
 		return nil, err
 	}
 
 	// If no session is found, return an error
 	if session == nil {
-		log.Printf("⚠ No opname session found with ID: %d", sessionID)
-		return nil, errors.New("opname session not found")
+		logger.Debug("no opname session found", "session_id", sessionID)
+		return nil, ErrSessionNotFound
 	}
 
 	return session, nil
 }
 
+// authorizeModify loads a session and checks that the actor may edit, cancel or finish it.
+func (service *Service) authorizeModify(ctx context.Context, action string, actor roles.Actor, sessionID int) (*OpnameSession, error) {
+	session, err := service.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.policy.CanModify(ctx, action, actor, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// authorizeReview loads a session and checks that the actor is its next reviewer.
+func (service *Service) authorizeReview(ctx context.Context, action string, actor roles.Actor, sessionID int) (*OpnameSession, error) {
+	session, err := service.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.policy.CanReview(ctx, action, actor, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
 // DeleteSession deletes an opname session by its ID.
-func (service *Service) DeleteSession(sessionID int, requestingUserID int, userPosition string) error {
-	// Validate sessionID and checks if it exists.
-	session, err := service.repo.GetSessionByID(sessionID)
+func (service *Service) DeleteSession(ctx context.Context, sessionID int, actor roles.Actor) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	// Validate sessionID, checks if it exists and that the actor may cancel it.
+	session, err := service.authorizeModify(ctx, "cancel", actor, sessionID)
 	if err != nil {
-		log.Printf("❌ Error retrieving opname session by ID %d: %v", sessionID, err)
 		return err
 	}
-	if session == nil {
-		log.Printf("⚠ No opname session found with ID: %d", sessionID)
-		return errors.New("opname session not found")
+
+	// The session and its asset changes are deleted, so keep them in the audit log.
+	before := sessionSnapshot(session)
+	if progress, err := service.repo.LoadOpnameProgress(ctx, sessionID); err == nil {
+		before["asset_changes"] = progress
 	}
 
 	// Delete all the condition photos associated with the session.
-	conditionPhotos, err := service.repo.GetPhotosBySessionID(sessionID)
+	conditionPhotos, err := service.repo.GetPhotosBySessionID(ctx, sessionID)
 	if err != nil {
-		log.Printf("❌ Error retrieving condition photos for session %d: %v", sessionID, err)
-		return errors.New("failed to retrieve condition photos for session")
+		logger.Error("failed to retrieve condition photos", "session_id", sessionID, "error", err)
+		return err
 	}
 
 	for _, conditionPhotoURL := range conditionPhotos {
 		if err := service.uploadService.DeleteConditionPhoto(conditionPhotoURL); err != nil {
-			log.Printf("❌ Error deleting condition photo %s for session %d: %v", conditionPhotoURL, sessionID, err)
+			logger.Warn("failed to delete condition photo", "session_id", sessionID, "photo_url", conditionPhotoURL, "error", err)
 			return err
 		}
-		log.Printf("✅ Successfully deleted condition photo %s for session %d", conditionPhotoURL, sessionID)
+		logger.Debug("deleted condition photo", "session_id", sessionID, "photo_url", conditionPhotoURL)
 	}
 
 	// Call the repository to delete the session.
-	err = service.repo.DeleteSession(sessionID)
+	err = service.repo.DeleteSession(ctx, sessionID)
 	if err != nil {
-		log.Printf("❌ Error deleting opname session with ID %d: %v", sessionID, err)
+		logger.Error("failed to delete opname session", "session_id", sessionID, "error", err)
 		return err
 	}
 
 	// If deletion is successful, log the success.
-	log.Printf("✅ Opname session with ID %d deleted successfully", sessionID)
+	logger.Info("cancelled opname session", "session_id", sessionID, "requested_by", actor.UserID)
+	audit.Record(ctx, audit.Event{
+		Action:     "opname.cancel",
+		EntityType: "opname_session",
+		EntityID:   strconv.Itoa(sessionID),
+		Before:     before,
+	})
 	return nil
 }
 
 // ProcessAssetChanges processes the changes made to an asset during an opname session.
-func (service *Service) ProcessAssetChanges(changedAsset AssetChange) ([]byte, error) {
-	changesJSON, err := service.repo.RecordAssetChange(changedAsset)
+func (service *Service) ProcessAssetChanges(ctx context.Context, actor roles.Actor, changedAsset AssetChange) ([]byte, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if _, err := service.authorizeModify(ctx, "edit", actor, changedAsset.SessionID); err != nil {
+		return nil, err
+	}
+
+	changesJSON, err := service.repo.RecordAssetChange(ctx, changedAsset)
 	if err != nil {
-		log.Printf("❌ Error recording asset changes for session %d, asset %s: %v", changedAsset.SessionID, changedAsset.AssetTag, err)
+		logger.Error("failed to record asset changes", "session_id", changedAsset.SessionID, "asset_tag", changedAsset.AssetTag, "error", err)
 		return nil, err
 	}
 
 	// Check if changesJSON is empty ('{}')
 	if string(changesJSON) == "{}" {
-		log.Printf("‼ No changes recorded for session %d, asset %s", changedAsset.SessionID, changedAsset.AssetTag)
+		logger.Warn("no changes recorded", "session_id", changedAsset.SessionID, "asset_tag", changedAsset.AssetTag)
 	} else {
-		log.Printf("✅ Asset changes for session %d, asset %s recorded successfully", changedAsset.SessionID, changedAsset.AssetTag)
+		logger.Debug("recorded asset changes", "session_id", changedAsset.SessionID, "asset_tag", changedAsset.AssetTag)
 	}
+	audit.Record(ctx, audit.Event{
+		Action:     "opname.asset_change",
+		EntityType: "asset",
+		EntityID:   changedAsset.AssetTag,
+		After:      map[string]any{"session_id": changedAsset.SessionID, "processing_status": changedAsset.ProcessingStatus, "changes": json.RawMessage(changesJSON)},
+	})
 
 	// Return the changes to the handler
 	return changesJSON, nil
 }
 
 // RemoveAssetChange removes an asset change from an opname session.
-func (service *Service) RemoveAssetChange(sessionID int, assetTag string) error {
+func (service *Service) RemoveAssetChange(ctx context.Context, actor roles.Actor, sessionID int, assetTag string) error {
+	logger := logging.FromContext(ctx, service.logger)
+
 	// Validate sessionID and assetTag
 	if sessionID <= 0 || assetTag == "" {
-		log.Printf("⚠ Invalid sessionID or assetTag: sessionID=%d, assetTag=%s", sessionID, assetTag)
-		return errors.New("invalid sessionID or assetTag")
+		logger.Warn("invalid session id or asset tag", "session_id", sessionID, "asset_tag", assetTag)
+		return apperr.Validation("invalid_asset_change", "session_id must be a positive integer and asset_tag must not be empty")
 	}
 
-	newConditionPhotoURL, err := service.repo.GetAssetChangePhoto(sessionID, assetTag)
+	if _, err := service.authorizeModify(ctx, "edit", actor, sessionID); err != nil {
+		return err
+	}
+
+	newConditionPhotoURL, err := service.repo.GetAssetChangePhoto(ctx, sessionID, assetTag)
 	if err != nil {
-		log.Printf("❌ Error retrieving asset change for session %d, asset %s: %v", sessionID, assetTag, err)
-		return errors.New("asset change record not found")
+		logger.Error("failed to retrieve asset change", "session_id", sessionID, "asset_tag", assetTag, "error", err)
+		return err
 	}
 
 	// Call the file service to delete the old condition photo if it exists and is not empty
 	if newConditionPhotoURL != "" {
 		if err := service.uploadService.DeleteConditionPhoto(newConditionPhotoURL); err != nil {
-			log.Printf("⚠ Error deleting condition photo %s: %v", newConditionPhotoURL, err)
+			logger.Warn("failed to delete condition photo", "session_id", sessionID, "photo_url", newConditionPhotoURL, "error", err)
 			// Continue with deletion even if photo deletion fails
 		} else {
-			log.Printf("✅ Successfully deleted condition photo: %s", newConditionPhotoURL)
+			logger.Debug("deleted condition photo", "session_id", sessionID, "photo_url", newConditionPhotoURL)
 		}
 	}
 
 	// Call the repository to delete the asset change
-	err = service.repo.DeleteAssetChange(sessionID, assetTag)
+	err = service.repo.DeleteAssetChange(ctx, sessionID, assetTag)
 	if err != nil {
-		log.Printf("❌ Error deleting asset change for session %d, asset %s: %v", sessionID, assetTag, err)
+		logger.Error("failed to delete asset change", "session_id", sessionID, "asset_tag", assetTag, "error", err)
 		return err
 	}
 
-	log.Printf("✅ Asset change for session %d, asset %s deleted successfully", sessionID, assetTag)
+	logger.Debug("removed asset change", "session_id", sessionID, "asset_tag", assetTag)
+	audit.Record(ctx, audit.Event{
+		Action:     "opname.asset_change_remove",
+		EntityType: "asset",
+		EntityID:   assetTag,
+		Before:     map[string]any{"session_id": sessionID, "condition_photo_url": newConditionPhotoURL},
+	})
 	return nil
 }
 
 // LoadOpnameProgress retrieves the progress of an opname session.
-func (service *Service) LoadOpnameProgress(sessionID int) ([]OpnameSessionProgress, error) {
+func (service *Service) LoadOpnameProgress(ctx context.Context, sessionID int) ([]OpnameSessionProgress, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
 	// Validate sessionID
 	if sessionID <= 0 {
-		log.Printf("⚠ Invalid sessionID: %d", sessionID)
-		return nil, errors.New("invalid sessionID")
+		logger.Warn("invalid session id", "session_id", sessionID)
+		return nil, ErrInvalidSessionID
 	}
 
 	// Call the repository to load the opname progress
-	progress, err := service.repo.LoadOpnameProgress(sessionID)
+	progress, err := service.repo.LoadOpnameProgress(ctx, sessionID)
 	if err != nil {
-		log.Printf("❌ Error loading opname progress for session %d: %v", sessionID, err)
+		logger.Error("failed to load opname progress", "session_id", sessionID, "error", err)
 		return nil, err
 	}
 
 	if len(progress) == 0 {
 		progress = make([]OpnameSessionProgress, 0) // Return an empty slice if no progress is found.
-		log.Printf("⚠ No progress found for opname session %d", sessionID)
+		logger.Debug("no progress found for opname session", "session_id", sessionID)
 	}
 
-	log.Printf("✅ Opname progress for session %d loaded successfully", sessionID)
 	return progress, nil
 }
 
 // FinishOpnameSession marks an opname session as finished.
-func (service *Service) FinishOpnameSession(sessionID int, requestingUserID int64) error {
-	// Validate sessionID
-	if sessionID <= 0 {
-		log.Printf("⚠ Invalid sessionID: %d", sessionID)
-		return errors.New("invalid sessionID")
+func (service *Service) FinishOpnameSession(ctx context.Context, sessionID int, actor roles.Actor) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	// Validate sessionID and check that the actor may finish the session
+	session, err := service.authorizeModify(ctx, "finish", actor, sessionID)
+	if err != nil {
+		return err
 	}
+	submitterID := int64(session.UserID)
 
 	// Call the repository to finish the opname session
-	err := service.repo.FinishOpnameSession(sessionID)
+	err = service.repo.FinishOpnameSession(ctx, sessionID)
 	if err != nil {
-		log.Printf("❌ Error finishing opname session with ID %d: %v", sessionID, err)
+		logger.Error("failed to finish opname session", "session_id", sessionID, "error", err)
 		return err
 	}
+	service.recordTransition(ctx, "opname.finish", session)
 
 	// Send a notification email to the user who started the session using a Go routine.
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("‼ Panic recovered in FinishOpnameSession goroutine: %v", r)
-			}
-		}()
-
-		submitter, err := service.userRepo.GetUserByID(requestingUserID)
+	service.jobs.Submit("opname.notify_submitted", func(ctx context.Context) error {
+		submitter, err := service.userRepo.GetUserByID(ctx, submitterID)
 		if err != nil || submitter == nil {
-			log.Printf("❌ Error getting submitter: %v", err)
-			return
+			return fmt.Errorf("get submitter: %w", cmp.Or(err, errNotFound))
 		}
-		session, err := service.repo.GetSessionByID(sessionID)
+		session, err := service.repo.GetSessionByID(ctx, sessionID)
 		if err != nil || session == nil {
-			log.Printf("❌ Error getting session: %v", err)
-			return
+			return fmt.Errorf("get session: %w", cmp.Or(err, errNotFound))
 		}
 		// !! WILL COME BACK TO APPLY NEW DEPT LOGIC TO EMAIL
 		// For now, will just parse int from nullable site id as to prevent compile error
-		site, err := service.siteRepo.GetSiteByID(int(session.SiteID.Int64))
+		site, err := service.siteRepo.GetSiteByID(ctx, int(session.SiteID.Int64))
 		if err != nil || site == nil {
-			log.Printf("❌ Error getting site: %v", err)
-			return
+			return fmt.Errorf("get site: %w", cmp.Or(err, errNotFound))
 		}
 		completedDate := time.Now().Format("Mon, 02 Jan 2006 15:04:05")
 		submitterName := cases.Title(language.English).String((submitter.FirstName + " " + submitter.LastName))
 
-		// Area manager info
-		managerID, managerEmail, err := service.userRepo.GetAreaManagerInfo(int64(site.SiteID))
+		// The first reviewers on the approval path of the location, the area manager unless the path says otherwise
+		reviewers, err := service.repo.GetSessionReviewers(ctx, sessionID)
 		if err != nil {
-			log.Printf("❌ Error getting area manager info: %v", err)
-			return
+			return fmt.Errorf("get session reviewers: %w", err)
 		}
-		manager, err := service.userRepo.GetUserByID(managerID)
-		if err != nil || manager == nil {
-			log.Printf("❌ Error getting manager: %v", err)
-			return
+		if len(reviewers) == 0 {
+			logger.Warn("no active reviewer to notify", "session_id", sessionID, "stage", "submitted")
 		}
-		managerName := cases.Title(language.English).String((manager.FirstName + " " + manager.LastName))
+		reviewerNames := make([]string, 0, len(reviewers))
+		for _, reviewer := range reviewers {
+			reviewerNames = append(reviewerNames, cases.Title(language.English).String(reviewer.Name))
+		}
+		managerName := strings.Join(reviewerNames, ", ")
 
 		// Prepare the email data for user who completed the session and send it.
 		emailDataUser := email.EmailData{
@@ -274,18 +381,19 @@ func (service *Service) FinishOpnameSession(sessionID int, requestingUserID int6
 			SiteName:         site.SiteName,
 			CompletedDate:    completedDate,
 			VerificationLink: "",
-			PageLink:         os.Getenv("FRONTEND_URL") + "/site/" + strconv.Itoa(site.SiteID) + "/report?session_id=" + strconv.Itoa(sessionID),
+			PageLink:         service.app.FrontendLink("/site/" + strconv.Itoa(site.SiteID) + "/report?session_id=" + strconv.Itoa(sessionID)),
 		}
 
-		// Generate initial PDF with only submitter signature (capture submit time in Asia/Jakarta)
-		loc, _ := time.LoadLocation("Asia/Jakarta")
+		// Generate initial PDF with only submitter signature (capture submit time in the configured timezone)
+		loc := service.app.Location()
 		submitTime := time.Now().In(loc)
-		pdfBytes, pdfErr := service.reportService.GenerateBAPPDF(int64(sessionID), report.BuildSignatures(submitterName, &submitTime, "", nil, "", nil), site.SiteName, site.SiteGroupName, submitTime)
+		pdfBytes, pdfErr := service.reportService.GenerateBAPPDF(ctx, int64(sessionID), report.BuildSignatures(submitterName, &submitTime, "", nil, "", nil), site.SiteName, site.SiteGroupName, submitTime)
 		if pdfErr != nil {
-			log.Printf("❌ PDF generation failed (submitted stage): %v", pdfErr)
+			logger.Error("failed to generate BAP PDF", "session_id", sessionID, "stage", "submitted", "error", pdfErr)
 		}
 
 		if err := service.emailService.SendEmail(
+			ctx,
 			submitter.Email,
 			submitter.Username,
 			fmt.Sprintf("Opname for %s submitted", site.SiteName),
@@ -294,61 +402,64 @@ func (service *Service) FinishOpnameSession(sessionID int, requestingUserID int6
 			nil,
 			email.Attachment{Filename: fmt.Sprintf("BAP_opname_%s_%s.pdf", strings.ReplaceAll(site.SiteName, " ", "_"), time.Now().Format("02-01-2006")), ContentType: "application/pdf", Data: pdfBytes},
 		); err != nil {
-			log.Printf("❌ Error sending email to submitter: %v", err)
+			logger.Error("failed to send submission email to submitter", "session_id", sessionID, "error", err)
 		}
 
-		// Prepare the email data for the area manager and send it.
+		// Prepare the email data for the reviewers and send it.
 		emailDataAreaManager := email.EmailData{
 			Submitter:        submitterName,
 			Reviewer:         managerName,
 			SiteName:         site.SiteName,
 			CompletedDate:    completedDate,
-			VerificationLink: os.Getenv("FRONTEND_URL") + "/opname/" + strconv.Itoa(sessionID) + "/review",
+			VerificationLink: service.app.FrontendLink("/opname/" + strconv.Itoa(sessionID) + "/review"),
 			PageLink:         "",
 		}
 
-		// Reuse same PDF for manager (only submitter signature at this point)
-
-		if err := service.emailService.SendEmail(
-			managerEmail,
-			manager.Username,
-			fmt.Sprintf("Opname for %s completed by %s", site.SiteName, submitterName),
-			"opname_review_manager.html",
-			emailDataAreaManager,
-			nil,
-			email.Attachment{Filename: fmt.Sprintf("BAP_opname_%s_%s.pdf", strings.ReplaceAll(site.SiteName, " ", "_"), time.Now().Format("02-01-2006")), ContentType: "application/pdf", Data: pdfBytes},
-		); err != nil {
-			log.Printf("❌ Error sending email to manager: %v", err)
+		// Reuse same PDF for the reviewers (only submitter signature at this point)
+		for _, reviewer := range reviewers {
+			if err := service.emailService.SendEmail(
+				ctx,
+				reviewer.Email,
+				reviewer.Name,
+				fmt.Sprintf("Opname for %s completed by %s", site.SiteName, submitterName),
+				"opname_review_manager.html",
+				emailDataAreaManager,
+				nil,
+				email.Attachment{Filename: fmt.Sprintf("BAP_opname_%s_%s.pdf", strings.ReplaceAll(site.SiteName, " ", "_"), time.Now().Format("02-01-2006")), ContentType: "application/pdf", Data: pdfBytes},
+			); err != nil {
+				logger.Error("failed to send review email to reviewer", "session_id", sessionID, "recipient", reviewer.Email, "error", err)
+			}
 		}
-	}()
+		return nil
+	}, "session_id", sessionID)
 
-	log.Printf("✅ Opname session with ID %d finished successfully", sessionID)
+	logger.Info("finished opname session", "session_id", sessionID, "user_id", actor.UserID)
 	return nil
 }
 
 // GetOpnameOnLocation retrieves all opname sessions for a specific location.
-func (service *Service) GetOpnameOnLocation(siteID *int, deptID *int) ([]OpnameFilter, error) {
+func (service *Service) GetOpnameOnLocation(ctx context.Context, siteID *int, deptID *int) ([]OpnameFilter, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
 	// Ensure either siteID or deptID must be valid, only one of them must be valid.
 	if (siteID == nil || *siteID <= 0) && (deptID == nil || *deptID <= 0) {
-		log.Printf("⚠ Invalid location, ensure either site or dept id is valid. Site: %v, Dept: %v",
-			utils.ParseNullableInt(siteID), utils.ParseNullableInt(deptID))
-		return nil, errors.New("invalid location passed")
+		logger.Warn("invalid location, either site or dept id must be valid", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID))
+		return nil, ErrInvalidLocation
 	} else if (siteID != nil && *siteID >= 0) && (deptID != nil && *deptID >= 0) {
-		log.Printf("⚠ Both siteID and deptID are valid, this may lead to unexpected results. Site: %v, Dept: %v",
-			utils.ParseNullableInt(siteID), utils.ParseNullableInt(deptID))
-		return nil, errors.New("both siteID and deptID are requested for one opname session")
+		logger.Warn("invalid location, both site and dept id were requested", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID))
+		return nil, ErrInvalidLocation
 	}
 
 	// Call the repository to get all opname sessions for the location
-	sessions, err := service.repo.GetOpnameOnLocation(siteID, deptID)
+	sessions, err := service.repo.GetOpnameOnLocation(ctx, siteID, deptID)
 	if err != nil {
-		log.Printf("❌ Error retrieving opname sessions for site %d: or dept: %d | err: %v", *siteID, *deptID, err)
+		logger.Error("failed to retrieve opname sessions for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID), "error", err)
 		return nil, err
 	}
 
 	if len(sessions) == 0 {
-		log.Printf("⚠ No opname sessions found for site %d", siteID)
-		return nil, errors.New("no opname sessions found for this site")
+		logger.Debug("no opname sessions found for location", "site_id", logging.Optional(siteID), "dept_id", logging.Optional(deptID))
+		return nil, apperr.NotFound("opname_sessions_not_found", "no opname sessions found for this location")
 	}
 
 	// Parse the completed date to a YYYY-MM-DD format.
@@ -358,56 +469,77 @@ func (service *Service) GetOpnameOnLocation(siteID *int, deptID *int) ([]OpnameF
 		}
 	}
 
-	log.Printf("✅ Opname sessions for site %d retrieved successfully", siteID)
 	return sessions, nil
 }
 
 // ApproveOpnameSession verifies an opname session by its ID.
-func (service *Service) ApproveOpnameSession(sessionID int, reviewerID int) error {
+// When the second reviewer verifies a session whose loss exceeds opname.loss_threshold, the session goes to "Loss Review" instead
+// and is verified once a loss approver approves it as well.
+func (service *Service) ApproveOpnameSession(ctx context.Context, sessionID int, actor roles.Actor) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	reviewerID := int(actor.UserID)
+
 	// Validate sessionID and reviewerID
 	if sessionID <= 0 || reviewerID <= 0 {
-		log.Printf("⚠ Invalid sessionID or reviewerID: sessionID=%d, reviewerID=%d", sessionID, reviewerID)
-		return errors.New("invalid sessionID or reviewerID")
+		logger.Warn("invalid session id or reviewer id", "session_id", sessionID, "reviewer_id", reviewerID)
+		return apperr.Validation("invalid_review", "session_id and reviewer_id must be positive integers")
 	}
 
-	// Call the repository to verify the opname session
-	err := service.repo.ApproveOpnameSession(sessionID, reviewerID)
+	// Only the next reviewer on the location's approval path may approve
+	reviewed, err := service.authorizeReview(ctx, "approve", actor, sessionID)
 	if err != nil {
-		log.Printf("❌ Error approving opname session with ID %d: %v", sessionID, err)
 		return err
 	}
 
-	// Init the ccEmails
-	var ccEmails []string
-
-	// Get the reviewer details
-	reviewer, _ := service.userRepo.GetUserByID(int64(reviewerID))
+	// The reviewer signs the notifications sent after the approval
+	reviewer, err := service.userRepo.GetUserByID(ctx, int64(reviewerID))
+	if err != nil {
+		return err
+	}
+	if reviewer == nil {
+		logger.Warn("reviewer not found", "session_id", sessionID, "reviewer_id", reviewerID)
+		return user.ErrUserNotFound
+	}
 
-	// Get the reviewer's position
-	var reviewerPosition = reviewer.Position
+	// Call the repository to verify the opname session, or to approve its loss
+	action := "opname.approve"
+	switch reviewed.Status {
+	case "Loss Review":
+		action = "opname.approve_loss"
+		err = service.repo.ReviewLoss(ctx, sessionID, reviewerID, true)
+	case "Escalated":
+		err = service.verifyEscalated(ctx, sessionID, reviewerID)
+	default:
+		err = service.repo.ApproveOpnameSession(ctx, sessionID, reviewerID)
+	}
+	if err != nil {
+		logger.Error("failed to approve opname session", "session_id", sessionID, "reviewer_id", reviewerID, "error", err)
+		return err
+	}
+	service.recordTransition(ctx, action, reviewed)
 
-	// Get the opname session info
-	session, _ := service.repo.GetSessionByID(sessionID)
+	// Init the ccEmails
+	var ccEmails []string
 
-	// If a manager approves the session, send a notification email to the user who started the session and request verification from L1 support.
-	if strings.ToLower(reviewerPosition) == "area manager" {
-		go func() {
-			defer func() {
-				if r := recover(); r != nil {
-					log.Printf("‼ Panic recovered in ApproveOpnameSession (area manager) goroutine: %v", r)
-				}
-			}()
+	// Get the opname session info. The approval is already stored, so a failure only skips the notifications.
+	session, err := service.repo.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		logger.Error("failed to retrieve approved opname session, notifications skipped", "session_id", sessionID, "error", err)
+	}
 
-			submitter, err := service.userRepo.GetUserByID(int64(session.UserID))
+	// If the first reviewer approves the session, send a notification email to the user who started the session and request
+	// verification from the second reviewers on the approval path (L1 support unless the path says otherwise).
+	if session != nil && session.Status == "Escalated" {
+		service.jobs.Submit("opname.notify_manager_approved", func(ctx context.Context) error {
+			submitter, err := service.userRepo.GetUserByID(ctx, int64(session.UserID))
 			if err != nil || submitter == nil {
-				log.Printf("❌ Error getting submitter: %v", err)
-				return
+				return fmt.Errorf("get submitter: %w", cmp.Or(err, errNotFound))
 			}
 			managerName := cases.Title(language.English).String((reviewer.FirstName + " " + reviewer.LastName))
-			site, err := service.siteRepo.GetSiteByID(int(session.SiteID.Int64))
+			site, err := service.siteRepo.GetSiteByID(ctx, int(session.SiteID.Int64))
 			if err != nil || site == nil {
-				log.Printf("❌ Error getting site: %v", err)
-				return
+				return fmt.Errorf("get site: %w", cmp.Or(err, errNotFound))
 			}
 			completedDate := time.Now().Format("Mon, 02 Jan 2006 15:04:05")
 
@@ -417,11 +549,11 @@ func (service *Service) ApproveOpnameSession(sessionID int, reviewerID int) erro
 				SiteName:         site.SiteName,
 				CompletedDate:    completedDate,
 				VerificationLink: "",
-				PageLink:         os.Getenv("FRONTEND_URL") + "/site/" + strconv.Itoa(site.SiteID) + "/report?session_id=" + strconv.Itoa(sessionID),
+				PageLink:         service.app.FrontendLink("/site/" + strconv.Itoa(site.SiteID) + "/report?session_id=" + strconv.Itoa(sessionID)),
 			}
 
 			// Generate escalated PDF (submitter + manager signatures) with timestamps
-			loc, _ := time.LoadLocation("Asia/Jakarta")
+			loc := service.app.Location()
 			// Parse submit time from session end date if available
 			var submitPtr *time.Time
 			if session.EndDate.Valid {
@@ -431,12 +563,13 @@ func (service *Service) ApproveOpnameSession(sessionID int, reviewerID int) erro
 				}
 			}
 			mgrTime := time.Now().In(loc)
-			pdfBytes, pdfErr := service.reportService.GenerateBAPPDF(int64(sessionID), report.BuildSignatures(cases.Title(language.English).String(submitter.FirstName+" "+submitter.LastName), submitPtr, managerName, &mgrTime, "", nil), site.SiteName, site.SiteGroupName, submitPtrOrNow(submitPtr, mgrTime))
+			pdfBytes, pdfErr := service.reportService.GenerateBAPPDF(ctx, int64(sessionID), report.BuildSignatures(cases.Title(language.English).String(submitter.FirstName+" "+submitter.LastName), submitPtr, managerName, &mgrTime, "", nil), site.SiteName, site.SiteGroupName, submitPtrOrNow(submitPtr, mgrTime))
 			if pdfErr != nil {
-				log.Printf("❌ PDF generation failed (escalated stage submitter email): %v", pdfErr)
+				logger.Error("failed to generate BAP PDF", "session_id", sessionID, "stage", "escalated", "error", pdfErr)
 			}
 
 			if err := service.emailService.SendEmail(
+				ctx,
 				submitter.Email,
 				submitter.Username,
 				fmt.Sprintf("Opname for %s approved by %s", site.SiteName, managerName),
@@ -445,17 +578,19 @@ func (service *Service) ApproveOpnameSession(sessionID int, reviewerID int) erro
 				ccEmails,
 				email.Attachment{Filename: fmt.Sprintf("BAP_opname_%s_%s.pdf", strings.ReplaceAll(site.SiteName, " ", "_"), time.Now().Format("02-01-2006")), ContentType: "application/pdf", Data: pdfBytes},
 			); err != nil {
-				log.Printf("❌ Error sending email to submitter: %v", err)
+				logger.Error("failed to send approval email to submitter", "session_id", sessionID, "error", err)
 			}
 
-			l1SupportEmails, err := service.userRepo.GetL1SupportEmails()
+			verifiers, err := service.repo.GetSessionReviewers(ctx, sessionID)
 			if err != nil {
-				log.Printf("❌ Error getting L1 support emails: %v", err)
-				return
+				return fmt.Errorf("get session reviewers: %w", err)
+			}
+			if len(verifiers) == 0 {
+				logger.Warn("no active reviewer to notify", "session_id", sessionID, "stage", "escalated")
 			}
 			opnameCompletedDateStr, err := time.Parse(time.RFC3339, session.EndDate.String)
 			if err != nil {
-				log.Printf("❌ Error parsing session end date: %v", err)
+				logger.Error("failed to parse session end date", "session_id", sessionID, "error", err)
 				opnameCompletedDateStr = time.Now()
 			}
 			opnameCompletedDate := opnameCompletedDateStr.Format("Mon, 02 Jan 2006 15:04:05")
@@ -464,47 +599,44 @@ func (service *Service) ApproveOpnameSession(sessionID int, reviewerID int) erro
 				Reviewer:         managerName,
 				SiteName:         site.SiteName,
 				CompletedDate:    opnameCompletedDate,
-				VerificationLink: os.Getenv("FRONTEND_URL") + "/opname/" + strconv.Itoa(sessionID) + "/review",
+				VerificationLink: service.app.FrontendLink("/opname/" + strconv.Itoa(sessionID) + "/review"),
 				PageLink:         "",
 			}
 
-			for _, emailAddr := range l1SupportEmails {
+			for _, verifier := range verifiers {
 				if err := service.emailService.SendEmail(
-					emailAddr,
-					"L1 Support Team",
+					ctx,
+					verifier.Email,
+					verifier.Name,
 					fmt.Sprintf("Opname for %s needs your verification!", site.SiteName),
 					"opname_verification_needed.html",
 					emailDataL1,
 					ccEmails,
 					email.Attachment{Filename: fmt.Sprintf("BAP_opname_%s_%s.pdf", strings.ReplaceAll(site.SiteName, " ", "_"), time.Now().Format("02-01-2006")), ContentType: "application/pdf", Data: pdfBytes},
 				); err != nil {
-					log.Printf("❌ Error sending email to L1 support (%s): %v", emailAddr, err)
+					logger.Error("failed to send verification email to reviewer", "session_id", sessionID, "recipient", verifier.Email, "error", err)
 				}
 			}
-		}()
-	} else if strings.ToLower(reviewerPosition) == "l1 support" {
-		go func() {
-			defer func() {
-				if r := recover(); r != nil {
-					log.Printf("‼ Panic recovered in ApproveOpnameSession (l1 support) goroutine: %v", r)
-				}
-			}()
-
-			submitter, err := service.userRepo.GetUserByID(int64(session.UserID))
+			return nil
+		}, "session_id", sessionID)
+	} else if session != nil && session.Status == "Loss Review" {
+		// The second reviewer verified a session losing more than the threshold, request the approval of the loss approvers.
+		service.notifyLossApprovers(ctx, sessionID, session, reviewer)
+	} else if session != nil && session.Status == "Verified" {
+		service.jobs.Submit("opname.notify_verified", func(ctx context.Context) error {
+			submitter, err := service.userRepo.GetUserByID(ctx, int64(session.UserID))
 			if err != nil || submitter == nil {
-				log.Printf("❌ Error getting submitter: %v", err)
-				return
+				return fmt.Errorf("get submitter: %w", cmp.Or(err, errNotFound))
 			}
-			l1User, err := service.userRepo.GetUserByID(int64(reviewerID))
+			// The session may have been verified by a loss approver after its second reviewer
+			l1User, err := service.userRepo.GetUserByID(ctx, session.L1ReviewerID.Int64)
 			if err != nil || l1User == nil {
-				log.Printf("❌ Error getting L1 user: %v", err)
-				return
+				return fmt.Errorf("get L1 reviewer: %w", cmp.Or(err, errNotFound))
 			}
 			l1Name := cases.Title(language.English).String((l1User.FirstName + " " + l1User.LastName))
-			site, err := service.siteRepo.GetSiteByID(int(session.SiteID.Int64))
+			site, err := service.siteRepo.GetSiteByID(ctx, int(session.SiteID.Int64))
 			if err != nil || site == nil {
-				log.Printf("❌ Error getting site: %v", err)
-				return
+				return fmt.Errorf("get site: %w", cmp.Or(err, errNotFound))
 			}
 			completedDate := time.Now().Format("Mon, 02 Jan 2006 15:04:05")
 
@@ -514,11 +646,11 @@ func (service *Service) ApproveOpnameSession(sessionID int, reviewerID int) erro
 				SiteName:         site.SiteName,
 				CompletedDate:    completedDate,
 				VerificationLink: "",
-				PageLink:         os.Getenv("FRONTEND_URL") + "/site/" + strconv.Itoa(site.SiteID) + "/report?session_id=" + strconv.Itoa(sessionID),
+				PageLink:         service.app.FrontendLink("/site/" + strconv.Itoa(site.SiteID) + "/report?session_id=" + strconv.Itoa(sessionID)),
 			}
 
 			// Generate verified PDF (submitter + manager (if any) + l1 signatures)
-			loc, _ := time.LoadLocation("Asia/Jakarta")
+			loc := service.app.Location()
 			var submitPtr *time.Time
 			if session.EndDate.Valid {
 				if t, err := time.Parse(time.RFC3339, session.EndDate.String); err == nil {
@@ -529,7 +661,10 @@ func (service *Service) ApproveOpnameSession(sessionID int, reviewerID int) erro
 			var mgrName string
 			var mgrTimePtr *time.Time
 			if session.ManagerReviewerID.Valid {
-				mgrUser, _ := service.userRepo.GetUserByID(session.ManagerReviewerID.Int64)
+				mgrUser, err := service.userRepo.GetUserByID(ctx, session.ManagerReviewerID.Int64)
+				if err != nil {
+					logger.Warn("failed to retrieve manager reviewer, signature left blank", "session_id", sessionID, "error", err)
+				}
 				if mgrUser != nil {
 					mgrName = cases.Title(language.English).String(mgrUser.FirstName + " " + mgrUser.LastName)
 				}
@@ -541,85 +676,94 @@ func (service *Service) ApproveOpnameSession(sessionID int, reviewerID int) erro
 				}
 			}
 			l1Time := time.Now().In(loc)
-			pdfBytes, pdfErr := service.reportService.GenerateBAPPDF(int64(sessionID), report.BuildSignatures(cases.Title(language.English).String(submitter.FirstName+" "+submitter.LastName), submitPtr, mgrName, mgrTimePtr, l1Name, &l1Time), site.SiteName, site.SiteGroupName, submitPtrOrNow(submitPtr, l1Time))
+			pdfBytes, pdfErr := service.reportService.GenerateBAPPDF(ctx, int64(sessionID), report.BuildSignatures(cases.Title(language.English).String(submitter.FirstName+" "+submitter.LastName), submitPtr, mgrName, mgrTimePtr, l1Name, &l1Time), site.SiteName, site.SiteGroupName, submitPtrOrNow(submitPtr, l1Time))
 			if pdfErr != nil {
-				log.Printf("❌ PDF generation failed (verified stage): %v", pdfErr)
+				logger.Error("failed to generate BAP PDF", "session_id", sessionID, "stage", "verified", "error", pdfErr)
 			}
 
 			if err := service.emailService.SendEmail(
+				ctx,
 				submitter.Email,
 				submitter.Username,
-				fmt.Sprintf("Opname for %s approved by L1 Support Team", site.SiteName),
+				fmt.Sprintf("Opname for %s verified by %s", site.SiteName, l1Name),
 				"opname_verified.html",
 				emailDataUser,
 				ccEmails,
 				email.Attachment{Filename: fmt.Sprintf("BAP_opname_%s_%s.pdf", strings.ReplaceAll(site.SiteName, " ", "_"), time.Now().Format("02-01-2006")), ContentType: "application/pdf", Data: pdfBytes},
 			); err != nil {
-				log.Printf("❌ Error sending email to submitter: %v", err)
+				logger.Error("failed to send verification email to submitter", "session_id", sessionID, "error", err)
 			}
-		}()
+			return nil
+		}, "session_id", sessionID)
 	}
 
-	log.Printf("✅ Opname session with ID %d verified successfully by user %d", sessionID, reviewerID)
+	logger.Info("approved opname session", "session_id", sessionID, "reviewer_id", reviewerID, "reviewer_position", reviewer.Position)
 	return nil
 }
 
 // RejectOpnameSession rejects an opname session by its ID.
-func (service *Service) RejectOpnameSession(sessionID int, reviewerID int) error {
+func (service *Service) RejectOpnameSession(ctx context.Context, sessionID int, actor roles.Actor) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	reviewerID := int(actor.UserID)
+
 	// Validate sessionID and reviewerID
 	if sessionID <= 0 || reviewerID <= 0 {
-		log.Printf("⚠ Invalid sessionID or reviewerID: sessionID=%d, reviewerID=%d", sessionID, reviewerID)
-		return errors.New("invalid sessionID or reviewerID")
+		logger.Warn("invalid session id or reviewer id", "session_id", sessionID, "reviewer_id", reviewerID)
+		return apperr.Validation("invalid_review", "session_id and reviewer_id must be positive integers")
 	}
 
-	// Call the repository to reject the opname session
-	err := service.repo.RejectOpnameSession(sessionID, reviewerID)
+	// Only the next reviewer on the location's approval path may reject
+	reviewed, err := service.authorizeReview(ctx, "reject", actor, sessionID)
 	if err != nil {
-		log.Printf("❌ Error rejecting opname session with ID %d: %v", sessionID, err)
 		return err
 	}
 
-	// Send a notification email to the user who started the session.
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("‼ Panic recovered in RejectOpnameSession goroutine: %v", r)
-			}
-		}()
+	// Call the repository to reject the opname session, or its loss when it waits for a loss approval
+	action := "opname.reject"
+	if reviewed.Status == "Loss Review" {
+		action = "opname.reject_loss"
+		err = service.repo.ReviewLoss(ctx, sessionID, reviewerID, false)
+	} else {
+		err = service.repo.RejectOpnameSession(ctx, sessionID, reviewerID)
+	}
+	if err != nil {
+		logger.Error("failed to reject opname session", "session_id", sessionID, "reviewer_id", reviewerID, "error", err)
+		return err
+	}
+	service.recordTransition(ctx, action, reviewed)
 
+	// Send a notification email to the user who started the session.
+	service.jobs.Submit("opname.notify_rejected", func(ctx context.Context) error {
 		// Get the reviewer details
-		reviewer, err := service.userRepo.GetUserByID(int64(reviewerID))
+		reviewer, err := service.userRepo.GetUserByID(ctx, int64(reviewerID))
 		if err != nil || reviewer == nil {
-			log.Printf("❌ Error getting reviewer: %v", err)
-			return
+			return fmt.Errorf("get reviewer: %w", cmp.Or(err, errNotFound))
 		}
 		reviewerName := cases.Title(language.English).String((reviewer.FirstName + " " + reviewer.LastName))
 
 		// Get the opname session info
-		session, err := service.repo.GetSessionByID(sessionID)
+		session, err := service.repo.GetSessionByID(ctx, sessionID)
 		if err != nil || session == nil {
-			log.Printf("❌ Error getting session: %v", err)
-			return
+			return fmt.Errorf("get session: %w", cmp.Or(err, errNotFound))
 		}
 
 		opnameCompletedDateStr, err := time.Parse(time.RFC3339, session.EndDate.String)
 		if err != nil {
-			log.Printf("❌ Error parsing session end date: %v", err)
+			logger.Error("failed to parse session end date", "session_id", sessionID, "error", err)
 			opnameCompletedDateStr = time.Now()
 		}
 		opnameCompletedDate := opnameCompletedDateStr.Format("Mon, 02 Jan 2006 15:04:05")
 
-		submitter, err := service.userRepo.GetUserByID(int64(session.UserID))
+		submitter, err := service.userRepo.GetUserByID(ctx, int64(session.UserID))
 		if err != nil {
-			log.Printf("❌ Error getting submitter: %v", err)
-			return
+			return fmt.Errorf("get submitter: %w", cmp.Or(err, errNotFound))
 		}
 		submitterName := cases.Title(language.English).String((submitter.FirstName + " " + submitter.LastName))
 
-		site, err := service.siteRepo.GetSiteByID(int(session.SiteID.Int64))
+		site, err := service.siteRepo.GetSiteByID(ctx, int(session.SiteID.Int64))
 		if err != nil {
-			log.Printf("❌ Error getting site: %v", err)
-			return
+			return fmt.Errorf("get site: %w", cmp.Or(err, errNotFound))
 		}
 
 		// Prepare the email data for the user who started the session.
@@ -629,26 +773,23 @@ func (service *Service) RejectOpnameSession(sessionID int, reviewerID int) error
 			SiteName:         site.SiteName,
 			CompletedDate:    opnameCompletedDate,
 			VerificationLink: "",
-			PageLink:         os.Getenv("FRONTEND_URL") + "/site/" + strconv.Itoa(site.SiteID) + "/report?session_id=" + strconv.Itoa(sessionID),
+			PageLink:         service.app.FrontendLink("/site/" + strconv.Itoa(site.SiteID) + "/report?session_id=" + strconv.Itoa(sessionID)),
 		}
 
 		var ccEmails []string
 
-		// If the reviewer is an L1 support, we cc the area manager.
-		if strings.ToLower(reviewer.Position) == "l1 support" {
-			// Get the area manager's email
-			_, areaManagerEmail, err := service.userRepo.GetAreaManagerInfo(int64(site.SiteID))
-			if err != nil {
-				log.Printf("❌ Error getting area manager info: %v", err)
-				return
+		// If the session was rejected after its first review, we cc the first reviewer who approved it.
+		if session.ManagerReviewerID.Valid && session.ManagerReviewerID.Int64 != int64(reviewerID) {
+			firstReviewer, err := service.userRepo.GetUserByID(ctx, session.ManagerReviewerID.Int64)
+			if err != nil || firstReviewer == nil {
+				return fmt.Errorf("get first reviewer: %w", cmp.Or(err, errNotFound))
 			}
-
-			// Add the area manager's email to the ccEmails
-			ccEmails = []string{areaManagerEmail}
+			ccEmails = []string{firstReviewer.Email}
 		}
 
 		// Send the email
-		service.emailService.SendEmail(
+		return service.emailService.SendEmail(
+			ctx,
 			submitter.Email,
 			submitter.Username,
 			fmt.Sprintf("Opname for %s rejected by %s", site.SiteName, reviewerName),
@@ -656,13 +797,106 @@ func (service *Service) RejectOpnameSession(sessionID int, reviewerID int) error
 			emailData,
 			ccEmails,
 		)
+	}, "session_id", sessionID)
 
-	}()
-
-	log.Printf("✅ Opname session with ID %d rejected successfully by user %d", sessionID, reviewerID)
+	logger.Info("rejected opname session", "session_id", sessionID, "reviewer_id", reviewerID)
 	return nil
 }
 
+// verifyEscalated verifies an escalated session on behalf of its second reviewer. When the net book value of its broken and missing
+// assets exceeds opname.loss_threshold, the session is left waiting for a loss approval instead.
+func (service *Service) verifyEscalated(ctx context.Context, sessionID int, reviewerID int) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if service.config.LossThreshold <= 0 {
+		return service.repo.ApproveOpnameSession(ctx, sessionID, reviewerID)
+	}
+
+	// Without stats the loss is unknown, so the session must not be verified as if it had none.
+	stats, err := service.reportService.GetOpnameStats(ctx, int64(sessionID))
+	if err != nil {
+		return err
+	}
+	if stats == nil {
+		logger.Error("no opname stats to check the loss of the session against", "session_id", sessionID)
+		return apperr.Internal(fmt.Errorf("get opname stats of session %d: %w", sessionID, errNotFound))
+	}
+	if !service.config.RequiresLossApproval(stats.LossValue()) {
+		return service.repo.ApproveOpnameSession(ctx, sessionID, reviewerID)
+	}
+
+	logger.Info("opname session loss exceeds threshold", "session_id", sessionID, "loss_value", stats.LossValue(), "loss_threshold", service.config.LossThreshold)
+	return service.repo.RequestLossApproval(ctx, sessionID, reviewerID, stats.LossValue(), service.config.LossThreshold)
+}
+
+// notifyLossApprovers emails the BAP of a session waiting for a loss approval to every active loss approver in the background.
+func (service *Service) notifyLossApprovers(ctx context.Context, sessionID int, session *OpnameSession, l1Reviewer *user.User) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	service.jobs.Submit("opname.notify_loss_approval_needed", func(ctx context.Context) error {
+		submitter, err := service.userRepo.GetUserByID(ctx, int64(session.UserID))
+		if err != nil || submitter == nil {
+			return fmt.Errorf("get submitter: %w", cmp.Or(err, errNotFound))
+		}
+		site, err := service.siteRepo.GetSiteByID(ctx, int(session.SiteID.Int64))
+		if err != nil || site == nil {
+			return fmt.Errorf("get site: %w", cmp.Or(err, errNotFound))
+		}
+		stats, err := service.reportService.GetOpnameStats(ctx, int64(sessionID))
+		if err != nil || stats == nil || stats.LossApproval == nil {
+			return fmt.Errorf("get loss approval: %w", cmp.Or(err, errNotFound))
+		}
+		approverEmails, err := service.userRepo.GetEmailsByPositions(ctx, service.config.LossApproverPositions)
+		if err != nil {
+			return fmt.Errorf("get loss approver emails: %w", err)
+		}
+		if len(approverEmails) == 0 {
+			logger.Warn("no active loss approver to notify", "session_id", sessionID, "positions", service.config.LossApproverPositions)
+			return nil
+		}
+
+		// The BAP shows the signatures so far and the financial impact of the session.
+		pdfBytes, filename, pdfErr := service.reportService.GenerateAndAssembleBAP(ctx, int64(sessionID))
+		if pdfErr != nil {
+			logger.Error("failed to generate BAP PDF", "session_id", sessionID, "stage", "loss_review", "error", pdfErr)
+		}
+
+		completedDate := time.Now().Format("Mon, 02 Jan 2006 15:04:05")
+		if submitTime, err := time.Parse(time.RFC3339, session.EndDate.String); err == nil {
+			completedDate = submitTime.Format("Mon, 02 Jan 2006 15:04:05")
+		}
+		var l1Name string
+		if l1Reviewer != nil {
+			l1Name = cases.Title(language.English).String(l1Reviewer.FirstName + " " + l1Reviewer.LastName)
+		}
+		emailData := email.EmailData{
+			Submitter:        cases.Title(language.English).String(submitter.FirstName + " " + submitter.LastName),
+			Reviewer:         l1Name,
+			SiteName:         site.SiteName,
+			CompletedDate:    completedDate,
+			VerificationLink: service.app.FrontendLink("/opname/" + strconv.Itoa(sessionID) + "/review"),
+			LossValue:        utils.FormatRupiah(stats.LossApproval.LossValue),
+			LossThreshold:    utils.FormatRupiah(stats.LossApproval.LossThreshold),
+		}
+
+		for _, emailAddr := range approverEmails {
+			if err := service.emailService.SendEmail(
+				ctx,
+				emailAddr,
+				"Loss Approver",
+				fmt.Sprintf("Opname for %s needs your loss approval!", site.SiteName),
+				"opname_loss_approval_needed.html",
+				emailData,
+				nil,
+				email.Attachment{Filename: filename, ContentType: "application/pdf", Data: pdfBytes},
+			); err != nil {
+				logger.Error("failed to send loss approval email", "session_id", sessionID, "recipient", emailAddr, "error", err)
+			}
+		}
+		return nil
+	}, "session_id", sessionID)
+}
+
 // submitPtrOrNow returns the submit time if not nil else fallback time (usually reviewer time)
 func submitPtrOrNow(submit *time.Time, fallback time.Time) time.Time {
 	if submit != nil {
@@ -672,44 +906,60 @@ func submitPtrOrNow(submit *time.Time, fallback time.Time) time.Time {
 }
 
 // GetUserFromOpnameSession retrieves the user who started an opname session.
-func (service *Service) GetUserFromOpnameSession(sessionID int) (*user.User, error) {
+func (service *Service) GetUserFromOpnameSession(ctx context.Context, sessionID int) (*user.User, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
 	// Validate sessionID
 	if sessionID <= 0 {
-		log.Printf("⚠ Invalid sessionID: %d", sessionID)
-		return nil, errors.New("invalid sessionID")
+		logger.Warn("invalid session id", "session_id", sessionID)
+		return nil, ErrInvalidSessionID
 	}
 
 	// Call the repository to get the user from the session
-	user, err := service.repo.GetUserFromOpnameSession(sessionID)
+	user, err := service.repo.GetUserFromOpnameSession(ctx, sessionID)
 	if err != nil {
-		log.Printf("❌ Error retrieving user from opname session %d: %v", sessionID, err)
+		logger.Error("failed to retrieve user for opname session", "session_id", sessionID, "error", err)
 		return nil, err
 	}
 
 	if user == nil {
-		log.Printf("⚠ No user found for opname session %d", sessionID)
-		return nil, errors.New("user not found for this opname session")
+		logger.Debug("no user found for opname session", "session_id", sessionID)
+		return nil, apperr.NotFound("opname_user_not_found", "user not found for this opname session")
 	}
 
-	log.Printf("✅ User retrieved successfully for opname session %d", sessionID)
 	return user, nil
 }
 
 // GetUnscannedAssets retrieves all unscanned assets for a specific opname session.
-func (service *Service) GetUnscannedAssets(sessionID int) ([]*asset.Asset, error) {
+func (service *Service) GetUnscannedAssets(ctx context.Context, sessionID int) ([]*asset.Asset, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
 	// Validate sessionID
 	if sessionID <= 0 {
-		log.Printf("⚠ Invalid sessionID: %d", sessionID)
-		return nil, errors.New("invalid sessionID")
+		logger.Warn("invalid session id", "session_id", sessionID)
+		return nil, ErrInvalidSessionID
 	}
 
 	// Call the repository to get unscanned assets
-	unscannedAssets, err := service.repo.GetUnscannedAssets(sessionID)
+	unscannedAssets, err := service.repo.GetUnscannedAssets(ctx, sessionID)
 	if err != nil {
-		log.Printf("❌ Error retrieving unscanned assets for opname session %d: %v", sessionID, err)
+		logger.Error("failed to retrieve unscanned assets", "session_id", sessionID, "error", err)
 		return nil, err
 	}
 
-	log.Printf("✅ Unscanned assets retrieved successfully for opname session %d", sessionID)
+	logger.Debug("retrieved unscanned assets", "session_id", sessionID, "count", len(unscannedAssets))
 	return unscannedAssets, nil
 }
+
+// GetExpectedOffSiteAssets retrieves the assets of the session's location that are on loan.
+// They are not required to be scanned and are reported as expected off-site rather than missing.
+func (service *Service) GetExpectedOffSiteAssets(ctx context.Context, sessionID int) ([]*ExpectedOffSiteAsset, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if sessionID <= 0 {
+		logger.Warn("invalid session id", "session_id", sessionID)
+		return nil, ErrInvalidSessionID
+	}
+
+	return service.repo.GetExpectedOffSiteAssets(ctx, sessionID)
+}
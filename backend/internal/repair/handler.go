@@ -0,0 +1,253 @@
+// == Handles API requests related to repair tickets ==
+package repair
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errMissingUser = apperr.Unauthorized("unauthorized", "user not found in context")
+
+type Handler struct {
+	service *Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates a new repair ticket handler.
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetTicketsHandler lists repair tickets, filterable by asset_tag, session_id, site_id (of the asset) and status.
+func (handler *Handler) GetTicketsHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	var filter Filter
+	if err := context.ShouldBindQuery(&filter); err != nil {
+		logger.Warn("invalid repair ticket filter", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_query", "invalid query parameters: "+err.Error()))
+		return
+	}
+
+	tickets, totalCount, err := handler.service.GetTickets(context.Request.Context(), filter)
+	if err != nil {
+		logger.Warn("failed to retrieve repair tickets", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	serialized := make([]gin.H, 0, len(tickets))
+	for _, ticket := range tickets {
+		serialized = append(serialized, serializeTicket(ticket))
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"tickets":     serialized,
+		"total_count": totalCount,
+	})
+}
+
+// GetTicketByIDHandler retrieves a repair ticket with its photos.
+func (handler *Handler) GetTicketByIDHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	ticketID, ok := ticketIDParam(context)
+	if !ok {
+		return
+	}
+
+	ticket, err := handler.service.GetTicketByID(context.Request.Context(), ticketID)
+	if err != nil {
+		logger.Warn("failed to retrieve repair ticket", "ticket_id", ticketID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, serializeTicket(ticket))
+}
+
+// UpdateTicketHandler sets the vendor, cost estimate and dates of an open repair ticket.
+func (handler *Handler) UpdateTicketHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	ticketID, ok := ticketIDParam(context)
+	if !ok {
+		return
+	}
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
+		return
+	}
+
+	var request UpdateRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid repair ticket update request", "ticket_id", ticketID, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	ticket, err := handler.service.UpdateTicket(context.Request.Context(), actor, ticketID, request)
+	if err != nil {
+		logger.Warn("failed to update repair ticket", "ticket_id", ticketID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "repair ticket updated successfully",
+		"ticket":  serializeTicket(ticket),
+	})
+}
+
+// AddPhotoHandler attaches a photo uploaded through /api/upload/photo to a repair ticket.
+func (handler *Handler) AddPhotoHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	ticketID, ok := ticketIDParam(context)
+	if !ok {
+		return
+	}
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
+		return
+	}
+
+	var request PhotoRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid repair ticket photo request", "ticket_id", ticketID, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	ticket, err := handler.service.AddPhoto(context.Request.Context(), actor, ticketID, request)
+	if err != nil {
+		logger.Warn("failed to add repair ticket photo", "ticket_id", ticketID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{
+		"message": "photo added successfully",
+		"ticket":  serializeTicket(ticket),
+	})
+}
+
+// ResolveTicketHandler closes a repair ticket as repaired or beyond repair.
+func (handler *Handler) ResolveTicketHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	ticketID, ok := ticketIDParam(context)
+	if !ok {
+		return
+	}
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
+		return
+	}
+
+	var request ResolveRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid repair ticket resolution request", "ticket_id", ticketID, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	ticket, err := handler.service.ResolveTicket(context.Request.Context(), actor, ticketID, request)
+	if err != nil {
+		logger.Warn("failed to resolve repair ticket", "ticket_id", ticketID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "repair ticket resolved successfully",
+		"ticket":  serializeTicket(ticket),
+	})
+}
+
+// serializeTicket converts a ticket to its JSON form. Photos are only included when they were loaded.
+func serializeTicket(ticket *Ticket) gin.H {
+	serialized := gin.H{
+		"id":                   ticket.ID,
+		"asset_tag":            ticket.AssetTag,
+		"serial_number":        ticket.SerialNumber,
+		"brand_name":           ticket.BrandName,
+		"product_name":         ticket.ProductName,
+		"product_variety":      ticket.ProductVariety,
+		"site_id":              ticket.SiteID,
+		"site_name":            ticket.SiteName,
+		"asset_status":         ticket.AssetStatus,
+		"session_id":           utils.SerializeNI(ticket.SessionID),
+		"status":               ticket.Status,
+		"problem":              ticket.Problem,
+		"previous_status":      ticket.PreviousStatus,
+		"vendor":               ticket.Vendor,
+		"cost_estimate":        utils.SerializeNI(ticket.CostEstimate),
+		"repair_cost":          utils.SerializeNI(ticket.RepairCost),
+		"sent_date":            serializeDate(ticket.SentDate),
+		"expected_return_date": serializeDate(ticket.ExpectedReturnDate),
+		"opened_at":            serializeTime(ticket.OpenedAt),
+		"updated_by":           utils.SerializeNI(ticket.UpdatedBy),
+		"updated_at":           serializeTime(ticket.UpdatedAt),
+		"resolved_by":          utils.SerializeNI(ticket.ResolvedBy),
+		"resolved_by_name":     utils.SerializeNS(ticket.ResolvedByName),
+		"resolved_at":          serializeTime(ticket.ResolvedAt),
+		"resolution_notes":     ticket.ResolutionNotes,
+	}
+	if ticket.Photos != nil {
+		photos := make([]gin.H, 0, len(ticket.Photos))
+		for _, photo := range ticket.Photos {
+			photos = append(photos, gin.H{
+				"id":               photo.ID,
+				"photo_url":        photo.PhotoURL,
+				"caption":          photo.Caption,
+				"uploaded_by":      utils.SerializeNI(photo.UploadedBy),
+				"uploaded_by_name": utils.SerializeNS(photo.UploadedByName),
+				"uploaded_at":      serializeTime(photo.UploadedAt),
+			})
+		}
+		serialized["photos"] = photos
+	}
+	return serialized
+}
+
+// serializeDate formats a nullable date as YYYY-MM-DD, or nil.
+func serializeDate(date sql.NullTime) any {
+	if !date.Valid {
+		return nil
+	}
+	return date.Time.Format(time.DateOnly)
+}
+
+// serializeTime returns a nullable timestamp, or nil.
+func serializeTime(timestamp sql.NullTime) any {
+	if !timestamp.Valid {
+		return nil
+	}
+	return timestamp.Time
+}
+
+// ticketIDParam parses the :ticket-id route parameter, aborting the request when it is invalid.
+func ticketIDParam(context *gin.Context) (int64, bool) {
+	ticketID, err := strconv.ParseInt(context.Param("ticket-id"), 10, 64)
+	if err != nil || ticketID <= 0 {
+		apperr.Abort(context, apperr.Validation("invalid_ticket_id", "invalid ticket_id format"))
+		return 0, false
+	}
+	return ticketID, true
+}
@@ -0,0 +1,279 @@
+// == Handles all database operations related to repair tickets ==
+package repair
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+)
+
+// Ticket status values. A ticket is open while "In Repair".
+const (
+	StatusInRepair     = "In Repair"
+	StatusRepaired     = "Repaired"
+	StatusBeyondRepair = "Beyond Repair"
+)
+
+// Ticket is the repair of a broken asset, along with the names needed to display it.
+// Tickets are opened by the database when an opname session with broken assets is verified.
+type Ticket struct {
+	ID                 int64
+	AssetTag           string
+	SerialNumber       string
+	BrandName          string
+	ProductName        string
+	ProductVariety     string
+	SiteID             int64 // The site the asset is on
+	SiteName           string
+	AssetStatus        string
+	SessionID          sql.NullInt64 // The opname session the asset was found broken in
+	Status             string
+	Problem            string
+	PreviousStatus     string // The asset status restored once repaired, "Deployed" or "In Inventory"
+	Vendor             string
+	CostEstimate       sql.NullInt64 // In rupiah
+	RepairCost         sql.NullInt64 // In rupiah, set on resolution
+	SentDate           sql.NullTime
+	ExpectedReturnDate sql.NullTime
+	OpenedAt           sql.NullTime
+	UpdatedBy          sql.NullInt64
+	UpdatedAt          sql.NullTime
+	ResolvedBy         sql.NullInt64
+	ResolvedByName     sql.NullString
+	ResolvedAt         sql.NullTime
+	ResolutionNotes    string
+	Photos             []*Photo // Only loaded for a single ticket
+}
+
+// Photo is a photo attached to a repair ticket.
+type Photo struct {
+	ID             int64
+	PhotoURL       string
+	Caption        string
+	UploadedBy     sql.NullInt64 // NULL for the condition photo taken during opname
+	UploadedByName sql.NullString
+	UploadedAt     sql.NullTime
+}
+
+// UpdateRequest is the body of PUT /api/repair/:ticket-id. Dates are YYYY-MM-DD; a missing field clears it.
+type UpdateRequest struct {
+	Vendor             string  `json:"vendor"`
+	CostEstimate       *int64  `json:"cost_estimate"`
+	SentDate           *string `json:"sent_date"`
+	ExpectedReturnDate *string `json:"expected_return_date"`
+}
+
+// PhotoRequest is the body of POST /api/repair/:ticket-id/photos. PhotoURL comes from POST /api/upload/photo.
+type PhotoRequest struct {
+	PhotoURL string `json:"photo_url" binding:"required"`
+	Caption  string `json:"caption"`
+}
+
+// ResolveRequest is the body of PUT /api/repair/:ticket-id/resolve. Status is "Repaired" or "Beyond Repair".
+type ResolveRequest struct {
+	Status     string `json:"status" binding:"required"`
+	RepairCost *int64 `json:"repair_cost"`
+	Notes      string `json:"notes"`
+}
+
+// Filter narrows down GET /api/repair. Every field is optional.
+type Filter struct {
+	AssetTag  *string `json:"asset_tag" form:"asset_tag"`
+	SessionID *int    `json:"session_id" form:"session_id"`
+	SiteID    *int    `json:"site_id" form:"site_id"`
+	Status    *string `json:"status" form:"status"`
+	Limit     *int    `json:"limit" form:"limit"`
+	PageNum   *int    `json:"page_num" form:"page_num"`
+}
+
+type Repository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewRepository creates a new repair ticket repository.
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetTickets retrieves the tickets matching the filter, open ones first, along with the total number of matches.
+func (repo *Repository) GetTickets(ctx context.Context, filter Filter) ([]*Ticket, int64, error) {
+	return repo.queryTickets(ctx, nil, filter)
+}
+
+// GetTicketByID retrieves a ticket without its photos, or nil if it does not exist.
+func (repo *Repository) GetTicketByID(ctx context.Context, ticketID int64) (*Ticket, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	tickets, _, err := repo.queryTickets(ctx, &ticketID, Filter{})
+	if err != nil {
+		return nil, err
+	}
+	if len(tickets) == 0 {
+		logger.Debug("no repair ticket found", "ticket_id", ticketID)
+		return nil, nil
+	}
+	return tickets[0], nil
+}
+
+// queryTickets runs get_repair_tickets, for a single ticket when ticketID is set.
+func (repo *Repository) queryTickets(ctx context.Context, ticketID *int64, filter Filter) ([]*Ticket, int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_repair_tickets($1, $2, $3, $4, $5, $6, $7)`
+
+	rows, err := repo.db.QueryContext(ctx, query, ticketID, filter.AssetTag, filter.SessionID, filter.SiteID, filter.Status, filter.Limit, filter.PageNum)
+	if err != nil {
+		logger.Error("failed to query repair tickets", "error", err)
+		return nil, 0, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	tickets := make([]*Ticket, 0)
+	var totalCount int64
+	for rows.Next() {
+		var ticket Ticket
+		if err := rows.Scan(
+			&ticket.ID,
+			&ticket.AssetTag,
+			&ticket.SerialNumber,
+			&ticket.BrandName,
+			&ticket.ProductName,
+			&ticket.ProductVariety,
+			&ticket.SiteID,
+			&ticket.SiteName,
+			&ticket.AssetStatus,
+			&ticket.SessionID,
+			&ticket.Status,
+			&ticket.Problem,
+			&ticket.PreviousStatus,
+			&ticket.Vendor,
+			&ticket.CostEstimate,
+			&ticket.RepairCost,
+			&ticket.SentDate,
+			&ticket.ExpectedReturnDate,
+			&ticket.OpenedAt,
+			&ticket.UpdatedBy,
+			&ticket.UpdatedAt,
+			&ticket.ResolvedBy,
+			&ticket.ResolvedByName,
+			&ticket.ResolvedAt,
+			&ticket.ResolutionNotes,
+			&totalCount,
+		); err != nil {
+			logger.Error("failed to scan repair ticket row", "error", err)
+			return nil, 0, apperr.FromPostgres(err)
+		}
+		tickets = append(tickets, &ticket)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating repair ticket rows", "error", err)
+		return nil, 0, apperr.FromPostgres(err)
+	}
+
+	return tickets, totalCount, nil
+}
+
+// GetTicketPhotos retrieves the photos of a ticket, oldest first.
+func (repo *Repository) GetTicketPhotos(ctx context.Context, ticketID int64) ([]*Photo, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_repair_ticket_photos($1)`
+
+	rows, err := repo.db.QueryContext(ctx, query, ticketID)
+	if err != nil {
+		logger.Error("failed to query repair ticket photos", "ticket_id", ticketID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	photos := make([]*Photo, 0)
+	for rows.Next() {
+		var photo Photo
+		if err := rows.Scan(
+			&photo.ID,
+			&photo.PhotoURL,
+			&photo.Caption,
+			&photo.UploadedBy,
+			&photo.UploadedByName,
+			&photo.UploadedAt,
+		); err != nil {
+			logger.Error("failed to scan repair ticket photo row", "ticket_id", ticketID, "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		photos = append(photos, &photo)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating repair ticket photo rows", "ticket_id", ticketID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	return photos, nil
+}
+
+// UpdateTicket sets the repair details of an open ticket. The request's dates must already be validated.
+func (repo *Repository) UpdateTicket(ctx context.Context, ticketID int64, updatedBy int64, request UpdateRequest) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL update_repair_ticket($1, $2, $3, $4, $5, $6)`
+
+	if _, err := repo.db.ExecContext(ctx, query, ticketID, request.Vendor, request.CostEstimate, request.SentDate, request.ExpectedReturnDate, updatedBy); err != nil {
+		logger.Warn("failed to update repair ticket", "ticket_id", ticketID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("updated repair ticket", "ticket_id", ticketID)
+	return nil
+}
+
+// AddPhoto attaches a photo to a ticket and returns its ID.
+func (repo *Repository) AddPhoto(ctx context.Context, ticketID int64, uploadedBy int64, request PhotoRequest) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT add_repair_ticket_photo($1, $2, $3, $4)`
+
+	var photoID int64
+	err := repo.db.QueryRowContext(ctx, query, ticketID, request.PhotoURL, request.Caption, uploadedBy).Scan(&photoID)
+	if err != nil {
+		logger.Warn("failed to add repair ticket photo", "ticket_id", ticketID, "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+
+	logger.Info("added repair ticket photo", "ticket_id", ticketID, "photo_id", photoID)
+	return photoID, nil
+}
+
+// ResolveTicket closes an open ticket as repaired or beyond repair, updating the asset accordingly.
+func (repo *Repository) ResolveTicket(ctx context.Context, ticketID int64, resolvedBy int64, request ResolveRequest) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL resolve_repair_ticket($1, $2, $3, $4, $5)`
+
+	if _, err := repo.db.ExecContext(ctx, query, ticketID, request.Status, request.RepairCost, request.Notes, resolvedBy); err != nil {
+		logger.Warn("failed to resolve repair ticket", "ticket_id", ticketID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("resolved repair ticket", "ticket_id", ticketID, "status", request.Status)
+	return nil
+}
+
+// IsTicketManager checks whether a user is the GA staff of the site the ticket's asset is on.
+func (repo *Repository) IsTicketManager(ctx context.Context, ticketID int64, userID int64) (bool, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT is_repair_ticket_manager($1, $2)`
+
+	var manager bool
+	if err := repo.db.QueryRowContext(ctx, query, ticketID, userID).Scan(&manager); err != nil {
+		logger.Error("failed to check repair ticket manager", "ticket_id", ticketID, "user_id", userID, "error", err)
+		return false, apperr.FromPostgres(err)
+	}
+	return manager, nil
+}
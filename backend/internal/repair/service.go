@@ -0,0 +1,255 @@
+// == Handles all logical operations related to repair tickets ==
+// == Broken assets of a verified opname session get a ticket; the GA staff of their site follow the repair until it is resolved ==
+package repair
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/upload"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+)
+
+// Errors returned by the repair workflow, in addition to the ones translated from the database.
+var (
+	ErrTicketNotFound   = apperr.NotFound("repair_ticket_not_found", "repair ticket not found")
+	ErrTicketResolved   = apperr.Conflict("repair_ticket_resolved", "the repair ticket has already been resolved")
+	ErrNotRepairManager = apperr.Forbidden("repair_forbidden", "only the GA staff of the asset's site or an admin can update its repair ticket")
+	ErrInvalidVendor    = apperr.Validation("invalid_vendor", "vendor must be at most 100 characters")
+	ErrInvalidCost      = apperr.Validation("invalid_cost", "cost_estimate and repair_cost must not be negative")
+	ErrInvalidDates     = apperr.Validation("invalid_repair_dates", "sent_date and expected_return_date must be dates as YYYY-MM-DD, the expected return not before the sending")
+	ErrInvalidOutcome   = apperr.Validation("invalid_repair_outcome", "status must be Repaired or Beyond Repair")
+	ErrInvalidPhoto     = apperr.Validation("invalid_photo_url", "photo_url must be a photo uploaded through /api/upload/photo")
+)
+
+// maxVendorLength is the size of "RepairTicket".vendor.
+const maxVendorLength = 100
+
+type Service struct {
+	repo          *Repository
+	uploadService *upload.Service
+	logger        *slog.Logger
+}
+
+// NewService creates a new repair ticket service.
+func NewService(repo *Repository, uploadService *upload.Service, logger *slog.Logger) *Service {
+	return &Service{
+		repo:          repo,
+		uploadService: uploadService,
+		logger:        logger,
+	}
+}
+
+// GetTickets retrieves the tickets matching the filter, open ones first, along with the total number of matches.
+func (service *Service) GetTickets(ctx context.Context, filter Filter) ([]*Ticket, int64, error) {
+	return service.repo.GetTickets(ctx, filter)
+}
+
+// GetTicketByID retrieves a ticket with its photos.
+func (service *Service) GetTicketByID(ctx context.Context, ticketID int64) (*Ticket, error) {
+	ticket, err := service.repo.GetTicketByID(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket == nil {
+		return nil, ErrTicketNotFound
+	}
+
+	ticket.Photos, err = service.repo.GetTicketPhotos(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	return ticket, nil
+}
+
+// UpdateTicket sets the vendor, cost estimate and dates of an open ticket on behalf of the GA staff of the asset's site or an admin.
+func (service *Service) UpdateTicket(ctx context.Context, actor roles.Actor, ticketID int64, request UpdateRequest) (*Ticket, error) {
+	request.Vendor = strings.TrimSpace(request.Vendor)
+	if len(request.Vendor) > maxVendorLength {
+		return nil, ErrInvalidVendor
+	}
+	if request.CostEstimate != nil && *request.CostEstimate < 0 {
+		return nil, ErrInvalidCost
+	}
+	sentDate, err := parseDate(request.SentDate)
+	if err != nil {
+		return nil, ErrInvalidDates
+	}
+	expectedReturnDate, err := parseDate(request.ExpectedReturnDate)
+	if err != nil || (sentDate != nil && expectedReturnDate != nil && expectedReturnDate.Before(*sentDate)) {
+		return nil, ErrInvalidDates
+	}
+	request.SentDate = formatDate(sentDate)
+	request.ExpectedReturnDate = formatDate(expectedReturnDate)
+
+	before, err := service.openTicket(ctx, actor, "update", ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.repo.UpdateTicket(ctx, ticketID, actor.UserID, request); err != nil {
+		return nil, err
+	}
+	after, err := service.GetTicketByID(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "repair_ticket.update",
+		EntityType: "repair_ticket",
+		EntityID:   strconv.FormatInt(ticketID, 10),
+		Before:     ticketSnapshot(before),
+		After:      ticketSnapshot(after),
+	})
+	return after, nil
+}
+
+// AddPhoto attaches a photo uploaded through /api/upload/photo to a ticket on behalf of the GA staff of the asset's site or an admin.
+// Photos can still be added once the ticket is resolved.
+func (service *Service) AddPhoto(ctx context.Context, actor roles.Actor, ticketID int64, request PhotoRequest) (*Ticket, error) {
+	request.PhotoURL = strings.TrimSpace(request.PhotoURL)
+	request.Caption = strings.TrimSpace(request.Caption)
+	if !service.uploadService.IsConditionPhoto(request.PhotoURL) {
+		return nil, ErrInvalidPhoto
+	}
+
+	if _, err := service.GetTicketByID(ctx, ticketID); err != nil {
+		return nil, err
+	}
+	if err := service.authorize(ctx, actor, "add_photo", ticketID); err != nil {
+		return nil, err
+	}
+
+	photoID, err := service.repo.AddPhoto(ctx, ticketID, actor.UserID, request)
+	if err != nil {
+		return nil, err
+	}
+	after, err := service.GetTicketByID(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "repair_ticket.add_photo",
+		EntityType: "repair_ticket",
+		EntityID:   strconv.FormatInt(ticketID, 10),
+		After:      map[string]any{"photo_id": photoID, "photo_url": request.PhotoURL, "caption": request.Caption},
+	})
+	return after, nil
+}
+
+// ResolveTicket closes an open ticket on behalf of the GA staff of the asset's site or an admin. A repaired asset gets its
+// status back in good condition; an asset beyond repair goes "Down" in bad condition and is left for disposal.
+func (service *Service) ResolveTicket(ctx context.Context, actor roles.Actor, ticketID int64, request ResolveRequest) (*Ticket, error) {
+	request.Notes = strings.TrimSpace(request.Notes)
+	switch status := strings.TrimSpace(request.Status); {
+	case strings.EqualFold(status, StatusRepaired):
+		request.Status = StatusRepaired
+	case strings.EqualFold(status, StatusBeyondRepair):
+		request.Status = StatusBeyondRepair
+	default:
+		return nil, ErrInvalidOutcome
+	}
+	if request.RepairCost != nil && *request.RepairCost < 0 {
+		return nil, ErrInvalidCost
+	}
+
+	before, err := service.openTicket(ctx, actor, "resolve", ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.repo.ResolveTicket(ctx, ticketID, actor.UserID, request); err != nil {
+		return nil, err
+	}
+	after, err := service.GetTicketByID(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "repair_ticket.resolve",
+		EntityType: "repair_ticket",
+		EntityID:   strconv.FormatInt(ticketID, 10),
+		Before:     ticketSnapshot(before),
+		After:      ticketSnapshot(after),
+	})
+	return after, nil
+}
+
+// openTicket retrieves a ticket that is still open and that the actor may act on.
+func (service *Service) openTicket(ctx context.Context, actor roles.Actor, action string, ticketID int64) (*Ticket, error) {
+	ticket, err := service.GetTicketByID(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Status != StatusInRepair {
+		return nil, ErrTicketResolved
+	}
+	if err := service.authorize(ctx, actor, action, ticketID); err != nil {
+		return nil, err
+	}
+	return ticket, nil
+}
+
+// authorize checks that the actor is the GA staff of the site the ticket's asset is on, or an admin.
+func (service *Service) authorize(ctx context.Context, actor roles.Actor, action string, ticketID int64) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if actor.IsAdmin() {
+		return nil
+	}
+	manager, err := service.repo.IsTicketManager(ctx, ticketID, actor.UserID)
+	if err != nil {
+		return err
+	}
+	if !manager {
+		logger.Warn("repair ticket change denied", "action", action, "user_id", actor.UserID, "position", actor.Position, "ticket_id", ticketID)
+		return ErrNotRepairManager
+	}
+	return nil
+}
+
+// parseDate parses an optional YYYY-MM-DD date, treating an empty one as missing.
+func parseDate(value *string) (*time.Time, error) {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil, nil
+	}
+	date, err := time.Parse(time.DateOnly, strings.TrimSpace(*value))
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+// formatDate formats an optional date as YYYY-MM-DD.
+func formatDate(date *time.Time) *string {
+	if date == nil {
+		return nil
+	}
+	formatted := date.Format(time.DateOnly)
+	return &formatted
+}
+
+// ticketSnapshot is the audited state of a ticket.
+func ticketSnapshot(ticket *Ticket) map[string]any {
+	return map[string]any{
+		"asset_tag":            ticket.AssetTag,
+		"asset_status":         ticket.AssetStatus,
+		"status":               ticket.Status,
+		"vendor":               ticket.Vendor,
+		"cost_estimate":        utils.SerializeNI(ticket.CostEstimate),
+		"repair_cost":          utils.SerializeNI(ticket.RepairCost),
+		"sent_date":            serializeDate(ticket.SentDate),
+		"expected_return_date": serializeDate(ticket.ExpectedReturnDate),
+		"resolution_notes":     ticket.ResolutionNotes,
+	}
+}
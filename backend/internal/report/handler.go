@@ -2,12 +2,14 @@
 package report
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -16,76 +18,149 @@ import (
 // Handler holds the report service.
 type Handler struct {
 	service *Service
+	logger  *slog.Logger
 }
 
 // NewHandler creates a new report handler with the provided report service.
-func NewHandler(service *Service) *Handler {
-	return &Handler{service: service}
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
+	return &Handler{service: service, logger: logger}
 }
 
 // GetOpnameStatsHandler retrieves the opname statistics for a given opname session ID.
 func (handler *Handler) GetOpnameStatsHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	sessionIDStr := context.Param("session-id")
 	if sessionIDStr == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "session-id is required"})
-		log.Printf("⚠ session_id is required but not provided")
+		apperr.Abort(context, apperr.Validation("invalid_session_id", "session-id is required"))
+		logger.Warn("missing session id in request")
 		return
 	}
 
 	sessionID, err := strconv.ParseInt(sessionIDStr, 10, 64)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid session-id format"})
-		log.Printf("⚠ Invalid session-id format: %s", sessionIDStr)
+		apperr.Abort(context, apperr.Validation("invalid_session_id", "invalid session-id format"))
+		logger.Warn("invalid session id", "session_id", sessionIDStr)
 		return
 	}
 
 	// Call the service to get opname stats
-	stats, err := handler.service.GetOpnameStats(sessionID)
+	stats, err := handler.service.GetOpnameStats(context.Request.Context(), sessionID)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch opname stats: " + err.Error()})
-		log.Printf("❌ Error fetching opname stats for session ID %d: %v", sessionID, err)
+		apperr.Abort(context, err)
+		logger.Error("failed to fetch opname stats", "session_id", sessionID, "error", err)
 		return
 	}
 	if stats == nil {
 		context.JSON(http.StatusNotFound, gin.H{"message": "no opname stats found for session ID: " + strconv.FormatInt(sessionID, 10)})
-		log.Printf("⚠ No opname stats found for session ID: %d", sessionID)
+		logger.Debug("no opname stats found", "session_id", sessionID)
 		return
 	}
 
 	// Return the opname stats
 	context.JSON(http.StatusOK, gin.H{
-		"working_assets":   stats.WorkingAssets,
-		"broken_assets":    stats.BrokenAssets,
-		"misplaced_assets": stats.MisplacedAssets,
-		"missing_assets":   stats.MissingAssets,
+		"working_assets":           stats.WorkingAssets,
+		"broken_assets":            stats.BrokenAssets,
+		"misplaced_assets":         stats.MisplacedAssets,
+		"missing_assets":           stats.MissingAssets,
+		"expected_off_site_assets": stats.ExpectedOffSite,
+		"broken_assets_value":      stats.BrokenValue,
+		"missing_assets_value":     stats.MissingValue,
+		"values":                   serializeValues(stats.Values),
+		"loss_value":               stats.LossValue(),
+		"loss_value_formatted":     utils.FormatRupiah(stats.LossValue()),
+		"loss_threshold":           stats.LossThreshold,
+		"loss_threshold_formatted": utils.FormatRupiah(stats.LossThreshold),
+		"loss_approval_required":   stats.LossApprovalRequired,
+		"loss_approval":            serializeLossApproval(stats.LossApproval),
 	})
 }
 
+// serializeValues converts the values of a session to one entry per category, in the BAP order, listing its product varieties.
+// Amounts are in rupiah, each with its IDR formatted form.
+func serializeValues(varietyValues []VarietyValue) []gin.H {
+	varieties := make(map[string][]gin.H)
+	for _, varietyValue := range varietyValues {
+		entry := serializeValueTotal(varietyValue.ValueTotal)
+		entry["product_variety"] = varietyValue.ProductVariety
+		varieties[varietyValue.Category] = append(varieties[varietyValue.Category], entry)
+	}
+
+	serialized := make([]gin.H, 0, len(categoryOrder))
+	for _, categoryValue := range categoryValues(varietyValues) {
+		entry := serializeValueTotal(categoryValue.ValueTotal)
+		entry["category"] = categoryValue.Category
+		entry["product_varieties"] = append(make([]gin.H, 0), varieties[categoryValue.Category]...)
+		serialized = append(serialized, entry)
+	}
+	return serialized
+}
+
+// serializeValueTotal converts the count and values of a group of assets to their JSON form.
+func serializeValueTotal(total ValueTotal) gin.H {
+	return gin.H{
+		"asset_count":           total.AssetCount,
+		"total_value":           total.TotalValue,
+		"total_value_formatted": utils.FormatRupiah(total.TotalValue),
+		"net_value":             total.NetValue,
+		"net_value_formatted":   utils.FormatRupiah(total.NetValue),
+	}
+}
+
+// serializeLossApproval converts the loss approval of a session to its JSON form, nil when none was requested.
+func serializeLossApproval(approval *LossApproval) gin.H {
+	if approval == nil {
+		return nil
+	}
+	var reviewedAt any
+	if approval.ReviewedAt.Valid {
+		reviewedAt = approval.ReviewedAt.Time
+	}
+	status := "Pending"
+	if approval.Decision.Valid {
+		status = approval.Decision.String
+	}
+	return gin.H{
+		"status":                   status,
+		"loss_value":               approval.LossValue,
+		"loss_value_formatted":     utils.FormatRupiah(approval.LossValue),
+		"loss_threshold":           approval.LossThreshold,
+		"loss_threshold_formatted": utils.FormatRupiah(approval.LossThreshold),
+		"requested_by":             utils.SerializeNI(approval.RequestedBy),
+		"requested_at":             approval.RequestedAt,
+		"reviewer_id":              utils.SerializeNI(approval.ReviewerID),
+		"reviewer_name":            utils.SerializeNS(approval.ReviewerName),
+		"reviewed_at":              reviewedAt,
+	}
+}
+
 // GenerateBAPHandler streams the BAP PDF for a session.
 func (handler *Handler) GenerateBAPHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	sessionIDStr := context.Param("session-id")
 	if sessionIDStr == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "session-id is required"})
+		apperr.Abort(context, apperr.Validation("invalid_session_id", "session-id is required"))
 		return
 	}
 
 	sessionID, err := strconv.ParseInt(sessionIDStr, 10, 64)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid session-id"})
+		apperr.Abort(context, apperr.Validation("invalid_session_id", "invalid session-id"))
 		return
 	}
 
 	start := time.Now()
-	log.Printf("[REPORT] START GenerateBAP session=%d", sessionID)
+	logger.Info("generating BAP", "session_id", sessionID)
 
-	pdfBytes, filename, err := handler.service.GenerateAndAssembleBAP(sessionID)
+	pdfBytes, filename, err := handler.service.GenerateAndAssembleBAP(context.Request.Context(), sessionID)
 	if err != nil {
-		log.Printf("[REPORT] ERROR GenerateBAP session=%d err=%v", sessionID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate BAP PDF", "detail": err.Error()})
+		logger.Error("failed to generate BAP", "session_id", sessionID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
-	log.Printf("[REPORT] DONE GenerateBAP session=%d bytes=%d elapsed=%s", sessionID, len(pdfBytes), time.Since(start))
+	logger.Info("generated BAP", "session_id", sessionID, "size_bytes", len(pdfBytes), "elapsed_ms", time.Since(start).Milliseconds())
 
 	context.Header("Content-Type", "application/pdf")
 	context.Header("Content-Disposition", "attachment; filename="+filename)
@@ -96,19 +171,19 @@ func (handler *Handler) GenerateBAPHandler(context *gin.Context) {
 func (handler *Handler) GetBAPRecapHandler(context *gin.Context) {
 	sessionIDStr := context.Param("session-id")
 	if sessionIDStr == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "session-id is required"})
+		apperr.Abort(context, apperr.Validation("invalid_session_id", "session-id is required"))
 		return
 	}
 
 	sessionID, err := strconv.ParseInt(sessionIDStr, 10, 64)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid session-id"})
+		apperr.Abort(context, apperr.Validation("invalid_session_id", "invalid session-id"))
 		return
 	}
 
-	recap, err := handler.service.repo.GetBAPRecap(sessionID)
+	recap, err := handler.service.GetBAPRecap(context.Request.Context(), sessionID)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch recap", "detail": err.Error()})
+		apperr.Abort(context, err)
 		return
 	}
 
@@ -119,19 +194,19 @@ func (handler *Handler) GetBAPRecapHandler(context *gin.Context) {
 func (handler *Handler) GetBAPDetailsHandler(context *gin.Context) {
 	sessionIDStr := context.Param("session-id")
 	if sessionIDStr == "" {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "session-id is required"})
+		apperr.Abort(context, apperr.Validation("invalid_session_id", "session-id is required"))
 		return
 	}
 
 	sessionID, err := strconv.ParseInt(sessionIDStr, 10, 64)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid session-id"})
+		apperr.Abort(context, apperr.Validation("invalid_session_id", "invalid session-id"))
 		return
 	}
 
-	details, err := handler.service.repo.GetBAPDetails(sessionID)
+	details, err := handler.service.repo.GetBAPDetails(context.Request.Context(), sessionID)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch details", "detail": err.Error()})
+		apperr.Abort(context, err)
 		return
 	}
 
@@ -166,18 +241,18 @@ func (handler *Handler) SetActionNotesHandler(context *gin.Context) {
 	// Security check: ensure user is authorized
 	position, ok := context.Get("position")
 	if !ok {
-		context.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		apperr.Abort(context, apperr.Unauthorized("unauthorized", "unauthorized"))
 		return
 	}
-	if strings.ToLower(position.(string)) != "l1 support" {
-		context.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	if !roles.IsAdmin(position.(string)) {
+		apperr.Abort(context, apperr.Forbidden("forbidden", "forbidden"))
 		return
 	}
 
 	// Get user id from claims
 	userID, ok := context.Get("user_id")
 	if !ok {
-		context.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		apperr.Abort(context, apperr.Unauthorized("unauthorized", "unauthorized"))
 		return
 	}
 
@@ -187,12 +262,12 @@ func (handler *Handler) SetActionNotesHandler(context *gin.Context) {
 		ActionNotes string `json:"action_notes"`
 	}
 	if err := context.ShouldBindJSON(&requestBody); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body"))
 		return
 	}
 
-	if err := handler.service.SetActionNotes(requestBody.AssetTag, requestBody.SessionID, userID.(int64), requestBody.ActionNotes); err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set action notes", "detail": err.Error()})
+	if err := handler.service.SetActionNotes(context.Request.Context(), requestBody.AssetTag, requestBody.SessionID, userID.(int64), requestBody.ActionNotes); err != nil {
+		apperr.Abort(context, err)
 		return
 	}
 
@@ -204,18 +279,18 @@ func (handler *Handler) DeleteActionNotesHandler(context *gin.Context) {
 	// Security check: ensure user is authorized
 	position, ok := context.Get("position")
 	if !ok {
-		context.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		apperr.Abort(context, apperr.Unauthorized("unauthorized", "unauthorized"))
 		return
 	}
-	if strings.ToLower(position.(string)) != "l1 support" {
-		context.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	if !roles.IsAdmin(position.(string)) {
+		apperr.Abort(context, apperr.Forbidden("forbidden", "forbidden"))
 		return
 	}
 
 	// Get user id from claims
 	userID, ok := context.Get("user_id")
 	if !ok {
-		context.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		apperr.Abort(context, apperr.Unauthorized("unauthorized", "unauthorized"))
 		return
 	}
 
@@ -225,12 +300,12 @@ func (handler *Handler) DeleteActionNotesHandler(context *gin.Context) {
 	}
 
 	if err := context.ShouldBindJSON(&requestBody); err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body"))
 		return
 	}
 
-	if err := handler.service.DeleteActionNotes(requestBody.AssetTag, requestBody.SessionID, userID.(int64)); err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete action notes", "detail": err.Error()})
+	if err := handler.service.DeleteActionNotes(context.Request.Context(), requestBody.AssetTag, requestBody.SessionID, userID.(int64)); err != nil {
+		apperr.Abort(context, err)
 		return
 	}
 
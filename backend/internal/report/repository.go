@@ -2,18 +2,26 @@
 package report
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/depreciation"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
 )
 
 type Repository struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *slog.Logger
 }
 
 // NewRepository creates a new report repository with the provided database connection.
-func NewRepository(db *sql.DB) *Repository {
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
 	return &Repository{
-		db: db,
+		db:     db,
+		logger: logger,
 	}
 }
 
@@ -22,6 +30,62 @@ type OpnameStats struct {
 	BrokenAssets    int64
 	MisplacedAssets int64
 	MissingAssets   int64
+	ExpectedOffSite int64 // Assets on loan, not expected to be found at the location
+	BrokenValue     int64 // Net book value of the broken assets, in rupiah
+	MissingValue    int64 // Net book value of the missing assets, in rupiah
+
+	Values []VarietyValue // Acquisition cost and net book value per category and product variety
+
+	// LossThreshold is opname.loss_threshold, 0 when losses need no approval. LossApprovalRequired reports whether the loss
+	// exceeds it, and LossApproval is the approval of the loss, nil until L1 support verified the session.
+	LossThreshold        int64
+	LossApprovalRequired bool
+	LossApproval         *LossApproval
+}
+
+// LossValue returns the net book value of the broken and missing assets, the loss of the session.
+func (stats *OpnameStats) LossValue() int64 {
+	return stats.BrokenValue + stats.MissingValue
+}
+
+// ValueTotal is the number, acquisition cost and net book value in rupiah of a group of assets.
+type ValueTotal struct {
+	AssetCount int64
+	TotalValue int64 // Acquisition cost
+	NetValue   int64 // Net book value
+}
+
+// VarietyValue totals the assets of a product variety within a category of an opname session.
+type VarietyValue struct {
+	Category       string
+	ProductVariety string
+	ValueTotal
+}
+
+// CategoryValue totals the assets of a category of an opname session.
+type CategoryValue struct {
+	Category string
+	ValueTotal
+}
+
+// LossApproval is the approval of the loss of an opname session whose loss exceeded opname.loss_threshold, see internal/opname.
+type LossApproval struct {
+	LossValue     int64
+	LossThreshold int64
+	RequestedBy   sql.NullInt64
+	RequestedAt   time.Time
+	ReviewerID    sql.NullInt64
+	ReviewerName  sql.NullString
+	ReviewedAt    sql.NullTime
+	Decision      sql.NullString // Approved or Rejected, null while pending
+}
+
+// AssetValue is a categorized asset of an opname session with what its net book value is computed from.
+type AssetValue struct {
+	Category       string
+	AssetTag       string
+	ProductVariety string
+	depreciation.Valuation
 }
 
 // TODO: !!!
@@ -33,6 +97,8 @@ type BAPRecapRow struct {
 	Category       string
 	ProductVariety string
 	AssetCount     int64
+	TotalValue     int64 // Acquisition cost in rupiah
+	NetValue       int64 // Net book value in rupiah
 }
 
 // BAPDetailRow represents a single asset detail row in the lampiran table.
@@ -46,6 +112,7 @@ type BAPDetailRow struct {
 	AssetStatus         string
 	ActionNotes         sql.NullString
 	CostCenterID        sql.NullInt64
+	BookValue           sql.NullInt64 // Net book value in rupiah as of the BAP date
 }
 
 // SessionMeta holds minimal session metadata needed for BAP generation (avoid importing opname pkg to prevent cycles).
@@ -64,12 +131,14 @@ type SessionMeta struct {
 }
 
 // GetBAPRecap retrieves recap rows (grouped by category & product variety) for a session.
-func (repo *Repository) GetBAPRecap(sessionID int64) ([]BAPRecapRow, error) {
+func (repo *Repository) GetBAPRecap(ctx context.Context, sessionID int64) ([]BAPRecapRow, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	query := `SELECT category, product_variety, asset_count FROM get_opname_bap_recap($1)`
-	rows, err := repo.db.Query(query, sessionID)
+	rows, err := repo.db.QueryContext(ctx, query, sessionID)
 	if err != nil {
-		log.Printf("❌ Error querying BAP recap for session %d: %v", sessionID, err)
-		return nil, err
+		logger.Error("failed to query BAP recap", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 	defer rows.Close()
 
@@ -77,24 +146,26 @@ func (repo *Repository) GetBAPRecap(sessionID int64) ([]BAPRecapRow, error) {
 	for rows.Next() {
 		var recapRow BAPRecapRow
 		if err := rows.Scan(&recapRow.Category, &recapRow.ProductVariety, &recapRow.AssetCount); err != nil {
-			log.Printf("❌ Error scanning BAP recap row: %v", err)
-			return nil, err
+			logger.Error("failed to scan BAP recap row", "session_id", sessionID, "error", err)
+			return nil, apperr.FromPostgres(err)
 		}
 		recap = append(recap, recapRow)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, apperr.FromPostgres(err)
 	}
 	return recap, nil
 }
 
 // GetBAPDetails retrieves detailed lampiran rows for a session.
-func (repo *Repository) GetBAPDetails(sessionID int64) ([]BAPDetailRow, error) {
+func (repo *Repository) GetBAPDetails(ctx context.Context, sessionID int64) ([]BAPDetailRow, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	query := `SELECT category, company, asset_tag, asset_name, equipments, user_name_and_position, asset_status, action_notes, cost_center_id FROM get_opname_bap_details($1)`
-	rows, err := repo.db.Query(query, sessionID)
+	rows, err := repo.db.QueryContext(ctx, query, sessionID)
 	if err != nil {
-		log.Printf("❌ Error querying BAP details for session %d: %v", sessionID, err)
-		return nil, err
+		logger.Error("failed to query BAP details", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 	defer rows.Close()
 
@@ -102,21 +173,77 @@ func (repo *Repository) GetBAPDetails(sessionID int64) ([]BAPDetailRow, error) {
 	for rows.Next() {
 		var detailRow BAPDetailRow
 		if err := rows.Scan(&detailRow.Category, &detailRow.Company, &detailRow.AssetTag, &detailRow.AssetName, &detailRow.Equipments, &detailRow.UserNameAndPosition, &detailRow.AssetStatus, &detailRow.ActionNotes, &detailRow.CostCenterID); err != nil {
-			log.Printf("❌ Error scanning BAP detail row: %v", err)
-			return nil, err
+			logger.Error("failed to scan BAP detail row", "session_id", sessionID, "error", err)
+			return nil, apperr.FromPostgres(err)
 		}
 		details = append(details, detailRow)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, apperr.FromPostgres(err)
 	}
 	return details, nil
 }
 
+// GetAssetValues retrieves the categorized assets of a session with what their net book value is computed from.
+func (repo *Repository) GetAssetValues(ctx context.Context, sessionID int64) ([]AssetValue, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT category, asset_tag, product_variety, total_cost, acquisition_date, depreciation_method, useful_life_months FROM get_opname_asset_values($1)`
+	rows, err := repo.db.QueryContext(ctx, query, sessionID)
+	if err != nil {
+		logger.Error("failed to query opname asset values", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	var values []AssetValue
+	for rows.Next() {
+		var value AssetValue
+		if err := rows.Scan(&value.Category, &value.AssetTag, &value.ProductVariety, &value.Cost, &value.AcquisitionDate, &value.Method, &value.UsefulLifeMonths); err != nil {
+			logger.Error("failed to scan opname asset value row", "session_id", sessionID, "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.FromPostgres(err)
+	}
+	return values, nil
+}
+
+// GetLossApproval retrieves the loss approval of a session, nil when none was requested.
+func (repo *Repository) GetLossApproval(ctx context.Context, sessionID int64) (*LossApproval, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT loss_value, loss_threshold, requested_by, requested_at, reviewer_id, reviewer_name, reviewed_at, decision FROM get_opname_loss_approval($1)`
+
+	var approval LossApproval
+	err := repo.db.QueryRowContext(ctx, query, sessionID).Scan(
+		&approval.LossValue,
+		&approval.LossThreshold,
+		&approval.RequestedBy,
+		&approval.RequestedAt,
+		&approval.ReviewerID,
+		&approval.ReviewerName,
+		&approval.ReviewedAt,
+		&approval.Decision,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		logger.Error("failed to query opname loss approval", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	return &approval, nil
+}
+
 // GetSessionMeta retrieves minimal opname session metadata (mirrors get_opname_session_by_id) without creating package cycles.
-func (repo *Repository) GetSessionMeta(sessionID int64) (*SessionMeta, error) {
+func (repo *Repository) GetSessionMeta(ctx context.Context, sessionID int64) (*SessionMeta, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var sessionMeta SessionMeta
-	err := repo.db.QueryRow(`SELECT * FROM get_opname_session_by_id($1)`, sessionID).Scan(
+	err := repo.db.QueryRowContext(ctx, `SELECT * FROM get_opname_session_by_id($1)`, sessionID).Scan(
 		&sessionMeta.ID,
 		&sessionMeta.StartDate,
 		&sessionMeta.EndDate,
@@ -131,51 +258,54 @@ func (repo *Repository) GetSessionMeta(sessionID int64) (*SessionMeta, error) {
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("⚠ No session meta found for ID %d", sessionID)
+			logger.Debug("no session meta found", "session_id", sessionID)
 			return nil, nil
 		}
-		log.Printf("❌ Error retrieving session meta %d: %v", sessionID, err)
-		return nil, err
+		logger.Error("failed to query session meta", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 	return &sessionMeta, nil
 }
 
 // GetOpnameStats retrieves the opname statistics for a given opname session ID.
-func (repo *Repository) GetOpnameStats(sessionID int64) (*OpnameStats, error) {
+func (repo *Repository) GetOpnameStats(ctx context.Context, sessionID int64) (*OpnameStats, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var stats OpnameStats
 
 	query := `SELECT * FROM get_opname_stats($1)`
 
-	err := repo.db.QueryRow(query, sessionID).Scan(
+	err := repo.db.QueryRowContext(ctx, query, sessionID).Scan(
 		&stats.WorkingAssets,
 		&stats.BrokenAssets,
 		&stats.MisplacedAssets,
 		&stats.MissingAssets,
+		&stats.ExpectedOffSite,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("⚠ No stats found for session ID: %d", sessionID)
+			logger.Debug("no opname stats found", "session_id", sessionID)
 			return nil, nil // No stats found for the given session ID
 		}
-		log.Printf("❌ Error retrieving opname stats for session ID %d: %v", sessionID, err)
-		return nil, err
+		logger.Error("failed to query opname stats", "session_id", sessionID, "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 
-	log.Printf("✅ Successfully retrieved opname stats for session ID %d", sessionID)
+	logger.Debug("retrieved opname stats", "session_id", sessionID)
 	return &stats, nil
 }
 
 // SetActionNotes updates the action note for a specific asset change record
-func (repo *Repository) SetActionNotes(assetTag string, sessionID int64, userID int64, actionNotes string) error {
+func (repo *Repository) SetActionNotes(ctx context.Context, assetTag string, sessionID int64, userID int64, actionNotes string) error {
 	query := `CALL set_action_notes($1, $2, $3, $4)`
-	_, err := repo.db.Exec(query, assetTag, sessionID, userID, actionNotes)
-	return err
+	_, err := repo.db.ExecContext(ctx, query, assetTag, sessionID, userID, actionNotes)
+	return apperr.FromPostgres(err)
 }
 
 // DeleteActionNotes removes the action note for a specific asset change record
-func (repo *Repository) DeleteActionNotes(assetTag string, sessionID int64, userID int64) error {
+func (repo *Repository) DeleteActionNotes(ctx context.Context, assetTag string, sessionID int64, userID int64) error {
 	query := `CALL delete_action_notes($1, $2, $3)`
-	_, err := repo.db.Exec(query, assetTag, sessionID, userID)
-	return err
+	_, err := repo.db.ExecContext(ctx, query, assetTag, sessionID, userID)
+	return apperr.FromPostgres(err)
 }
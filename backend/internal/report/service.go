@@ -3,107 +3,325 @@ package report
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"html/template"
-	"io"
-	"log"
-	"os"
+	"log/slog"
 	"os/exec"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/config"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/metrics"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/templates"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
 
 	"github.com/SebastiaanKlippert/go-wkhtmltopdf"
 )
 
 type Service struct {
-	repo *Repository
+	repo      *Repository
+	templates *templates.Set
+	opname    config.OpnameConfig // Loss threshold shown in the stats and on the BAP
+	location  *time.Location      // Timezone used for the signature timestamps on the BAP
+	logger    *slog.Logger
 }
 
-func NewService(repo *Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo *Repository, templateSet *templates.Set, opnameConfig config.OpnameConfig, location *time.Location, logger *slog.Logger) *Service {
+	return &Service{repo: repo, templates: templateSet, opname: opnameConfig, location: location, logger: logger}
 }
 
 // Category order and Indonesian labels.
-var categoryOrder = []string{"working_assets", "broken_assets", "misplaced_assets", "missing_assets"}
+var categoryOrder = []string{"working_assets", "broken_assets", "misplaced_assets", "missing_assets", "expected_off_site"}
 var categoryLabel = map[string]string{
-	"working_assets":   "sesuai dan berfungsi",
-	"broken_assets":    "rusak",
-	"misplaced_assets": "selisih administrasi (karena mutasi)",
-	"missing_assets":   "tidak ditemukan",
+	"working_assets":    "sesuai dan berfungsi",
+	"broken_assets":     "rusak",
+	"misplaced_assets":  "selisih administrasi (karena mutasi)",
+	"missing_assets":    "tidak ditemukan",
+	"expected_off_site": "di luar lokasi (dipinjam)",
 }
 
-func getBAPTemplate() (string, error) {
-	fmt.Println(os.Getwd())
-	file, err := os.Open("templates/bap_template.html")
+// PDFOptions controls the page setup passed to wkhtmltopdf. Margins are in millimetres.
+type PDFOptions struct {
+	Orientation  string
+	PageSize     string
+	MarginTop    uint
+	MarginBottom uint
+	MarginLeft   uint
+	MarginRight  uint
+	// DisableSmartShrinking keeps CSS millimetre sizes exact, needed for label sheets.
+	DisableSmartShrinking bool
+}
+
+// bapPDFOptions is the landscape A4 setup used by the BAP document.
+var bapPDFOptions = PDFOptions{
+	Orientation:  wkhtmltopdf.OrientationLandscape,
+	PageSize:     wkhtmltopdf.PageSizeA4,
+	MarginTop:    12,
+	MarginBottom: 12,
+	MarginLeft:   10,
+	MarginRight:  10,
+}
+
+// bapTemplateFuncs are the helpers used by bap_template.html.
+var bapTemplateFuncs = template.FuncMap{
+	"Label":   func(category string) string { return categoryLabel[category] },
+	"Safe":    func(nullable interface{}) string { return utils.SafeString(nullable) },
+	"SafeInt": func(nullableInt sql.NullInt64) string { return utils.SafeIntString(nullableInt) },
+	"add":     func(a, b int) int { return a + b },
+	"sub":     func(a, b int) int { return a - b },
+	"len": func(slice interface{}) int {
+		switch cast := slice.(type) {
+		case []BAPDetailRow:
+			return len(cast)
+		default:
+			return 0
+		}
+	},
+	"FisikQty": func(row BAPRecapRow) int64 {
+		// Loaned assets are not physically there either, but they are accounted for and are no discrepancy.
+		if row.Category == "missing_assets" || row.Category == "expected_off_site" {
+			return 0
+		}
+		return row.AssetCount
+	},
+	"DataQty": func(row BAPRecapRow) int64 { return row.AssetCount },
+	"Selisih": func(row BAPRecapRow) string {
+		if row.Category == "missing_assets" {
+			return fmt.Sprintf("%d", row.AssetCount)
+		}
+		return "-"
+	},
+	"Satuan": func(row BAPRecapRow) string { return "Unit" },
+	"Rupiah": utils.FormatRupiah,
+	"upper":  strings.ToUpper,
+}
+
+// TemplateFuncs returns the template functions the report templates need, keyed by template name, for templates.Load.
+func TemplateFuncs() map[string]template.FuncMap {
+	return map[string]template.FuncMap{"bap_template.html": bapTemplateFuncs}
+}
+
+// RenderTemplate renders one of the preloaded HTML templates, e.g. before passing it to RenderPDF.
+func (service *Service) RenderTemplate(name string, data any) ([]byte, error) {
+	return service.templates.Render(name, data)
+}
+
+// GetOpnameStats counts the assets of a session per category, with their acquisition cost and net book value per category
+// and product variety as of the session's submission, or now while it is still active, and whether its loss needs an approval.
+func (service *Service) GetOpnameStats(ctx context.Context, sessionID int64) (*OpnameStats, error) {
+	stats, err := service.repo.GetOpnameStats(ctx, sessionID)
+	if err != nil || stats == nil {
+		return nil, err
+	}
+
+	valuedAt, err := service.submissionTime(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	_, varietyValues, err := service.valuate(ctx, sessionID, valuedAt)
+	if err != nil {
+		return nil, err
+	}
+	stats.Values = varietyValues
+	for _, categoryValue := range categoryValues(varietyValues) {
+		switch categoryValue.Category {
+		case "broken_assets":
+			stats.BrokenValue = categoryValue.NetValue
+		case "missing_assets":
+			stats.MissingValue = categoryValue.NetValue
+		}
+	}
+
+	stats.LossThreshold = service.opname.LossThreshold
+	stats.LossApprovalRequired = service.opname.RequiresLossApproval(stats.LossValue())
+	stats.LossApproval, err = service.repo.GetLossApproval(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetBAPRecap retrieves the recap rows of a session with their acquisition cost and net book value as of its submission.
+func (service *Service) GetBAPRecap(ctx context.Context, sessionID int64) ([]BAPRecapRow, error) {
+	recap, err := service.repo.GetBAPRecap(ctx, sessionID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer file.Close()
 
-	content, err := io.ReadAll(file)
+	valuedAt, err := service.submissionTime(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	_, varietyValues, err := service.valuate(ctx, sessionID, valuedAt)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	fillRecapValues(recap, varietyValues)
+	return recap, nil
+}
 
-	return string(content), nil
+// submissionTime returns when a session was submitted, the date its assets are valued at, or now while it is still active.
+func (service *Service) submissionTime(ctx context.Context, sessionID int64) (time.Time, error) {
+	sessionMeta, err := service.repo.GetSessionMeta(ctx, sessionID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if sessionMeta != nil {
+		if submitTime := service.parseTimestamp(sessionMeta.EndDate); submitTime != nil {
+			return *submitTime, nil
+		}
+	}
+	return time.Now(), nil
 }
 
-func (service *Service) GetOpnameStats(sessionID int64) (*OpnameStats, error) {
-	stats, _ := service.repo.GetOpnameStats(sessionID)
-	log.Printf("✅ Successfully retrieved opname stats for session ID %d", sessionID)
-	return stats, nil
+// valuate computes the net book value at valuedAt of the assets of a session, by asset tag,
+// and totals their acquisition cost and net book value per category and product variety, in the BAP order.
+func (service *Service) valuate(ctx context.Context, sessionID int64, valuedAt time.Time) (map[string]int64, []VarietyValue, error) {
+	assetValues, err := service.repo.GetAssetValues(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	assetBookValues := make(map[string]int64, len(assetValues))
+	varietyIndex := make(map[[2]string]int)
+	varietyValues := make([]VarietyValue, 0)
+	for _, assetValue := range assetValues {
+		bookValue := assetValue.BookValue(valuedAt)
+		assetBookValues[assetValue.AssetTag] = bookValue
+
+		key := [2]string{assetValue.Category, assetValue.ProductVariety}
+		i, ok := varietyIndex[key]
+		if !ok {
+			i = len(varietyValues)
+			varietyIndex[key] = i
+			varietyValues = append(varietyValues, VarietyValue{Category: assetValue.Category, ProductVariety: assetValue.ProductVariety})
+		}
+		varietyValues[i].AssetCount++
+		varietyValues[i].TotalValue += max(assetValue.Cost, 0)
+		varietyValues[i].NetValue += bookValue
+	}
+
+	sort.SliceStable(varietyValues, func(i, j int) bool {
+		if categoryIndex(varietyValues[i].Category) == categoryIndex(varietyValues[j].Category) {
+			return varietyValues[i].ProductVariety < varietyValues[j].ProductVariety
+		}
+		return categoryIndex(varietyValues[i].Category) < categoryIndex(varietyValues[j].Category)
+	})
+	return assetBookValues, varietyValues, nil
 }
 
-// GenerateBAPPDF delegates to HTML path for backward compatibility with existing callers.
-func (service *Service) GenerateBAPPDF(sessionID int64, signatures []string, siteName, siteGroup string, endDate time.Time) ([]byte, error) {
-	return service.GenerateBAPPDFHTML(sessionID, signatures, siteName, siteGroup, endDate)
+// fillRecapValues sets the acquisition cost and net book value of the recap rows from the values of their product variety.
+func fillRecapValues(recap []BAPRecapRow, varietyValues []VarietyValue) {
+	for _, varietyValue := range varietyValues {
+		for i := range recap {
+			if recap[i].Category == varietyValue.Category && recap[i].ProductVariety == varietyValue.ProductVariety {
+				recap[i].TotalValue = varietyValue.TotalValue
+				recap[i].NetValue = varietyValue.NetValue
+			}
+		}
+	}
 }
 
-func (service *Service) GenerateBAPPDFHTML(sessionID int64, signatures []string, siteName, siteGroup string, endDate time.Time) ([]byte, error) {
-	if _, err := exec.LookPath("wkhtmltopdf"); err != nil {
-		log.Printf("[REPORT] wkhtmltopdf not found: %v", err)
-		return nil, fmt.Errorf("wkhtmltopdf binary not found in PATH: %w", err)
+// categoryValues sums the values of the product varieties per category, in the BAP order. Every category is listed, empty ones with zeros.
+func categoryValues(varietyValues []VarietyValue) []CategoryValue {
+	totals := make([]CategoryValue, len(categoryOrder))
+	for i, category := range categoryOrder {
+		totals[i].Category = category
+	}
+	for _, varietyValue := range varietyValues {
+		i := categoryIndex(varietyValue.Category)
+		if i >= len(totals) {
+			continue
+		}
+		totals[i].AssetCount += varietyValue.AssetCount
+		totals[i].TotalValue += varietyValue.TotalValue
+		totals[i].NetValue += varietyValue.NetValue
 	}
-	log.Printf("[REPORT] Generating BAP HTML->PDF session=%d", sessionID)
+	return totals
+}
 
-	recapRows, err := service.repo.GetBAPRecap(sessionID)
+// categoryIndex returns the position of a category in the BAP, unknown categories last.
+func categoryIndex(category string) int {
+	for i, v := range categoryOrder {
+		if v == category {
+			return i
+		}
+	}
+	return 99
+}
+
+// GenerateBAPPDF delegates to HTML path for backward compatibility with existing callers.
+// Every BAP goes through here so its generation time is recorded in the metrics.
+func (service *Service) GenerateBAPPDF(ctx context.Context, sessionID int64, signatures []string, siteName, siteGroup string, endDate time.Time) ([]byte, error) {
+	start := time.Now()
+	pdfBytes, err := service.GenerateBAPPDFHTML(ctx, sessionID, signatures, siteName, siteGroup, endDate)
+	metrics.ObserveBAPGeneration(start, err)
+	return pdfBytes, err
+}
+
+func (service *Service) GenerateBAPPDFHTML(ctx context.Context, sessionID int64, signatures []string, siteName, siteGroup string, endDate time.Time) ([]byte, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	logger.Debug("rendering BAP HTML", "session_id", sessionID)
+
+	recapRows, err := service.repo.GetBAPRecap(ctx, sessionID)
 	if err != nil {
 		return nil, err
 	}
-	detailRows, err := service.repo.GetBAPDetails(sessionID)
+	detailRows, err := service.repo.GetBAPDetails(ctx, sessionID)
 	if err != nil {
 		return nil, err
 	}
 
 	recapTable := append([]BAPRecapRow(nil), recapRows...)
 
-	orderIndex := func(category string) int {
-		for i, v := range categoryOrder {
-			if v == category {
-				return i
-			}
-		}
-		return 99
-	}
-
 	sort.Slice(recapTable, func(i, j int) bool {
-		if orderIndex(recapTable[i].Category) == orderIndex(recapTable[j].Category) {
+		if categoryIndex(recapTable[i].Category) == categoryIndex(recapTable[j].Category) {
 			return recapTable[i].ProductVariety < recapTable[j].ProductVariety
 		}
-		return orderIndex(recapTable[i].Category) < orderIndex(recapTable[j].Category)
+		return categoryIndex(recapTable[i].Category) < categoryIndex(recapTable[j].Category)
 	})
 
+	// Assets are valued as of the BAP date.
+	assetBookValues, varietyValues, err := service.valuate(ctx, sessionID, endDate)
+	if err != nil {
+		return nil, err
+	}
+	fillRecapValues(recapTable, varietyValues)
+	for i := range detailRows {
+		if bookValue, ok := assetBookValues[detailRows[i].AssetTag]; ok {
+			detailRows[i].BookValue = sql.NullInt64{Int64: bookValue, Valid: true}
+		}
+	}
+
 	sort.Slice(detailRows, func(i, j int) bool {
-		if orderIndex(detailRows[i].Category) == orderIndex(detailRows[j].Category) {
+		if categoryIndex(detailRows[i].Category) == categoryIndex(detailRows[j].Category) {
 			return detailRows[i].AssetTag < detailRows[j].AssetTag
 		}
-		return orderIndex(detailRows[i].Category) < orderIndex(detailRows[j].Category)
+		return categoryIndex(detailRows[i].Category) < categoryIndex(detailRows[j].Category)
 	})
 
+	categories := categoryValues(varietyValues)
+	lossValue := categories[categoryIndex("broken_assets")].NetValue + categories[categoryIndex("missing_assets")].NetValue
+	lossApproval, err := service.repo.GetLossApproval(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	sessionMeta, err := service.repo.GetSessionMeta(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	var sessionStatus string
+	if sessionMeta != nil {
+		sessionStatus = sessionMeta.Status
+	}
+
 	data := struct {
 		SiteName      string
 		SiteGroup     string
@@ -113,6 +331,9 @@ func (service *Service) GenerateBAPPDFHTML(sessionID int64, signatures []string,
 		CategoryLabel map[string]string
 		Signatures    []string
 		Details       []BAPDetailRow
+		Categories    []CategoryValue
+		LossValue     int64
+		LossNote      string
 	}{
 		SiteName:      siteName,
 		SiteGroup:     siteGroup,
@@ -122,82 +343,80 @@ func (service *Service) GenerateBAPPDFHTML(sessionID int64, signatures []string,
 		CategoryLabel: categoryLabel,
 		Signatures:    signatures,
 		Details:       detailRows,
+		Categories:    categories,
+		LossValue:     lossValue,
+		LossNote:      service.lossNote(lossValue, lossApproval, sessionStatus),
 	}
 
-	templateContent, err := getBAPTemplate()
+	html, err := service.RenderTemplate("bap_template.html", data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read template: %w", err)
-	}
-
-	templateFunctions := template.FuncMap{
-		"Label":   func(category string) string { return categoryLabel[category] },
-		"Safe":    func(nullable interface{}) string { return utils.SafeString(nullable) },
-		"SafeInt": func(nullableInt sql.NullInt64) string { return utils.SafeIntString(nullableInt) },
-		"add":     func(a, b int) int { return a + b },
-		"sub":     func(a, b int) int { return a - b },
-		"len": func(slice interface{}) int {
-			switch cast := slice.(type) {
-			case []BAPDetailRow:
-				return len(cast)
-			default:
-				return 0
-			}
-		},
-		"FisikQty": func(row BAPRecapRow) int64 {
-			if row.Category == "missing_assets" {
-				return 0
-			}
-			return row.AssetCount
-		},
-		"DataQty": func(row BAPRecapRow) int64 { return row.AssetCount },
-		"Selisih": func(row BAPRecapRow) string {
-			if row.Category == "missing_assets" {
-				return fmt.Sprintf("%d", row.AssetCount)
-			}
-			return "-"
-		},
-		"Satuan": func(row BAPRecapRow) string { return "Unit" },
-		"upper":  strings.ToUpper,
+		return nil, fmt.Errorf("failed to render template: %w", err)
 	}
 
-	tpl, err := template.New("bap").Funcs(templateFunctions).Parse(templateContent)
+	pdfBytes, err := service.RenderPDF(ctx, html, bapPDFOptions)
 	if err != nil {
 		return nil, err
 	}
+	logger.Debug("rendered BAP PDF", "session_id", sessionID, "size_bytes", len(pdfBytes))
+	return pdfBytes, nil
+}
 
-	var htmlBuffer bytes.Buffer
-	if err := tpl.Execute(&htmlBuffer, data); err != nil {
+// CheckPDFBackend reports whether the wkhtmltopdf binary used by RenderPDF is available.
+func CheckPDFBackend() error {
+	if _, err := exec.LookPath("wkhtmltopdf"); err != nil {
+		return fmt.Errorf("wkhtmltopdf binary not found in PATH: %w", err)
+	}
+	return nil
+}
+
+// RenderPDF converts a rendered HTML document into PDF bytes through wkhtmltopdf.
+// It is the single PDF pipeline shared by the BAP and any other printable document.
+func (service *Service) RenderPDF(ctx context.Context, html []byte, options PDFOptions) ([]byte, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if err := CheckPDFBackend(); err != nil {
+		logger.Error("wkhtmltopdf not found in PATH", "error", err)
 		return nil, err
 	}
 
 	pdfGenerator, err := wkhtmltopdf.NewPDFGenerator()
 	if err != nil {
-		log.Printf("[REPORT] wkhtmltopdf init failed: %v", err)
+		logger.Error("failed to initialise wkhtmltopdf", "error", err)
 		return nil, fmt.Errorf("wkhtmltopdf init failed: %w", err)
 	}
-	page := wkhtmltopdf.NewPageReader(bytes.NewReader(htmlBuffer.Bytes()))
+	page := wkhtmltopdf.NewPageReader(bytes.NewReader(html))
 	page.EnableLocalFileAccess.Set(true)
+	if options.DisableSmartShrinking {
+		page.DisableSmartShrinking.Set(true)
+	}
 	pdfGenerator.AddPage(page)
 	pdfGenerator.Dpi.Set(96)
-	pdfGenerator.Orientation.Set(wkhtmltopdf.OrientationLandscape)
-	pdfGenerator.PageSize.Set(wkhtmltopdf.PageSizeA4)
-	pdfGenerator.MarginLeft.Set(10)
-	pdfGenerator.MarginRight.Set(10)
-	pdfGenerator.MarginTop.Set(12)
-	pdfGenerator.MarginBottom.Set(12)
-	if err := pdfGenerator.Create(); err != nil {
-		log.Printf("[REPORT] wkhtmltopdf create failed: %v", err)
+	pdfGenerator.Orientation.Set(options.Orientation)
+	pdfGenerator.PageSize.Set(options.PageSize)
+	pdfGenerator.MarginLeft.Set(options.MarginLeft)
+	pdfGenerator.MarginRight.Set(options.MarginRight)
+	pdfGenerator.MarginTop.Set(options.MarginTop)
+	pdfGenerator.MarginBottom.Set(options.MarginBottom)
+	// The wkhtmltopdf process is killed when ctx is cancelled (client gone or route timeout).
+	if err := pdfGenerator.CreateContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			logger.Warn("PDF rendering cancelled", "error", ctx.Err())
+			return nil, ctx.Err()
+		}
+		logger.Error("wkhtmltopdf failed to create PDF", "error", err)
 		return nil, fmt.Errorf("wkhtmltopdf create failed: %w", err)
 	}
-	log.Printf("[REPORT] PDF generated size=%d bytes session=%d", len(pdfGenerator.Bytes()), sessionID)
 	return pdfGenerator.Bytes(), nil
 }
 
 // GenerateAndAssembleBAP gathers meta + signatures then produces PDF + filename.
-func (service *Service) GenerateAndAssembleBAP(sessionID int64) ([]byte, string, error) {
-	sessionMeta, err := service.repo.GetSessionMeta(sessionID)
-	if err != nil || sessionMeta == nil {
-		return nil, "", fmt.Errorf("session not found")
+func (service *Service) GenerateAndAssembleBAP(ctx context.Context, sessionID int64) ([]byte, string, error) {
+	sessionMeta, err := service.repo.GetSessionMeta(ctx, sessionID)
+	if err != nil {
+		return nil, "", err
+	}
+	if sessionMeta == nil {
+		return nil, "", apperr.NotFound("opname_session_not_found", "opname session not found")
 	}
 
 	type locationInfo struct{ Name, Group string }
@@ -206,66 +425,53 @@ func (service *Service) GenerateAndAssembleBAP(sessionID int64) ([]byte, string,
 
 	if sessionMeta.SiteID.Valid {
 		// Site-based opname
-		row := service.repo.db.QueryRow(`SELECT site_name, site_group_name FROM get_site_by_id($1)`, sessionMeta.SiteID.Int64)
+		row := service.repo.db.QueryRowContext(ctx, `SELECT site_name, site_group_name FROM get_site_by_id($1)`, sessionMeta.SiteID.Int64)
 		if err := row.Scan(&locationData.Name, &locationData.Group); err != nil {
 			return nil, "", fmt.Errorf("site fetch failed: %w", err)
 		}
 		locationName = locationData.Name
 	} else if sessionMeta.DeptID.Valid {
-		// Department-based opname - need to get dept info and associated site info
-		row := service.repo.db.QueryRow(`SELECT dept_name, site_name FROM get_dept_by_id($1)`, sessionMeta.DeptID.Int64)
+		// Department-based opname - need to get dept info and its head office site
+		row := service.repo.db.QueryRowContext(ctx, `SELECT dept_name, site_name FROM get_dept_by_id($1)`, sessionMeta.DeptID.Int64)
 		var deptName, siteName string
 		if err := row.Scan(&deptName, &siteName); err != nil {
 			return nil, "", fmt.Errorf("department fetch failed: %w", err)
 		}
 		locationData.Name = deptName
-		locationData.Group = siteName // Show "Head Office Jakarta" as the group for departments
+		locationData.Group = siteName // Show the department's head office as the group
 		locationName = deptName
 	} else {
 		return nil, "", fmt.Errorf("session has neither site_id nor dept_id")
 	}
 
 	var submitterFirst, submitterLast string
-	_ = service.repo.db.QueryRow(`SELECT first_name, last_name FROM get_user_by_id($1)`, sessionMeta.UserID).Scan(&submitterFirst, &submitterLast)
+	_ = service.repo.db.QueryRowContext(ctx, `SELECT first_name, last_name FROM get_user_by_id($1)`, sessionMeta.UserID).Scan(&submitterFirst, &submitterLast)
 	submitterName := strings.TrimSpace(submitterFirst + " " + submitterLast)
 	statusLower := strings.ToLower(sessionMeta.Status)
 	var managerName, l1Name string
 	if sessionMeta.ManagerReviewerID.Valid {
 		var firstName, lastName string
-		_ = service.repo.db.QueryRow(`SELECT first_name, last_name FROM get_user_by_id($1)`, sessionMeta.ManagerReviewerID.Int64).Scan(&firstName, &lastName)
+		_ = service.repo.db.QueryRowContext(ctx, `SELECT first_name, last_name FROM get_user_by_id($1)`, sessionMeta.ManagerReviewerID.Int64).Scan(&firstName, &lastName)
 		managerName = strings.TrimSpace(firstName + " " + lastName)
 	}
 	if sessionMeta.L1ReviewerID.Valid {
 		var firstName, lastName string
-		_ = service.repo.db.QueryRow(`SELECT first_name, last_name FROM get_user_by_id($1)`, sessionMeta.L1ReviewerID.Int64).Scan(&firstName, &lastName)
+		_ = service.repo.db.QueryRowContext(ctx, `SELECT first_name, last_name FROM get_user_by_id($1)`, sessionMeta.L1ReviewerID.Int64).Scan(&firstName, &lastName)
 		l1Name = strings.TrimSpace(firstName + " " + lastName)
 	}
 	if sessionMeta.L1ReviewerID.Valid {
 		var firstName, lastName string
-		_ = service.repo.db.QueryRow(`SELECT first_name, last_name FROM get_user_by_id($1)`, sessionMeta.L1ReviewerID.Int64).Scan(&firstName, &lastName)
+		_ = service.repo.db.QueryRowContext(ctx, `SELECT first_name, last_name FROM get_user_by_id($1)`, sessionMeta.L1ReviewerID.Int64).Scan(&firstName, &lastName)
 		l1Name = strings.TrimSpace(firstName + " " + lastName)
 	}
-	locationJakarta, _ := time.LoadLocation("Asia/Jakarta")
-	parseTimestamp := func(nullableString sql.NullString) *time.Time {
-		if !nullableString.Valid || strings.TrimSpace(nullableString.String) == "" {
-			return nil
-		}
-		layouts := []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05Z07:00"}
-		for _, layout := range layouts {
-			if parsed, err := time.ParseInLocation(layout, nullableString.String, locationJakarta); err == nil {
-				return &parsed
-			}
-		}
-		return nil
-	}
-	submitTime := parseTimestamp(sessionMeta.EndDate)
-	managerTime := parseTimestamp(sessionMeta.ManagerReviewedAt)
-	l1Time := parseTimestamp(sessionMeta.L1ReviewedAt)
-	if !(statusLower == "escalated" || statusLower == "verified" || statusLower == "rejected") {
+	submitTime := service.parseTimestamp(sessionMeta.EndDate)
+	managerTime := service.parseTimestamp(sessionMeta.ManagerReviewedAt)
+	l1Time := service.parseTimestamp(sessionMeta.L1ReviewedAt)
+	if !(statusLower == "escalated" || statusLower == "loss review" || statusLower == "verified" || statusLower == "rejected") {
 		managerName = ""
 		managerTime = nil
 	}
-	if !(statusLower == "verified" || statusLower == "rejected") {
+	if !(statusLower == "loss review" || statusLower == "verified" || statusLower == "rejected") {
 		l1Name = ""
 		l1Time = nil
 	}
@@ -274,7 +480,7 @@ func (service *Service) GenerateAndAssembleBAP(sessionID int64) ([]byte, string,
 	if submitTime != nil {
 		endTime = *submitTime
 	}
-	pdfBytes, err := service.GenerateBAPPDFHTML(sessionID, signatures, locationData.Name, locationData.Group, endTime)
+	pdfBytes, err := service.GenerateBAPPDF(ctx, sessionID, signatures, locationData.Name, locationData.Group, endTime)
 	if err != nil {
 		return nil, "", err
 	}
@@ -282,6 +488,20 @@ func (service *Service) GenerateAndAssembleBAP(sessionID int64) ([]byte, string,
 	return pdfBytes, filename, nil
 }
 
+// parseTimestamp parses a timestamp of the session metadata in the BAP timezone, nil when it is empty or malformed.
+func (service *Service) parseTimestamp(nullableString sql.NullString) *time.Time {
+	if !nullableString.Valid || strings.TrimSpace(nullableString.String) == "" {
+		return nil
+	}
+	layouts := []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05Z07:00"}
+	for _, layout := range layouts {
+		if parsed, err := time.ParseInLocation(layout, nullableString.String, service.location); err == nil {
+			return &parsed
+		}
+	}
+	return nil
+}
+
 func sanitizeFileFragment(s string) string {
 	out := make([]rune, 0, len(s))
 	for _, r := range s {
@@ -297,6 +517,28 @@ func sanitizeFileFragment(s string) string {
 	return string(out)
 }
 
+// lossNote describes on the BAP whether the loss of a session needs an approval and who gave it, empty when none is needed.
+// Sessions closed without an approval, e.g. before the threshold was configured, get no note.
+func (service *Service) lossNote(lossValue int64, approval *LossApproval, sessionStatus string) string {
+	if approval == nil {
+		if sessionStatus == "Verified" || sessionStatus == "Rejected" || !service.opname.RequiresLossApproval(lossValue) {
+			return ""
+		}
+		return fmt.Sprintf("Kerugian melebihi batas %s dan memerlukan persetujuan setelah verifikasi L1.", utils.FormatRupiah(service.opname.LossThreshold))
+	}
+
+	exceeded := fmt.Sprintf("Kerugian melebihi batas %s", utils.FormatRupiah(approval.LossThreshold))
+	if !approval.Decision.Valid {
+		return exceeded + " dan menunggu persetujuan."
+	}
+	verb := "disetujui"
+	if approval.Decision.String == "Rejected" {
+		verb = "ditolak"
+	}
+	reviewedAt := approval.ReviewedAt.Time.In(service.location).Format("2006-01-02 15:04 WIB")
+	return fmt.Sprintf("%s, %s oleh: %s – %s", exceeded, verb, strings.TrimSpace(approval.ReviewerName.String), reviewedAt)
+}
+
 func BuildSignatures(submitter string, submitTime *time.Time, manager string, managerTime *time.Time, level1 string, level1Time *time.Time) []string {
 	var signatures []string
 	format := func(t *time.Time) string {
@@ -318,13 +560,29 @@ func BuildSignatures(submitter string, submitTime *time.Time, manager string, ma
 }
 
 // SetActionNotes updates the action note for a specific asset change record
-func (service *Service) SetActionNotes(assetTag string, sessionID int64, userID int64, actionNotes string) error {
-	// No logical operations needed yet
-	return service.repo.SetActionNotes(assetTag, sessionID, userID, actionNotes)
+func (service *Service) SetActionNotes(ctx context.Context, assetTag string, sessionID int64, userID int64, actionNotes string) error {
+	if err := service.repo.SetActionNotes(ctx, assetTag, sessionID, userID, actionNotes); err != nil {
+		return err
+	}
+	audit.Record(ctx, audit.Event{
+		Action:     "report.action_notes_set",
+		EntityType: "asset",
+		EntityID:   assetTag,
+		After:      map[string]any{"session_id": sessionID, "action_notes": actionNotes},
+	})
+	return nil
 }
 
 // DeleteActionNotes removes the action note for a specific asset change record
-func (service *Service) DeleteActionNotes(assetTag string, sessionID int64, userID int64) error {
-	// No logical operations needed yet
-	return service.repo.DeleteActionNotes(assetTag, sessionID, userID)
+func (service *Service) DeleteActionNotes(ctx context.Context, assetTag string, sessionID int64, userID int64) error {
+	if err := service.repo.DeleteActionNotes(ctx, assetTag, sessionID, userID); err != nil {
+		return err
+	}
+	audit.Record(ctx, audit.Event{
+		Action:     "report.action_notes_delete",
+		EntityType: "asset",
+		EntityID:   assetTag,
+		Before:     map[string]any{"session_id": sessionID},
+	})
+	return nil
 }
@@ -0,0 +1,38 @@
+// == Handles all database operations related to roles ==
+package roles
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
+	"github.com/lib/pq"
+)
+
+type Repository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewRepository creates a new role repository.
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SyncAdminPositions replaces the admin positions the stored procedures check (see is_admin_position) with auth.admin_positions.
+func (repo *Repository) SyncAdminPositions(ctx context.Context, positions []string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL set_admin_positions($1)`
+	if _, err := repo.db.ExecContext(ctx, query, pq.Array(positions)); err != nil {
+		logger.Error("failed to sync admin positions", "positions", positions, "error", err)
+		return apperr.FromPostgres(err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// == Decides what a user may do from their job position, the one place the admin positions are checked ==
+package roles
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginPositions lists the positions that may sign in. Users holding any other position are refused at login.
+// ! Note: This is a temporary measure and should be replaced with a more robust access control system, e.g. storing user roles in the database.
+var LoginPositions = []string{
+	"ADMIN STAFF GENERAL AFFAIRS",
+	"L1 SUPPORT",
+	"AREA MANAGER",
+	"IT SERVICES MANAGER",
+	"FINANCE & ACCOUNTING MANAGER",
+	"AUDITOR",
+}
+
+// DefaultAdminPositions administer the system unless auth.admin_positions says otherwise.
+var DefaultAdminPositions = []string{"L1 SUPPORT"}
+
+// adminPositions is set once at startup by SetAdminPositions, before the server handles requests.
+var adminPositions = DefaultAdminPositions
+
+// SetAdminPositions replaces the positions administering the system, from auth.admin_positions.
+// The database keeps its own copy for the stored procedures, see Repository.SyncAdminPositions.
+func SetAdminPositions(positions []string) {
+	adminPositions = slices.Clone(positions)
+}
+
+// IsAdmin reports whether users with the position administer the system.
+func IsAdmin(position string) bool {
+	return matches(adminPositions, position)
+}
+
+// CanLogin reports whether users with the position may sign in.
+func CanLogin(position string) bool {
+	return matches(LoginPositions, position)
+}
+
+// Actor is the authenticated user performing an action, as read from the JWT claims.
+type Actor struct {
+	UserID   int64
+	Position string
+}
+
+// ActorFromContext reads the actor set on the request by the auth middleware. It reports false on unauthenticated requests.
+func ActorFromContext(context *gin.Context) (Actor, bool) {
+	userID, exists := context.Get("user_id")
+	if !exists {
+		return Actor{}, false
+	}
+	return Actor{UserID: userID.(int64), Position: context.GetString("position")}, true
+}
+
+// IsAdmin reports whether the actor administers the system.
+func (actor Actor) IsAdmin() bool {
+	return IsAdmin(actor.Position)
+}
+
+// matches reports whether the position is one of positions, ignoring case and surrounding spaces.
+func matches(positions []string, position string) bool {
+	return slices.ContainsFunc(positions, func(candidate string) bool {
+		return strings.EqualFold(strings.TrimSpace(candidate), strings.TrimSpace(position))
+	})
+}
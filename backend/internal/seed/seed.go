@@ -1,5 +1,6 @@
 // A standalone program to populate the database with initial dummy data.
 // IMPORTANT: Make sure to run this everytime the database schema changes.
+// The schema itself is created by the migrations (go run ./cmd/api migrate up), run them before seeding.
 
 package main
 
@@ -129,12 +130,13 @@ func seedSiteGroup(db *sql.DB, record []string) error {
 }
 
 // Expected CSV format for site.csv:
-// id [PK], site_name, site_group_id [FK], site_ga_id [FK]
+// id [PK], site_name, site_group_id [FK], site_ga_id [FK], is_head_office
 func seedSite(db *sql.DB, record []string) error {
 	site_name := record[1]
 	site_group_id := record[2]
 	site_ga_id := record[3]
-	query := `INSERT INTO "Site" (site_name, site_group_id, site_ga_id) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING`
+	is_head_office := record[4]
+	query := `INSERT INTO "Site" (site_name, site_group_id, site_ga_id, is_head_office) VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO NOTHING`
 
 	// Check if the referenced site_group_id exists
 	var exists bool
@@ -149,7 +151,7 @@ func seedSite(db *sql.DB, record []string) error {
 		return fmt.Errorf("site_group_id %v does not exist", site_group_id)
 	}
 
-	_, err = db.Exec(query, site_name, site_group_id, site_ga_id)
+	_, err = db.Exec(query, site_name, site_group_id, site_ga_id, is_head_office)
 	if err != nil {
 		log.Fatalf("Error inserting record into Site table: %v\n", err)
 		return err
@@ -175,12 +177,13 @@ func seedSubSite(db *sql.DB, record []string) error {
 }
 
 // Expected CSV format for department.csv:
-// id [PK], dept_name
+// id [PK], dept_name, site_id [FK] (a head office site)
 func seedDepartment(db *sql.DB, record []string) error {
 	dept_name := record[1]
-	query := `INSERT INTO "Department" (dept_name) VALUES ($1) ON CONFLICT (id) DO NOTHING`
+	site_id := record[2]
+	query := `INSERT INTO "Department" (dept_name, site_id) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`
 
-	_, err := db.Exec(query, dept_name)
+	_, err := db.Exec(query, dept_name, site_id)
 	if err != nil {
 		log.Fatalf("Error inserting record into Department table: %v\n", err)
 		return err
@@ -2,44 +2,48 @@
 package site
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
 	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
 	service *Service
+	logger  *slog.Logger
 }
 
 // NewHandler creates a new instance of Handler with the provided service.
-func NewHandler(service *Service) *Handler {
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
 	return &Handler{
 		service: service,
+		logger:  logger,
 	}
 }
 
 // GetAllSitesHandler handles the API request to retrieve all sites.
 func (handler *Handler) GetAllSitesHandler(context *gin.Context) {
-	allSites, err := handler.service.GetAllSites()
+	logger := logging.FromGin(context, handler.logger)
+
+	allSites, err := handler.service.GetAllSites(context.Request.Context())
 	if err != nil {
-		log.Printf("Error fetching all sites: %v", err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to fetch sites: " + err.Error(),
-		})
+		logger.Error("failed to fetch all sites", "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
 	if allSites == nil {
-		log.Println("No sites found in the system")
+		logger.Debug("no sites found in the system")
 		context.JSON(http.StatusNotFound, gin.H{
 			"message": "no sites found",
 		})
 		allSites = make([]*Site, 0)
 	}
 
-	log.Printf("Successfully retrieved %d sites", len(allSites))
 	context.JSON(http.StatusOK, gin.H{
 		"sites": allSites,
 	})
@@ -47,42 +51,37 @@ func (handler *Handler) GetAllSitesHandler(context *gin.Context) {
 
 // GetSiteByIDHandler handles the API request to retrieve a site by its ID.
 func (handler *Handler) GetSiteByIDHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	siteIDstr, exists := context.Params.Get("site-id")
 	if !exists {
-		log.Printf("Error retrieving site ID from request")
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid API request, site ID is required",
-		})
+		logger.Warn("missing site id in request")
+		apperr.Abort(context, apperr.Validation("invalid_site_id", "invalid API request, site ID is required"))
 		return
 	}
 
 	siteID, err := strconv.Atoi(siteIDstr)
 	if err != nil {
-		log.Printf("Error converting site ID to integer: %v", err)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid site ID format",
-		})
+		logger.Warn("invalid site id", "site_id", siteIDstr, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_site_id", "invalid site ID format"))
 		return
 	}
 
-	site, err := handler.service.GetSiteByID(siteID)
+	site, err := handler.service.GetSiteByID(context.Request.Context(), siteID)
 	if err != nil {
-		log.Printf("Error fetching site by ID %v: %v", siteID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to fetch site: " + err.Error(),
-		})
+		logger.Error("failed to fetch site", "site_id", siteID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
 	if site == nil {
-		log.Printf("No site found with ID: %v", siteID)
+		logger.Debug("no site found", "site_id", siteID)
 		context.JSON(http.StatusNotFound, gin.H{
 			"message": "site not found",
 		})
 		return
 	}
 
-	log.Printf("Successfully retrieved site by ID: %v", siteID)
 	context.JSON(http.StatusOK, gin.H{
 		"site_id":           site.SiteID,
 		"site_name":         site.SiteName,
@@ -94,21 +93,20 @@ func (handler *Handler) GetSiteByIDHandler(context *gin.Context) {
 
 // GetAllSubSitesHandler handles the API request to retrieve all sub-sites.
 func (handler *Handler) GetAllSubSitesHandler(context *gin.Context) {
-	subSites, err := handler.service.GetAllSubSites()
+	logger := logging.FromGin(context, handler.logger)
+
+	subSites, err := handler.service.GetAllSubSites(context.Request.Context())
 	if err != nil {
-		log.Printf("Error fetching all sub-sites: %v", err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to fetch sub-sites: " + err.Error(),
-		})
+		logger.Error("failed to fetch all sub-sites", "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
 	if subSites == nil {
-		log.Println("No sub-sites found in the system")
+		logger.Debug("no sub-sites found in the system")
 		subSites = make([]*SubSite, 0)
 	}
 
-	log.Printf("Successfully retrieved %d sub-sites", len(subSites))
 	context.JSON(http.StatusOK, gin.H{
 		"sub_sites": subSites,
 	})
@@ -116,41 +114,36 @@ func (handler *Handler) GetAllSubSitesHandler(context *gin.Context) {
 
 // GetSubSiteByIDHandler handles the API request to retrieve a sub-site by its ID.
 func (handler *Handler) GetSubSiteByIDHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	subSiteIDstr, exists := context.Params.Get("sub-site-id")
 	if !exists {
-		log.Printf("Error retrieving sub-site ID from request")
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid API request, sub-site ID is required",
-		})
+		logger.Warn("missing sub-site id in request")
+		apperr.Abort(context, apperr.Validation("invalid_sub_site_id", "invalid API request, sub-site ID is required"))
 		return
 	}
 
 	subSiteID, err := strconv.Atoi(subSiteIDstr)
 	if err != nil {
-		log.Printf("Error converting sub-site ID to integer: %v", err)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid sub-site ID format",
-		})
+		logger.Warn("invalid sub-site id", "sub_site_id", subSiteIDstr, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_sub_site_id", "invalid sub-site ID format"))
 		return
 	}
 
-	subSite, err := handler.service.GetSubSiteByID(subSiteID)
+	subSite, err := handler.service.GetSubSiteByID(context.Request.Context(), subSiteID)
 	if err != nil {
-		log.Printf("Error fetching sub-site by ID %v: %v", subSiteID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to fetch sub-site: " + err.Error(),
-		})
+		logger.Error("failed to fetch sub-site", "sub_site_id", subSiteID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 	if subSite == nil {
-		log.Printf("No sub-site found with ID: %v", subSiteID)
+		logger.Debug("no sub-site found", "sub_site_id", subSiteID)
 		context.JSON(http.StatusNotFound, gin.H{
 			"message": "sub-site not found",
 		})
 		return
 	}
 
-	log.Printf("Successfully retrieved sub-site by ID: %v", subSiteID)
 	context.JSON(http.StatusOK, gin.H{
 		"sub_site_id":   subSite.SubSiteID,
 		"sub_site_name": subSite.SubSiteName,
@@ -160,39 +153,34 @@ func (handler *Handler) GetSubSiteByIDHandler(context *gin.Context) {
 
 // GetSubSitesBySiteIDHandler handles the API request to retrieve all sub-sites for a given site ID.
 func (handler *Handler) GetSubSitesBySiteIDHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	siteIDstr, exists := context.Params.Get("site-id")
 	if !exists {
-		log.Printf("Error retrieving site ID from request")
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid API request, site ID is required",
-		})
+		logger.Warn("missing site id in request")
+		apperr.Abort(context, apperr.Validation("invalid_site_id", "invalid API request, site ID is required"))
 		return
 	}
 
 	siteID, err := strconv.Atoi(siteIDstr)
 	if err != nil {
-		log.Printf("Error converting site ID to integer: %v", err)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid site ID format",
-		})
+		logger.Warn("invalid site id", "site_id", siteIDstr, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_site_id", "invalid site ID format"))
 		return
 	}
 
-	subSites, err := handler.service.GetSubSitesBySiteID(siteID)
+	subSites, err := handler.service.GetSubSitesBySiteID(context.Request.Context(), siteID)
 	if err != nil {
-		log.Printf("Error fetching sub-sites for site ID %v: %v", siteID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to fetch sub-sites: " + err.Error(),
-		})
+		logger.Error("failed to fetch sub-sites for site", "site_id", siteID, "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
 	if subSites == nil {
-		log.Printf("No sub-sites found for site ID: %d", siteID)
+		logger.Debug("no sub-sites found for site", "site_id", siteID)
 		subSites = make([]*SubSite, 0)
 	}
 
-	log.Printf("Successfully retrieved %d sub-sites for site ID: %d", len(subSites), siteID)
 	context.JSON(http.StatusOK, gin.H{
 		"sub_sites": subSites,
 	})
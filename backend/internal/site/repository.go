@@ -2,12 +2,17 @@
 package site
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
 )
 
 type Repository struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *slog.Logger
 }
 
 type Site struct {
@@ -25,25 +30,27 @@ type SubSite struct {
 }
 
 // NewRepository creates a new site repository for managing site-related operations.
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{db: db, logger: logger}
 }
 
 // GetAllSites retrieves all sites from the database.
-func (repo *Repository) GetAllSites() ([]*Site, error) {
+func (repo *Repository) GetAllSites(ctx context.Context) ([]*Site, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var allSites []*Site
 
-	rows, err := repo.db.Query("SELECT site_id, site_name, site_group_name, region_name FROM get_all_sites()")
+	rows, err := repo.db.QueryContext(ctx, "SELECT site_id, site_name, site_group_name, region_name FROM get_all_sites()")
 	if err != nil {
-		log.Printf("❌ Error retrieving all sites, error: %v\n", err)
-		return nil, err
+		logger.Error("failed to query all sites", "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var site Site
 		if err := rows.Scan(&site.SiteID, &site.SiteName, &site.SiteGroupName, &site.RegionName); err != nil {
-			return nil, err
+			return nil, apperr.FromPostgres(err)
 		}
 		allSites = append(allSites, &site)
 	}
@@ -52,58 +59,64 @@ func (repo *Repository) GetAllSites() ([]*Site, error) {
 }
 
 // GetSiteByID retrieves a site by its ID from the database.
-func (repo *Repository) GetSiteByID(siteID int) (*Site, error) {
+func (repo *Repository) GetSiteByID(ctx context.Context, siteID int) (*Site, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var site Site
 
 	query := `SELECT * FROM get_site_by_id($1)`
-	err := repo.db.QueryRow(query, siteID).Scan(&site.SiteID, &site.SiteName, &site.SiteGroupName, &site.RegionName, &site.OpnameSessionID)
+	err := repo.db.QueryRowContext(ctx, query, siteID).Scan(&site.SiteID, &site.SiteName, &site.SiteGroupName, &site.RegionName, &site.OpnameSessionID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("⚠ No site found with ID: %d\n", siteID)
+			logger.Debug("no site found", "site_id", siteID)
 			return nil, nil // No site found
 		}
-		log.Printf("❌ Error retrieving site with ID: %d, error: %v\n", siteID, err)
-		return nil, err // Return the error for unexpected cases
+		logger.Error("failed to query site", "site_id", siteID, "error", err)
+		return nil, apperr.FromPostgres(err) // Return the error for unexpected cases
 	}
 
-	log.Printf("✅ Successfully retrieved site with ID: %d\n", siteID)
+	logger.Debug("retrieved site", "site_id", siteID)
 	return &site, nil
 }
 
 // GetSubSiteByID retrieves a sub-site by its ID from the database.
-func (repo *Repository) GetSubSiteByID(subSiteID int) (*SubSite, error) {
+func (repo *Repository) GetSubSiteByID(ctx context.Context, subSiteID int) (*SubSite, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var subSite SubSite
 
 	query := `SELECT * FROM get_sub_site_by_id($1)`
-	err := repo.db.QueryRow(query, subSiteID).Scan(&subSite.SubSiteID, &subSite.SubSiteName, &subSite.SiteID)
+	err := repo.db.QueryRowContext(ctx, query, subSiteID).Scan(&subSite.SubSiteID, &subSite.SubSiteName, &subSite.SiteID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Printf("⚠ No sub-site found with ID: %d\n", subSiteID)
+			logger.Debug("no sub-site found", "sub_site_id", subSiteID)
 			return nil, nil // No sub-site found
 		}
-		log.Printf("❌ Error retrieving sub-site with ID: %d, error: %v\n", subSiteID, err)
-		return nil, err // Return the error for unexpected cases
+		logger.Error("failed to query sub-site", "sub_site_id", subSiteID, "error", err)
+		return nil, apperr.FromPostgres(err) // Return the error for unexpected cases
 	}
 
-	log.Printf("✅ Successfully retrieved sub-site with ID: %d\n", subSiteID)
+	logger.Debug("retrieved sub-site", "sub_site_id", subSiteID)
 	return &subSite, nil
 }
 
 // GetAllSubSites retrieves all sub-sites from the database.
-func (repo *Repository) GetAllSubSites() ([]*SubSite, error) {
+func (repo *Repository) GetAllSubSites(ctx context.Context) ([]*SubSite, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var allSubSites []*SubSite
 
-	rows, err := repo.db.Query("SELECT sub_site_id, sub_site_name, site_id FROM get_all_sub_sites()")
+	rows, err := repo.db.QueryContext(ctx, "SELECT sub_site_id, sub_site_name, site_id FROM get_all_sub_sites()")
 	if err != nil {
-		log.Printf("❌ Error retrieving all sub-sites, error: %v\n", err)
-		return nil, err
+		logger.Error("failed to query all sub-sites", "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var subSite SubSite
 		if err := rows.Scan(&subSite.SubSiteID, &subSite.SubSiteName, &subSite.SiteID); err != nil {
-			return nil, err
+			return nil, apperr.FromPostgres(err)
 		}
 		allSubSites = append(allSubSites, &subSite)
 	}
@@ -112,30 +125,32 @@ func (repo *Repository) GetAllSubSites() ([]*SubSite, error) {
 }
 
 // GetSubSitesBySiteID retrieves all sub-sites for a given site ID.
-func (repo *Repository) GetSubSitesBySiteID(siteID int) ([]*SubSite, error) {
+func (repo *Repository) GetSubSitesBySiteID(ctx context.Context, siteID int) ([]*SubSite, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var subSites []*SubSite
 
 	query := `SELECT sub_site_id, sub_site_name FROM get_sub_sites_by_site_id($1)`
-	rows, err := repo.db.Query(query, siteID)
+	rows, err := repo.db.QueryContext(ctx, query, siteID)
 	if err != nil {
-		log.Printf("❌ Error retrieving sub-sites for site ID %d: %v", siteID, err)
-		return nil, err // Return the error if query fails
+		logger.Error("failed to query sub-sites for site", "site_id", siteID, "error", err)
+		return nil, apperr.FromPostgres(err) // Return the error if query fails
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var subSite SubSite
 		if err := rows.Scan(&subSite.SubSiteID, &subSite.SubSiteName); err != nil {
-			log.Printf("❌ Error scanning sub-site row: %v", err)
-			return nil, err // Return the error if scanning fails
+			logger.Error("failed to scan sub-site", "site_id", siteID, "error", err)
+			return nil, apperr.FromPostgres(err) // Return the error if scanning fails
 		}
 		subSite.SiteID = siteID               // Set the site ID for the sub-site
 		subSites = append(subSites, &subSite) // Append the sub-site to the slice
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("❌ Error iterating over sub-site rows: %v", err)
-		return nil, err // Return any error encountered during iteration
+		logger.Error("failed to iterate sub-sites", "site_id", siteID, "error", err)
+		return nil, apperr.FromPostgres(err) // Return any error encountered during iteration
 	}
 
 	return subSites, nil
@@ -1,107 +1,122 @@
 // == Handles all logical operations related to site management ==
 package site
 
-import "log"
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+)
 
 type Service struct {
-	repo *Repository
+	repo   *Repository
+	logger *slog.Logger
 }
 
 // NewService creates a new instance of Service with the provided repository.
-func NewService(repo *Repository) *Service {
+func NewService(repo *Repository, logger *slog.Logger) *Service {
 	return &Service{
-		repo: repo,
+		repo:   repo,
+		logger: logger,
 	}
 }
 
 // GetAllSites retrieves all sites from the repository.
-func (service *Service) GetAllSites() ([]*Site, error) {
-	allSites, err := service.repo.GetAllSites()
+func (service *Service) GetAllSites(ctx context.Context) ([]*Site, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	allSites, err := service.repo.GetAllSites(ctx)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching all sites: %v", err)
+		logger.Error("failed to fetch all sites", "error", err)
 		return nil, err
 	}
 
 	if allSites == nil {
 		// If no sites are found, return an empty slice
-		log.Println("No sites found in the system")
+		logger.Debug("no sites found in the system")
 		return nil, nil // No sites found
 	}
 
-	log.Printf("Successfully retrieved %d sites", len(allSites))
+	logger.Debug("retrieved all sites", "count", len(allSites))
 	return allSites, nil
 }
 
 // GetSiteByID retrieves a site by its ID from the repository.
-func (service *Service) GetSiteByID(siteID int) (*Site, error) {
-	site, err := service.repo.GetSiteByID(siteID)
+func (service *Service) GetSiteByID(ctx context.Context, siteID int) (*Site, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	site, err := service.repo.GetSiteByID(ctx, siteID)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching site by ID %d: %v", siteID, err)
+		logger.Error("failed to fetch site", "site_id", siteID, "error", err)
 		return nil, err
 	}
 	if site == nil {
 		// If no site is found, return nil
-		log.Printf("No site found with ID: %d", siteID)
+		logger.Debug("no site found", "site_id", siteID)
 		return nil, nil // No site found
 	}
 
-	log.Printf("Successfully retrieved site by ID: %d", siteID)
 	return site, nil
 }
 
 // GetSubSiteByID retrieves a sub-site by its ID from the repository.
-func (service *Service) GetSubSiteByID(subSiteID int) (*SubSite, error) {
-	subSite, err := service.repo.GetSubSiteByID(subSiteID)
+func (service *Service) GetSubSiteByID(ctx context.Context, subSiteID int) (*SubSite, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	subSite, err := service.repo.GetSubSiteByID(ctx, subSiteID)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching sub-site by ID %d: %v", subSiteID, err)
+		logger.Error("failed to fetch sub-site", "sub_site_id", subSiteID, "error", err)
 		return nil, err
 	}
 
 	if subSite == nil {
 		// If no sub-site is found, return nil
-		log.Printf("No sub-site found with ID: %d", subSiteID)
+		logger.Debug("no sub-site found", "sub_site_id", subSiteID)
 		return nil, nil // No sub-site found
 	}
 
-	log.Printf("Successfully retrieved sub-site by ID: %d", subSiteID)
 	return subSite, nil
 }
 
 // GetAllSubSites retrieves all sub-sites from the database.
-func (service *Service) GetAllSubSites() ([]*SubSite, error) {
-	subSites, err := service.repo.GetAllSubSites()
+func (service *Service) GetAllSubSites(ctx context.Context) ([]*SubSite, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	subSites, err := service.repo.GetAllSubSites(ctx)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching all sub-sites: %v", err)
+		logger.Error("failed to fetch all sub-sites", "error", err)
 		return nil, err
 	}
 	if subSites == nil {
 		// If no sub-sites are found, return an empty slice
-		log.Println("No sub-sites found in the system")
+		logger.Debug("no sub-sites found in the system")
 		return nil, nil // No sub-sites found
 	}
 
-	log.Printf("Successfully retrieved %d sub-sites", len(subSites))
+	logger.Debug("retrieved all sub-sites", "count", len(subSites))
 	return subSites, nil
 }
 
 // GetSubSitesBySiteID retrieves all sub-sites for a given site ID.
-func (service *Service) GetSubSitesBySiteID(siteID int) ([]*SubSite, error) {
-	subSites, err := service.repo.GetSubSitesBySiteID(siteID)
+func (service *Service) GetSubSitesBySiteID(ctx context.Context, siteID int) ([]*SubSite, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	subSites, err := service.repo.GetSubSitesBySiteID(ctx, siteID)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching sub-sites for site ID %d: %v", siteID, err)
+		logger.Error("failed to fetch sub-sites for site", "site_id", siteID, "error", err)
 		return nil, err
 	}
 	if subSites == nil {
 		// If no sub-sites are found, return an empty slice
-		log.Printf("No sub-sites found for site ID: %d", siteID)
+		logger.Debug("no sub-sites found for site", "site_id", siteID)
 		return nil, nil // No sub-sites found
 	}
 
-	log.Printf("Successfully retrieved %d sub-sites for site ID: %d", len(subSites), siteID)
+	logger.Debug("retrieved sub-sites for site", "site_id", siteID, "count", len(subSites))
 	return subSites, nil
 }
@@ -0,0 +1,105 @@
+// == Holds the HTML templates (PDFs and notification emails) embedded into the binary ==
+// == Every template is parsed once at startup; a file with the same name in the override directory replaces the embedded one ==
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+//go:embed *.html
+var embedded embed.FS
+
+// Set is the collection of parsed templates, keyed by file name (e.g. "bap_template.html").
+type Set struct {
+	templates map[string]*template.Template
+}
+
+// Load parses every embedded template. When overrideDir is set, a template with the same file name there is used instead,
+// so ops can customise wording or branding without a rebuild. funcs maps a template name to the functions it needs at parse time.
+// A missing override directory, or a template that fails to parse, is an error so the app fails fast at startup.
+func Load(overrideDir string, funcs map[string]template.FuncMap, logger *slog.Logger) (*Set, error) {
+	if overrideDir != "" {
+		if info, err := os.Stat(overrideDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("template override directory %s is not a readable directory", overrideDir)
+		}
+	}
+
+	names, err := fs.Glob(embedded, "*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	set := &Set{templates: make(map[string]*template.Template, len(names))}
+	var errs []error
+	for _, name := range names {
+		content, source, err := read(name, overrideDir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		parsed, err := template.New(name).Funcs(funcs[name]).Parse(string(content))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse template %s from %s: %w", name, source, err))
+			continue
+		}
+		set.templates[name] = parsed
+
+		if source != "embedded" {
+			logger.Info("using template override", "template", name, "path", source)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	logger.Debug("loaded templates", "count", len(set.templates), "override_dir", overrideDir)
+	return set, nil
+}
+
+// read returns the override file for name if there is one, otherwise the embedded file, along with where it came from.
+func read(name, overrideDir string) ([]byte, string, error) {
+	if overrideDir != "" {
+		overridePath := filepath.Join(overrideDir, name)
+		content, err := os.ReadFile(overridePath)
+		if err == nil {
+			return content, overridePath, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, "", fmt.Errorf("failed to read template override %s: %w", overridePath, err)
+		}
+	}
+
+	content, err := embedded.ReadFile(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read embedded template %s: %w", name, err)
+	}
+	return content, "embedded", nil
+}
+
+// Execute renders the named template into w.
+func (set *Set) Execute(w io.Writer, name string, data any) error {
+	parsed, exists := set.templates[name]
+	if !exists {
+		return fmt.Errorf("template %s does not exist", name)
+	}
+	return parsed.Execute(w, data)
+}
+
+// Render renders the named template and returns the result.
+func (set *Set) Render(name string, data any) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := set.Execute(&buffer, name, data); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,39 @@
+// == Puts a deadline on every request so DB queries and PDF rendering stop when it passes or the client disconnects ==
+package timeout
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware derives the request context with a deadline. routeTimeouts overrides defaultTimeout per route,
+// keyed as "METHOD /route/template" (e.g. "GET /api/report/:session-id/bap.pdf"). A zero timeout means no deadline.
+// The request context is already cancelled by net/http when the client disconnects.
+func Middleware(defaultTimeout time.Duration, routeTimeouts map[string]time.Duration) gin.HandlerFunc {
+	return func(ginContext *gin.Context) {
+		limit := defaultTimeout
+		if routeLimit, exists := routeTimeouts[ginContext.Request.Method+" "+ginContext.FullPath()]; exists {
+			limit = routeLimit
+		}
+		if limit <= 0 {
+			ginContext.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ginContext.Request.Context(), limit)
+		defer cancel()
+		ginContext.Request = ginContext.Request.WithContext(ctx)
+
+		ginContext.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			logging.FromGin(ginContext, slog.Default()).Warn("request exceeded its timeout", "route", ginContext.FullPath(), "timeout", limit.String())
+		}
+	}
+}
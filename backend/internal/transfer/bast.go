@@ -0,0 +1,121 @@
+// == Prints the handover document (Berita Acara Serah Terima, BAST) of an approved transfer ==
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/report"
+
+	"github.com/SebastiaanKlippert/go-wkhtmltopdf"
+)
+
+// bastPDFOptions is the portrait A4 setup used by the BAST.
+var bastPDFOptions = report.PDFOptions{
+	Orientation:  wkhtmltopdf.OrientationPortrait,
+	PageSize:     wkhtmltopdf.PageSizeA4,
+	MarginTop:    15,
+	MarginBottom: 15,
+	MarginLeft:   15,
+	MarginRight:  15,
+}
+
+// bastParty is one side of the handover as printed on the BAST.
+type bastParty struct {
+	OwnerName     string
+	OwnerPosition string
+	CostCenter    string
+	Location      string
+}
+
+// GenerateBASTPDF renders the BAST of an approved transfer and returns the PDF bytes and a filename.
+func (service *Service) GenerateBASTPDF(ctx context.Context, transferID int64) ([]byte, string, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	transfer, err := service.GetTransferByID(ctx, transferID)
+	if err != nil {
+		return nil, "", err
+	}
+	if transfer.Status != "Approved" || !transfer.ReviewedAt.Valid {
+		return nil, "", ErrNotApproved
+	}
+
+	approvedAt := transfer.ReviewedAt.Time.In(service.location)
+	data := struct {
+		DocumentNumber string
+		Date           string
+		Time           string
+		AssetTag       string
+		SerialNumber   string
+		AssetName      string
+		ProductVariety string
+		Equipments     string
+		Reason         string
+		From           bastParty
+		To             bastParty
+		RequesterName  string
+		ApproverName   string
+		ReviewNotes    string
+	}{
+		DocumentNumber: fmt.Sprintf("BAST-MUT/%05d/%s", transfer.ID, approvedAt.Format("01/2006")),
+		Date:           approvedAt.Format("02-01-2006"),
+		Time:           approvedAt.Format("15:04"),
+		AssetTag:       transfer.AssetTag,
+		SerialNumber:   transfer.SerialNumber,
+		AssetName:      transfer.BrandName + " " + transfer.ProductName,
+		ProductVariety: transfer.ProductVariety,
+		Equipments:     orDash(transfer.Equipments.String),
+		Reason:         transfer.Reason,
+		From:           newBASTParty(transfer.From),
+		To:             newBASTParty(transfer.To),
+		RequesterName:  transfer.RequesterName,
+		ApproverName:   transfer.ReviewerName.String,
+		ReviewNotes:    transfer.ReviewNotes,
+	}
+
+	html, err := service.reportService.RenderTemplate("asset_transfer_bast.html", data)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to render template: %w", err)
+	}
+
+	pdfBytes, err := service.reportService.RenderPDF(ctx, html, bastPDFOptions)
+	if err != nil {
+		return nil, "", err
+	}
+
+	logger.Debug("rendered BAST PDF", "transfer_id", transferID, "size_bytes", len(pdfBytes))
+	filename := fmt.Sprintf("BAST_mutasi_%s_%d.pdf", transfer.AssetTag, transfer.ID)
+	return pdfBytes, filename, nil
+}
+
+// newBASTParty formats a side of the transfer. The location is the site followed by the sub-site or department.
+func newBASTParty(party Party) bastParty {
+	location := party.SiteName
+	if party.SubSiteID.Valid {
+		location += " / " + party.SubSiteName.String
+	} else if party.DeptID.Valid {
+		location += " / " + party.DeptName.String
+	}
+
+	costCenter := "-"
+	if party.CostCenterID.Valid {
+		costCenter = strconv.FormatInt(party.CostCenterID.Int64, 10) + " - " + party.CostCenterName.String
+	}
+
+	return bastParty{
+		OwnerName:     party.OwnerName,
+		OwnerPosition: orDash(party.OwnerPosition),
+		CostCenter:    costCenter,
+		Location:      location,
+	}
+}
+
+// orDash prints an empty value as "-".
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
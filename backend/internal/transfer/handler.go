@@ -0,0 +1,237 @@
+// == Handles API requests related to asset transfers ==
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errMissingUser = apperr.Unauthorized("unauthorized", "user not found in context")
+
+// reviewAction is a service method closing a pending transfer: approve, reject or cancel.
+type reviewAction func(ctx context.Context, actor roles.Actor, transferID int64, notes string) (*Transfer, error)
+
+type Handler struct {
+	service *Service
+	logger  *slog.Logger
+}
+
+// NewHandler creates a new asset transfer handler.
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
+	return &Handler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetTransfersHandler lists transfers, filterable by status, asset_tag, site_id (from or to) and user_id (involved user).
+func (handler *Handler) GetTransfersHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	var filter Filter
+	if err := context.ShouldBindQuery(&filter); err != nil {
+		logger.Warn("invalid asset transfer filter", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_query", "invalid query parameters: "+err.Error()))
+		return
+	}
+
+	transfers, totalCount, err := handler.service.GetTransfers(context.Request.Context(), filter)
+	if err != nil {
+		logger.Warn("failed to retrieve asset transfers", "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	serialized := make([]gin.H, 0, len(transfers))
+	for _, transfer := range transfers {
+		serialized = append(serialized, serializeTransfer(transfer))
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"transfers":   serialized,
+		"total_count": totalCount,
+	})
+}
+
+// GetTransferByIDHandler retrieves a transfer.
+func (handler *Handler) GetTransferByIDHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	transferID, ok := transferIDParam(context)
+	if !ok {
+		return
+	}
+
+	transfer, err := handler.service.GetTransferByID(context.Request.Context(), transferID)
+	if err != nil {
+		logger.Warn("failed to retrieve asset transfer", "transfer_id", transferID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, serializeTransfer(transfer))
+}
+
+// RequestTransferHandler requests the transfer of an asset to another owner or location.
+func (handler *Handler) RequestTransferHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
+		return
+	}
+
+	var request TransferRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid asset transfer request", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	created, err := handler.service.RequestTransfer(context.Request.Context(), actor, request)
+	if err != nil {
+		logger.Warn("failed to request asset transfer", "asset_tag", request.AssetTag, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{
+		"message":  "asset transfer requested successfully",
+		"transfer": serializeTransfer(created),
+	})
+}
+
+// ApproveTransferHandler approves a pending transfer and updates the asset. Only the receiving area manager may do this.
+func (handler *Handler) ApproveTransferHandler(context *gin.Context) {
+	handler.review(context, "approved", handler.service.ApproveTransfer)
+}
+
+// RejectTransferHandler rejects a pending transfer. Only the receiving area manager may do this.
+func (handler *Handler) RejectTransferHandler(context *gin.Context) {
+	handler.review(context, "rejected", handler.service.RejectTransfer)
+}
+
+// CancelTransferHandler withdraws a pending transfer. Only its requester or an admin may do this.
+func (handler *Handler) CancelTransferHandler(context *gin.Context) {
+	handler.review(context, "cancelled", handler.service.CancelTransfer)
+}
+
+// GenerateBASTHandler downloads the handover document (BAST) of an approved transfer as a PDF.
+func (handler *Handler) GenerateBASTHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	transferID, ok := transferIDParam(context)
+	if !ok {
+		return
+	}
+
+	pdfBytes, filename, err := handler.service.GenerateBASTPDF(context.Request.Context(), transferID)
+	if err != nil {
+		logger.Error("failed to generate BAST", "transfer_id", transferID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.Header("Content-Disposition", "attachment; filename="+filename)
+	context.Data(http.StatusOK, "application/pdf", pdfBytes)
+}
+
+// review closes a pending transfer through one of the review actions. The notes body is optional.
+func (handler *Handler) review(context *gin.Context, outcome string, action reviewAction) {
+	logger := logging.FromGin(context, handler.logger)
+
+	transferID, ok := transferIDParam(context)
+	if !ok {
+		return
+	}
+	actor, exists := roles.ActorFromContext(context)
+	if !exists {
+		apperr.Abort(context, errMissingUser)
+		return
+	}
+
+	var request ReviewRequest
+	if err := context.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		logger.Warn("invalid asset transfer review request", "transfer_id", transferID, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	transfer, err := action(context.Request.Context(), actor, transferID, request.Notes)
+	if err != nil {
+		logger.Warn("failed to review asset transfer", "transfer_id", transferID, "outcome", outcome, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	logger.Info("reviewed asset transfer", "transfer_id", transferID, "outcome", outcome)
+	context.JSON(http.StatusOK, gin.H{
+		"message":  "asset transfer " + outcome + " successfully",
+		"transfer": serializeTransfer(transfer),
+	})
+}
+
+// serializeTransfer converts a transfer to its JSON form.
+func serializeTransfer(transfer *Transfer) gin.H {
+	var reviewedAt any
+	if transfer.ReviewedAt.Valid {
+		reviewedAt = transfer.ReviewedAt.Time
+	}
+	return gin.H{
+		"id":              transfer.ID,
+		"asset_tag":       transfer.AssetTag,
+		"serial_number":   transfer.SerialNumber,
+		"brand_name":      transfer.BrandName,
+		"product_name":    transfer.ProductName,
+		"product_variety": transfer.ProductVariety,
+		"status":          transfer.Status,
+		"reason":          transfer.Reason,
+		"requester_id":    transfer.RequesterID,
+		"requester_name":  transfer.RequesterName,
+		"requested_at":    transfer.RequestedAt,
+		"from":            serializeParty(transfer.From),
+		"to":              serializeParty(transfer.To),
+		"reviewer_id":     utils.SerializeNI(transfer.ReviewerID),
+		"reviewer_name":   utils.SerializeNS(transfer.ReviewerName),
+		"reviewed_at":     reviewedAt,
+		"review_notes":    transfer.ReviewNotes,
+	}
+}
+
+// serializeParty converts one side of a transfer to its JSON form.
+func serializeParty(party Party) gin.H {
+	return gin.H{
+		"owner_id":         party.OwnerID,
+		"owner_name":       party.OwnerName,
+		"owner_position":   party.OwnerPosition,
+		"cost_center_id":   utils.SerializeNI(party.CostCenterID),
+		"cost_center_name": utils.SerializeNS(party.CostCenterName),
+		"sub_site_id":      utils.SerializeNI(party.SubSiteID),
+		"sub_site_name":    utils.SerializeNS(party.SubSiteName),
+		"dept_id":          utils.SerializeNI(party.DeptID),
+		"dept_name":        utils.SerializeNS(party.DeptName),
+		"site_id":          party.SiteID,
+		"site_name":        party.SiteName,
+	}
+}
+
+// transferIDParam parses the :transfer-id route parameter, aborting the request when it is invalid.
+func transferIDParam(context *gin.Context) (int64, bool) {
+	transferID, err := strconv.ParseInt(context.Param("transfer-id"), 10, 64)
+	if err != nil || transferID <= 0 {
+		apperr.Abort(context, apperr.Validation("invalid_transfer_id", "invalid transfer_id format"))
+		return 0, false
+	}
+	return transferID, true
+}
@@ -0,0 +1,263 @@
+// == Handles all database operations related to asset transfers ==
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+)
+
+// Party is one side of a transfer: the owner and location the asset is handed over from or to.
+// The cost center is the owner's, DeptID is set for head office locations and SubSiteID for area locations.
+type Party struct {
+	OwnerID        int64
+	OwnerName      string
+	OwnerPosition  string
+	CostCenterID   sql.NullInt64
+	CostCenterName sql.NullString
+	SubSiteID      sql.NullInt64
+	SubSiteName    sql.NullString
+	DeptID         sql.NullInt64
+	DeptName       sql.NullString
+	SiteID         int64
+	SiteName       string
+}
+
+// Transfer is an asset transfer (mutasi) along with the asset details printed on its BAST.
+type Transfer struct {
+	ID             int64
+	AssetTag       string
+	SerialNumber   string
+	BrandName      string
+	ProductName    string
+	ProductVariety string
+	Equipments     sql.NullString
+	Status         string // "Pending", "Approved", "Rejected" or "Cancelled"
+	Reason         string
+	RequesterID    int64
+	RequesterName  string
+	RequestedAt    time.Time
+	From           Party
+	To             Party
+	ReviewerID     sql.NullInt64 // The manager who approved or rejected the transfer, or the user who cancelled it
+	ReviewerName   sql.NullString
+	ReviewedAt     sql.NullTime
+	ReviewNotes    string
+}
+
+// TransferRequest is the body of POST /api/transfer. Unset destination fields keep the asset's current owner and location,
+// the cost center is always the one of the receiving owner. At most one of ToSubSiteID or ToDeptID may be set.
+type TransferRequest struct {
+	AssetTag    string `json:"asset_tag" binding:"required"`
+	ToOwnerID   *int64 `json:"to_owner_id"`
+	ToSubSiteID *int64 `json:"to_sub_site_id"`
+	ToDeptID    *int64 `json:"to_dept_id"`
+	Reason      string `json:"reason" binding:"required"`
+}
+
+// ReviewRequest is the optional body of the approve, reject and cancel endpoints.
+type ReviewRequest struct {
+	Notes string `json:"notes"`
+}
+
+// Filter narrows down GET /api/transfer. Every field is optional.
+type Filter struct {
+	Status   *string `json:"status" form:"status"`
+	AssetTag *string `json:"asset_tag" form:"asset_tag"`
+	SiteID   *int    `json:"site_id" form:"site_id"`
+	UserID   *int    `json:"user_id" form:"user_id"`
+	Limit    *int    `json:"limit" form:"limit"`
+	PageNum  *int    `json:"page_num" form:"page_num"`
+}
+
+type Repository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewRepository creates a new asset transfer repository.
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
+	return &Repository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetTransfers retrieves the transfers matching the filter, newest first, along with the total number of matches.
+func (repo *Repository) GetTransfers(ctx context.Context, filter Filter) ([]*Transfer, int64, error) {
+	return repo.queryTransfers(ctx, nil, filter)
+}
+
+// GetTransferByID retrieves a transfer, or nil if it does not exist.
+func (repo *Repository) GetTransferByID(ctx context.Context, transferID int64) (*Transfer, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	transfers, _, err := repo.queryTransfers(ctx, &transferID, Filter{})
+	if err != nil {
+		return nil, err
+	}
+	if len(transfers) == 0 {
+		logger.Debug("no asset transfer found", "transfer_id", transferID)
+		return nil, nil
+	}
+	return transfers[0], nil
+}
+
+// queryTransfers runs get_asset_transfers, for a single transfer when transferID is set.
+func (repo *Repository) queryTransfers(ctx context.Context, transferID *int64, filter Filter) ([]*Transfer, int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_asset_transfers($1, $2, $3, $4, $5, $6, $7)`
+
+	rows, err := repo.db.QueryContext(ctx, query, transferID, filter.Status, filter.AssetTag, filter.SiteID, filter.UserID, filter.Limit, filter.PageNum)
+	if err != nil {
+		logger.Error("failed to query asset transfers", "error", err)
+		return nil, 0, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	transfers := make([]*Transfer, 0)
+	var totalCount int64
+	for rows.Next() {
+		var transfer Transfer
+		if err := rows.Scan(
+			&transfer.ID,
+			&transfer.AssetTag,
+			&transfer.SerialNumber,
+			&transfer.BrandName,
+			&transfer.ProductName,
+			&transfer.ProductVariety,
+			&transfer.Equipments,
+			&transfer.Status,
+			&transfer.Reason,
+			&transfer.RequesterID,
+			&transfer.RequesterName,
+			&transfer.RequestedAt,
+			&transfer.From.OwnerID,
+			&transfer.From.OwnerName,
+			&transfer.From.OwnerPosition,
+			&transfer.From.CostCenterID,
+			&transfer.From.CostCenterName,
+			&transfer.From.SubSiteID,
+			&transfer.From.SubSiteName,
+			&transfer.From.DeptID,
+			&transfer.From.DeptName,
+			&transfer.From.SiteID,
+			&transfer.From.SiteName,
+			&transfer.To.OwnerID,
+			&transfer.To.OwnerName,
+			&transfer.To.OwnerPosition,
+			&transfer.To.CostCenterID,
+			&transfer.To.CostCenterName,
+			&transfer.To.SubSiteID,
+			&transfer.To.SubSiteName,
+			&transfer.To.DeptID,
+			&transfer.To.DeptName,
+			&transfer.To.SiteID,
+			&transfer.To.SiteName,
+			&transfer.ReviewerID,
+			&transfer.ReviewerName,
+			&transfer.ReviewedAt,
+			&transfer.ReviewNotes,
+			&totalCount,
+		); err != nil {
+			logger.Error("failed to scan asset transfer row", "error", err)
+			return nil, 0, apperr.FromPostgres(err)
+		}
+		transfers = append(transfers, &transfer)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("error iterating asset transfer rows", "error", err)
+		return nil, 0, apperr.FromPostgres(err)
+	}
+
+	return transfers, totalCount, nil
+}
+
+// CreateTransfer records a pending transfer and returns its ID.
+func (repo *Repository) CreateTransfer(ctx context.Context, requesterID int64, request TransferRequest) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT create_asset_transfer($1, $2, $3, $4, $5, $6)`
+
+	var transferID int64
+	err := repo.db.QueryRowContext(
+		ctx,
+		query,
+		request.AssetTag,
+		requesterID,
+		request.ToOwnerID,
+		request.ToSubSiteID,
+		request.ToDeptID,
+		request.Reason,
+	).Scan(&transferID)
+	if err != nil {
+		logger.Warn("failed to create asset transfer", "asset_tag", request.AssetTag, "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+
+	logger.Info("created asset transfer", "transfer_id", transferID, "asset_tag", request.AssetTag)
+	return transferID, nil
+}
+
+// IsRequester checks whether a user owns the asset or is the GA staff of its site.
+func (repo *Repository) IsRequester(ctx context.Context, assetTag string, userID int64) (bool, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT is_asset_transfer_requester($1, $2)`
+
+	var requester bool
+	if err := repo.db.QueryRowContext(ctx, query, assetTag, userID).Scan(&requester); err != nil {
+		logger.Error("failed to check asset transfer requester", "asset_tag", assetTag, "user_id", userID, "error", err)
+		return false, apperr.FromPostgres(err)
+	}
+	return requester, nil
+}
+
+// IsApprover checks whether a user is an area manager of the region the asset is transferred to.
+func (repo *Repository) IsApprover(ctx context.Context, transferID int64, userID int64) (bool, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT is_asset_transfer_approver($1, $2)`
+
+	var approver bool
+	if err := repo.db.QueryRowContext(ctx, query, transferID, userID).Scan(&approver); err != nil {
+		logger.Error("failed to check asset transfer approver", "transfer_id", transferID, "user_id", userID, "error", err)
+		return false, apperr.FromPostgres(err)
+	}
+	return approver, nil
+}
+
+// ApproveTransfer approves a pending transfer and applies it to the asset master.
+func (repo *Repository) ApproveTransfer(ctx context.Context, transferID int64, reviewerID int64, notes string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL approve_asset_transfer($1, $2, $3)`
+
+	if _, err := repo.db.ExecContext(ctx, query, transferID, reviewerID, notes); err != nil {
+		logger.Warn("failed to approve asset transfer", "transfer_id", transferID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("approved asset transfer", "transfer_id", transferID, "reviewer_id", reviewerID)
+	return nil
+}
+
+// CloseTransfer rejects or cancels a pending transfer. status is "Rejected" or "Cancelled".
+func (repo *Repository) CloseTransfer(ctx context.Context, transferID int64, status string, reviewerID int64, notes string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL close_asset_transfer($1, $2, $3, $4)`
+
+	if _, err := repo.db.ExecContext(ctx, query, transferID, status, reviewerID, notes); err != nil {
+		logger.Warn("failed to close asset transfer", "transfer_id", transferID, "status", status, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Info("closed asset transfer", "transfer_id", transferID, "status", status, "reviewer_id", reviewerID)
+	return nil
+}
@@ -0,0 +1,211 @@
+// == Handles all logical operations related to asset transfers (mutasi) ==
+// == A transfer is requested by the asset's owner or site GA, approved by the receiving area manager and then applied to the asset master ==
+package transfer
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/report"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
+)
+
+// Errors returned by the transfer workflow.
+var (
+	ErrTransferNotFound = apperr.NotFound("transfer_not_found", "asset transfer not found")
+	ErrNotPending       = apperr.Conflict("transfer_not_pending", "the asset transfer is no longer pending")
+	ErrNotApproved      = apperr.Conflict("transfer_not_approved", "the BAST is only available once the transfer is approved")
+	ErrNotRequester     = apperr.Forbidden("transfer_request_forbidden", "only the asset's owner, the GA staff of its site or an admin can request its transfer")
+	ErrNotApprover      = apperr.Forbidden("transfer_review_forbidden", "only the area manager of the receiving site can review this transfer")
+	ErrNotCancellable   = apperr.Forbidden("transfer_cancel_forbidden", "only the requester or an admin can cancel this transfer")
+	ErrInvalidReason    = apperr.Validation("invalid_reason", "reason must not be empty")
+	ErrInvalidStatus    = apperr.Validation("invalid_query", "status must be one of Pending, Approved, Rejected or Cancelled")
+)
+
+// statuses are the states of a transfer, Pending until it is approved, rejected or cancelled.
+var statuses = []string{"Pending", "Approved", "Rejected", "Cancelled"}
+
+type Service struct {
+	repo          *Repository
+	reportService *report.Service
+	location      *time.Location // Timezone of the dates printed on the BAST
+	transitions   audit.Transitions[*Transfer]
+	logger        *slog.Logger
+}
+
+// NewService creates a new asset transfer service. The report service provides the PDF pipeline used to print the BAST.
+func NewService(repo *Repository, reportService *report.Service, location *time.Location, logger *slog.Logger) *Service {
+	service := &Service{
+		repo:          repo,
+		reportService: reportService,
+		location:      location,
+		logger:        logger,
+	}
+	service.transitions = audit.Transitions[*Transfer]{EntityType: "asset_transfer", Reload: service.GetTransferByID, Snapshot: transferSnapshot}
+	return service
+}
+
+// GetTransfers retrieves the transfers matching the filter, newest first, along with the total number of matches.
+func (service *Service) GetTransfers(ctx context.Context, filter Filter) ([]*Transfer, int64, error) {
+	if filter.Status != nil && *filter.Status != "" {
+		status, ok := utils.MatchFold(statuses, *filter.Status)
+		if !ok {
+			return nil, 0, ErrInvalidStatus
+		}
+		filter.Status = &status
+	}
+	return service.repo.GetTransfers(ctx, filter)
+}
+
+// GetTransferByID retrieves a transfer.
+func (service *Service) GetTransferByID(ctx context.Context, transferID int64) (*Transfer, error) {
+	transfer, err := service.repo.GetTransferByID(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil {
+		return nil, ErrTransferNotFound
+	}
+	return transfer, nil
+}
+
+// RequestTransfer records a pending transfer on behalf of the asset's owner, the GA staff of its site or an admin, and returns it.
+func (service *Service) RequestTransfer(ctx context.Context, actor roles.Actor, request TransferRequest) (*Transfer, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	request.AssetTag = strings.TrimSpace(request.AssetTag)
+	request.Reason = strings.TrimSpace(request.Reason)
+	if request.Reason == "" {
+		return nil, ErrInvalidReason
+	}
+
+	if !actor.IsAdmin() {
+		requester, err := service.repo.IsRequester(ctx, request.AssetTag, actor.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !requester {
+			logger.Warn("asset transfer request denied", "user_id", actor.UserID, "position", actor.Position, "asset_tag", request.AssetTag)
+			return nil, ErrNotRequester
+		}
+	}
+
+	transferID, err := service.repo.CreateTransfer(ctx, actor.UserID, request)
+	if err != nil {
+		return nil, err
+	}
+	created, err := service.GetTransferByID(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit.Record(ctx, audit.Event{
+		Action:     "asset_transfer.request",
+		EntityType: "asset_transfer",
+		EntityID:   strconv.FormatInt(transferID, 10),
+		After:      transferSnapshot(created),
+	})
+	return created, nil
+}
+
+// ApproveTransfer approves a pending transfer on behalf of the receiving area manager. The asset master is updated
+// to the new owner and location, so later opnames no longer report the asset as misplaced. The cost center stays
+// recorded on the transfer; the receiving owner's own cost center is left unchanged.
+func (service *Service) ApproveTransfer(ctx context.Context, actor roles.Actor, transferID int64, notes string) (*Transfer, error) {
+	before, err := service.reviewable(ctx, actor, "approve", transferID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.repo.ApproveTransfer(ctx, transferID, actor.UserID, strings.TrimSpace(notes)); err != nil {
+		return nil, err
+	}
+	return service.transitions.Record(ctx, "asset_transfer.approve", before.ID, before)
+}
+
+// RejectTransfer rejects a pending transfer on behalf of the receiving area manager. The asset is left as it is.
+func (service *Service) RejectTransfer(ctx context.Context, actor roles.Actor, transferID int64, notes string) (*Transfer, error) {
+	before, err := service.reviewable(ctx, actor, "reject", transferID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.repo.CloseTransfer(ctx, transferID, "Rejected", actor.UserID, strings.TrimSpace(notes)); err != nil {
+		return nil, err
+	}
+	return service.transitions.Record(ctx, "asset_transfer.reject", before.ID, before)
+}
+
+// CancelTransfer withdraws a pending transfer on behalf of its requester or an admin.
+func (service *Service) CancelTransfer(ctx context.Context, actor roles.Actor, transferID int64, notes string) (*Transfer, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	before, err := service.GetTransferByID(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != "Pending" {
+		return nil, ErrNotPending
+	}
+	if before.RequesterID != actor.UserID && !actor.IsAdmin() {
+		logger.Warn("asset transfer cancellation denied", "user_id", actor.UserID, "position", actor.Position, "transfer_id", transferID)
+		return nil, ErrNotCancellable
+	}
+
+	if err := service.repo.CloseTransfer(ctx, transferID, "Cancelled", actor.UserID, strings.TrimSpace(notes)); err != nil {
+		return nil, err
+	}
+	return service.transitions.Record(ctx, "asset_transfer.cancel", before.ID, before)
+}
+
+// reviewable loads a transfer and checks that it is pending and that the actor is its receiving manager.
+func (service *Service) reviewable(ctx context.Context, actor roles.Actor, action string, transferID int64) (*Transfer, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	transfer, err := service.GetTransferByID(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Status != "Pending" {
+		return nil, ErrNotPending
+	}
+
+	approver, err := service.repo.IsApprover(ctx, transferID, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !approver {
+		logger.Warn("asset transfer review denied", "action", action, "user_id", actor.UserID, "position", actor.Position, "transfer_id", transferID)
+		return nil, ErrNotApprover
+	}
+	return transfer, nil
+}
+
+// transferSnapshot is the audited state of a transfer.
+func transferSnapshot(transfer *Transfer) map[string]any {
+	return map[string]any{
+		"asset_tag":           transfer.AssetTag,
+		"status":              transfer.Status,
+		"reason":              transfer.Reason,
+		"requester_id":        transfer.RequesterID,
+		"from_owner_id":       transfer.From.OwnerID,
+		"from_cost_center_id": utils.SerializeNI(transfer.From.CostCenterID),
+		"from_sub_site_id":    utils.SerializeNI(transfer.From.SubSiteID),
+		"from_dept_id":        utils.SerializeNI(transfer.From.DeptID),
+		"from_site_id":        transfer.From.SiteID,
+		"to_owner_id":         transfer.To.OwnerID,
+		"to_cost_center_id":   utils.SerializeNI(transfer.To.CostCenterID),
+		"to_sub_site_id":      utils.SerializeNI(transfer.To.SubSiteID),
+		"to_dept_id":          utils.SerializeNI(transfer.To.DeptID),
+		"to_site_id":          transfer.To.SiteID,
+		"reviewer_id":         utils.SerializeNI(transfer.ReviewerID),
+		"review_notes":        transfer.ReviewNotes,
+	}
+}
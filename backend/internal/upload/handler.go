@@ -2,36 +2,42 @@
 package upload
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type Handler struct {
 	service *Service
+	logger  *slog.Logger
 }
 
 // NewHandler creates a new upload handler.
-func NewHandler(service *Service) *Handler {
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
 	return &Handler{
 		service: service,
+		logger:  logger,
 	}
 }
 
 // UploadPhotoHandler handles photo uploads.
 func (handler *Handler) UploadPhotoHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Retrieve the file from the form-data.
 	// "condition_photo" is the 'name' attribute of the file input in the HTML form.
 	file, err := context.FormFile("condition_photo")
 	if err != nil {
-		log.Printf("❌ Error retrieving file from form: %v", err)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "No file received. Make sure the form has 'enctype' set to 'multipart/form-data' and the file input name is 'condition_photo'.",
-		})
+		logger.Warn("no file received in upload", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_upload", "No file received. Make sure the form has 'enctype' set to 'multipart/form-data' and the file input name is 'condition_photo'."))
 		return
 	}
 
@@ -40,14 +46,12 @@ func (handler *Handler) UploadPhotoHandler(context *gin.Context) {
 	filename := uuid.New().String() + filepath.Ext(file.Filename)
 
 	// Define the upload directory.
-	uploadDir := "../uploads/asset_condition_photos"
+	uploadDir := handler.service.Dir("asset_condition_photos")
 
 	// Ensure the upload directory exists.
 	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
-		log.Printf("❌ Error creating upload directory: %v", err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create upload directory.",
-		})
+		logger.Error("failed to create upload directory", "dir", uploadDir, "error", err)
+		apperr.Abort(context, apperr.Internal(err))
 		return
 	}
 
@@ -56,10 +60,8 @@ func (handler *Handler) UploadPhotoHandler(context *gin.Context) {
 
 	// Save the file to the specified path.
 	if err := context.SaveUploadedFile(file, uploadPath); err != nil {
-		log.Printf("❌ Error saving uploaded file: %v", err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to save the uploaded file.",
-		})
+		logger.Error("failed to save uploaded file", "path", uploadPath, "error", err)
+		apperr.Abort(context, apperr.Internal(err))
 		return
 	}
 
@@ -67,18 +69,23 @@ func (handler *Handler) UploadPhotoHandler(context *gin.Context) {
 	oldPhotoURL := context.PostForm("old_condition_photo_url")
 
 	if err := handler.service.DeleteConditionPhoto(oldPhotoURL); err != nil {
-		log.Printf("⚠️ Warning: Could not delete old photo %s: %v", oldPhotoURL, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to delete the old photo.",
-		})
+		logger.Error("failed to delete old photo", "photo_url", oldPhotoURL, "error", err)
+		apperr.Abort(context, apperr.Internal(err))
 		return
 	} else {
-		log.Printf("✅ Successfully deleted old photo: %s", oldPhotoURL)
+		logger.Debug("deleted old photo", "photo_url", oldPhotoURL)
 	}
 
 	// If successful, return the new file path.
 	fileURL := "/uploads/asset_condition_photos/" + filename
-	log.Printf("✅ File uploaded successfully: %s", fileURL)
+	logger.Info("uploaded condition photo", "photo_url", fileURL, "size_bytes", file.Size)
+	audit.Record(context.Request.Context(), audit.Event{
+		Action:     "upload.condition_photo",
+		EntityType: "condition_photo",
+		EntityID:   fileURL,
+		Before:     gin.H{"url": oldPhotoURL},
+		After:      gin.H{"url": fileURL, "original_name": file.Filename, "size_bytes": file.Size},
+	})
 	context.JSON(http.StatusOK, gin.H{
 		"message": "File uploaded successfully",
 		"url":     fileURL,
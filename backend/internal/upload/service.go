@@ -2,31 +2,48 @@
 package upload
 
 import (
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-type Service struct{}
+type Service struct {
+	uploadsDir string // Directory served under /uploads
+	logger     *slog.Logger
+}
+
+// NewService creates a new upload service storing files under uploadsDir.
+func NewService(uploadsDir string, logger *slog.Logger) *Service {
+	return &Service{uploadsDir: uploadsDir, logger: logger}
+}
 
-// NewService creates a new upload service.
-func NewService() *Service {
-	return &Service{}
+// Dir returns the path of a subdirectory of the uploads directory.
+func (service *Service) Dir(subdir string) string {
+	return filepath.Join(service.uploadsDir, subdir)
+}
+
+// IsConditionPhoto reports whether photoURL is a condition photo uploaded through UploadPhotoHandler and still on disk.
+func (service *Service) IsConditionPhoto(photoURL string) bool {
+	if !strings.HasPrefix(photoURL, "/uploads/asset_condition_photos/") || strings.Contains(photoURL, "..") {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(service.uploadsDir, filepath.FromSlash(strings.TrimPrefix(photoURL, "/uploads/"))))
+	return err == nil
 }
 
 // DeleteConditionPhoto deletes an asset's condition photo from the server.
 func (service *Service) DeleteConditionPhoto(photoURL string) error {
 	if photoURL != "" && strings.HasPrefix(photoURL, "/uploads/asset_condition_photo") {
-		// Convert to local path
-		oldFilepath := filepath.Join("..", photoURL)
+		// Convert to local path, the URL is relative to the /uploads route
+		oldFilepath := filepath.Join(service.uploadsDir, filepath.FromSlash(strings.TrimPrefix(photoURL, "/uploads/")))
 
 		// Attempt to remove the old file
 		if err := os.Remove(oldFilepath); err != nil {
-			log.Printf("⚠️ Warning: Could not delete old photo %s: %v", oldFilepath, err)
+			service.logger.Warn("failed to delete photo", "path", oldFilepath, "error", err)
 			return err
 		} else {
-			log.Printf("✅ Successfully deleted old photo: %s", oldFilepath)
+			service.logger.Debug("deleted photo", "path", oldFilepath)
 		}
 	}
 
@@ -2,10 +2,13 @@
 package user
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
 	"github.com/Sam-Gunawan/SOSMIT/backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -14,12 +17,14 @@ import (
 // Handler holds the user repo.
 type Handler struct {
 	service *Service
+	logger  *slog.Logger
 }
 
 // NewHandler creates a new user handler with the provided user service.
-func NewHandler(service *Service) *Handler {
+func NewHandler(service *Service, logger *slog.Logger) *Handler {
 	return &Handler{
 		service: service,
+		logger:  logger,
 	}
 }
 
@@ -28,26 +33,20 @@ func (handler *Handler) GetMeHandler(context *gin.Context) {
 	// Get the username from context (placed by auth middleware)
 	username, exists := context.Get("username")
 	if !exists {
-		context.JSON(http.StatusUnauthorized, gin.H{
-			"error": "username not found in context for: " + username.(string),
-		})
+		apperr.Abort(context, apperr.Unauthorized("unauthorized", "username not found in context for: "+username.(string)))
 		return
 	}
 
 	// Call the service to get user details
-	user, err := handler.service.GetUserByUsername(username.(string))
+	user, err := handler.service.GetUserByUsername(context.Request.Context(), username.(string))
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to fetch user details: " + err.Error(),
-		})
+		apperr.Abort(context, err)
 		return
 	}
 
 	// If user is nil, it means no user was found with that username
 	if user == nil {
-		context.JSON(http.StatusNotFound, gin.H{
-			"error": "user not found with username: " + username.(string),
-		})
+		apperr.Abort(context, apperr.NotFound("user_not_found", "user not found with username: "+username.(string)))
 		return
 	}
 
@@ -55,24 +54,23 @@ func (handler *Handler) GetMeHandler(context *gin.Context) {
 	context.JSON(http.StatusOK, gin.H{"user": serializeUser(user)})
 }
 
-// GetAllUsersHandler retrieves all users in the system.
+// GetAllUsersHandler lists users, filterable by search, position, department, division, site_id, cost_center_id and is_active,
+// and paginated with limit and page_num. Without limit every match is returned.
 func (handler *Handler) GetAllUsersHandler(context *gin.Context) {
-	// Call the service to get all users
-	allUsers, err := handler.service.GetAllUsers()
-	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to fetch all users: " + err.Error(),
-		})
+	logger := logging.FromGin(context, handler.logger)
+
+	var filter UserFilter
+	if err := context.ShouldBindQuery(&filter); err != nil {
+		logger.Warn("invalid user filter", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_query", "invalid query parameters: "+err.Error()))
 		return
 	}
 
-	if allUsers == nil {
-		log.Println("No users found in the system")
-		context.JSON(http.StatusNotFound, gin.H{
-			"message": "no users found",
-		})
-		// No users found, send an empty array
-		allUsers = make([]*User, 0)
+	// Call the service to get the matching users
+	allUsers, totalCount, err := handler.service.GetAllUsers(context.Request.Context(), filter)
+	if err != nil {
+		apperr.Abort(context, err)
+		return
 	}
 
 	// Map users to serialized form
@@ -80,7 +78,10 @@ func (handler *Handler) GetAllUsersHandler(context *gin.Context) {
 	for _, u := range allUsers {
 		serialized = append(serialized, serializeUser(u))
 	}
-	context.JSON(http.StatusOK, gin.H{"users": serialized})
+	context.JSON(http.StatusOK, gin.H{
+		"users":       serialized,
+		"total_count": totalCount,
+	})
 }
 
 // GetUserByIDHandler retrieves a user by their ID.
@@ -88,27 +89,21 @@ func (handler *Handler) GetUserByIDHandler(context *gin.Context) {
 	// Get the user ID from the URL parameters
 	userIDParam := context.Param("user-id")
 	if userIDParam == "" {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "user_id parameter is required",
-		})
+		apperr.Abort(context, apperr.Validation("invalid_user_id", "user_id parameter is required"))
 		return
 	}
 
 	// Convert userIDParam to int64
 	userID, err := strconv.ParseInt(userIDParam, 10, 64)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user_id format",
-		})
+		apperr.Abort(context, apperr.Validation("invalid_user_id", "invalid user_id format"))
 		return
 	}
 
 	// Call the service to get the user by ID
-	user, err := handler.service.GetUserByID(userID)
+	user, err := handler.service.GetUserByID(context.Request.Context(), userID)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to fetch user by ID: " + err.Error(),
-		})
+		apperr.Abort(context, err)
 		return
 	}
 
@@ -124,30 +119,26 @@ func (handler *Handler) GetUserByIDHandler(context *gin.Context) {
 
 // GetUserOpnameLocationsHandler retrieves all the opname locations for a user.
 func (handler *Handler) GetUserOpnameLocationsHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
 	// Get the user ID from context (placed by auth middleware)
 	userID, exists := context.Get("user_id")
 	if !exists {
-		context.JSON(http.StatusUnauthorized, gin.H{
-			"error": "user unauthorized, user_id not found in context",
-		})
+		apperr.Abort(context, apperr.Unauthorized("unauthorized", "user unauthorized, user_id not found in context"))
 		return
 	}
 
 	position, exists := context.Get("position")
 	if !exists {
-		context.JSON(http.StatusUnauthorized, gin.H{
-			"error": "user unauthorized, position not found in context",
-		})
+		apperr.Abort(context, apperr.Unauthorized("unauthorized", "user unauthorized, position not found in context"))
 		return
 	}
 
 	// Bind the query parameters to the OpnameLocationFilter struct
 	var filter OpnameLocationFilter
 	if err := context.ShouldBindQuery(&filter); err != nil {
-		log.Printf("❌ Error binding query parameters: %v", err)
-		context.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid query parameters: " + err.Error(),
-		})
+		logger.Warn("invalid opname location filter", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_query", "invalid query parameters: "+err.Error()))
 		return
 	}
 
@@ -160,18 +151,14 @@ func (handler *Handler) GetUserOpnameLocationsHandler(context *gin.Context) {
 	case float64:
 		userIDInt = int(v)
 	default:
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "invalid user_id type in context",
-		})
+		apperr.Abort(context, apperr.Internal(errors.New("invalid user_id type in context")))
 		return
 	}
 
-	locations, err := handler.service.GetUserOpnameLocations(userIDInt, position.(string), filter)
+	locations, err := handler.service.GetUserOpnameLocations(context.Request.Context(), userIDInt, position.(string), filter)
 	if err != nil {
-		log.Printf("❌ Error retrieving opname locations for user %d: %v", userID, err)
-		context.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to retrieve opname locations: " + err.Error(),
-		})
+		logger.Error("failed to retrieve opname locations", "error", err)
+		apperr.Abort(context, err)
 		return
 	}
 
@@ -205,6 +192,136 @@ func (handler *Handler) GetUserOpnameLocationsHandler(context *gin.Context) {
 	})
 }
 
+// CreateUserHandler creates a user. Only L1 support may do this.
+func (handler *Handler) CreateUserHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	var request CreateUserRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid create user request", "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	created, err := handler.service.CreateUser(context.Request.Context(), context.GetString("position"), request)
+	if err != nil {
+		logger.Warn("failed to create user", "username", request.Username, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusCreated, gin.H{
+		"message": "user created successfully",
+		"user":    serializeUser(created),
+	})
+}
+
+// UpdateUserHandler changes a user's details. Only L1 support may do this.
+func (handler *Handler) UpdateUserHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	userID, ok := userIDParam(context)
+	if !ok {
+		return
+	}
+
+	var request UpdateUserRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		logger.Warn("invalid update user request", "user_id", userID, "error", err)
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	updated, err := handler.service.UpdateUser(context.Request.Context(), context.GetString("position"), userID, request)
+	if err != nil {
+		logger.Warn("failed to update user", "user_id", userID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "user updated successfully",
+		"user":    serializeUser(updated),
+	})
+}
+
+// ResetPasswordRequest is the body of PUT /api/user/:user-id/password.
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// ResetPasswordHandler sets a new password for a user. Only L1 support may do this.
+func (handler *Handler) ResetPasswordHandler(context *gin.Context) {
+	logger := logging.FromGin(context, handler.logger)
+
+	userID, ok := userIDParam(context)
+	if !ok {
+		return
+	}
+
+	var request ResetPasswordRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		apperr.Abort(context, apperr.Validation("invalid_request_body", "invalid request body: "+err.Error()))
+		return
+	}
+
+	if err := handler.service.ResetPassword(context.Request.Context(), context.GetString("position"), userID, request.Password); err != nil {
+		logger.Warn("failed to reset password", "user_id", userID, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{"message": "password reset successfully"})
+}
+
+// DeactivateUserHandler deactivates a user and flags their assets for reassignment. Only L1 support may do this.
+func (handler *Handler) DeactivateUserHandler(context *gin.Context) {
+	handler.setActive(context, false)
+}
+
+// ReactivateUserHandler lets a deactivated user sign in again. Only L1 support may do this.
+func (handler *Handler) ReactivateUserHandler(context *gin.Context) {
+	handler.setActive(context, true)
+}
+
+// setActive handles both DeactivateUserHandler and ReactivateUserHandler.
+func (handler *Handler) setActive(context *gin.Context, active bool) {
+	logger := logging.FromGin(context, handler.logger)
+
+	userID, ok := userIDParam(context)
+	if !ok {
+		return
+	}
+	adminID, _ := context.Get("user_id")
+	adminIDInt, _ := adminID.(int64)
+
+	flaggedAssets, err := handler.service.SetActive(context.Request.Context(), adminIDInt, context.GetString("position"), userID, active)
+	if err != nil {
+		logger.Warn("failed to change user active state", "user_id", userID, "is_active", active, "error", err)
+		apperr.Abort(context, err)
+		return
+	}
+
+	message := "user reactivated successfully"
+	if !active {
+		message = "user deactivated successfully"
+	}
+	context.JSON(http.StatusOK, gin.H{
+		"message":        message,
+		"flagged_assets": flaggedAssets,
+	})
+}
+
+// userIDParam parses the :user-id route parameter, aborting the request when it is invalid.
+func userIDParam(context *gin.Context) (int64, bool) {
+	userID, err := strconv.ParseInt(context.Param("user-id"), 10, 64)
+	if err != nil || userID <= 0 {
+		apperr.Abort(context, apperr.Validation("invalid_user_id", "invalid user_id format"))
+		return 0, false
+	}
+	return userID, true
+}
+
 // serializeUser flattens nullable SQL fields into plain JSON values.
 func serializeUser(u *User) gin.H {
 	return gin.H{
@@ -221,5 +338,6 @@ func serializeUser(u *User) gin.H {
 		"site_group":     utils.SerializeNS(u.SiteGroupName),
 		"region_name":    utils.SerializeNS(u.RegionName),
 		"cost_center_id": utils.SerializeNI(u.CostCenterID),
+		"is_active":      u.IsActive,
 	}
 }
@@ -0,0 +1,69 @@
+// == Hashes and checks user passwords ==
+package user
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLength is the shortest password an admin may set.
+const minPasswordLength = 8
+
+// dummyPasswordHash is a bcrypt hash (at bcrypt.DefaultCost) of a password nobody uses. Logins without a stored hash
+// to compare against are checked against it, so they take as long as a wrong password and do not reveal which usernames exist.
+const dummyPasswordHash = "$2a$10$SG1y9vwMRGbopiXyW3qCnuxUHgEHBII/dPwVLR22cDk6IeFUsWxxu"
+
+// compareHash is bcrypt.CompareHashAndPassword, replaced in tests to observe which checks run bcrypt.
+var compareHash = bcrypt.CompareHashAndPassword
+
+// HashPassword hashes a password with bcrypt for storage.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", apperr.Internal(fmt.Errorf("hash password: %w", err))
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches the stored one. Passwords set through the API are bcrypt hashes;
+// the seeded ones are still plain text until their first login (see IsHashed) or an admin resets them.
+// Plain-text passwords are compared in constant time after a dummy bcrypt check, so every check takes as long as a bcrypt one
+// and the response time reveals neither which usernames exist nor which still have a plain-text password.
+// An empty stored password (SSO-only users) never matches.
+func CheckPassword(stored, password string) bool {
+	if stored == "" {
+		RejectPassword(password)
+		return false
+	}
+	if IsHashed(stored) {
+		return compareHash([]byte(stored), []byte(password)) == nil
+	}
+	RejectPassword(password)
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
+
+// IsHashed reports whether a stored password is a bcrypt hash rather than a seeded plain-text password.
+func IsHashed(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// RejectPassword spends the time of a bcrypt check on a login that fails regardless, e.g. for an unknown username.
+func RejectPassword(password string) {
+	_ = compareHash([]byte(dummyPasswordHash), []byte(password))
+}
+
+// validatePassword checks a new password against the policy.
+func validatePassword(password string) error {
+	switch {
+	case len(password) < minPasswordLength:
+		return apperr.Validation("weak_password", fmt.Sprintf("password must be at least %d characters long", minPasswordLength))
+	case len(password) > 72:
+		// bcrypt ignores everything after 72 bytes.
+		return apperr.Validation("weak_password", "password must be at most 72 bytes long")
+	}
+	return nil
+}
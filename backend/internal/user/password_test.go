@@ -0,0 +1,58 @@
+package user
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPasswordAlwaysRunsBcrypt(t *testing.T) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		stored   string
+		password string
+		want     bool
+	}{
+		{"bcrypt hash, right password", string(hashed), "correct horse", true},
+		{"bcrypt hash, wrong password", string(hashed), "battery staple", false},
+		{"plain text, right password", "correct horse", "correct horse", true},
+		{"plain text, wrong password", "correct horse", "battery staple", false},
+		{"no password", "", "correct horse", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			compareHash = func(hash, password []byte) error {
+				calls++
+				return bcrypt.CompareHashAndPassword(hash, password)
+			}
+			t.Cleanup(func() { compareHash = bcrypt.CompareHashAndPassword })
+
+			if got := CheckPassword(test.stored, test.password); got != test.want {
+				t.Errorf("CheckPassword(%q, %q) = %t, want %t", test.stored, test.password, got, test.want)
+			}
+			if calls != 1 {
+				t.Errorf("CheckPassword(%q, %q) ran bcrypt %d times, want 1", test.stored, test.password, calls)
+			}
+		})
+	}
+}
+
+func TestRejectPasswordRunsBcrypt(t *testing.T) {
+	calls := 0
+	compareHash = func(hash, password []byte) error {
+		calls++
+		return bcrypt.CompareHashAndPassword(hash, password)
+	}
+	t.Cleanup(func() { compareHash = bcrypt.CompareHashAndPassword })
+
+	RejectPassword("anything")
+	if calls != 1 {
+		t.Errorf("RejectPassword ran bcrypt %d times, want 1", calls)
+	}
+}
@@ -2,17 +2,25 @@
 package user
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+
+	"github.com/lib/pq"
 )
 
 // Credentials struct represents a user's login credentials.
 type Credentials struct {
-	UserID   int64
-	Username string
-	Password string
-	Position string
-	OuCode   string
+	UserID      int64
+	Username    string
+	Password    string
+	Position    string
+	OuCode      string
+	OIDCSubject string // Empty until the user first signs in through SSO
+	IsActive    bool   // False once the user left, see internal/directory
 }
 
 // User struct represents a user in the system.
@@ -30,6 +38,49 @@ type User struct {
 	SiteGroupName sql.NullString
 	RegionName    sql.NullString
 	CostCenterID  sql.NullInt64
+	IsActive      bool
+}
+
+// UserFilter narrows down GET /api/user/all. Every field is optional; without limit every match is returned.
+type UserFilter struct {
+	Search       *string `json:"search" form:"search"` // Matches the username, name or email
+	Position     *string `json:"position" form:"position"`
+	Department   *string `json:"department" form:"department"`
+	Division     *string `json:"division" form:"division"`
+	SiteID       *int    `json:"site_id" form:"site_id"`
+	CostCenterID *int    `json:"cost_center_id" form:"cost_center_id"`
+	IsActive     *bool   `json:"is_active" form:"is_active"`
+	Limit        *int    `json:"limit" form:"limit"`
+	PageNum      *int    `json:"page_num" form:"page_num"`
+}
+
+// CreateUserRequest is the body of POST /api/user. UserID is the HR employee number, the next free ID when omitted.
+type CreateUserRequest struct {
+	UserID       *int64 `json:"user_id"`
+	Username     string `json:"username" binding:"required"`
+	Password     string `json:"password" binding:"required"`
+	Email        string `json:"email"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Position     string `json:"position" binding:"required"`
+	Department   string `json:"department"`
+	Division     string `json:"division"`
+	SiteID       *int64 `json:"site_id"`
+	CostCenterID *int64 `json:"cost_center_id"`
+	OuCode       string `json:"ou_code"`
+}
+
+// UpdateUserRequest is the body of PUT /api/user/:user-id. Omitted fields keep their current value.
+type UpdateUserRequest struct {
+	Email        *string `json:"email"`
+	FirstName    *string `json:"first_name"`
+	LastName     *string `json:"last_name"`
+	Position     *string `json:"position"`
+	Department   *string `json:"department"`
+	Division     *string `json:"division"`
+	SiteID       *int64  `json:"site_id"`
+	CostCenterID *int64  `json:"cost_center_id"`
+	OuCode       *string `json:"ou_code"`
 }
 
 type UserSiteCard struct {
@@ -74,54 +125,73 @@ type OpnameLocationFilter struct {
 // NOTE: We create a Repository struct to encapsulate database operations related to User.
 // This allows us to easily manage database interactions and makes it easier to mock for testing.
 type Repository struct {
-	db *sql.DB
+	db     *sql.DB
+	logger *slog.Logger
 }
 
 // NewRepository creates a new instance of Repository with the provided database connection.
-func NewRepository(db *sql.DB) *Repository {
+func NewRepository(db *sql.DB, logger *slog.Logger) *Repository {
 	return &Repository{
-		db: db,
+		db:     db,
+		logger: logger,
 	}
 }
 
 // GetUserCredentials retrieves a user's credentials by their username from the database.
 // It is a method of the Repository struct, which holds the database connection.
-// It returns a User struct containing the username, password, position, and ou code.
-func (repo *Repository) GetUserCredentials(username string) (*Credentials, error) {
+// It returns a Credentials struct containing the username, password, position, ou code and whether the user is active.
+func (repo *Repository) GetUserCredentials(ctx context.Context, username string) (*Credentials, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var credentials Credentials
 
-	// get_credentials returns username, password, position, ou_code
+	// get_credentials returns user_id, username, password, position, ou_code, is_active
 	// It takes in a username with VARCHAR(255) type
 	query := `SELECT * FROM get_credentials($1)`
 
-	err := repo.db.QueryRow(query, username).Scan(&credentials.UserID, &credentials.Username, &credentials.Password, &credentials.Position, &credentials.OuCode)
+	err := repo.db.QueryRowContext(ctx, query, username).Scan(&credentials.UserID, &credentials.Username, &credentials.Password, &credentials.Position, &credentials.OuCode, &credentials.IsActive)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// If no user is found, return nil
 			// It is a valid case where the user does not exist
-			log.Printf("⚠ No credentials found with username: %s\n", username)
+			logger.Debug("no credentials found", "username", username)
 			return nil, nil // No user found
 		}
 
 		// Any other error is unexpected
-		log.Printf("❌ Error retrieving credentials for username: %s, error: %v\n", username, err)
-		return nil, err // Return the error for unexpected cases
+		logger.Error("failed to query credentials", "username", username, "error", err)
+		return nil, apperr.FromPostgres(err) // Return the error for unexpected cases
 	}
 
 	// Return the user credentials
-	log.Printf("✅ Successfully retrieved credentials for username: %s\n", username)
+	logger.Debug("retrieved credentials", "username", username)
 	return &credentials, nil
 }
 
-// GetAllUsers retrieves all users from the database.
-func (repo *Repository) GetAllUsers() ([]*User, error) {
+// GetAllUsers retrieves the users matching the filter, along with the total number of matches.
+func (repo *Repository) GetAllUsers(ctx context.Context, filter UserFilter) ([]*User, int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var allUsers []*User
+	var totalCount int64
 
-	query := `SELECT * FROM get_all_users()`
-	rows, err := repo.db.Query(query)
+	query := `SELECT * FROM get_all_users($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	rows, err := repo.db.QueryContext(
+		ctx,
+		query,
+		filter.Search,
+		filter.Position,
+		filter.Department,
+		filter.Division,
+		filter.SiteID,
+		filter.CostCenterID,
+		filter.IsActive,
+		filter.Limit,
+		filter.PageNum,
+	)
 	if err != nil {
-		log.Printf("❌ Error retrieving all users, error: %v\n", err)
-		return nil, err // Return the error for unexpected cases
+		logger.Error("failed to query all users", "error", err)
+		return nil, 0, apperr.FromPostgres(err) // Return the error for unexpected cases
 	}
 	// Ensure rows are closed after processing
 	defer rows.Close()
@@ -143,10 +213,12 @@ func (repo *Repository) GetAllUsers() ([]*User, error) {
 			&user.SiteGroupName,
 			&user.RegionName,
 			&user.CostCenterID,
+			&user.IsActive,
+			&totalCount,
 		)
 		if err != nil {
-			log.Printf("❌ Error scanning user, error: %v\n", err)
-			return nil, err // Return the error for unexpected cases
+			logger.Error("failed to scan user", "error", err)
+			return nil, 0, apperr.FromPostgres(err) // Return the error for unexpected cases
 		}
 
 		allUsers = append(allUsers, &user)
@@ -154,67 +226,74 @@ func (repo *Repository) GetAllUsers() ([]*User, error) {
 
 	// Check for any error encountered during iteration
 	if err = rows.Err(); err != nil {
-		log.Printf("❌ Error encountered while iterating users, error: %v\n", err)
-		return nil, err // Return the error for unexpected cases
+		logger.Error("failed to iterate users", "error", err)
+		return nil, 0, apperr.FromPostgres(err) // Return the error for unexpected cases
 	}
 
-	log.Printf("✅ Successfully retrieved %d users from the database\n", len(allUsers))
-	return allUsers, nil // Return the slice of users found
+	logger.Debug("retrieved all users", "count", len(allUsers), "total_count", totalCount)
+	return allUsers, totalCount, nil // Return the slice of users found
 }
 
 // GetUserByID retrieves a user's details by their user ID from the database.
-func (repo *Repository) GetUserByID(userID int64) (*User, error) {
+func (repo *Repository) GetUserByID(ctx context.Context, userID int64) (*User, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var user User
 
 	query := `SELECT * FROM get_user_by_id($1)`
 
-	err := repo.db.QueryRow(query, userID).Scan(&user.UserID, &user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Position, &user.Department, &user.Division, &user.SiteID, &user.SiteName, &user.SiteGroupName, &user.RegionName, &user.CostCenterID)
+	err := repo.db.QueryRowContext(ctx, query, userID).Scan(&user.UserID, &user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Position, &user.Department, &user.Division, &user.SiteID, &user.SiteName, &user.SiteGroupName, &user.RegionName, &user.CostCenterID, &user.IsActive)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// If no user is found, return nil
-			log.Printf("⚠ No user found with ID: %d\n", userID)
+			logger.Debug("no user found", "user_id", userID)
 			return nil, nil // No user found
 		}
 
 		// Any other error is unexpected
-		log.Printf("❌ Error retrieving user by ID: %d, error: %v\n", userID, err)
-		return nil, err // Return the error for unexpected cases
+		logger.Error("failed to query user by id", "user_id", userID, "error", err)
+		return nil, apperr.FromPostgres(err) // Return the error for unexpected cases
 	}
 
-	log.Printf("✅ Successfully retrieved user by ID: %d\n", userID)
+	logger.Debug("retrieved user by id", "user_id", userID)
 	return &user, nil
 }
 
 // GetUserByUsername retrieves a user's details by their username from the database.
-func (repo *Repository) GetUserByUsername(username string) (*User, error) {
+func (repo *Repository) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var user User
 
 	query := `SELECT * FROM get_user_by_username($1)`
 
-	err := repo.db.QueryRow(query, username).Scan(&user.UserID, &user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Position, &user.Department, &user.Division, &user.SiteID, &user.SiteName, &user.SiteGroupName, &user.RegionName, &user.CostCenterID)
+	err := repo.db.QueryRowContext(ctx, query, username).Scan(&user.UserID, &user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Position, &user.Department, &user.Division, &user.SiteID, &user.SiteName, &user.SiteGroupName, &user.RegionName, &user.CostCenterID, &user.IsActive)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// If no user is found, return nil
-			log.Printf("⚠ No user found with username: %s\n", username)
+			logger.Debug("no user found", "username", username)
 			return nil, nil // No user found
 		}
 
 		// Any other error is unexpected
-		log.Printf("❌ Error retrieving user by username: %s, error: %v\n", username, err)
-		return nil, err // Return the error for unexpected cases
+		logger.Error("failed to query user by username", "username", username, "error", err)
+		return nil, apperr.FromPostgres(err) // Return the error for unexpected cases
 	}
 
-	log.Printf("✅ Successfully retrieved user by username: %s\n", username)
+	logger.Debug("retrieved user by username", "username", username)
 	return &user, nil
 }
 
 // GetUserOpnameLocations retrieves all the opname locations (with filter and paging) tied to the logged-in user.
-func (repo *Repository) GetUserOpnameLocations(userID int, position string, filter OpnameLocationFilter) ([]OpnameLocations, error) {
+func (repo *Repository) GetUserOpnameLocations(ctx context.Context, userID int, position string, filter OpnameLocationFilter) ([]OpnameLocations, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
 	var locations []OpnameLocations
 
 	query := `SELECT * FROM get_user_opname_locations($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
 
-	rows, err := repo.db.Query(
+	rows, err := repo.db.QueryContext(
+		ctx,
 		query,
 		userID,
 		position,
@@ -232,8 +311,8 @@ func (repo *Repository) GetUserOpnameLocations(userID int, position string, filt
 	)
 
 	if err != nil {
-		log.Printf("❌ Error retrieving opname locations: %v", err)
-		return nil, err
+		logger.Error("failed to query opname locations", "user_id", userID, "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 	defer rows.Close()
 
@@ -251,8 +330,8 @@ func (repo *Repository) GetUserOpnameLocations(userID int, position string, filt
 			&location.LastOpnameBy,
 			&location.TotalCount,
 		); err != nil {
-			log.Printf("❌ Error scanning opname location: %v", err)
-			return nil, err
+			logger.Error("failed to scan opname location", "user_id", userID, "error", err)
+			return nil, apperr.FromPostgres(err)
 		}
 		locations = append(locations, location)
 	}
@@ -260,13 +339,15 @@ func (repo *Repository) GetUserOpnameLocations(userID int, position string, filt
 	return locations, nil
 }
 
-// GetL1SupportEmails retrieves all L1 support emails from the database.
-func (repo *Repository) GetL1SupportEmails() ([]string, error) {
-	query := `SELECT * FROM get_l1_support_emails()`
-	rows, err := repo.db.Query(query)
+// GetEmailsByPositions retrieves the emails of the active users holding one of the positions, matched case-insensitively.
+func (repo *Repository) GetEmailsByPositions(ctx context.Context, positions []string) ([]string, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT email FROM get_user_emails_by_positions($1)`
+	rows, err := repo.db.QueryContext(ctx, query, pq.Array(positions))
 	if err != nil {
-		log.Printf("❌ Error retrieving L1 support emails, error: %v\n", err)
-		return nil, err // Return the error for unexpected cases
+		logger.Error("failed to query user emails by positions", "positions", positions, "error", err)
+		return nil, apperr.FromPostgres(err)
 	}
 	defer rows.Close()
 
@@ -274,39 +355,192 @@ func (repo *Repository) GetL1SupportEmails() ([]string, error) {
 	for rows.Next() {
 		var email string
 		if err := rows.Scan(&email); err != nil {
-			log.Printf("❌ Error scanning L1 support email, error: %v\n", err)
-			return nil, err // Return the error for unexpected cases
+			logger.Error("failed to scan user email", "error", err)
+			return nil, apperr.FromPostgres(err)
 		}
 		emails = append(emails, email)
 	}
+	if err = rows.Err(); err != nil {
+		logger.Error("failed to iterate user emails", "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	logger.Debug("retrieved user emails by positions", "positions", positions, "count", len(emails))
+	return emails, nil
+}
+
+// GetCredentialsByOIDC retrieves the credentials of the user linked to an OIDC subject or, when none is linked yet,
+// of the unlinked users with the given email. Email is not unique, so callers must handle several matches.
+func (repo *Repository) GetCredentialsByOIDC(ctx context.Context, subject, email string) ([]*Credentials, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT * FROM get_credentials_by_oidc($1, $2)`
+
+	rows, err := repo.db.QueryContext(ctx, query, subject, email)
+	if err != nil {
+		logger.Error("failed to query credentials by oidc identity", "subject", subject, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+	defer rows.Close()
+
+	var matches []*Credentials
+	for rows.Next() {
+		var credentials Credentials
+		var linkedSubject sql.NullString
+		if err := rows.Scan(&credentials.UserID, &credentials.Username, &credentials.Password, &credentials.Position, &credentials.OuCode, &linkedSubject, &credentials.IsActive); err != nil {
+			logger.Error("failed to scan credentials", "subject", subject, "error", err)
+			return nil, apperr.FromPostgres(err)
+		}
+		credentials.OIDCSubject = linkedSubject.String
+		matches = append(matches, &credentials)
+	}
 
 	if err = rows.Err(); err != nil {
-		log.Printf("❌ Error encountered while iterating L1 support emails, error: %v\n", err)
-		return nil, err // Return the error for unexpected cases
+		logger.Error("failed to iterate credentials", "subject", subject, "error", err)
+		return nil, apperr.FromPostgres(err)
+	}
+
+	logger.Debug("retrieved credentials by oidc identity", "subject", subject, "count", len(matches))
+	return matches, nil
+}
+
+// LinkOIDCSubject links a user to an OIDC subject so later logins find it without the email fallback.
+func (repo *Repository) LinkOIDCSubject(ctx context.Context, userID int64, subject string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL link_oidc_subject($1, $2)`
+
+	_, err := repo.db.ExecContext(ctx, query, userID, subject)
+	if err != nil {
+		logger.Error("failed to link oidc subject", "user_id", userID, "subject", subject, "error", err)
+		return apperr.FromPostgres(err)
 	}
 
-	log.Printf("✅ Successfully retrieved %d L1 support emails from the database\n", len(emails))
-	return emails, nil // Return the slice of emails found
+	logger.Debug("linked oidc subject", "user_id", userID, "subject", subject)
+	return nil
 }
 
-// GetAreaManagerInfo retrieves the area manager's email and user ID for a given site.
-func (repo *Repository) GetAreaManagerInfo(siteID int64) (int64, string, error) {
+// ProvisionOIDCUser creates a password-less user for an OIDC identity and returns its ID.
+func (repo *Repository) ProvisionOIDCUser(ctx context.Context, subject string, newUser User, ouCode string) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT provision_oidc_user($1, $2, $3, $4, $5, $6, $7)`
+
 	var userID int64
-	var email string
+	err := repo.db.QueryRowContext(ctx, query, subject, newUser.Username, newUser.Email, newUser.FirstName, newUser.LastName, newUser.Position, ouCode).Scan(&userID)
+	if err != nil {
+		logger.Error("failed to provision oidc user", "username", newUser.Username, "subject", subject, "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+
+	logger.Info("provisioned oidc user", "user_id", userID, "username", newUser.Username)
+	return userID, nil
+}
+
+// CreateUser creates a user and returns its ID. password must already be hashed.
+func (repo *Repository) CreateUser(ctx context.Context, request CreateUserRequest, password string) (int64, error) {
+	logger := logging.FromContext(ctx, repo.logger)
 
-	query := `SELECT * FROM get_area_manager_info($1)`
+	query := `SELECT create_user($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 
-	err := repo.db.QueryRow(query, siteID).Scan(&userID, &email)
+	var userID int64
+	err := repo.db.QueryRowContext(
+		ctx,
+		query,
+		request.UserID,
+		request.Username,
+		request.Email,
+		password,
+		request.FirstName,
+		request.LastName,
+		request.Position,
+		request.Department,
+		request.Division,
+		request.SiteID,
+		request.CostCenterID,
+		request.OuCode,
+	).Scan(&userID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Printf("⚠ No area manager found for site ID: %d\n", siteID)
-			return 0, "", nil // No area manager found
-		}
+		logger.Error("failed to create user", "username", request.Username, "error", err)
+		return 0, apperr.FromPostgres(err)
+	}
+
+	logger.Info("created user", "user_id", userID, "username", request.Username)
+	return userID, nil
+}
+
+// UpdateUser changes a user's details, keeping the fields the request omits.
+func (repo *Repository) UpdateUser(ctx context.Context, userID int64, request UpdateUserRequest) error {
+	logger := logging.FromContext(ctx, repo.logger)
 
-		log.Printf("❌ Error retrieving area manager info for site ID: %d, error: %v\n", siteID, err)
-		return 0, "", err // Return the error for unexpected cases
+	query := `CALL update_user($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err := repo.db.ExecContext(
+		ctx,
+		query,
+		userID,
+		request.Email,
+		request.FirstName,
+		request.LastName,
+		request.Position,
+		request.Department,
+		request.Division,
+		request.SiteID,
+		request.CostCenterID,
+		request.OuCode,
+	)
+	if err != nil {
+		logger.Error("failed to update user", "user_id", userID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Debug("updated user", "user_id", userID)
+	return nil
+}
+
+// SetPassword replaces a user's password. password must already be hashed.
+func (repo *Repository) SetPassword(ctx context.Context, userID int64, password string) error {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `CALL set_user_password($1, $2)`
+
+	_, err := repo.db.ExecContext(ctx, query, userID, password)
+	if err != nil {
+		logger.Error("failed to set user password", "user_id", userID, "error", err)
+		return apperr.FromPostgres(err)
+	}
+
+	logger.Debug("set user password", "user_id", userID)
+	return nil
+}
+
+// IsActive reports whether a user exists and has not been deactivated.
+func (repo *Repository) IsActive(ctx context.Context, userID int64) (bool, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT is_user_active($1)`
+
+	var active bool
+	if err := repo.db.QueryRowContext(ctx, query, userID).Scan(&active); err != nil {
+		logger.Error("failed to check whether user is active", "user_id", userID, "error", err)
+		return false, apperr.FromPostgres(err)
+	}
+	return active, nil
+}
+
+// SetActive deactivates or reactivates a user. It returns how many of the user's assets were flagged for reassignment.
+func (repo *Repository) SetActive(ctx context.Context, userID int64, active bool) (int, error) {
+	logger := logging.FromContext(ctx, repo.logger)
+
+	query := `SELECT set_user_active($1, $2)`
+
+	var flaggedAssets int
+	err := repo.db.QueryRowContext(ctx, query, userID, active).Scan(&flaggedAssets)
+	if err != nil {
+		logger.Error("failed to set user active state", "user_id", userID, "is_active", active, "error", err)
+		return 0, apperr.FromPostgres(err)
 	}
 
-	log.Printf("✅ Successfully retrieved area manager info for site ID: %d\n", siteID)
-	return userID, email, nil
+	logger.Debug("set user active state", "user_id", userID, "is_active", active, "flagged_assets", flaggedAssets)
+	return flaggedAssets, nil
 }
@@ -2,90 +2,332 @@
 package user
 
 import (
-	"errors"
-	"log"
+	"context"
+	"log/slog"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/audit"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/logging"
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/roles"
+)
+
+// Errors returned by the user administration.
+var (
+	ErrAdminOnly       = apperr.Forbidden("user_admin_forbidden", "only an admin can manage users")
+	ErrUserNotFound    = apperr.NotFound("user_not_found", "user not found")
+	ErrDeactivateSelf  = apperr.Conflict("deactivate_self", "you cannot deactivate your own account")
+	ErrInvalidUsername = apperr.Validation("invalid_username", "username must not be empty, contain spaces or be VACANT")
+	ErrInvalidEmail    = apperr.Validation("invalid_email", "email is not a valid address")
+	ErrInvalidOuCode   = apperr.Validation("invalid_ou_code", "ou_code must be at most 5 characters long")
+	ErrInvalidPosition = apperr.Validation("invalid_position", "position must not be empty")
+	ErrNothingToUpdate = apperr.Validation("nothing_to_update", "the request changes no field")
+	ErrInvalidUserID   = apperr.Validation("invalid_user_id", "user_id must be a positive integer")
 )
 
 type Service struct {
-	repo *Repository
+	repo   *Repository
+	logger *slog.Logger
 }
 
 // NewService creates a new instance of the user service.
-func NewService(repo *Repository) *Service {
+func NewService(repo *Repository, logger *slog.Logger) *Service {
 	return &Service{
-		repo: repo,
+		repo:   repo,
+		logger: logger,
 	}
 }
 
 // GetUserByUsername retrieves a user by their username.
-func (service *Service) GetUserByUsername(username string) (*User, error) {
-	user, err := service.repo.GetUserByUsername(username)
+func (service *Service) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	user, err := service.repo.GetUserByUsername(ctx, username)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching user by username %s: %v", username, err)
+		logger.Error("failed to fetch user by username", "username", username, "error", err)
 		return nil, err
 	}
 	if user == nil {
 		// If no user is found, return nil
-		log.Printf("No user found with username: %s", username)
+		logger.Debug("no user found", "username", username)
 		return nil, nil // No user found
 	}
 
-	log.Printf("Successfully retrieved user by username: %s", username)
 	return user, nil
 }
 
-// GetAllUsers retrieves all users from the repository.
-func (service *Service) GetAllUsers() ([]*User, error) {
-	allUsers, err := service.repo.GetAllUsers()
+// GetAllUsers retrieves the users matching the filter, along with the total number of matches.
+func (service *Service) GetAllUsers(ctx context.Context, filter UserFilter) ([]*User, int64, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	allUsers, totalCount, err := service.repo.GetAllUsers(ctx, filter)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching all users: %v", err)
-		return nil, err
+		logger.Error("failed to fetch all users", "error", err)
+		return nil, 0, err
 	}
 
-	if allUsers == nil {
-		// If no users are found, return an empty slice
-		log.Println("No users found in the system")
-		return nil, nil // No users found
-	}
-
-	log.Printf("Successfully retrieved %d users", len(allUsers))
-	return allUsers, nil
+	logger.Debug("retrieved all users", "count", len(allUsers), "total_count", totalCount)
+	return allUsers, totalCount, nil
 }
 
 // GetUserByID retrieves a user by their ID.
-func (service *Service) GetUserByID(userID int64) (*User, error) {
-	user, err := service.repo.GetUserByID(userID)
+func (service *Service) GetUserByID(ctx context.Context, userID int64) (*User, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	user, err := service.repo.GetUserByID(ctx, userID)
 	if err != nil {
 		// Log the error and return it
-		log.Printf("Error fetching user by ID %d: %v", userID, err)
+		logger.Error("failed to fetch user by id", "user_id", userID, "error", err)
 		return nil, err
 	}
 	if user == nil {
 		// If no user is found, return nil
-		log.Printf("No user found with ID: %d", userID)
+		logger.Debug("no user found", "user_id", userID)
 		return nil, nil // No user found
 	}
 
-	log.Printf("Successfully retrieved user by ID: %d", userID)
 	return user, nil
 }
 
 // GetUserOpnameLocation retrieves all the opname locations for a user.
-func (service *Service) GetUserOpnameLocations(userID int, position string, filter OpnameLocationFilter) ([]OpnameLocations, error) {
+func (service *Service) GetUserOpnameLocations(ctx context.Context, userID int, position string, filter OpnameLocationFilter) ([]OpnameLocations, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
 	// Validate userID
 	if userID <= 0 {
-		log.Printf("⚠ Invalid userID: %d", userID)
-		return nil, errors.New("invalid userID")
+		logger.Warn("invalid user id", "user_id", userID)
+		return nil, apperr.Validation("invalid_user_id", "user_id must be a positive integer")
 	}
 
 	// Call the repository to get the user's opname locations
-	locations, err := service.repo.GetUserOpnameLocations(userID, position, filter)
+	locations, err := service.repo.GetUserOpnameLocations(ctx, userID, position, filter)
 	if err != nil {
-		log.Printf("❌ Error retrieving opname locations for user %d: %v", userID, err)
+		logger.Error("failed to retrieve opname locations", "user_id", userID, "error", err)
 		return nil, err
 	}
 
 	return locations, nil
 }
+
+// CreateUser creates a user on behalf of L1 support and returns it.
+func (service *Service) CreateUser(ctx context.Context, adminPosition string, request CreateUserRequest) (*User, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !isUserAdmin(adminPosition) {
+		logger.Warn("user creation denied", "position", adminPosition)
+		return nil, ErrAdminOnly
+	}
+
+	request.Username = strings.TrimSpace(request.Username)
+	request.Email = strings.TrimSpace(request.Email)
+	request.FirstName = strings.TrimSpace(request.FirstName)
+	request.LastName = strings.TrimSpace(request.LastName)
+	request.Position = strings.ToUpper(strings.TrimSpace(request.Position))
+	request.Department = strings.TrimSpace(request.Department)
+	request.Division = strings.TrimSpace(request.Division)
+	request.OuCode = strings.TrimSpace(request.OuCode)
+	switch {
+	case request.Username == "" || strings.ContainsAny(request.Username, " \t") || strings.EqualFold(request.Username, "vacant"):
+		return nil, ErrInvalidUsername
+	case request.UserID != nil && *request.UserID <= 1: // 1 is the VACANT placeholder
+		return nil, ErrInvalidUserID
+	case request.Position == "":
+		return nil, ErrInvalidPosition
+	}
+	if err := validateEmail(request.Email); err != nil {
+		return nil, err
+	}
+	if len(request.OuCode) > 5 {
+		return nil, ErrInvalidOuCode
+	}
+	if err := validatePassword(request.Password); err != nil {
+		return nil, err
+	}
+
+	hash, err := HashPassword(request.Password)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := service.repo.CreateUser(ctx, request, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := service.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if created == nil {
+		return nil, ErrUserNotFound
+	}
+
+	logger.Info("user created", "user_id", userID, "username", created.Username)
+	audit.Record(ctx, audit.Event{
+		Action:     "user.create",
+		EntityType: "user",
+		EntityID:   strconv.FormatInt(userID, 10),
+		After:      serializeUser(created),
+	})
+	return created, nil
+}
+
+// UpdateUser changes a user's position, department, division, site, cost center or contact details on behalf of L1 support.
+func (service *Service) UpdateUser(ctx context.Context, adminPosition string, userID int64, request UpdateUserRequest) (*User, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !isUserAdmin(adminPosition) {
+		logger.Warn("user update denied", "position", adminPosition, "user_id", userID)
+		return nil, ErrAdminOnly
+	}
+
+	trim := func(value *string) {
+		if value != nil {
+			*value = strings.TrimSpace(*value)
+		}
+	}
+	trim(request.Email)
+	trim(request.FirstName)
+	trim(request.LastName)
+	trim(request.Department)
+	trim(request.Division)
+	trim(request.OuCode)
+	if request.Position != nil {
+		*request.Position = strings.ToUpper(strings.TrimSpace(*request.Position))
+		if *request.Position == "" {
+			return nil, ErrInvalidPosition
+		}
+	}
+	if request.Email != nil {
+		if err := validateEmail(*request.Email); err != nil {
+			return nil, err
+		}
+	}
+	if request.OuCode != nil && len(*request.OuCode) > 5 {
+		return nil, ErrInvalidOuCode
+	}
+	if request == (UpdateUserRequest{}) {
+		return nil, ErrNothingToUpdate
+	}
+
+	before, err := service.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := service.repo.UpdateUser(ctx, userID, request); err != nil {
+		return nil, err
+	}
+
+	after, err := service.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if after == nil {
+		return nil, ErrUserNotFound
+	}
+
+	logger.Info("user updated", "user_id", userID)
+	audit.Record(ctx, audit.Event{
+		Action:     "user.update",
+		EntityType: "user",
+		EntityID:   strconv.FormatInt(userID, 10),
+		Before:     serializeUser(before),
+		After:      serializeUser(after),
+	})
+	return after, nil
+}
+
+// ResetPassword sets a new password for a user on behalf of L1 support.
+func (service *Service) ResetPassword(ctx context.Context, adminPosition string, userID int64, password string) error {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !isUserAdmin(adminPosition) {
+		logger.Warn("password reset denied", "position", adminPosition, "user_id", userID)
+		return ErrAdminOnly
+	}
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := service.repo.SetPassword(ctx, userID, hash); err != nil {
+		return err
+	}
+
+	logger.Info("user password reset", "user_id", userID)
+	audit.Record(ctx, audit.Event{
+		Action:     "user.password_reset",
+		EntityType: "user",
+		EntityID:   strconv.FormatInt(userID, 10),
+	})
+	return nil
+}
+
+// SetActive deactivates or reactivates a user on behalf of L1 support. A deactivated user can no longer sign in,
+// and their assets are flagged for reassignment. The directory sync keeps the change until the directory agrees with it.
+// It returns how many assets were flagged.
+func (service *Service) SetActive(ctx context.Context, adminID int64, adminPosition string, userID int64, active bool) (int, error) {
+	logger := logging.FromContext(ctx, service.logger)
+
+	if !isUserAdmin(adminPosition) {
+		logger.Warn("user activation change denied", "position", adminPosition, "user_id", userID, "is_active", active)
+		return 0, ErrAdminOnly
+	}
+	if !active && userID == adminID {
+		return 0, ErrDeactivateSelf
+	}
+
+	before, err := service.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if before == nil {
+		return 0, ErrUserNotFound
+	}
+
+	flaggedAssets, err := service.repo.SetActive(ctx, userID, active)
+	if err != nil {
+		return 0, err
+	}
+
+	action := "user.reactivate"
+	if !active {
+		action = "user.deactivate"
+	}
+	logger.Info("user active state changed", "user_id", userID, "is_active", active, "flagged_assets", flaggedAssets)
+	audit.Record(ctx, audit.Event{
+		Action:     action,
+		EntityType: "user",
+		EntityID:   strconv.FormatInt(userID, 10),
+		Before:     map[string]any{"is_active": before.IsActive},
+		After:      map[string]any{"is_active": active, "flagged_assets": flaggedAssets},
+	})
+	return flaggedAssets, nil
+}
+
+// isUserAdmin reports whether a position may manage users, which the admin positions do.
+func isUserAdmin(position string) bool {
+	return roles.IsAdmin(position)
+}
+
+// validateEmail accepts an empty email (the seed data has users without one) or a bare address.
+func validateEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return ErrInvalidEmail
+	}
+	return nil
+}
@@ -3,9 +3,11 @@ package utils
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+
+	"github.com/Sam-Gunawan/SOSMIT/backend/internal/apperr"
 )
 
 // SerializeNS converts sql.NullString to either its string value or nil.
@@ -66,7 +68,7 @@ func ParseLocationParams(siteIDParam, deptIDParam string) (siteID *int, deptID *
 	// Read optional query params: /api/opname/location?site_id=1&dept_id=2
 	if siteIDParam != "" {
 		if parsedSiteID, err := strconv.Atoi(siteIDParam); err != nil {
-			return nil, nil, errors.New("invalid site_id, must be an integer: " + err.Error())
+			return nil, nil, apperr.Validation("invalid_location", "invalid site_id, must be an integer")
 		} else {
 			siteID = &parsedSiteID
 		}
@@ -74,15 +76,43 @@ func ParseLocationParams(siteIDParam, deptIDParam string) (siteID *int, deptID *
 
 	if deptIDParam != "" {
 		if parsedDeptID, err := strconv.Atoi(deptIDParam); err != nil {
-			return nil, nil, errors.New("invalid dept_id, must be an integer: " + err.Error())
+			return nil, nil, apperr.Validation("invalid_location", "invalid dept_id, must be an integer")
 		} else {
 			deptID = &parsedDeptID
 		}
 	}
 
 	if siteID == nil && deptID == nil {
-		return nil, nil, errors.New("at least one of site_id or dept_id must be provided")
+		return nil, nil, apperr.Validation("invalid_location", "at least one of site_id or dept_id must be provided")
 	}
 
 	return siteID, deptID, nil
 }
+
+// FormatRupiah formats an amount in rupiah the Indonesian way, e.g. "Rp 12.500.000" or "-Rp 1.000".
+func FormatRupiah(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.FormatInt(amount, 10)
+	grouped := make([]byte, 0, len(digits)+len(digits)/3)
+	for i := range len(digits) {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped = append(grouped, '.')
+		}
+		grouped = append(grouped, digits[i])
+	}
+	return sign + "Rp " + string(grouped)
+}
+
+// MatchFold returns the known value equal to value ignoring case, e.g. to accept "pending" for the status "Pending".
+func MatchFold(known []string, value string) (string, bool) {
+	for _, candidate := range known {
+		if strings.EqualFold(value, candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}